@@ -0,0 +1,14 @@
+import json
+
+from chalicelib.utils import pg_client
+
+
+def save(job, certificate):
+    # There is no audit log, the certificate is kept with the job and returned by the jobs API
+    with pg_client.PostgresClient() as cur:
+        cur.execute(
+            cur.mogrify("""UPDATE public.jobs
+                           SET certificate = %(certificate)s::jsonb
+                           WHERE job_id = %(job_id)s;""",
+                        {"job_id": job["jobId"], "certificate": json.dumps(certificate)})
+        )
@@ -0,0 +1,83 @@
+import hashlib
+import hmac
+import json
+
+from decouple import config
+
+from chalicelib.core import sessions_mobs, deletion_audit
+from chalicelib.utils import pg_client
+from chalicelib.utils.TimeUTC import TimeUTC
+
+CERTIFICATE_VERSION = 1
+
+
+def __get_secret():
+    return config("DELETION_CERTIFICATE_SECRET", default=config("jwt_secret"))
+
+
+def __sign(certificate):
+    data = {k: v for k, v in certificate.items() if k != "signature"}
+    message = json.dumps(data, sort_keys=True, separators=(",", ":"))
+    return hmac.new(__get_secret().encode(), message.encode(), hashlib.sha256).hexdigest()
+
+
+def __get_session_tables(cur):
+    # All tables which reference sessions are checked, including the ones added after this code
+    cur.execute("""SELECT kcu.table_schema, kcu.table_name, kcu.column_name
+                   FROM information_schema.referential_constraints AS rc
+                            INNER JOIN information_schema.key_column_usage AS kcu
+                                       USING (constraint_schema, constraint_name)
+                            INNER JOIN information_schema.constraint_column_usage AS ccu
+                                       USING (constraint_schema, constraint_name)
+                   WHERE ccu.table_schema = 'public'
+                     AND ccu.table_name = 'sessions'
+                   ORDER BY 1, 2;""")
+    return [("public", "sessions", "session_id")] + \
+           [(r["table_schema"], r["table_name"], r["column_name"]) for r in cur.fetchall()]
+
+
+def __get_remaining_rows(session_ids):
+    if len(session_ids) == 0:
+        return []
+    remaining = []
+    with pg_client.PostgresClient(unlimited_query=True) as cur:
+        for schema, table, column in __get_session_tables(cur):
+            cur.execute(cur.mogrify(f"""SELECT COUNT(1) AS count
+                                        FROM "{schema}"."{table}"
+                                        WHERE "{column}" IN %(session_ids)s;""",
+                                    {"session_ids": tuple(session_ids)}))
+            remaining.append({"table": f"{schema}.{table}", "rows": cur.fetchone()["count"]})
+    return remaining
+
+
+def create(job, session_ids):
+    rows = __get_remaining_rows(session_ids)
+    objects = sessions_mobs.get_remaining_mobs(session_ids)
+    certificate = {
+        "version": CERTIFICATE_VERSION,
+        "jobId": job["jobId"],
+        "projectId": job["projectId"],
+        "action": job["action"],
+        # The certificate is kept in the audit log, the data subject isn't stored in clear
+        "referenceIdHash": hashlib.sha256(job["referenceId"].encode()).hexdigest(),
+        "sessionIds": [str(s) for s in sorted(session_ids)],
+        "remainingRows": rows,
+        "remainingObjects": objects,
+        "verified": all(r["rows"] == 0 for r in rows) and len(objects) == 0,
+        "issuedAt": TimeUTC.now(),
+        "algorithm": "HMAC-SHA256"
+    }
+    certificate["signature"] = __sign(certificate)
+    return certificate
+
+
+def is_valid(certificate):
+    if certificate is None or "signature" not in certificate:
+        return False
+    return hmac.compare_digest(certificate["signature"], __sign(certificate))
+
+
+def issue(job, session_ids):
+    certificate = create(job=job, session_ids=session_ids)
+    deletion_audit.save(job=job, certificate=certificate)
+    return certificate
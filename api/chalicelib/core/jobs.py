@@ -1,6 +1,6 @@
 from chalicelib.utils import pg_client, helper
 from chalicelib.utils.TimeUTC import TimeUTC
-from chalicelib.core import sessions, sessions_mobs
+from chalicelib.core import sessions, sessions_mobs, deletion_certificates
 
 
 class Actions:
@@ -31,7 +31,7 @@ def get(job_id):
 
         format_datetime(data)
 
-    return helper.dict_to_camel_case(data)
+    return helper.dict_to_camel_case(data, ignore_keys=["certificate"])
 
 
 def get_all(project_id):
@@ -48,7 +48,7 @@ def get_all(project_id):
         data = cur.fetchall()
         for record in data:
             format_datetime(record)
-    return helper.list_to_camel_case(data)
+    return [helper.dict_to_camel_case(r, ignore_keys=["certificate"]) for r in data]
 
 
 def create(project_id, data):
@@ -103,7 +103,7 @@ def update(job_id, job):
 
         r = cur.fetchone()
         format_datetime(r)
-        record = helper.dict_to_camel_case(r)
+        record = helper.dict_to_camel_case(r, ignore_keys=["certificate"])
     return record
 
 
@@ -136,26 +136,30 @@ def execute_jobs():
         return
 
     for job in jobs:
-        print(f"job can be executed {job['id']}")
+        print(f"job can be executed {job['jobId']}")
         try:
             if job["action"] == Actions.DELETE_USER_DATA:
                 session_ids = sessions.get_session_ids_by_user_ids(
-                    project_id=job["projectId"], user_ids=job["referenceId"]
+                    project_id=job["projectId"], user_ids=[job["referenceId"]]
                 )
 
                 sessions.delete_sessions_by_session_ids(session_ids)
                 sessions_mobs.delete_mobs(session_ids)
+                # The deletion is verified and certified, the certificate is kept even if something remained
+                certificate = deletion_certificates.issue(job=job, session_ids=session_ids)
+                if not certificate["verified"]:
+                    raise Exception("Deleted data is still present, see the deletion certificate.")
             else:
                 raise Exception(f"The action {job['action']} not supported.")
 
             job["status"] = JobStatus.COMPLETED
-            print(f"job completed {job['id']}")
+            print(f"job completed {job['jobId']}")
         except Exception as e:
             job["status"] = JobStatus.FAILED
-            job["error"] = str(e)
-            print(f"job failed {job['id']}")
+            job["errors"] = str(e)
+            print(f"job failed {job['jobId']}")
 
-        update(job["job_id"], job)
+        update(job["jobId"], job)
 
 
 def group_user_ids_by_project_id(jobs, now):
@@ -1201,11 +1201,14 @@ def get_session_ids_by_user_ids(project_id, user_ids):
                 project_id = %(project_id)s AND user_id IN %(userId)s;""",
             {"project_id": project_id, "userId": tuple(user_ids)}
         )
-        ids = cur.execute(query=query)
-    return ids
+        cur.execute(query=query)
+        rows = cur.fetchall()
+    return [r["session_id"] for r in rows]
 
 
 def delete_sessions_by_session_ids(session_ids):
+    if len(session_ids) == 0:
+        return True
     with pg_client.PostgresClient(unlimited_query=True) as cur:
         query = cur.mogrify(
             """\
@@ -1,10 +1,29 @@
+import hashlib
+import hmac
+import time
+from urllib.parse import quote, urlencode
+
 from decouple import config
 
 from chalicelib.utils import s3
 from chalicelib.utils.s3 import client
 
+MOB_URL_TTL = 100000
+
+
+def __get_replay_file_url(key):
+    # Same signature as the http service checks, the file is decompressed by it
+    expires = int((time.time() + MOB_URL_TTL) * 1000)
+    signature = hmac.new(config("REPLAY_FILES_SECRET").encode(), f"{key}:{expires}".encode(),
+                         hashlib.sha256).hexdigest()
+    return f"{config('REPLAY_FILES_URL').rstrip('/')}/v1/replay/files/{quote(key, safe='')}?" \
+           + urlencode({"expires": expires, "signature": signature})
+
 
 def get_web(sessionId):
+    # Files compressed with project dictionaries can't be read by the player, they go through the http service
+    if len(config("REPLAY_FILES_URL", default="")) > 0:
+        return [__get_replay_file_url(str(sessionId)), __get_replay_file_url(str(sessionId) + "e")]
     return [
         client.generate_presigned_url(
             'get_object',
@@ -12,7 +31,7 @@ def get_web(sessionId):
                 'Bucket': config("sessions_bucket"),
                 'Key': str(sessionId)
             },
-            ExpiresIn=100000
+            ExpiresIn=MOB_URL_TTL
         ),
         client.generate_presigned_url(
             'get_object',
@@ -20,7 +39,7 @@ def get_web(sessionId):
                 'Bucket': config("sessions_bucket"),
                 'Key': str(sessionId) + "e"
             },
-            ExpiresIn=100000
+            ExpiresIn=MOB_URL_TTL
         )]
 
 
@@ -35,6 +54,20 @@ def get_ios(sessionId):
     )
 
 
+def __get_mob_keys(session_id):
+    return [str(session_id), str(session_id) + "e"]
+
+
 def delete_mobs(session_ids):
     for session_id in session_ids:
-        s3.schedule_for_deletion(config("sessions_bucket"), session_id)
+        for key in __get_mob_keys(session_id):
+            s3.delete_all_versions(config("sessions_bucket"), key)
+
+
+def get_remaining_mobs(session_ids):
+    remaining = []
+    for session_id in session_ids:
+        for key in __get_mob_keys(session_id):
+            if s3.has_versions(config("sessions_bucket"), key):
+                remaining.append(key)
+    return remaining
@@ -83,6 +83,23 @@ def rename(source_bucket, source_key, target_bucket, target_key):
     s3.Object(source_bucket, source_key).delete()
 
 
+def delete_all_versions(bucket, key):
+    # Versioned buckets keep previous versions after the regular delete
+    objects = [{"Key": key, "VersionId": v["VersionId"]} for v in __get_versions(bucket, key)]
+    if len(objects) == 0:
+        return
+    client.delete_objects(Bucket=bucket, Delete={"Objects": objects, "Quiet": True})
+
+
+def has_versions(bucket, key):
+    return len(__get_versions(bucket, key)) > 0
+
+
+def __get_versions(bucket, key):
+    response = client.list_object_versions(Bucket=bucket, Prefix=key)
+    return [v for v in response.get("Versions", []) + response.get("DeleteMarkers", []) if v["Key"] == key]
+
+
 def schedule_for_deletion(bucket, key):
     s3 = __get_s3_resource()
     s3_object = s3.Object(bucket, key)
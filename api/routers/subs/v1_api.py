@@ -1,7 +1,7 @@
 from fastapi import Depends, Body
 
 import schemas
-from chalicelib.core import sessions, events, jobs, projects
+from chalicelib.core import sessions, events, jobs, projects, deletion_certificates
 from chalicelib.utils.TimeUTC import TimeUTC
 from or_dependencies import OR_context
 from routers.base import get_routers
@@ -82,6 +82,17 @@ def get_job(projectKey: str, jobId: int):
     }
 
 
+@app_apikey.get('/v1/{projectKey}/jobs/{jobId}/certificate', tags=["api"])
+def get_job_certificate(projectKey: str, jobId: int):
+    job = jobs.get(job_id=jobId)
+    if len(job.keys()) == 0 or job.get("certificate") is None:
+        return {"errors": ["Certificate not found."]}
+    return {
+        'data': {"certificate": job["certificate"],
+                 "valid": deletion_certificates.is_valid(job["certificate"])}
+    }
+
+
 @app_apikey.delete('/v1/{projectKey}/jobs/{jobId}', tags=["api"])
 def cancel_job(projectKey: str, jobId: int):
     job = jobs.get(job_id=jobId)
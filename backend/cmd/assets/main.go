@@ -12,9 +12,13 @@ import (
 	"openreplay/backend/internal/assets"
 	"openreplay/backend/internal/assets/cacher"
 	config "openreplay/backend/internal/config/assets"
+	"openreplay/backend/pkg/budget"
+	"openreplay/backend/pkg/control"
 	"openreplay/backend/pkg/messages"
 	"openreplay/backend/pkg/monitoring"
+	"openreplay/backend/pkg/msgstats"
 	"openreplay/backend/pkg/queue"
+	"openreplay/backend/pkg/topology"
 )
 
 func main() {
@@ -24,18 +28,40 @@ func main() {
 
 	cfg := config.New()
 
+	resources, err := budget.New(&cfg.Config, metrics)
+	if err != nil {
+		log.Fatalf("can't init resource budget: %s", err)
+	}
+	ctrl, err := control.New("assets", &cfg.Config, metrics)
+	if err != nil {
+		log.Fatalf("can't init control api: %s", err)
+	}
+	ctrl.Stat("budget_level", func() float64 { return float64(resources.Level()) })
+	ctrl.OnReload(func() ([]string, error) {
+		fresh := config.New()
+		if err := resources.SetLimits(&fresh.Config); err != nil {
+			return nil, err
+		}
+		return control.Diff(cfg, fresh), nil
+	})
+
 	cacher := cacher.NewCacher(cfg, metrics)
 
 	totalAssets, err := metrics.RegisterCounter("assets_total")
 	if err != nil {
 		log.Printf("can't create assets_total metric: %s", err)
 	}
+	typeStats, err := msgstats.New(metrics, nil)
+	if err != nil {
+		log.Fatalf("can't init message stats: %s", err)
+	}
 
 	consumer := queue.NewMessageConsumer(
 		cfg.GroupCache,
 		[]string{cfg.TopicCache},
 		func(sessionID uint64, iter messages.Iterator, meta *types.Meta) {
 			for iter.Next() {
+				typeStats.Add(sessionID, iter.Message())
 				if iter.Type() == messages.MsgAssetCache {
 					m := iter.Message().Decode()
 					if m == nil {
@@ -53,6 +79,10 @@ func main() {
 					if msg.Source != "js_exception" {
 						continue
 					}
+					// JS files and their source maps are needed only for error stack traces
+					if !resources.Allow("js_sources") {
+						continue
+					}
 					sourceList, err := assets.ExtractJSExceptionSources(&msg.Payload)
 					if err != nil {
 						log.Printf("Error on source extraction: %v", err)
@@ -69,6 +99,18 @@ func main() {
 		cfg.MessageSizeLimit,
 	)
 
+	topo := topology.New("assets", cfg.GroupCache)
+	topo.Consume(cfg.TopicCache)
+	topo.Store("s3", cfg.S3BucketAssets)
+	topo.Store("redis", cfg.RedisString)
+	consumer.SetPartitionListener(topo.Listener(nil))
+
+	if lag, err := queue.NewLagMonitor(consumer, cfg.GroupCache, &cfg.Config, metrics); err != nil {
+		log.Printf("can't monitor consumer lag: %s", err)
+	} else {
+		ctrl.Stat("consumer_lag", func() float64 { return float64(lag.Total()) })
+	}
+
 	log.Printf("Cacher service started\n")
 
 	sigchan := make(chan os.Signal, 1)
@@ -79,6 +121,7 @@ func main() {
 		select {
 		case sig := <-sigchan:
 			log.Printf("Caught signal %v: terminating\n", sig)
+			cacher.Stop()
 			consumer.Close()
 			os.Exit(0)
 		case err := <-cacher.Errors:
@@ -86,7 +129,14 @@ func main() {
 			// TODO: notify user
 		case <-tick:
 			cacher.UpdateTimeouts()
+		case <-ctrl.Drains():
+			// Assets which are being downloaded are cached in the background
+			ctrl.Drained(consumer.Commit())
 		default:
+			if ctrl.Hold() {
+				continue
+			}
+			resources.Throttle()
 			if err := consumer.ConsumeNext(); err != nil {
 				log.Fatalf("Error on consumption: %v", err)
 			}
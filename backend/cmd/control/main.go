@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"openreplay/backend/pkg/control"
+)
+
+const usage = `Calls the control API of backend services (CONTROL_ADDR of the service).
+
+Usage:
+  control [flags] <command> <addr> [<addr>...]
+
+Commands:
+  pause    stop consuming, partitions stay assigned to the instance
+  resume   continue consuming after pause or drain
+  drain    stop consuming, flush buffers and commit offsets, waits until it's done
+  stats    print the state and stats of the instance
+  reload   re-read the config, print settings which differ from the running ones
+
+Flags:
+`
+
+func main() {
+	log.SetFlags(0)
+
+	token := flag.String("token", os.Getenv("CONTROL_TOKEN"), "control token of the services")
+	timeout := flag.Duration("timeout", time.Minute, "timeout of the call to each service")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	command, addrs := flag.Arg(0), flag.Args()[1:]
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	failed := false
+	for _, addr := range addrs {
+		res, err := call(addr, *token, command, *timeout)
+		if err != nil {
+			log.Printf("%s: %s", addr, err)
+			failed = true
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\n", addr, res)
+	}
+	w.Flush()
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func call(addr, token, command string, timeout time.Duration) (string, error) {
+	client, err := control.Dial(addr, token)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	switch command {
+	case "pause":
+		reply, err := client.Pause(ctx)
+		if err != nil {
+			return "", err
+		}
+		return reply.State, nil
+	case "resume":
+		reply, err := client.Resume(ctx)
+		if err != nil {
+			return "", err
+		}
+		return reply.State, nil
+	case "drain":
+		reply, err := client.Drain(ctx)
+		if err != nil {
+			return "", err
+		}
+		return reply.State, nil
+	case "stats":
+		reply, err := client.Stats(ctx)
+		if err != nil {
+			return "", err
+		}
+		return formatStats(reply), nil
+	case "reload":
+		reply, err := client.Reload(ctx)
+		if err != nil {
+			return "", err
+		}
+		if len(reply.Changed) == 0 {
+			return "no changes", nil
+		}
+		return "changed: " + strings.Join(reply.Changed, ","), nil
+	}
+	return "", fmt.Errorf("unknown command: %s", command)
+}
+
+func formatStats(s *control.StatsReply) string {
+	res := fmt.Sprintf("%s\t%s\t%s\tuptime=%s\tgoroutines=%d\tmemory=%dMB", s.Service, s.Hostname, s.State,
+		time.Since(s.StartedAt).Round(time.Second), s.Goroutines, s.MemoryMB)
+	names := make([]string, 0, len(s.Values))
+	for name := range s.Values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		res += fmt.Sprintf("\t%s=%g", name, s.Values[name])
+	}
+	return res
+}
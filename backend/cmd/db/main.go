@@ -1,16 +1,22 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"log"
 	"openreplay/backend/pkg/queue/types"
+	"openreplay/backend/pkg/validation"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+
 	"openreplay/backend/internal/config/db"
 	"openreplay/backend/internal/db/datasaver"
+	"openreplay/backend/pkg/budget"
+	"openreplay/backend/pkg/control"
 	"openreplay/backend/pkg/db/cache"
 	"openreplay/backend/pkg/db/postgres"
 	"openreplay/backend/pkg/handlers"
@@ -18,8 +24,10 @@ import (
 	logger "openreplay/backend/pkg/log"
 	"openreplay/backend/pkg/messages"
 	"openreplay/backend/pkg/monitoring"
+	"openreplay/backend/pkg/msgstats"
 	"openreplay/backend/pkg/queue"
 	"openreplay/backend/pkg/sessions"
+	"openreplay/backend/pkg/topology"
 )
 
 func main() {
@@ -29,10 +37,62 @@ func main() {
 
 	cfg := db.New()
 
+	resources, err := budget.New(&cfg.Config, metrics)
+	if err != nil {
+		log.Fatalf("can't init resource budget: %s", err)
+	}
+	ctrl, err := control.New("db", &cfg.Config, metrics)
+	if err != nil {
+		log.Fatalf("can't init control api: %s", err)
+	}
+	ctrl.Stat("budget_level", func() float64 { return float64(resources.Level()) })
+	ctrl.OnReload(func() ([]string, error) {
+		fresh := db.New()
+		if err := resources.SetLimits(&fresh.Config); err != nil {
+			return nil, err
+		}
+		return control.Diff(cfg, fresh), nil
+	})
+	validator, err := validation.New(&cfg.Config, metrics)
+	if err != nil {
+		log.Fatalf("can't init message validator: %s", err)
+	}
+	logDedup, err := validation.NewLogDeduplicator(&cfg.Config, metrics)
+	if err != nil {
+		log.Fatalf("can't init log deduplicator: %s", err)
+	}
+	clockNormalizer, err := validation.NewClockNormalizer(&cfg.Config, metrics)
+	if err != nil {
+		log.Fatalf("can't init clock normalizer: %s", err)
+	}
+	if err := validation.CountDeprecated(metrics); err != nil {
+		log.Printf("can't count deprecated messages: %s", err)
+	}
+
+	// Init shared session state
+	var sessionState cache.SessionState
+	if cfg.UseSessionState {
+		state, err := cache.NewRedisSessionState(cfg.RedisString)
+		if err != nil {
+			log.Fatalf("can't init session state: %s", err)
+		}
+		sessionState = state
+	}
+
 	// Init database
-	pg := cache.NewPGCache(postgres.NewConn(cfg.Postgres, cfg.BatchQueueLimit, cfg.BatchSizeLimit, metrics), cfg.ProjectExpirationTimeoutMs)
+	pg := cache.NewPGCache(postgres.NewConn(cfg.Postgres, cfg.BatchQueueLimit, cfg.BatchSizeLimit, metrics), cfg.ProjectExpirationTimeoutMs, sessionState)
 	defer pg.Close()
 
+	typeStats, err := msgstats.New(metrics, func(sessionID uint64) uint32 {
+		if session, err := pg.GetSession(sessionID); err == nil && session != nil {
+			return session.ProjectID
+		}
+		return 0
+	})
+	if err != nil {
+		log.Fatalf("can't init message stats: %s", err)
+	}
+
 	// HandlersFabric returns the list of message handlers we want to be applied to each incoming message.
 	handlersFabric := func() []handlers.MessageProcessor {
 		return []handlers.MessageProcessor{
@@ -46,7 +106,9 @@ func main() {
 	builderMap := sessions.NewBuilderMap(handlersFabric)
 
 	keepMessage := func(tp int) bool {
-		return tp == messages.MsgMetadata || tp == messages.MsgIssueEvent || tp == messages.MsgSessionStart || tp == messages.MsgSessionEnd || tp == messages.MsgUserID || tp == messages.MsgUserAnonymousID || tp == messages.MsgCustomEvent || tp == messages.MsgClickEvent || tp == messages.MsgInputEvent || tp == messages.MsgPageEvent || tp == messages.MsgErrorEvent || tp == messages.MsgFetchEvent || tp == messages.MsgGraphQLEvent || tp == messages.MsgIntegrationEvent || tp == messages.MsgPerformanceTrackAggr || tp == messages.MsgResourceEvent || tp == messages.MsgLongTask || tp == messages.MsgJSException || tp == messages.MsgResourceTiming || tp == messages.MsgRawCustomEvent || tp == messages.MsgCustomIssue || tp == messages.MsgFetch || tp == messages.MsgGraphQL || tp == messages.MsgStateAction || tp == messages.MsgSetInputTarget || tp == messages.MsgSetInputValue || tp == messages.MsgCreateDocument || tp == messages.MsgMouseClick || tp == messages.MsgSetPageLocation || tp == messages.MsgPageLoadTiming || tp == messages.MsgPageRenderTiming
+		return tp == messages.MsgMetadata || tp == messages.MsgIssueEvent || tp == messages.MsgSessionStart || tp == messages.MsgSessionEnd || tp == messages.MsgUserID || tp == messages.MsgUserAnonymousID || tp == messages.MsgCustomEvent || tp == messages.MsgClickEvent || tp == messages.MsgInputEvent || tp == messages.MsgPageEvent || tp == messages.MsgErrorEvent || tp == messages.MsgFetchEvent || tp == messages.MsgGraphQLEvent || tp == messages.MsgIntegrationEvent || tp == messages.MsgPerformanceTrackAggr || tp == messages.MsgResourceEvent || tp == messages.MsgLongTask || tp == messages.MsgJSException || tp == messages.MsgResourceTiming || tp == messages.MsgRawCustomEvent || tp == messages.MsgCustomIssue || tp == messages.MsgFetch || tp == messages.MsgGraphQL || tp == messages.MsgStateAction || tp == messages.MsgSetInputTarget || tp == messages.MsgSetInputValue || tp == messages.MsgCreateDocument || tp == messages.MsgMouseClick || tp == messages.MsgSetPageLocation || tp == messages.MsgPageLoadTiming || tp == messages.MsgPageRenderTiming ||
+			tp == messages.MsgIOSSessionStart || tp == messages.MsgIOSSessionEnd || tp == messages.MsgIOSUserID || tp == messages.MsgIOSUserAnonymousID || tp == messages.MsgIOSCustomEvent || tp == messages.MsgIOSClickEvent || tp == messages.MsgIOSInputEvent || tp == messages.MsgIOSNetworkCall || tp == messages.MsgIOSScreenEnter || tp == messages.MsgIOSCrash ||
+			tp == messages.MsgMobileTouchEvent || tp == messages.MsgMobileLifecycleEvent
 	}
 
 	var producer types.Producer = nil
@@ -57,22 +119,54 @@ func main() {
 
 	// Init modules
 	saver := datasaver.New(pg, producer)
-	saver.InitStats()
+	saver.InitStats(metrics)
 	statsLogger := logger.NewQueueStats(cfg.LoggerTimeout)
 
+	// Time of the consumer itself and time of commits to stores, sql and clickhouse metrics show time of statements
+	batchTime, err := metrics.RegisterHistogram("db_batch_processing_time")
+	if err != nil {
+		log.Printf("can't create db_batch_processing_time metric: %s", err)
+	}
+	commitTime, err := metrics.RegisterHistogram("db_commit_duration")
+	if err != nil {
+		log.Printf("can't create db_commit_duration metric: %s", err)
+	}
+
 	// Handler logic
 	handler := func(sessionID uint64, iter messages.Iterator, meta *types.Meta) {
+		start := time.Now()
+		defer func() {
+			batchTime.Record(context.Background(), float64(time.Since(start).Milliseconds()),
+				attribute.String("topic", meta.Topic))
+		}()
+		// Client clocks are corrected before the validation of timestamps, analytics messages have the server time
+		if meta.Topic == cfg.TopicRawWeb {
+			iter = clockNormalizer.Wrap(sessionID, iter, meta.Timestamp)
+		}
+		iter = validator.Wrap(iter)
 		statsLogger.Collect(sessionID, meta)
 
 		for iter.Next() {
 			if !keepMessage(iter.Type()) {
 				continue
 			}
+			typeStats.Add(sessionID, iter.Message())
 			msg := iter.Message().Decode()
 			if msg == nil {
 				return
 			}
 
+			// Repeated exceptions of the session are saved once per window
+			switch msg.TypeID() {
+			case messages.MsgJSException:
+				if logDedup.Skip(sessionID, msg) {
+					continue
+				}
+			case messages.MsgSessionEnd, messages.MsgIOSSessionEnd:
+				logDedup.Flush(sessionID, msg)
+				clockNormalizer.Delete(sessionID)
+			}
+
 			// Just save session data into db without additional checks
 			if err := saver.InsertMessage(sessionID, msg); err != nil {
 				if !postgres.IsPkeyViolation(err) {
@@ -96,7 +190,7 @@ func main() {
 			}
 
 			// Handle heuristics and save to temporary queue in memory
-			builderMap.HandleMessage(sessionID, msg, msg.Meta().Index)
+			builderMap.HandleMessage(sessionID, meta.TopicPartition(), msg, msg.Meta().Index)
 
 			// Process saved heuristics messages as usual messages above in the code
 			builderMap.IterateSessionReadyMessages(sessionID, func(msg messages.Message) {
@@ -116,16 +210,42 @@ func main() {
 	}
 
 	// Init consumer
-	consumer := queue.NewMessageConsumer(
-		cfg.GroupDB,
-		[]string{
-			cfg.TopicRawWeb,
-			cfg.TopicAnalytics,
-		},
-		handler,
-		false,
-		cfg.MessageSizeLimit,
-	)
+	topics := []string{cfg.TopicRawWeb, cfg.TopicAnalytics}
+	if cfg.TopicRawIOS != "" {
+		topics = append(topics, cfg.TopicRawIOS)
+	}
+	var consumer types.Consumer
+	if cfg.SpillDir != "" {
+		spool, err := queue.NewDiskSpool(cfg.SpillDir, cfg.SpillMaxSize, cfg.SpillMaxAge, metrics)
+		if err != nil {
+			log.Fatalf("can't init spill buffer: %s", err)
+		}
+		spill := queue.NewConsumerSpill(spool, saver.Health(), cfg.SpillTimeout)
+		consumer = queue.NewSpillingMessageConsumer(cfg.GroupDB, topics, handler, false, cfg.MessageSizeLimit, spill)
+	} else {
+		consumer = queue.NewMessageConsumer(
+			cfg.GroupDB,
+			topics,
+			handler,
+			false,
+			cfg.MessageSizeLimit,
+		)
+	}
+
+	topo := topology.New("db", cfg.GroupDB)
+	topo.Consume(topics...)
+	if cfg.UseQuickwit {
+		topo.Produce("quickwit")
+	}
+	topo.Store("postgres", cfg.Postgres)
+	topo.Store("redis", cfg.RedisString)
+	consumer.SetPartitionListener(topo.Listener(nil))
+
+	if lag, err := queue.NewLagMonitor(consumer, cfg.GroupDB, &cfg.Config, metrics); err != nil {
+		log.Printf("can't monitor consumer lag: %s", err)
+	} else {
+		ctrl.Stat("consumer_lag", func() float64 { return float64(lag.Total()) })
+	}
 
 	log.Printf("Db service started\n")
 
@@ -151,13 +271,33 @@ func main() {
 			}
 			chDur := time.Now().Sub(start).Milliseconds()
 			log.Printf("commit duration(ms), pg: %d, ch: %d", pgDur, chDur)
+			commitTime.Record(context.Background(), float64(pgDur), attribute.String("store", "postgres"))
+			// Stats go to clickhouse in the enterprise edition
+			commitTime.Record(context.Background(), float64(chDur), attribute.String("store", "stats"))
+
+			logDedup.Cleanup()
+			clockNormalizer.Cleanup()
 
 			// TODO: use commit worker to save time each tick
+			start = time.Now()
 			if err := consumer.Commit(); err != nil {
 				log.Printf("Error on consumer commit: %v", err)
 			}
+			commitTime.Record(context.Background(), float64(time.Since(start).Milliseconds()),
+				attribute.String("store", "queue"))
+		case <-ctrl.Drains():
+			pg.CommitBatches()
+			err := saver.CommitStats(consumer.HasFirstPartition())
+			if err == nil {
+				err = consumer.Commit()
+			}
+			ctrl.Drained(err)
 		default:
 			// Handle new message from queue
+			if ctrl.Hold() {
+				continue
+			}
+			resources.Throttle()
 			err := consumer.ConsumeNext()
 			if err != nil {
 				log.Fatalf("Error on consumption: %v", err)
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	config "openreplay/backend/internal/config/dictionaries"
+	"openreplay/backend/pkg/db/postgres"
+	"openreplay/backend/pkg/dictionaries"
+	"openreplay/backend/pkg/monitoring"
+	"openreplay/backend/pkg/storage"
+)
+
+// Offline job, trains zstd dictionaries on recent session files of each project.
+// Storage service picks up new dictionaries if USE_DICTIONARIES is enabled.
+func main() {
+	metrics := monitoring.New("dictionaries")
+
+	log.SetFlags(log.LstdFlags | log.LUTC | log.Llongfile)
+
+	cfg := config.New()
+
+	pg := postgres.NewConn(cfg.Postgres, 0, 0, metrics)
+	defer pg.Close()
+
+	objStorage, err := storage.NewObjectStorage(cfg.StorageProvider, cfg.S3Region, cfg.S3Bucket)
+	if err != nil {
+		log.Fatalf("can't init object storage: %s", err)
+	}
+	store, err := dictionaries.NewStore(objStorage, time.Minute)
+	if err != nil {
+		log.Fatalf("can't init dictionaries store: %s", err)
+	}
+	trainer, err := dictionaries.NewTrainer(cfg.ZstdBinary, cfg.DictionarySize, cfg.SampleBlockSize)
+	if err != nil {
+		log.Fatalf("can't init trainer: %s", err)
+	}
+
+	projects, err := pg.GetActiveProjectIDs()
+	if err != nil {
+		log.Fatalf("can't get projects: %s", err)
+	}
+	for _, projectID := range projects {
+		if err := trainProject(cfg, pg, objStorage, store, trainer, projectID); err != nil {
+			log.Printf("can't train dictionary, projID: %d, err: %s", projectID, err)
+		}
+	}
+	log.Printf("Dictionaries training finished, projects: %d", len(projects))
+}
+
+func trainProject(cfg *config.Config, pg *postgres.Conn, objStorage storage.ObjectStorage, store *dictionaries.Store,
+	trainer *dictionaries.Trainer, projectID uint32) error {
+	sessions, err := pg.GetRecentSessionIDs(projectID, cfg.SessionsPerProject)
+	if err != nil {
+		return err
+	}
+	if len(sessions) < cfg.MinSessions {
+		log.Printf("not enough sessions for training, projID: %d, sessions: %d", projectID, len(sessions))
+		return nil
+	}
+
+	samplesDir, err := os.MkdirTemp(cfg.FSDir, "samples-"+strconv.FormatUint(uint64(projectID), 10)+"-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(samplesDir)
+
+	samples, samplesSize := 0, int64(0)
+	for _, sessionID := range sessions {
+		size, err := downloadSample(objStorage, store, sessionID, samplesDir)
+		if err != nil {
+			log.Printf("can't download sample, sessID: %d, err: %s", sessionID, err)
+			continue
+		}
+		samples++
+		samplesSize += size
+	}
+	if samples < cfg.MinSessions {
+		log.Printf("not enough samples for training, projID: %d, samples: %d", projectID, samples)
+		return nil
+	}
+
+	dictID, err := dictionaries.NewID()
+	if err != nil {
+		return err
+	}
+	dict, err := trainer.Train(dictID, samplesDir)
+	if err != nil {
+		return err
+	}
+	if err := store.Save(projectID, dict); err != nil {
+		return err
+	}
+	log.Printf("dictionary trained, projID: %d, dictID: %d, samples: %d, samplesSize: %d, dictSize: %d",
+		projectID, dictID, samples, samplesSize, len(dict))
+	return nil
+}
+
+// downloadSample saves decompressed start file of the session, it contains the initial DOM snapshot
+func downloadSample(objStorage storage.ObjectStorage, store *dictionaries.Store, sessionID uint64, dir string) (int64, error) {
+	key := strconv.FormatUint(sessionID, 10)
+	file, err := objStorage.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	reader, err := dictionaries.NewReader(file, store)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	sample, err := os.Create(filepath.Join(dir, key))
+	if err != nil {
+		return 0, err
+	}
+	defer sample.Close()
+	return io.Copy(sample, reader)
+}
@@ -10,13 +10,19 @@ import (
 
 	"openreplay/backend/internal/config/ender"
 	"openreplay/backend/internal/sessionender"
+	"openreplay/backend/pkg/budget"
+	"openreplay/backend/pkg/control"
 	"openreplay/backend/pkg/db/cache"
 	"openreplay/backend/pkg/db/postgres"
+	"openreplay/backend/pkg/handoff"
 	"openreplay/backend/pkg/intervals"
 	logger "openreplay/backend/pkg/log"
 	"openreplay/backend/pkg/messages"
 	"openreplay/backend/pkg/monitoring"
+	"openreplay/backend/pkg/msgstats"
 	"openreplay/backend/pkg/queue"
+	"openreplay/backend/pkg/tiers"
+	"openreplay/backend/pkg/topology"
 )
 
 func main() {
@@ -27,17 +33,53 @@ func main() {
 	// Load service configuration
 	cfg := ender.New()
 
-	pg := cache.NewPGCache(postgres.NewConn(cfg.Postgres, 0, 0, metrics), cfg.ProjectExpirationTimeoutMs)
+	resources, err := budget.New(&cfg.Config, metrics)
+	if err != nil {
+		log.Fatalf("can't init resource budget: %s", err)
+	}
+	ctrl, err := control.New("ender", &cfg.Config, metrics)
+	if err != nil {
+		log.Fatalf("can't init control api: %s", err)
+	}
+	ctrl.Stat("budget_level", func() float64 { return float64(resources.Level()) })
+	ctrl.OnReload(func() ([]string, error) {
+		fresh := ender.New()
+		if err := resources.SetLimits(&fresh.Config); err != nil {
+			return nil, err
+		}
+		return control.Diff(cfg, fresh), nil
+	})
+
+	pg := cache.NewPGCache(postgres.NewConn(cfg.Postgres, 0, 0, metrics), cfg.ProjectExpirationTimeoutMs, nil)
 	defer pg.Close()
 
 	// Init all modules
 	statsLogger := logger.NewQueueStats(cfg.LoggerTimeout)
-	sessions, err := sessionender.New(metrics, intervals.EVENTS_SESSION_END_TIMEOUT, cfg.PartitionsNumber)
+	typeStats, err := msgstats.New(metrics, nil)
+	if err != nil {
+		log.Fatalf("can't init message stats: %s", err)
+	}
+	var sessions sessionender.Ender
+	var memorySessions *sessionender.SessionEnder
+	if cfg.UseRedisState {
+		sessions, err = sessionender.NewRedis(metrics, cfg.RedisString, cfg.GroupEnder, intervals.EVENTS_SESSION_END_TIMEOUT)
+	} else {
+		memorySessions, err = sessionender.New(metrics, intervals.EVENTS_SESSION_END_TIMEOUT, cfg.PartitionsNumber)
+		sessions = memorySessions
+	}
 	if err != nil {
 		log.Printf("can't init ender service: %s", err)
 		return
 	}
-	producer := queue.NewProducer(cfg.MessageSizeLimit, true)
+	projectTiers, err := tiers.New(cfg.DefaultTier, cfg.ProjectTiers)
+	if err != nil {
+		log.Fatalf("can't init project tiers: %s", err)
+	}
+	producer, err := queue.NewPartitioningProducer(queue.NewProducer(cfg.MessageSizeLimit, true),
+		cfg.PartitionKeys, cfg.Partitions, pg.GetSessionPartitionKeys, projectTiers)
+	if err != nil {
+		log.Fatalf("can't init partitioning: %s", err)
+	}
 	consumer := queue.NewMessageConsumer(
 		cfg.GroupEnder,
 		[]string{
@@ -45,6 +87,7 @@ func main() {
 		},
 		func(sessionID uint64, iter messages.Iterator, meta *types.Meta) {
 			for iter.Next() {
+				typeStats.Add(sessionID, iter.Message())
 				if iter.Type() == messages.MsgSessionStart || iter.Type() == messages.MsgSessionEnd {
 					continue
 				}
@@ -52,7 +95,7 @@ func main() {
 					log.Printf("ZERO TS, sessID: %d, msgType: %d", sessionID, iter.Type())
 				}
 				statsLogger.Collect(sessionID, meta)
-				sessions.UpdateSession(sessionID, meta.Timestamp, iter.Message().Meta().Timestamp)
+				sessions.UpdateSession(sessionID, meta.TopicPartition(), meta.Timestamp, iter.Message().Meta().Timestamp)
 			}
 			iter.Close()
 		},
@@ -60,6 +103,32 @@ func main() {
 		cfg.MessageSizeLimit,
 	)
 
+	topo := topology.New("ender", cfg.GroupEnder)
+	topo.Consume(cfg.TopicRawWeb)
+	topo.Produce(cfg.TopicRawWeb)
+	topo.Store("redis", cfg.RedisString)
+
+	var listener types.PartitionListener
+	// Redis state is shared by all instances, there is nothing to hand off
+	if cfg.UseStateHandoff && !cfg.UseRedisState {
+		store, err := handoff.NewRedisStore(cfg.RedisString, cfg.GroupEnder)
+		if err != nil {
+			log.Fatalf("can't init state store: %s", err)
+		}
+		stateManager, err := handoff.New(store, memorySessions)
+		if err != nil {
+			log.Fatalf("can't init state handoff: %s", err)
+		}
+		listener = stateManager
+	}
+	consumer.SetPartitionListener(topo.Listener(listener))
+
+	if lag, err := queue.NewLagMonitor(consumer, cfg.GroupEnder, &cfg.Config, metrics); err != nil {
+		log.Printf("can't monitor consumer lag: %s", err)
+	} else {
+		ctrl.Stat("consumer_lag", func() float64 { return float64(lag.Total()) })
+	}
+
 	log.Printf("Ender service started\n")
 
 	sigchan := make(chan os.Signal, 1)
@@ -104,7 +173,15 @@ func main() {
 			if err := consumer.CommitBack(intervals.EVENTS_BACK_COMMIT_GAP); err != nil {
 				log.Printf("can't commit messages with offset: %s", err)
 			}
+		case <-ctrl.Drains():
+			// Sessions in memory are kept, ended ones are still sent on ticks
+			producer.Flush(cfg.ProducerTimeout)
+			ctrl.Drained(consumer.CommitBack(intervals.EVENTS_BACK_COMMIT_GAP))
 		default:
+			if ctrl.Hold() {
+				continue
+			}
+			resources.Throttle()
 			if err := consumer.ConsumeNext(); err != nil {
 				log.Fatalf("Error on consuming: %v", err)
 			}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"openreplay/backend/pkg/queue/types"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	config "openreplay/backend/internal/config/forwarder"
+	"openreplay/backend/internal/forwarder"
+	"openreplay/backend/pkg/budget"
+	"openreplay/backend/pkg/control"
+	"openreplay/backend/pkg/db/cache"
+	"openreplay/backend/pkg/db/postgres"
+	"openreplay/backend/pkg/handlers"
+	custom2 "openreplay/backend/pkg/handlers/custom"
+	"openreplay/backend/pkg/messages"
+	"openreplay/backend/pkg/monitoring"
+	"openreplay/backend/pkg/msgstats"
+	"openreplay/backend/pkg/queue"
+	"openreplay/backend/pkg/sessions"
+	"openreplay/backend/pkg/storage"
+	"openreplay/backend/pkg/topology"
+)
+
+// Data forwarding service, streams finished sessions and their events to the customer Kafka topic or S3 prefix
+func main() {
+	metrics := monitoring.New("forwarder")
+
+	log.SetFlags(log.LstdFlags | log.LUTC | log.Llongfile)
+
+	cfg := config.New()
+
+	resources, err := budget.New(&cfg.Config, metrics)
+	if err != nil {
+		log.Fatalf("can't init resource budget: %s", err)
+	}
+	ctrl, err := control.New("forwarder", &cfg.Config, metrics)
+	if err != nil {
+		log.Fatalf("can't init control api: %s", err)
+	}
+	ctrl.Stat("budget_level", func() float64 { return float64(resources.Level()) })
+	ctrl.OnReload(func() ([]string, error) {
+		fresh := config.New()
+		if err := resources.SetLimits(&fresh.Config); err != nil {
+			return nil, err
+		}
+		return control.Diff(cfg, fresh), nil
+	})
+
+	pg := cache.NewPGCache(postgres.NewConn(cfg.Postgres, 0, 0, metrics), cfg.ProjectExpirationTimeoutMs, nil)
+	defer pg.Close()
+
+	sink, err := newSink(cfg)
+	if err != nil {
+		log.Fatalf("can't init forwarder sink: %s", err)
+	}
+	projects, err := parseProjects(cfg.Projects)
+	if err != nil {
+		log.Fatalf("can't parse forwarded projects: %s", err)
+	}
+	fwd, err := forwarder.New(sink, projects, cfg.BatchSize, cfg.BufferLimit, metrics)
+	if err != nil {
+		log.Fatalf("can't init forwarder: %s", err)
+	}
+
+	// Events are built from raw messages the same way as in the db service
+	handlersFabric := func() []handlers.MessageProcessor {
+		return []handlers.MessageProcessor{
+			&custom2.EventMapper{},
+			custom2.NewInputEventBuilder(),
+			custom2.NewPageEventBuilder(),
+		}
+	}
+	builderMap := sessions.NewBuilderMap(handlersFabric)
+
+	typeStats, err := msgstats.New(metrics, nil)
+	if err != nil {
+		log.Fatalf("can't init message stats: %s", err)
+	}
+
+	keepMessage := func(tp int) bool {
+		return tp == messages.MsgSessionEnd || tp == messages.MsgIssueEvent || tp == messages.MsgCustomEvent || tp == messages.MsgRawCustomEvent || tp == messages.MsgCustomIssue || tp == messages.MsgJSException || tp == messages.MsgMouseClick || tp == messages.MsgSetInputTarget || tp == messages.MsgSetInputValue || tp == messages.MsgCreateDocument || tp == messages.MsgSetPageLocation || tp == messages.MsgPageLoadTiming || tp == messages.MsgPageRenderTiming
+	}
+
+	forwardEvent := func(sessionID uint64, msg messages.Message) {
+		if !cfg.ForwardEvents {
+			return
+		}
+		rec := forwarder.NewEventRecord(sessionID, msg)
+		if rec == nil {
+			return
+		}
+		session, err := pg.GetSession(sessionID)
+		if session == nil {
+			if err != nil && !errors.Is(err, cache.NilSessionInCacheError) {
+				log.Printf("can't get session, sessID: %d, err: %s", sessionID, err)
+			}
+			return
+		}
+		rec.ProjectID = session.ProjectID
+		fwd.Add(rec)
+	}
+
+	// Finished sessions are forwarded with a delay, when the db service has saved all counters.
+	// Pending sessions are lost if the service crashes.
+	endedSessions := make(map[uint64]time.Time)
+	forwardSession := func(sessionID uint64) {
+		pg.DeleteSession(sessionID)
+		session, err := pg.Conn.GetSession(sessionID)
+		if err != nil {
+			log.Printf("can't get finished session, sessID: %d, err: %s", sessionID, err)
+			return
+		}
+		if !fwd.IsForwarded(session.ProjectID) {
+			return
+		}
+		project, err := pg.GetProject(session.ProjectID)
+		if err != nil {
+			log.Printf("can't get project, projID: %d, err: %s", session.ProjectID, err)
+			return
+		}
+		fwd.Add(forwarder.NewSessionRecord(session, project))
+	}
+
+	handler := func(sessionID uint64, iter messages.Iterator, meta *types.Meta) {
+		for iter.Next() {
+			typeStats.Add(sessionID, iter.Message())
+			if !keepMessage(iter.Type()) {
+				continue
+			}
+			msg := iter.Message().Decode()
+			if msg == nil {
+				return
+			}
+			if _, ok := msg.(*messages.SessionEnd); ok {
+				endedSessions[sessionID] = time.Now()
+			}
+			forwardEvent(sessionID, msg)
+
+			builderMap.HandleMessage(sessionID, meta.TopicPartition(), msg, msg.Meta().Index)
+			builderMap.IterateSessionReadyMessages(sessionID, func(msg messages.Message) {
+				forwardEvent(sessionID, msg)
+			})
+		}
+		iter.Close()
+	}
+
+	consumer := queue.NewMessageConsumer(
+		cfg.GroupForwarder,
+		[]string{
+			cfg.TopicRawWeb,
+			cfg.TopicAnalytics,
+		},
+		handler,
+		false,
+		cfg.MessageSizeLimit,
+	)
+
+	topo := topology.New("forwarder", cfg.GroupForwarder)
+	topo.Consume(cfg.TopicRawWeb, cfg.TopicAnalytics)
+	topo.Store("postgres", cfg.Postgres)
+	if cfg.Target == "kafka" {
+		topo.Store("kafka", cfg.KafkaServers+"/"+cfg.KafkaTopic)
+	} else {
+		topo.Store(cfg.StorageProvider, cfg.S3Bucket+"/"+cfg.S3Prefix)
+	}
+	consumer.SetPartitionListener(topo.Listener(nil))
+
+	if lag, err := queue.NewLagMonitor(consumer, cfg.GroupForwarder, &cfg.Config, metrics); err != nil {
+		log.Printf("can't monitor consumer lag: %s", err)
+	} else {
+		ctrl.Stat("consumer_lag", func() float64 { return float64(lag.Total()) })
+	}
+
+	log.Printf("Forwarder service started, target: %s\n", cfg.Target)
+
+	sigchan := make(chan os.Signal, 1)
+	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
+
+	tick := time.Tick(cfg.FlushInterval)
+	for {
+		select {
+		case sig := <-sigchan:
+			log.Printf("Caught signal %v: terminating\n", sig)
+			for sessionID := range endedSessions {
+				forwardSession(sessionID)
+			}
+			if err := fwd.Close(); err != nil {
+				log.Printf("can't forward records: %s", err)
+			} else if err := consumer.Commit(); err != nil {
+				log.Printf("can't commit messages: %s", err)
+			}
+			consumer.Close()
+			os.Exit(0)
+		case <-tick:
+			for sessionID, endedAt := range endedSessions {
+				if time.Since(endedAt) >= cfg.SessionDelay {
+					delete(endedSessions, sessionID)
+					forwardSession(sessionID)
+				}
+			}
+			// Offsets are committed only when all collected records are delivered
+			if err := fwd.Flush(); err != nil {
+				log.Printf("can't forward records: %s", err)
+				continue
+			}
+			if err := consumer.Commit(); err != nil {
+				log.Printf("can't commit messages: %s", err)
+			}
+		case <-ctrl.Drains():
+			// Ended sessions aren't delayed, the same as on shutdown
+			for sessionID := range endedSessions {
+				delete(endedSessions, sessionID)
+				forwardSession(sessionID)
+			}
+			err := fwd.Flush()
+			if err == nil {
+				err = consumer.Commit()
+			}
+			ctrl.Drained(err)
+		default:
+			if ctrl.Hold() {
+				continue
+			}
+			resources.Throttle()
+			if err := consumer.ConsumeNext(); err != nil {
+				log.Fatalf("Error on consumption: %v", err)
+			}
+		}
+	}
+}
+
+func newSink(cfg *config.Config) (forwarder.Sink, error) {
+	switch cfg.Target {
+	case "kafka":
+		return forwarder.NewKafkaSink(cfg.KafkaServers, cfg.KafkaTopic, cfg.KafkaProperties, cfg.ProducerTimeout)
+	case "storage", "s3":
+		objStorage, err := storage.NewObjectStorage(cfg.StorageProvider, cfg.S3Region, cfg.S3Bucket)
+		if err != nil {
+			return nil, err
+		}
+		return forwarder.NewStorageSink(objStorage, cfg.S3Prefix, cfg.WorkerID)
+	}
+	return nil, fmt.Errorf("unknown forward target: %s", cfg.Target)
+}
+
+func parseProjects(values []string) ([]uint32, error) {
+	projects := make([]uint32, 0, len(values))
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		projectID, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, uint32(projectID))
+	}
+	return projects, nil
+}
@@ -9,21 +9,38 @@ import (
 	"time"
 
 	"openreplay/backend/internal/config/heuristics"
+	"openreplay/backend/pkg/control"
 	"openreplay/backend/pkg/handlers"
 	web2 "openreplay/backend/pkg/handlers/web"
+	"openreplay/backend/pkg/handoff"
 	"openreplay/backend/pkg/intervals"
 	logger "openreplay/backend/pkg/log"
 	"openreplay/backend/pkg/messages"
+	"openreplay/backend/pkg/monitoring"
+	"openreplay/backend/pkg/msgstats"
 	"openreplay/backend/pkg/queue"
 	"openreplay/backend/pkg/sessions"
+	"openreplay/backend/pkg/topology"
 )
 
 func main() {
+	// Metrics server also serves the topology endpoint
+	metrics := monitoring.New("heuristics")
+
 	log.SetFlags(log.LstdFlags | log.LUTC | log.Llongfile)
 
 	// Load service configuration
 	cfg := heuristics.New()
 
+	ctrl, err := control.New("heuristics", &cfg.Config, metrics)
+	if err != nil {
+		log.Fatalf("can't init control api: %s", err)
+	}
+	ctrl.OnReload(func() ([]string, error) {
+		fresh := heuristics.New()
+		return control.Diff(cfg, fresh), nil
+	})
+
 	// HandlersFabric returns the list of message handlers we want to be applied to each incoming message.
 	handlersFabric := func() []handlers.MessageProcessor {
 		return []handlers.MessageProcessor{
@@ -34,6 +51,7 @@ func main() {
 			&web2.MemoryIssueDetector{},
 			&web2.NetworkIssueDetector{},
 			&web2.PerformanceAggregator{},
+			&web2.PluginIssueDetector{},
 			// Other handlers (you can add your custom handlers here)
 			//&custom.CustomHandler{},
 		}
@@ -44,6 +62,10 @@ func main() {
 
 	// Init logger
 	statsLogger := logger.NewQueueStats(cfg.LoggerTimeout)
+	typeStats, err := msgstats.New(metrics, nil)
+	if err != nil {
+		log.Fatalf("can't init message stats: %s", err)
+	}
 
 	// Init producer and consumer for data bus
 	producer := queue.NewProducer(cfg.MessageSizeLimit, true)
@@ -56,13 +78,14 @@ func main() {
 			var lastMessageID uint64
 			for iter.Next() {
 				statsLogger.Collect(sessionID, meta)
+				typeStats.Add(sessionID, iter.Message())
 				msg := iter.Message().Decode()
 				if msg == nil {
 					log.Printf("failed batch, sess: %d, lastIndex: %d", sessionID, lastMessageID)
 					continue
 				}
 				lastMessageID = msg.Meta().Index
-				builderMap.HandleMessage(sessionID, msg, iter.Message().Meta().Index)
+				builderMap.HandleMessage(sessionID, meta.TopicPartition(), msg, iter.Message().Meta().Index)
 			}
 			iter.Close()
 		},
@@ -70,6 +93,31 @@ func main() {
 		cfg.MessageSizeLimit,
 	)
 
+	topo := topology.New("heuristics", cfg.GroupHeuristics)
+	topo.Consume(cfg.TopicRawWeb)
+	topo.Produce(cfg.TopicAnalytics)
+	topo.Store("redis", cfg.RedisString)
+
+	var listener types.PartitionListener
+	if cfg.UseStateHandoff {
+		store, err := handoff.NewRedisStore(cfg.RedisString, cfg.GroupHeuristics)
+		if err != nil {
+			log.Fatalf("can't init state store: %s", err)
+		}
+		stateManager, err := handoff.New(store, builderMap)
+		if err != nil {
+			log.Fatalf("can't init state handoff: %s", err)
+		}
+		listener = stateManager
+	}
+	consumer.SetPartitionListener(topo.Listener(listener))
+
+	if lag, err := queue.NewLagMonitor(consumer, cfg.GroupHeuristics, &cfg.Config, metrics); err != nil {
+		log.Printf("can't monitor consumer lag: %s", err)
+	} else {
+		ctrl.Stat("consumer_lag", func() float64 { return float64(lag.Total()) })
+	}
+
 	log.Printf("Heuristics service started\n")
 
 	sigchan := make(chan os.Signal, 1)
@@ -90,7 +138,17 @@ func main() {
 			})
 			producer.Flush(cfg.ProducerTimeout)
 			consumer.Commit()
+		case <-ctrl.Drains():
+			// Unfinished sessions stay in builders, their events are sent on ticks
+			builderMap.IterateReadyMessages(func(sessionID uint64, readyMsg messages.Message) {
+				producer.Produce(cfg.TopicAnalytics, sessionID, messages.Encode(readyMsg))
+			})
+			producer.Flush(cfg.ProducerTimeout)
+			ctrl.Drained(consumer.Commit())
 		default:
+			if ctrl.Hold() {
+				continue
+			}
 			if err := consumer.ConsumeNext(); err != nil {
 				log.Fatalf("Error on consuming: %v", err)
 			}
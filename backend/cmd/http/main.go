@@ -1,19 +1,26 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"openreplay/backend/internal/config/http"
 	"openreplay/backend/internal/http/router"
 	"openreplay/backend/internal/http/server"
 	"openreplay/backend/internal/http/services"
+	"openreplay/backend/pkg/control"
 	"openreplay/backend/pkg/monitoring"
 	"os"
 	"os/signal"
 	"syscall"
 
+	"google.golang.org/grpc"
+
 	"openreplay/backend/pkg/db/cache"
 	"openreplay/backend/pkg/db/postgres"
 	"openreplay/backend/pkg/queue"
+	"openreplay/backend/pkg/queue/types"
+	"openreplay/backend/pkg/tiers"
+	"openreplay/backend/pkg/topology"
 )
 
 func main() {
@@ -23,14 +30,53 @@ func main() {
 
 	cfg := http.New()
 
+	// Paused and drained instances reject requests, the load balancer moves trackers to other ones
+	ctrl, err := control.New("http", &cfg.Config, metrics)
+	if err != nil {
+		log.Fatalf("can't init control api: %s", err)
+	}
+	ctrl.OnReload(func() ([]string, error) {
+		fresh := http.New()
+		return control.Diff(cfg, fresh), nil
+	})
+
 	// Connect to queue
-	producer := queue.NewProducer(cfg.MessageSizeLimit, true)
+	var producer types.Producer
+	if cfg.SpillDir != "" {
+		spool, err := queue.NewDiskSpool(cfg.SpillDir, cfg.SpillMaxSize, cfg.SpillMaxAge, metrics)
+		if err != nil {
+			log.Fatalf("can't init spill buffer: %s", err)
+		}
+		producer = queue.NewSpillingProducer(cfg.MessageSizeLimit, true, spool, cfg.SpillTimeout)
+	} else {
+		producer = queue.NewProducer(cfg.MessageSizeLimit, true)
+	}
 	defer producer.Close(15000)
 
+	// Init shared session state
+	var sessionState cache.SessionState
+	if cfg.UseSessionState {
+		state, err := cache.NewRedisSessionState(cfg.RedisString)
+		if err != nil {
+			log.Fatalf("can't init session state: %s", err)
+		}
+		sessionState = state
+	}
+
 	// Connect to database
-	dbConn := cache.NewPGCache(postgres.NewConn(cfg.Postgres, 0, 0, metrics), 1000*60*20)
+	dbConn := cache.NewPGCache(postgres.NewConn(cfg.Postgres, 0, 0, metrics), 1000*60*20, sessionState)
 	defer dbConn.Close()
 
+	// Session start is saved to the database before its first message is sent, so keys of new sessions are known
+	projectTiers, err := tiers.New(cfg.DefaultTier, cfg.ProjectTiers)
+	if err != nil {
+		log.Fatalf("can't init project tiers: %s", err)
+	}
+	producer, err = queue.NewPartitioningProducer(producer, cfg.PartitionKeys, cfg.Partitions, dbConn.GetSessionPartitionKeys, projectTiers)
+	if err != nil {
+		log.Fatalf("can't init partitioning: %s", err)
+	}
+
 	// Build all services
 	services := services.New(cfg, producer, dbConn)
 
@@ -41,7 +87,7 @@ func main() {
 	}
 
 	// Init server
-	server, err := server.New(router.GetHandler(), cfg.HTTPHost, cfg.HTTPPort, cfg.HTTPTimeout)
+	server, err := server.New(ctrl.Handler(router.GetHandler()), cfg.HTTPHost, cfg.HTTPPort, cfg.HTTPTimeout)
 	if err != nil {
 		log.Fatalf("failed while creating server: %s", err)
 	}
@@ -53,12 +99,38 @@ func main() {
 		}
 	}()
 
+	// gRPC ingest shares the handlers, gRPC-Web calls are served by the HTTP server
+	var grpcServer *grpc.Server
+	if cfg.GRPCPort != "" {
+		grpcServer, err = router.ServeGRPC(fmt.Sprintf("%s:%s", cfg.HTTPHost, cfg.GRPCPort), ctrl.UnaryInterceptor())
+		if err != nil {
+			log.Fatalf("failed while creating grpc server: %s", err)
+		}
+	}
+
+	topo := topology.New("http", "")
+	topo.Produce(cfg.TopicRawWeb, cfg.TopicRawIOS, cfg.TopicAnalytics)
+	topo.Store("postgres", cfg.Postgres)
+	topo.Store("redis", cfg.RedisString)
+
 	log.Printf("Server successfully started on port %v\n", cfg.HTTPPort)
 
 	// Wait stop signal to shut down server gracefully
 	sigchan := make(chan os.Signal, 1)
 	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigchan
-	log.Printf("Shutting down the server\n")
-	server.Stop()
+	for {
+		select {
+		case <-sigchan:
+			log.Printf("Shutting down the server\n")
+			server.Stop()
+			if grpcServer != nil {
+				grpcServer.GracefulStop()
+			}
+			return
+		case <-ctrl.Drains():
+			// New requests are rejected already, accepted batches are sent to the queue
+			producer.Flush(15000)
+			ctrl.Drained(nil)
+		}
+	}
 }
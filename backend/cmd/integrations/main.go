@@ -15,7 +15,9 @@ import (
 	"openreplay/backend/pkg/intervals"
 	"openreplay/backend/pkg/messages"
 	"openreplay/backend/pkg/queue"
+	"openreplay/backend/pkg/tiers"
 	"openreplay/backend/pkg/token"
+	"openreplay/backend/pkg/topology"
 )
 
 //
@@ -31,7 +33,11 @@ func main() {
 
 	tokenizer := token.NewTokenizer(cfg.TokenSecret)
 
-	manager := clientManager.NewManager()
+	projectTiers, err := tiers.New(cfg.DefaultTier, cfg.ProjectTiers)
+	if err != nil {
+		log.Fatalf("can't init project tiers: %s", err)
+	}
+	manager := clientManager.NewManager(projectTiers)
 
 	pg.IterateIntegrationsOrdered(func(i *postgres.Integration, err error) {
 		if err != nil {
@@ -46,7 +52,11 @@ func main() {
 		}
 	})
 
-	producer := queue.NewProducer(cfg.MessageSizeLimit, true)
+	producer, err := queue.NewPartitioningProducer(queue.NewProducer(cfg.MessageSizeLimit, true),
+		cfg.PartitionKeys, cfg.Partitions, pg.GetSessionPartitionKeys, projectTiers)
+	if err != nil {
+		log.Fatalf("can't init partitioning: %s", err)
+	}
 	defer producer.Close(15000)
 
 	listener, err := postgres.NewIntegrationsListener(cfg.PostgresURI)
@@ -61,6 +71,10 @@ func main() {
 
 	tick := time.Tick(intervals.INTEGRATIONS_REQUEST_INTERVAL * time.Millisecond)
 
+	topo := topology.New("integrations", "")
+	topo.Produce(cfg.TopicAnalytics)
+	topo.Store("postgres", cfg.PostgresURI)
+
 	log.Printf("Integration service started\n")
 	manager.RequestAll()
 	for {
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"openreplay/backend/pkg/dictionaries"
+	"openreplay/backend/pkg/env"
+	"openreplay/backend/pkg/messages"
+	"openreplay/backend/pkg/messages/delta"
+	"openreplay/backend/pkg/storage"
+)
+
+const usage = `Prints messages of the recording file as JSON.
+
+Usage:
+  mobdecoder [flags] <file> [<file>...]   local files, parts of the split file are read one after another
+  mobdecoder [flags] -session <id>        both parts of the recording from the object storage
+                                          (STORAGE_PROVIDER, AWS_REGION_WEB and S3_BUCKET_WEB are used)
+
+Files can be gzip or zstd compressed and delta encoded. Local files compressed with the project
+dictionary are read only if S3_BUCKET_WEB is set, the dictionary is loaded from the object storage.
+
+Flags:
+`
+
+// Record is one printed message, Time is the last Timestamp message before it for web recordings
+type Record struct {
+	Index  uint64                 `json:"index"`
+	Time   int64                  `json:"time,omitempty"`
+	Type   string                 `json:"type"`
+	TypeID int                    `json:"typeId"`
+	Data   map[string]interface{} `json:"data"`
+}
+
+func main() {
+	log.SetFlags(0)
+
+	sessionID := flag.Uint64("session", 0, "session id to download from the object storage")
+	format := flag.String("format", "ndjson", "output format: ndjson or json")
+	types := flag.String("types", "", "comma separated message types to print, names (SetNodeAttribute) or ids (12)")
+	ios := flag.Bool("ios", false, "mobile recording, messages are stored without indexes")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *format != "ndjson" && *format != "json" {
+		log.Fatalf("unknown format: %s", *format)
+	}
+	filter := parseTypes(*types)
+
+	var data []byte
+	var err error
+	switch {
+	case *sessionID != 0:
+		data, err = downloadSession(*sessionID)
+	case flag.NArg() > 0:
+		data, err = readFiles(flag.Args())
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("can't read recording: %s", err)
+	}
+	if data, err = delta.Decode(data); err != nil {
+		log.Fatalf("can't decode delta encoded recording: %s", err)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	printer := newPrinter(out, *format)
+	var timestamp int64
+	handle := func(msg messages.Message) {
+		if m, ok := msg.(*messages.Timestamp); ok {
+			timestamp = int64(m.Timestamp)
+		}
+		name := reflect.TypeOf(msg).Elem().Name()
+		if filter != nil && !filter[name] && !filter[strconv.Itoa(msg.TypeID())] {
+			return
+		}
+		if err := printer.print(&Record{
+			Index:  msg.Meta().Index,
+			Time:   timestamp,
+			Type:   name,
+			TypeID: msg.TypeID(),
+			Data:   fields(msg),
+		}); err != nil {
+			log.Fatalf("can't print message: %s", err)
+		}
+	}
+	if *ios {
+		err = readIOSFile(data, handle)
+	} else {
+		err = messages.ReadSessionFile(data, handle)
+	}
+	if err := printer.close(); err != nil {
+		log.Fatalf("can't print messages: %s", err)
+	}
+	if err != nil {
+		out.Flush()
+		log.Fatalf("recording is decoded partially: %s", err)
+	}
+}
+
+func parseTypes(types string) map[string]bool {
+	if types == "" {
+		return nil
+	}
+	filter := make(map[string]bool)
+	for _, tp := range strings.Split(types, ",") {
+		filter[strings.TrimSpace(tp)] = true
+	}
+	return filter
+}
+
+// fields returns the message fields without meta information
+func fields(msg messages.Message) map[string]interface{} {
+	value := reflect.ValueOf(msg).Elem()
+	result := make(map[string]interface{}, value.NumField())
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.Anonymous || !field.IsExported() {
+			continue
+		}
+		result[field.Name] = value.Field(i).Interface()
+	}
+	return result
+}
+
+// readIOSFile reads files written by the sink for mobile sessions, each message has its own timestamp
+func readIOSFile(data []byte, fn func(msg messages.Message)) error {
+	reader := bytes.NewReader(data)
+	for index := uint64(0); reader.Len() > 0; index++ {
+		tp, err := messages.ReadUint(reader)
+		if err != nil {
+			return err
+		}
+		msg, err := messages.ReadMessage(tp, reader)
+		if err != nil {
+			return fmt.Errorf("can't read message at %d: %s", len(data)-reader.Len(), err)
+		}
+		msg.Meta().Index = index
+		fn(msg)
+	}
+	return nil
+}
+
+// readFiles uses dictionaries of the object storage only if it's configured
+func readFiles(paths []string) ([]byte, error) {
+	var store *dictionaries.Store
+	if env.StringOptional("S3_BUCKET_WEB") != "" {
+		objStorage, err := newObjectStorage()
+		if err != nil {
+			return nil, err
+		}
+		if store, err = dictionaries.NewStore(objStorage, time.Minute); err != nil {
+			return nil, err
+		}
+	}
+	var data []byte
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		part, err := decompress(file, store)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		data = append(data, part...)
+	}
+	return data, nil
+}
+
+func newObjectStorage() (storage.ObjectStorage, error) {
+	return storage.NewObjectStorage(env.StringOptional("STORAGE_PROVIDER"),
+		env.StringOptional("AWS_REGION_WEB"), env.String("S3_BUCKET_WEB"))
+}
+
+func downloadSession(sessionID uint64) ([]byte, error) {
+	objStorage, err := newObjectStorage()
+	if err != nil {
+		return nil, err
+	}
+	store, err := dictionaries.NewStore(objStorage, time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	key := strconv.FormatUint(sessionID, 10)
+	data, err := downloadFile(objStorage, store, key)
+	if err != nil {
+		return nil, err
+	}
+	if objStorage.Exists(key + "e") {
+		end, err := downloadFile(objStorage, store, key+"e")
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, end...)
+	}
+	return data, nil
+}
+
+func downloadFile(objStorage storage.ObjectStorage, store *dictionaries.Store, key string) ([]byte, error) {
+	file, err := objStorage.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return decompress(file, store)
+}
+
+func decompress(file io.Reader, store *dictionaries.Store) ([]byte, error) {
+	reader, err := dictionaries.NewReader(file, store)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// printer writes records as they are decoded, so big recordings aren't kept twice in memory
+type printer struct {
+	out     io.Writer
+	buf     bytes.Buffer
+	encoder *json.Encoder
+	array   bool
+	count   int
+}
+
+func newPrinter(out io.Writer, format string) *printer {
+	p := &printer{out: out, array: format == "json"}
+	p.encoder = json.NewEncoder(&p.buf)
+	p.encoder.SetEscapeHTML(false) // DOM messages are full of html
+	if p.array {
+		p.encoder.SetIndent("  ", "  ")
+	}
+	return p
+}
+
+func (p *printer) print(record *Record) error {
+	p.buf.Reset()
+	if err := p.encoder.Encode(record); err != nil {
+		return err
+	}
+	if p.array {
+		sep := ",\n  "
+		if p.count == 0 {
+			sep = "[\n  "
+		}
+		if _, err := io.WriteString(p.out, sep); err != nil {
+			return err
+		}
+		p.buf.Truncate(p.buf.Len() - 1) // separator goes before the next record
+	}
+	p.count++
+	_, err := p.out.Write(p.buf.Bytes())
+	return err
+}
+
+func (p *printer) close() error {
+	if !p.array {
+		return nil
+	}
+	if p.count == 0 {
+		_, err := io.WriteString(p.out, "[]\n")
+		return err
+	}
+	_, err := io.WriteString(p.out, "\n]\n")
+	return err
+}
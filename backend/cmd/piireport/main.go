@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	config "openreplay/backend/internal/config/piireport"
+	"openreplay/backend/pkg/db/postgres"
+	"openreplay/backend/pkg/dictionaries"
+	"openreplay/backend/pkg/messages/delta"
+	"openreplay/backend/pkg/monitoring"
+	"openreplay/backend/pkg/pii"
+	"openreplay/backend/pkg/storage"
+)
+
+// Offline job, samples recent recordings of each project and reports likely personal data
+// found by scrubbing rules, so redaction can be tuned before recordings are shared.
+func main() {
+	metrics := monitoring.New("piireport")
+
+	log.SetFlags(log.LstdFlags | log.LUTC | log.Llongfile)
+
+	cfg := config.New()
+
+	pg := postgres.NewConn(cfg.Postgres, 0, 0, metrics)
+	defer pg.Close()
+
+	objStorage, err := storage.NewObjectStorage(cfg.StorageProvider, cfg.S3Region, cfg.S3Bucket)
+	if err != nil {
+		log.Fatalf("can't init object storage: %s", err)
+	}
+	store, err := dictionaries.NewStore(objStorage, time.Minute)
+	if err != nil {
+		log.Fatalf("can't init dictionaries store: %s", err)
+	}
+	engine, err := pii.NewEngine(pii.ModeDetect, cfg.Rules, cfg.CustomRules)
+	if err != nil {
+		log.Fatalf("can't init rule engine: %s", err)
+	}
+	if err := os.MkdirAll(cfg.ReportDir, 0755); err != nil {
+		log.Fatalf("can't create report dir: %s", err)
+	}
+
+	projects, err := getProjects(cfg, pg)
+	if err != nil {
+		log.Fatalf("can't get projects: %s", err)
+	}
+	for _, projectID := range projects {
+		if err := reportProject(cfg, pg, objStorage, store, engine, projectID); err != nil {
+			log.Printf("can't make pii report, projID: %d, err: %s", projectID, err)
+		}
+	}
+	log.Printf("PII reports finished, projects: %d", len(projects))
+}
+
+func getProjects(cfg *config.Config, pg *postgres.Conn) ([]uint32, error) {
+	if len(cfg.Projects) == 0 {
+		return pg.GetActiveProjectIDs()
+	}
+	projects := make([]uint32, 0, len(cfg.Projects))
+	for _, project := range cfg.Projects {
+		projectID, err := strconv.ParseUint(project, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, uint32(projectID))
+	}
+	return projects, nil
+}
+
+func reportProject(cfg *config.Config, pg *postgres.Conn, objStorage storage.ObjectStorage, store *dictionaries.Store,
+	engine *pii.Engine, projectID uint32) error {
+	sessions, err := pg.GetRecentSessionIDs(projectID, cfg.SessionsPerProject)
+	if err != nil {
+		return err
+	}
+	report, err := pii.NewReport(projectID, engine)
+	if err != nil {
+		return err
+	}
+	for _, sessionID := range sessions {
+		data, err := downloadSession(objStorage, store, sessionID)
+		if err != nil {
+			log.Printf("can't download session, sessID: %d, err: %s", sessionID, err)
+			continue
+		}
+		if err := report.AddSession(sessionID, data); err != nil {
+			log.Printf("session file is checked partially, sessID: %d, err: %s", sessionID, err)
+		}
+	}
+	report.Finish()
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(cfg.ReportDir, strconv.FormatUint(uint64(projectID), 10)+".json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	log.Printf("pii report saved, projID: %d, sessions: %d, findings: %d, path: %s",
+		projectID, report.Sessions, len(report.Findings), path)
+	return nil
+}
+
+// downloadSession returns the whole decompressed recording, both parts of the split file
+func downloadSession(objStorage storage.ObjectStorage, store *dictionaries.Store, sessionID uint64) ([]byte, error) {
+	key := strconv.FormatUint(sessionID, 10)
+	data, err := downloadFile(objStorage, store, key)
+	if err != nil {
+		return nil, err
+	}
+	if objStorage.Exists(key + "e") {
+		end, err := downloadFile(objStorage, store, key+"e")
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, end...)
+	}
+	return delta.Decode(data)
+}
+
+func downloadFile(objStorage storage.ObjectStorage, store *dictionaries.Store, key string) ([]byte, error) {
+	file, err := objStorage.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader, err := dictionaries.NewReader(file, store)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
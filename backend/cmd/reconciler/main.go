@@ -0,0 +1,74 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	config "openreplay/backend/internal/config/reconciler"
+	"openreplay/backend/pkg/db/postgres"
+	"openreplay/backend/pkg/monitoring"
+	"openreplay/backend/pkg/reconcile"
+	"openreplay/backend/pkg/topology"
+)
+
+// Consistency checker, periodically compares sessions in Postgres and ClickHouse
+// and reports (optionally repairs) sessions present only in one of the stores
+func main() {
+	metrics := monitoring.New("reconciler")
+
+	log.SetFlags(log.LstdFlags | log.LUTC | log.Llongfile)
+
+	cfg := config.New()
+
+	pg := postgres.NewConn(cfg.Postgres, 0, 0, metrics)
+	defer pg.Close()
+
+	store, err := reconcile.NewStore(cfg.ClickHouse)
+	if err != nil {
+		log.Fatalf("can't init analytics store: %s", err)
+	}
+	defer store.Close()
+
+	checker, err := reconcile.NewChecker(pg, store, cfg.Repair, metrics)
+	if err != nil {
+		log.Fatalf("can't init consistency checker: %s", err)
+	}
+
+	// Windows follow each other, the first one is the last window which ended before the delay
+	next := time.Now().Add(-cfg.Delay - cfg.Window).Truncate(cfg.Window)
+	check := func() {
+		for !next.Add(cfg.Window).After(time.Now().Add(-cfg.Delay)) {
+			report, err := checker.Check(next, next.Add(cfg.Window))
+			if err != nil {
+				log.Printf("consistency check failed: %s", err)
+				return
+			}
+			log.Printf("consistency check: %s", report)
+			next = next.Add(cfg.Window)
+		}
+	}
+	check()
+
+	topo := topology.New("reconciler", "")
+	topo.Store("postgres", cfg.Postgres)
+	topo.Store("clickhouse", cfg.ClickHouse)
+
+	log.Printf("Reconciler service started\n")
+
+	sigchan := make(chan os.Signal, 1)
+	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
+
+	tick := time.Tick(cfg.Interval)
+	for {
+		select {
+		case sig := <-sigchan:
+			log.Printf("Caught signal %v: terminating\n", sig)
+			os.Exit(0)
+		case <-tick:
+			check()
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"openreplay/backend/pkg/env"
+	"openreplay/backend/pkg/queue"
+	"openreplay/backend/pkg/queue/types"
+)
+
+const usage = `Sends batches of the dead letter queue (QUEUE_DLQ_TOPIC of the services) back to their topics.
+Only the consumer group which failed to process the batch reads it again. Stops when there are
+no new letters for the idle time, letters are committed after all batches are sent.
+
+Usage:
+  redrive [flags]
+
+The queue is configured by the same environment variables as the services (QUEUE_BACKEND, KAFKA_SERVERS,
+REDIS_STRING, ...). Every letter is printed: failure time, group, topic, session id, size, attempts,
+messages handled before the failure (the group skips them when the batch is redriven) and error.
+
+Flags:
+`
+
+const defaultMessageSizeLimit = 1048576
+
+func main() {
+	log.SetFlags(0)
+
+	topic := flag.String("topic", os.Getenv("QUEUE_DLQ_TOPIC"), "dead letter topic")
+	group := flag.String("group", "redrive", "consumer group of the tool")
+	idle := flag.Duration("idle", 10*time.Second, "stop if there are no new letters for this time")
+	dryRun := flag.Bool("dry-run", false, "print letters without sending and committing them")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *topic == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	messageSizeLimit := env.IntOptional("QUEUE_MESSAGE_SIZE_LIMIT")
+	if messageSizeLimit == 0 {
+		messageSizeLimit = defaultMessageSizeLimit
+	}
+	producer := queue.NewRedriveProducer(messageSizeLimit)
+	defer producer.Close(15000)
+
+	redriven, wrong := 0, 0
+	lastRead := time.Now()
+	consumer := queue.NewConsumer(*group, []string{*topic}, func(_ uint64, value []byte, meta *types.Meta) {
+		lastRead = time.Now()
+		letter, err := queue.DecodeDeadLetter(value)
+		if err != nil {
+			log.Printf("can't decode letter %d: %s", meta.ID, err)
+			wrong++
+			return
+		}
+		fmt.Printf("%s\t%s\t%s\t%d\t%d\t%d\t%d\t%s\n", time.UnixMilli(letter.FailedAt).UTC().Format(time.RFC3339),
+			letter.Group, letter.Topic, letter.SessionID, len(letter.Value), letter.Attempts, letter.Handled, letter.Error)
+		if *dryRun {
+			return
+		}
+		// Not committed letters are read again by the next run
+		if err := producer.Produce(letter.Topic, letter.SessionID, letter.Redrive()); err != nil {
+			log.Fatalf("can't send batch to %s: %s", letter.Topic, err)
+		}
+		redriven++
+	}, false, 2*messageSizeLimit)
+	defer consumer.Close()
+
+	for time.Since(lastRead) < *idle {
+		if err := consumer.ConsumeNext(); err != nil {
+			log.Fatalf("can't read dead letters: %s", err)
+		}
+	}
+	if !*dryRun {
+		producer.Flush(15000)
+		if err := consumer.Commit(); err != nil {
+			log.Fatalf("can't commit dead letters: %s", err)
+		}
+	}
+	log.Printf("redriven batches: %d, wrong letters: %d", redriven, wrong)
+}
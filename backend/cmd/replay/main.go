@@ -0,0 +1,238 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"openreplay/backend/pkg/db/postgres"
+	"openreplay/backend/pkg/env"
+	"openreplay/backend/pkg/monitoring"
+	"openreplay/backend/pkg/queue"
+	"openreplay/backend/pkg/queue/types"
+)
+
+const usage = `Reprocesses a window of a topic by one consumer group, e.g. raw messages after a bug in db or heuristics.
+
+Usage:
+  replay -group <group> -topic <topic> [-from <time>] [-to <time>] [flags]
+  replay -group <group> -topic <topic> -from <time> -seek
+
+By default the topic is read from the beginning by a new consumer group of the tool and batches of the window
+(-from/-to timestamps or -from-offset/-to-offset offsets of -partition) are sent back to the topic, the same
+way as by redrive: only the chosen group processes them again, the other groups skip them. -project keeps
+batches of sessions of the project only (POSTGRES_STRING is required to find projects of sessions).
+The tool stops when there are no new messages for the idle time.
+
+With -seek the committed offsets of the group are moved to the -from time instead, the group reads everything
+after it again. It requires kafka, and all services of the group have to be stopped, so the tool gets
+all partitions of the topic.
+
+The queue is configured by the same environment variables as the services (QUEUE_BACKEND, KAFKA_SERVERS, ...).
+
+Flags:
+`
+
+const (
+	defaultMessageSizeLimit = 1048576
+	seekAssignTimeout       = 30 * time.Second
+)
+
+// seeker is implemented by the kafka consumer
+type seeker interface {
+	CommitAtTimestamp(commitTs int64) error
+}
+
+// window of the topic which is replayed, zero bounds are open
+type window struct {
+	from, to             int64 // unix ms
+	fromOffset, toOffset uint64
+	partition            int64 // -1 means offsets of all partitions
+}
+
+func (w *window) contains(meta *types.Meta) bool {
+	if w.from > 0 && meta.Timestamp < w.from || w.to > 0 && meta.Timestamp > w.to {
+		return false
+	}
+	if w.partition >= 0 && meta.Partition != uint64(w.partition) {
+		return false
+	}
+	return meta.ID >= w.fromOffset && (w.toOffset == 0 || meta.ID <= w.toOffset)
+}
+
+type partitions struct {
+	assigned []types.TopicPartition
+}
+
+func (p *partitions) Assigned(assigned []types.TopicPartition) {
+	p.assigned = append(p.assigned, assigned...)
+}
+
+func (p *partitions) Revoked(_ []types.TopicPartition) {}
+
+func main() {
+	log.SetFlags(0)
+
+	group := flag.String("group", "", "consumer group which reprocesses the window")
+	topic := flag.String("topic", "", "topic of the window")
+	from := flag.String("from", "", "start of the window, RFC3339 time")
+	to := flag.String("to", "", "end of the window, RFC3339 time")
+	fromOffset := flag.Uint64("from-offset", 0, "first offset of the window")
+	toOffset := flag.Uint64("to-offset", 0, "last offset of the window, 0 means no limit")
+	partition := flag.Int64("partition", -1, "partition of the offsets, -1 means all partitions")
+	projectID := flag.Uint64("project", 0, "replay sessions of the project only")
+	seek := flag.Bool("seek", false, "move committed offsets of the group to the -from time")
+	idle := flag.Duration("idle", 10*time.Second, "stop if there are no new messages for this time")
+	dryRun := flag.Bool("dry-run", false, "count batches of the window without sending them")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *group == "" || *topic == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	w := &window{fromOffset: *fromOffset, toOffset: *toOffset, partition: *partition}
+	var err error
+	if w.from, err = parseTime(*from); err != nil {
+		log.Fatalf("wrong -from: %s", err)
+	}
+	if w.to, err = parseTime(*to); err != nil {
+		log.Fatalf("wrong -to: %s", err)
+	}
+	if w.to > 0 && w.to < w.from || w.toOffset > 0 && w.toOffset < w.fromOffset {
+		log.Fatalf("the end of the window is before its start")
+	}
+
+	messageSizeLimit := env.IntOptional("QUEUE_MESSAGE_SIZE_LIMIT")
+	if messageSizeLimit == 0 {
+		messageSizeLimit = defaultMessageSizeLimit
+	}
+
+	if *seek {
+		if w.from == 0 || *projectID != 0 || *fromOffset != 0 || *toOffset != 0 {
+			log.Fatalf("-seek requires -from and can't be used with -project or offsets")
+		}
+		seekGroup(*group, *topic, w.from, messageSizeLimit)
+		return
+	}
+	replay(*group, *topic, w, uint32(*projectID), *idle, *dryRun, messageSizeLimit)
+}
+
+func parseTime(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, err
+	}
+	return t.UnixMilli(), nil
+}
+
+// seekGroup joins the group, waits for the partitions and commits the offsets of the time
+func seekGroup(group, topic string, ts int64, messageSizeLimit int) {
+	consumer := queue.NewConsumer(group, []string{topic}, func(uint64, []byte, *types.Meta) {}, false, messageSizeLimit)
+	defer consumer.Close()
+	s, ok := consumer.(seeker)
+	if !ok {
+		log.Fatalf("-seek requires kafka queue backend")
+	}
+	assigned := &partitions{}
+	consumer.SetPartitionListener(assigned)
+	start := time.Now()
+	for len(assigned.assigned) == 0 {
+		if time.Since(start) > seekAssignTimeout {
+			log.Fatalf("partitions of %s aren't assigned, are services of group %s stopped?", topic, group)
+		}
+		// Read messages aren't committed, only the offsets of the time are
+		if err := consumer.ConsumeNext(); err != nil {
+			log.Fatalf("can't read %s: %s", topic, err)
+		}
+	}
+	if err := s.CommitAtTimestamp(ts); err != nil {
+		log.Fatalf("can't commit offsets: %s", err)
+	}
+	list := make([]string, 0, len(assigned.assigned))
+	for _, tp := range assigned.assigned {
+		list = append(list, tp.String())
+	}
+	log.Printf("group %s is moved to %s, partitions: %s", group, time.UnixMilli(ts).UTC().Format(time.RFC3339),
+		strings.Join(list, ","))
+}
+
+// replay reads the topic by a new group, so the window is found from the beginning of the topic every time
+func replay(group, topic string, w *window, projectID uint32, idle time.Duration, dryRun bool, messageSizeLimit int) {
+	var pg *postgres.Conn
+	if projectID != 0 {
+		pg = postgres.NewConn(env.String("POSTGRES_STRING"), 0, 0, monitoring.New("replay"))
+		defer pg.Close()
+	}
+	projects := make(map[uint64]uint32)
+	sessionProject := func(sessionID uint64) (uint32, error) {
+		if p, ok := projects[sessionID]; ok {
+			return p, nil
+		}
+		p, _, err := pg.GetSessionPartitionKeys(sessionID)
+		if err != nil {
+			return 0, err
+		}
+		projects[sessionID] = p
+		return p, nil
+	}
+
+	producer := queue.NewRedriveProducer(messageSizeLimit)
+	defer producer.Close(15000)
+
+	replayed, skipped := 0, 0
+	lastRead := time.Now()
+	reader := fmt.Sprintf("replay-%s-%d", group, time.Now().Unix())
+	consumer := queue.NewConsumer(reader, []string{topic}, func(sessionID uint64, value []byte, meta *types.Meta) {
+		lastRead = time.Now()
+		// Batches which were redriven or replayed before are processed by their groups already
+		if !w.contains(meta) || queue.IsRedriven(value) {
+			return
+		}
+		if projectID != 0 {
+			p, err := sessionProject(sessionID)
+			if err != nil {
+				log.Printf("can't get project of session %d: %s", sessionID, err)
+				skipped++
+				return
+			}
+			if p != projectID {
+				return
+			}
+		}
+		replayed++
+		if dryRun {
+			return
+		}
+		letter := &queue.DeadLetter{
+			Group:     group,
+			Topic:     meta.Topic,
+			Partition: meta.Partition,
+			MessageID: meta.ID,
+			Timestamp: meta.Timestamp,
+			SessionID: sessionID,
+			Value:     value,
+		}
+		if err := producer.Produce(meta.Topic, sessionID, letter.Redrive()); err != nil {
+			log.Fatalf("can't send batch to %s: %s", meta.Topic, err)
+		}
+	}, true, messageSizeLimit)
+	defer consumer.Close()
+
+	for time.Since(lastRead) < idle {
+		if err := consumer.ConsumeNext(); err != nil {
+			log.Fatalf("can't read %s: %s", topic, err)
+		}
+	}
+	producer.Flush(15000)
+	log.Printf("replayed batches: %d, skipped sessions without project: %d, reader group: %s", replayed, skipped, reader)
+}
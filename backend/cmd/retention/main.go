@@ -0,0 +1,118 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	config "openreplay/backend/internal/config/retention"
+	"openreplay/backend/pkg/db/postgres"
+	"openreplay/backend/pkg/monitoring"
+	"openreplay/backend/pkg/partitions"
+	"openreplay/backend/pkg/retention"
+	"openreplay/backend/pkg/storage"
+	"openreplay/backend/pkg/topology"
+)
+
+// Retention service, periodically deletes sessions, their events, issues and files
+// which are older than the retention of their project, erases sessions of users on their requests and manages
+// partitions of the largest tables
+func main() {
+	metrics := monitoring.New("retention")
+
+	log.SetFlags(log.LstdFlags | log.LUTC | log.Llongfile)
+
+	cfg := config.New()
+
+	pg := postgres.NewConn(cfg.Postgres, 0, 0, metrics)
+	defer pg.Close()
+
+	store, err := retention.NewStore(cfg.ClickHouse, pg)
+	if err != nil {
+		log.Fatalf("can't init analytics store: %s", err)
+	}
+	if store != nil {
+		defer store.Close()
+	}
+
+	var objects storage.ObjectStorage
+	if cfg.S3Bucket != "" {
+		if objects, err = storage.NewObjectStorage(cfg.StorageProvider, cfg.S3Region, cfg.S3Bucket); err != nil {
+			log.Fatalf("can't init object storage: %s", err)
+		}
+	}
+
+	cleaner, err := retention.NewCleaner(pg, store, objects, cfg.CanvasPrefix, cfg.DefaultRetention,
+		cfg.BatchSize, cfg.DryRun, metrics)
+	if err != nil {
+		log.Fatalf("can't init retention cleaner: %s", err)
+	}
+	run := func() {
+		if err := cleaner.Run(time.Now()); err != nil {
+			log.Printf("retention cleanup failed: %s", err)
+		}
+	}
+	run()
+
+	erase := func() {
+		if err := cleaner.RunErasures(cfg.ErasureBatchSize); err != nil {
+			log.Printf("erasure failed: %s", err)
+		}
+	}
+	erase()
+
+	pgPartitions, err := partitions.NewPostgresStore(pg, cfg.PartitionTables)
+	if err != nil {
+		log.Fatalf("can't init postgres partitions: %s", err)
+	}
+	stores := map[string]partitions.Store{"postgres": pgPartitions}
+	analyticsPartitions, err := partitions.NewAnalyticsStore(cfg.ClickHouse)
+	if err != nil {
+		log.Fatalf("can't init analytics partitions: %s", err)
+	}
+	if analyticsPartitions != nil {
+		defer analyticsPartitions.Close()
+		stores["analytics"] = analyticsPartitions
+	}
+	manager, err := partitions.NewManager(stores, cfg.PartitionAhead, cfg.PartitionRetention, cfg.DryRun, metrics)
+	if err != nil {
+		log.Fatalf("can't init partition manager: %s", err)
+	}
+	managePartitions := func() {
+		if err := manager.Run(time.Now()); err != nil {
+			log.Printf("partition management failed: %s", err)
+		}
+	}
+	managePartitions()
+
+	topo := topology.New("retention", "")
+	topo.Store("postgres", cfg.Postgres)
+	if cfg.ClickHouse != "" {
+		topo.Store("clickhouse", cfg.ClickHouse)
+	}
+	if cfg.S3Bucket != "" {
+		topo.Store("storage", cfg.S3Bucket)
+	}
+
+	log.Printf("Retention service started, dry run: %t\n", cfg.DryRun)
+
+	sigchan := make(chan os.Signal, 1)
+	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
+
+	tick := time.Tick(cfg.Interval)
+	erasureTick := time.Tick(cfg.ErasureInterval)
+	for {
+		select {
+		case sig := <-sigchan:
+			log.Printf("Caught signal %v: terminating\n", sig)
+			os.Exit(0)
+		case <-tick:
+			run()
+			managePartitions()
+		case <-erasureTick:
+			erase()
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	config "openreplay/backend/internal/config/rollup"
+	"openreplay/backend/pkg/monitoring"
+	"openreplay/backend/pkg/rollup"
+	"openreplay/backend/pkg/topology"
+)
+
+// Rollup service, downsamples per-minute internal metrics to hourly and daily ones
+// and removes the data of each resolution after its retention
+func main() {
+	metrics := monitoring.New("rollup")
+
+	log.SetFlags(log.LstdFlags | log.LUTC | log.Llongfile)
+
+	cfg := config.New()
+
+	store, err := rollup.NewStore(cfg.ClickHouse)
+	if err != nil {
+		log.Fatalf("can't init metrics store: %s", err)
+	}
+	defer store.Close()
+
+	roller, err := rollup.NewRoller(store, []rollup.Level{
+		{Name: "minutely", Period: time.Minute, Retention: cfg.RetentionMinutely},
+		{Name: "hourly", Period: time.Hour, Retention: cfg.RetentionHourly},
+		{Name: "daily", Period: 24 * time.Hour, Retention: cfg.RetentionDaily},
+	}, cfg.Lateness, metrics)
+	if err != nil {
+		log.Fatalf("can't init rollup: %s", err)
+	}
+	run := func() {
+		if err := roller.Run(time.Now()); err != nil {
+			log.Printf("rollup failed: %s", err)
+		}
+	}
+	run()
+
+	topo := topology.New("rollup", "")
+	topo.Store("clickhouse", cfg.ClickHouse)
+
+	log.Printf("Rollup service started\n")
+
+	sigchan := make(chan os.Signal, 1)
+	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
+
+	tick := time.Tick(cfg.Interval)
+	for {
+		select {
+		case sig := <-sigchan:
+			log.Printf("Caught signal %v: terminating\n", sig)
+			os.Exit(0)
+		case <-tick:
+			run()
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 	"openreplay/backend/pkg/queue/types"
+	"openreplay/backend/pkg/validation"
 	"os"
 	"os/signal"
 	"syscall"
@@ -11,11 +12,18 @@ import (
 
 	"openreplay/backend/internal/config/sink"
 	"openreplay/backend/internal/sink/assetscache"
+	"openreplay/backend/internal/sink/canvas"
+	"openreplay/backend/internal/sink/domdict"
 	"openreplay/backend/internal/sink/oswriter"
+	"openreplay/backend/internal/sink/timeorder"
 	"openreplay/backend/internal/storage"
+	"openreplay/backend/pkg/budget"
+	"openreplay/backend/pkg/control"
 	. "openreplay/backend/pkg/messages"
 	"openreplay/backend/pkg/monitoring"
+	"openreplay/backend/pkg/msgstats"
 	"openreplay/backend/pkg/queue"
+	"openreplay/backend/pkg/topology"
 	"openreplay/backend/pkg/url/assets"
 )
 
@@ -26,6 +34,38 @@ func main() {
 
 	cfg := sink.New()
 
+	resources, err := budget.New(&cfg.Config, metrics)
+	if err != nil {
+		log.Fatalf("can't init resource budget: %s", err)
+	}
+	ctrl, err := control.New("sink", &cfg.Config, metrics)
+	if err != nil {
+		log.Fatalf("can't init control api: %s", err)
+	}
+	ctrl.Stat("budget_level", func() float64 { return float64(resources.Level()) })
+	ctrl.OnReload(func() ([]string, error) {
+		fresh := sink.New()
+		if err := resources.SetLimits(&fresh.Config); err != nil {
+			return nil, err
+		}
+		return control.Diff(cfg, fresh), nil
+	})
+	validator, err := validation.New(&cfg.Config, metrics)
+	if err != nil {
+		log.Fatalf("can't init message validator: %s", err)
+	}
+	logDedup, err := validation.NewLogDeduplicator(&cfg.Config, metrics)
+	if err != nil {
+		log.Fatalf("can't init log deduplicator: %s", err)
+	}
+	clockNormalizer, err := validation.NewClockNormalizer(&cfg.Config, metrics)
+	if err != nil {
+		log.Fatalf("can't init clock normalizer: %s", err)
+	}
+	if err := validation.CountDeprecated(metrics); err != nil {
+		log.Printf("can't count deprecated messages: %s", err)
+	}
+
 	if _, err := os.Stat(cfg.FsDir); os.IsNotExist(err) {
 		log.Fatalf("%v doesn't exist. %v", cfg.FsDir, err)
 	}
@@ -37,6 +77,30 @@ func main() {
 	rewriter := assets.NewRewriter(cfg.AssetsOrigin)
 	assetMessageHandler := assetscache.New(cfg, rewriter, producer)
 
+	timeKeeper, err := timeorder.New(cfg.TimestampTolerance, cfg.SessionIdleTimeout, metrics)
+	if err != nil {
+		log.Fatalf("can't init timestamp keeper: %s", err)
+	}
+
+	canvasWriter, err := canvas.New(cfg.FsDir, cfg.CanvasMaxSessionSize, cfg.SessionIdleTimeout, metrics)
+	if err != nil {
+		log.Fatalf("can't init canvas writer: %s", err)
+	}
+
+	var attrEncoder *domdict.Encoder
+	if cfg.UseAttributeDictionary {
+		attrEncoder, err = domdict.New(cfg.AttributeDictionaryMaxEntries, cfg.SessionIdleTimeout, metrics)
+		if err != nil {
+			log.Fatalf("can't init attribute dictionary: %s", err)
+		}
+	}
+
+	// Projects of sessions are known from their start messages
+	typeStats, err := msgstats.New(metrics, nil)
+	if err != nil {
+		log.Fatalf("can't init message stats: %s", err)
+	}
+
 	counter := storage.NewLogCounter()
 	totalMessages, err := metrics.RegisterCounter("messages_total")
 	if err != nil {
@@ -51,23 +115,79 @@ func main() {
 		log.Printf("can't create messages_size metric: %s", err)
 	}
 
+	writeMessage := func(sessionID uint64, msg Message) {
+		// If message timestamp is empty, use at least ts of session start
+		ts := msg.Meta().Timestamp
+		if ts == 0 {
+			log.Printf("zero ts; sessID: %d, msgType: %d", sessionID, msg.TypeID())
+		} else {
+			// Log ts of last processed message
+			counter.Update(sessionID, time.UnixMilli(ts))
+		}
+
+		// Write encoded message with index to session file
+		data := msg.EncodeWithIndex()
+		if err := writer.Write(sessionID, data); err != nil {
+			log.Printf("Writer error: %v\n", err)
+		}
+
+		// [METRICS] Increase the number of written to the files messages and the message size
+		messageSize.Record(context.Background(), float64(len(data)))
+		savedMessages.Add(context.Background(), 1)
+	}
+
 	consumer := queue.NewMessageConsumer(
 		cfg.GroupSink,
 		[]string{
 			cfg.TopicRawWeb,
+			cfg.TopicRawIOS,
 		},
 		func(sessionID uint64, iter Iterator, meta *types.Meta) {
+			// Client clocks are corrected before the validation of timestamps
+			if meta.Topic == cfg.TopicRawWeb {
+				iter = clockNormalizer.Wrap(sessionID, iter, meta.Timestamp)
+			}
+			iter = validator.Wrap(iter)
 			for iter.Next() {
 				// [METRICS] Increase the number of processed messages
 				totalMessages.Add(context.Background(), 1)
+				typeStats.Add(sessionID, iter.Message())
 
 				// Send SessionEnd trigger to storage service
 				if iter.Type() == MsgSessionEnd {
+					for _, m := range logDedup.Flush(sessionID, iter.Message()) {
+						writeMessage(sessionID, timeKeeper.Apply(sessionID, m))
+					}
+					timeKeeper.Delete(sessionID)
+					clockNormalizer.Delete(sessionID)
+					canvasWriter.Delete(sessionID)
+					if attrEncoder != nil {
+						attrEncoder.Delete(sessionID)
+					}
 					if err := producer.Produce(cfg.TopicTrigger, sessionID, iter.Message().Encode()); err != nil {
 						log.Printf("can't send SessionEnd to trigger topic: %s; sessID: %d", err, sessionID)
 					}
 					continue
 				}
+				// Mobile sessions are uploaded by the same trigger
+				if iter.Type() == MsgIOSSessionEnd {
+					m := iter.Message().Decode()
+					if m == nil {
+						return
+					}
+					logDedup.Flush(sessionID, m)
+					timeKeeper.Delete(sessionID)
+					clockNormalizer.Delete(sessionID)
+					canvasWriter.Delete(sessionID)
+					if attrEncoder != nil {
+						attrEncoder.Delete(sessionID)
+					}
+					sessionEnd := &SessionEnd{Timestamp: m.(*IOSSessionEnd).Timestamp}
+					if err := producer.Produce(cfg.TopicTrigger, sessionID, sessionEnd.Encode()); err != nil {
+						log.Printf("can't send SessionEnd to trigger topic: %s; sessID: %d", err, sessionID)
+					}
+					continue
+				}
 
 				msg := iter.Message()
 				// Process assets
@@ -83,35 +203,54 @@ func main() {
 					msg = assetMessageHandler.ParseAssets(sessionID, m) // TODO: filter type only once (use iterator inide or bring ParseAssets out here).
 				}
 
+				// Images of canvas snapshots are stored next to the session file
+				if iter.Type() == MsgCanvasSnapshot {
+					m := msg.Decode()
+					if m == nil {
+						return
+					}
+					if msg = canvasWriter.Write(sessionID, m.(*CanvasSnapshot)); msg == nil {
+						continue
+					}
+				}
+
 				// Filter message
 				if !IsReplayerType(msg.TypeID()) {
 					continue
 				}
 
-				// If message timestamp is empty, use at least ts of session start
-				ts := msg.Meta().Timestamp
-				if ts == 0 {
-					log.Printf("zero ts; sessID: %d, msgType: %d", sessionID, iter.Type())
-				} else {
-					// Log ts of last processed message
-					counter.Update(sessionID, time.UnixMilli(ts))
-				}
+				// Repeated console logs are collapsed into the first one and the summary
+				for _, msg := range logDedup.Apply(sessionID, msg) {
+					// Out-of-order timestamps break the replay
+					msg = timeKeeper.Apply(sessionID, msg)
 
-				// Write encoded message with index to session file
-				data := msg.EncodeWithIndex()
-				if err := writer.Write(sessionID, data); err != nil {
-					log.Printf("Writer error: %v\n", err)
+					// Attribute strings are replaced by dictionary references
+					if attrEncoder != nil && msg.TypeID() == MsgSetNodeAttribute {
+						for _, m := range attrEncoder.Encode(sessionID, msg) {
+							writeMessage(sessionID, m)
+						}
+						continue
+					}
+					writeMessage(sessionID, msg)
 				}
-
-				// [METRICS] Increase the number of written to the files messages and the message size
-				messageSize.Record(context.Background(), float64(len(data)))
-				savedMessages.Add(context.Background(), 1)
 			}
 			iter.Close()
 		},
 		false,
 		cfg.MessageSizeLimit,
 	)
+	topo := topology.New("sink", cfg.GroupSink)
+	topo.Consume(cfg.TopicRawWeb, cfg.TopicRawIOS)
+	topo.Produce(cfg.TopicTrigger, cfg.TopicCache)
+	topo.Store("fs", cfg.FsDir)
+	consumer.SetPartitionListener(topo.Listener(nil))
+
+	if lag, err := queue.NewLagMonitor(consumer, cfg.GroupSink, &cfg.Config, metrics); err != nil {
+		log.Printf("can't monitor consumer lag: %s", err)
+	} else {
+		ctrl.Stat("consumer_lag", func() float64 { return float64(lag.Total()) })
+	}
+
 	log.Printf("Sink service started\n")
 
 	sigchan := make(chan os.Signal, 1)
@@ -122,7 +261,10 @@ func main() {
 		select {
 		case sig := <-sigchan:
 			log.Printf("Caught signal %v: terminating\n", sig)
-			if err := consumer.Commit(); err != nil {
+			// Messages are committed only after they are synced to disk, otherwise they are read again
+			if err := writer.SyncAll(); err != nil {
+				log.Printf("can't sync files, messages aren't committed: %s", err)
+			} else if err := consumer.Commit(); err != nil {
 				log.Printf("can't commit messages: %s", err)
 			}
 			consumer.Close()
@@ -132,10 +274,28 @@ func main() {
 				log.Fatalf("Sync error: %v\n", err)
 			}
 			counter.Print()
+			timeKeeper.Cleanup()
+			canvasWriter.Cleanup()
+			logDedup.Cleanup()
+			clockNormalizer.Cleanup()
+			if attrEncoder != nil {
+				attrEncoder.Cleanup()
+			}
 			if err := consumer.Commit(); err != nil {
 				log.Printf("can't commit messages: %s", err)
 			}
+		case <-ctrl.Drains():
+			err := writer.SyncAll()
+			if err == nil {
+				producer.Flush(cfg.ProducerCloseTimeout)
+				err = consumer.Commit()
+			}
+			ctrl.Drained(err)
 		default:
+			if ctrl.Hold() {
+				continue
+			}
+			resources.Throttle()
 			err := consumer.ConsumeNext()
 			if err != nil {
 				log.Fatalf("Error on consumption: %v", err)
@@ -11,11 +11,17 @@ import (
 
 	config "openreplay/backend/internal/config/storage"
 	"openreplay/backend/internal/storage"
+	"openreplay/backend/pkg/budget"
+	"openreplay/backend/pkg/control"
+	"openreplay/backend/pkg/db/cache"
+	"openreplay/backend/pkg/dictionaries"
 	"openreplay/backend/pkg/failover"
 	"openreplay/backend/pkg/messages"
 	"openreplay/backend/pkg/monitoring"
 	"openreplay/backend/pkg/queue"
 	s3storage "openreplay/backend/pkg/storage"
+	"openreplay/backend/pkg/tiers"
+	"openreplay/backend/pkg/topology"
 )
 
 func main() {
@@ -25,8 +31,54 @@ func main() {
 
 	cfg := config.New()
 
-	s3 := s3storage.NewS3(cfg.S3Region, cfg.S3Bucket)
-	srv, err := storage.New(cfg, s3, metrics)
+	resources, err := budget.New(&cfg.Config, metrics)
+	if err != nil {
+		log.Fatalf("can't init resource budget: %s", err)
+	}
+	ctrl, err := control.New("storage", &cfg.Config, metrics)
+	if err != nil {
+		log.Fatalf("can't init control api: %s", err)
+	}
+	ctrl.Stat("budget_level", func() float64 { return float64(resources.Level()) })
+	ctrl.OnReload(func() ([]string, error) {
+		fresh := config.New()
+		if err := resources.SetLimits(&fresh.Config); err != nil {
+			return nil, err
+		}
+		return control.Diff(cfg, fresh), nil
+	})
+
+	objStorage, err := s3storage.NewObjectStorage(cfg.StorageProvider, cfg.S3Region, cfg.S3Bucket)
+	if err != nil {
+		log.Fatalf("can't init object storage: %s", err)
+	}
+	classes, err := s3storage.NewStorageClasses(cfg.StorageClass, cfg.ProjectClasses)
+	if err != nil {
+		log.Fatalf("can't init storage classes: %s", err)
+	}
+	projectTiers, err := tiers.New(cfg.DefaultTier, cfg.ProjectTiers)
+	if err != nil {
+		log.Fatalf("can't init project tiers: %s", err)
+	}
+	var (
+		dicts    *dictionaries.Store
+		sessions cache.SessionState
+	)
+	if cfg.UseDictionaries || classes.HasProjects() || (cfg.UploadConcurrency > 0 && projectTiers.HasProjects()) {
+		if sessions, err = cache.NewRedisSessionState(cfg.RedisString); err != nil {
+			log.Fatalf("can't init session state: %s", err)
+		}
+	}
+	if cfg.UseDictionaries {
+		if dicts, err = dictionaries.NewStore(objStorage, cfg.DictionariesTTL); err != nil {
+			log.Fatalf("can't init dictionaries store: %s", err)
+		}
+	}
+	var producer types.Producer
+	if cfg.TopicSessionReady != "" {
+		producer = queue.NewProducer(cfg.MessageSizeLimit, true)
+	}
+	srv, err := storage.New(cfg, objStorage, dicts, sessions, classes, projectTiers, producer, metrics)
 	if err != nil {
 		log.Printf("can't init storage service: %s", err)
 		return
@@ -38,7 +90,7 @@ func main() {
 		log.Fatalf("can't init sessionFinder module: %s", err)
 	}
 
-	consumer := queue.NewMessageConsumer(
+	consumer := queue.NewConcurrentMessageConsumer(
 		cfg.GroupStorage,
 		[]string{
 			cfg.TopicTrigger,
@@ -60,22 +112,59 @@ func main() {
 		cfg.MessageSizeLimit,
 	)
 
+	topo := topology.New("storage", cfg.GroupStorage)
+	topo.Consume(cfg.TopicTrigger)
+	if cfg.UseFailover {
+		topo.Consume(cfg.TopicFailover)
+		topo.Produce(cfg.TopicFailover)
+	}
+	if cfg.TopicSessionReady != "" {
+		topo.Produce(cfg.TopicSessionReady)
+	}
+	topo.Store("s3", cfg.S3Bucket)
+	topo.Store("redis", cfg.RedisString)
+	consumer.SetPartitionListener(topo.Listener(nil))
+
+	if lag, err := queue.NewLagMonitor(consumer, cfg.GroupStorage, &cfg.Config, metrics); err != nil {
+		log.Printf("can't monitor consumer lag: %s", err)
+	} else {
+		ctrl.Stat("consumer_lag", func() float64 { return float64(lag.Total()) })
+	}
+
 	log.Printf("Storage service started\n")
 
 	sigchan := make(chan os.Signal, 1)
 	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
 
 	counterTick := time.Tick(time.Second * 30)
+	var liveTick <-chan time.Time
+	if cfg.UseLiveUpload {
+		liveTick = time.Tick(cfg.LiveUploadInterval)
+	}
 	for {
 		select {
 		case sig := <-sigchan:
 			log.Printf("Caught signal %v: terminating\n", sig)
 			sessionFinder.Stop()
+			if producer != nil {
+				producer.Close(cfg.ProducerCloseTimeout)
+			}
 			consumer.Close()
 			os.Exit(0)
 		case <-counterTick:
 			go counter.Print()
+		case <-liveTick:
+			go srv.UploadLive()
+		case <-ctrl.Drains():
+			if producer != nil {
+				producer.Flush(cfg.ProducerCloseTimeout)
+			}
+			ctrl.Drained(consumer.Commit())
 		default:
+			if ctrl.Hold() {
+				continue
+			}
+			resources.Throttle()
 			err := consumer.ConsumeNext()
 			if err != nil {
 				log.Fatalf("Error on consumption: %v", err)
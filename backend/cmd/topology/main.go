@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"openreplay/backend/pkg/topology"
+)
+
+// Prints the pipeline topology assembled from the metrics servers of the services and warns about
+// topics without producers or consumers and partitions assigned to several consumers of the same group.
+// Usage: topology http://sink:8888 http://db:8888 ...
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		log.Fatalf("usage: %s <metrics server url>...", os.Args[0])
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+	var services []*topology.Topology
+	for _, addr := range os.Args[1:] {
+		t, err := fetch(client, addr)
+		if err != nil {
+			log.Printf("can't get topology of %s: %s", addr, err)
+			continue
+		}
+		services = append(services, t)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVICE\tHOST\tGROUP\tCONSUMES\tPRODUCES\tPARTITIONS\tSTORES")
+	for _, t := range services {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%v\t%s\n", t.Service, t.Hostname, t.Group,
+			strings.Join(t.Consumes, ","), strings.Join(t.Produces, ","), t.Partitions, stores(t.Stores))
+	}
+	w.Flush()
+
+	for _, warning := range check(services) {
+		fmt.Println("WARNING:", warning)
+	}
+}
+
+func fetch(client *http.Client, addr string) (*topology.Topology, error) {
+	res, err := client.Get(strings.TrimSuffix(addr, "/") + topology.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code is %d", res.StatusCode)
+	}
+	t := &topology.Topology{}
+	if err := json.NewDecoder(res.Body).Decode(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func stores(s map[string]string) string {
+	list := make([]string, 0, len(s))
+	for kind, addr := range s {
+		list = append(list, kind+"="+addr)
+	}
+	sort.Strings(list)
+	return strings.Join(list, " ")
+}
+
+func check(services []*topology.Topology) []string {
+	var warnings []string
+	producers := make(map[string][]string)
+	consumers := make(map[string][]string)
+	owners := make(map[string]map[uint64]string) // group -> partition -> host
+	for _, t := range services {
+		for _, topic := range t.Produces {
+			producers[topic] = append(producers[topic], t.Service)
+		}
+		for _, topic := range t.Consumes {
+			consumers[topic] = append(consumers[topic], t.Service)
+		}
+		if t.Group == "" {
+			continue
+		}
+		if owners[t.Group] == nil {
+			owners[t.Group] = make(map[uint64]string)
+		}
+		for _, partition := range t.Partitions {
+			if host, ok := owners[t.Group][partition]; ok {
+				warnings = append(warnings, fmt.Sprintf("partition %d of group %s is assigned to %s and %s", partition, t.Group, host, t.Hostname))
+				continue
+			}
+			owners[t.Group][partition] = t.Hostname
+		}
+	}
+	for topic, list := range consumers {
+		if _, ok := producers[topic]; !ok {
+			warnings = append(warnings, fmt.Sprintf("topic %s is consumed by %s, but nobody produces it", topic, strings.Join(list, ",")))
+		}
+	}
+	for topic, list := range producers {
+		if _, ok := consumers[topic]; !ok {
+			warnings = append(warnings, fmt.Sprintf("topic %s is produced by %s, but nobody consumes it", topic, strings.Join(list, ",")))
+		}
+	}
+	sort.Strings(warnings)
+	return warnings
+}
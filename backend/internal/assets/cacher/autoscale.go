@@ -0,0 +1,62 @@
+package cacher
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	scaleInterval   = time.Second
+	scaleDownChecks = 30 // pool starts shrinking after 30 checks in a row without backlog
+)
+
+// scaler adds workers when tasks wait in the queue longer than the target latency or the queue
+// is half full, and removes idle workers one by one when there is no backlog for a while
+func (p *WorkerPool) scaler() {
+	defer p.wg.Done()
+	tick := time.NewTicker(scaleInterval)
+	defer tick.Stop()
+	idleChecks := 0
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-tick.C:
+		}
+		workers := int(atomic.LoadInt32(&p.workers))
+		busy := int(atomic.LoadInt32(&p.busy))
+		wait := time.Duration(atomic.SwapInt64(&p.maxWait, 0))
+		queued := p.queued()
+		switch {
+		case workers < p.maxSize && queued > 0 && (wait > p.targetLatency || queued >= cap(p.low)/2):
+			idleChecks = 0
+			// Pool doubles on backlog and shrinks by one worker per check, so bursts are handled quickly
+			step := workers
+			if workers+step > p.maxSize {
+				step = p.maxSize - workers
+			}
+			p.startWorkers(step)
+			log.Printf("assets pool is scaled up to %d workers, queued: %d, wait: %s", workers+step, queued, wait)
+		case workers > p.minSize && queued == 0 && busy <= workers/2:
+			if idleChecks++; idleChecks < scaleDownChecks {
+				continue
+			}
+			// Only a worker waiting for tasks takes the signal
+			select {
+			case p.quit <- struct{}{}:
+			default:
+			}
+		default:
+			idleChecks = 0
+		}
+	}
+}
+
+// queued returns the number of tasks waiting for workers
+func (p *WorkerPool) queued() int {
+	p.spillMu.Lock()
+	spilled := len(p.spill)
+	p.spillMu.Unlock()
+	return len(p.high) + len(p.low) + spilled
+}
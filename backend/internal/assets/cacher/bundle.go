@@ -0,0 +1,241 @@
+package cacher
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/binary"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"openreplay/backend/pkg/storage"
+	"openreplay/backend/pkg/url/assets"
+)
+
+// Part numbers of the session are forgotten after this time without new assets
+const bundlePartsTTL = 6 * time.Hour
+
+type bundleItem struct {
+	Path        string `json:"path"`
+	ContentType string `json:"contentType"`
+	Offset      int    `json:"offset"`
+	Size        int    `json:"size"`
+	data        []byte
+}
+
+type sessionBundle struct {
+	items      []*bundleItem
+	paths      map[string]bool
+	size       int
+	part       int // number of the next uploaded part
+	lastUpdate time.Time
+}
+
+// contentCache keeps the latest small assets, so a session gets the asset into its bundle
+// even if it was fetched for another session
+type contentCache struct {
+	limit int
+	size  int
+	order *list.List // front is the most recently used
+	items map[string]*list.Element
+}
+
+type contentEntry struct {
+	path        string
+	contentType string
+	data        []byte
+}
+
+func newContentCache(limit int) *contentCache {
+	return &contentCache{
+		limit: limit,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (c *contentCache) get(path string) (*contentEntry, bool) {
+	el, ok := c.items[path]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*contentEntry), true
+}
+
+func (c *contentCache) put(entry *contentEntry) {
+	if el, ok := c.items[entry.path]; ok {
+		c.size -= len(el.Value.(*contentEntry).data)
+		c.order.Remove(el)
+	}
+	c.items[entry.path] = c.order.PushFront(entry)
+	c.size += len(entry.data)
+	for c.size > c.limit && c.order.Len() > 0 {
+		el := c.order.Back()
+		old := el.Value.(*contentEntry)
+		c.order.Remove(el)
+		delete(c.items, old.path)
+		c.size -= len(old.data)
+	}
+}
+
+// bundler collects small assets of each session and uploads them as one object
+// with an index, player fetches the bundle first and requests only missing assets separately
+// (frontend/app/player/MessageDistributor/network/loadBundles.ts)
+type bundler struct {
+	mu        sync.Mutex
+	s3        storage.ObjectStorage
+	sessions  map[uint64]*sessionBundle
+	content   *contentCache
+	itemLimit int
+	sizeLimit int
+	timeout   time.Duration
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+func newBundler(s3 storage.ObjectStorage, itemLimit, sizeLimit, cacheSize int, timeout time.Duration) *bundler {
+	if timeout <= 0 {
+		log.Printf("wrong bundle timeout: %s, using %s", timeout, time.Minute)
+		timeout = time.Minute
+	}
+	b := &bundler{
+		s3:        s3,
+		sessions:  make(map[uint64]*sessionBundle),
+		content:   newContentCache(cacheSize),
+		itemLimit: itemLimit,
+		sizeLimit: sizeLimit,
+		timeout:   timeout,
+		done:      make(chan struct{}),
+	}
+	b.wg.Add(1)
+	go b.run()
+	return b
+}
+
+func (b *bundler) run() {
+	defer b.wg.Done()
+	tick := time.NewTicker(b.timeout / 2)
+	defer tick.Stop()
+	for {
+		select {
+		case <-b.done:
+			b.flush(func(*sessionBundle) bool { return true })
+			return
+		case <-tick.C:
+			b.flush(func(sb *sessionBundle) bool { return time.Since(sb.lastUpdate) > b.timeout })
+		}
+	}
+}
+
+// add is called for the fetched asset, big assets aren't bundled
+func (b *bundler) add(sessionID uint64, path, contentType string, data []byte) {
+	if len(data) > b.itemLimit {
+		return
+	}
+	entry := &contentEntry{path: path, contentType: contentType, data: data}
+	b.mu.Lock()
+	b.content.put(entry)
+	full := b.addToSession(sessionID, entry)
+	b.mu.Unlock()
+	if full != nil {
+		b.upload(sessionID, full)
+	}
+}
+
+// addCached is called for the asset that wasn't fetched because of deduplication
+func (b *bundler) addCached(sessionID uint64, path string) {
+	b.mu.Lock()
+	entry, ok := b.content.get(path)
+	var full *sessionBundle
+	if ok {
+		full = b.addToSession(sessionID, entry)
+	}
+	b.mu.Unlock()
+	if full != nil {
+		b.upload(sessionID, full)
+	}
+}
+
+// addToSession returns a copy of the bundle if it reached the size limit and has to be uploaded
+func (b *bundler) addToSession(sessionID uint64, entry *contentEntry) *sessionBundle {
+	sb, ok := b.sessions[sessionID]
+	if !ok {
+		sb = &sessionBundle{paths: make(map[string]bool)}
+		b.sessions[sessionID] = sb
+	}
+	sb.lastUpdate = time.Now()
+	if sb.paths[entry.path] {
+		return nil
+	}
+	sb.paths[entry.path] = true
+	sb.items = append(sb.items, &bundleItem{
+		Path:        entry.path,
+		ContentType: entry.contentType,
+		Offset:      sb.size,
+		Size:        len(entry.data),
+		data:        entry.data,
+	})
+	sb.size += len(entry.data)
+	if sb.size < b.sizeLimit {
+		return nil
+	}
+	return b.takeBundle(sb)
+}
+
+// takeBundle moves collected items to the returned bundle and reserves the part number
+func (b *bundler) takeBundle(sb *sessionBundle) *sessionBundle {
+	full := &sessionBundle{items: sb.items, size: sb.size, part: sb.part}
+	sb.items, sb.size = nil, 0
+	sb.part++
+	return full
+}
+
+func (b *bundler) flush(needFlush func(*sessionBundle) bool) {
+	ready := make(map[uint64]*sessionBundle)
+	b.mu.Lock()
+	for sessionID, sb := range b.sessions {
+		if len(sb.items) > 0 && needFlush(sb) {
+			ready[sessionID] = b.takeBundle(sb)
+		} else if len(sb.items) == 0 && time.Since(sb.lastUpdate) > bundlePartsTTL {
+			delete(b.sessions, sessionID)
+		}
+	}
+	b.mu.Unlock()
+	for sessionID, sb := range ready {
+		b.upload(sessionID, sb)
+	}
+}
+
+func (b *bundler) upload(sessionID uint64, sb *sessionBundle) {
+	data, err := encodeBundle(sb.items, sb.size)
+	if err != nil {
+		log.Printf("can't encode assets bundle, sessID: %d, err: %s", sessionID, err)
+		return
+	}
+	if err := b.s3.Upload(bytes.NewReader(data), assets.GetBundlePath(sessionID, sb.part), "application/octet-stream", false); err != nil {
+		log.Printf("can't upload assets bundle, sessID: %d, err: %s", sessionID, err)
+	}
+}
+
+// encodeBundle writes the length of the index, JSON index and data of all items
+func encodeBundle(items []*bundleItem, size int) ([]byte, error) {
+	index, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, 4, 4+len(index)+size)
+	binary.LittleEndian.PutUint32(data, uint32(len(index)))
+	data = append(data, index...)
+	for _, item := range items {
+		data = append(data, item.data...)
+	}
+	return data, nil
+}
+
+// stop uploads all collected bundles
+func (b *bundler) stop() {
+	close(b.done)
+	b.wg.Wait()
+}
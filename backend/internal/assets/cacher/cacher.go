@@ -2,19 +2,18 @@ package cacher
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
 	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
 	"io"
 	"io/ioutil"
 	"log"
-	"mime"
 	"net/http"
+	"net/url"
 	"openreplay/backend/pkg/monitoring"
-	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/go-redis/redis"
 	"github.com/pkg/errors"
 
 	config "openreplay/backend/internal/config/assets"
@@ -22,17 +21,39 @@ import (
 	"openreplay/backend/pkg/url/assets"
 )
 
-const MAX_CACHE_DEPTH = 5
-
 type cacher struct {
-	timeoutMap       *timeoutMap      // Concurrency implemented
-	s3               *storage.S3      // AWS Docs: "These clients are safe to use concurrently."
-	httpClient       *http.Client     // Docs: "Clients are safe for concurrent use by multiple goroutines."
-	rewriter         *assets.Rewriter // Read only
-	Errors           chan error
-	sizeLimit        int
-	downloadedAssets syncfloat64.Counter
-	requestHeaders   map[string]string
+	timeoutMap         *timeoutMap           // Concurrency implemented
+	seenSet            *redisSeenSet         // Optional, shared between instances
+	dedupTTL           time.Duration         // Asset is not fetched again during this time
+	s3                 storage.ObjectStorage // AWS and GCS Docs: clients are safe to use concurrently
+	httpClient         *http.Client          // Docs: "Clients are safe for concurrent use by multiple goroutines."
+	rewriter           *assets.Rewriter      // Read only
+	Errors             chan error
+	sizeLimit          int
+	contentTypes       contentTypes // nil allows any content type
+	downloadedAssets   syncfloat64.Counter
+	droppedTasks       syncfloat64.Counter
+	rateLimited        syncfloat64.Counter
+	limiter            *domainLimiter // Optional, nil if there are no limits
+	delayed            *delayQueue    // Tasks postponed by the limiter or by the full queue
+	policy             *fetchPolicy
+	blockedAssets      syncfloat64.Counter
+	rejectedAssets     syncfloat64.Counter
+	bundler            *bundler // Optional
+	requestHeaders     map[string]string
+	workers            *WorkerPool
+	journal            *taskJournal   // Optional, nil if pending tasks are kept only in memory
+	validators         validatorStore // Optional, nil if revalidation is disabled
+	revalidatedAssets  syncfloat64.Counter
+	evictor            *evictor // Optional
+	sourceMapSizeLimit int      // 0 if source maps are disabled
+	maxDepth           byte     // Nesting level of assets referenced by other assets (CSS imports, fonts, SVG)
+	scanner            scanner  // Optional, assets are uploaded only if they are clean
+	scanFailOpen       bool
+	quarantinePrefix   string
+	scannedAssets      syncfloat64.Counter
+	scanErrors         syncfloat64.Counter
+	quarantinedAssets  syncfloat64.Counter
 }
 
 func NewCacher(cfg *config.Config, metrics *monitoring.Metrics) *cacher {
@@ -44,112 +65,453 @@ func NewCacher(cfg *config.Config, metrics *monitoring.Metrics) *cacher {
 	if err != nil {
 		log.Printf("can't create downloaded_assets metric: %s", err)
 	}
-	return &cacher{
-		timeoutMap: newTimeoutMap(),
-		s3:         storage.NewS3(cfg.AWSRegion, cfg.S3BucketAssets),
+	droppedTasks, err := metrics.RegisterCounter("assets_dropped_tasks")
+	if err != nil {
+		log.Printf("can't create assets_dropped_tasks metric: %s", err)
+	}
+	rateLimited, err := metrics.RegisterCounter("assets_rate_limited")
+	if err != nil {
+		log.Printf("can't create assets_rate_limited metric: %s", err)
+	}
+	blockedAssets, err := metrics.RegisterCounter("assets_blocked")
+	if err != nil {
+		log.Printf("can't create assets_blocked metric: %s", err)
+	}
+	revalidatedAssets, err := metrics.RegisterCounter("assets_revalidated")
+	if err != nil {
+		log.Printf("can't create assets_revalidated metric: %s", err)
+	}
+	rejectedAssets, err := metrics.RegisterCounter("assets_rejected")
+	if err != nil {
+		log.Printf("can't create assets_rejected metric: %s", err)
+	}
+	var limiter *domainLimiter
+	if cfg.AssetsRateLimit > 0 || len(cfg.AssetsDomainRateLimits) > 0 {
+		limiter, err = newDomainLimiter(cfg.AssetsRateLimit, cfg.AssetsRateBurst, cfg.AssetsDomainRateLimits)
+		if err != nil {
+			log.Fatalf("can't init rate limiter: %s", err)
+		}
+	}
+	objStorage, err := storage.NewObjectStorage(cfg.StorageProvider, cfg.AWSRegion, cfg.S3BucketAssets)
+	if err != nil {
+		log.Fatalf("can't init object storage: %s", err)
+	}
+	// Assets are shared by projects, so all of them use the same storage class
+	if objStorage, err = storage.WithStorageClass(objStorage, cfg.AssetsStorageClass); err != nil {
+		log.Fatalf("can't init assets storage class: %s", err)
+	}
+	var seenSet *redisSeenSet
+	if cfg.AssetsDedupRedis {
+		seenSet, err = newRedisSeenSet(cfg.RedisString, cfg.AssetsDedupTTL)
+		if err != nil {
+			log.Fatalf("can't init assets seen set: %s", err)
+		}
+	}
+	policy, err := newFetchPolicy(cfg.AssetsAllowDomains, cfg.AssetsDenyDomains, cfg.AssetsBlockPrivateIPs, nil)
+	if err != nil {
+		log.Fatalf("can't init fetch policy: %s", err)
+	}
+	types, err := newContentTypes(cfg.AssetsContentTypes)
+	if err != nil {
+		log.Fatalf("can't init content types: %s", err)
+	}
+	proxy, err := newProxySelector(cfg.AssetsProxy, cfg.AssetsNoProxy)
+	if err != nil {
+		log.Fatalf("can't init proxy: %s", err)
+	}
+	transport := newTransport(cfg, policy, proxy)
+	if cfg.AssetsRespectRobots {
+		policy.robots = newRobotsCache(&http.Client{
+			Timeout:   cfg.AssetsRequestTimeout,
+			Transport: transport,
+		}, cfg.AssetsRobotsTTL)
+	}
+	c := &cacher{
+		timeoutMap: newTimeoutMap(cfg.AssetsDedupTTL),
+		seenSet:    seenSet,
+		dedupTTL:   cfg.AssetsDedupTTL,
+		s3:         objStorage,
 		httpClient: &http.Client{
-			Timeout: time.Duration(6) * time.Second,
-			Transport: &http.Transport{
-				Proxy:           http.ProxyFromEnvironment,
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			Timeout:   cfg.AssetsRequestTimeout,
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= cfg.AssetsMaxRedirects {
+					return fmt.Errorf("stopped after %d redirects", cfg.AssetsMaxRedirects)
+				}
+				return policy.check(req.Context(), req.URL)
 			},
 		},
 		rewriter:         rewriter,
-		Errors:           make(chan error),
+		Errors:           make(chan error, 100),
 		sizeLimit:        cfg.AssetsSizeLimit,
 		downloadedAssets: downloadedAssets,
+		droppedTasks:     droppedTasks,
+		rateLimited:      rateLimited,
+		limiter:          limiter,
+		policy:           policy,
+		blockedAssets:    blockedAssets,
+		rejectedAssets:   rejectedAssets,
+		contentTypes:     types,
 		requestHeaders:   cfg.AssetsRequestHeaders,
+		maxDepth:         cfg.AssetsMaxDepth,
+	}
+	if cfg.AssetsRevalidate {
+		c.revalidatedAssets = revalidatedAssets
+		if seenSet != nil {
+			c.validators = &redisValidators{client: seenSet.client, ttl: cfg.AssetsValidatorsTTL}
+		} else {
+			c.validators = newMemoryValidators(cfg.AssetsValidatorsTTL)
+		}
+	}
+	if cfg.AssetsSourceMaps {
+		c.sourceMapSizeLimit = cfg.AssetsSourceMapSizeLimit
 	}
+	if cfg.AssetsScanner != "" {
+		if c.scanner, err = newScanner(cfg.AssetsScanner, cfg.AssetsScanTimeout); err != nil {
+			log.Fatalf("can't init assets scanner: %s", err)
+		}
+		c.scanFailOpen = cfg.AssetsScanFailOpen
+		c.quarantinePrefix = cfg.AssetsQuarantinePrefix
+		if c.scannedAssets, err = metrics.RegisterCounter("assets_scanned"); err != nil {
+			log.Printf("can't create assets_scanned metric: %s", err)
+		}
+		if c.scanErrors, err = metrics.RegisterCounter("assets_scan_errors"); err != nil {
+			log.Printf("can't create assets_scan_errors metric: %s", err)
+		}
+		if c.quarantinedAssets, err = metrics.RegisterCounter("assets_quarantined"); err != nil {
+			log.Printf("can't create assets_quarantined metric: %s", err)
+		}
+	}
+	if cfg.AssetsTTL > 0 {
+		// Stored copy must outlive its dedup and validators records, otherwise evicted asset isn't fetched again
+		minTTL := cfg.AssetsDedupTTL
+		if c.validators != nil {
+			minTTL += cfg.AssetsValidatorsTTL
+		}
+		if cfg.AssetsTTL <= minTTL {
+			log.Fatalf("assets ttl must be greater than %s", minTTL)
+		}
+		var lock *redis.Client
+		if seenSet != nil {
+			lock = seenSet.client
+		}
+		c.evictor = newEvictor(objStorage, cfg.AssetsTTL, cfg.AssetsEvictionInterval, lock, metrics)
+	}
+	if cfg.AssetsBundleEnabled {
+		c.bundler = newBundler(objStorage, cfg.AssetsBundleItemLimit, cfg.AssetsBundleSizeLimit,
+			cfg.AssetsBundleCacheSize, cfg.AssetsBundleTimeout)
+	}
+	var restored []*Task
+	if cfg.AssetsQueueDir != "" {
+		journal, tasks, err := openJournal(cfg.AssetsQueueDir, cfg.AssetsQueueSyncInterval, cfg.AssetsQueueCompactSize)
+		if err != nil {
+			log.Fatalf("can't init assets tasks journal: %s", err)
+		}
+		c.journal, restored = journal, tasks
+	}
+	minWorkers := cfg.AssetsMinWorkers
+	if minWorkers <= 0 {
+		minWorkers = cfg.AssetsWorkers
+	}
+	c.workers = NewPool(minWorkers, cfg.AssetsWorkers, cfg.AssetsQueueCapacity, cfg.AssetsSpillLimit,
+		cfg.AssetsWorkersLatency, cfg.AssetsTaskTimeout, OverflowMode(cfg.AssetsOverflowMode), c.runTask, metrics)
+	c.delayed = newDelayQueue(cfg.AssetsDelayedLimit, limiter, c.addTask)
+	if len(restored) > 0 {
+		log.Printf("restored assets tasks: %d", len(restored))
+		// Queue may be smaller than the number of restored tasks
+		go func() {
+			for _, task := range restored {
+				c.enqueue(task)
+			}
+		}()
+	}
+	return c
 }
 
-func (c *cacher) cacheURL(requestURL string, sessionID uint64, depth byte, urlContext string, isJS bool) {
-	var cachePath string
-	if isJS {
-		cachePath = assets.GetCachePathForJS(requestURL)
+// runTask is the job of the worker pool, errors are sent to the Errors channel
+func (c *cacher) runTask(ctx context.Context, t *Task) error {
+	var err error
+	if t.sourceMapOf != "" {
+		err = c.cacheSourceMap(ctx, t)
 	} else {
-		cachePath = assets.GetCachePathForAssets(sessionID, requestURL)
+		err = c.cacheURL(ctx, t)
 	}
-	if c.timeoutMap.contains(cachePath) {
-		return
+	if err != nil {
+		c.sendError(errors.Wrap(err, t.urlContext))
 	}
-	c.timeoutMap.add(cachePath)
-	crTime := c.s3.GetCreationTime(cachePath)
-	if crTime != nil && crTime.After(time.Now().Add(-MAX_STORAGE_TIME)) { // recently uploaded
-		return
+	// Tasks cancelled by the stopped pool stay in the journal and run again after restart
+	if c.journal != nil && ctx.Err() != context.Canceled {
+		c.journal.done(t)
+	}
+	return err
+}
+
+// admit returns false if the asset shouldn't be fetched now: it's a duplicate, it's blocked
+// by the fetch policy (error is returned) or the task is postponed by the rate limiter
+func (c *cacher) admit(ctx context.Context, t *Task) (bool, error) {
+	if !t.checked && !c.isNewAsset(t.cachePath) {
+		if c.bundler != nil && !t.isJS {
+			c.bundler.addCached(t.sessionID, t.cachePath)
+		}
+		return false, nil
 	}
+	if u, err := url.Parse(t.requestURL); err != nil {
+		return false, err
+	} else if err := c.policy.check(ctx, u); err != nil {
+		c.blockedAssets.Add(context.Background(), 1)
+		return false, err
+	}
+	if c.limiter != nil && !t.rateAllowed {
+		if ok, wait := c.limiter.allow(getHost(t.requestURL)); !ok {
+			// Postponed task doesn't occupy the worker, asset is already marked as seen
+			t.checked = true
+			c.rateLimited.Add(context.Background(), 1)
+			if !c.delayed.push(t, wait) {
+				c.droppedTasks.Add(context.Background(), 1)
+				c.forgetAsset(t.cachePath)
+			}
+			return false, nil
+		}
+	}
+	return true, nil
+}
 
-	req, _ := http.NewRequest("GET", requestURL, nil)
+// fetch downloads the asset, response body is already read and closed.
+// Data is nil if the request was conditional and the stored copy is still valid (304)
+// fetch reads the response only if its size and content type are allowed, nil types allow any content type
+func (c *cacher) fetch(ctx context.Context, t *Task, sizeLimit int, types contentTypes) ([]byte, *http.Response, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET", t.requestURL, nil)
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 6.1; rv:31.0) Gecko/20100101 Firefox/31.0")
 	for k, v := range c.requestHeaders {
 		req.Header.Set(k, v)
 	}
+	if c.validators != nil {
+		if v := c.validators.get(t.cachePath); v != nil {
+			v.setHeaders(req)
+		}
+	}
 	res, err := c.httpClient.Do(req)
 	if err != nil {
-		c.Errors <- errors.Wrap(err, urlContext)
-		return
+		return nil, nil, err
 	}
 	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotModified {
+		c.revalidatedAssets.Add(context.Background(), 1)
+		return nil, res, nil
+	}
 	if res.StatusCode >= 400 {
 		// TODO: retry
-		c.Errors <- errors.Wrap(fmt.Errorf("Status code is %v, ", res.StatusCode), urlContext)
-		return
+		return nil, nil, fmt.Errorf("Status code is %v, ", res.StatusCode)
+	}
+	if res.ContentLength > int64(sizeLimit) {
+		c.rejectedAssets.Add(context.Background(), 1)
+		return nil, nil, fmt.Errorf("Maximum size exceeded: %d", res.ContentLength)
 	}
-	data, err := ioutil.ReadAll(io.LimitReader(res.Body, int64(c.sizeLimit+1)))
+	if contentType := assetContentType(res); !types.allowed(contentType) {
+		c.rejectedAssets.Add(context.Background(), 1)
+		return nil, nil, fmt.Errorf("content type %s isn't allowed", contentType)
+	}
+	// Body is closed after the limit, so a chunked response without length isn't downloaded completely
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, int64(sizeLimit+1)))
 	if err != nil {
-		c.Errors <- errors.Wrap(err, urlContext)
-		return
+		return nil, nil, err
 	}
-	if len(data) > c.sizeLimit {
-		c.Errors <- errors.Wrap(errors.New("Maximum size exceeded"), urlContext)
-		return
+	if len(data) > sizeLimit {
+		c.rejectedAssets.Add(context.Background(), 1)
+		return nil, nil, errors.New("Maximum size exceeded")
 	}
+	return data, res, nil
+}
 
-	contentType := res.Header.Get("Content-Type")
-	if contentType == "" {
-		contentType = mime.TypeByExtension(filepath.Ext(res.Request.URL.Path))
+func (c *cacher) cacheURL(ctx context.Context, t *Task) error {
+	if ok, err := c.admit(ctx, t); err != nil {
+		c.forgetAsset(t.cachePath)
+		return err
+	} else if !ok {
+		return nil
+	}
+	// Asset is marked as seen by the deduplication, it's fetched again by the next session if it isn't stored
+	stored := false
+	defer func() {
+		if !stored {
+			c.forgetAsset(t.cachePath)
+		}
+	}()
+
+	data, res, err := c.fetch(ctx, t, c.sizeLimit, c.contentTypes)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		// Stored copy is up to date, nested assets were cached with it
+		stored = true
+		return nil
+	}
+
+	contentType := assetContentType(res)
+	if c.scanner != nil {
+		if err := c.scanAsset(ctx, t, data, contentType); err != nil {
+			return err
+		}
 	}
 	isCSS := strings.HasPrefix(contentType, "text/css")
+	isSVG := strings.HasPrefix(contentType, "image/svg+xml")
 
 	strData := string(data)
-	if isCSS {
-		strData = c.rewriter.RewriteCSS(sessionID, requestURL, strData) // TODO: one method for rewrite and return list
+	switch {
+	case isCSS:
+		strData = c.rewriter.RewriteCSS(t.sessionID, t.requestURL, strData) // TODO: one method for rewrite and return list
+	case isSVG:
+		strData = c.rewriter.RewriteSVG(t.sessionID, t.requestURL, strData)
 	}
 
 	// TODO: implement in streams
-	err = c.s3.Upload(strings.NewReader(strData), cachePath, contentType, false)
+	err = c.s3.Upload(strings.NewReader(strData), t.cachePath, contentType, false)
 	if err != nil {
-		c.Errors <- errors.Wrap(err, urlContext)
-		return
+		return err
 	}
+	stored = true
 	c.downloadedAssets.Add(context.Background(), 1)
+	c.saveValidators(t.cachePath, res.Header)
+	if c.bundler != nil && !t.isJS {
+		c.bundler.add(t.sessionID, t.cachePath, contentType, []byte(strData))
+	}
+	if t.isJS && c.sourceMapSizeLimit > 0 {
+		c.handleSourceMapRef(t, res.Header, data)
+		return nil
+	}
 
-	if isCSS {
-		if depth > 0 {
-			for _, extractedURL := range assets.ExtractURLsFromCSS(string(data)) {
-				if fullURL, cachable := assets.GetFullCachableURL(requestURL, extractedURL); cachable {
-					go c.cacheURL(fullURL, sessionID, depth-1, urlContext+"\n  -> "+fullURL, false)
-				}
-			}
-			if err != nil {
-				c.Errors <- errors.Wrap(err, urlContext)
-				return
+	var nestedURLs []string
+	switch {
+	case isCSS:
+		nestedURLs = assets.ExtractURLsFromCSS(string(data))
+	case isSVG:
+		nestedURLs = assets.ExtractURLsFromSVG(string(data))
+	}
+	if len(nestedURLs) == 0 {
+		return nil
+	}
+	if t.depth >= c.maxDepth {
+		return errors.New("Maximum recursion cache depth exceeded")
+	}
+	for _, extractedURL := range nestedURLs {
+		if fullURL, cachable := assets.GetFullCachableURL(t.requestURL, extractedURL); cachable {
+			task := &Task{
+				requestURL: fullURL,
+				sessionID:  t.sessionID,
+				depth:      t.depth + 1,
+				urlContext: t.urlContext + "\n  -> " + fullURL,
+				cachePath:  assets.GetCachePathForAssets(t.sessionID, fullURL),
 			}
-		} else {
-			c.Errors <- errors.Wrap(errors.New("Maximum recursion cache depth exceeded"), urlContext)
-			return
+			c.addFromWorker(task)
 		}
 	}
-	return
+	return nil
+}
+
+// sendError doesn't block the worker if nobody is reading errors at the moment
+func (c *cacher) sendError(err error) {
+	select {
+	case c.Errors <- err:
+	default:
+		log.Printf("Error while caching: %v", err)
+	}
+}
+
+func (c *cacher) addTask(task *Task) {
+	if c.journal != nil {
+		c.journal.add(task)
+	}
+	c.enqueue(task)
+}
+
+// addFromWorker never blocks the worker, otherwise all workers could wait for a free slot in the block mode.
+// Tasks which don't fit into the queue wait in the delay queue.
+func (c *cacher) addFromWorker(task *Task) {
+	if c.workers.mode != OverflowBlock {
+		c.addTask(task)
+		return
+	}
+	if c.journal != nil {
+		c.journal.add(task)
+	}
+	if c.workers.TryAddTask(task) || c.delayed.push(task, 0) {
+		return
+	}
+	c.droppedTasks.Add(context.Background(), 1)
+	if c.journal != nil {
+		c.journal.done(task)
+	}
+}
+
+// enqueue removes dropped tasks from the journal, tasks skipped by the stopped pool are kept for the next start
+func (c *cacher) enqueue(task *Task) {
+	if c.workers.AddTask(task) {
+		return
+	}
+	c.droppedTasks.Add(context.Background(), 1)
+	if c.journal != nil && !c.workers.stopped() {
+		c.journal.done(task)
+	}
+	// Postponed task was marked as seen before it was dropped
+	if task.checked {
+		c.forgetAsset(task.cachePath)
+	}
 }
 
 func (c *cacher) CacheJSFile(sourceURL string) {
-	go c.cacheURL(sourceURL, 0, 0, sourceURL, true)
+	c.addTask(&Task{
+		requestURL: sourceURL,
+		urlContext: sourceURL,
+		isJS:       true,
+		cachePath:  assets.GetCachePathForJS(sourceURL),
+	})
 }
 
 func (c *cacher) CacheURL(sessionID uint64, fullURL string) {
-	go c.cacheURL(fullURL, sessionID, MAX_CACHE_DEPTH, fullURL, false)
+	c.addTask(&Task{
+		requestURL: fullURL,
+		sessionID:  sessionID,
+		urlContext: fullURL,
+		cachePath:  assets.GetCachePathForAssets(sessionID, fullURL),
+	})
 }
 
 func (c *cacher) UpdateTimeouts() {
 	c.timeoutMap.deleteOutdated()
+	if c.validators != nil {
+		c.validators.deleteOutdated()
+	}
+	if c.limiter != nil {
+		c.limiter.deleteOutdated()
+	}
+	if c.policy.robots != nil {
+		c.policy.robots.deleteOutdated()
+	}
+}
+
+func getHost(requestURL string) string {
+	u, err := url.Parse(requestURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+func (c *cacher) Stop() {
+	// Released tasks need the running pool, pending ones are done in the journal already
+	pending := c.delayed.stop()
+	c.workers.Stop()
+	if c.journal != nil {
+		for _, task := range pending {
+			c.journal.add(task)
+		}
+		c.journal.close()
+	}
+	if c.bundler != nil {
+		c.bundler.stop()
+	}
+	if c.evictor != nil {
+		c.evictor.stop()
+	}
 }
@@ -0,0 +1,72 @@
+package cacher
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Media types which the player uses, applied if the allowlist isn't configured
+var defaultContentTypes = []string{
+	"text/css",
+	"text/javascript",
+	"application/javascript",
+	"application/x-javascript",
+	"application/ecmascript",
+	"image/*",
+	"font/*",
+	"application/font-*",
+	"application/x-font-*",
+	"application/vnd.ms-fontobject",
+}
+
+// contentTypes is an allowlist of media type patterns like "image/*", nil allows everything
+type contentTypes []string
+
+func newContentTypes(patterns []string) (contentTypes, error) {
+	if len(patterns) == 0 {
+		patterns = defaultContentTypes
+	}
+	var types contentTypes
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "*" || pattern == "*/*" {
+			return nil, nil
+		}
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("wrong content type pattern %s: %s", pattern, err)
+		}
+		types = append(types, pattern)
+	}
+	return types, nil
+}
+
+func (ct contentTypes) allowed(contentType string) bool {
+	if ct == nil {
+		return true
+	}
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	for _, pattern := range ct {
+		if ok, _ := path.Match(pattern, mediaType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// assetContentType uses the file extension if the server doesn't send a specific type
+func assetContentType(res *http.Response) string {
+	contentType := res.Header.Get("Content-Type")
+	if contentType == "" || strings.HasPrefix(contentType, "application/octet-stream") {
+		if byExt := mime.TypeByExtension(filepath.Ext(res.Request.URL.Path)); byExt != "" {
+			return byExt
+		}
+	}
+	return contentType
+}
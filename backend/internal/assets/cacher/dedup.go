@@ -0,0 +1,78 @@
+package cacher
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// redisSeenSet shares the list of already cached assets between all cacher instances
+type redisSeenSet struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func newRedisSeenSet(addr string, ttl time.Duration) (*redisSeenSet, error) {
+	if addr == "" {
+		return nil, fmt.Errorf("redis address is empty")
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr: addr,
+	})
+	if _, err := client.Ping().Result(); err != nil {
+		return nil, fmt.Errorf("can't connect to redis: %s", err)
+	}
+	return &redisSeenSet{
+		client: client,
+		ttl:    ttl,
+	}, nil
+}
+
+func seenKey(key string) string {
+	hash := sha1.Sum([]byte(key))
+	return "assets:seen:" + hex.EncodeToString(hash[:])
+}
+
+// addIfNotExists returns false if the key was added by any cacher instance during the last ttl
+func (r *redisSeenSet) addIfNotExists(key string) (bool, error) {
+	return r.client.SetNX(seenKey(key), 1, r.ttl).Result()
+}
+
+func (r *redisSeenSet) delete(key string) error {
+	return r.client.Del(seenKey(key)).Err()
+}
+
+// isNewAsset checks local and shared seen sets, the asset should be fetched only if it's new for both of them.
+// The asset is marked as seen by the check, so other sessions don't fetch it at the same time,
+// forgetAsset has to be called if the asset isn't stored in the end.
+func (c *cacher) isNewAsset(cachePath string) bool {
+	if !c.timeoutMap.addIfNotExists(cachePath) {
+		return false
+	}
+	if c.seenSet == nil {
+		// Without the shared set the asset could be uploaded recently by another instance
+		crTime := c.s3.GetCreationTime(cachePath)
+		return crTime == nil || crTime.Before(time.Now().Add(-c.dedupTTL))
+	}
+	isNew, err := c.seenSet.addIfNotExists(cachePath)
+	if err != nil {
+		log.Printf("can't check asset in redis: %s", err)
+		return true
+	}
+	return isNew
+}
+
+// forgetAsset removes the asset from the seen sets, so the next session fetches it again
+func (c *cacher) forgetAsset(cachePath string) {
+	c.timeoutMap.delete(cachePath)
+	if c.seenSet == nil {
+		return
+	}
+	if err := c.seenSet.delete(cachePath); err != nil {
+		log.Printf("can't delete asset from redis: %s", err)
+	}
+}
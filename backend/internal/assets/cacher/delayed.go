@@ -0,0 +1,166 @@
+package cacher
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Random time added to the first wait of the domain, so domains limited at the same moment don't return at once
+const maxDelayJitter = 100 * time.Millisecond
+
+type delayedDomain struct {
+	tasks   []*Task
+	readyAt time.Time
+}
+
+// delayQueue keeps tasks postponed by the rate limiter until their domain gets a token, and tasks of workers
+// which didn't fit into the full queue of the block mode. Tasks are released one by one by a single goroutine,
+// so a limited domain doesn't flood the pool when its wait is over.
+type delayQueue struct {
+	mu      sync.Mutex
+	limit   int
+	size    int
+	domains map[string]*delayedDomain
+	rnd     *rand.Rand
+	limiter *domainLimiter   // nil if tasks are postponed only because the pool is full
+	release func(task *Task) // goes through the pool overflow mode, may block
+	wake    chan struct{}
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newDelayQueue(limit int, limiter *domainLimiter, release func(task *Task)) *delayQueue {
+	if limit <= 0 {
+		limit = 1
+	}
+	q := &delayQueue{
+		limit:   limit,
+		domains: make(map[string]*delayedDomain),
+		rnd:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		limiter: limiter,
+		release: release,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	q.wg.Add(1)
+	go q.run()
+	return q
+}
+
+// push returns false if the queue is full or stopped
+func (q *delayQueue) push(task *Task, wait time.Duration) bool {
+	host := getHost(task.requestURL)
+	q.mu.Lock()
+	if q.size >= q.limit || q.stopped() {
+		q.mu.Unlock()
+		return false
+	}
+	d, ok := q.domains[host]
+	if !ok {
+		d = &delayedDomain{readyAt: time.Now().Add(wait + q.jitter())}
+		q.domains[host] = d
+	}
+	d.tasks = append(d.tasks, task)
+	q.size++
+	q.mu.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+func (q *delayQueue) run() {
+	defer q.wg.Done()
+	for !q.stopped() {
+		ready, next := q.takeReady()
+		for _, task := range ready {
+			q.release(task)
+		}
+		if len(ready) > 0 {
+			continue
+		}
+		var timer *time.Timer
+		var tick <-chan time.Time
+		if !next.IsZero() {
+			timer = time.NewTimer(time.Until(next))
+			tick = timer.C
+		}
+		select {
+		case <-q.done:
+		case <-q.wake:
+		case <-tick:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// takeReady returns tasks which got tokens of their domains and the time of the next ready domain
+func (q *delayQueue) takeReady() ([]*Task, time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var ready []*Task
+	var next time.Time
+	now := time.Now()
+	for host, d := range q.domains {
+		for len(d.tasks) > 0 && !d.readyAt.After(now) {
+			if q.limiter != nil {
+				if ok, wait := q.limiter.allow(host); !ok {
+					d.readyAt = now.Add(wait)
+					break
+				}
+			}
+			task := d.tasks[0]
+			d.tasks[0] = nil
+			d.tasks = d.tasks[1:]
+			q.size--
+			// Token is already taken for the task
+			task.rateAllowed = true
+			ready = append(ready, task)
+		}
+		if len(d.tasks) == 0 {
+			delete(q.domains, host)
+			continue
+		}
+		if next.IsZero() || d.readyAt.Before(next) {
+			next = d.readyAt
+		}
+	}
+	return ready, next
+}
+
+func (q *delayQueue) jitter() time.Duration {
+	return time.Duration(q.rnd.Int63n(int64(maxDelayJitter)))
+}
+
+func (q *delayQueue) stopped() bool {
+	select {
+	case <-q.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// stop waits for the released tasks and returns tasks which are still waiting
+func (q *delayQueue) stop() []*Task {
+	q.mu.Lock()
+	if !q.stopped() {
+		close(q.done)
+	}
+	q.mu.Unlock()
+	q.wg.Wait()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var pending []*Task
+	for host, d := range q.domains {
+		pending = append(pending, d.tasks...)
+		delete(q.domains, host)
+	}
+	q.size = 0
+	return pending
+}
@@ -0,0 +1,135 @@
+package cacher
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+
+	"openreplay/backend/pkg/monitoring"
+	"openreplay/backend/pkg/storage"
+)
+
+const (
+	evictionLockKey   = "assets:eviction"
+	evictionBatchSize = 1000
+)
+
+// evictor deletes cached assets (as well as bundles and source maps) which weren't uploaded during ttl.
+// Revalidated assets aren't uploaded again, but their validators expire earlier than ttl,
+// so frequently used assets are re-uploaded before eviction
+type evictor struct {
+	s3       storage.ObjectStorage
+	ttl      time.Duration
+	interval time.Duration
+	lock     *redis.Client // Optional, only one instance runs eviction during the interval
+	evicted  syncfloat64.Counter
+	bytes    syncfloat64.Counter
+	done     chan struct{}
+}
+
+func newEvictor(s3 storage.ObjectStorage, ttl, interval time.Duration, lock *redis.Client, metrics *monitoring.Metrics) *evictor {
+	evicted, err := metrics.RegisterCounter("assets_evicted")
+	if err != nil {
+		log.Printf("can't create assets_evicted metric: %s", err)
+	}
+	evictedBytes, err := metrics.RegisterCounter("assets_evicted_bytes")
+	if err != nil {
+		log.Printf("can't create assets_evicted_bytes metric: %s", err)
+	}
+	if interval <= 0 {
+		log.Printf("wrong eviction interval: %s, using %s", interval, 24*time.Hour)
+		interval = 24 * time.Hour
+	}
+	e := &evictor{
+		s3:       s3,
+		ttl:      ttl,
+		interval: interval,
+		lock:     lock,
+		evicted:  evicted,
+		bytes:    evictedBytes,
+		done:     make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+func (e *evictor) run() {
+	tick := time.NewTicker(e.interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-tick.C:
+			if !e.acquire() {
+				continue
+			}
+			start := time.Now()
+			count, size, err := e.evict()
+			if err != nil {
+				log.Printf("can't evict cached assets: %s", err)
+			}
+			log.Printf("evicted assets: %d, bytes: %d, duration: %s", count, size, time.Since(start))
+		}
+	}
+}
+
+// acquire returns false if another instance has already run eviction during the interval
+func (e *evictor) acquire() bool {
+	if e.lock == nil {
+		return true
+	}
+	ok, err := e.lock.SetNX(evictionLockKey, 1, e.interval-e.interval/10).Result()
+	if err != nil {
+		log.Printf("can't acquire eviction lock: %s", err)
+		return false
+	}
+	return ok
+}
+
+// evict lists the whole bucket and deletes outdated objects in batches
+func (e *evictor) evict() (int, int64, error) {
+	var (
+		count int
+		size  int64
+		batch []string
+		sizes int64
+	)
+	deadline := time.Now().Add(-e.ttl)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := e.s3.Delete(batch); err != nil {
+			return err
+		}
+		count += len(batch)
+		size += sizes
+		e.evicted.Add(context.Background(), float64(len(batch)))
+		e.bytes.Add(context.Background(), float64(sizes))
+		batch, sizes = batch[:0], 0
+		return nil
+	}
+	err := e.s3.Walk("", func(obj *storage.ObjectInfo) error {
+		if obj.LastModified.After(deadline) {
+			return nil
+		}
+		batch = append(batch, obj.Key)
+		sizes += obj.Size
+		if len(batch) < evictionBatchSize {
+			return nil
+		}
+		return flush()
+	})
+	if err != nil {
+		return count, size, err
+	}
+	return count, size, flush()
+}
+
+func (e *evictor) stop() {
+	close(e.done)
+}
@@ -0,0 +1,278 @@
+package cacher
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const journalFile = "tasks.log"
+
+// journalRecord is one line of the journal, add records contain the whole task
+type journalRecord struct {
+	Op   string      `json:"op"` // add or done
+	ID   uint64      `json:"id"`
+	Task *taskRecord `json:"task,omitempty"`
+}
+
+type taskRecord struct {
+	URL         string `json:"url"`
+	SessionID   uint64 `json:"sessionId,omitempty"`
+	Depth       byte   `json:"depth,omitempty"`
+	URLContext  string `json:"urlContext"`
+	IsJS        bool   `json:"isJS,omitempty"`
+	CachePath   string `json:"cachePath"`
+	SourceMapOf string `json:"sourceMapOf,omitempty"`
+}
+
+type journalEntry struct {
+	task *taskRecord
+	refs int // postponed task is added again before its first run is done
+}
+
+// taskJournal keeps pending tasks in an append-only file, so tasks queued or running during a restart
+// or a crash are restored on start. The file is synced periodically, tasks added during the last
+// sync interval before a crash are lost. The file is rewritten with pending tasks only when it grows too big.
+type taskJournal struct {
+	mu          sync.Mutex
+	path        string
+	file        *os.File
+	writer      *bufio.Writer
+	size        int64
+	compactSize int64
+	lastID      uint64
+	pending     map[uint64]*journalEntry
+	quit        chan struct{}
+	stopped     chan struct{}
+}
+
+// openJournal returns the journal and the tasks which weren't finished by the previous run
+func openJournal(dir string, syncInterval time.Duration, compactSize int64) (*taskJournal, []*Task, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, nil, err
+	}
+	if syncInterval <= 0 {
+		log.Printf("wrong journal sync interval: %s, using %s", syncInterval, time.Second)
+		syncInterval = time.Second
+	}
+	j := &taskJournal{
+		path:        filepath.Join(dir, journalFile),
+		compactSize: compactSize,
+		pending:     make(map[uint64]*journalEntry),
+		quit:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+	if err := j.load(); err != nil {
+		return nil, nil, fmt.Errorf("can't load journal: %s", err)
+	}
+	// Every restored task is queued once
+	for _, entry := range j.pending {
+		entry.refs = 1
+	}
+	if err := j.compact(); err != nil {
+		return nil, nil, fmt.Errorf("can't compact journal: %s", err)
+	}
+
+	ids := make([]uint64, 0, len(j.pending))
+	for id := range j.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, k int) bool { return ids[i] < ids[k] })
+	tasks := make([]*Task, 0, len(ids))
+	for _, id := range ids {
+		rec := j.pending[id].task
+		tasks = append(tasks, &Task{
+			requestURL:  rec.URL,
+			sessionID:   rec.SessionID,
+			depth:       rec.Depth,
+			urlContext:  rec.URLContext,
+			isJS:        rec.IsJS,
+			cachePath:   rec.CachePath,
+			checked:     true, // task could be marked as seen by deduplication before the crash
+			sourceMapOf: rec.SourceMapOf,
+			journalID:   id,
+		})
+	}
+	go j.run(syncInterval)
+	return j, tasks, nil
+}
+
+// load replays the journal, the last line can be incomplete after a crash
+func (j *taskJournal) load() error {
+	file, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		rec := &journalRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), rec); err != nil {
+			log.Printf("skipped broken journal record: %s", err)
+			continue
+		}
+		if rec.ID > j.lastID {
+			j.lastID = rec.ID
+		}
+		j.apply(rec)
+	}
+	return scanner.Err()
+}
+
+func (j *taskJournal) apply(rec *journalRecord) {
+	switch rec.Op {
+	case "add":
+		if entry, ok := j.pending[rec.ID]; ok {
+			entry.refs++
+		} else if rec.Task != nil {
+			j.pending[rec.ID] = &journalEntry{task: rec.Task, refs: 1}
+		}
+	case "done":
+		if entry, ok := j.pending[rec.ID]; ok {
+			if entry.refs--; entry.refs <= 0 {
+				delete(j.pending, rec.ID)
+			}
+		}
+	}
+}
+
+// compact rewrites the journal with pending tasks only, the new file replaces the old one atomically
+func (j *taskJournal) compact() error {
+	tmpPath := j.path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	size := int64(0)
+	for id, entry := range j.pending {
+		for i := 0; i < entry.refs; i++ {
+			n, err := writeRecord(writer, &journalRecord{Op: "add", ID: id, Task: entry.task})
+			if err != nil {
+				file.Close()
+				return err
+			}
+			size += int64(n)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := os.Rename(tmpPath, j.path); err != nil {
+		file.Close()
+		return err
+	}
+	// Old file is closed only after it is replaced, its buffered records are in the new file already
+	if j.file != nil {
+		j.file.Close()
+	}
+	j.file, j.writer, j.size = file, writer, size
+	return nil
+}
+
+func writeRecord(w *bufio.Writer, rec *journalRecord) (int, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return 0, err
+	}
+	data = append(data, '\n')
+	return w.Write(data)
+}
+
+func (j *taskJournal) write(rec *journalRecord) {
+	n, err := writeRecord(j.writer, rec)
+	if err != nil {
+		log.Printf("can't write journal record: %s", err)
+	}
+	j.size += int64(n)
+}
+
+// add saves the task before it's queued, the task added again keeps its id
+func (j *taskJournal) add(t *Task) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if t.journalID == 0 {
+		j.lastID++
+		t.journalID = j.lastID
+	}
+	rec := &journalRecord{Op: "add", ID: t.journalID, Task: &taskRecord{
+		URL:         t.requestURL,
+		SessionID:   t.sessionID,
+		Depth:       t.depth,
+		URLContext:  t.urlContext,
+		IsJS:        t.isJS,
+		CachePath:   t.cachePath,
+		SourceMapOf: t.sourceMapOf,
+	}}
+	j.apply(rec)
+	j.write(rec)
+}
+
+// done removes the finished or dropped task
+func (j *taskJournal) done(t *Task) {
+	if t.journalID == 0 {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	rec := &journalRecord{Op: "done", ID: t.journalID}
+	j.apply(rec)
+	j.write(rec)
+}
+
+func (j *taskJournal) run(syncInterval time.Duration) {
+	defer close(j.stopped)
+	tick := time.NewTicker(syncInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-j.quit:
+			return
+		case <-tick.C:
+			if err := j.sync(); err != nil {
+				log.Printf("can't sync journal: %s", err)
+			}
+		}
+	}
+}
+
+func (j *taskJournal) sync() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.compactSize > 0 && j.size > j.compactSize {
+		return j.compact()
+	}
+	if err := j.writer.Flush(); err != nil {
+		return err
+	}
+	return j.file.Sync()
+}
+
+func (j *taskJournal) close() {
+	close(j.quit)
+	<-j.stopped
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.writer.Flush(); err != nil {
+		log.Printf("can't flush journal: %s", err)
+	}
+	if err := j.file.Sync(); err != nil {
+		log.Printf("can't sync journal: %s", err)
+	}
+	j.file.Close()
+}
@@ -0,0 +1,108 @@
+package cacher
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bucket of a domain without requests during this time is removed
+const bucketIdleTimeout = 10 * time.Minute
+
+type tokenBucket struct {
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// domainLimiter keeps a token bucket per target host, so one slow or strict origin
+// doesn't get all requests of the cacher at once
+type domainLimiter struct {
+	mu        sync.Mutex
+	rate      float64            // default limit, 0 means unlimited
+	burst     float64            // bucket size for all domains
+	overrides map[string]float64 // domain -> limit, applied to subdomains as well
+	buckets   map[string]*tokenBucket
+}
+
+func newDomainLimiter(rate float64, burst int, overrides map[string]string) (*domainLimiter, error) {
+	if rate < 0 {
+		return nil, fmt.Errorf("wrong rate limit: %f", rate)
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	l := &domainLimiter{
+		rate:      rate,
+		burst:     float64(burst),
+		overrides: make(map[string]float64, len(overrides)),
+		buckets:   make(map[string]*tokenBucket),
+	}
+	for domain, value := range overrides {
+		limit, err := strconv.ParseFloat(value, 64)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("wrong rate limit for domain %s: %s", domain, value)
+		}
+		l.overrides[strings.ToLower(domain)] = limit
+	}
+	return l, nil
+}
+
+// limitFor returns limit of the host or of the closest parent domain with override
+func (l *domainLimiter) limitFor(host string) float64 {
+	for domain := host; domain != ""; {
+		if limit, ok := l.overrides[domain]; ok {
+			return limit
+		}
+		dot := strings.IndexByte(domain, '.')
+		if dot < 0 {
+			break
+		}
+		domain = domain[dot+1:]
+	}
+	return l.rate
+}
+
+// allow takes a token for the host, otherwise returns time until the next token
+func (l *domainLimiter) allow(host string) (bool, time.Duration) {
+	host = strings.ToLower(host)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	bucket, ok := l.buckets[host]
+	if !ok {
+		rate := l.limitFor(host)
+		if rate == 0 {
+			return true, 0
+		}
+		bucket = &tokenBucket{rate: rate, burst: l.burst, tokens: l.burst, last: now}
+		l.buckets[host] = bucket
+	}
+	bucket.refill(now)
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - bucket.tokens) / bucket.rate * float64(time.Second))
+}
+
+func (l *domainLimiter) deleteOutdated() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	for host, bucket := range l.buckets {
+		if now.Sub(bucket.last) > bucketIdleTimeout {
+			delete(l.buckets, host)
+		}
+	}
+}
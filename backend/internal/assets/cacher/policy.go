@@ -0,0 +1,115 @@
+package cacher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+)
+
+// Hosts of cloud metadata services, they are never fetched regardless of the config
+var defaultDenyDomains = []string{
+	"169.254.169.254",
+	"metadata",
+	"metadata.google.internal",
+	"metadata.azure.com",
+	"localhost",
+	"*.localhost",
+	"*.internal",
+}
+
+var errPrivateAddress = errors.New("private network address")
+
+// fetchPolicy decides whether the cacher is allowed to fetch the asset
+type fetchPolicy struct {
+	allow        []string // host patterns, empty list allows everything that isn't denied
+	deny         []string
+	blockPrivate bool
+	robots       *robotsCache // optional
+}
+
+func newFetchPolicy(allow, deny []string, blockPrivate bool, robots *robotsCache) (*fetchPolicy, error) {
+	p := &fetchPolicy{
+		blockPrivate: blockPrivate,
+		robots:       robots,
+	}
+	for _, pattern := range allow {
+		if pattern = normalizePattern(pattern); pattern != "" {
+			p.allow = append(p.allow, pattern)
+		}
+	}
+	for _, pattern := range append(deny, defaultDenyDomains...) {
+		if pattern = normalizePattern(pattern); pattern != "" {
+			p.deny = append(p.deny, pattern)
+		}
+	}
+	for _, pattern := range append(p.allow, p.deny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("wrong domain pattern %s: %s", pattern, err)
+		}
+	}
+	return p, nil
+}
+
+func normalizePattern(pattern string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(pattern)), ".")
+}
+
+// matchDomain checks the host against patterns like "example.com" or "*.example.com"
+func matchDomain(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// check is called before each request, including redirects
+func (p *fetchPolicy) check(ctx context.Context, u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("scheme %s isn't allowed", u.Scheme)
+	}
+	host := normalizePattern(u.Hostname())
+	if host == "" {
+		return errors.New("host is empty")
+	}
+	if matchDomain(p.deny, host) {
+		return fmt.Errorf("domain %s is denied", host)
+	}
+	if len(p.allow) > 0 && !matchDomain(p.allow, host) {
+		return fmt.Errorf("domain %s isn't allowed", host)
+	}
+	if ip := net.ParseIP(host); ip != nil && p.blockPrivate && isPrivateIP(ip) {
+		return errPrivateAddress
+	}
+	if p.robots != nil && !p.robots.allowed(ctx, u) {
+		return fmt.Errorf("disallowed by robots.txt")
+	}
+	return nil
+}
+
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast()
+}
+
+// dialControl checks resolved addresses, so a public domain pointing to the internal network
+// is blocked as well (including DNS rebinding between the check and the connection)
+func (p *fetchPolicy) dialControl(network, address string, _ syscall.RawConn) error {
+	if !p.blockPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || isPrivateIP(ip) {
+		return errPrivateAddress
+	}
+	return nil
+}
@@ -0,0 +1,339 @@
+package cacher
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+
+	"openreplay/backend/pkg/monitoring"
+)
+
+type Task struct {
+	requestURL  string
+	sessionID   uint64
+	depth       byte // 0 for assets referenced by the page, increases for each nested CSS import, font or SVG
+	urlContext  string
+	isJS        bool
+	cachePath   string
+	checked     bool   // deduplication is done, task was postponed by rate limiter
+	rateAllowed bool   // token of the domain is taken by the delay queue
+	sourceMapOf string // URL of the JS file, set for source map tasks only
+	queuedAt    time.Time
+	journalID   uint64 // 0 if the durable queue is disabled
+}
+
+// isPriority returns true for assets referenced by the page itself, they block replay rendering
+func (t *Task) isPriority() bool {
+	return !t.isJS && t.depth == 0
+}
+
+// Job gets the context of the pool, it's cancelled after the task timeout or when the pool is stopped.
+// Returned error is counted in the pool metrics only, the job reports it itself.
+type Job func(ctx context.Context, task *Task) error
+
+// OverflowMode defines AddTask behaviour when the queue is full
+type OverflowMode string
+
+const (
+	OverflowBlock OverflowMode = "block" // wait for a free slot
+	OverflowDrop  OverflowMode = "drop"  // skip the task, assets of the page wait for a free slot
+	OverflowSpill OverflowMode = "spill" // keep the task in the in-memory spill list until the queue has a free slot, like drop if the list is full
+)
+
+type WorkerPool struct {
+	high          chan *Task
+	low           chan *Task
+	wg            sync.WaitGroup
+	done          chan struct{}
+	term          sync.Once
+	ctx           context.Context
+	cancel        context.CancelFunc
+	taskTimeout   time.Duration // 0 means no limit
+	minSize       int
+	maxSize       int
+	targetLatency time.Duration // queue wait time which makes the pool grow
+	workers       int32         // current number of workers
+	busy          int32
+	maxWait       int64         // the longest queue wait time since the last scaling check, ns
+	quit          chan struct{} // stops one idle worker
+	job           Job
+	mode          OverflowMode
+	spill         []*Task
+	spillMu       sync.Mutex
+	spillLimit    int
+	spillSig      chan struct{}
+	queueDepth    syncfloat64.UpDownCounter // tasks in the queues and the spill list
+	processed     syncfloat64.Counter
+	failed        syncfloat64.Counter
+	taskDuration  syncfloat64.Histogram
+}
+
+// NewPool creates a pool with minSize workers, it grows up to maxSize workers under load
+// and shrinks back when workers are idle. Pool size is fixed if minSize equals maxSize.
+func NewPool(minSize, maxSize, capacity, spillLimit int, targetLatency, taskTimeout time.Duration, mode OverflowMode, job Job, metrics *monitoring.Metrics) *WorkerPool {
+	switch mode {
+	case OverflowBlock, OverflowDrop, OverflowSpill:
+	default:
+		log.Printf("unknown overflow mode: %s, using %s", mode, OverflowBlock)
+		mode = OverflowBlock
+	}
+	if minSize <= 0 {
+		minSize = 1
+	}
+	if maxSize < minSize {
+		maxSize = minSize
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	newPool := &WorkerPool{
+		high:          make(chan *Task, capacity),
+		low:           make(chan *Task, capacity),
+		done:          make(chan struct{}),
+		ctx:           ctx,
+		cancel:        cancel,
+		taskTimeout:   taskTimeout,
+		minSize:       minSize,
+		maxSize:       maxSize,
+		targetLatency: targetLatency,
+		quit:          make(chan struct{}),
+		job:           job,
+		mode:          mode,
+		spillLimit:    spillLimit,
+		spillSig:      make(chan struct{}, 1),
+	}
+	var err error
+	if newPool.queueDepth, err = metrics.RegisterUpDownCounter("assets_pool_queue_depth"); err != nil {
+		log.Printf("can't create assets_pool_queue_depth metric: %s", err)
+	}
+	if newPool.processed, err = metrics.RegisterCounter("assets_pool_tasks_processed"); err != nil {
+		log.Printf("can't create assets_pool_tasks_processed metric: %s", err)
+	}
+	if newPool.failed, err = metrics.RegisterCounter("assets_pool_tasks_failed"); err != nil {
+		log.Printf("can't create assets_pool_tasks_failed metric: %s", err)
+	}
+	if newPool.taskDuration, err = metrics.RegisterHistogram("assets_pool_task_duration"); err != nil {
+		log.Printf("can't create assets_pool_task_duration metric: %s", err)
+	}
+	newPool.init()
+	return newPool
+}
+
+func (p *WorkerPool) init() {
+	p.startWorkers(p.minSize)
+	if p.mode == OverflowSpill {
+		p.wg.Add(1)
+		go p.spiller()
+	}
+	if p.maxSize > p.minSize {
+		p.wg.Add(1)
+		go p.scaler()
+	}
+}
+
+func (p *WorkerPool) startWorkers(n int) {
+	p.wg.Add(n)
+	atomic.AddInt32(&p.workers, int32(n))
+	for i := 0; i < n; i++ {
+		go p.worker()
+	}
+}
+
+// worker takes low priority tasks only if there are no high priority tasks in the queue
+func (p *WorkerPool) worker() {
+	defer p.wg.Done()
+	defer atomic.AddInt32(&p.workers, -1)
+	for {
+		select {
+		case <-p.done:
+			return
+		case task := <-p.high:
+			p.run(task)
+			continue
+		default:
+		}
+		select {
+		case <-p.done:
+			return
+		case <-p.quit:
+			return
+		case task := <-p.high:
+			p.run(task)
+		case task := <-p.low:
+			p.run(task)
+		}
+	}
+}
+
+func (p *WorkerPool) run(task *Task) {
+	p.queueDepth.Add(context.Background(), -1)
+	wait := int64(time.Since(task.queuedAt))
+	for {
+		maxWait := atomic.LoadInt64(&p.maxWait)
+		if wait <= maxWait || atomic.CompareAndSwapInt64(&p.maxWait, maxWait, wait) {
+			break
+		}
+	}
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if p.taskTimeout > 0 {
+		ctx, cancel = context.WithTimeout(p.ctx, p.taskTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(p.ctx)
+	}
+	atomic.AddInt32(&p.busy, 1)
+	start := time.Now()
+	err := p.job(ctx, task)
+	p.taskDuration.Record(context.Background(), float64(time.Since(start).Milliseconds()))
+	atomic.AddInt32(&p.busy, -1)
+	p.processed.Add(context.Background(), 1)
+	if err != nil {
+		reason := "error"
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			reason = "timeout"
+		case context.Canceled:
+			reason = "cancelled"
+		}
+		p.failed.Add(context.Background(), 1, attribute.String("reason", reason))
+	}
+	cancel()
+}
+
+// spiller moves spilled tasks back to the low priority queue
+func (p *WorkerPool) spiller() {
+	defer p.wg.Done()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-p.spillSig:
+		}
+		for task := p.popSpilled(); task != nil; task = p.popSpilled() {
+			select {
+			case p.low <- task:
+			case <-p.done:
+				return
+			}
+		}
+	}
+}
+
+func (p *WorkerPool) popSpilled() *Task {
+	p.spillMu.Lock()
+	defer p.spillMu.Unlock()
+	if len(p.spill) == 0 {
+		return nil
+	}
+	task := p.spill[0]
+	p.spill[0] = nil
+	p.spill = p.spill[1:]
+	return task
+}
+
+func (p *WorkerPool) pushSpilled(task *Task) bool {
+	p.spillMu.Lock()
+	if len(p.spill) >= p.spillLimit {
+		p.spillMu.Unlock()
+		return false
+	}
+	p.spill = append(p.spill, task)
+	p.spillMu.Unlock()
+	select {
+	case p.spillSig <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// AddTask returns false if the task was dropped
+func (p *WorkerPool) AddTask(task *Task) bool {
+	p.queueDepth.Add(context.Background(), 1)
+	if !p.enqueue(task) {
+		p.queueDepth.Add(context.Background(), -1)
+		return false
+	}
+	return true
+}
+
+// TryAddTask never waits for a free slot, returns false if the queue is full
+func (p *WorkerPool) TryAddTask(task *Task) bool {
+	task.queuedAt = time.Now()
+	lane := p.low
+	if task.isPriority() {
+		lane = p.high
+	}
+	select {
+	case lane <- task:
+		p.queueDepth.Add(context.Background(), 1)
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *WorkerPool) enqueue(task *Task) bool {
+	task.queuedAt = time.Now()
+	if p.mode == OverflowBlock {
+		lane := p.low
+		if task.isPriority() {
+			lane = p.high
+		}
+		select {
+		case lane <- task:
+			return true
+		case <-p.done:
+			return false
+		}
+	}
+	// High priority task goes to the low priority queue if there is no space for it
+	if task.isPriority() {
+		select {
+		case p.high <- task:
+			return true
+		default:
+		}
+	}
+	select {
+	case p.low <- task:
+		return true
+	default:
+	}
+	if p.mode == OverflowSpill && p.pushSpilled(task) {
+		return true
+	}
+	if !task.isPriority() {
+		return false
+	}
+	// Assets of the page are never dropped, the caller waits for a free slot. They are added
+	// by the consumer and the delay queue only, so workers can't wait for themselves.
+	select {
+	case p.high <- task:
+		return true
+	case p.low <- task:
+		return true
+	case <-p.done:
+		return false
+	}
+}
+
+func (p *WorkerPool) stopped() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Stop cancels in-flight tasks and waits for workers to finish
+func (p *WorkerPool) Stop() {
+	p.term.Do(func() {
+		close(p.done)
+		p.cancel()
+	})
+	p.wg.Wait()
+}
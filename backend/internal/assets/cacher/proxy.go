@@ -0,0 +1,77 @@
+package cacher
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+var proxyDefaultPorts = map[string]string{
+	"http":   "80",
+	"https":  "443",
+	"socks5": "1080",
+}
+
+// proxySelector chooses the outbound proxy of the request. The configured proxy overrides
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables, hosts matching bypass patterns
+// are always fetched directly.
+type proxySelector struct {
+	proxy  *url.URL // nil means the proxy from environment
+	bypass []string
+	addrs  sync.Map // addresses of used proxies, map[string]bool
+}
+
+func newProxySelector(proxy string, bypass []string) (*proxySelector, error) {
+	p := &proxySelector{}
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("wrong proxy url: %s", err)
+		}
+		if _, ok := proxyDefaultPorts[u.Scheme]; !ok {
+			return nil, fmt.Errorf("proxy scheme %s isn't supported", u.Scheme)
+		}
+		if u.Hostname() == "" {
+			return nil, fmt.Errorf("proxy host is empty")
+		}
+		p.proxy = u
+	}
+	for _, pattern := range bypass {
+		if pattern = normalizePattern(pattern); pattern != "" {
+			p.bypass = append(p.bypass, pattern)
+		}
+	}
+	return p, nil
+}
+
+// proxyURL is used as Proxy function of the transport, nil url means direct connection
+func (p *proxySelector) proxyURL(req *http.Request) (*url.URL, error) {
+	if matchDomain(p.bypass, normalizePattern(req.URL.Hostname())) {
+		return nil, nil
+	}
+	proxy := p.proxy
+	if proxy == nil {
+		var err error
+		if proxy, err = http.ProxyFromEnvironment(req); err != nil || proxy == nil {
+			return proxy, err
+		}
+	}
+	p.addrs.Store(proxyAddr(proxy), true)
+	return proxy, nil
+}
+
+// isProxy checks the address passed to the dialer, the transport dials the proxy with its host from the url
+func (p *proxySelector) isProxy(addr string) bool {
+	_, ok := p.addrs.Load(addr)
+	return ok
+}
+
+func proxyAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = proxyDefaultPorts[u.Scheme]
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
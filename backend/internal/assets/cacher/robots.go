@@ -0,0 +1,167 @@
+package cacher
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	robotsUserAgent = "openreplay"
+	robotsSizeLimit = 512 * 1024
+)
+
+type robotsRule struct {
+	pattern *regexp.Regexp
+	length  int // length of the original pattern, the longest matching rule wins
+	allow   bool
+}
+
+// newRobotsRule supports "*" wildcard and "$" end anchor
+func newRobotsRule(pattern string, allow bool) robotsRule {
+	expr := regexp.QuoteMeta(strings.TrimSuffix(pattern, "$"))
+	expr = "^" + strings.ReplaceAll(expr, `\*`, ".*")
+	if strings.HasSuffix(pattern, "$") {
+		expr += "$"
+	}
+	return robotsRule{
+		pattern: regexp.MustCompile(expr),
+		length:  len(pattern),
+		allow:   allow,
+	}
+}
+
+// robotsRules is a group of rules for our user agent (or for "*" if there is no specific group)
+type robotsRules struct {
+	rules     []robotsRule
+	fetchedAt time.Time
+}
+
+// allowed applies the longest matching rule, allow wins if lengths are equal
+func (r *robotsRules) allowed(path string) bool {
+	best, allowed := -1, true
+	for _, rule := range r.rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > best || (rule.length == best && rule.allow) {
+			best, allowed = rule.length, rule.allow
+		}
+	}
+	return allowed
+}
+
+func parseRobots(body io.Reader) []robotsRule {
+	var (
+		specific, common   []robotsRule
+		agents             []string
+		inRules            bool
+		hasSpecificSection bool
+	)
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.IndexByte(line, '#'); idx >= 0 {
+			line = line[:idx]
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			if inRules {
+				agents, inRules = nil, false
+			}
+			agents = append(agents, strings.ToLower(value))
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				continue // empty disallow allows everything
+			}
+			rule := newRobotsRule(value, key == "allow")
+			for _, agent := range agents {
+				switch {
+				case agent == "*":
+					common = append(common, rule)
+				case strings.HasPrefix(agent, robotsUserAgent):
+					specific = append(specific, rule)
+					hasSpecificSection = true
+				}
+			}
+		}
+	}
+	if hasSpecificSection {
+		return specific
+	}
+	return common
+}
+
+// robotsCache keeps parsed robots.txt of each host during ttl
+type robotsCache struct {
+	client *http.Client
+	ttl    time.Duration
+	mu     sync.Mutex
+	hosts  map[string]*robotsRules
+}
+
+func newRobotsCache(client *http.Client, ttl time.Duration) *robotsCache {
+	return &robotsCache{
+		client: client,
+		ttl:    ttl,
+		hosts:  make(map[string]*robotsRules),
+	}
+}
+
+func (c *robotsCache) allowed(ctx context.Context, u *url.URL) bool {
+	origin := u.Scheme + "://" + u.Host
+	c.mu.Lock()
+	rules, ok := c.hosts[origin]
+	c.mu.Unlock()
+	if !ok || time.Since(rules.fetchedAt) > c.ttl {
+		fetched := c.fetch(ctx, origin)
+		if ctx.Err() != nil {
+			// Cancelled task fails anyway, empty rules of the interrupted request aren't cached
+			return true
+		}
+		rules = &robotsRules{rules: fetched, fetchedAt: time.Now()}
+		c.mu.Lock()
+		c.hosts[origin] = rules
+		c.mu.Unlock()
+	}
+	return rules.allowed(u.EscapedPath())
+}
+
+// fetch returns no rules if robots.txt is unavailable
+func (c *robotsCache) fetch(ctx context.Context, origin string) []robotsRule {
+	req, err := http.NewRequestWithContext(ctx, "GET", origin+"/robots.txt", nil)
+	if err != nil {
+		return nil
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil
+	}
+	return parseRobots(io.LimitReader(res.Body, robotsSizeLimit))
+}
+
+func (c *robotsCache) deleteOutdated() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for origin, rules := range c.hosts {
+		if time.Since(rules.fetchedAt) > c.ttl {
+			delete(c.hosts, origin)
+		}
+	}
+}
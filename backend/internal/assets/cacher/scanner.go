@@ -0,0 +1,208 @@
+package cacher
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const scanChunkSize = 64 * 1024
+
+// scanner checks assets before upload, signature is empty if the asset is clean
+type scanner interface {
+	scan(ctx context.Context, data []byte, contentType string) (signature string, err error)
+}
+
+// newScanner parses the scanner address:
+// clamav://host:3310 or clamav:///var/run/clamav/clamd.ctl for the clamd socket,
+// icap://host:1344/service for an ICAP server with the RESPMOD service
+func newScanner(addr string, timeout time.Duration) (scanner, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, fmt.Errorf("wrong scanner address: %s", err)
+	}
+	switch u.Scheme {
+	case "clamav":
+		if u.Host == "" {
+			return &clamdScanner{network: "unix", addr: u.Path, timeout: timeout}, nil
+		}
+		return &clamdScanner{network: "tcp", addr: u.Host, timeout: timeout}, nil
+	case "icap":
+		if u.Port() == "" {
+			u.Host += ":1344"
+		}
+		return &icapScanner{url: u, timeout: timeout}, nil
+	}
+	return nil, fmt.Errorf("unknown scanner: %s", u.Scheme)
+}
+
+func dialScanner(ctx context.Context, network, addr string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	return conn, nil
+}
+
+// clamdScanner sends the asset with the INSTREAM command of clamd
+type clamdScanner struct {
+	network string
+	addr    string
+	timeout time.Duration
+}
+
+func (s *clamdScanner) scan(ctx context.Context, data []byte, _ string) (string, error) {
+	conn, err := dialScanner(ctx, s.network, s.addr, s.timeout)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	w := bufio.NewWriter(conn)
+	w.WriteString("zINSTREAM\x00")
+	size := make([]byte, 4)
+	for len(data) > 0 {
+		chunk := data
+		if len(chunk) > scanChunkSize {
+			chunk = chunk[:scanChunkSize]
+		}
+		binary.BigEndian.PutUint32(size, uint32(len(chunk)))
+		w.Write(size)
+		w.Write(chunk)
+		data = data[len(chunk):]
+	}
+	binary.BigEndian.PutUint32(size, 0)
+	w.Write(size)
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	// Possible replies: "stream: OK", "stream: <signature> FOUND", "INSTREAM size limit exceeded. ERROR"
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	switch {
+	case strings.HasSuffix(reply, " OK"):
+		return "", nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), " FOUND"), nil
+	}
+	return "", fmt.Errorf("clamd error: %s", reply)
+}
+
+// icapScanner sends the asset as the encapsulated response of the RESPMOD request (RFC 3507)
+type icapScanner struct {
+	url     *url.URL
+	timeout time.Duration
+}
+
+// Headers set by common ICAP antivirus services (c-icap, Symantec, McAfee, Kaspersky)
+var icapInfectionHeaders = []string{"X-Infection-Found", "X-Virus-ID", "X-Violations-Found"}
+
+func (s *icapScanner) scan(ctx context.Context, data []byte, contentType string) (string, error) {
+	conn, err := dialScanner(ctx, "tcp", s.url.Host, s.timeout)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	resHeader := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: %s\r\nContent-Length: %d\r\n\r\n", contentType, len(data))
+	w := bufio.NewWriter(conn)
+	fmt.Fprintf(w, "RESPMOD %s ICAP/1.0\r\n", s.url.String())
+	fmt.Fprintf(w, "Host: %s\r\n", s.url.Host)
+	w.WriteString("Allow: 204\r\n")
+	fmt.Fprintf(w, "Encapsulated: res-hdr=0, res-body=%d\r\n\r\n", len(resHeader))
+	w.WriteString(resHeader)
+	if len(data) > 0 {
+		fmt.Fprintf(w, "%x\r\n", len(data))
+		w.Write(data)
+		w.WriteString("\r\n")
+	}
+	w.WriteString("0\r\n\r\n")
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+
+	// ICAP status line and headers have the same format as the HTTP ones
+	reader := bufio.NewReader(conn)
+	status, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	parts := strings.Fields(status)
+	if len(parts) < 2 || !strings.HasPrefix(parts[0], "ICAP/") {
+		return "", fmt.Errorf("wrong icap response: %s", strings.TrimSpace(status))
+	}
+	header, err := readMIMEHeader(reader)
+	if err != nil {
+		return "", err
+	}
+	switch parts[1] {
+	case "204":
+		return "", nil
+	case "200":
+		for _, name := range icapInfectionHeaders {
+			if value := header.Get(name); value != "" {
+				return value, nil
+			}
+		}
+		// Unmodified response without infection headers means the service doesn't support 204
+		return "", nil
+	}
+	return "", fmt.Errorf("icap error: %s", strings.TrimSpace(status))
+}
+
+func readMIMEHeader(reader *bufio.Reader) (http.Header, error) {
+	header := http.Header{}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			return header, nil
+		}
+		if i := strings.IndexByte(line, ':'); i > 0 {
+			header.Add(strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:]))
+		}
+	}
+}
+
+// scanAsset uploads flagged assets to the quarantine prefix instead of the cache path, so they are
+// never served to the player. Assets aren't uploaded at all if the scanner is unavailable,
+// unless the cacher is configured to fail open.
+func (c *cacher) scanAsset(ctx context.Context, t *Task, data []byte, contentType string) error {
+	signature, err := c.scanner.scan(ctx, data, contentType)
+	if err != nil {
+		c.scanErrors.Add(context.Background(), 1)
+		if c.scanFailOpen {
+			return nil
+		}
+		return fmt.Errorf("can't scan asset: %s", err)
+	}
+	c.scannedAssets.Add(context.Background(), 1)
+	if signature == "" {
+		return nil
+	}
+	c.quarantinedAssets.Add(context.Background(), 1)
+	quarantinePath := c.quarantinePrefix + strings.TrimPrefix(t.cachePath, "/")
+	if err := c.s3.Upload(bytes.NewReader(data), quarantinePath, contentType, false); err != nil {
+		return fmt.Errorf("can't quarantine asset with %s: %s", signature, err)
+	}
+	return fmt.Errorf("asset is quarantined: %s, path: %s", signature, quarantinePath)
+}
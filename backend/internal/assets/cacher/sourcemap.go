@@ -0,0 +1,120 @@
+package cacher
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"openreplay/backend/pkg/url/assets"
+)
+
+var sourceMappingURL = regexp.MustCompile(`(?m)^[ \t]*//[#@][ \t]*sourceMappingURL=([^\s'"]+)[ \t]*$`)
+
+// sourceMapStatus is stored next to the JS file, symbolication service checks it
+// instead of requesting the source map itself
+type sourceMapStatus struct {
+	URL          string `json:"url"`
+	SourceMapURL string `json:"sourceMapURL,omitempty"` // empty for inline source maps
+	Available    bool   `json:"available"`
+	Error        string `json:"error,omitempty"`
+	UpdatedAt    int64  `json:"updatedAt"`
+}
+
+// sourceMapRef returns source map reference from the response headers or from the last comment of the file
+func sourceMapRef(header http.Header, data []byte) string {
+	if ref := header.Get("SourceMap"); ref != "" {
+		return ref
+	}
+	if ref := header.Get("X-SourceMap"); ref != "" {
+		return ref
+	}
+	matches := sourceMappingURL.FindAllSubmatch(data, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+	return string(matches[len(matches)-1][1])
+}
+
+// decodeDataURL supports inline source maps: data:application/json;base64,...
+func decodeDataURL(ref string) ([]byte, error) {
+	meta, payload, found := strings.Cut(strings.TrimPrefix(ref, "data:"), ",")
+	if !found {
+		return nil, errors.New("wrong data url")
+	}
+	if strings.HasSuffix(meta, ";base64") {
+		return base64.StdEncoding.DecodeString(payload)
+	}
+	decoded, err := url.PathUnescape(payload)
+	return []byte(decoded), err
+}
+
+// handleSourceMapRef stores inline source map or creates a task for the referenced one
+func (c *cacher) handleSourceMapRef(t *Task, header http.Header, data []byte) {
+	ref := sourceMapRef(header, data)
+	if ref == "" {
+		c.saveSourceMapStatus(t.requestURL, "", errors.New("no source map reference"))
+		return
+	}
+	if strings.HasPrefix(ref, "data:") {
+		sourceMap, err := decodeDataURL(ref)
+		if err == nil {
+			err = c.s3.Upload(bytes.NewReader(sourceMap), assets.GetCachePathForSourceMap(t.requestURL), "application/json", false)
+		}
+		c.saveSourceMapStatus(t.requestURL, "", err)
+		return
+	}
+	mapURL := assets.ResolveURL(t.requestURL, ref)
+	c.addFromWorker(&Task{
+		requestURL:  mapURL,
+		urlContext:  t.urlContext + "\n  -> " + mapURL,
+		isJS:        true,
+		cachePath:   assets.GetCachePathForSourceMap(t.requestURL),
+		sourceMapOf: t.requestURL,
+	})
+}
+
+func (c *cacher) cacheSourceMap(ctx context.Context, t *Task) error {
+	if ok, err := c.admit(ctx, t); err != nil {
+		c.saveSourceMapStatus(t.sourceMapOf, t.requestURL, err)
+		return err
+	} else if !ok {
+		return nil
+	}
+	data, res, err := c.fetch(ctx, t, c.sourceMapSizeLimit, nil)
+	if err == nil && data == nil {
+		return nil // not modified, status is already saved
+	}
+	if err == nil {
+		err = c.s3.Upload(bytes.NewReader(data), t.cachePath, "application/json", false)
+	}
+	if err == nil {
+		c.saveValidators(t.cachePath, res.Header)
+	}
+	c.saveSourceMapStatus(t.sourceMapOf, t.requestURL, err)
+	return err
+}
+
+func (c *cacher) saveSourceMapStatus(jsURL, mapURL string, err error) {
+	status := &sourceMapStatus{
+		URL:          jsURL,
+		SourceMapURL: mapURL,
+		Available:    err == nil,
+		UpdatedAt:    time.Now().UnixMilli(),
+	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+	data, _ := json.Marshal(status)
+	if err := c.s3.Upload(bytes.NewReader(data), assets.GetSourceMapStatusPath(jsURL), "application/json", false); err != nil {
+		log.Printf("can't save source map status: %s, url: %s", err, jsURL)
+	}
+}
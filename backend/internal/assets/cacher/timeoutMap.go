@@ -5,32 +5,36 @@ import (
 	"time"
 )
 
-const MAX_STORAGE_TIME = 24 * time.Hour
-
 // If problem with cache contention (>=4 core) look at sync.Map
 
 type timeoutMap struct {
-	mx sync.RWMutex
-	m  map[string]time.Time
+	mx  sync.RWMutex
+	m   map[string]time.Time
+	ttl time.Duration
 }
 
-func newTimeoutMap() *timeoutMap {
+func newTimeoutMap(ttl time.Duration) *timeoutMap {
 	return &timeoutMap{
-		m: make(map[string]time.Time),
+		m:   make(map[string]time.Time),
+		ttl: ttl,
 	}
 }
 
-func (tm *timeoutMap) add(key string) {
+// addIfNotExists returns false if the key is already in the map
+func (tm *timeoutMap) addIfNotExists(key string) bool {
 	tm.mx.Lock()
 	defer tm.mx.Unlock()
+	if _, ok := tm.m[key]; ok {
+		return false
+	}
 	tm.m[key] = time.Now()
+	return true
 }
 
-func (tm *timeoutMap) contains(key string) bool {
-	tm.mx.RLock()
-	defer tm.mx.RUnlock()
-	_, ok := tm.m[key]
-	return ok
+func (tm *timeoutMap) delete(key string) {
+	tm.mx.Lock()
+	defer tm.mx.Unlock()
+	delete(tm.m, key)
 }
 
 func (tm *timeoutMap) deleteOutdated() {
@@ -38,7 +42,7 @@ func (tm *timeoutMap) deleteOutdated() {
 	tm.mx.Lock()
 	defer tm.mx.Unlock()
 	for key, t := range tm.m {
-		if now.Sub(t) > MAX_STORAGE_TIME {
+		if now.Sub(t) > tm.ttl {
 			delete(tm.m, key)
 		}
 	}
@@ -0,0 +1,47 @@
+package cacher
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	config "openreplay/backend/internal/config/assets"
+)
+
+// newTransport builds the transport of the outbound client, every connection except the ones
+// to the proxy is checked by the fetch policy (resolved addresses of proxied hosts are checked by the proxy).
+// Slow origins hold connections for up to the response timeout, so limits per host keep
+// them from taking the whole pool
+func newTransport(cfg *config.Config, policy *fetchPolicy, proxy *proxySelector) *http.Transport {
+	checkedDialer := &net.Dialer{
+		Timeout: cfg.AssetsDialTimeout,
+		Control: policy.dialControl,
+	}
+	proxyDialer := &net.Dialer{
+		Timeout: cfg.AssetsDialTimeout,
+	}
+	transport := &http.Transport{
+		Proxy: proxy.proxyURL,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if proxy.isProxy(addr) {
+				return proxyDialer.DialContext(ctx, network, addr)
+			}
+			return checkedDialer.DialContext(ctx, network, addr)
+		},
+		TLSClientConfig:       &tls.Config{InsecureSkipVerify: true},
+		TLSHandshakeTimeout:   cfg.AssetsTLSTimeout,
+		ResponseHeaderTimeout: cfg.AssetsResponseTimeout,
+		MaxIdleConns:          cfg.AssetsMaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.AssetsMaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.AssetsMaxConnsPerHost,
+		IdleConnTimeout:       cfg.AssetsIdleConnTimeout,
+		// Custom dialer and TLS config disable HTTP/2 by default
+		ForceAttemptHTTP2: cfg.AssetsHTTP2,
+	}
+	if !cfg.AssetsHTTP2 {
+		// Non-nil empty map disables HTTP/2 upgrade
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+	return transport
+}
@@ -0,0 +1,142 @@
+package cacher
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// validators of the cached asset are sent back to the origin as conditional request headers
+type validators struct {
+	etag         string
+	lastModified string
+	savedAt      time.Time
+}
+
+func validatorsFromHeader(header http.Header) *validators {
+	v := &validators{
+		etag:         header.Get("ETag"),
+		lastModified: header.Get("Last-Modified"),
+		savedAt:      time.Now(),
+	}
+	if v.etag == "" && v.lastModified == "" {
+		return nil
+	}
+	return v
+}
+
+func (v *validators) setHeaders(req *http.Request) {
+	if v.etag != "" {
+		req.Header.Set("If-None-Match", v.etag)
+	}
+	if v.lastModified != "" {
+		req.Header.Set("If-Modified-Since", v.lastModified)
+	}
+}
+
+// validatorStore keeps validators of uploaded assets during ttl, ttl must be shorter
+// than the bucket retention, otherwise a revalidated asset could be already deleted
+type validatorStore interface {
+	get(cachePath string) *validators
+	set(cachePath string, v *validators)
+	deleteOutdated()
+}
+
+type memoryValidators struct {
+	mx  sync.RWMutex
+	m   map[string]*validators
+	ttl time.Duration
+}
+
+func newMemoryValidators(ttl time.Duration) *memoryValidators {
+	return &memoryValidators{
+		m:   make(map[string]*validators),
+		ttl: ttl,
+	}
+}
+
+func (s *memoryValidators) get(cachePath string) *validators {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	v, ok := s.m[cachePath]
+	if !ok || time.Since(v.savedAt) > s.ttl {
+		return nil
+	}
+	return v
+}
+
+func (s *memoryValidators) set(cachePath string, v *validators) {
+	s.mx.Lock()
+	s.m[cachePath] = v
+	s.mx.Unlock()
+}
+
+func (s *memoryValidators) deleteOutdated() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for key, v := range s.m {
+		if time.Since(v.savedAt) > s.ttl {
+			delete(s.m, key)
+		}
+	}
+}
+
+// redisValidators shares validators between cacher instances
+type redisValidators struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func redisValidatorsKey(cachePath string) string {
+	hash := sha1.Sum([]byte(cachePath))
+	return "assets:validators:" + hex.EncodeToString(hash[:])
+}
+
+func (s *redisValidators) get(cachePath string) *validators {
+	values, err := s.client.HMGet(redisValidatorsKey(cachePath), "etag", "lastModified").Result()
+	if err != nil {
+		log.Printf("can't get asset validators from redis: %s", err)
+		return nil
+	}
+	v := &validators{}
+	v.etag, _ = values[0].(string)
+	v.lastModified, _ = values[1].(string)
+	if v.etag == "" && v.lastModified == "" {
+		return nil
+	}
+	return v
+}
+
+func (s *redisValidators) set(cachePath string, v *validators) {
+	key := redisValidatorsKey(cachePath)
+	pipe := s.client.TxPipeline()
+	pipe.Del(key)
+	pipe.HMSet(key, map[string]interface{}{
+		"etag":         v.etag,
+		"lastModified": v.lastModified,
+	})
+	pipe.Expire(key, s.ttl)
+	if _, err := pipe.Exec(); err != nil {
+		log.Printf("can't save asset validators to redis: %s", err)
+	}
+}
+
+func (s *redisValidators) deleteOutdated() {
+	// redis keys expire by themselves
+}
+
+// saveValidators is called after the successful upload only, so 304 response always means
+// that the stored copy is up to date
+func (c *cacher) saveValidators(cachePath string, header http.Header) {
+	if c.validators == nil {
+		return
+	}
+	if v := validatorsFromHeader(header); v != nil {
+		c.validators.set(cachePath, v)
+	}
+}
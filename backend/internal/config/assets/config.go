@@ -3,17 +3,71 @@ package assets
 import (
 	"openreplay/backend/internal/config/common"
 	"openreplay/backend/internal/config/configurator"
+	"time"
 )
 
 type Config struct {
 	common.Config
-	GroupCache           string            `env:"GROUP_CACHE,required"`
-	TopicCache           string            `env:"TOPIC_CACHE,required"`
-	AWSRegion            string            `env:"AWS_REGION,required"`
-	S3BucketAssets       string            `env:"S3_BUCKET_ASSETS,required"`
-	AssetsOrigin         string            `env:"ASSETS_ORIGIN,required"`
-	AssetsSizeLimit      int               `env:"ASSETS_SIZE_LIMIT,required"`
-	AssetsRequestHeaders map[string]string `env:"ASSETS_REQUEST_HEADERS"`
+	GroupCache                string            `env:"GROUP_CACHE,required"`
+	TopicCache                string            `env:"TOPIC_CACHE,required"`
+	StorageProvider           string            `env:"STORAGE_PROVIDER,default=s3"`
+	AWSRegion                 string            `env:"AWS_REGION"` // required by the s3 provider
+	S3BucketAssets            string            `env:"S3_BUCKET_ASSETS,required"`
+	AssetsOrigin              string            `env:"ASSETS_ORIGIN,required"`
+	AssetsSizeLimit           int               `env:"ASSETS_SIZE_LIMIT,required"`
+	AssetsContentTypes        []string          `env:"ASSETS_CONTENT_TYPES"`       // patterns like image/*, empty list allows css, js, images and fonts, * allows everything
+	AssetsMaxDepth            byte              `env:"ASSETS_MAX_DEPTH,default=5"` // recursion depth of CSS imports, fonts and SVG references
+	AssetsWorkers             int               `env:"ASSETS_WORKERS,default=64"`
+	AssetsMinWorkers          int               `env:"ASSETS_MIN_WORKERS,default=0"`         // 0 disables autoscaling, the pool has ASSETS_WORKERS workers
+	AssetsWorkersLatency      time.Duration     `env:"ASSETS_WORKERS_LATENCY,default=500ms"` // queue wait time which adds workers
+	AssetsTaskTimeout         time.Duration     `env:"ASSETS_TASK_TIMEOUT,default=30s"`      // deadline of one task, cancels requests to a hung server, 0 means no limit
+	AssetsQueueCapacity       int               `env:"ASSETS_QUEUE_CAPACITY,default=128"`
+	AssetsOverflowMode        string            `env:"ASSETS_OVERFLOW_MODE,default=block"`
+	AssetsSpillLimit          int               `env:"ASSETS_SPILL_LIMIT,default=10000"`
+	AssetsQueueDir            string            `env:"ASSETS_QUEUE_DIR"` // local directory of pending tasks journal, empty keeps tasks only in memory
+	AssetsQueueSyncInterval   time.Duration     `env:"ASSETS_QUEUE_SYNC_INTERVAL,default=1s"`
+	AssetsQueueCompactSize    int64             `env:"ASSETS_QUEUE_COMPACT_SIZE,default=67108864"`
+	AssetsDedupTTL            time.Duration     `env:"ASSETS_DEDUP_TTL,default=24h"`
+	AssetsDedupRedis          bool              `env:"ASSETS_DEDUP_REDIS,default=false"`
+	RedisString               string            `env:"REDIS_STRING"`
+	AssetsRateLimit           float64           `env:"ASSETS_RATE_LIMIT,default=0"` // requests per second for each domain, 0 means no limit
+	AssetsRateBurst           int               `env:"ASSETS_RATE_BURST,default=10"`
+	AssetsDomainRateLimits    map[string]string `env:"ASSETS_DOMAIN_RATE_LIMITS"`          // domain:limit pairs, applied to subdomains as well
+	AssetsDelayedLimit        int               `env:"ASSETS_DELAYED_LIMIT,default=10000"` // tasks waiting for the rate limit or the full queue, the rest are dropped
+	AssetsAllowDomains        []string          `env:"ASSETS_ALLOW_DOMAINS"`               // host patterns like *.example.com, empty list allows all domains
+	AssetsDenyDomains         []string          `env:"ASSETS_DENY_DOMAINS"`
+	AssetsBlockPrivateIPs     bool              `env:"ASSETS_BLOCK_PRIVATE_IPS,default=true"` // connections to the proxy aren't checked
+	AssetsRespectRobots       bool              `env:"ASSETS_RESPECT_ROBOTS,default=false"`
+	AssetsRobotsTTL           time.Duration     `env:"ASSETS_ROBOTS_TTL,default=1h"`
+	AssetsBundleEnabled       bool              `env:"ASSETS_BUNDLE_ENABLED,default=false"`
+	AssetsBundleItemLimit     int               `env:"ASSETS_BUNDLE_ITEM_LIMIT,default=16384"` // bigger assets are stored only separately
+	AssetsBundleSizeLimit     int               `env:"ASSETS_BUNDLE_SIZE_LIMIT,default=4194304"`
+	AssetsBundleCacheSize     int               `env:"ASSETS_BUNDLE_CACHE_SIZE,default=67108864"`
+	AssetsBundleTimeout       time.Duration     `env:"ASSETS_BUNDLE_TIMEOUT,default=5m"`
+	AssetsSourceMaps          bool              `env:"ASSETS_SOURCE_MAPS,default=true"`
+	AssetsSourceMapSizeLimit  int               `env:"ASSETS_SOURCE_MAP_SIZE_LIMIT,default=52428800"`
+	AssetsRevalidate          bool              `env:"ASSETS_REVALIDATE,default=true"`     // conditional requests with ETag/Last-Modified of the stored copy
+	AssetsValidatorsTTL       time.Duration     `env:"ASSETS_VALIDATORS_TTL,default=168h"` // must be shorter than the assets bucket retention
+	AssetsTTL                 time.Duration     `env:"ASSETS_TTL,default=0"`               // cached assets which weren't uploaded during ttl are deleted, 0 disables eviction
+	AssetsEvictionInterval    time.Duration     `env:"ASSETS_EVICTION_INTERVAL,default=24h"`
+	AssetsRequestTimeout      time.Duration     `env:"ASSETS_REQUEST_TIMEOUT,default=6s"` // whole request including redirects and body
+	AssetsDialTimeout         time.Duration     `env:"ASSETS_DIAL_TIMEOUT,default=5s"`
+	AssetsTLSTimeout          time.Duration     `env:"ASSETS_TLS_TIMEOUT,default=5s"`
+	AssetsResponseTimeout     time.Duration     `env:"ASSETS_RESPONSE_TIMEOUT,default=5s"` // waiting for response headers
+	AssetsMaxIdleConns        int               `env:"ASSETS_MAX_IDLE_CONNS,default=100"`
+	AssetsMaxIdleConnsPerHost int               `env:"ASSETS_MAX_IDLE_CONNS_PER_HOST,default=8"`
+	AssetsMaxConnsPerHost     int               `env:"ASSETS_MAX_CONNS_PER_HOST,default=16"` // 0 means no limit
+	AssetsIdleConnTimeout     time.Duration     `env:"ASSETS_IDLE_CONN_TIMEOUT,default=90s"`
+	AssetsHTTP2               bool              `env:"ASSETS_HTTP2,default=true"`
+	AssetsMaxRedirects        int               `env:"ASSETS_MAX_REDIRECTS,default=10"`
+	AssetsRequestHeaders      map[string]string `env:"ASSETS_REQUEST_HEADERS"`
+	AssetsProxy               string            `env:"ASSETS_PROXY"`    // http://, https:// or socks5:// url with optional credentials, overrides HTTP(S)_PROXY
+	AssetsNoProxy             []string          `env:"ASSETS_NO_PROXY"` // host patterns like *.example.com which are fetched directly
+	AssetsStorageClass        string            `env:"ASSETS_STORAGE_CLASS"`
+	AssetsScanner             string            `env:"ASSETS_SCANNER"` // clamav://host:3310, clamav:///path/to/clamd.ctl or icap://host:1344/service
+	AssetsScanTimeout         time.Duration     `env:"ASSETS_SCAN_TIMEOUT,default=10s"`
+	AssetsScanFailOpen        bool              `env:"ASSETS_SCAN_FAIL_OPEN,default=false"` // upload assets without scanning if the scanner is unavailable
+	AssetsQuarantinePrefix    string            `env:"ASSETS_QUARANTINE_PREFIX,default=quarantine/"`
 }
 
 func New() *Config {
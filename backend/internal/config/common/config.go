@@ -1,8 +1,50 @@
 package common
 
+import "time"
+
 type Config struct {
 	ConfigFilePath   string `env:"CONFIG_FILE_PATH"`
 	MessageSizeLimit int    `env:"QUEUE_MESSAGE_SIZE_LIMIT,default=1048576"`
+	MemoryBudget     int    `env:"MEMORY_BUDGET_MB,default=0"`   // 0 means no limit
+	GoroutineBudget  int    `env:"GOROUTINE_BUDGET,default=0"`   // 0 means no limit
+	BudgetSoftLimit  int    `env:"BUDGET_SOFT_LIMIT,default=80"` // percent of the budget which slows consumers down
+
+	// Validation of raw messages by the services which save them (sink and db)
+	ValidateMessages bool          `env:"VALIDATE_MESSAGES,default=false"`
+	MaxStringSize    int           `env:"MESSAGE_MAX_STRING_SIZE,default=1048576"`
+	MaxCoordinate    int           `env:"MESSAGE_MAX_COORDINATE,default=1000000"` // pixels, 0 disables the check
+	MaxFutureDrift   time.Duration `env:"MESSAGE_MAX_FUTURE_DRIFT,default=24h"`   // timestamps ahead of the server clock
+
+	// Custom events are checked even if validation is disabled, 0 disables the size limit
+	CustomEventMaxPayloadSize int `env:"CUSTOM_EVENT_MAX_PAYLOAD_SIZE,default=16384"`
+
+	// Identical console logs and exceptions of the session are saved once per window with the number of repeats
+	LogDedupWindow      time.Duration `env:"LOG_DEDUP_WINDOW,default=0s"`     // 0 disables deduplication
+	LogDedupMaxKeys     int           `env:"LOG_DEDUP_MAX_KEYS,default=1000"` // different messages per session
+	LogDedupIdleTimeout time.Duration `env:"LOG_DEDUP_IDLE_TIMEOUT,default=30m"`
+
+	// Timestamps of sessions with wrong client clocks are moved to the time of receiving their batches
+	ClockSkewThreshold   time.Duration `env:"CLOCK_SKEW_THRESHOLD,default=0s"` // 0 disables the correction
+	ClockSkewIdleTimeout time.Duration `env:"CLOCK_SKEW_IDLE_TIMEOUT,default=2h"`
+
+	// Partition keys of topics (topic=session|project|user|tier, comma separated), session by default.
+	// Applied by the services which can get sessions from the database (http, ender and integrations).
+	PartitionKeys string `env:"QUEUE_PARTITION_KEYS"`
+	Partitions    int    `env:"QUEUE_PARTITIONS,default=8"` // has to be the same as the number of partitions of topics
+
+	// Processing priority classes of projects (projectID:high|normal|low, comma separated). The tier partition key
+	// gives each tier its own share of partitions, storage gives upload slots and integrations request data by tier.
+	DefaultTier  string   `env:"DEFAULT_PROJECT_TIER,default=normal"`
+	ProjectTiers []string `env:"PROJECT_TIERS"`
+
+	// Lag of consumer partitions is recorded to the consumer_lag metric and optionally posted to the url as JSON,
+	// so autoscalers (HPA, KEDA) can scale consumers by the lag
+	LagReportInterval time.Duration `env:"LAG_REPORT_INTERVAL,default=15s"`
+	LagReportURL      string        `env:"LAG_REPORT_URL"`
+
+	// gRPC control API (pause, drain, stats and config reload), empty address disables it
+	ControlAddr  string `env:"CONTROL_ADDR"`
+	ControlToken string `env:"CONTROL_TOKEN"` // required in the authorization metadata if it's set
 }
 
 type Configer interface {
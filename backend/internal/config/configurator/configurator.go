@@ -33,7 +33,10 @@ func readFile(path string) (map[string]string, error) {
 	res := make(map[string]string)
 	lines := strings.Split(string(data), "\n")
 	for _, line := range lines {
-		env := strings.Split(line, "=")
+		env := strings.SplitN(line, "=", 2)
+		if len(env) != 2 {
+			continue
+		}
 		res[env[0]] = env[1]
 	}
 	return res, nil
@@ -75,6 +78,13 @@ func parseFile(a interface{}, path string) {
 					continue
 				}
 				val.Field(i).SetUint(uint64(uintValue))
+			case "float32", "float64":
+				floatValue, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					log.Printf("can't parse float value: %s", err)
+					continue
+				}
+				val.Field(i).SetFloat(floatValue)
 			case "bool":
 				boolValue, err := strconv.ParseBool(value)
 				if err != nil {
@@ -89,6 +99,8 @@ func parseFile(a interface{}, path string) {
 					continue
 				}
 				val.Field(i).SetInt(int64(d))
+			case "[]string":
+				val.Field(i).Set(reflect.ValueOf(strings.Split(value, ",")))
 			case "map[string]string":
 				var stringMap map[string]string
 				if err := json.Unmarshal([]byte(value), &stringMap); err != nil {
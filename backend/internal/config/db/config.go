@@ -14,10 +14,19 @@ type Config struct {
 	GroupDB                    string        `env:"GROUP_DB,required"`
 	TopicRawWeb                string        `env:"TOPIC_RAW_WEB,required"`
 	TopicAnalytics             string        `env:"TOPIC_ANALYTICS,required"`
+	TopicRawIOS                string        `env:"TOPIC_RAW_IOS"` // enables mobile sessions
 	CommitBatchTimeout         time.Duration `env:"COMMIT_BATCH_TIMEOUT,default=15s"`
 	BatchQueueLimit            int           `env:"DB_BATCH_QUEUE_LIMIT,required"`
 	BatchSizeLimit             int           `env:"DB_BATCH_SIZE_LIMIT,required"`
 	UseQuickwit                bool          `env:"QUICKWIT_ENABLED,default=false"`
+	UseSessionState            bool          `env:"USE_SESSION_STATE,default=false"`
+	RedisString                string        `env:"REDIS_STRING"`
+
+	// Consumed batches are kept on the local disk while the database is unavailable, empty DB_SPILL_DIR disables it
+	SpillDir     string        `env:"DB_SPILL_DIR"`
+	SpillMaxSize int64         `env:"DB_SPILL_MAX_SIZE,default=1073741824"`
+	SpillMaxAge  time.Duration `env:"DB_SPILL_MAX_AGE,default=12h"`
+	SpillTimeout time.Duration `env:"DB_SPILL_TIMEOUT,default=30s"` // how long the database may be unavailable before spilling
 }
 
 func New() *Config {
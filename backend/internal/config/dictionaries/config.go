@@ -0,0 +1,26 @@
+package dictionaries
+
+import (
+	"openreplay/backend/internal/config/common"
+	"openreplay/backend/internal/config/configurator"
+)
+
+type Config struct {
+	common.Config
+	Postgres           string `env:"POSTGRES_STRING,required"`
+	StorageProvider    string `env:"STORAGE_PROVIDER,default=s3"`
+	S3Region           string `env:"AWS_REGION_WEB"` // required by the s3 provider
+	S3Bucket           string `env:"S3_BUCKET_WEB,required"`
+	FSDir              string `env:"FS_DIR,required"`
+	ZstdBinary         string `env:"ZSTD_BINARY,default=zstd"`
+	DictionarySize     int    `env:"DICTIONARY_SIZE,default=112640"`
+	SampleBlockSize    int    `env:"SAMPLE_BLOCK_SIZE,default=16384"`
+	SessionsPerProject int    `env:"SESSIONS_PER_PROJECT,default=200"`
+	MinSessions        int    `env:"MIN_SESSIONS,default=50"`
+}
+
+func New() *Config {
+	cfg := &Config{}
+	configurator.Process(cfg)
+	return cfg
+}
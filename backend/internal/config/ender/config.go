@@ -14,6 +14,9 @@ type Config struct {
 	TopicRawWeb                string `env:"TOPIC_RAW_WEB,required"`
 	ProducerTimeout            int    `env:"PRODUCER_TIMEOUT,default=2000"`
 	PartitionsNumber           int    `env:"PARTITIONS_NUMBER,required"`
+	UseStateHandoff            bool   `env:"USE_STATE_HANDOFF,default=false"`
+	RedisString                string `env:"REDIS_STRING"`
+	UseRedisState              bool   `env:"USE_REDIS_STATE,default=false"` // sessions are tracked in redis instead of memory
 }
 
 func New() *Config {
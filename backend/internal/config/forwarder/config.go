@@ -0,0 +1,39 @@
+package forwarder
+
+import (
+	"openreplay/backend/internal/config/common"
+	"openreplay/backend/internal/config/configurator"
+	"openreplay/backend/pkg/env"
+	"time"
+)
+
+type Config struct {
+	common.Config
+	Postgres                   string            `env:"POSTGRES_STRING,required"`
+	ProjectExpirationTimeoutMs int64             `env:"PROJECT_EXPIRATION_TIMEOUT_MS,default=1200000"`
+	GroupForwarder             string            `env:"GROUP_FORWARDER,required"`
+	TopicRawWeb                string            `env:"TOPIC_RAW_WEB,required"`
+	TopicAnalytics             string            `env:"TOPIC_ANALYTICS,required"`
+	Target                     string            `env:"FORWARD_TARGET,required"` // kafka or storage (s3 is the old name of storage)
+	Projects                   []string          `env:"FORWARD_PROJECTS"`        // project ids, empty list forwards all projects
+	ForwardEvents              bool              `env:"FORWARD_EVENTS,default=true"`
+	SessionDelay               time.Duration     `env:"FORWARD_SESSION_DELAY,default=30s"` // waits for the db service to save session counters
+	BatchSize                  int               `env:"FORWARD_BATCH_SIZE,default=1000"`
+	BufferLimit                int               `env:"FORWARD_BUFFER_LIMIT,default=100000"` // records kept while the target is unavailable
+	FlushInterval              time.Duration     `env:"FORWARD_FLUSH_INTERVAL,default=10s"`
+	KafkaServers               string            `env:"FORWARD_KAFKA_SERVERS"`
+	KafkaTopic                 string            `env:"FORWARD_KAFKA_TOPIC"`
+	KafkaProperties            map[string]string `env:"FORWARD_KAFKA_PROPERTIES"` // librdkafka properties, e.g. {"security.protocol":"sasl_ssl"}
+	ProducerTimeout            int               `env:"PRODUCER_TIMEOUT,default=2000"`
+	StorageProvider            string            `env:"FORWARD_STORAGE_PROVIDER,default=s3"`
+	S3Region                   string            `env:"FORWARD_S3_REGION"` // s3 provider only
+	S3Bucket                   string            `env:"FORWARD_S3_BUCKET"` // bucket policy has to allow writes of the service account
+	S3Prefix                   string            `env:"FORWARD_S3_PREFIX,default=openreplay"`
+	WorkerID                   uint16
+}
+
+func New() *Config {
+	cfg := &Config{WorkerID: env.WorkerID()}
+	configurator.Process(cfg)
+	return cfg
+}
@@ -13,6 +13,8 @@ type Config struct {
 	TopicRawWeb     string `env:"TOPIC_RAW_WEB,required"`
 	TopicRawIOS     string `env:"TOPIC_RAW_IOS,required"`
 	ProducerTimeout int    `env:"PRODUCER_TIMEOUT,default=2000"`
+	UseStateHandoff bool   `env:"USE_STATE_HANDOFF,default=false"`
+	RedisString     string `env:"REDIS_STRING"`
 }
 
 func New() *Config {
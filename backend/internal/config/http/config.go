@@ -12,18 +12,79 @@ type Config struct {
 	HTTPHost          string        `env:"HTTP_HOST,default="`
 	HTTPPort          string        `env:"HTTP_PORT,required"`
 	HTTPTimeout       time.Duration `env:"HTTP_TIMEOUT,default=60s"`
+	GRPCPort          string        `env:"GRPC_PORT"` // gRPC ingest for native SDKs on HTTP_HOST, empty disables it
 	TopicRawWeb       string        `env:"TOPIC_RAW_WEB,required"`
 	TopicRawIOS       string        `env:"TOPIC_RAW_IOS,required"`
 	BeaconSizeLimit   int64         `env:"BEACON_SIZE_LIMIT,required"`
 	JsonSizeLimit     int64         `env:"JSON_SIZE_LIMIT,default=1000"`
 	FileSizeLimit     int64         `env:"FILE_SIZE_LIMIT,default=10000000"`
-	AWSRegion         string        `env:"AWS_REGION,required"`
+	SearchSizeLimit   int64         `env:"SEARCH_SIZE_LIMIT,default=1048576"` // up to 50 filters of 100 values
+	StorageProvider   string        `env:"STORAGE_PROVIDER,default=s3"`
+	AWSRegion         string        `env:"AWS_REGION"` // required by the s3 provider
 	S3BucketIOSImages string        `env:"S3_BUCKET_IOS_IMAGES,required"`
 	Postgres          string        `env:"POSTGRES_STRING,required"`
 	TokenSecret       string        `env:"TOKEN_SECRET,required"`
 	UAParserFile      string        `env:"UAPARSER_FILE,required"`
 	MaxMinDBFile      string        `env:"MAXMINDDB_FILE,required"`
+	UseSessionState   bool          `env:"USE_SESSION_STATE,default=false"`
+	JWTSecret         string        `env:"JWT_SECRET"`
+	JWTIssuer         string        `env:"JWT_ISSUER"`
+	S3BucketWeb       string        `env:"S3_BUCKET_WEB"`
+	S3BucketAssets    string        `env:"S3_BUCKET_ASSETS"`
+	ReplayURLTimeout  time.Duration `env:"REPLAY_URL_TIMEOUT,default=5m"`
+	ReplaySizeLimit   int64         `env:"REPLAY_SIZE_LIMIT,default=262144"` // body of the replay urls request, up to 500 asset paths
+	RedisString       string        `env:"REDIS_STRING"`
+	ClickHouse        string        `env:"CLICKHOUSE_STRING"`
+	CDPSecret         string        `env:"CDP_WEBHOOK_SECRET"` // enables CDP webhook receiver
+	CDPSizeLimit      int64         `env:"CDP_SIZE_LIMIT,default=524288"`
+	TopicAnalytics    string        `env:"TOPIC_ANALYTICS"`
+	AttrsSizeLimit    int64         `env:"USER_ATTRIBUTES_SIZE_LIMIT,default=10000000"`
+	AttrsRowsLimit    int           `env:"USER_ATTRIBUTES_ROWS_LIMIT,default=100000"`
+	BotFilterAction   string        `env:"BOT_FILTER_ACTION"` // drop, flag or sample, empty disables bot filter
+	BotUAPatterns     []string      `env:"BOT_UA_PATTERNS"`
+	BotIPRanges       []string      `env:"BOT_IP_RANGES"`
+	BotIPRangesFile   string        `env:"BOT_IP_RANGES_FILE"`
+	BotSampleRate     int           `env:"BOT_SAMPLE_RATE,default=10"`
+	AssistSizeLimit   int64         `env:"ASSIST_EVENTS_SIZE_LIMIT,default=1048576"`
 	WorkerID          uint16
+
+	// Limits of endpoints (path=bytes, comma separated) which override the size limits above, e.g. /v1/web/start=4096.
+	// Limits apply to decompressed bodies (gzip and br Content-Encoding), larger bodies are rejected with 413.
+	BodySizeLimits []string `env:"BODY_SIZE_LIMITS"`
+
+	// Defaults of the tracker remote configuration, projects override them in the tracker_configs table
+	RemoteConfigTTL     time.Duration `env:"REMOTE_CONFIG_TTL,default=1m"`
+	TrackerTextMasking  bool          `env:"TRACKER_TEXT_MASKING,default=false"`
+	TrackerInputMasking bool          `env:"TRACKER_INPUT_MASKING,default=true"`
+	TrackerEmailMasking bool          `env:"TRACKER_EMAIL_MASKING,default=true"`
+	TrackerIngestURL    string        `env:"TRACKER_INGEST_URL"`
+	TrackerAssetsURL    string        `env:"TRACKER_ASSETS_URL"`
+
+	// Batches of server-side SDKs, keys are stored in the server_keys table, TOPIC_ANALYTICS is required
+	ServerBatchSizeLimit int64 `env:"SERVER_BATCH_SIZE_LIMIT,default=5242880"`
+
+	// Replays opened right after the session end are served from files cached by the storage service
+	ReplayCacheDir string `env:"REPLAY_CACHE_DIR"` // shared with the storage service, empty disables the cache
+	ReplayCacheURL string `env:"REPLAY_CACHE_URL"` // public url of this service, players download cached files from it
+	// Key of the urls of cached files, the cache can't be enabled without it
+	ReplayCacheSecret string `env:"REPLAY_CACHE_SECRET"`
+	// Files compressed with project dictionaries (USE_DICTIONARIES of the storage service) can't be read by the player,
+	// they are decompressed by this service, requires REPLAY_CACHE_URL and REPLAY_CACHE_SECRET
+	ReplayDictionaries bool `env:"REPLAY_DICTIONARIES,default=false"`
+
+	// Batches are kept on the local disk while the queue is unavailable, empty QUEUE_SPILL_DIR disables it
+	SpillDir     string        `env:"QUEUE_SPILL_DIR"`
+	SpillMaxSize int64         `env:"QUEUE_SPILL_MAX_SIZE,default=1073741824"`
+	SpillMaxAge  time.Duration `env:"QUEUE_SPILL_MAX_AGE,default=2h"`
+	SpillTimeout time.Duration `env:"QUEUE_SPILL_TIMEOUT,default=5s"` // how long the queue may be unavailable before spilling
+
+	// Batches are rejected with 503 while the queue producer has too many undelivered bytes (KAFKA_MAX_IN_FLIGHT_BYTES),
+	// trackers send them again after this time
+	BackpressureRetryAfter time.Duration `env:"BACKPRESSURE_RETRY_AFTER,default=5s"`
+	// Percent of sessions which trackers keep recording after the overload hint, the rest are stopped
+	OverloadSampleRate int `env:"OVERLOAD_SAMPLE_RATE,default=100"`
+	// New sessions get the hint for this time after the last rejected request
+	OverloadHintWindow time.Duration `env:"OVERLOAD_HINT_WINDOW,default=1m"`
 }
 
 func New() *Config {
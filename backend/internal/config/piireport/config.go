@@ -0,0 +1,25 @@
+package piireport
+
+import (
+	"openreplay/backend/internal/config/common"
+	"openreplay/backend/internal/config/configurator"
+)
+
+type Config struct {
+	common.Config
+	Postgres           string   `env:"POSTGRES_STRING,required"`
+	StorageProvider    string   `env:"STORAGE_PROVIDER,default=s3"`
+	S3Region           string   `env:"AWS_REGION_WEB"` // required by the s3 provider
+	S3Bucket           string   `env:"S3_BUCKET_WEB,required"`
+	Projects           []string `env:"PII_PROJECTS"` // project ids, empty list checks all active projects
+	SessionsPerProject int      `env:"PII_SESSIONS_PER_PROJECT,default=50"`
+	Rules              []string `env:"PII_RULES"`        // names of default rules, empty list enables all of them
+	CustomRules        []string `env:"PII_CUSTOM_RULES"` // name:regexp pairs
+	ReportDir          string   `env:"PII_REPORT_DIR,default=./pii-reports"`
+}
+
+func New() *Config {
+	cfg := &Config{}
+	configurator.Process(cfg)
+	return cfg
+}
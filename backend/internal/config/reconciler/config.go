@@ -0,0 +1,23 @@
+package reconciler
+
+import (
+	"openreplay/backend/internal/config/common"
+	"openreplay/backend/internal/config/configurator"
+	"time"
+)
+
+type Config struct {
+	common.Config
+	Postgres   string        `env:"POSTGRES_STRING,required"`
+	ClickHouse string        `env:"CLICKHOUSE_STRING,required"`
+	Window     time.Duration `env:"RECONCILE_WINDOW,default=1h"`
+	Delay      time.Duration `env:"RECONCILE_DELAY,default=1h"` // sessions have to be finished and saved by all services
+	Interval   time.Duration `env:"RECONCILE_INTERVAL,default=1h"`
+	Repair     bool          `env:"RECONCILE_REPAIR,default=false"` // copy sessions missing in ClickHouse from Postgres
+}
+
+func New() *Config {
+	cfg := &Config{}
+	configurator.Process(cfg)
+	return cfg
+}
@@ -0,0 +1,36 @@
+package retention
+
+import (
+	"openreplay/backend/internal/config/common"
+	"openreplay/backend/internal/config/configurator"
+	"time"
+)
+
+type Config struct {
+	common.Config
+	Postgres         string        `env:"POSTGRES_STRING,required"`
+	ClickHouse       string        `env:"CLICKHOUSE_STRING"` // used only by the enterprise edition
+	Interval         time.Duration `env:"RETENTION_INTERVAL,default=6h"`
+	DefaultRetention time.Duration `env:"RETENTION_DEFAULT,default=0"` // projects without retention_days, 0 keeps their sessions
+	BatchSize        int           `env:"RETENTION_BATCH_SIZE,default=500"`
+	DryRun           bool          `env:"RETENTION_DRY_RUN,default=false"` // only logs and counts sessions which would be deleted
+	ErasureInterval  time.Duration `env:"ERASURE_INTERVAL,default=1m"`     // checks of user erasure requests, they aren't processed in the dry run
+	ErasureBatchSize int           `env:"ERASURE_BATCH_SIZE,default=10"`
+
+	// Partitions of the largest tables, postgres tables have to be partitioned by the range of their time column
+	PartitionTables    []string      `env:"PARTITION_TABLES"`              // postgres tables like events.pages, empty list manages only analytics tables
+	PartitionAhead     int           `env:"PARTITION_AHEAD,default=3"`     // months with created partitions after the current one
+	PartitionRetention time.Duration `env:"PARTITION_RETENTION,default=0"` // applies to all projects, so it has to be longer than their retentions, 0 keeps all partitions
+
+	// Files of sessions, empty bucket keeps them (e.g. if the bucket has its own lifecycle rules)
+	StorageProvider string `env:"STORAGE_PROVIDER,default=s3"`
+	S3Region        string `env:"AWS_REGION_WEB"`
+	S3Bucket        string `env:"S3_BUCKET_WEB"`
+	CanvasPrefix    string `env:"CANVAS_PREFIX,default=canvas/"`
+}
+
+func New() *Config {
+	cfg := &Config{}
+	configurator.Process(cfg)
+	return cfg
+}
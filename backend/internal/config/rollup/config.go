@@ -0,0 +1,23 @@
+package rollup
+
+import (
+	"openreplay/backend/internal/config/common"
+	"openreplay/backend/internal/config/configurator"
+	"time"
+)
+
+type Config struct {
+	common.Config
+	ClickHouse        string        `env:"CLICKHOUSE_STRING,required"`
+	Interval          time.Duration `env:"ROLLUP_INTERVAL,default=5m"`
+	Lateness          time.Duration `env:"ROLLUP_LATENESS,default=10m"` // metrics of the period are written by the db service
+	RetentionMinutely time.Duration `env:"ROLLUP_RETENTION_MINUTELY,default=168h"`
+	RetentionHourly   time.Duration `env:"ROLLUP_RETENTION_HOURLY,default=2160h"`
+	RetentionDaily    time.Duration `env:"ROLLUP_RETENTION_DAILY,default=0"` // 0 keeps daily metrics forever
+}
+
+func New() *Config {
+	cfg := &Config{}
+	configurator.Process(cfg)
+	return cfg
+}
@@ -1,6 +1,8 @@
 package sink
 
 import (
+	"time"
+
 	"openreplay/backend/internal/config/common"
 	"openreplay/backend/internal/config/configurator"
 )
@@ -17,6 +19,16 @@ type Config struct {
 	CacheAssets          bool   `env:"CACHE_ASSETS,required"`
 	AssetsOrigin         string `env:"ASSETS_ORIGIN,required"`
 	ProducerCloseTimeout int    `env:"PRODUCER_CLOSE_TIMEOUT,default=15000"`
+
+	TimestampTolerance time.Duration `env:"TIMESTAMP_TOLERANCE,default=5s"` // bigger steps back in time re-stamp the rest of the session
+	SessionIdleTimeout time.Duration `env:"SESSION_IDLE_TIMEOUT,default=2h"`
+
+	// Images of canvas snapshots are written to FS_DIR/canvas and uploaded by the storage service
+	CanvasMaxSessionSize int `env:"CANVAS_MAX_SESSION_SIZE,default=52428800"` // 0 means no limit
+
+	// Repeated attribute strings are written once per session and referenced by the dictionary key
+	UseAttributeDictionary        bool `env:"USE_ATTRIBUTE_DICTIONARY,default=false"`
+	AttributeDictionaryMaxEntries int  `env:"ATTRIBUTE_DICTIONARY_MAX_ENTRIES,default=100000"` // per session, new strings are written as is after it
 }
 
 func New() *Config {
@@ -8,19 +8,43 @@ import (
 
 type Config struct {
 	common.Config
-	S3Region             string        `env:"AWS_REGION_WEB,required"`
+	StorageProvider      string        `env:"STORAGE_PROVIDER,default=s3"`
+	S3Region             string        `env:"AWS_REGION_WEB"` // required by the s3 provider
 	S3Bucket             string        `env:"S3_BUCKET_WEB,required"`
 	FSDir                string        `env:"FS_DIR,required"`
 	FSCleanHRS           int           `env:"FS_CLEAN_HRS,required"`
 	FileSplitSize        int           `env:"FILE_SPLIT_SIZE,required"`
 	RetryTimeout         time.Duration `env:"RETRY_TIMEOUT,default=2m"`
-	GroupStorage         string        `env:"GROUP_STORAGE,required"`
+	GroupStorage         string        `env:"GROUP_STORAGE,required"` // partitions are uploaded concurrently only with kafka, redis and nats read them in one goroutine
 	TopicTrigger         string        `env:"TOPIC_TRIGGER,required"`
 	GroupFailover        string        `env:"GROUP_STORAGE_FAILOVER"`
 	TopicFailover        string        `env:"TOPIC_STORAGE_FAILOVER"`
 	DeleteTimeout        time.Duration `env:"DELETE_TIMEOUT,default=48h"`
 	ProducerCloseTimeout int           `env:"PRODUCER_CLOSE_TIMEOUT,default=15000"`
 	UseFailover          bool          `env:"USE_FAILOVER,default=false"`
+	UseDictionaries      bool          `env:"USE_DICTIONARIES,default=false"` // requires USE_SESSION_STATE in db and http services and REPLAY_DICTIONARIES in http
+	DictionariesTTL      time.Duration `env:"DICTIONARIES_TTL,default=1h"`
+	RedisString          string        `env:"REDIS_STRING"`
+	UseDeltaEncoding     bool          `env:"USE_DELTA_ENCODING,default=false"` // replaces repeated DOM snapshots with references
+	DeltaMinRun          int           `env:"DELTA_MIN_RUN,default=16"`
+	UseLiveUpload        bool          `env:"USE_LIVE_UPLOAD,default=false"` // uploads chunks of active sessions, enable on one instance only
+	LiveUploadInterval   time.Duration `env:"LIVE_UPLOAD_INTERVAL,default=5s"`
+	LiveSessionTimeout   time.Duration `env:"LIVE_SESSION_TIMEOUT,default=5m"`
+	StorageClass         string        `env:"STORAGE_CLASS"`                // STANDARD, IA or INTELLIGENT_TIERING, empty uses the bucket default
+	ProjectClasses       []string      `env:"PROJECT_STORAGE_CLASSES"`      // projectID:class pairs, require session state
+	UploadConcurrency    int           `env:"UPLOAD_CONCURRENCY,default=0"` // 0 means no limit, slots are shared by PROJECT_TIERS
+
+	// Images of canvas snapshots written by the sink, the player expects the default prefix
+	CanvasPrefix string `env:"CANVAS_PREFIX,default=canvas/"`
+
+	// Uploaded files are kept in the directory shared with the http service, it serves replays opened right after the session end
+	ReplayCacheDir  string `env:"REPLAY_CACHE_DIR"` // empty disables the cache
+	ReplayCacheSize int64  `env:"REPLAY_CACHE_SIZE,default=1073741824"`
+
+	// Uploaded files are checked before the session is announced, eventually consistent stores return 404 for a while
+	UploadVerifyAttempts int           `env:"UPLOAD_VERIFY_ATTEMPTS,default=0"`    // 0 disables the check
+	UploadVerifyBackoff  time.Duration `env:"UPLOAD_VERIFY_BACKOFF,default=200ms"` // doubled after each attempt
+	TopicSessionReady    string        `env:"TOPIC_SESSION_READY"`                 // gets SessionEnd when files are readable
 }
 
 func New() *Config {
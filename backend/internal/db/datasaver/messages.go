@@ -78,6 +78,12 @@ func (mi *Saver) InsertMessage(sessionID uint64, msg Message) error {
 	case *IOSCrash:
 		return mi.pg.InsertIOSCrash(sessionID, m)
 
+		// Mobile
+	case *MobileTouchEvent:
+		return mi.pg.InsertMobileTouchEvent(sessionID, m)
+	case *MobileLifecycleEvent:
+		return mi.pg.InsertMobileLifecycleEvent(sessionID, m)
+
 	}
 	return nil // "Not implemented"
 }
@@ -2,6 +2,7 @@ package datasaver
 
 import (
 	"openreplay/backend/pkg/db/cache"
+	"openreplay/backend/pkg/queue"
 	"openreplay/backend/pkg/queue/types"
 )
 
@@ -13,3 +14,8 @@ type Saver struct {
 func New(pg *cache.PGCache, producer types.Producer) *Saver {
 	return &Saver{pg: pg, producer: producer}
 }
+
+// Health reports outages of the stores of the saver, batches are spilled to disk meanwhile
+func (s *Saver) Health() queue.HealthChecker {
+	return s.pg
+}
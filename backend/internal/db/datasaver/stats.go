@@ -3,9 +3,10 @@ package datasaver
 import (
 	. "openreplay/backend/pkg/db/types"
 	. "openreplay/backend/pkg/messages"
+	"openreplay/backend/pkg/monitoring"
 )
 
-func (si *Saver) InitStats() {
+func (si *Saver) InitStats(_ *monitoring.Metrics) {
 	// noop
 }
 
@@ -0,0 +1,126 @@
+package forwarder
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	"log"
+	"openreplay/backend/pkg/monitoring"
+	"time"
+)
+
+// Sink delivers records to the customer storage, Send returns only after the whole batch is persisted.
+// Records of a failed batch are sent again, so consumers may get duplicates.
+type Sink interface {
+	Send(records []*Record) error
+	Close() error
+}
+
+// Forwarder collects records into batches. Failed batches are kept and sent again with the next flush,
+// so consumer offsets have to be committed only after successful flush.
+type Forwarder struct {
+	sink           Sink
+	projects       map[uint32]bool // nil forwards records of all projects
+	batchSize      int
+	bufferLimit    int
+	buffer         []*Record
+	sentRecords    syncfloat64.Counter
+	failedBatches  syncfloat64.Counter
+	droppedRecords syncfloat64.Counter
+	sendDuration   syncfloat64.Histogram
+}
+
+func New(sink Sink, projects []uint32, batchSize, bufferLimit int, metrics *monitoring.Metrics) (*Forwarder, error) {
+	switch {
+	case sink == nil:
+		return nil, fmt.Errorf("sink is empty")
+	case metrics == nil:
+		return nil, fmt.Errorf("metrics module is empty")
+	case batchSize <= 0:
+		return nil, fmt.Errorf("wrong batch size: %d", batchSize)
+	case bufferLimit < batchSize:
+		return nil, fmt.Errorf("buffer limit is less than batch size")
+	}
+	f := &Forwarder{
+		sink:        sink,
+		batchSize:   batchSize,
+		bufferLimit: bufferLimit,
+	}
+	if len(projects) > 0 {
+		f.projects = make(map[uint32]bool, len(projects))
+		for _, projectID := range projects {
+			f.projects[projectID] = true
+		}
+	}
+	var err error
+	if f.sentRecords, err = metrics.RegisterCounter("forwarder_sent_records"); err != nil {
+		return nil, fmt.Errorf("can't register forwarder_sent_records metric: %s", err)
+	}
+	if f.failedBatches, err = metrics.RegisterCounter("forwarder_failed_batches"); err != nil {
+		return nil, fmt.Errorf("can't register forwarder_failed_batches metric: %s", err)
+	}
+	if f.droppedRecords, err = metrics.RegisterCounter("forwarder_dropped_records"); err != nil {
+		return nil, fmt.Errorf("can't register forwarder_dropped_records metric: %s", err)
+	}
+	if f.sendDuration, err = metrics.RegisterHistogram("forwarder_send_duration"); err != nil {
+		return nil, fmt.Errorf("can't register forwarder_send_duration metric: %s", err)
+	}
+	return f, nil
+}
+
+// IsForwarded checks the project filter
+func (f *Forwarder) IsForwarded(projectID uint32) bool {
+	return f.projects == nil || f.projects[projectID]
+}
+
+func (f *Forwarder) Add(rec *Record) {
+	if rec == nil || !f.IsForwarded(rec.ProjectID) {
+		return
+	}
+	f.buffer = append(f.buffer, rec)
+	if len(f.buffer) >= f.batchSize {
+		if err := f.Flush(); err != nil {
+			log.Printf("can't forward records: %s", err)
+		}
+	}
+}
+
+// Flush sends all collected records, the oldest records are dropped if the sink is unavailable for too long
+func (f *Forwarder) Flush() error {
+	for len(f.buffer) > 0 {
+		size := f.batchSize
+		if len(f.buffer) < size {
+			size = len(f.buffer)
+		}
+		start := time.Now()
+		err := f.sink.Send(f.buffer[:size])
+		f.sendDuration.Record(context.Background(), float64(time.Now().Sub(start).Milliseconds()),
+			attribute.Bool("failed", err != nil))
+		if err != nil {
+			f.failedBatches.Add(context.Background(), 1)
+			f.dropOverflow()
+			return err
+		}
+		f.sentRecords.Add(context.Background(), float64(size))
+		f.buffer = f.buffer[size:]
+	}
+	f.buffer = nil
+	return nil
+}
+
+func (f *Forwarder) dropOverflow() {
+	if overflow := len(f.buffer) - f.bufferLimit; overflow > 0 {
+		log.Printf("forwarder buffer is full, dropped records: %d", overflow)
+		f.droppedRecords.Add(context.Background(), float64(overflow))
+		f.buffer = f.buffer[overflow:]
+	}
+}
+
+func (f *Forwarder) Close() error {
+	err := f.Flush()
+	if closeErr := f.sink.Close(); closeErr != nil {
+		log.Printf("can't close forwarder sink: %s", closeErr)
+	}
+	return err
+}
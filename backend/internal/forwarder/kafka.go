@@ -0,0 +1,77 @@
+package forwarder
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+// kafkaSink writes records to the customer topic, session id is the message key,
+// so records of one session keep the order
+type kafkaSink struct {
+	producer *kafka.Producer
+	topic    string
+	timeout  int // ms
+}
+
+// NewKafkaSink accepts librdkafka properties for authentication (security.protocol, sasl.*, ssl.*)
+func NewKafkaSink(servers, topic string, properties map[string]string, timeout int) (Sink, error) {
+	switch {
+	case servers == "":
+		return nil, fmt.Errorf("kafka servers are empty")
+	case topic == "":
+		return nil, fmt.Errorf("kafka topic is empty")
+	}
+	cfg := &kafka.ConfigMap{
+		"bootstrap.servers":      servers,
+		"enable.idempotence":     true,
+		"queue.buffering.max.ms": 100,
+		"compression.type":       "lz4",
+	}
+	for key, value := range properties {
+		if err := cfg.SetKey(key, value); err != nil {
+			return nil, fmt.Errorf("wrong kafka property %s: %s", key, err)
+		}
+	}
+	producer, err := kafka.NewProducer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaSink{producer: producer, topic: topic, timeout: timeout}, nil
+}
+
+func (s *kafkaSink) Send(records []*Record) error {
+	reports := make(chan kafka.Event, len(records))
+	for _, rec := range records {
+		value, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		if err := s.producer.Produce(&kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &s.topic, Partition: kafka.PartitionAny},
+			Key:            []byte(rec.SessionID),
+			Value:          value,
+			Headers: []kafka.Header{
+				{Key: "schema-version", Value: []byte(strconv.Itoa(rec.Version))},
+				{Key: "record-type", Value: []byte(rec.Type)},
+			},
+		}, reports); err != nil {
+			return err
+		}
+	}
+	// Wait for delivery of the whole batch
+	for range records {
+		if msg, ok := (<-reports).(*kafka.Message); ok && msg.TopicPartition.Error != nil {
+			return msg.TopicPartition.Error
+		}
+	}
+	return nil
+}
+
+func (s *kafkaSink) Close() error {
+	s.producer.Flush(s.timeout)
+	s.producer.Close()
+	return nil
+}
@@ -0,0 +1,144 @@
+package forwarder
+
+import (
+	"strconv"
+
+	"openreplay/backend/pkg/db/types"
+	"openreplay/backend/pkg/messages"
+)
+
+// SchemaVersion is increased on every incompatible change of the record format,
+// consumers have to check it before parsing the record
+const SchemaVersion = 1
+
+const (
+	RecordSessionCompleted = "session_completed"
+	RecordEvent            = "event"
+)
+
+// Record is a normalized session or event, it doesn't depend on the tracker message format
+type Record struct {
+	Version   int      `json:"version"`
+	Type      string   `json:"type"`
+	ProjectID uint32   `json:"projectId"`
+	SessionID string   `json:"sessionId"` // string, because 64-bit numbers are lost in JSON parsers of some languages
+	Timestamp uint64   `json:"timestamp"`
+	Session   *Session `json:"session,omitempty"`
+	Event     *Event   `json:"event,omitempty"`
+}
+
+type Session struct {
+	StartTs         uint64            `json:"startTs"`
+	Duration        uint64            `json:"duration"`
+	Platform        string            `json:"platform"`
+	UserID          string            `json:"userId,omitempty"`
+	UserAnonymousID string            `json:"userAnonymousId,omitempty"`
+	UserOS          string            `json:"userOs"`
+	UserBrowser     string            `json:"userBrowser,omitempty"`
+	UserDevice      string            `json:"userDevice,omitempty"`
+	UserDeviceType  string            `json:"userDeviceType"`
+	UserCountry     string            `json:"userCountry"`
+	RevID           string            `json:"revId,omitempty"`
+	PagesCount      int               `json:"pagesCount"`
+	EventsCount     int               `json:"eventsCount"`
+	ErrorsCount     int               `json:"errorsCount"`
+	IssueTypes      []string          `json:"issueTypes"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+}
+
+type Event struct {
+	Type     string `json:"type"` // page, click, input, custom, error or issue
+	Name     string `json:"name,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Referrer string `json:"referrer,omitempty"`
+	Label    string `json:"label,omitempty"`
+	Selector string `json:"selector,omitempty"`
+	Source   string `json:"source,omitempty"`
+	Message  string `json:"message,omitempty"`
+	Context  string `json:"context,omitempty"`
+	Payload  string `json:"payload,omitempty"`
+	LoadTime uint64 `json:"loadTime,omitempty"`
+}
+
+// NewEventRecord returns nil for messages which aren't forwarded, project id is set by the caller.
+// Input values aren't forwarded, they may contain personal data.
+func NewEventRecord(sessionID uint64, msg messages.Message) *Record {
+	var event *Event
+	var timestamp uint64
+	switch m := msg.(type) {
+	case *messages.PageEvent:
+		event = &Event{Type: "page", URL: m.URL, Referrer: m.Referrer}
+		if m.LoadEventEnd > m.RequestStart {
+			event.LoadTime = m.LoadEventEnd - m.RequestStart
+		}
+		timestamp = m.Timestamp
+	case *messages.ClickEvent:
+		event = &Event{Type: "click", Label: m.Label, Selector: m.Selector}
+		timestamp = m.Timestamp
+	case *messages.InputEvent:
+		event = &Event{Type: "input", Label: m.Label}
+		timestamp = m.Timestamp
+	case *messages.CustomEvent:
+		event = &Event{Type: "custom", Name: m.Name, Payload: m.Payload}
+		timestamp = m.Timestamp
+	case *messages.ErrorEvent:
+		event = &Event{Type: "error", Name: m.Name, Source: m.Source, Message: m.Message}
+		timestamp = m.Timestamp
+	case *messages.IssueEvent:
+		event = &Event{Type: "issue", Name: m.Type, Context: m.ContextString, Payload: m.Payload}
+		timestamp = m.Timestamp
+	default:
+		return nil
+	}
+	return &Record{
+		Version:   SchemaVersion,
+		Type:      RecordEvent,
+		SessionID: strconv.FormatUint(sessionID, 10),
+		Timestamp: timestamp,
+		Event:     event,
+	}
+}
+
+// NewSessionRecord is created from the finished session, metadata columns are renamed according to the project config
+func NewSessionRecord(s *types.Session, project *types.Project) *Record {
+	session := &Session{
+		StartTs:        s.Timestamp,
+		Platform:       s.Platform,
+		UserOS:         s.UserOS,
+		UserBrowser:    s.UserBrowser,
+		UserDevice:     s.UserDevice,
+		UserDeviceType: s.UserDeviceType,
+		UserCountry:    s.UserCountry,
+		RevID:          s.RevID,
+		PagesCount:     s.PagesCount,
+		EventsCount:    s.EventsCount,
+		ErrorsCount:    s.ErrorsCount,
+		IssueTypes:     s.IssueTypes,
+	}
+	if s.Duration != nil {
+		session.Duration = *s.Duration
+	}
+	if s.UserID != nil {
+		session.UserID = *s.UserID
+	}
+	if s.UserAnonymousID != nil {
+		session.UserAnonymousID = *s.UserAnonymousID
+	}
+	for no := uint(1); no <= 10; no++ {
+		value := s.GetMetadata(no)
+		if key := project.GetMetadataKey(no); value != nil && key != "" {
+			if session.Metadata == nil {
+				session.Metadata = make(map[string]string)
+			}
+			session.Metadata[key] = *value
+		}
+	}
+	return &Record{
+		Version:   SchemaVersion,
+		Type:      RecordSessionCompleted,
+		ProjectID: s.ProjectID,
+		SessionID: strconv.FormatUint(s.SessionID, 10),
+		Timestamp: s.Timestamp + session.Duration,
+		Session:   session,
+	}
+}
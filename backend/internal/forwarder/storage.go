@@ -0,0 +1,71 @@
+package forwarder
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+
+	"openreplay/backend/pkg/storage"
+)
+
+// storageSink uploads each batch to the object storage (s3 or gcs) as gzipped newline-delimited JSON, one file per project.
+// Keys are partitioned by date and hour, so the data can be queried by Athena or BigQuery
+// without extra processing.
+type storageSink struct {
+	storage storage.ObjectStorage
+	prefix  string
+	worker  uint16 // several forwarders may upload files at the same time
+}
+
+func NewStorageSink(objStorage storage.ObjectStorage, prefix string, worker uint16) (Sink, error) {
+	if objStorage == nil {
+		return nil, fmt.Errorf("object storage is empty")
+	}
+	return &storageSink{storage: objStorage, prefix: prefix, worker: worker}, nil
+}
+
+func (s *storageSink) Send(records []*Record) error {
+	projects := make(map[uint32][]*Record)
+	for _, rec := range records {
+		projects[rec.ProjectID] = append(projects[rec.ProjectID], rec)
+	}
+	now := time.Now().UTC()
+	for projectID, projectRecords := range projects {
+		data, err := encodeRecords(projectRecords)
+		if err != nil {
+			return err
+		}
+		key := path.Join(s.prefix,
+			fmt.Sprintf("version=%d", SchemaVersion),
+			fmt.Sprintf("project_id=%d", projectID),
+			now.Format("dt=2006-01-02/hour=15"),
+			fmt.Sprintf("%d-%d.ndjson.gz", now.UnixNano(), s.worker),
+		)
+		if err := s.storage.Upload(bytes.NewReader(data), key, "application/x-ndjson", true); err != nil {
+			return fmt.Errorf("can't upload %s: %s", key, err)
+		}
+	}
+	return nil
+}
+
+func encodeRecords(records []*Record) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	encoder := json.NewEncoder(gz) // adds a new line after each record
+	for _, rec := range records {
+		if err := encoder.Encode(rec); err != nil {
+			return nil, err
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *storageSink) Close() error {
+	return nil
+}
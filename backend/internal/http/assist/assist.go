@@ -0,0 +1,100 @@
+package assist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"openreplay/backend/pkg/messages"
+)
+
+const (
+	maxBatchSize   = 100
+	maxPayloadSize = 8 * 1024
+	maxNameSize    = 256
+	maxEventDelay  = 5 * time.Minute
+)
+
+// eventTypes are agent-side actions which change what the user sees, they have to be visible in the replay
+var eventTypes = map[string]bool{
+	"click":         true, // remote click while the agent controls the page
+	"scroll":        true,
+	"input":         true,
+	"annotation":    true, // drawing over the user screen
+	"control_start": true,
+	"control_end":   true,
+	"call_start":    true,
+	"call_end":      true,
+}
+
+// Event is one action of the agent in the live session
+type Event struct {
+	Type      string          `json:"type"`
+	Timestamp int64           `json:"timestamp"` // unix ms on the agent side
+	Payload   json.RawMessage `json:"payload"`   // coordinates, selector, annotation path etc.
+}
+
+// Batch is sent by the agent UI along with the assist socket events
+type Batch struct {
+	SessionID string   `json:"sessionID"`
+	AgentName string   `json:"agentName"` // display name, the agent id is taken from the token
+	Events    []*Event `json:"events"`
+}
+
+func ParseBody(body []byte) (*Batch, error) {
+	batch := &Batch{}
+	if err := json.Unmarshal(body, batch); err != nil {
+		return nil, err
+	}
+	if len(batch.Events) == 0 {
+		return nil, errors.New("events are empty")
+	}
+	if len(batch.Events) > maxBatchSize {
+		return nil, fmt.Errorf("batch is too big, max: %d", maxBatchSize)
+	}
+	for _, e := range batch.Events {
+		if !eventTypes[e.Type] {
+			return nil, fmt.Errorf("unsupported event type: %s", e.Type)
+		}
+		if len(e.Payload) > maxPayloadSize {
+			return nil, fmt.Errorf("payload of %s event is too big, max: %d", e.Type, maxPayloadSize)
+		}
+	}
+	if len(batch.AgentName) > maxNameSize {
+		batch.AgentName = batch.AgentName[:maxNameSize]
+	}
+	return batch, nil
+}
+
+// eventTime keeps the agent clock only if it's close to the server one, otherwise the event
+// would be placed at the wrong moment of the recording
+func eventTime(e *Event, now time.Time) uint64 {
+	ts := time.UnixMilli(e.Timestamp)
+	if e.Timestamp <= 0 || ts.After(now) || ts.Before(now.Add(-maxEventDelay)) {
+		ts = now
+	}
+	return uint64(ts.UnixMilli())
+}
+
+// ToBatch encodes events as a batch of the session raw stream. Every event is preceded by
+// the timestamp message, so the sink merges it into the recording at the moment it happened.
+func ToBatch(agentID uint64, batch *Batch, now time.Time) []byte {
+	var data []byte
+	for _, e := range batch.Events {
+		ts := eventTime(e, now)
+		payload := string(e.Payload)
+		if payload == "" {
+			payload = "{}"
+		}
+		data = append(data, messages.Encode(&messages.Timestamp{Timestamp: ts})...)
+		data = append(data, messages.Encode(&messages.AssistEvent{
+			Timestamp: ts,
+			AgentID:   agentID,
+			AgentName: batch.AgentName,
+			Type:      e.Type,
+			Payload:   payload,
+		})...)
+	}
+	return data
+}
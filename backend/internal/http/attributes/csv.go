@@ -0,0 +1,81 @@
+package attributes
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	UserIDColumn   = "user_id"
+	MaxKeyLength   = 64
+	MaxValueLength = 256
+)
+
+// ParseCSV reads user attributes from CSV with a header row, one column has to be "user_id",
+// other columns are attribute names. Empty values are skipped, so they don't overwrite imported ones.
+func ParseCSV(r io.Reader, maxRows int) (map[string]map[string]string, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("header row is missing")
+	}
+	if err != nil {
+		return nil, err
+	}
+	userIDCol := -1
+	for i, name := range header {
+		// Excel adds BOM to the beginning of the file
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\uFEFF"))
+		if strings.EqualFold(name, UserIDColumn) {
+			userIDCol = i
+			continue
+		}
+		if name == "" || len(name) > MaxKeyLength {
+			return nil, fmt.Errorf("wrong column name: %q", name)
+		}
+		header[i] = name
+	}
+	if userIDCol < 0 {
+		return nil, fmt.Errorf("%s column is missing", UserIDColumn)
+	}
+	if len(header) < 2 {
+		return nil, errors.New("there are no attribute columns")
+	}
+
+	users := make(map[string]map[string]string)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		userID := strings.TrimSpace(record[userIDCol])
+		if userID == "" {
+			return nil, fmt.Errorf("empty %s on line %d", UserIDColumn, line)
+		}
+		user, ok := users[userID]
+		if !ok {
+			if len(users) >= maxRows {
+				return nil, fmt.Errorf("too many users, limit: %d", maxRows)
+			}
+			user = make(map[string]string)
+			users[userID] = user
+		}
+		for i, value := range record {
+			if value = strings.TrimSpace(value); i == userIDCol || value == "" {
+				continue
+			}
+			if len(value) > MaxValueLength {
+				return nil, fmt.Errorf("too long value of %s on line %d", header[i], line)
+			}
+			user[header[i]] = value
+		}
+	}
+	return users, nil
+}
@@ -0,0 +1,138 @@
+package botfilter
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/tomasen/realip"
+)
+
+type Action string
+
+const (
+	ActionDrop   Action = "drop"   // session isn't started
+	ActionFlag   Action = "flag"   // session is recorded with bot_suspected event
+	ActionSample Action = "sample" // only sample rate percent of bot sessions are recorded and flagged
+)
+
+const (
+	ReasonUserAgent  = "user_agent"
+	ReasonHeadless   = "headless"
+	ReasonDatacenter = "datacenter_ip"
+)
+
+// Crawlers, headless browsers and synthetic monitoring services, matched as lowercase substrings
+var defaultUAPatterns = []string{
+	"bot", "crawler", "spider", "slurp",
+	"headlesschrome", "phantomjs", "puppeteer", "playwright", "selenium", "webdriver",
+	"lighthouse", "pingdom", "datadogsynthetics", "gtmetrix", "uptimerobot", "newrelicpinger",
+	"catchpoint", "site24x7", "statuscake", "checkly",
+}
+
+// Filter detects suspected bot and synthetic monitoring traffic by the start request
+type Filter struct {
+	action     Action
+	sampleRate int
+	uaPatterns []string
+	ipRanges   []*net.IPNet
+}
+
+// New creates the filter, configured user agent patterns extend the default list,
+// datacenter ip ranges are taken from the config and the file with one CIDR per line
+func New(action string, sampleRate int, uaPatterns, ipRanges []string, ipRangesFile string) (*Filter, error) {
+	f := &Filter{action: Action(action), sampleRate: sampleRate}
+	switch f.action {
+	case ActionDrop, ActionFlag:
+	case ActionSample:
+		if sampleRate < 0 || sampleRate > 100 {
+			return nil, fmt.Errorf("wrong bot sample rate: %d", sampleRate)
+		}
+	default:
+		return nil, fmt.Errorf("unknown bot filter action: %s", action)
+	}
+	for _, pattern := range append(defaultUAPatterns, uaPatterns...) {
+		if pattern = strings.ToLower(strings.TrimSpace(pattern)); pattern != "" {
+			f.uaPatterns = append(f.uaPatterns, pattern)
+		}
+	}
+	if ipRangesFile != "" {
+		fileRanges, err := readRanges(ipRangesFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't read ip ranges file: %s", err)
+		}
+		ipRanges = append(ipRanges, fileRanges...)
+	}
+	for _, cidr := range ipRanges {
+		if cidr = strings.TrimSpace(cidr); cidr == "" {
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("wrong ip range %s: %s", cidr, err)
+		}
+		f.ipRanges = append(f.ipRanges, ipNet)
+	}
+	return f, nil
+}
+
+func readRanges(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var ranges []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ranges = append(ranges, line)
+	}
+	return ranges, scanner.Err()
+}
+
+func (f *Filter) Action() Action {
+	return f.action
+}
+
+// Check returns the reason why the request looks like a bot, empty reason means a regular user
+func (f *Filter) Check(r *http.Request) string {
+	ua := strings.ToLower(r.Header.Get("User-Agent"))
+	for _, pattern := range f.uaPatterns {
+		if strings.Contains(ua, pattern) {
+			return ReasonUserAgent
+		}
+	}
+	// Real browsers always send preferred languages, headless ones often don't
+	if strings.Contains(strings.ToLower(r.Header.Get("Sec-CH-UA")), "headless") || r.Header.Get("Accept-Language") == "" {
+		return ReasonHeadless
+	}
+	if len(f.ipRanges) > 0 {
+		if ip := net.ParseIP(realip.FromRequest(r)); ip != nil {
+			for _, ipNet := range f.ipRanges {
+				if ipNet.Contains(ip) {
+					return ReasonDatacenter
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// Keep decides whether the suspected bot session is recorded
+func (f *Filter) Keep() bool {
+	switch f.action {
+	case ActionFlag:
+		return true
+	case ActionSample:
+		return rand.Intn(100) < f.sampleRate
+	}
+	return false
+}
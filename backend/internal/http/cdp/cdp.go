@@ -0,0 +1,153 @@
+package cdp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+
+	"openreplay/backend/pkg/messages"
+)
+
+const (
+	maxBatchSize   = 100
+	maxTraits      = 10
+	maxPayloadSize = 8 * 1024
+)
+
+// Event is a Segment-like track or identify call, other types are ignored
+type Event struct {
+	Type        string                 `json:"type"`
+	MessageID   string                 `json:"messageId"`
+	Event       string                 `json:"event"` // name of the tracked event
+	UserID      string                 `json:"userId"`
+	AnonymousID string                 `json:"anonymousId"`
+	Timestamp   string                 `json:"timestamp"` // ISO 8601
+	Properties  map[string]interface{} `json:"properties"`
+	Traits      map[string]interface{} `json:"traits"`
+	Context     struct {
+		OpenReplay SessionRef             `json:"openreplay"`
+		Traits     map[string]interface{} `json:"traits"`
+	} `json:"context"`
+	Integrations struct {
+		OpenReplay SessionRef `json:"OpenReplay"`
+	} `json:"integrations"`
+}
+
+// SessionRef links the event to the recorded session, user ids are used if it's empty
+type SessionRef struct {
+	SessionID    string `json:"sessionId"`
+	SessionToken string `json:"sessionToken"`
+}
+
+// Ref returns the session reference from the context or from the integration options
+func (e *Event) Ref() SessionRef {
+	if e.Context.OpenReplay.SessionID != "" || e.Context.OpenReplay.SessionToken != "" {
+		return e.Context.OpenReplay
+	}
+	return e.Integrations.OpenReplay
+}
+
+// Time returns the event time, current time if it's missing or has a wrong format
+func (e *Event) Time() time.Time {
+	if ts, err := time.Parse(time.RFC3339Nano, e.Timestamp); err == nil {
+		return ts
+	}
+	return time.Now()
+}
+
+// ParseBody accepts a single call or a batch: {"batch": [...]}
+func ParseBody(body []byte) ([]*Event, error) {
+	batch := &struct {
+		Batch []*Event `json:"batch"`
+	}{}
+	if err := json.Unmarshal(body, batch); err != nil {
+		return nil, err
+	}
+	if len(batch.Batch) > maxBatchSize {
+		return nil, fmt.Errorf("batch is too big, max: %d", maxBatchSize)
+	}
+	if batch.Batch != nil {
+		return batch.Batch, nil
+	}
+	event := &Event{}
+	if err := json.Unmarshal(body, event); err != nil {
+		return nil, err
+	}
+	return []*Event{event}, nil
+}
+
+// messageIndex keeps events with the same messageId deduplicated in the database
+func messageIndex(e *Event) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(e.MessageID + e.Event + e.Timestamp))
+	return h.Sum64()
+}
+
+// ToMessages maps the call to OpenReplay messages, track becomes a custom event
+// and identify sets the user id and metadata of the session
+func ToMessages(e *Event) ([]messages.Message, error) {
+	var res []messages.Message
+	switch e.Type {
+	case "track":
+		if e.Event == "" {
+			return nil, errors.New("event name is empty")
+		}
+		payload, err := json.Marshal(e.Properties)
+		if err != nil {
+			return nil, err
+		}
+		if len(payload) > maxPayloadSize {
+			payload = []byte("{}")
+		}
+		res = append(res, &messages.CustomEvent{
+			MessageID: messageIndex(e),
+			Timestamp: uint64(e.Time().UnixMilli()),
+			Name:      e.Event,
+			Payload:   string(payload),
+		})
+	case "identify":
+		if e.UserID != "" {
+			res = append(res, &messages.UserID{ID: e.UserID})
+		}
+		traits := e.Traits
+		if traits == nil {
+			traits = e.Context.Traits
+		}
+		for key, value := range traits {
+			if len(res) > maxTraits {
+				break
+			}
+			// Only traits which are configured as project metadata are saved
+			if str, ok := value.(string); ok {
+				res = append(res, &messages.Metadata{Key: key, Value: str})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported call type: %s", e.Type)
+	}
+	return res, nil
+}
+
+// Authorize checks "Authorization: Bearer <secret>" or the Segment X-Signature header (HMAC-SHA1 of the body)
+func Authorize(secret, authorization, signature string, body []byte) bool {
+	if token := strings.TrimPrefix(authorization, "Bearer "); token != authorization {
+		return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+	}
+	if signature == "" {
+		return false
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,145 @@
+package overload
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Reasons of hints, trackers handle all of them the same way
+const (
+	ReasonBackpressure = "backpressure" // the queue doesn't accept batches while brokers are slow
+)
+
+// Projects of sessions which weren't seen for this time are resolved again
+const (
+	projectsTTL             = time.Hour
+	projectsCleanupInterval = time.Minute
+)
+
+// Hint is sent to trackers in the body of rejected requests and in start responses while the service is
+// overloaded. Trackers send the rejected batch again after RetryAfter and keep recording only SampleRate
+// percent of sessions, the rest are stopped.
+type Hint struct {
+	Reason     string `json:"reason"`
+	RetryAfter int64  `json:"retryAfter"` // ms
+	SampleRate int    `json:"sampleRate"`
+}
+
+// ProjectResolver returns the project of the session
+type ProjectResolver func(sessionID uint64) (uint32, error)
+
+// Counter records issued hints, project is 0 if it can't be resolved
+type Counter func(projectID uint32, reason string)
+
+type project struct {
+	id       uint32
+	lastSeen time.Time
+}
+
+// Hints issues hints of the same shape for all reasons of overload and counts them per project.
+// New sessions get the hint of the last overload for window after it.
+type Hints struct {
+	retryAfter time.Duration
+	sampleRate int
+	window     time.Duration
+	resolve    ProjectResolver
+	count      Counter
+
+	mu           sync.Mutex
+	projects     map[uint64]*project
+	lastCleanup  time.Time
+	lastReason   string
+	lastOverload time.Time
+}
+
+func New(retryAfter time.Duration, sampleRate int, window time.Duration, resolve ProjectResolver, count Counter) (*Hints, error) {
+	switch {
+	case retryAfter <= 0:
+		return nil, fmt.Errorf("wrong retry after: %s", retryAfter)
+	case sampleRate < 0 || sampleRate > 100:
+		return nil, fmt.Errorf("wrong overload sample rate: %d", sampleRate)
+	case resolve == nil:
+		return nil, fmt.Errorf("project resolver is empty")
+	case count == nil:
+		return nil, fmt.Errorf("counter is empty")
+	}
+	return &Hints{
+		retryAfter:  retryAfter,
+		sampleRate:  sampleRate,
+		window:      window,
+		resolve:     resolve,
+		count:       count,
+		projects:    make(map[uint64]*project),
+		lastCleanup: time.Now(),
+	}, nil
+}
+
+// RetryAfter is the delay of all hints
+func (h *Hints) RetryAfter() time.Duration {
+	return h.retryAfter
+}
+
+// Issue returns the hint for the rejected request of the session
+func (h *Hints) Issue(sessionID uint64, reason string) *Hint {
+	return h.IssueProject(h.project(sessionID), reason)
+}
+
+// IssueProject returns the hint for the rejected request of the project
+func (h *Hints) IssueProject(projectID uint32, reason string) *Hint {
+	h.mu.Lock()
+	h.lastReason, h.lastOverload = reason, time.Now()
+	h.mu.Unlock()
+	h.count(projectID, reason)
+	return h.hint(reason)
+}
+
+// Current returns the hint of the last overload for the started session, nil if the service isn't overloaded
+func (h *Hints) Current(projectID uint32) *Hint {
+	h.mu.Lock()
+	reason, active := h.lastReason, !h.lastOverload.IsZero() && time.Since(h.lastOverload) < h.window
+	h.mu.Unlock()
+	if !active {
+		return nil
+	}
+	h.count(projectID, reason)
+	return h.hint(reason)
+}
+
+func (h *Hints) hint(reason string) *Hint {
+	return &Hint{
+		Reason:     reason,
+		RetryAfter: h.retryAfter.Milliseconds(),
+		SampleRate: h.sampleRate,
+	}
+}
+
+// project keeps resolved projects, so the database is queried once per session during the overload
+func (h *Hints) project(sessionID uint64) uint32 {
+	now := time.Now()
+	h.mu.Lock()
+	if now.Sub(h.lastCleanup) > projectsCleanupInterval {
+		for id, p := range h.projects {
+			if now.Sub(p.lastSeen) > projectsTTL {
+				delete(h.projects, id)
+			}
+		}
+		h.lastCleanup = now
+	}
+	if p, ok := h.projects[sessionID]; ok {
+		p.lastSeen = now
+		h.mu.Unlock()
+		return p.id
+	}
+	h.mu.Unlock()
+
+	projectID, err := h.resolve(sessionID)
+	if err != nil {
+		log.Printf("can't get project of overloaded session, sessID: %d, err: %s", sessionID, err)
+	}
+	h.mu.Lock()
+	h.projects[sessionID] = &project{id: projectID, lastSeen: now}
+	h.mu.Unlock()
+	return projectID
+}
@@ -0,0 +1,118 @@
+package remoteconfig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SchemaVersion is increased on incompatible changes of the document, trackers ignore unknown versions
+const SchemaVersion = 1
+
+// Obfuscation defaults, trackers apply them unless the page overrides them with data attributes
+type Obfuscation struct {
+	TextMasking  bool `json:"textMasking"`
+	InputMasking bool `json:"inputMasking"`
+	EmailMasking bool `json:"emailMasking"`
+}
+
+// Endpoints replace the tracker defaults, empty values keep the ones from the tracker options
+type Endpoints struct {
+	Ingest string `json:"ingest,omitempty"`
+	Assets string `json:"assets,omitempty"`
+}
+
+// Document is polled by trackers, so behaviour can be changed without redeploying the site
+type Document struct {
+	Version           int         `json:"version"`
+	CaptureRate       int         `json:"captureRate"`       // percent of sessions to record
+	HeartbeatInterval int64       `json:"heartbeatInterval"` // ms
+	Obfuscation       Obfuscation `json:"obfuscation"`
+	Endpoints         Endpoints   `json:"endpoints"`
+}
+
+// ErrUnknownProject is returned by loaders for wrong or inactive project keys
+var ErrUnknownProject = errors.New("project doesn't exist")
+
+// Loader returns the capture rate of the project and its overrides from the database, nil if there are none
+type Loader func(projectKey string) (captureRate int, overrides []byte, err error)
+
+type entry struct {
+	body      []byte
+	etag      string
+	expiresAt time.Time
+}
+
+// Configs builds documents of projects and keeps them for ttl, trackers poll on every page load
+type Configs struct {
+	defaults Document
+	load     Loader
+	ttl      time.Duration
+	mutex    sync.Mutex
+	cache    map[string]*entry // documents by project key, trackers know only the key
+}
+
+func New(defaults Document, load Loader, ttl time.Duration) (*Configs, error) {
+	if load == nil {
+		return nil, fmt.Errorf("loader is empty")
+	}
+	defaults.Version = SchemaVersion
+	return &Configs{
+		defaults: defaults,
+		load:     load,
+		ttl:      ttl,
+		cache:    make(map[string]*entry),
+	}, nil
+}
+
+// Get returns the encoded document of the project and its etag
+func (c *Configs) Get(projectKey string) ([]byte, string, error) {
+	c.mutex.Lock()
+	e, ok := c.cache[projectKey]
+	c.mutex.Unlock()
+	if ok && time.Now().Before(e.expiresAt) {
+		return e.body, e.etag, nil
+	}
+
+	e, err := c.build(projectKey)
+	if err != nil {
+		return nil, "", err
+	}
+	c.mutex.Lock()
+	c.cache[projectKey] = e
+	c.mutex.Unlock()
+	return e.body, e.etag, nil
+}
+
+// build applies project overrides over the defaults, overrides may contain only changed fields
+func (c *Configs) build(projectKey string) (*entry, error) {
+	captureRate, overrides, err := c.load(projectKey)
+	if err != nil {
+		return nil, err
+	}
+	doc := c.defaults
+	doc.CaptureRate = captureRate
+	if len(overrides) > 0 {
+		if err := json.Unmarshal(overrides, &doc); err != nil {
+			return nil, fmt.Errorf("wrong config overrides: %s", err)
+		}
+	}
+	doc.Version = SchemaVersion
+	if doc.CaptureRate < 0 || doc.CaptureRate > 100 {
+		return nil, fmt.Errorf("wrong capture rate: %d", doc.CaptureRate)
+	}
+	body, err := json.Marshal(&doc)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(body)
+	return &entry{
+		body:      body,
+		etag:      `"` + hex.EncodeToString(hash[:16]) + `"`,
+		expiresAt: time.Now().Add(c.ttl),
+	}, nil
+}
@@ -0,0 +1,134 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gorilla/mux"
+	gzip "github.com/klauspost/pgzip"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ingestPrefix is the second path of ingest endpoints, limits of BODY_SIZE_LIMITS are set by paths without it
+const ingestPrefix = "/ingest"
+
+var errBodyTooLarge = errors.New("request body is too large")
+
+// limitedReader fails with errBodyTooLarge after the limit, io.LimitReader would cut the body silently
+type limitedReader struct {
+	reader io.Reader
+	left   int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.left+1 {
+		p = p[:l.left+1]
+	}
+	n, err := l.reader.Read(p)
+	l.left -= int64(n)
+	if l.left < 0 {
+		return n, errBodyTooLarge
+	}
+	return n, err
+}
+
+// parseBodyLimits parses limits of endpoints (path=bytes), paths are route templates, e.g. /v1/cdp/{projectKey}
+func parseBodyLimits(list []string) (map[string]int64, error) {
+	limits := make(map[string]int64, len(list))
+	for _, item := range list {
+		path, value, ok := strings.Cut(item, "=")
+		path, value = strings.TrimSpace(path), strings.TrimSpace(value)
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if !ok || !strings.HasPrefix(path, "/") || err != nil || limit <= 0 {
+			return nil, fmt.Errorf("wrong body size limit: %s", item)
+		}
+		limits[strings.TrimPrefix(path, ingestPrefix)] = limit
+	}
+	return limits, nil
+}
+
+// bodyLimit returns the limit of the endpoint from BODY_SIZE_LIMITS or the default one
+func (e *Router) bodyLimit(r *http.Request, limit int64) int64 {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return limit
+	}
+	path, err := route.GetPathTemplate()
+	if err != nil {
+		return limit
+	}
+	if custom, ok := e.bodyLimits[strings.TrimPrefix(path, ingestPrefix)]; ok {
+		return custom
+	}
+	return limit
+}
+
+func bodyTooLarge(limit int64) error {
+	return newRequestError(http.StatusRequestEntityTooLarge, fmt.Errorf("request body is larger than %d bytes", limit))
+}
+
+// decodeBody decompresses the body by its Content-Encoding, trackers compress beacons with gzip or brotli
+func decodeBody(body io.Reader, encoding string) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return io.NopCloser(body), nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(body)
+	case "br":
+		return io.NopCloser(brotli.NewReader(body)), nil
+	}
+	return nil, newRequestError(http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content encoding: %s", encoding))
+}
+
+// readBody reads the body decompressed by its Content-Encoding. The limit of the endpoint applies to both
+// the compressed and the decompressed body, so small compressed bodies can't expand to huge ones. Errors are
+// request errors: 413 above the limit, 415 for unknown encodings and 400 for broken bodies.
+func (e *Router) readBody(w http.ResponseWriter, r *http.Request, limit int64) ([]byte, error) {
+	limit = e.bodyLimit(r, limit)
+	if r.ContentLength > limit {
+		return nil, bodyTooLarge(limit)
+	}
+	defer func() {
+		if closeErr := r.Body.Close(); closeErr != nil {
+			log.Printf("error while closing request body: %s", closeErr)
+		}
+	}()
+
+	encoded := &limitedReader{reader: r.Body, left: limit}
+	reader, err := decodeBody(encoded, r.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, bodyError(err, encoded, limit)
+	}
+	bodyBytes, err := io.ReadAll(&limitedReader{reader: reader, left: limit})
+	if closeErr := reader.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, bodyError(err, encoded, limit)
+	}
+
+	e.requestSize.Record(
+		r.Context(),
+		float64(len(bodyBytes)),
+		[]attribute.KeyValue{attribute.String("method", r.URL.Path)}...,
+	)
+	return bodyBytes, nil
+}
+
+// bodyError keeps request errors, bodies above the limit get 413 even if the decompressor hides the error
+func bodyError(err error, encoded *limitedReader, limit int64) error {
+	var reqErr *requestError
+	switch {
+	case errors.As(err, &reqErr):
+		return err
+	case encoded.left < 0 || errors.Is(err, errBodyTooLarge):
+		return bodyTooLarge(limit)
+	}
+	return newRequestError(http.StatusBadRequest, fmt.Errorf("can't read request body: %s", err))
+}
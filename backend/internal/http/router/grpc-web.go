@@ -0,0 +1,117 @@
+package router
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text" // base64 encoded frames
+	grpcFrameHeaderSize    = 5                           // flags byte and big endian length of the message
+	grpcTrailerFlag        = 0x80
+)
+
+// grpcWebHandler serves unary calls of gRPC-Web clients (browsers and SDKs without HTTP/2) on the HTTP server,
+// so they don't need a proxy in front of the gRPC port. Compressed messages aren't supported.
+func (e *Router) grpcWebHandler(w http.ResponseWriter, r *http.Request) {
+	contentType := r.Header.Get("Content-Type")
+	text := strings.HasPrefix(contentType, grpcWebTextContentType)
+	if !text && !strings.HasPrefix(contentType, grpcWebContentType) {
+		ResponseWithError(w, http.StatusUnsupportedMediaType, errors.New("gRPC-Web content type is expected"))
+		return
+	}
+	method := findIngestMethod(mux.Vars(r)["method"])
+	if method == nil {
+		writeGRPCWeb(w, text, nil, status.New(codes.Unimplemented, "unknown method"), nil)
+		return
+	}
+	if r.Body == nil {
+		writeGRPCWeb(w, text, nil, status.New(codes.InvalidArgument, "request body is empty"), nil)
+		return
+	}
+
+	// Limits of methods are checked after decoding, base64 makes bodies a third larger
+	limit := (e.cfg.BeaconSizeLimit+grpcFrameHeaderSize)*4/3 + 4
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		writeGRPCWeb(w, text, nil, status.New(codes.ResourceExhausted, err.Error()), nil)
+		return
+	}
+	if text {
+		if body, err = base64.StdEncoding.DecodeString(string(body)); err != nil {
+			writeGRPCWeb(w, text, nil, status.New(codes.InvalidArgument, err.Error()), nil)
+			return
+		}
+	}
+	message, err := readGRPCFrame(body)
+	if err != nil {
+		writeGRPCWeb(w, text, nil, status.New(codes.InvalidArgument, err.Error()), nil)
+		return
+	}
+
+	reply, err := e.callIngest(r, method, func(v interface{}) error {
+		return ingestCodec{}.Unmarshal(message, v)
+	})
+	if err != nil {
+		st, trailers := ingestStatus(err)
+		writeGRPCWeb(w, text, nil, st, trailers)
+		return
+	}
+	writeGRPCWeb(w, text, reply, status.New(codes.OK, ""), nil)
+}
+
+func readGRPCFrame(body []byte) ([]byte, error) {
+	if len(body) < grpcFrameHeaderSize {
+		return nil, errors.New("message frame is too short")
+	}
+	if body[0] != 0 {
+		return nil, errors.New("compressed messages aren't supported")
+	}
+	size := binary.BigEndian.Uint32(body[1:grpcFrameHeaderSize])
+	if uint64(size) > uint64(len(body)-grpcFrameHeaderSize) {
+		return nil, errors.New("message frame is incomplete")
+	}
+	return body[grpcFrameHeaderSize : grpcFrameHeaderSize+int(size)], nil
+}
+
+func appendGRPCFrame(buf []byte, flags byte, message []byte) []byte {
+	var header [grpcFrameHeaderSize]byte
+	header[0] = flags
+	binary.BigEndian.PutUint32(header[1:], uint32(len(message)))
+	return append(append(buf, header[:]...), message...)
+}
+
+// writeGRPCWeb writes the reply frame if there is a reply and the trailer frame with the status,
+// errors of calls are sent with the 200 HTTP status the same way as gRPC does
+func writeGRPCWeb(w http.ResponseWriter, text bool, reply protoReply, st *status.Status, trailers map[string]string) {
+	var body []byte
+	if reply != nil {
+		body = appendGRPCFrame(body, 0, reply.marshalProto())
+	}
+	var trailer strings.Builder
+	fmt.Fprintf(&trailer, "grpc-status: %d\r\ngrpc-message: %s\r\n", st.Code(), url.PathEscape(st.Message()))
+	for key, value := range trailers {
+		fmt.Fprintf(&trailer, "%s: %s\r\n", key, value)
+	}
+	body = appendGRPCFrame(body, grpcTrailerFlag, []byte(trailer.String()))
+
+	if text {
+		w.Header().Set("Content-Type", grpcWebTextContentType+"+proto")
+		body = []byte(base64.StdEncoding.EncodeToString(body))
+	} else {
+		w.Header().Set("Content-Type", grpcWebContentType+"+proto")
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
@@ -0,0 +1,283 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	_ "google.golang.org/grpc/encoding/gzip" // SDKs may compress batches with gzip
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	http3 "openreplay/backend/internal/config/http"
+)
+
+// The gRPC ingest (ingest.proto) shares the logic of the HTTP endpoints. Native and mobile SDKs send batches
+// over one multiplexed connection without headers of HTTP/1 requests and JSON of start requests.
+const ingestService = "openreplay.ingest.Ingest"
+
+type protoRequest interface {
+	unmarshalProto(data []byte) error
+}
+
+type protoReply interface {
+	marshalProto() []byte
+}
+
+// ingestRequest is decoded by ingestCodec, size is checked by the limit of the same HTTP endpoint
+type ingestRequest struct {
+	message protoRequest
+	limit   int64
+	size    int
+}
+
+// ingestCodec is forced on the ingest server, all its models are encoded by hand
+type ingestCodec struct{}
+
+func (ingestCodec) Marshal(v interface{}) ([]byte, error) {
+	reply, ok := v.(protoReply)
+	if !ok {
+		return nil, fmt.Errorf("can't encode %T", v)
+	}
+	return reply.marshalProto(), nil
+}
+
+func (ingestCodec) Unmarshal(data []byte, v interface{}) error {
+	req, ok := v.(*ingestRequest)
+	if !ok {
+		return fmt.Errorf("can't decode %T", v)
+	}
+	req.size = len(data)
+	if int64(req.size) > req.limit {
+		return errors.New("request is too large")
+	}
+	return req.message.unmarshalProto(data)
+}
+
+func (ingestCodec) Name() string {
+	return "proto"
+}
+
+type ingestMethod struct {
+	name    string
+	limit   func(cfg *http3.Config) int64
+	request func() protoRequest
+	call    func(e *Router, r *http.Request, req protoRequest) (protoReply, error)
+}
+
+func (m *ingestMethod) path() string {
+	return "/" + ingestService + "/" + m.name
+}
+
+var ingestMethods = []*ingestMethod{
+	{
+		name:    "StartWeb",
+		limit:   func(cfg *http3.Config) int64 { return cfg.JsonSizeLimit },
+		request: func() protoRequest { return &StartSessionRequest{} },
+		call: func(e *Router, r *http.Request, req protoRequest) (protoReply, error) {
+			res, err := e.startSessionWeb(r, req.(*StartSessionRequest))
+			if err != nil {
+				return nil, err
+			}
+			return res, nil
+		},
+	},
+	{
+		name:    "PushWeb",
+		limit:   func(cfg *http3.Config) int64 { return cfg.BeaconSizeLimit },
+		request: func() protoRequest { return &ingestBatch{} },
+		call: func(e *Router, r *http.Request, req protoRequest) (protoReply, error) {
+			return e.pushCall(r, e.cfg.TopicRawWeb, req.(*ingestBatch))
+		},
+	},
+	{
+		name:    "StartIOS",
+		limit:   func(cfg *http3.Config) int64 { return cfg.JsonSizeLimit },
+		request: func() protoRequest { return &StartIOSSessionRequest{} },
+		call: func(e *Router, r *http.Request, req protoRequest) (protoReply, error) {
+			res, err := e.startSessionIOS(r, req.(*StartIOSSessionRequest))
+			if err != nil {
+				return nil, err
+			}
+			return res, nil
+		},
+	},
+	{
+		name:    "PushIOS",
+		limit:   func(cfg *http3.Config) int64 { return cfg.BeaconSizeLimit },
+		request: func() protoRequest { return &ingestBatch{} },
+		call: func(e *Router, r *http.Request, req protoRequest) (protoReply, error) {
+			return e.pushCall(r, e.cfg.TopicRawIOS, req.(*ingestBatch))
+		},
+	},
+}
+
+func findIngestMethod(name string) *ingestMethod {
+	for _, method := range ingestMethods {
+		if method.name == name {
+			return method
+		}
+	}
+	return nil
+}
+
+// pushCall checks the session token of the call the same way as HTTP handlers do
+func (e *Router) pushCall(r *http.Request, topicName string, batch *ingestBatch) (protoReply, error) {
+	sessionData, err := e.services.Tokenizer.ParseFromHTTPRequest(r)
+	if err != nil {
+		return nil, newRequestError(http.StatusUnauthorized, err)
+	}
+	if len(batch.data) == 0 {
+		return nil, newRequestError(http.StatusBadRequest, errors.New("batch is empty"))
+	}
+	if err := e.pushBatch(sessionData.ID, topicName, batch.data, batch.proto); err != nil {
+		return nil, err
+	}
+	return pushReply{}, nil
+}
+
+// callIngest decodes the request of the method and calls its logic, r has headers and the address of the client
+func (e *Router) callIngest(r *http.Request, method *ingestMethod, decode func(interface{}) error) (protoReply, error) {
+	req := &ingestRequest{message: method.request(), limit: method.limit(e.cfg)}
+	if err := decode(req); err != nil {
+		if int64(req.size) > req.limit {
+			return nil, newRequestError(http.StatusRequestEntityTooLarge, err)
+		}
+		return nil, newRequestError(http.StatusBadRequest, err)
+	}
+	e.requestSize.Record(r.Context(), float64(req.size), attribute.String("method", method.path()))
+	return method.call(e, r, req.message)
+}
+
+// clientRequest keeps metadata and the address of the gRPC client, so the logic shared with HTTP handlers reads
+// the user agent, the address for geoip and bot checks and the session token from the same headers
+func clientRequest(ctx context.Context) *http.Request {
+	r := &http.Request{Header: make(http.Header)}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for key, values := range md {
+			if strings.HasPrefix(key, ":") || strings.HasSuffix(key, "-bin") {
+				continue
+			}
+			for _, value := range values {
+				r.Header.Add(key, value)
+			}
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		r.RemoteAddr = p.Addr.String()
+	}
+	return r.WithContext(ctx)
+}
+
+// ingestStatus returns the status with the closest code and trailers of the error
+func ingestStatus(err error) (*status.Status, map[string]string) {
+	reqErr, ok := err.(*requestError)
+	if !ok {
+		return status.New(codes.Internal, err.Error()), nil
+	}
+	var trailers map[string]string
+	if reqErr.hint != nil {
+		trailers = hintTrailers(reqErr.hint)
+	}
+	return status.New(grpcCode(reqErr.status), reqErr.Error()), trailers
+}
+
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusRequestEntityTooLarge:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	}
+	return codes.Internal
+}
+
+// grpcHandler passes the call through the interceptor the same way as generated code,
+// the request is decoded only if the interceptor lets the call in
+func grpcHandler(method *ingestMethod) func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		call := func(ctx context.Context, _ interface{}) (interface{}, error) {
+			reply, err := srv.(*Router).callIngest(clientRequest(ctx), method, dec)
+			if err != nil {
+				st, trailers := ingestStatus(err)
+				if trailers != nil {
+					grpc.SetTrailer(ctx, metadata.New(trailers))
+				}
+				return nil, st.Err()
+			}
+			return reply, nil
+		}
+		if interceptor == nil {
+			return call(ctx, nil)
+		}
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: method.path()}
+		return interceptor(ctx, nil, info, call)
+	}
+}
+
+func ingestServiceDesc() *grpc.ServiceDesc {
+	desc := &grpc.ServiceDesc{
+		ServiceName: ingestService,
+		HandlerType: (*interface{})(nil),
+		Streams:     []grpc.StreamDesc{},
+		Metadata:    "ingest.proto",
+	}
+	for _, method := range ingestMethods {
+		desc.Methods = append(desc.Methods, grpc.MethodDesc{
+			MethodName: method.name,
+			Handler:    grpcHandler(method),
+		})
+	}
+	return desc
+}
+
+// grpcMetrics records calls to the same metrics as HTTP requests, the method is the full name of the call
+func (e *Router) grpcMetrics(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	requestStart := time.Now()
+	reply, err := handler(ctx, req)
+	e.totalRequests.Add(context.Background(), 1)
+	e.requestDuration.Record(context.Background(),
+		float64(time.Now().Sub(requestStart).Milliseconds()),
+		attribute.String("method", info.FullMethod),
+	)
+	return reply, err
+}
+
+// ServeGRPC starts the gRPC ingest server in the background, interceptors run after the metrics one
+// (e.g. the check of the control state). gRPC-Web calls are served by the HTTP router.
+func (e *Router) ServeGRPC(addr string, interceptors ...grpc.UnaryServerInterceptor) (*grpc.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("can't listen on %s: %s", addr, err)
+	}
+	server := grpc.NewServer(
+		grpc.ForceServerCodec(ingestCodec{}),
+		grpc.MaxRecvMsgSize(int(e.cfg.BeaconSizeLimit)),
+		grpc.ChainUnaryInterceptor(append([]grpc.UnaryServerInterceptor{e.grpcMetrics}, interceptors...)...),
+	)
+	server.RegisterService(ingestServiceDesc(), e)
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Printf("grpc ingest server stopped: %s", err)
+		}
+	}()
+	log.Printf("gRPC ingest server running on %s", addr)
+	return server, nil
+}
@@ -0,0 +1,84 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"openreplay/backend/internal/http/assist"
+	"openreplay/backend/internal/http/overload"
+	"openreplay/backend/pkg/queue/types"
+)
+
+type assistEventsResponse struct {
+	Accepted int `json:"accepted"`
+}
+
+// assistEventsHandler records control events of the agent connected to the live session via assist.
+// They are produced to the raw stream of the session, so the sink merges them into the recording
+// and the replay shows which agent did what.
+func (e *Router) assistEventsHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := e.services.JWTValidator.ParseFromHTTPRequest(r)
+	if err != nil {
+		ResponseWithError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if r.Body == nil {
+		ResponseWithError(w, http.StatusBadRequest, errors.New("request body is empty"))
+		return
+	}
+	bodyBytes, err := e.readBody(w, r, e.cfg.AssistSizeLimit)
+	if err != nil {
+		log.Printf("error while reading request body: %s", err)
+		ResponseWithRequestError(w, err)
+		return
+	}
+	batch, err := assist.ParseBody(bodyBytes)
+	if err != nil {
+		ResponseWithError(w, http.StatusBadRequest, err)
+		return
+	}
+	sessionID, err := strconv.ParseUint(batch.SessionID, 10, 64)
+	if err != nil {
+		ResponseWithError(w, http.StatusBadRequest, fmt.Errorf("wrong session id: %s", err))
+		return
+	}
+
+	hasAccess, err := e.services.Database.HasSessionAccess(user.UserID, user.TenantID, sessionID)
+	if err != nil {
+		log.Printf("can't check session access, userID: %d, sessID: %d, err: %s", user.UserID, sessionID, err)
+		ResponseWithError(w, http.StatusInternalServerError, errors.New("can't check session access"))
+		return
+	}
+	if !hasAccess {
+		ResponseWithError(w, http.StatusForbidden, errors.New("access denied"))
+		return
+	}
+	// Recording of the ended session is already uploaded, late events would be lost anyway
+	sess, err := e.services.Database.Conn.GetSession(sessionID)
+	if err != nil {
+		log.Printf("can't get session, sessID: %d, err: %s", sessionID, err)
+		ResponseWithError(w, http.StatusInternalServerError, errors.New("can't get session"))
+		return
+	}
+	if sess.Duration != nil {
+		ResponseWithError(w, http.StatusConflict, errors.New("session has ended"))
+		return
+	}
+
+	if err := e.services.Producer.Produce(e.cfg.TopicRawWeb, sessionID, assist.ToBatch(user.UserID, batch, time.Now())); err != nil {
+		if errors.Is(err, types.ErrBackpressure) {
+			ResponseWithOverload(w, e.hints.Issue(sessionID, overload.ReasonBackpressure))
+			return
+		}
+		log.Printf("can't send assist events, sessID: %d, err: %s", sessionID, err)
+		ResponseWithError(w, http.StatusInternalServerError, errors.New("can't save events"))
+		return
+	}
+	log.Printf("assist events: userID: %d, sessID: %d, events: %d", user.UserID, sessionID, len(batch.Events))
+	ResponseWithJSON(w, &assistEventsResponse{Accepted: len(batch.Events)})
+}
@@ -0,0 +1,83 @@
+package router
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"openreplay/backend/internal/http/attributes"
+)
+
+type userAttributesResponse struct {
+	Imported int      `json:"imported"`
+	Unknown  []string `json:"unknown,omitempty"` // attributes without a project metadata key, they are saved but not applied
+}
+
+// importUserAttributesHandler saves user attributes from CSV, they are added to the session metadata
+// when the user is identified, so sessions can be segmented by business attributes without tracker changes
+func (e *Router) importUserAttributesHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := e.services.JWTValidator.ParseFromHTTPRequest(r)
+	if err != nil {
+		ResponseWithError(w, http.StatusUnauthorized, err)
+		return
+	}
+	projectID, err := strconv.ParseUint(mux.Vars(r)["projectID"], 10, 32)
+	if err != nil {
+		ResponseWithError(w, http.StatusBadRequest, errors.New("wrong project id"))
+		return
+	}
+
+	if r.Body == nil {
+		ResponseWithError(w, http.StatusBadRequest, errors.New("request body is empty"))
+		return
+	}
+	bodyBytes, err := e.readBody(w, r, e.cfg.AttrsSizeLimit)
+	if err != nil {
+		log.Printf("error while reading request body: %s", err)
+		ResponseWithRequestError(w, err)
+		return
+	}
+	users, err := attributes.ParseCSV(bytes.NewReader(bodyBytes), e.cfg.AttrsRowsLimit)
+	if err != nil {
+		ResponseWithError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	hasAccess, err := e.services.Database.HasProjectAccess(user.UserID, user.TenantID, uint32(projectID))
+	if err != nil {
+		log.Printf("can't check project access, userID: %d, projID: %d, err: %s", user.UserID, projectID, err)
+		ResponseWithError(w, http.StatusInternalServerError, errors.New("can't check project access"))
+		return
+	}
+	if !hasAccess {
+		ResponseWithError(w, http.StatusForbidden, errors.New("access denied"))
+		return
+	}
+	project, err := e.services.Database.Conn.GetProject(uint32(projectID))
+	if err != nil {
+		log.Printf("can't get project, projID: %d, err: %s", projectID, err)
+		ResponseWithError(w, http.StatusInternalServerError, errors.New("can't get project"))
+		return
+	}
+
+	if err := e.services.Database.Conn.UpsertUserAttributes(project.ProjectID, users); err != nil {
+		log.Printf("can't import user attributes, projID: %d, err: %s", projectID, err)
+		ResponseWithError(w, http.StatusInternalServerError, errors.New("can't import user attributes"))
+		return
+	}
+	res := &userAttributesResponse{Imported: len(users)}
+	unknown := make(map[string]bool)
+	for _, attrs := range users {
+		for key := range attrs {
+			if !unknown[key] && project.GetMetadataNo(key) == 0 {
+				unknown[key] = true
+				res.Unknown = append(res.Unknown, key)
+			}
+		}
+	}
+	ResponseWithJSON(w, res)
+}
@@ -0,0 +1,100 @@
+package router
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"openreplay/backend/internal/http/cdp"
+	"openreplay/backend/internal/http/overload"
+	"openreplay/backend/pkg/messages"
+	"openreplay/backend/pkg/queue/types"
+)
+
+type cdpWebhookResponse struct {
+	Accepted int `json:"accepted"`
+	Skipped  int `json:"skipped"` // events without a matching session or with unsupported type
+}
+
+// cdpWebhookHandler maps track and identify calls of customer event pipelines
+// to custom events and user identities of recorded sessions
+func (e *Router) cdpWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Body == nil {
+		ResponseWithError(w, http.StatusBadRequest, errors.New("request body is empty"))
+		return
+	}
+	bodyBytes, err := e.readBody(w, r, e.cfg.CDPSizeLimit)
+	if err != nil {
+		log.Printf("error while reading request body: %s", err)
+		ResponseWithRequestError(w, err)
+		return
+	}
+	if !cdp.Authorize(e.cfg.CDPSecret, r.Header.Get("Authorization"), r.Header.Get("X-Signature"), bodyBytes) {
+		ResponseWithError(w, http.StatusUnauthorized, errors.New("wrong secret or signature"))
+		return
+	}
+	project, err := e.services.Database.GetProjectByKey(mux.Vars(r)["projectKey"])
+	if err != nil || project == nil {
+		ResponseWithError(w, http.StatusNotFound, errors.New("project doesn't exist"))
+		return
+	}
+	events, err := cdp.ParseBody(bodyBytes)
+	if err != nil {
+		ResponseWithError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	res := &cdpWebhookResponse{}
+	for _, event := range events {
+		msgs, err := cdp.ToMessages(event)
+		if err != nil {
+			res.Skipped++
+			continue
+		}
+		sessionID, err := e.cdpSessionID(project.ProjectID, event)
+		if err != nil {
+			res.Skipped++
+			continue
+		}
+		// Session reference comes from the customer, so it has to belong to the project
+		if sess, err := e.services.Database.Conn.GetSession(sessionID); err != nil || sess.ProjectID != project.ProjectID {
+			res.Skipped++
+			continue
+		}
+		for _, msg := range msgs {
+			if err := e.services.Producer.Produce(e.cfg.TopicAnalytics, sessionID, messages.Encode(msg)); err != nil {
+				if errors.Is(err, types.ErrBackpressure) {
+					ResponseWithOverload(w, e.hints.IssueProject(project.ProjectID, overload.ReasonBackpressure))
+					return
+				}
+				log.Printf("can't send cdp event, sessID: %d, err: %s", sessionID, err)
+				ResponseWithError(w, http.StatusInternalServerError, errors.New("can't save event"))
+				return
+			}
+		}
+		res.Accepted++
+	}
+	ResponseWithJSON(w, res)
+}
+
+// cdpSessionID uses the session reference of the event or finds the session by user ids
+func (e *Router) cdpSessionID(projectID uint32, event *cdp.Event) (uint64, error) {
+	ref := event.Ref()
+	if ref.SessionToken != "" {
+		data, err := e.services.Tokenizer.Parse(ref.SessionToken)
+		if data == nil {
+			return 0, err
+		}
+		return data.ID, nil // expired token still points to the right session
+	}
+	if ref.SessionID != "" {
+		return strconv.ParseUint(ref.SessionID, 10, 64)
+	}
+	if event.UserID == "" && event.AnonymousID == "" {
+		return 0, errors.New("session reference and user ids are empty")
+	}
+	return e.services.Database.GetUserSessionAt(projectID, event.UserID, event.AnonymousID, event.Time().UnixMilli())
+}
@@ -0,0 +1,45 @@
+package router
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"openreplay/backend/internal/http/remoteconfig"
+)
+
+// trackerConfigHandler serves the remote configuration of the project. Trackers poll it on every page load,
+// unchanged documents are answered with 304 by the etag of the cached one.
+func (e *Router) trackerConfigHandler(w http.ResponseWriter, r *http.Request) {
+	body, etag, err := e.services.RemoteConfigs.Get(mux.Vars(r)["projectKey"])
+	if errors.Is(err, remoteconfig.ErrUnknownProject) {
+		ResponseWithError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		log.Printf("can't get tracker config: %s", err)
+		ResponseWithError(w, http.StatusInternalServerError, errors.New("can't get config"))
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache") // cached documents have to be revalidated
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+func etagMatches(header, etag string) bool {
+	for _, value := range strings.Split(header, ",") {
+		value = strings.TrimPrefix(strings.TrimSpace(value), "W/")
+		if value == etag || value == "*" {
+			return true
+		}
+	}
+	return false
+}
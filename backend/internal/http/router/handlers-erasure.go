@@ -0,0 +1,111 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"openreplay/backend/pkg/db/postgres"
+)
+
+type erasureRequest struct {
+	UserID      string `json:"userId"`
+	AnonymousID string `json:"anonymousId"`
+}
+
+type erasureResponse struct {
+	ErasureID uint64 `json:"erasureId"`
+	Status    string `json:"status"`
+}
+
+// projectAccess checks the dashboard user and its access to the project of the request path, the error is already
+// written to the response if the returned project id is 0
+func (e *Router) projectAccess(w http.ResponseWriter, r *http.Request) (uint64, uint32) {
+	user, err := e.services.JWTValidator.ParseFromHTTPRequest(r)
+	if err != nil {
+		ResponseWithError(w, http.StatusUnauthorized, err)
+		return 0, 0
+	}
+	projectID, err := strconv.ParseUint(mux.Vars(r)["projectID"], 10, 32)
+	if err != nil || projectID == 0 {
+		ResponseWithError(w, http.StatusBadRequest, errors.New("wrong project id"))
+		return 0, 0
+	}
+	hasAccess, err := e.services.Database.HasProjectAccess(user.UserID, user.TenantID, uint32(projectID))
+	if err != nil {
+		log.Printf("can't check project access, userID: %d, projID: %d, err: %s", user.UserID, projectID, err)
+		ResponseWithError(w, http.StatusInternalServerError, errors.New("can't check project access"))
+		return 0, 0
+	}
+	if !hasAccess {
+		ResponseWithError(w, http.StatusForbidden, errors.New("access denied"))
+		return 0, 0
+	}
+	return user.UserID, uint32(projectID)
+}
+
+// createErasureHandler registers the request to erase all sessions of the user, they are deleted from all stores
+// by the retention service and the deletion report is saved with the request
+func (e *Router) createErasureHandler(w http.ResponseWriter, r *http.Request) {
+	userID, projectID := e.projectAccess(w, r)
+	if projectID == 0 {
+		return
+	}
+	if r.Body == nil {
+		ResponseWithError(w, http.StatusBadRequest, errors.New("request body is empty"))
+		return
+	}
+	bodyBytes, err := e.readBody(w, r, e.cfg.JsonSizeLimit)
+	if err != nil {
+		log.Printf("error while reading request body: %s", err)
+		ResponseWithRequestError(w, err)
+		return
+	}
+	req := &erasureRequest{}
+	if err := json.Unmarshal(bodyBytes, req); err != nil {
+		ResponseWithError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.UserID == "" && req.AnonymousID == "" {
+		ResponseWithError(w, http.StatusBadRequest, errors.New("userId or anonymousId is required"))
+		return
+	}
+
+	erasureID, err := e.services.Database.Conn.InsertErasureRequest(projectID, req.UserID, req.AnonymousID, userID)
+	if err != nil {
+		log.Printf("can't save erasure request, projID: %d, err: %s", projectID, err)
+		ResponseWithError(w, http.StatusInternalServerError, errors.New("can't save erasure request"))
+		return
+	}
+	log.Printf("erasure %d of project %d is requested by user %d", erasureID, projectID, userID)
+	w.WriteHeader(http.StatusAccepted)
+	ResponseWithJSON(w, &erasureResponse{ErasureID: erasureID, Status: postgres.ErasurePending})
+}
+
+// getErasureHandler returns the status of the erasure request with the deletion report
+func (e *Router) getErasureHandler(w http.ResponseWriter, r *http.Request) {
+	_, projectID := e.projectAccess(w, r)
+	if projectID == 0 {
+		return
+	}
+	erasureID, err := strconv.ParseUint(mux.Vars(r)["erasureID"], 10, 64)
+	if err != nil {
+		ResponseWithError(w, http.StatusBadRequest, errors.New("wrong erasure id"))
+		return
+	}
+	req, err := e.services.Database.Conn.GetErasureRequest(projectID, erasureID)
+	if err != nil {
+		log.Printf("can't get erasure request, projID: %d, erasureID: %d, err: %s", projectID, erasureID, err)
+		ResponseWithError(w, http.StatusInternalServerError, errors.New("can't get erasure request"))
+		return
+	}
+	if req == nil {
+		ResponseWithError(w, http.StatusNotFound, errors.New("erasure request not found"))
+		return
+	}
+	ResponseWithJSON(w, req)
+}
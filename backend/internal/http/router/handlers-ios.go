@@ -18,34 +18,47 @@ import (
 )
 
 func (e *Router) startSessionHandlerIOS(w http.ResponseWriter, r *http.Request) {
-	startTime := time.Now()
 	req := &StartIOSSessionRequest{}
 
 	if r.Body == nil {
 		ResponseWithError(w, http.StatusBadRequest, errors.New("request body is empty"))
 		return
 	}
-	body := http.MaxBytesReader(w, r.Body, e.cfg.JsonSizeLimit)
-	defer body.Close()
+	bodyBytes, err := e.readBody(w, r, e.cfg.JsonSizeLimit)
+	if err != nil {
+		log.Printf("error while reading request body: %s", err)
+		ResponseWithRequestError(w, err)
+		return
+	}
 
-	if err := json.NewDecoder(body).Decode(req); err != nil {
+	if err := json.Unmarshal(bodyBytes, req); err != nil {
 		ResponseWithError(w, http.StatusBadRequest, err)
 		return
 	}
 
-	if req.ProjectKey == nil {
-		ResponseWithError(w, http.StatusForbidden, errors.New("ProjectKey value required"))
+	res, err := e.startSessionIOS(r, req)
+	if err != nil {
+		ResponseWithRequestError(w, err)
 		return
 	}
+	ResponseWithJSON(w, res)
+}
+
+// startSessionIOS is the logic of the start request shared by the HTTP and gRPC endpoints,
+// r is used only for headers and the address of the client
+func (e *Router) startSessionIOS(r *http.Request, req *StartIOSSessionRequest) (*StartIOSSessionResponse, error) {
+	startTime := time.Now()
+
+	if req.ProjectKey == nil {
+		return nil, newRequestError(http.StatusForbidden, errors.New("ProjectKey value required"))
+	}
 
 	p, err := e.services.Database.GetProjectByKey(*req.ProjectKey)
 	if err != nil {
 		if postgres.IsNoRowsErr(err) {
-			ResponseWithError(w, http.StatusNotFound, errors.New("Project doesn't exist or is not active"))
-		} else {
-			ResponseWithError(w, http.StatusInternalServerError, err) // TODO: send error here only on staging
+			return nil, newRequestError(http.StatusNotFound, errors.New("Project doesn't exist or is not active"))
 		}
-		return
+		return nil, newRequestError(http.StatusInternalServerError, err) // TODO: send error here only on staging
 	}
 	userUUID := uuid.GetUUID(req.UserUUID)
 	tokenData, err := e.services.Tokenizer.Parse(req.Token)
@@ -53,19 +66,16 @@ func (e *Router) startSessionHandlerIOS(w http.ResponseWriter, r *http.Request)
 	if err != nil { // Starting the new one
 		dice := byte(rand.Intn(100)) // [0, 100)
 		if dice >= p.SampleRate {
-			ResponseWithError(w, http.StatusForbidden, errors.New("cancel"))
-			return
+			return nil, newRequestError(http.StatusForbidden, errors.New("cancel"))
 		}
 
 		ua := e.services.UaParser.ParseFromHTTPRequest(r)
 		if ua == nil {
-			ResponseWithError(w, http.StatusForbidden, errors.New("browser not recognized"))
-			return
+			return nil, newRequestError(http.StatusForbidden, errors.New("browser not recognized"))
 		}
 		sessionID, err := e.services.Flaker.Compose(uint64(startTime.UnixMilli()))
 		if err != nil {
-			ResponseWithError(w, http.StatusInternalServerError, err)
-			return
+			return nil, newRequestError(http.StatusInternalServerError, err)
 		}
 		// TODO: if EXPIRED => send message for two sessions association
 		expTime := startTime.Add(time.Duration(p.MaxSessionDuration) * time.Millisecond)
@@ -88,12 +98,12 @@ func (e *Router) startSessionHandlerIOS(w http.ResponseWriter, r *http.Request)
 		}))
 	}
 
-	ResponseWithJSON(w, &StartIOSSessionResponse{
+	return &StartIOSSessionResponse{
 		Token:           e.services.Tokenizer.Compose(*tokenData),
 		UserUUID:        userUUID,
 		SessionID:       strconv.FormatUint(tokenData.ID, 10),
 		BeaconSizeLimit: e.cfg.BeaconSizeLimit,
-	})
+	}, nil
 }
 
 func (e *Router) pushMessagesHandlerIOS(w http.ResponseWriter, r *http.Request) {
@@ -128,7 +138,13 @@ func (e *Router) imagesUploadHandlerIOS(w http.ResponseWriter, r *http.Request)
 		ResponseWithError(w, http.StatusBadRequest, errors.New("request body is empty"))
 		return
 	}
-	r.Body = http.MaxBytesReader(w, r.Body, e.cfg.FileSizeLimit)
+	// Images are already compressed, multipart bodies are limited without decompression
+	limit := e.bodyLimit(r, e.cfg.FileSizeLimit)
+	if r.ContentLength > limit {
+		ResponseWithRequestError(w, bodyTooLarge(limit))
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
 	defer r.Body.Close()
 
 	err = r.ParseMultipartForm(1e6) // ~1Mb
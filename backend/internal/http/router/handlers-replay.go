@@ -0,0 +1,227 @@
+package router
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"openreplay/backend/pkg/dictionaries"
+	"openreplay/backend/pkg/storage"
+	"openreplay/backend/pkg/url/assets"
+)
+
+const maxReplayAssets = 500
+
+// replayURLsHandler returns short-lived signed urls for session recording files and requested assets.
+// All replay access goes through this handler, so every request is logged for audit.
+func (e *Router) replayURLsHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := e.services.JWTValidator.ParseFromHTTPRequest(r)
+	if err != nil {
+		ResponseWithError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if r.Body == nil {
+		ResponseWithError(w, http.StatusBadRequest, errors.New("request body is empty"))
+		return
+	}
+	bodyBytes, err := e.readBody(w, r, e.cfg.ReplaySizeLimit)
+	if err != nil {
+		log.Printf("error while reading request body: %s", err)
+		ResponseWithRequestError(w, err)
+		return
+	}
+	req := &ReplayURLsRequest{}
+	if err := json.Unmarshal(bodyBytes, req); err != nil {
+		ResponseWithError(w, http.StatusBadRequest, err)
+		return
+	}
+	sessionID, err := strconv.ParseUint(req.SessionID, 10, 64)
+	if err != nil {
+		ResponseWithError(w, http.StatusBadRequest, fmt.Errorf("wrong session id: %s", err))
+		return
+	}
+	if len(req.Assets) > maxReplayAssets {
+		ResponseWithError(w, http.StatusBadRequest, fmt.Errorf("too many assets, max: %d", maxReplayAssets))
+		return
+	}
+
+	hasAccess, err := e.services.Database.HasSessionAccess(user.UserID, user.TenantID, sessionID)
+	if err != nil {
+		log.Printf("can't check session access, userID: %d, sessID: %d, err: %s", user.UserID, sessionID, err)
+		ResponseWithError(w, http.StatusInternalServerError, errors.New("can't check session access"))
+		return
+	}
+	log.Printf("replay access: userID: %d, tenantID: %d, sessID: %d, granted: %t, assets: %d",
+		user.UserID, user.TenantID, sessionID, hasAccess, len(req.Assets))
+	if !hasAccess {
+		ResponseWithError(w, http.StatusForbidden, errors.New("access denied"))
+		return
+	}
+
+	ttl := e.cfg.ReplayURLTimeout
+	res := &ReplayURLsResponse{ExpiresAt: time.Now().Add(ttl).UnixMilli()}
+	// Recording is split into two files, the second one exists only for big sessions.
+	// Active sessions have only chunks uploaded by the storage service so far.
+	sessionKey := strconv.FormatUint(sessionID, 10)
+	keys := []string{sessionKey, sessionKey + "e"}
+	if !e.isReplayCached(sessionKey) && !e.services.SessionStorage.Exists(sessionKey) {
+		liveKeys, err := e.liveChunkKeys(sessionKey)
+		if err != nil {
+			log.Printf("can't list live chunks, sessID: %d, err: %s", sessionID, err)
+		} else if len(liveKeys) > 0 {
+			keys, res.Live = liveKeys, true
+		}
+	}
+	for i, key := range keys {
+		// Recently uploaded files are served by this service
+		if !res.Live && e.isReplayCached(key) {
+			res.Mobs = append(res.Mobs, e.replayFileURL(key, res.ExpiresAt))
+			continue
+		}
+		if !res.Live && i > 0 && !e.services.SessionStorage.Exists(key) {
+			continue
+		}
+		// Files could be compressed with the project dictionary, the player reads only gzip
+		if !res.Live && e.services.Dictionaries != nil {
+			res.Mobs = append(res.Mobs, e.replayFileURL(key, res.ExpiresAt))
+			continue
+		}
+		url, err := e.services.SessionStorage.GetPresignedURL(key, ttl)
+		if err != nil {
+			log.Printf("can't sign session url, sessID: %d, err: %s", sessionID, err)
+			ResponseWithError(w, http.StatusInternalServerError, errors.New("can't sign session url"))
+			return
+		}
+		res.Mobs = append(res.Mobs, url)
+	}
+	if len(req.Assets) > 0 {
+		res.Assets = make(map[string]string, len(req.Assets))
+	}
+	for _, asset := range req.Assets {
+		// Only paths generated by the cacher for this session are signed
+		if !assets.IsSessionAssetPath(sessionID, asset) {
+			continue
+		}
+		url, err := e.services.AssetsStorage.GetPresignedURL(asset, ttl)
+		if err != nil {
+			log.Printf("can't sign asset url, sessID: %d, err: %s", sessionID, err)
+			continue
+		}
+		res.Assets[asset] = url
+	}
+	ResponseWithJSON(w, res)
+}
+
+// liveChunkKeys returns keys of chunks in upload order
+func (e *Router) liveChunkKeys(sessionKey string) ([]string, error) {
+	var keys []string
+	err := e.services.SessionStorage.Walk(storage.LiveChunksPrefix(sessionKey), func(obj *storage.ObjectInfo) error {
+		keys = append(keys, obj.Key)
+		return nil
+	})
+	sort.Strings(keys)
+	return keys, err
+}
+
+func (e *Router) isReplayCached(key string) bool {
+	return e.services.ReplayCache != nil && e.services.ReplayCache.Exists(key)
+}
+
+// replayFileSignature binds the url to the file and its expiration time, the player downloads files without the JWT
+func (e *Router) replayFileSignature(key string, expiresAt int64) string {
+	mac := hmac.New(sha256.New, []byte(e.cfg.ReplayCacheSecret))
+	mac.Write([]byte(fmt.Sprintf("%s:%d", key, expiresAt)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (e *Router) replayFileURL(key string, expiresAt int64) string {
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expiresAt, 10))
+	query.Set("signature", e.replayFileSignature(key, expiresAt))
+	return strings.TrimSuffix(e.cfg.ReplayCacheURL, "/") + "/v1/replay/files/" + url.PathEscape(key) + "?" + query.Encode()
+}
+
+// replayFileHandler serves the cached file with the headers of the uploaded one, the player can't tell them apart.
+// Files evicted after the url was signed are redirected to the object storage, or read from it
+// if they could be compressed with a dictionary.
+func (e *Router) replayFileHandler(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+	expiresAt, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil || time.Now().UnixMilli() > expiresAt {
+		ResponseWithError(w, http.StatusForbidden, errors.New("url is expired"))
+		return
+	}
+	signature, err := hex.DecodeString(r.URL.Query().Get("signature"))
+	expected, _ := hex.DecodeString(e.replayFileSignature(key, expiresAt))
+	if err != nil || !hmac.Equal(signature, expected) {
+		ResponseWithError(w, http.StatusForbidden, errors.New("wrong signature"))
+		return
+	}
+
+	var obj *storage.CachedObject
+	if e.services.ReplayCache != nil {
+		if obj, err = e.services.ReplayCache.Open(key); err != nil {
+			log.Printf("can't open cached replay file, key: %s, err: %s", key, err)
+		}
+	}
+	if obj == nil && e.services.Dictionaries != nil {
+		file, err := e.services.SessionStorage.Get(key)
+		if err != nil {
+			log.Printf("can't get replay file, key: %s, err: %s", key, err)
+			ResponseWithError(w, http.StatusNotFound, errors.New("file not found"))
+			return
+		}
+		defer file.Close()
+		e.serveDecompressed(w, key, file)
+		return
+	}
+	if obj == nil {
+		location, err := e.services.SessionStorage.GetPresignedURL(key, time.Until(time.UnixMilli(expiresAt)))
+		if err != nil {
+			log.Printf("can't sign session url, key: %s, err: %s", key, err)
+			ResponseWithError(w, http.StatusInternalServerError, errors.New("can't sign session url"))
+			return
+		}
+		http.Redirect(w, r, location, http.StatusFound)
+		return
+	}
+	defer obj.Close()
+	if obj.ContentType == dictionaries.ContentType {
+		e.serveDecompressed(w, key, obj.Body)
+		return
+	}
+	w.Header().Set("Content-Type", obj.ContentType)
+	if obj.Gzipped {
+		w.Header().Set("Content-Encoding", "gzip")
+	}
+	http.ServeContent(w, r, "", obj.ModTime, obj.Body)
+}
+
+// serveDecompressed sends the file of any compression as is, dictionaries are found by ids from zstd frame headers
+func (e *Router) serveDecompressed(w http.ResponseWriter, key string, file io.Reader) {
+	reader, err := dictionaries.NewReader(file, e.services.Dictionaries)
+	if err != nil {
+		log.Printf("can't decompress replay file, key: %s, err: %s", key, err)
+		ResponseWithError(w, http.StatusInternalServerError, errors.New("can't decompress file"))
+		return
+	}
+	defer reader.Close()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if _, err := io.Copy(w, reader); err != nil {
+		log.Printf("can't send replay file, key: %s, err: %s", key, err)
+	}
+}
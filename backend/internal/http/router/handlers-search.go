@@ -0,0 +1,88 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"openreplay/backend/pkg/search"
+)
+
+// searchSessionsHandler finds sessions by metadata, events and issues, results are paginated
+func (e *Router) searchSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := e.services.JWTValidator.ParseFromHTTPRequest(r)
+	if err != nil {
+		ResponseWithError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if r.Body == nil {
+		ResponseWithError(w, http.StatusBadRequest, errors.New("request body is empty"))
+		return
+	}
+	bodyBytes, err := e.readBody(w, r, e.cfg.SearchSizeLimit)
+	if err != nil {
+		log.Printf("error while reading request body: %s", err)
+		ResponseWithRequestError(w, err)
+		return
+	}
+	req := &search.Request{}
+	if err := json.Unmarshal(bodyBytes, req); err != nil {
+		ResponseWithError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		ResponseWithError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	hasAccess, err := e.services.Database.HasProjectAccess(user.UserID, user.TenantID, req.ProjectID)
+	if err != nil {
+		log.Printf("can't check project access, userID: %d, projID: %d, err: %s", user.UserID, req.ProjectID, err)
+		ResponseWithError(w, http.StatusInternalServerError, errors.New("can't check project access"))
+		return
+	}
+	if !hasAccess {
+		ResponseWithError(w, http.StatusForbidden, errors.New("access denied"))
+		return
+	}
+	project, err := e.services.Database.Conn.GetProject(req.ProjectID)
+	if err != nil {
+		log.Printf("can't get project, projID: %d, err: %s", req.ProjectID, err)
+		ResponseWithError(w, http.StatusInternalServerError, errors.New("can't get project"))
+		return
+	}
+	for _, f := range req.Filters {
+		if f.Type != search.FilterMetadata {
+			continue
+		}
+		if f.MetadataNo = project.GetMetadataNo(f.Key); f.MetadataNo == 0 {
+			ResponseWithError(w, http.StatusBadRequest, fmt.Errorf("unknown metadata key: %s", f.Key))
+			return
+		}
+	}
+
+	res, err := e.services.Searcher.Search(req)
+	if err != nil {
+		log.Printf("can't search sessions, projID: %d, err: %s", req.ProjectID, err)
+		ResponseWithError(w, http.StatusInternalServerError, errors.New("can't search sessions"))
+		return
+	}
+	// Metadata columns are renamed according to the project config
+	for _, sess := range res.Sessions {
+		if sess.Metadata == nil {
+			continue
+		}
+		metadata := make(map[string]string, len(sess.Metadata))
+		for no := uint(1); no <= 10; no++ {
+			value, ok := sess.Metadata[search.MetadataColumn(no)]
+			if key := project.GetMetadataKey(no); ok && key != "" {
+				metadata[key] = value
+			}
+		}
+		sess.Metadata = metadata
+	}
+	ResponseWithJSON(w, res)
+}
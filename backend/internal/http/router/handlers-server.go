@@ -0,0 +1,117 @@
+package router
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"openreplay/backend/internal/http/overload"
+	"openreplay/backend/internal/http/serverbatch"
+	"openreplay/backend/pkg/queue/types"
+)
+
+type serverItemError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+type serverBatchResponse struct {
+	Accepted int                `json:"accepted"`
+	Rejected []*serverItemError `json:"rejected,omitempty"`
+}
+
+// serverBatchHandler accepts events of server-side SDKs for many sessions and projects in one request.
+// The key is checked once per request, every item is checked against the projects of the key,
+// so the batch is accepted partially if some of its items are out of the key scope.
+func (e *Router) serverBatchHandler(w http.ResponseWriter, r *http.Request) {
+	key := serverbatch.KeyFromHeader(r.Header.Get("Authorization"))
+	if key == "" {
+		ResponseWithError(w, http.StatusUnauthorized, errors.New("server key is empty"))
+		return
+	}
+	if r.Body == nil {
+		ResponseWithError(w, http.StatusBadRequest, errors.New("request body is empty"))
+		return
+	}
+	bodyBytes, err := e.readBody(w, r, e.cfg.ServerBatchSizeLimit)
+	if err != nil {
+		log.Printf("error while reading request body: %s", err)
+		ResponseWithRequestError(w, err)
+		return
+	}
+	projects, err := e.services.Database.Conn.GetServerKeyProjects(serverbatch.HashKey(key))
+	if err != nil {
+		log.Printf("can't get server key projects: %s", err)
+		ResponseWithError(w, http.StatusInternalServerError, errors.New("can't check server key"))
+		return
+	}
+	if projects == nil {
+		ResponseWithError(w, http.StatusUnauthorized, errors.New("wrong or revoked server key"))
+		return
+	}
+	allowed := make(map[uint32]bool, len(projects))
+	for _, projectID := range projects {
+		allowed[projectID] = true
+	}
+	batch, err := serverbatch.ParseBody(bodyBytes)
+	if err != nil {
+		ResponseWithError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	res, now := &serverBatchResponse{}, time.Now()
+	for index, item := range batch.Items {
+		sessionID, err := e.serverItemSession(item, allowed)
+		if err == nil {
+			err = e.services.Producer.Produce(e.cfg.TopicAnalytics, sessionID, item.ToMessages(now))
+			switch {
+			case errors.Is(err, types.ErrBackpressure):
+				// Only rejected items have to be sent again
+				e.hints.Issue(sessionID, overload.ReasonBackpressure)
+				SetRetryAfter(w, e.hints.RetryAfter())
+			case err != nil:
+				log.Printf("can't send server events, sessID: %d, err: %s", sessionID, err)
+				err = errors.New("can't save events")
+			}
+		}
+		if err != nil {
+			res.Rejected = append(res.Rejected, &serverItemError{Index: index, Error: err.Error()})
+			continue
+		}
+		res.Accepted++
+	}
+	ResponseWithJSON(w, res)
+}
+
+// serverItemSession returns the session of the item if the key has access to its project
+func (e *Router) serverItemSession(item *serverbatch.Item, allowed map[uint32]bool) (uint64, error) {
+	if err := item.Validate(); err != nil {
+		return 0, err
+	}
+	project, err := e.services.Database.GetProjectByKey(item.ProjectKey)
+	if err != nil || project == nil {
+		return 0, errors.New("project doesn't exist")
+	}
+	if !allowed[project.ProjectID] {
+		return 0, errors.New("server key has no access to the project")
+	}
+	var sessionID uint64
+	if item.SessionID != "" {
+		if sessionID, err = strconv.ParseUint(item.SessionID, 10, 64); err != nil {
+			return 0, errors.New("wrong session id")
+		}
+	} else {
+		data, _ := e.services.Tokenizer.Parse(item.SessionToken)
+		if data == nil {
+			return 0, errors.New("wrong session token")
+		}
+		sessionID = data.ID // expired token still points to the right session
+	}
+	// Session reference comes from the service, so it has to belong to the project
+	if sess, err := e.services.Database.Conn.GetSession(sessionID); err != nil || sess.ProjectID != project.ProjectID {
+		return 0, errors.New("session doesn't belong to the project")
+	}
+	return sessionID, nil
+}
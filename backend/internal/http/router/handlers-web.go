@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"errors"
 	"go.opentelemetry.io/otel/attribute"
-	"io"
 	"log"
 	"math/rand"
 	"net/http"
@@ -18,28 +17,7 @@ import (
 	"openreplay/backend/pkg/token"
 )
 
-func (e *Router) readBody(w http.ResponseWriter, r *http.Request, limit int64) ([]byte, error) {
-	body := http.MaxBytesReader(w, r.Body, limit)
-	bodyBytes, err := io.ReadAll(body)
-	if closeErr := body.Close(); closeErr != nil {
-		log.Printf("error while closing request body: %s", closeErr)
-	}
-	if err != nil {
-		return nil, err
-	}
-
-	reqSize := len(bodyBytes)
-	e.requestSize.Record(
-		r.Context(),
-		float64(reqSize),
-		[]attribute.KeyValue{attribute.String("method", r.URL.Path)}...,
-	)
-	return bodyBytes, nil
-}
-
 func (e *Router) startSessionHandlerWeb(w http.ResponseWriter, r *http.Request) {
-	startTime := time.Now()
-
 	// Check request body
 	if r.Body == nil {
 		ResponseWithError(w, http.StatusBadRequest, errors.New("request body is empty"))
@@ -49,7 +27,7 @@ func (e *Router) startSessionHandlerWeb(w http.ResponseWriter, r *http.Request)
 	bodyBytes, err := e.readBody(w, r, e.cfg.JsonSizeLimit)
 	if err != nil {
 		log.Printf("error while reading request body: %s", err)
-		ResponseWithError(w, http.StatusRequestEntityTooLarge, err)
+		ResponseWithRequestError(w, err)
 		return
 	}
 
@@ -60,21 +38,30 @@ func (e *Router) startSessionHandlerWeb(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Handler's logic
-	if req.ProjectKey == nil {
-		ResponseWithError(w, http.StatusForbidden, errors.New("ProjectKey value required"))
+	res, err := e.startSessionWeb(r, req)
+	if err != nil {
+		ResponseWithRequestError(w, err)
 		return
 	}
+	ResponseWithJSON(w, res)
+}
+
+// startSessionWeb is the logic of the start request shared by the HTTP and gRPC endpoints,
+// r is used only for headers and the address of the client
+func (e *Router) startSessionWeb(r *http.Request, req *StartSessionRequest) (*StartSessionResponse, error) {
+	startTime := time.Now()
+
+	if req.ProjectKey == nil {
+		return nil, newRequestError(http.StatusForbidden, errors.New("ProjectKey value required"))
+	}
 
 	p, err := e.services.Database.GetProjectByKey(*req.ProjectKey)
 	if err != nil {
 		if postgres.IsNoRowsErr(err) {
-			ResponseWithError(w, http.StatusNotFound, errors.New("project doesn't exist or capture limit has been reached"))
-		} else {
-			log.Printf("can't get project by key: %s", err)
-			ResponseWithError(w, http.StatusInternalServerError, errors.New("can't get project by key"))
+			return nil, newRequestError(http.StatusNotFound, errors.New("project doesn't exist or capture limit has been reached"))
 		}
-		return
+		log.Printf("can't get project by key: %s", err)
+		return nil, newRequestError(http.StatusInternalServerError, errors.New("can't get project by key"))
 	}
 
 	userUUID := uuid.GetUUID(req.UserUUID)
@@ -82,19 +69,27 @@ func (e *Router) startSessionHandlerWeb(w http.ResponseWriter, r *http.Request)
 	if err != nil || req.Reset { // Starting the new one
 		dice := byte(rand.Intn(100)) // [0, 100)
 		if dice >= p.SampleRate {
-			ResponseWithError(w, http.StatusForbidden, errors.New("cancel"))
-			return
+			return nil, newRequestError(http.StatusForbidden, errors.New("cancel"))
 		}
 
 		ua := e.services.UaParser.ParseFromHTTPRequest(r)
 		if ua == nil {
-			ResponseWithError(w, http.StatusForbidden, errors.New("browser not recognized"))
-			return
+			return nil, newRequestError(http.StatusForbidden, errors.New("browser not recognized"))
+		}
+		botReason := ""
+		if e.services.BotFilter != nil {
+			if botReason = e.services.BotFilter.Check(r); botReason != "" {
+				keep := e.services.BotFilter.Keep()
+				e.botSessions.Add(r.Context(), 1, attribute.String("action", string(e.services.BotFilter.Action())),
+					attribute.String("reason", botReason), attribute.Bool("kept", keep))
+				if !keep {
+					return nil, newRequestError(http.StatusForbidden, errors.New("cancel"))
+				}
+			}
 		}
 		sessionID, err := e.services.Flaker.Compose(uint64(startTime.UnixMilli()))
 		if err != nil {
-			ResponseWithError(w, http.StatusInternalServerError, err)
-			return
+			return nil, newRequestError(http.StatusInternalServerError, err)
 		}
 		// TODO: if EXPIRED => send message for two sessions association
 		expTime := startTime.Add(time.Duration(p.MaxSessionDuration) * time.Millisecond)
@@ -128,16 +123,36 @@ func (e *Router) startSessionHandlerWeb(w http.ResponseWriter, r *http.Request)
 		if err := e.services.Producer.Produce(e.cfg.TopicRawWeb, tokenData.ID, Encode(sessionStart)); err != nil {
 			log.Printf("can't send session start: %s", err)
 		}
+
+		// Kept bot session is marked, so it can be excluded from analytics
+		if botReason != "" {
+			botEvent := &CustomEvent{Timestamp: req.Timestamp, Name: "bot_suspected", Payload: botReason}
+			if err := e.services.Producer.Produce(e.cfg.TopicRawWeb, tokenData.ID, Encode(botEvent)); err != nil {
+				log.Printf("can't send bot flag, sessID: %d, err: %s", tokenData.ID, err)
+			}
+		}
 	}
 
-	ResponseWithJSON(w, &StartSessionResponse{
+	return &StartSessionResponse{
 		Token:           e.services.Tokenizer.Compose(*tokenData),
 		UserUUID:        userUUID,
 		SessionID:       strconv.FormatUint(tokenData.ID, 10),
 		ProjectID:       strconv.FormatUint(uint64(p.ProjectID), 10),
 		BeaconSizeLimit: e.cfg.BeaconSizeLimit,
 		StartTimestamp:  int64(flakeid.ExtractTimestamp(tokenData.ID)),
-	})
+		Encoding:        batchEncoding(req.Encoding),
+		BatchVersion:    BatchVersion,
+		Overload:        e.hints.Current(p.ProjectID),
+	}, nil
+}
+
+// batchEncoding negotiates the encoding of batches, trackers which don't send it use the custom binary format.
+// Protobuf batches have to be sent with ProtoContentType.
+func batchEncoding(preferred string) string {
+	if preferred == "protobuf" {
+		return preferred
+	}
+	return "binary"
 }
 
 func (e *Router) pushMessagesHandlerWeb(w http.ResponseWriter, r *http.Request) {
@@ -157,15 +172,15 @@ func (e *Router) pushMessagesHandlerWeb(w http.ResponseWriter, r *http.Request)
 	bodyBytes, err := e.readBody(w, r, e.cfg.BeaconSizeLimit)
 	if err != nil {
 		log.Printf("error while reading request body: %s", err)
-		ResponseWithError(w, http.StatusRequestEntityTooLarge, err)
+		ResponseWithRequestError(w, err)
 		return
 	}
 
 	// Send processed messages to queue as array of bytes
 	// TODO: check bytes for nonsense crap
-	err = e.services.Producer.Produce(e.cfg.TopicRawWeb, sessionData.ID, bodyBytes)
-	if err != nil {
-		log.Printf("can't send processed messages to queue: %s", err)
+	if err := e.pushBatch(sessionData.ID, e.cfg.TopicRawWeb, bodyBytes, r.Header.Get("Content-Type") == ProtoContentType); err != nil {
+		ResponseWithRequestError(w, err)
+		return
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -181,7 +196,7 @@ func (e *Router) notStartedHandlerWeb(w http.ResponseWriter, r *http.Request) {
 	bodyBytes, err := e.readBody(w, r, e.cfg.JsonSizeLimit)
 	if err != nil {
 		log.Printf("error while reading request body: %s", err)
-		ResponseWithError(w, http.StatusRequestEntityTooLarge, err)
+		ResponseWithRequestError(w, err)
 		return
 	}
 
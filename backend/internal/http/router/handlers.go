@@ -1,40 +1,50 @@
 package router
 
 import (
-	gzip "github.com/klauspost/pgzip"
-	"io"
-	"io/ioutil"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+
+	"openreplay/backend/internal/http/overload"
+	"openreplay/backend/pkg/messages"
+	"openreplay/backend/pkg/queue/types"
 )
 
 func (e *Router) pushMessages(w http.ResponseWriter, r *http.Request, sessionID uint64, topicName string) {
-	body := http.MaxBytesReader(w, r.Body, e.cfg.BeaconSizeLimit)
-	defer body.Close()
-
-	var reader io.ReadCloser
-	var err error
-
-	switch r.Header.Get("Content-Encoding") {
-	case "gzip":
-		log.Println("Gzip", reader)
-
-		reader, err = gzip.NewReader(body)
-		if err != nil {
-			ResponseWithError(w, http.StatusInternalServerError, err) // TODO: stage-dependent response
-			return
-		}
-		//log.Println("Gzip reader init", reader)
-		defer reader.Close()
-	default:
-		reader = body
+	if r.Body == nil {
+		ResponseWithError(w, http.StatusBadRequest, errors.New("request body is empty"))
+		return
 	}
-	//log.Println("Reader after switch:", reader)
-	buf, err := ioutil.ReadAll(reader)
+	buf, err := e.readBody(w, r, e.cfg.BeaconSizeLimit)
 	if err != nil {
-		ResponseWithError(w, http.StatusInternalServerError, err) // TODO: send error here only on staging
+		log.Printf("error while reading request body: %s", err)
+		ResponseWithRequestError(w, err)
+		return
+	}
+	if err := e.pushBatch(sessionID, topicName, buf, false); err != nil {
+		ResponseWithRequestError(w, err)
 		return
 	}
-	e.services.Producer.Produce(topicName, sessionID, buf) // What if not able to send?
 	w.WriteHeader(http.StatusOK)
 }
+
+// pushBatch is shared by the HTTP and gRPC endpoints. Protobuf batches are converted, consumers always get
+// the custom binary format. Batches rejected by the queue get the overload hint, other errors are only logged.
+func (e *Router) pushBatch(sessionID uint64, topicName string, batch []byte, proto bool) error {
+	if proto {
+		var err error
+		if batch, err = messages.ProtoBatchToNative(batch); err != nil {
+			return newRequestError(http.StatusBadRequest, fmt.Errorf("can't decode protobuf batch: %s", err))
+		}
+	}
+	err := e.services.Producer.Produce(topicName, sessionID, batch)
+	if errors.Is(err, types.ErrBackpressure) {
+		hint := e.hints.Issue(sessionID, overload.ReasonBackpressure)
+		return &requestError{status: http.StatusServiceUnavailable, err: fmt.Errorf("service is overloaded: %s", hint.Reason), hint: hint}
+	}
+	if err != nil {
+		log.Printf("can't send messages to queue, sessID: %d, err: %s", sessionID, err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package router
+
+import (
+	"strconv"
+
+	"openreplay/backend/internal/http/overload"
+	"openreplay/backend/pkg/messages"
+)
+
+// Models of the gRPC ingest are encoded by hand with the protobuf wire format of ingest.proto,
+// the same way as batches of messages.proto, so there is no generated code
+
+func (req *StartSessionRequest) unmarshalProto(data []byte) error {
+	return messages.ReadProtoFields(data, func(field uint64, value *messages.ProtoValue) {
+		switch field {
+		case 1:
+			req.Token = value.String()
+		case 2:
+			uuid := value.String()
+			req.UserUUID = &uuid
+		case 3:
+			req.RevID = value.String()
+		case 4:
+			req.Timestamp = value.Uint()
+		case 5:
+			req.TrackerVersion = value.String()
+		case 6:
+			req.IsSnippet = value.Boolean()
+		case 7:
+			req.DeviceMemory = value.Uint()
+		case 8:
+			req.JsHeapSizeLimit = value.Uint()
+		case 9:
+			key := value.String()
+			req.ProjectKey = &key
+		case 10:
+			req.Reset = value.Boolean()
+		case 11:
+			req.UserID = value.String()
+		case 12:
+			req.Encoding = value.String()
+		}
+	})
+}
+
+func (res *StartSessionResponse) marshalProto() []byte {
+	buf := messages.AppendProtoString(nil, 1, res.Token)
+	buf = messages.AppendProtoString(buf, 2, res.UserUUID)
+	buf = messages.AppendProtoString(buf, 3, res.SessionID)
+	buf = messages.AppendProtoString(buf, 4, res.ProjectID)
+	buf = messages.AppendProtoUint(buf, 5, uint64(res.BeaconSizeLimit))
+	buf = messages.AppendProtoUint(buf, 6, uint64(res.StartTimestamp))
+	buf = messages.AppendProtoString(buf, 7, res.Encoding)
+	buf = messages.AppendProtoUint(buf, 8, uint64(res.BatchVersion))
+	if res.Overload != nil {
+		buf = messages.AppendProtoData(buf, 9, marshalHint(res.Overload))
+	}
+	return buf
+}
+
+func marshalHint(hint *overload.Hint) []byte {
+	buf := messages.AppendProtoString(nil, 1, hint.Reason)
+	buf = messages.AppendProtoUint(buf, 2, uint64(hint.RetryAfter))
+	return messages.AppendProtoUint(buf, 3, uint64(hint.SampleRate))
+}
+
+// hintTrailers are the trailers of calls rejected with the hint, gRPC errors have no body for it
+func hintTrailers(hint *overload.Hint) map[string]string {
+	return map[string]string{
+		"overload-reason":      hint.Reason,
+		"overload-retry-after": strconv.FormatInt(hint.RetryAfter, 10),
+		"overload-sample-rate": strconv.Itoa(hint.SampleRate),
+	}
+}
+
+func (req *StartIOSSessionRequest) unmarshalProto(data []byte) error {
+	return messages.ReadProtoFields(data, func(field uint64, value *messages.ProtoValue) {
+		switch field {
+		case 1:
+			req.Token = value.String()
+		case 2:
+			key := value.String()
+			req.ProjectKey = &key
+		case 3:
+			req.TrackerVersion = value.String()
+		case 4:
+			req.RevID = value.String()
+		case 5:
+			uuid := value.String()
+			req.UserUUID = &uuid
+		case 6:
+			req.UserOSVersion = value.String()
+		case 7:
+			req.UserDevice = value.String()
+		case 8:
+			req.Timestamp = value.Uint()
+		}
+	})
+}
+
+func (res *StartIOSSessionResponse) marshalProto() []byte {
+	buf := messages.AppendProtoString(nil, 1, res.Token)
+	for _, hash := range res.ImagesHashList {
+		buf = messages.AppendProtoData(buf, 2, []byte(hash))
+	}
+	buf = messages.AppendProtoString(buf, 3, res.UserUUID)
+	buf = messages.AppendProtoUint(buf, 4, uint64(res.BeaconSizeLimit))
+	return messages.AppendProtoString(buf, 5, res.SessionID)
+}
+
+// ingestBatch is the batch of push calls, protobuf batches are converted by pushBatch
+type ingestBatch struct {
+	data  []byte
+	proto bool
+}
+
+// unmarshalProto copies the batch, gRPC may reuse the buffer of the request after decoding
+func (b *ingestBatch) unmarshalProto(data []byte) error {
+	return messages.ReadProtoFields(data, func(field uint64, value *messages.ProtoValue) {
+		switch field {
+		case 1, 2:
+			b.data = append([]byte(nil), value.Data()...)
+			b.proto = field == 2
+		}
+	})
+}
+
+type pushReply struct{}
+
+func (pushReply) marshalProto() []byte {
+	return nil
+}
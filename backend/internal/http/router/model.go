@@ -1,5 +1,7 @@
 package router
 
+import "openreplay/backend/internal/http/overload"
+
 type StartSessionRequest struct {
 	Token           string  `json:"token"`
 	UserUUID        *string `json:"userUUID"`
@@ -12,6 +14,7 @@ type StartSessionRequest struct {
 	ProjectKey      *string `json:"projectKey"`
 	Reset           bool    `json:"reset"`
 	UserID          string  `json:"userID"`
+	Encoding        string  `json:"encoding"` // preferred batch encoding, binary or protobuf
 }
 
 type StartSessionResponse struct {
@@ -23,6 +26,10 @@ type StartSessionResponse struct {
 	SessionID       string `json:"sessionID"`
 	ProjectID       string `json:"projectID"`
 	BeaconSizeLimit int64  `json:"beaconSizeLimit"`
+	Encoding        string `json:"encoding"`     // accepted batch encoding
+	BatchVersion    int    `json:"batchVersion"` // the latest batch version, trackers may send it or the previous one
+	// Hint of the recent overload, trackers keep recording only the sample rate percent of sessions
+	Overload *overload.Hint `json:"overload,omitempty"`
 }
 
 type NotStartedRequest struct {
@@ -49,3 +56,15 @@ type StartIOSSessionResponse struct {
 	BeaconSizeLimit int64    `json:"beaconSizeLimit"`
 	SessionID       string   `json:"sessionID"`
 }
+
+type ReplayURLsRequest struct {
+	SessionID string   `json:"sessionID"`
+	Assets    []string `json:"assets"`
+}
+
+type ReplayURLsResponse struct {
+	Mobs      []string          `json:"mobs"`
+	Live      bool              `json:"live,omitempty"` // mobs are chunks of the active session, the list grows until it ends
+	Assets    map[string]string `json:"assets,omitempty"`
+	ExpiresAt int64             `json:"expiresAt"`
+}
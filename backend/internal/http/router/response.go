@@ -2,8 +2,14 @@ package router
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
+
+	"openreplay/backend/internal/http/overload"
 )
 
 func ResponseWithJSON(w http.ResponseWriter, res interface{}) {
@@ -22,3 +28,50 @@ func ResponseWithError(w http.ResponseWriter, code int, err error) {
 	w.WriteHeader(code)
 	ResponseWithJSON(w, &response{err.Error()})
 }
+
+// requestError is the error of the request logic shared by HTTP and gRPC endpoints, status is the HTTP one,
+// gRPC calls get the closest code
+type requestError struct {
+	status int
+	err    error
+	hint   *overload.Hint // rejected batches are sent again after the hint delay
+}
+
+func newRequestError(status int, err error) error {
+	return &requestError{status: status, err: err}
+}
+
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+// ResponseWithRequestError writes errors of the shared request logic, other errors are internal ones
+func ResponseWithRequestError(w http.ResponseWriter, err error) {
+	reqErr, ok := err.(*requestError)
+	switch {
+	case !ok:
+		ResponseWithError(w, http.StatusInternalServerError, err)
+	case reqErr.hint != nil:
+		ResponseWithOverload(w, reqErr.hint)
+	default:
+		ResponseWithError(w, reqErr.status, reqErr.err)
+	}
+}
+
+// ResponseWithOverload asks the client to send the request again later, the hint is repeated in the body for
+// trackers which read the delay and the sample rate from it
+func ResponseWithOverload(w http.ResponseWriter, hint *overload.Hint) {
+	type response struct {
+		Error string         `json:"error"`
+		Hint  *overload.Hint `json:"hint"`
+	}
+	SetRetryAfter(w, time.Duration(hint.RetryAfter)*time.Millisecond)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	ResponseWithJSON(w, &response{fmt.Sprintf("service is overloaded: %s", hint.Reason), hint})
+}
+
+// SetRetryAfter rounds the delay up to whole seconds
+func SetRetryAfter(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+}
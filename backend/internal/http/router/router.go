@@ -9,6 +9,7 @@ import (
 	"log"
 	"net/http"
 	http3 "openreplay/backend/internal/config/http"
+	"openreplay/backend/internal/http/overload"
 	http2 "openreplay/backend/internal/http/services"
 	"openreplay/backend/internal/http/util"
 	"openreplay/backend/pkg/monitoring"
@@ -22,6 +23,10 @@ type Router struct {
 	requestSize     syncfloat64.Histogram
 	requestDuration syncfloat64.Histogram
 	totalRequests   syncfloat64.Counter
+	botSessions     syncfloat64.Counter
+	overloadHints   syncfloat64.Counter
+	hints           *overload.Hints
+	bodyLimits      map[string]int64 // limits of endpoints from BODY_SIZE_LIMITS by route paths
 }
 
 func NewRouter(cfg *http3.Config, services *http2.ServicesBuilder, metrics *monitoring.Metrics) (*Router, error) {
@@ -38,6 +43,14 @@ func NewRouter(cfg *http3.Config, services *http2.ServicesBuilder, metrics *moni
 		services: services,
 	}
 	e.initMetrics(metrics)
+	var err error
+	if e.bodyLimits, err = parseBodyLimits(cfg.BodySizeLimits); err != nil {
+		return nil, err
+	}
+	if e.hints, err = overload.New(cfg.BackpressureRetryAfter, cfg.OverloadSampleRate, cfg.OverloadHintWindow,
+		e.sessionProject, e.countOverloadHint); err != nil {
+		return nil, err
+	}
 	e.init()
 	return e, nil
 }
@@ -56,14 +69,61 @@ func (e *Router) init() {
 		"/v1/ios/i":           e.pushMessagesHandlerIOS,
 		"/v1/ios/late":        e.pushLateMessagesHandlerIOS,
 		"/v1/ios/images":      e.imagesUploadHandlerIOS,
+		// gRPC-Web calls of the gRPC ingest, see ingest.proto
+		"/" + ingestService + "/{method}": e.grpcWebHandler,
 	}
-	prefix := "/ingest"
+	prefix := ingestPrefix
 
 	for path, handler := range handlers {
 		e.router.HandleFunc(path, handler).Methods("POST", "OPTIONS")
 		e.router.HandleFunc(prefix+path, handler).Methods("POST", "OPTIONS")
 	}
 
+	// Remote configuration polled by trackers
+	e.router.HandleFunc("/v1/web/config/{projectKey}", e.trackerConfigHandler).Methods("GET", "OPTIONS")
+	e.router.HandleFunc(prefix+"/v1/web/config/{projectKey}", e.trackerConfigHandler).Methods("GET", "OPTIONS")
+
+	// Replay access for dashboard users
+	if e.services.JWTValidator != nil {
+		e.router.HandleFunc("/v1/replay/urls", e.replayURLsHandler).Methods("POST", "OPTIONS")
+	}
+	if e.services.ReplayCache != nil || e.services.Dictionaries != nil {
+		e.router.HandleFunc("/v1/replay/files/{key}", e.replayFileHandler).Methods("GET", "OPTIONS")
+	}
+
+	// Agent-side events of assist sessions, they are merged into the recording
+	if e.services.JWTValidator != nil {
+		e.router.HandleFunc("/v1/assist/events", e.assistEventsHandler).Methods("POST", "OPTIONS")
+	}
+
+	// CDP webhooks (Segment-like track and identify calls)
+	if e.cfg.CDPSecret != "" && e.cfg.TopicAnalytics != "" {
+		e.router.HandleFunc("/v1/cdp/{projectKey}", e.cdpWebhookHandler).Methods("POST", "OPTIONS")
+		e.router.HandleFunc(prefix+"/v1/cdp/{projectKey}", e.cdpWebhookHandler).Methods("POST", "OPTIONS")
+	}
+
+	// Batches of server-side SDKs with events of many sessions and projects
+	if e.cfg.TopicAnalytics != "" {
+		e.router.HandleFunc("/v1/server/batch", e.serverBatchHandler).Methods("POST", "OPTIONS")
+		e.router.HandleFunc(prefix+"/v1/server/batch", e.serverBatchHandler).Methods("POST", "OPTIONS")
+	}
+
+	// Programmatic session search, ClickHouse is required
+	if e.services.JWTValidator != nil && e.services.Searcher != nil {
+		e.router.HandleFunc("/v1/sessions/search", e.searchSessionsHandler).Methods("POST", "OPTIONS")
+	}
+
+	// Bulk import of user attributes (account tier, region etc.) for dashboard users
+	if e.services.JWTValidator != nil {
+		e.router.HandleFunc("/v1/projects/{projectID}/user-attributes", e.importUserAttributesHandler).Methods("POST", "OPTIONS")
+	}
+
+	// Erasure of all sessions of the user (GDPR), requests are processed by the retention service
+	if e.services.JWTValidator != nil {
+		e.router.HandleFunc("/v1/projects/{projectID}/erasures", e.createErasureHandler).Methods("POST", "OPTIONS")
+		e.router.HandleFunc("/v1/projects/{projectID}/erasures/{erasureID}", e.getErasureHandler).Methods("GET", "OPTIONS")
+	}
+
 	// CORS middleware
 	e.router.Use(e.corsMiddleware)
 }
@@ -82,6 +142,27 @@ func (e *Router) initMetrics(metrics *monitoring.Metrics) {
 	if err != nil {
 		log.Printf("can't create requests_total metric: %s", err)
 	}
+	e.botSessions, err = metrics.RegisterCounter("bot_sessions")
+	if err != nil {
+		log.Printf("can't create bot_sessions metric: %s", err)
+	}
+	e.overloadHints, err = metrics.RegisterCounter("overload_hints")
+	if err != nil {
+		log.Printf("can't create overload_hints metric: %s", err)
+	}
+}
+
+func (e *Router) countOverloadHint(projectID uint32, reason string) {
+	if e.overloadHints == nil {
+		return
+	}
+	e.overloadHints.Add(context.Background(), 1, attribute.Int64("project_id", int64(projectID)),
+		attribute.String("reason", reason))
+}
+
+func (e *Router) sessionProject(sessionID uint64) (uint32, error) {
+	projectID, _, err := e.services.Database.Conn.GetSessionPartitionKeys(sessionID)
+	return projectID, err
 }
 
 func (e *Router) root(w http.ResponseWriter, r *http.Request) {
@@ -92,8 +173,9 @@ func (e *Router) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Prepare headers for preflight requests
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "POST")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type,Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "POST,GET")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type,Authorization,If-None-Match,X-Grpc-Web,X-User-Agent,Grpc-Timeout")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag,Grpc-Status,Grpc-Message")
 		if r.Method == http.MethodOptions {
 			w.Header().Set("Cache-Control", "max-age=86400")
 			w.WriteHeader(http.StatusOK)
@@ -0,0 +1,154 @@
+package serverbatch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+
+	"openreplay/backend/pkg/messages"
+)
+
+const (
+	maxItems       = 1000
+	maxItemEvents  = 100
+	maxPayloadSize = 8 * 1024
+	maxEventDelay  = 24 * time.Hour
+)
+
+// Event is one server-side event of the session
+type Event struct {
+	Type      string          `json:"type"` // custom, issue, user_id or metadata
+	MessageID string          `json:"messageId"`
+	Name      string          `json:"name"` // event or issue name, metadata key
+	Value     string          `json:"value"`
+	Payload   json.RawMessage `json:"payload"`
+	Timestamp int64           `json:"timestamp"` // unix ms
+}
+
+// Item keeps events of one session, items of the same batch can belong to different projects
+type Item struct {
+	ProjectKey   string   `json:"projectKey"`
+	SessionID    string   `json:"sessionId"`
+	SessionToken string   `json:"sessionToken"` // token of the tracker, it's used if the session id is empty
+	Events       []*Event `json:"events"`
+}
+
+// Batch is sent by server-side SDKs, one request carries events of many services and projects
+type Batch struct {
+	Items []*Item `json:"items"`
+}
+
+// HashKey returns the stored form of the server-side key
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// KeyFromHeader returns the key of "Authorization: Bearer <key>", empty string if the header has another format
+func KeyFromHeader(authorization string) string {
+	if token := strings.TrimPrefix(authorization, "Bearer "); token != authorization {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// ParseBody checks the size of the batch only, items are checked one by one, so one wrong item doesn't reject the others
+func ParseBody(body []byte) (*Batch, error) {
+	batch := &Batch{}
+	if err := json.Unmarshal(body, batch); err != nil {
+		return nil, err
+	}
+	if len(batch.Items) == 0 {
+		return nil, errors.New("items are empty")
+	}
+	if len(batch.Items) > maxItems {
+		return nil, fmt.Errorf("batch is too big, max: %d", maxItems)
+	}
+	return batch, nil
+}
+
+// Validate returns the reason why the item can't be accepted
+func (item *Item) Validate() error {
+	switch {
+	case item.ProjectKey == "":
+		return errors.New("project key is empty")
+	case item.SessionID == "" && item.SessionToken == "":
+		return errors.New("session reference is empty")
+	case len(item.Events) == 0:
+		return errors.New("events are empty")
+	case len(item.Events) > maxItemEvents:
+		return fmt.Errorf("too many events, max: %d", maxItemEvents)
+	}
+	for _, e := range item.Events {
+		if len(e.Payload) > maxPayloadSize {
+			return fmt.Errorf("payload of %s event is too big, max: %d", e.Name, maxPayloadSize)
+		}
+		switch e.Type {
+		case "custom", "issue", "metadata":
+			if e.Name == "" {
+				return fmt.Errorf("name of %s event is empty", e.Type)
+			}
+		case "user_id":
+			if e.Value == "" {
+				return errors.New("user id is empty")
+			}
+		default:
+			return fmt.Errorf("unsupported event type: %s", e.Type)
+		}
+	}
+	return nil
+}
+
+// eventTime keeps the server clock of the service if it's close to ours, services send events with a delay
+func eventTime(e *Event, now time.Time) uint64 {
+	ts := time.UnixMilli(e.Timestamp)
+	if e.Timestamp <= 0 || ts.After(now) || ts.Before(now.Add(-maxEventDelay)) {
+		ts = now
+	}
+	return uint64(ts.UnixMilli())
+}
+
+// messageIndex keeps retried events deduplicated in the database
+func messageIndex(e *Event, ts uint64) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(fmt.Sprintf("%s:%s:%s:%d", e.MessageID, e.Type, e.Name, ts)))
+	return h.Sum64()
+}
+
+// ToMessages encodes events of the validated item as they are produced to the analytics topic
+func (item *Item) ToMessages(now time.Time) []byte {
+	var data []byte
+	for _, e := range item.Events {
+		ts := eventTime(e, now)
+		payload := string(e.Payload)
+		var msg messages.Message
+		switch e.Type {
+		case "custom":
+			msg = &messages.CustomEvent{
+				MessageID: messageIndex(e, ts),
+				Timestamp: ts,
+				Name:      e.Name,
+				Payload:   payload,
+			}
+		case "issue":
+			msg = &messages.IssueEvent{
+				MessageID:     messageIndex(e, ts),
+				Timestamp:     ts,
+				Type:          "custom",
+				ContextString: e.Name,
+				Payload:       payload,
+			}
+		case "metadata":
+			msg = &messages.Metadata{Key: e.Name, Value: e.Value}
+		case "user_id":
+			msg = &messages.UserID{ID: e.Value}
+		}
+		data = append(data, messages.Encode(msg)...)
+	}
+	return data
+}
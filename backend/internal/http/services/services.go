@@ -1,12 +1,22 @@
 package services
 
 import (
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+
 	"openreplay/backend/internal/config/http"
+	"openreplay/backend/internal/http/botfilter"
 	"openreplay/backend/internal/http/geoip"
+	"openreplay/backend/internal/http/remoteconfig"
 	"openreplay/backend/internal/http/uaparser"
 	"openreplay/backend/pkg/db/cache"
+	"openreplay/backend/pkg/dictionaries"
 	"openreplay/backend/pkg/flakeid"
+	"openreplay/backend/pkg/intervals"
 	"openreplay/backend/pkg/queue/types"
+	"openreplay/backend/pkg/search"
 	"openreplay/backend/pkg/storage"
 	"openreplay/backend/pkg/token"
 )
@@ -18,17 +28,106 @@ type ServicesBuilder struct {
 	UaParser  *uaparser.UAParser
 	GeoIP     *geoip.GeoIP
 	Tokenizer *token.Tokenizer
-	Storage   *storage.S3
+	Storage   storage.ObjectStorage
+	// Replay access, initialized only if JWT_SECRET is set
+	JWTValidator   *token.JWTValidator
+	SessionStorage storage.ObjectStorage
+	AssetsStorage  storage.ObjectStorage
+	ReplayCache    *storage.DiskCache  // initialized only if REPLAY_CACHE_DIR and REPLAY_CACHE_URL are set
+	Dictionaries   *dictionaries.Store // initialized only if REPLAY_DICTIONARIES and REPLAY_CACHE_URL are set
+	// Session search, initialized only if CLICKHOUSE_STRING is set (enterprise edition)
+	Searcher search.Searcher
+	// Bot and synthetic traffic detection, initialized only if BOT_FILTER_ACTION is set
+	BotFilter *botfilter.Filter
+	// Remote configuration polled by trackers
+	RemoteConfigs *remoteconfig.Configs
 }
 
 func New(cfg *http.Config, producer types.Producer, pgconn *cache.PGCache) *ServicesBuilder {
-	return &ServicesBuilder{
+	objStorage, err := storage.NewObjectStorage(cfg.StorageProvider, cfg.AWSRegion, cfg.S3BucketIOSImages)
+	if err != nil {
+		log.Fatalf("can't init object storage: %s", err)
+	}
+	builder := &ServicesBuilder{
 		Database:  pgconn,
 		Producer:  producer,
-		Storage:   storage.NewS3(cfg.AWSRegion, cfg.S3BucketIOSImages),
+		Storage:   objStorage,
 		Tokenizer: token.NewTokenizer(cfg.TokenSecret),
 		UaParser:  uaparser.NewUAParser(cfg.UAParserFile),
 		GeoIP:     geoip.NewGeoIP(cfg.MaxMinDBFile),
 		Flaker:    flakeid.NewFlaker(cfg.WorkerID),
 	}
+	if cfg.JWTSecret != "" {
+		builder.JWTValidator = token.NewJWTValidator(cfg.JWTSecret, cfg.JWTIssuer)
+		if builder.SessionStorage, err = storage.NewObjectStorage(cfg.StorageProvider, cfg.AWSRegion, cfg.S3BucketWeb); err != nil {
+			log.Fatalf("can't init sessions storage: %s", err)
+		}
+		if builder.AssetsStorage, err = storage.NewObjectStorage(cfg.StorageProvider, cfg.AWSRegion, cfg.S3BucketAssets); err != nil {
+			log.Fatalf("can't init assets storage: %s", err)
+		}
+		if (cfg.ReplayCacheDir != "" || cfg.ReplayDictionaries) && cfg.ReplayCacheURL != "" && cfg.ReplayCacheSecret == "" {
+			// Anyone could sign urls of files served by this service with the empty key
+			log.Fatalf("REPLAY_CACHE_SECRET is required for the replay cache and dictionaries")
+		}
+		if cfg.ReplayCacheDir != "" && cfg.ReplayCacheURL != "" {
+			// Files are evicted by the storage service
+			if builder.ReplayCache, err = storage.NewDiskCache(cfg.ReplayCacheDir, 0); err != nil {
+				log.Fatalf("can't init replay cache: %s", err)
+			}
+		}
+		if cfg.ReplayDictionaries {
+			if cfg.ReplayCacheURL == "" {
+				log.Fatalf("REPLAY_CACHE_URL is required for the replay dictionaries")
+			}
+			// Dictionaries are stored next to session files, archived ones are immutable so ttl doesn't matter
+			if builder.Dictionaries, err = dictionaries.NewStore(builder.SessionStorage, time.Hour); err != nil {
+				log.Fatalf("can't init dictionaries store: %s", err)
+			}
+		}
+	}
+	if cfg.ClickHouse != "" {
+		if builder.Searcher, err = search.NewSearcher(cfg.ClickHouse); err != nil {
+			log.Printf("can't init session search: %s", err)
+		}
+	}
+	if cfg.BotFilterAction != "" {
+		if builder.BotFilter, err = botfilter.New(cfg.BotFilterAction, cfg.BotSampleRate, cfg.BotUAPatterns,
+			cfg.BotIPRanges, cfg.BotIPRangesFile); err != nil {
+			log.Fatalf("can't init bot filter: %s", err)
+		}
+	}
+	defaults := remoteconfig.Document{
+		HeartbeatInterval: intervals.HEARTBEAT_INTERVAL,
+		Obfuscation: remoteconfig.Obfuscation{
+			TextMasking:  cfg.TrackerTextMasking,
+			InputMasking: cfg.TrackerInputMasking,
+			EmailMasking: cfg.TrackerEmailMasking,
+		},
+		Endpoints: remoteconfig.Endpoints{
+			Ingest: cfg.TrackerIngestURL,
+			Assets: cfg.TrackerAssetsURL,
+		},
+	}
+	if builder.RemoteConfigs, err = remoteconfig.New(defaults, trackerConfigLoader(pgconn), cfg.RemoteConfigTTL); err != nil {
+		log.Fatalf("can't init remote config: %s", err)
+	}
+	return builder
+}
+
+// trackerConfigLoader reads the project sample rate and its overrides of the remote configuration
+func trackerConfigLoader(pgconn *cache.PGCache) remoteconfig.Loader {
+	return func(projectKey string) (int, []byte, error) {
+		project, err := pgconn.GetProjectByKey(projectKey)
+		if err == pgx.ErrNoRows || err == nil && project == nil {
+			return 0, nil, remoteconfig.ErrUnknownProject
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		overrides, err := pgconn.Conn.GetTrackerConfig(project.ProjectID)
+		if err != nil {
+			return 0, nil, err
+		}
+		return int(project.SampleRate), overrides, nil
+	}
 }
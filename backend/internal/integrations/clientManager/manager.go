@@ -5,18 +5,24 @@ import (
 	"strconv"
 
 	"openreplay/backend/pkg/db/postgres"
+	"openreplay/backend/pkg/tiers"
 )
 
 type manager struct {
 	clientMap          integration.ClientMap
+	projects           map[string]uint32 // clientMap key -> project id
+	tiers              *tiers.Tiers
+	requests           int // number of RequestAll calls, projects of low tiers are requested every few calls
 	Events             chan *integration.SessionErrorEvent
 	Errors             chan error
 	RequestDataUpdates chan postgres.Integration // not pointer because it could change in other thread
 }
 
-func NewManager() *manager {
+func NewManager(projectTiers *tiers.Tiers) *manager {
 	return &manager{
 		clientMap:          make(integration.ClientMap),
+		projects:           make(map[string]uint32),
+		tiers:              projectTiers,
 		RequestDataUpdates: make(chan postgres.Integration, 100),
 		Events:             make(chan *integration.SessionErrorEvent, 100),
 		Errors:             make(chan error, 100),
@@ -28,8 +34,10 @@ func (m *manager) Update(i *postgres.Integration) error {
 	key := strconv.Itoa(int(i.ProjectID)) + i.Provider
 	if i.Options == nil {
 		delete(m.clientMap, key)
+		delete(m.projects, key)
 		return nil
 	}
+	m.projects[key] = i.ProjectID
 	c, exists := m.clientMap[key]
 	if !exists {
 		c, err := integration.NewClient(i, m.RequestDataUpdates, m.Events, m.Errors)
@@ -43,7 +51,11 @@ func (m *manager) Update(i *postgres.Integration) error {
 }
 
 func (m *manager) RequestAll() {
-	for _, c := range m.clientMap {
+	for key, c := range m.clientMap {
+		if m.tiers != nil && m.requests%m.tiers.Get(m.projects[key]).IntervalFactor() != 0 {
+			continue
+		}
 		go c.Request()
 	}
+	m.requests++
 }
@@ -6,14 +6,22 @@ import (
 	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
 	"log"
 	"openreplay/backend/pkg/monitoring"
+	"openreplay/backend/pkg/queue/types"
 	"time"
 )
 
 // EndedSessionHandler handler for ended sessions
 type EndedSessionHandler func(sessionID uint64, timestamp int64) bool
 
+// Ender finds sessions without new messages, state is kept in memory (SessionEnder) or in redis (RedisEnder)
+type Ender interface {
+	UpdateSession(sessionID uint64, partition types.TopicPartition, timestamp, msgTimestamp int64)
+	HandleEndedSessions(handler EndedSessionHandler)
+}
+
 // session holds information about user's session live status
 type session struct {
+	partition     types.TopicPartition
 	lastTimestamp int64
 	lastUpdate    int64
 	lastUserTime  int64
@@ -52,7 +60,7 @@ func New(metrics *monitoring.Metrics, timeout int64, parts int) (*SessionEnder,
 }
 
 // UpdateSession save timestamp for new sessions and update for existing sessions
-func (se *SessionEnder) UpdateSession(sessionID uint64, timestamp, msgTimestamp int64) {
+func (se *SessionEnder) UpdateSession(sessionID uint64, partition types.TopicPartition, timestamp, msgTimestamp int64) {
 	localTS := time.Now().UnixMilli()
 	currTS := timestamp
 	if currTS == 0 {
@@ -63,6 +71,7 @@ func (se *SessionEnder) UpdateSession(sessionID uint64, timestamp, msgTimestamp
 	sess, ok := se.sessions[sessionID]
 	if !ok {
 		se.sessions[sessionID] = &session{
+			partition:     partition,
 			lastTimestamp: currTS,       // timestamp from message broker
 			lastUpdate:    localTS,      // local timestamp
 			lastUserTime:  msgTimestamp, // last timestamp from user's machine
@@ -0,0 +1,76 @@
+package sessionender
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"openreplay/backend/pkg/queue/types"
+)
+
+// sessionState is a serializable copy of session live status
+type sessionState struct {
+	LastTimestamp int64 `json:"lastTimestamp"`
+	LastUserTime  int64 `json:"lastUserTime"`
+	IsEnded       bool  `json:"isEnded"`
+}
+
+// ExportPartitions serializes sessions of revoked partitions and stops tracking them
+func (se *SessionEnder) ExportPartitions(partitions []types.TopicPartition) (map[types.TopicPartition][]byte, error) {
+	revoked := make(map[types.TopicPartition]map[uint64]sessionState, len(partitions)) // map[partition]map[sessionID]state
+	for _, partition := range partitions {
+		revoked[partition] = make(map[uint64]sessionState)
+	}
+	for sessID, sess := range se.sessions {
+		states, ok := revoked[sess.partition]
+		if !ok {
+			continue
+		}
+		states[sessID] = sessionState{
+			LastTimestamp: sess.lastTimestamp,
+			LastUserTime:  sess.lastUserTime,
+			IsEnded:       sess.isEnded,
+		}
+		delete(se.sessions, sessID)
+		se.activeSessions.Add(context.Background(), -1)
+	}
+	result := make(map[types.TopicPartition][]byte, len(revoked))
+	for partition, states := range revoked {
+		if len(states) == 0 {
+			continue
+		}
+		data, err := json.Marshal(states)
+		if err != nil {
+			return nil, err
+		}
+		result[partition] = data
+	}
+	return result, nil
+}
+
+// ImportPartition continues tracking of sessions received from another ender instance
+func (se *SessionEnder) ImportPartition(partition types.TopicPartition, data []byte) error {
+	states := make(map[uint64]sessionState)
+	if err := json.Unmarshal(data, &states); err != nil {
+		return err
+	}
+	localTS := time.Now().UnixMilli()
+	for sessID, state := range states {
+		if _, ok := se.sessions[sessID]; ok {
+			continue
+		}
+		if se.timeCtrl.LastTimestamp(sessID) < state.LastTimestamp {
+			se.timeCtrl.UpdateTime(sessID, state.LastTimestamp)
+		}
+		// Local time is not comparable between instances, so inactivity timeout starts from the moment of handoff
+		se.sessions[sessID] = &session{
+			partition:     partition,
+			lastTimestamp: state.LastTimestamp,
+			lastUpdate:    localTS,
+			lastUserTime:  state.LastUserTime,
+			isEnded:       state.IsEnded,
+		}
+		se.activeSessions.Add(context.Background(), 1)
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package sessionender
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+
+	"openreplay/backend/pkg/monitoring"
+	"openreplay/backend/pkg/queue/types"
+)
+
+const (
+	redisStateTTL   = 24 * time.Hour // limits the life of sessions which were never ended
+	redisClaimTTL   = time.Minute    // one instance handles the ended session, the others skip it
+	redisSweepLimit = 1000
+)
+
+// updateScript keeps the highest user's timestamp, renews the activity key and returns 1 for new sessions
+var updateScript = redis.NewScript(`
+local created = redis.call('EXISTS', KEYS[1]) == 0
+local prev = tonumber(redis.call('GET', KEYS[1]) or '0')
+if tonumber(ARGV[1]) > prev then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[4])
+else
+	redis.call('PEXPIRE', KEYS[1], ARGV[4])
+end
+redis.call('SET', KEYS[2], '1', 'PX', ARGV[2])
+redis.call('ZADD', KEYS[3], ARGV[3], ARGV[5])
+if created then return 1 end
+return 0
+`)
+
+// endScript deletes the state of the ended session unless the session was continued during the handling
+var endScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[2]) == 1 then return 0 end
+redis.call('DEL', KEYS[1])
+redis.call('ZREM', KEYS[3], ARGV[1])
+return 1
+`)
+
+// RedisEnder keeps session activity in redis, so the service doesn't have state of its own and
+// doesn't need partition handoff. Every session has the activity key with the TTL of the session end
+// timeout, its expiration notification means the end of the session. Notifications aren't delivered
+// while the service is down, sessions without activity are also found by the periodic sweep.
+type RedisEnder struct {
+	client        *redis.Client
+	prefix        string
+	timeout       time.Duration
+	updates       map[uint64]int64 // map[sessionID]userTime, flushed to redis before handling ended sessions
+	pendingMutex  sync.Mutex
+	pending       map[uint64]bool // sessions with expired activity keys
+	totalSessions syncfloat64.Counter
+	endedSessions syncfloat64.Counter
+}
+
+func NewRedis(metrics *monitoring.Metrics, addr, group string, timeout int64) (*RedisEnder, error) {
+	switch {
+	case metrics == nil:
+		return nil, fmt.Errorf("metrics module is empty")
+	case addr == "":
+		return nil, fmt.Errorf("redis address is empty")
+	case group == "":
+		return nil, fmt.Errorf("consumer group is empty")
+	}
+	totalSessions, err := metrics.RegisterCounter("sessions_total")
+	if err != nil {
+		return nil, fmt.Errorf("can't register session.total metric: %s", err)
+	}
+	endedSessions, err := metrics.RegisterCounter("sessions_ended")
+	if err != nil {
+		return nil, fmt.Errorf("can't register session.ended metric: %s", err)
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr: addr,
+	})
+	if _, err := client.Ping().Result(); err != nil {
+		return nil, fmt.Errorf("can't connect to redis: %s", err)
+	}
+	se := &RedisEnder{
+		client:        client,
+		prefix:        "ender:" + group + ":",
+		timeout:       time.Duration(timeout) * time.Millisecond,
+		updates:       make(map[uint64]int64),
+		pending:       make(map[uint64]bool),
+		totalSessions: totalSessions,
+		endedSessions: endedSessions,
+	}
+	if err := se.enableNotifications(); err != nil {
+		log.Printf("can't enable keyspace notifications, sessions will be ended by sweep only: %s", err)
+	}
+	go se.listen()
+	return se, nil
+}
+
+func (se *RedisEnder) sessionKey(sessionID uint64) string {
+	return se.prefix + "session:" + strconv.FormatUint(sessionID, 10)
+}
+
+func (se *RedisEnder) activeKey(sessionID uint64) string {
+	return se.prefix + "active:" + strconv.FormatUint(sessionID, 10)
+}
+
+func (se *RedisEnder) claimKey(sessionID uint64) string {
+	return se.prefix + "claim:" + strconv.FormatUint(sessionID, 10)
+}
+
+func (se *RedisEnder) sessionsKey() string {
+	return se.prefix + "sessions"
+}
+
+// enableNotifications adds expired events to the redis config, managed redis may not allow it
+func (se *RedisEnder) enableNotifications() error {
+	res, err := se.client.ConfigGet("notify-keyspace-events").Result()
+	if err != nil {
+		return err
+	}
+	flags := ""
+	if len(res) == 2 {
+		flags, _ = res[1].(string)
+	}
+	hasExpired := strings.Contains(flags, "x") || strings.Contains(flags, "A")
+	if strings.Contains(flags, "E") && hasExpired {
+		return nil
+	}
+	if !strings.Contains(flags, "E") {
+		flags += "E"
+	}
+	if !hasExpired {
+		flags += "x"
+	}
+	return se.client.ConfigSet("notify-keyspace-events", flags).Err()
+}
+
+// listen collects expired activity keys, go-redis reconnects the subscription by itself
+func (se *RedisEnder) listen() {
+	pubsub := se.client.PSubscribe("__keyevent@*__:expired")
+	activePrefix := se.prefix + "active:"
+	for msg := range pubsub.Channel() {
+		if !strings.HasPrefix(msg.Payload, activePrefix) {
+			continue
+		}
+		sessionID, err := strconv.ParseUint(strings.TrimPrefix(msg.Payload, activePrefix), 10, 64)
+		if err != nil {
+			log.Printf("wrong activity key: %s", msg.Payload)
+			continue
+		}
+		se.pendingMutex.Lock()
+		se.pending[sessionID] = true
+		se.pendingMutex.Unlock()
+	}
+}
+
+// UpdateSession keeps the highest user's timestamp of the session until the next flush
+func (se *RedisEnder) UpdateSession(sessionID uint64, partition types.TopicPartition, timestamp, msgTimestamp int64) {
+	if timestamp == 0 {
+		log.Printf("got empty timestamp for sessionID: %d", sessionID)
+		return
+	}
+	if userTime, ok := se.updates[sessionID]; !ok || msgTimestamp > userTime {
+		se.updates[sessionID] = msgTimestamp
+	}
+}
+
+// flush renews activity keys of all sessions updated since the previous flush in one pipeline
+func (se *RedisEnder) flush() error {
+	if len(se.updates) == 0 {
+		return nil
+	}
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	pipe := se.client.Pipeline()
+	cmds := make([]*redis.Cmd, 0, len(se.updates))
+	for sessionID, userTime := range se.updates {
+		cmds = append(cmds, updateScript.Eval(pipe,
+			[]string{se.sessionKey(sessionID), se.activeKey(sessionID), se.sessionsKey()},
+			userTime, se.timeout.Milliseconds(), now, redisStateTTL.Milliseconds(), sessionID,
+		))
+	}
+	if _, err := pipe.Exec(); err != nil {
+		return err
+	}
+	created := 0
+	for _, cmd := range cmds {
+		if res, _ := cmd.Int64(); res == 1 {
+			created++
+		}
+	}
+	se.totalSessions.Add(context.Background(), float64(created))
+	se.updates = make(map[uint64]int64)
+	return nil
+}
+
+// candidates returns notified sessions and sessions which were missed by notifications
+func (se *RedisEnder) candidates() []uint64 {
+	se.pendingMutex.Lock()
+	ids := make(map[uint64]bool, len(se.pending))
+	for sessionID := range se.pending {
+		ids[sessionID] = true
+	}
+	se.pendingMutex.Unlock()
+
+	// Twice the timeout, so the notified sessions are usually ended before the sweep
+	maxScore := strconv.FormatInt(time.Now().Add(-2*se.timeout).UnixMilli(), 10)
+	missed, err := se.client.ZRangeByScore(se.sessionsKey(), redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   maxScore,
+		Count: redisSweepLimit,
+	}).Result()
+	if err != nil {
+		log.Printf("can't sweep inactive sessions: %s", err)
+	}
+	for _, member := range missed {
+		if sessionID, err := strconv.ParseUint(member, 10, 64); err == nil {
+			ids[sessionID] = true
+		}
+	}
+	result := make([]uint64, 0, len(ids))
+	for sessionID := range ids {
+		result = append(result, sessionID)
+	}
+	return result
+}
+
+func (se *RedisEnder) done(sessionID uint64) {
+	se.pendingMutex.Lock()
+	delete(se.pending, sessionID)
+	se.pendingMutex.Unlock()
+}
+
+// HandleEndedSessions runs handler for each ended session and delete information about session in successful case
+func (se *RedisEnder) HandleEndedSessions(handler EndedSessionHandler) {
+	if err := se.flush(); err != nil {
+		log.Printf("can't save sessions activity: %s", err)
+		return
+	}
+	sessions, removedSessions := se.candidates(), 0
+	for _, sessID := range sessions {
+		// The session could be continued after the notification
+		if active, err := se.client.Exists(se.activeKey(sessID)).Result(); err != nil || active > 0 {
+			if err == nil {
+				se.done(sessID)
+			}
+			continue
+		}
+		claimed, err := se.client.SetNX(se.claimKey(sessID), "1", redisClaimTTL).Result()
+		if err != nil {
+			log.Printf("can't claim ended session, sessID: %d, err: %s", sessID, err)
+			continue
+		}
+		if !claimed {
+			se.done(sessID)
+			continue
+		}
+		userTime, err := se.client.Get(se.sessionKey(sessID)).Int64()
+		if err == redis.Nil {
+			// Already ended by another instance
+			se.client.ZRem(se.sessionsKey(), sessID)
+			se.done(sessID)
+			continue
+		}
+		if err != nil {
+			log.Printf("can't get session state, sessID: %d, err: %s", sessID, err)
+			se.client.Del(se.claimKey(sessID))
+			continue
+		}
+		if !handler(sessID, userTime) {
+			log.Printf("sessID: %d, userTime: %d", sessID, userTime)
+			se.client.Del(se.claimKey(sessID))
+			continue
+		}
+		// Late notifications of other instances are skipped, because the state doesn't exist anymore
+		err = endScript.Run(se.client,
+			[]string{se.sessionKey(sessID), se.activeKey(sessID), se.sessionsKey()}, sessID,
+		).Err()
+		if err != nil {
+			log.Printf("can't delete session state, sessID: %d, err: %s", sessID, err)
+		}
+		se.client.Del(se.claimKey(sessID))
+		se.done(sessID)
+		se.endedSessions.Add(context.Background(), 1)
+		removedSessions++
+	}
+	log.Printf("Removed %d of %d sessions", removedSessions, len(sessions))
+}
@@ -0,0 +1,159 @@
+package canvas
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+
+	"openreplay/backend/pkg/messages"
+	"openreplay/backend/pkg/monitoring"
+	"openreplay/backend/pkg/storage"
+)
+
+// Formats of canvas snapshots which browsers can encode, tracker can send them with the image/ prefix
+var formats = map[string]bool{"png": true, "jpeg": true, "webp": true}
+
+type sessionCanvas struct {
+	size    int
+	updated time.Time
+}
+
+// Writer keeps images of canvas snapshots out of the session file, they are uploaded by the storage
+// service next to it. Recording gets the CanvasNode message instead, so the player knows which
+// image to draw and when.
+type Writer struct {
+	dir            string
+	maxSessionSize int
+	idleTimeout    time.Duration
+	sessions       map[uint64]*sessionCanvas
+	saved          syncfloat64.Counter
+	savedBytes     syncfloat64.Counter
+	dropped        syncfloat64.Counter
+}
+
+// New creates the canvas directory in fsDir, maxSessionSize limits images of one session, 0 means no limit
+func New(fsDir string, maxSessionSize int, idleTimeout time.Duration, metrics *monitoring.Metrics) (*Writer, error) {
+	switch {
+	case fsDir == "":
+		return nil, fmt.Errorf("fs dir is empty")
+	case maxSessionSize < 0:
+		return nil, fmt.Errorf("max session size can't be negative")
+	case metrics == nil:
+		return nil, fmt.Errorf("metrics module is empty")
+	}
+	dir := filepath.Join(fsDir, storage.CanvasDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("can't create canvas dir: %s", err)
+	}
+	saved, err := metrics.RegisterCounter("canvas_snapshots_saved")
+	if err != nil {
+		return nil, fmt.Errorf("can't register canvas_snapshots_saved metric: %s", err)
+	}
+	savedBytes, err := metrics.RegisterCounter("canvas_snapshots_bytes")
+	if err != nil {
+		return nil, fmt.Errorf("can't register canvas_snapshots_bytes metric: %s", err)
+	}
+	dropped, err := metrics.RegisterCounter("canvas_snapshots_dropped")
+	if err != nil {
+		return nil, fmt.Errorf("can't register canvas_snapshots_dropped metric: %s", err)
+	}
+	return &Writer{
+		dir:            dir,
+		maxSessionSize: maxSessionSize,
+		idleTimeout:    idleTimeout,
+		sessions:       make(map[uint64]*sessionCanvas),
+		saved:          saved,
+		savedBytes:     savedBytes,
+		dropped:        dropped,
+	}, nil
+}
+
+func (w *Writer) drop(reason string) messages.Message {
+	w.dropped.Add(context.Background(), 1, attribute.String("reason", reason))
+	return nil
+}
+
+// Write saves the image and returns the message for the session file, nil if the snapshot is dropped
+func (w *Writer) Write(sessionID uint64, msg *messages.CanvasSnapshot) messages.Message {
+	format := strings.TrimPrefix(strings.ToLower(msg.Format), "image/")
+	if format == "jpg" {
+		format = "jpeg"
+	}
+	if !formats[format] {
+		return w.drop("format")
+	}
+	// canvas.toDataURL() result can be sent as is
+	encoded := msg.Data
+	if strings.HasPrefix(encoded, "data:") {
+		if i := strings.Index(encoded, ","); i >= 0 {
+			encoded = encoded[i+1:]
+		}
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return w.drop("decode")
+	}
+	if len(data) == 0 {
+		return w.drop("empty")
+	}
+	s, ok := w.sessions[sessionID]
+	if !ok {
+		s = &sessionCanvas{}
+		w.sessions[sessionID] = s
+	}
+	s.updated = time.Now()
+	if w.maxSessionSize > 0 && s.size+len(data) > w.maxSessionSize {
+		return w.drop("session_size")
+	}
+
+	timestamp := msg.Timestamp
+	if timestamp == 0 {
+		timestamp = uint64(msg.Meta().Timestamp)
+	}
+	dir := filepath.Join(w.dir, strconv.FormatUint(sessionID, 10))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("can't create canvas dir, sessID: %d, err: %s", sessionID, err)
+		return w.drop("write")
+	}
+	path := filepath.Join(dir, storage.CanvasFileName(msg.NodeID, timestamp, format))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		log.Printf("can't write canvas snapshot, sessID: %d, err: %s", sessionID, err)
+		return w.drop("write")
+	}
+	s.size += len(data)
+	w.saved.Add(context.Background(), 1)
+	w.savedBytes.Add(context.Background(), float64(len(data)))
+
+	node := &messages.CanvasNode{
+		NodeID:    msg.NodeID,
+		Timestamp: timestamp,
+		Format:    format,
+		Size:      uint64(len(data)),
+	}
+	node.SetMeta(msg.Meta())
+	return node
+}
+
+// Delete is called at the end of the session, files are removed by the storage service after upload
+func (w *Writer) Delete(sessionID uint64) {
+	delete(w.sessions, sessionID)
+}
+
+// Cleanup removes size accounting of sessions which didn't send snapshots for the idle timeout
+func (w *Writer) Cleanup() {
+	now := time.Now()
+	for sessionID, s := range w.sessions {
+		if now.Sub(s.updated) > w.idleTimeout {
+			delete(w.sessions, sessionID)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package domdict
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+
+	"openreplay/backend/pkg/messages"
+	"openreplay/backend/pkg/monitoring"
+)
+
+const (
+	minValueSize  = 16 // shorter values are written as is, dictionary entry costs more
+	minPrefixSize = 8  // shorter common prefixes aren't worth the reference to the base string
+)
+
+type sessionDict struct {
+	keys      map[string]uint64
+	lastValue map[string]string // last value of every attribute name, it's the base of the next one
+	nextKey   uint64
+	updated   time.Time
+}
+
+// Encoder replaces SetNodeAttribute messages with references to session dictionary strings.
+// Class lists, styles and urls of sibling nodes are repeated or differ in the tail only, so each
+// string is written once and near-identical ones are written as the suffix of the previous value
+// of the same attribute. Dictionary is kept in memory, it starts from scratch after restarts,
+// readers keep the last definition of every key.
+type Encoder struct {
+	maxEntries   int
+	idleTimeout  time.Duration
+	sessions     map[uint64]*sessionDict
+	inputBytes   syncfloat64.Counter
+	encodedBytes syncfloat64.Counter
+}
+
+func New(maxEntries int, idleTimeout time.Duration, metrics *monitoring.Metrics) (*Encoder, error) {
+	switch {
+	case maxEntries <= 0:
+		return nil, fmt.Errorf("max entries should be positive")
+	case idleTimeout <= 0:
+		return nil, fmt.Errorf("idle timeout should be positive")
+	case metrics == nil:
+		return nil, fmt.Errorf("metrics module is empty")
+	}
+	inputBytes, err := metrics.RegisterCounter("dom_dict_input_bytes")
+	if err != nil {
+		return nil, fmt.Errorf("can't register dom_dict_input_bytes metric: %s", err)
+	}
+	encodedBytes, err := metrics.RegisterCounter("dom_dict_encoded_bytes")
+	if err != nil {
+		return nil, fmt.Errorf("can't register dom_dict_encoded_bytes metric: %s", err)
+	}
+	return &Encoder{
+		maxEntries:   maxEntries,
+		idleTimeout:  idleTimeout,
+		sessions:     make(map[uint64]*sessionDict),
+		inputBytes:   inputBytes,
+		encodedBytes: encodedBytes,
+	}, nil
+}
+
+// asciiPrefix is measured in bytes by the backend and in characters by the player, they are equal for ascii only
+func asciiPrefix(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] && a[n] < 0x80 {
+		n++
+	}
+	return n
+}
+
+// key returns the key of the string and appends its definition if it's new
+func (s *sessionDict) key(str, attrName string, defs []messages.Message) (uint64, []messages.Message) {
+	if key, ok := s.keys[str]; ok {
+		return key, defs
+	}
+	s.nextKey++
+	def := &messages.StringDict{Key: s.nextKey, Suffix: str}
+	if base, ok := s.lastValue[attrName]; ok && attrName != "" {
+		if prefix := asciiPrefix(base, str); prefix >= minPrefixSize {
+			def.BaseKey = s.keys[base]
+			def.PrefixLength = uint64(prefix)
+			def.Suffix = str[prefix:]
+		}
+	}
+	s.keys[str] = s.nextKey
+	return s.nextKey, append(defs, def)
+}
+
+// Encode returns messages to write instead of the given one, other messages are returned as is
+func (e *Encoder) Encode(sessionID uint64, msg messages.Message) []messages.Message {
+	if msg.TypeID() != messages.MsgSetNodeAttribute {
+		return []messages.Message{msg}
+	}
+	decoded := msg.Decode()
+	if decoded == nil {
+		return []messages.Message{msg}
+	}
+	attr := decoded.(*messages.SetNodeAttribute)
+	if len(attr.Value) < minValueSize {
+		return []messages.Message{msg}
+	}
+	s, ok := e.sessions[sessionID]
+	if !ok {
+		s = &sessionDict{keys: make(map[string]uint64), lastValue: make(map[string]string)}
+		e.sessions[sessionID] = s
+	}
+	s.updated = time.Now()
+	newEntries := 0
+	for _, str := range []string{attr.Name, attr.Value} {
+		if _, ok := s.keys[str]; !ok {
+			newEntries++
+		}
+	}
+	if len(s.keys)+newEntries > e.maxEntries {
+		return []messages.Message{msg}
+	}
+
+	var defs []messages.Message
+	nameKey, defs := s.key(attr.Name, "", defs)
+	valueKey, defs := s.key(attr.Value, attr.Name, defs)
+	s.lastValue[attr.Name] = attr.Value
+	ref := &messages.SetNodeAttributeDict{ID: attr.ID, NameKey: nameKey, ValueKey: valueKey}
+	res := append(defs, ref)
+
+	// Definitions have the index of the message, so readers don't skip them as out of order
+	encodedSize := 0
+	for _, m := range res {
+		m.Meta().SetMeta(msg.Meta())
+		encodedSize += len(m.Encode())
+	}
+	e.inputBytes.Add(context.Background(), float64(len(attr.Encode())))
+	e.encodedBytes.Add(context.Background(), float64(encodedSize))
+	return res
+}
+
+// Delete is called at the end of the session
+func (e *Encoder) Delete(sessionID uint64) {
+	delete(e.sessions, sessionID)
+}
+
+// Cleanup removes dictionaries of sessions which didn't change attributes for the idle timeout
+func (e *Encoder) Cleanup() {
+	now := time.Now()
+	for sessionID, s := range e.sessions {
+		if now.Sub(s.updated) > e.idleTimeout {
+			delete(e.sessions, sessionID)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package timeorder
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+
+	"openreplay/backend/pkg/messages"
+	"openreplay/backend/pkg/monitoring"
+)
+
+// sessionTime is kept until the end of the session or until it's idle for longer than the idle timeout
+type sessionTime struct {
+	last    int64 // last written timestamp
+	offset  int64 // shift of the session clock after it jumped back
+	updated time.Time
+}
+
+// Keeper makes timestamps of written messages non-decreasing within each session. The player places
+// messages by the preceding Timestamp message, so going back in time breaks the replay.
+// Small steps back (network reordering, clock jitter) are clamped to the last timestamp.
+// Bigger ones mean the tracker clock was changed, the rest of the session is re-stamped with the offset,
+// otherwise all messages up to the old time would be squashed into one moment.
+type Keeper struct {
+	tolerance   int64
+	idleTimeout time.Duration
+	sessions    map[uint64]*sessionTime
+	clamped     syncfloat64.Counter
+	restamped   syncfloat64.Counter
+}
+
+func New(tolerance, idleTimeout time.Duration, metrics *monitoring.Metrics) (*Keeper, error) {
+	switch {
+	case tolerance < 0:
+		return nil, fmt.Errorf("tolerance can't be negative")
+	case idleTimeout <= 0:
+		return nil, fmt.Errorf("idle timeout should be positive")
+	case metrics == nil:
+		return nil, fmt.Errorf("metrics module is empty")
+	}
+	clamped, err := metrics.RegisterCounter("messages_time_clamped")
+	if err != nil {
+		return nil, fmt.Errorf("can't register messages_time_clamped metric: %s", err)
+	}
+	restamped, err := metrics.RegisterCounter("session_clock_jumps")
+	if err != nil {
+		return nil, fmt.Errorf("can't register session_clock_jumps metric: %s", err)
+	}
+	return &Keeper{
+		tolerance:   tolerance.Milliseconds(),
+		idleTimeout: idleTimeout,
+		sessions:    make(map[uint64]*sessionTime),
+		clamped:     clamped,
+		restamped:   restamped,
+	}, nil
+}
+
+// Apply returns the message with the corrected timestamp, Timestamp messages are replaced by new ones.
+// Mobile messages are returned as is, each of them carries its own time.
+func (k *Keeper) Apply(sessionID uint64, msg messages.Message) messages.Message {
+	ts := msg.Meta().Timestamp
+	if ts == 0 || messages.IsIOSType(msg.TypeID()) {
+		return msg
+	}
+	s, ok := k.sessions[sessionID]
+	if !ok {
+		s = &sessionTime{}
+		k.sessions[sessionID] = s
+	}
+	s.updated = time.Now()
+
+	fixed := ts + s.offset
+	if fixed < s.last {
+		if s.last-fixed <= k.tolerance {
+			k.clamped.Add(context.Background(), 1)
+		} else {
+			s.offset += s.last - fixed
+			k.restamped.Add(context.Background(), 1)
+			log.Printf("session clock jumped back by %d ms, re-stamping the rest of the session; sessID: %d, msgType: %d",
+				s.last-fixed, sessionID, msg.TypeID())
+		}
+		fixed = s.last
+	}
+	s.last = fixed
+	if fixed == ts {
+		return msg
+	}
+
+	if msg.TypeID() == messages.MsgTimestamp {
+		newMsg := &messages.Timestamp{Timestamp: uint64(fixed)}
+		newMsg.SetMeta(msg.Meta())
+		msg = newMsg
+	}
+	msg.Meta().Timestamp = fixed
+	return msg
+}
+
+// Delete is called at the end of the session
+func (k *Keeper) Delete(sessionID uint64) {
+	delete(k.sessions, sessionID)
+}
+
+// Cleanup removes sessions which ended without the SessionEnd message
+func (k *Keeper) Cleanup() {
+	deadline := time.Now().Add(-k.idleTimeout)
+	for sessionID, s := range k.sessions {
+		if s.updated.Before(deadline) {
+			delete(k.sessions, sessionID)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"openreplay/backend/pkg/storage"
+)
+
+// uploadCanvas uploads images of canvas snapshots written by the sink for the session. Images are
+// already compressed, they are uploaded as is with their own content type. Local files are removed
+// only if all of them are uploaded.
+func (s *Storage) uploadCanvas(key string, class string) {
+	dir := filepath.Join(s.cfg.FSDir, storage.CanvasDir, key)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("can't read canvas dir, sessID: %s, err: %s", key, err)
+		}
+		return
+	}
+	var totalSize int64
+	uploaded, failed := 0, false
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		file, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			log.Printf("can't open canvas snapshot, sessID: %s, file: %s, err: %s", key, name, err)
+			failed = true
+			continue
+		}
+		contentType := "image/" + strings.TrimPrefix(filepath.Ext(name), ".")
+		err = storage.UploadWithClass(s.s3, file, s.cfg.CanvasPrefix+key+"/"+name, contentType, false, class)
+		file.Close()
+		if err != nil {
+			log.Printf("can't upload canvas snapshot, sessID: %s, file: %s, err: %s", key, name, err)
+			failed = true
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			totalSize += info.Size()
+		}
+		uploaded++
+	}
+	s.canvasSize.Record(context.Background(), float64(totalSize))
+	s.canvasSnapshots.Add(context.Background(), float64(uploaded))
+	if failed {
+		return
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		log.Printf("can't remove canvas dir, sessID: %s, err: %s", key, err)
+	}
+}
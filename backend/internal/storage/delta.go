@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"log"
+
+	"openreplay/backend/pkg/messages/delta"
+)
+
+// encodeDelta returns the original file if there are no repeated snapshots or the result can't be decoded back
+func (s *Storage) encodeDelta(key string, data []byte) []byte {
+	encoded, ok := delta.Encode(data, s.cfg.DeltaMinRun)
+	if !ok {
+		return data
+	}
+	decoded, err := delta.Decode(encoded)
+	if err != nil || !bytes.Equal(decoded, data) {
+		log.Printf("delta encoding isn't reversible, sessID: %s, err: %v", key, err)
+		return data
+	}
+	return encoded
+}
+
+// splitFile keeps the same split as for the original files, player concatenates both parts before decoding
+func (s *Storage) splitFile(data []byte) (io.Reader, io.Reader) {
+	if len(data) <= s.cfg.FileSplitSize {
+		return bytes.NewReader(data), nil
+	}
+	return bytes.NewReader(data[:s.cfg.FileSplitSize]), bytes.NewReader(data[s.cfg.FileSplitSize:])
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"openreplay/backend/pkg/storage"
+)
+
+// liveSession is the uploaded part of the active session file
+type liveSession struct {
+	offset  int64
+	chunks  int
+	updated time.Time
+}
+
+// UploadLive uploads new bytes of active sessions as numbered chunks, so the player can replay a session
+// before it ends. Chunks are gzipped parts of the raw file, the player concatenates them in order.
+// Chunks are deleted after the whole session is uploaded by UploadKey.
+func (s *Storage) UploadLive() {
+	if !atomic.CompareAndSwapInt32(&s.liveRunning, 0, 1) {
+		return // previous upload is still in progress
+	}
+	defer atomic.StoreInt32(&s.liveRunning, 0)
+
+	entries, err := os.ReadDir(s.cfg.FSDir)
+	if err != nil {
+		log.Printf("can't read sessions dir: %s", err)
+		return
+	}
+	now := time.Now()
+	for _, entry := range entries {
+		key := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+		if _, err := strconv.ParseUint(key, 10, 64); err != nil {
+			continue // not a session file
+		}
+		info, err := entry.Info()
+		if err != nil || now.Sub(info.ModTime()) > s.cfg.LiveSessionTimeout {
+			continue
+		}
+		if err := s.uploadLiveChunk(key, info.Size()); err != nil {
+			log.Printf("can't upload live chunk, sessID: %s, err: %s", key, err)
+		}
+	}
+	s.cleanLiveSessions(now)
+}
+
+func (s *Storage) uploadLiveChunk(key string, size int64) error {
+	s.liveMu.Lock()
+	sess, ok := s.live[key]
+	_, finished := s.liveFinished[key]
+	s.liveMu.Unlock()
+	if finished {
+		return nil
+	}
+	if !ok {
+		// Chunks uploaded before the restart could have different boundaries
+		if err := s.deleteLiveChunks(key); err != nil {
+			return err
+		}
+		sess = &liveSession{}
+	}
+	if size <= sess.offset {
+		return nil
+	}
+	file, err := os.Open(s.cfg.FSDir + "/" + key)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	start := time.Now()
+	chunkKey := storage.LiveChunkKey(key, sess.chunks)
+	reader := io.NewSectionReader(file, sess.offset, size-sess.offset)
+	if err := s.s3.Upload(s.gzipFile(reader), chunkKey, "application/octet-stream", true); err != nil {
+		return err
+	}
+	s.liveUploadTime.Record(context.Background(), float64(time.Now().Sub(start).Milliseconds()))
+	s.liveChunks.Add(context.Background(), 1)
+
+	s.liveMu.Lock()
+	_, finished = s.liveFinished[key]
+	if !finished {
+		sess.offset, sess.chunks, sess.updated = size, sess.chunks+1, start
+		s.live[key] = sess
+	}
+	s.liveMu.Unlock()
+	if finished {
+		// Session was uploaded by UploadKey during the chunk upload
+		return s.s3.Delete([]string{chunkKey})
+	}
+	return nil
+}
+
+// finishLive is called when the whole session is uploaded, live chunks aren't needed anymore
+func (s *Storage) finishLive(key string) {
+	s.liveMu.Lock()
+	delete(s.live, key)
+	s.liveFinished[key] = time.Now()
+	s.liveMu.Unlock()
+	if err := s.deleteLiveChunks(key); err != nil {
+		log.Printf("can't delete live chunks, sessID: %s, err: %s", key, err)
+	}
+}
+
+func (s *Storage) deleteLiveChunks(key string) error {
+	var keys []string
+	err := s.s3.Walk(storage.LiveChunksPrefix(key), func(obj *storage.ObjectInfo) error {
+		keys = append(keys, obj.Key)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("can't list live chunks: %s", err)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return s.s3.Delete(keys)
+}
+
+// cleanLiveSessions forgets sessions which weren't updated for too long, their files are removed by the sink
+func (s *Storage) cleanLiveSessions(now time.Time) {
+	s.liveMu.Lock()
+	defer s.liveMu.Unlock()
+	for key, sess := range s.live {
+		if now.Sub(sess.updated) > s.cfg.LiveSessionTimeout {
+			delete(s.live, key)
+		}
+	}
+	for key, ts := range s.liveFinished {
+		if now.Sub(ts) > s.cfg.LiveSessionTimeout {
+			delete(s.liveFinished, key)
+		}
+	}
+}
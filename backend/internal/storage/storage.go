@@ -5,32 +5,72 @@ import (
 	"context"
 	"fmt"
 	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	"io"
 	"log"
 	config "openreplay/backend/internal/config/storage"
+	"openreplay/backend/pkg/db/cache"
+	"openreplay/backend/pkg/dictionaries"
 	"openreplay/backend/pkg/flakeid"
 	"openreplay/backend/pkg/monitoring"
+	"openreplay/backend/pkg/queue/types"
 	"openreplay/backend/pkg/storage"
+	"openreplay/backend/pkg/tiers"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 )
 
 type Storage struct {
 	cfg           *config.Config
-	s3            *storage.S3
-	startBytes    []byte
+	s3            storage.ObjectStorage
+	startBytes    sync.Pool // UploadKey is called concurrently for different partitions
+	dicts         *dictionaries.Store
+	sessions      cache.SessionState
+	classes       *storage.StorageClasses
+	tiers         *tiers.Tiers
+	uploads       *tiers.Limiter // nil if the number of concurrent uploads isn't limited
 	totalSessions syncfloat64.Counter
 	sessionSize   syncfloat64.Histogram
 	readingTime   syncfloat64.Histogram
 	archivingTime syncfloat64.Histogram
+	// Images of canvas snapshots, they are uploaded next to the session file
+	canvasSize      syncfloat64.Histogram
+	canvasSnapshots syncfloat64.Counter
+	// Recently uploaded files for replays, enabled by REPLAY_CACHE_DIR
+	replayCache *storage.DiskCache
+	// Uploaded sessions are announced when their files are readable
+	producer       types.Producer
+	verifyFailures syncfloat64.Counter
+	verifyTime     syncfloat64.Histogram
+	// Progressive upload of active sessions, enabled by USE_LIVE_UPLOAD
+	liveMu         sync.Mutex
+	liveRunning    int32
+	live           map[string]*liveSession
+	liveFinished   map[string]time.Time
+	liveChunks     syncfloat64.Counter
+	liveUploadTime syncfloat64.Histogram
 }
 
-func New(cfg *config.Config, s3 *storage.S3, metrics *monitoring.Metrics) (*Storage, error) {
+// New creates storage service, dicts and sessions are optional and enable dictionary compression.
+// Sessions are also required to find projects with their own storage class or tier of limited uploads.
+// Producer is optional and required only for the session ready events.
+func New(cfg *config.Config, s3 storage.ObjectStorage, dicts *dictionaries.Store, sessions cache.SessionState, classes *storage.StorageClasses, projectTiers *tiers.Tiers, producer types.Producer, metrics *monitoring.Metrics) (*Storage, error) {
 	switch {
 	case cfg == nil:
 		return nil, fmt.Errorf("config is empty")
 	case s3 == nil:
-		return nil, fmt.Errorf("s3 storage is empty")
+		return nil, fmt.Errorf("object storage is empty")
+	case classes == nil:
+		return nil, fmt.Errorf("storage classes are empty")
+	case projectTiers == nil:
+		return nil, fmt.Errorf("project tiers are empty")
+	case (dicts != nil || classes.HasProjects()) && sessions == nil:
+		return nil, fmt.Errorf("session state is empty")
+	case cfg.UploadConcurrency > 0 && projectTiers.HasProjects() && sessions == nil:
+		return nil, fmt.Errorf("session state is empty")
+	case cfg.TopicSessionReady != "" && producer == nil:
+		return nil, fmt.Errorf("producer is empty")
 	}
 	// Create metrics
 	totalSessions, err := metrics.RegisterCounter("sessions_total")
@@ -49,15 +89,65 @@ func New(cfg *config.Config, s3 *storage.S3, metrics *monitoring.Metrics) (*Stor
 	if err != nil {
 		log.Printf("can't create archiving_duration metric: %s", err)
 	}
-	return &Storage{
-		cfg:           cfg,
-		s3:            s3,
-		startBytes:    make([]byte, cfg.FileSplitSize),
+	canvasSize, err := metrics.RegisterHistogram("canvas_sessions_size")
+	if err != nil {
+		log.Printf("can't create canvas_sessions_size metric: %s", err)
+	}
+	canvasSnapshots, err := metrics.RegisterCounter("canvas_snapshots_total")
+	if err != nil {
+		log.Printf("can't create canvas_snapshots_total metric: %s", err)
+	}
+	verifyFailures, err := metrics.RegisterCounter("upload_verify_failures")
+	if err != nil {
+		log.Printf("can't create upload_verify_failures metric: %s", err)
+	}
+	verifyTime, err := metrics.RegisterHistogram("upload_verify_duration")
+	if err != nil {
+		log.Printf("can't create upload_verify_duration metric: %s", err)
+	}
+	s := &Storage{
+		cfg: cfg,
+		s3:  s3,
+		startBytes: sync.Pool{
+			New: func() interface{} { return make([]byte, cfg.FileSplitSize) },
+		},
+		dicts:         dicts,
+		sessions:      sessions,
+		classes:       classes,
+		tiers:         projectTiers,
 		totalSessions: totalSessions,
 		sessionSize:   sessionSize,
 		readingTime:   readingTime,
 		archivingTime: archivingTime,
-	}, nil
+
+		canvasSize:      canvasSize,
+		canvasSnapshots: canvasSnapshots,
+
+		producer:       producer,
+		verifyFailures: verifyFailures,
+		verifyTime:     verifyTime,
+	}
+	if cfg.UploadConcurrency > 0 {
+		if s.uploads, err = tiers.NewLimiter(cfg.UploadConcurrency); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.ReplayCacheDir != "" {
+		if s.replayCache, err = storage.NewDiskCache(cfg.ReplayCacheDir, cfg.ReplayCacheSize); err != nil {
+			return nil, fmt.Errorf("can't init replay cache: %s", err)
+		}
+	}
+	if cfg.UseLiveUpload {
+		s.live = make(map[string]*liveSession)
+		s.liveFinished = make(map[string]time.Time)
+		if s.liveChunks, err = metrics.RegisterCounter("live_chunks_total"); err != nil {
+			log.Printf("can't create live_chunks_total metric: %s", err)
+		}
+		if s.liveUploadTime, err = metrics.RegisterHistogram("live_upload_duration"); err != nil {
+			log.Printf("can't create live_upload_duration metric: %s", err)
+		}
+	}
+	return s, nil
 }
 
 func (s *Storage) UploadKey(key string, retryCount int) error {
@@ -76,7 +166,9 @@ func (s *Storage) UploadKey(key string, retryCount int) error {
 	}
 	defer file.Close()
 
-	nRead, err := file.Read(s.startBytes)
+	startBytes := s.startBytes.Get().([]byte)
+	defer s.startBytes.Put(startBytes)
+	nRead, err := file.Read(startBytes)
 	if err != nil {
 		sessID, _ := strconv.ParseUint(key, 10, 64)
 		log.Printf("File read error: %s; sessID: %s, part: %d, sessStart: %s",
@@ -90,31 +182,113 @@ func (s *Storage) UploadKey(key string, retryCount int) error {
 		})
 		return nil
 	}
+	var startReader, endReader io.Reader = bytes.NewBuffer(startBytes[:nRead]), nil
+	if nRead == s.cfg.FileSplitSize {
+		endReader = file
+	}
+	if s.cfg.UseDeltaEncoding {
+		data := make([]byte, nRead, nRead*2)
+		copy(data, startBytes[:nRead])
+		if nRead == s.cfg.FileSplitSize {
+			rest, err := io.ReadAll(file)
+			if err != nil {
+				log.Printf("File read error: %s; sessID: %s", err, key)
+				time.AfterFunc(s.cfg.RetryTimeout, func() {
+					s.UploadKey(key, retryCount-1)
+				})
+				return nil
+			}
+			data = append(data, rest...)
+		}
+		startReader, endReader = s.splitFile(s.encodeDelta(key, data))
+	}
 	s.readingTime.Record(context.Background(), float64(time.Now().Sub(start).Milliseconds()))
 
 	start = time.Now()
-	startReader := bytes.NewBuffer(s.startBytes[:nRead])
-	if err := s.s3.Upload(s.gzipFile(startReader), key, "application/octet-stream", true); err != nil {
+	projectID := s.getProjectID(key)
+	dict, class := s.getDictionary(projectID), s.classes.Get(projectID)
+	if s.uploads != nil {
+		// Sessions of higher tiers get free upload slots first
+		s.uploads.Acquire(s.tiers.Get(projectID))
+	}
+	if err := s.uploadFile(startReader, key, dict, class); err != nil {
 		log.Fatalf("Storage: start upload failed.  %v\n", err)
 	}
-	if nRead == s.cfg.FileSplitSize {
-		if err := s.s3.Upload(s.gzipFile(file), key+"e", "application/octet-stream", true); err != nil {
+	if endReader != nil {
+		if err := s.uploadFile(endReader, key+"e", dict, class); err != nil {
 			log.Fatalf("Storage: end upload failed. %v\n", err)
 		}
 	}
+	s.uploadCanvas(key, class)
+	if s.uploads != nil {
+		s.uploads.Release()
+	}
 	s.archivingTime.Record(context.Background(), float64(time.Now().Sub(start).Milliseconds()))
 
 	// Save metrics
 	var fileSize float64 = 0
+	lastWrite := time.Now()
 	fileInfo, err := file.Stat()
 	if err != nil {
 		log.Printf("can't get file info: %s", err)
 	} else {
 		fileSize = float64(fileInfo.Size())
+		lastWrite = fileInfo.ModTime()
 	}
 	ctx, _ := context.WithTimeout(context.Background(), time.Millisecond*200)
 
 	s.sessionSize.Record(ctx, fileSize)
 	s.totalSessions.Add(ctx, 1)
+	s.announce(key, endReader != nil, lastWrite, true)
 	return nil
 }
+
+// uploadFile compresses the file with the project dictionary if there is one, otherwise with gzip
+func (s *Storage) uploadFile(reader io.Reader, key string, dict []byte, class string) error {
+	contentType, gzipped := "application/octet-stream", true
+	if dict != nil {
+		reader, contentType, gzipped = dictionaries.Compress(reader, dict), dictionaries.ContentType, false
+	} else {
+		reader = s.gzipFile(reader)
+	}
+	if s.replayCache == nil {
+		return storage.UploadWithClass(s.s3, reader, key, contentType, gzipped, class)
+	}
+	// Cached copy is exactly what the player downloads from the object storage
+	uploaded := &bytes.Buffer{}
+	if err := storage.UploadWithClass(s.s3, io.TeeReader(reader, uploaded), key, contentType, gzipped, class); err != nil {
+		return err
+	}
+	if err := s.replayCache.Put(key, contentType, gzipped, uploaded.Bytes()); err != nil {
+		log.Printf("can't cache uploaded file, key: %s, err: %s", key, err)
+	}
+	return nil
+}
+
+// getProjectID returns 0 if the project is unknown, such sessions use default compression and storage class
+func (s *Storage) getProjectID(key string) uint32 {
+	if s.sessions == nil {
+		return 0
+	}
+	sessID, err := strconv.ParseUint(key, 10, 64)
+	if err != nil {
+		return 0
+	}
+	session, err := s.sessions.Get(sessID)
+	if err != nil {
+		log.Printf("can't get session state, sessID: %d, err: %s", sessID, err)
+		return 0
+	}
+	if session == nil {
+		return 0
+	}
+	return session.ProjectID
+}
+
+// getDictionary returns nil if the session should be compressed with gzip
+func (s *Storage) getDictionary(projectID uint32) []byte {
+	if s.dicts == nil || projectID == 0 {
+		return nil
+	}
+	return s.dicts.Get(projectID)
+}
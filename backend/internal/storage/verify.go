@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"openreplay/backend/pkg/messages"
+	"openreplay/backend/pkg/storage"
+)
+
+// verifyUploaded returns true if all parts of the session are readable or the check is disabled
+func (s *Storage) verifyUploaded(key string, hasEnd bool) bool {
+	if s.cfg.UploadVerifyAttempts <= 0 {
+		return true
+	}
+	start := time.Now()
+	keys := []string{key}
+	if hasEnd {
+		keys = append(keys, key+"e")
+	}
+	for _, k := range keys {
+		if !storage.WaitUntilExists(s.s3, k, s.cfg.UploadVerifyAttempts, s.cfg.UploadVerifyBackoff) {
+			s.verifyFailures.Add(context.Background(), 1)
+			return false
+		}
+	}
+	s.verifyTime.Record(context.Background(), float64(time.Now().Sub(start).Milliseconds()))
+	return true
+}
+
+// announce makes the uploaded session available: live chunks are replaced by the session file and the ready
+// event is sent. It's done only when the files are readable, otherwise the player gets 404 for the session
+// which has just finished. Files which aren't readable after the second check are announced anyway.
+func (s *Storage) announce(key string, hasEnd bool, lastWrite time.Time, retry bool) {
+	if !s.verifyUploaded(key, hasEnd) {
+		if retry {
+			log.Printf("uploaded session isn't readable yet, next check in %s, sessID: %s", s.cfg.RetryTimeout, key)
+			time.AfterFunc(s.cfg.RetryTimeout, func() {
+				s.announce(key, hasEnd, lastWrite, false)
+			})
+			return
+		}
+		log.Printf("uploaded session isn't readable, announcing it anyway, sessID: %s", key)
+	}
+	if s.cfg.UseLiveUpload {
+		s.finishLive(key)
+	}
+	if s.producer == nil {
+		return
+	}
+	sessID, err := strconv.ParseUint(key, 10, 64)
+	if err != nil {
+		return
+	}
+	// Same message as in the trigger topic, its time is the last write of the session file
+	msg := &messages.SessionEnd{Timestamp: uint64(lastWrite.UnixMilli())}
+	if err := s.producer.Produce(s.cfg.TopicSessionReady, sessID, msg.Encode()); err != nil {
+		log.Printf("can't send session ready event, sessID: %s, err: %s", key, err)
+	}
+}
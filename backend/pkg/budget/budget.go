@@ -0,0 +1,191 @@
+package budget
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+
+	"openreplay/backend/internal/config/common"
+	"openreplay/backend/pkg/monitoring"
+)
+
+type Level int32
+
+const (
+	LevelNormal   Level = iota
+	LevelHigh           // soft limit is reached, consumers poll slower and optional processing is skipped
+	LevelCritical       // budget is exhausted, consumers almost stop polling until usage goes down
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelHigh:
+		return "high"
+	case LevelCritical:
+		return "critical"
+	}
+	return "normal"
+}
+
+const (
+	checkInterval     = time.Second
+	highPollDelay     = 10 * time.Millisecond
+	criticalPollDelay = 500 * time.Millisecond
+)
+
+// Budget tracks memory and goroutines of the service. Usage is sampled in the background,
+// so the hot path only reads the current level.
+type Budget struct {
+	mu             sync.Mutex // limits are changed by config reloads
+	memoryLimit    uint64     // bytes, 0 means no limit
+	goroutineLimit int        // 0 means no limit
+	softLimit      float64
+	level          int32
+	memoryUsage    syncfloat64.UpDownCounter
+	goroutines     syncfloat64.UpDownCounter
+	throttledPolls syncfloat64.Counter
+	skippedStages  syncfloat64.Counter
+	lastMemory     float64
+	lastGoroutines float64
+}
+
+func validate(cfg *common.Config) error {
+	switch {
+	case cfg == nil:
+		return fmt.Errorf("config is empty")
+	case cfg.MemoryBudget < 0 || cfg.GoroutineBudget < 0:
+		return fmt.Errorf("budget can't be negative")
+	case cfg.BudgetSoftLimit <= 0 || cfg.BudgetSoftLimit > 100:
+		return fmt.Errorf("wrong budget soft limit: %d", cfg.BudgetSoftLimit)
+	}
+	return nil
+}
+
+func New(cfg *common.Config, metrics *monitoring.Metrics) (*Budget, error) {
+	if metrics == nil {
+		return nil, fmt.Errorf("metrics module is empty")
+	}
+	if err := validate(cfg); err != nil {
+		return nil, err
+	}
+	b := &Budget{
+		memoryLimit:    uint64(cfg.MemoryBudget) << 20,
+		goroutineLimit: cfg.GoroutineBudget,
+		softLimit:      float64(cfg.BudgetSoftLimit) / 100,
+	}
+	var err error
+	if b.memoryUsage, err = metrics.RegisterUpDownCounter("budget_memory_usage"); err != nil {
+		return nil, fmt.Errorf("can't register budget_memory_usage metric: %s", err)
+	}
+	if b.goroutines, err = metrics.RegisterUpDownCounter("budget_goroutines"); err != nil {
+		return nil, fmt.Errorf("can't register budget_goroutines metric: %s", err)
+	}
+	if b.throttledPolls, err = metrics.RegisterCounter("budget_throttled_polls"); err != nil {
+		return nil, fmt.Errorf("can't register budget_throttled_polls metric: %s", err)
+	}
+	if b.skippedStages, err = metrics.RegisterCounter("budget_skipped_stages"); err != nil {
+		return nil, fmt.Errorf("can't register budget_skipped_stages metric: %s", err)
+	}
+	b.check()
+	go b.run()
+	return b, nil
+}
+
+// SetLimits applies limits of the reloaded config, the level is updated by the next check
+func (b *Budget) SetLimits(cfg *common.Config) error {
+	if err := validate(cfg); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.memoryLimit = uint64(cfg.MemoryBudget) << 20
+	b.goroutineLimit = cfg.GoroutineBudget
+	b.softLimit = float64(cfg.BudgetSoftLimit) / 100
+	return nil
+}
+
+func (b *Budget) Level() Level {
+	return Level(atomic.LoadInt32(&b.level))
+}
+
+// Throttle is called before each poll of the consumer, it delays the poll when the service
+// is close to the budget, so the consumed data has time to be flushed
+func (b *Budget) Throttle() {
+	level := b.Level()
+	switch level {
+	case LevelHigh:
+		time.Sleep(highPollDelay)
+	case LevelCritical:
+		time.Sleep(criticalPollDelay)
+	default:
+		return
+	}
+	b.throttledPolls.Add(context.Background(), 1, attribute.String("level", level.String()))
+}
+
+// Allow returns false if the optional processing stage has to be skipped to save resources
+func (b *Budget) Allow(stage string) bool {
+	if b.Level() == LevelNormal {
+		return true
+	}
+	b.skippedStages.Add(context.Background(), 1, attribute.String("stage", stage))
+	return false
+}
+
+func (b *Budget) run() {
+	tick := time.NewTicker(checkInterval)
+	defer tick.Stop()
+	for range tick.C {
+		b.check()
+	}
+}
+
+func (b *Budget) check() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	memory := stats.Sys - stats.HeapReleased // close to the resident memory of the process
+	goroutines := runtime.NumGoroutine()
+
+	ctx := context.Background()
+	memoryMB := float64(memory >> 20)
+	b.memoryUsage.Add(ctx, memoryMB-b.lastMemory)
+	b.goroutines.Add(ctx, float64(goroutines)-b.lastGoroutines)
+	b.lastMemory, b.lastGoroutines = memoryMB, float64(goroutines)
+
+	b.mu.Lock()
+	memoryLimit, goroutineLimit, softLimit := b.memoryLimit, b.goroutineLimit, b.softLimit
+	b.mu.Unlock()
+	usage := 0.0
+	if memoryLimit > 0 {
+		usage = float64(memory) / float64(memoryLimit)
+	}
+	if goroutineLimit > 0 {
+		if u := float64(goroutines) / float64(goroutineLimit); u > usage {
+			usage = u
+		}
+	}
+	level := LevelNormal
+	switch {
+	case usage >= 1:
+		level = LevelCritical
+	case usage >= softLimit:
+		level = LevelHigh
+	}
+	prev := Level(atomic.SwapInt32(&b.level, int32(level)))
+	if level == prev {
+		return
+	}
+	log.Printf("resource usage level changed from %s to %s, memory: %d MB, goroutines: %d", prev, level, memory>>20, goroutines)
+	if level == LevelCritical {
+		// Memory of the dropped buffers is returned to the OS right away
+		debug.FreeOSMemory()
+	}
+}
@@ -0,0 +1,265 @@
+package control
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+
+	"openreplay/backend/internal/config/common"
+	"openreplay/backend/pkg/monitoring"
+)
+
+type State int32
+
+const (
+	StateRunning  State = iota
+	StatePaused         // consumer isn't polled, assigned partitions stay with the service
+	StateDraining       // consumer isn't polled, the service flushes buffers and commits offsets
+	StateDrained        // everything consumed is flushed, the instance can be stopped without re-processing
+)
+
+func (s State) String() string {
+	switch s {
+	case StatePaused:
+		return "paused"
+	case StateDraining:
+		return "draining"
+	case StateDrained:
+		return "drained"
+	}
+	return "running"
+}
+
+// heldPollDelay is the sleep of the main loop while the consumer isn't polled, ticks and signals are still handled
+const heldPollDelay = 100 * time.Millisecond
+
+// ReloadFunc re-reads the service config, applies settings which can be changed at runtime and
+// returns names of all settings which differ from the running config
+type ReloadFunc func() ([]string, error)
+
+// Controller is the runtime state of the service which is changed by operators through the control API.
+// Services check it in their main loop: the consumer isn't polled while Hold returns true and drains are
+// handled by the loop itself, so the flush runs in the same goroutine as the processing.
+type Controller struct {
+	service   string
+	hostname  string
+	startedAt time.Time
+	state     int32
+	drains    chan struct{}
+	mu        sync.Mutex
+	drained   chan struct{} // closed when the current drain is over
+	drainErr  error
+	reload    ReloadFunc
+	stats     map[string]func() float64
+	commands  syncfloat64.Counter
+}
+
+// New starts the control server if its address is set in the config
+func New(service string, cfg *common.Config, metrics *monitoring.Metrics) (*Controller, error) {
+	switch {
+	case service == "":
+		return nil, fmt.Errorf("service name is empty")
+	case cfg == nil:
+		return nil, fmt.Errorf("config is empty")
+	case metrics == nil:
+		return nil, fmt.Errorf("metrics module is empty")
+	}
+	commands, err := metrics.RegisterCounter("control_commands")
+	if err != nil {
+		return nil, fmt.Errorf("can't register control_commands metric: %s", err)
+	}
+	hostname, _ := os.Hostname()
+	c := &Controller{
+		service:   service,
+		hostname:  hostname,
+		startedAt: time.Now(),
+		drains:    make(chan struct{}, 1),
+		stats:     make(map[string]func() float64),
+		commands:  commands,
+	}
+	if err := c.serve(cfg.ControlAddr, cfg.ControlToken); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Controller) State() State {
+	return State(atomic.LoadInt32(&c.state))
+}
+
+// Hold is called before each poll of the consumer, it sleeps and returns true if the consumer must not be polled
+func (c *Controller) Hold() bool {
+	if c.State() == StateRunning {
+		return false
+	}
+	time.Sleep(heldPollDelay)
+	return true
+}
+
+// Drains returns the channel of drain requests, the main loop flushes everything and calls Drained
+func (c *Controller) Drains() <-chan struct{} {
+	return c.drains
+}
+
+// Drained is called by the main loop when buffers are flushed and offsets are committed,
+// the service is paused if the flush failed
+func (c *Controller) Drained(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.drainErr = err
+	if err != nil {
+		atomic.StoreInt32(&c.state, int32(StatePaused))
+	} else {
+		atomic.StoreInt32(&c.state, int32(StateDrained))
+	}
+	if c.drained != nil {
+		close(c.drained)
+		c.drained = nil
+	}
+}
+
+// OnReload sets the reload function of the service, config can't be reloaded without it
+func (c *Controller) OnReload(reload ReloadFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reload = reload
+}
+
+// Stat adds the value to the stats of the service, get is called from the control server goroutine
+func (c *Controller) Stat(name string, get func() float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats[name] = get
+}
+
+// Handler rejects requests while the service is paused or drained, so the load balancer moves
+// clients to other instances. It's used by services without consumers.
+func (c *Controller) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.State() != StateRunning {
+			w.Header().Set("Retry-After", "10")
+			http.Error(w, fmt.Sprintf("service is %s", c.State()), http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *Controller) count(command string) {
+	c.commands.Add(context.Background(), 1, attribute.String("command", command))
+}
+
+func (c *Controller) pause() State {
+	c.count("pause")
+	atomic.CompareAndSwapInt32(&c.state, int32(StateRunning), int32(StatePaused))
+	return c.State()
+}
+
+func (c *Controller) resume() (State, error) {
+	c.count("resume")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.State() == StateDraining {
+		return StateDraining, fmt.Errorf("service is draining")
+	}
+	atomic.StoreInt32(&c.state, int32(StateRunning))
+	return StateRunning, nil
+}
+
+// drain waits until the main loop flushes everything or the context is done
+func (c *Controller) drain(ctx context.Context) (State, error) {
+	c.count("drain")
+	c.mu.Lock()
+	if c.State() == StateDrained {
+		c.mu.Unlock()
+		return StateDrained, nil
+	}
+	if c.drained == nil {
+		atomic.StoreInt32(&c.state, int32(StateDraining))
+		c.drained = make(chan struct{})
+		select {
+		case c.drains <- struct{}{}:
+		default:
+		}
+	}
+	drained := c.drained
+	c.mu.Unlock()
+	select {
+	case <-drained:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.State(), c.drainErr
+	case <-ctx.Done():
+		return c.State(), ctx.Err()
+	}
+}
+
+func (c *Controller) statsReply() *StatsReply {
+	c.count("stats")
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	res := &StatsReply{
+		Service:    c.service,
+		Hostname:   c.hostname,
+		State:      c.State().String(),
+		StartedAt:  c.startedAt,
+		Goroutines: runtime.NumGoroutine(),
+		MemoryMB:   (mem.Sys - mem.HeapReleased) >> 20,
+		Values:     make(map[string]float64),
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, get := range c.stats {
+		res.Values[name] = get()
+	}
+	return res
+}
+
+func (c *Controller) reloadConfig() ([]string, error) {
+	c.count("reload")
+	c.mu.Lock()
+	reload := c.reload
+	c.mu.Unlock()
+	if reload == nil {
+		return nil, fmt.Errorf("service doesn't support config reload")
+	}
+	return reload()
+}
+
+// Diff returns env names of settings which differ in two configs of the same type, fields of
+// embedded configs are compared as well
+func Diff(old, new interface{}) []string {
+	var res []string
+	diff(reflect.Indirect(reflect.ValueOf(old)), reflect.Indirect(reflect.ValueOf(new)), &res)
+	return res
+}
+
+func diff(old, new reflect.Value, res *[]string) {
+	if old.Kind() != reflect.Struct || old.Type() != new.Type() {
+		return
+	}
+	for i := 0; i < old.NumField(); i++ {
+		field := old.Type().Field(i)
+		if field.Anonymous {
+			diff(old.Field(i), new.Field(i), res)
+			continue
+		}
+		name := strings.Split(field.Tag.Get("env"), ",")[0]
+		if name == "" || !field.IsExported() {
+			continue
+		}
+		if !reflect.DeepEqual(old.Field(i).Interface(), new.Field(i).Interface()) {
+			*res = append(*res, name)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package control
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// The API is a plain gRPC service with JSON encoded messages (content type application/grpc+json),
+// so it doesn't need generated code. Any gRPC client can call it with the same codec:
+//
+//	service openreplay.control.Control {
+//	  rpc Pause(Empty) returns (StateReply);
+//	  rpc Resume(Empty) returns (StateReply);
+//	  rpc Drain(Empty) returns (StateReply);    // returns when the service is drained
+//	  rpc Stats(Empty) returns (StatsReply);
+//	  rpc Reload(Empty) returns (ReloadReply);
+//	}
+const (
+	serviceName = "openreplay.control.Control"
+	codecName   = "json"
+	tokenHeader = "authorization"
+)
+
+type Empty struct{}
+
+type StateReply struct {
+	State string `json:"state"`
+}
+
+type StatsReply struct {
+	Service    string             `json:"service"`
+	Hostname   string             `json:"hostname"`
+	State      string             `json:"state"`
+	StartedAt  time.Time          `json:"startedAt"`
+	Goroutines int                `json:"goroutines"`
+	MemoryMB   uint64             `json:"memoryMB"`
+	Values     map[string]float64 `json:"values,omitempty"` // service specific stats
+}
+
+type ReloadReply struct {
+	Changed []string `json:"changed"` // only settings which the service applies at runtime take effect
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return codecName
+}
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// unaryHandler decodes the empty request and passes the call through the interceptor the same way as generated code
+func unaryHandler(method string, call func(c *Controller, ctx context.Context) (interface{}, error)) func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		req := &Empty{}
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return call(srv.(*Controller), ctx)
+		}
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/" + method}
+		return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return call(srv.(*Controller), ctx)
+		})
+	}
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Pause",
+			Handler: unaryHandler("Pause", func(c *Controller, ctx context.Context) (interface{}, error) {
+				return &StateReply{State: c.pause().String()}, nil
+			}),
+		},
+		{
+			MethodName: "Resume",
+			Handler: unaryHandler("Resume", func(c *Controller, ctx context.Context) (interface{}, error) {
+				state, err := c.resume()
+				if err != nil {
+					return nil, status.Error(codes.FailedPrecondition, err.Error())
+				}
+				return &StateReply{State: state.String()}, nil
+			}),
+		},
+		{
+			MethodName: "Drain",
+			Handler: unaryHandler("Drain", func(c *Controller, ctx context.Context) (interface{}, error) {
+				state, err := c.drain(ctx)
+				if err != nil && err == ctx.Err() {
+					return nil, status.Errorf(codes.DeadlineExceeded, "service is still %s: %s", state, err)
+				}
+				if err != nil {
+					return nil, status.Errorf(codes.Internal, "service is %s: %s", state, err)
+				}
+				return &StateReply{State: state.String()}, nil
+			}),
+		},
+		{
+			MethodName: "Stats",
+			Handler: unaryHandler("Stats", func(c *Controller, ctx context.Context) (interface{}, error) {
+				return c.statsReply(), nil
+			}),
+		},
+		{
+			MethodName: "Reload",
+			Handler: unaryHandler("Reload", func(c *Controller, ctx context.Context) (interface{}, error) {
+				changed, err := c.reloadConfig()
+				if err != nil {
+					return nil, status.Error(codes.FailedPrecondition, err.Error())
+				}
+				return &ReloadReply{Changed: changed}, nil
+			}),
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+func authInterceptor(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get(tokenHeader)
+		if len(values) == 0 || subtle.ConstantTimeCompare([]byte(values[0]), []byte(token)) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "wrong control token")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// UnaryInterceptor rejects calls of other gRPC servers of the service while it isn't running, the same as Handler
+func (c *Controller) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if state := c.State(); state != StateRunning {
+			return nil, status.Errorf(codes.Unavailable, "service is %s", state)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// serve starts the control server in the background, empty address disables it.
+// Requests must have the token if it's set.
+func (c *Controller) serve(addr, token string) error {
+	if addr == "" {
+		return nil
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("can't listen on %s: %s", addr, err)
+	}
+	var opts []grpc.ServerOption
+	if token != "" {
+		opts = append(opts, grpc.UnaryInterceptor(authInterceptor(token)))
+	}
+	server := grpc.NewServer(opts...)
+	server.RegisterService(&serviceDesc, c)
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Printf("control server stopped: %s", err)
+		}
+	}()
+	log.Printf("Control server running on %s", addr)
+	return nil
+}
+
+// Client calls the control API of one service instance
+type Client struct {
+	conn  *grpc.ClientConn
+	token string
+}
+
+func Dial(addr, token string) (*Client, error) {
+	conn, err := grpc.Dial(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(codecName)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, token: token}, nil
+}
+
+func (c *Client) invoke(ctx context.Context, method string, reply interface{}) error {
+	if c.token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, tokenHeader, c.token)
+	}
+	return c.conn.Invoke(ctx, "/"+serviceName+"/"+method, &Empty{}, reply)
+}
+
+func (c *Client) Pause(ctx context.Context) (*StateReply, error) {
+	reply := &StateReply{}
+	return reply, c.invoke(ctx, "Pause", reply)
+}
+
+func (c *Client) Resume(ctx context.Context) (*StateReply, error) {
+	reply := &StateReply{}
+	return reply, c.invoke(ctx, "Resume", reply)
+}
+
+// Drain returns when the service is drained, the context limits the wait
+func (c *Client) Drain(ctx context.Context) (*StateReply, error) {
+	reply := &StateReply{}
+	return reply, c.invoke(ctx, "Drain", reply)
+}
+
+func (c *Client) Stats(ctx context.Context) (*StatsReply, error) {
+	reply := &StatsReply{}
+	return reply, c.invoke(ctx, "Stats", reply)
+}
+
+func (c *Client) Reload(ctx context.Context) (*ReloadReply, error) {
+	reply := &ReloadReply{}
+	return reply, c.invoke(ctx, "Reload", reply)
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	. "openreplay/backend/pkg/db/types"
+)
+
+// IdentityMeta keeps imported attributes of the identified user
+type IdentityMeta struct {
+	attributes     map[string]string
+	expirationTime time.Time
+}
+
+func identityKey(projectID uint32, userID string) string {
+	return fmt.Sprintf("%d:%s", projectID, userID)
+}
+
+// GetUserAttributes returns imported attributes of the user, both found and missing attributes are cached
+func (c *PGCache) GetUserAttributes(projectID uint32, userID string) (map[string]string, error) {
+	key := identityKey(projectID, userID)
+	if im, ok := c.identities[key]; ok && time.Now().Before(im.expirationTime) {
+		return im.attributes, nil
+	}
+	attributes, err := c.Conn.GetUserAttributes(projectID, userID)
+	if err != nil {
+		return nil, err
+	}
+	c.deleteOutdatedIdentities()
+	c.identities[key] = &IdentityMeta{attributes, time.Now().Add(c.projectExpirationTimeout)}
+	return attributes, nil
+}
+
+func (c *PGCache) deleteOutdatedIdentities() {
+	now := time.Now()
+	if now.Before(c.identitiesCleanupTime) {
+		return
+	}
+	for key, im := range c.identities {
+		if now.After(im.expirationTime) {
+			delete(c.identities, key)
+		}
+	}
+	c.identitiesCleanupTime = now.Add(c.projectExpirationTimeout)
+}
+
+// applyUserAttributes writes imported attributes of the identified user to the session metadata,
+// only attributes with the same name as a project metadata key are applied
+// and values sent by the tracker take precedence
+func (c *PGCache) applyUserAttributes(session *Session) {
+	if session.UserID == nil || *session.UserID == "" {
+		return
+	}
+	attributes, err := c.GetUserAttributes(session.ProjectID, *session.UserID)
+	if err != nil {
+		log.Printf("can't get user attributes, sessID: %d, err: %s", session.SessionID, err)
+		return
+	}
+	if len(attributes) == 0 {
+		return
+	}
+	project, err := c.GetProject(session.ProjectID)
+	if err != nil {
+		log.Printf("can't get project, sessID: %d, err: %s", session.SessionID, err)
+		return
+	}
+	for key, value := range attributes {
+		keyNo := project.GetMetadataNo(key)
+		if keyNo == 0 || value == "" || session.GetMetadata(keyNo) != nil {
+			continue
+		}
+		if err := c.Conn.InsertMetadata(session.SessionID, keyNo, value); err != nil {
+			log.Printf("can't insert user attribute, sessID: %d, key: %s, err: %s", session.SessionID, key, err)
+			continue
+		}
+		session.SetMetadata(keyNo, value)
+	}
+}
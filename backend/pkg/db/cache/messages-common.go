@@ -15,6 +15,7 @@ func (c *PGCache) HandleSessionEnd(sessionID uint64) error {
 	if err := c.Conn.HandleSessionEnd(sessionID); err != nil {
 		log.Printf("can't handle session end: %s", err)
 	}
+	c.Conn.UpdateSessionScores(sessionID)
 	c.DeleteSession(sessionID)
 	return nil
 }
@@ -53,5 +54,6 @@ func (c *PGCache) InsertMetadata(sessionID uint64, metadata *Metadata) error {
 		return err
 	}
 	session.SetMetadata(keyNo, metadata.Value)
+	c.publishSession(session)
 	return nil
 }
@@ -28,6 +28,7 @@ func (c *PGCache) InsertIOSSessionStart(sessionID uint64, s *IOSSessionStart) er
 		c.sessions[sessionID] = nil
 		return err
 	}
+	c.publishSession(c.sessions[sessionID])
 	return nil
 }
 
@@ -87,3 +88,19 @@ func (c *PGCache) InsertIOSCrash(sessionID uint64, crash *IOSCrash) error {
 func (c *PGCache) InsertIOSIssueEvent(sessionID uint64, issueEvent *IOSIssueEvent) error {
 	return nil
 }
+
+// InsertMobileTouchEvent saves the end of the touch as a click, the other phases are needed only for the replay
+func (c *PGCache) InsertMobileTouchEvent(sessionID uint64, e *MobileTouchEvent) error {
+	if e.Phase != "ended" {
+		return nil
+	}
+	click := &IOSClickEvent{
+		Timestamp: e.Timestamp,
+		Length:    e.Length,
+		Label:     e.Label,
+		X:         e.X,
+		Y:         e.Y,
+	}
+	click.SetMeta(e.Meta())
+	return c.InsertIOSClickEvent(sessionID, click)
+}
@@ -7,7 +7,7 @@ import (
 )
 
 func (c *PGCache) InsertWebSessionStart(sessionID uint64, s *SessionStart) error {
-	return c.Conn.InsertSessionStart(sessionID, &Session{
+	session := &Session{
 		SessionID:      sessionID,
 		Platform:       "web",
 		Timestamp:      s.Timestamp,
@@ -27,7 +27,12 @@ func (c *PGCache) InsertWebSessionStart(sessionID uint64, s *SessionStart) error
 		UserDeviceMemorySize: s.UserDeviceMemorySize,
 		UserDeviceHeapSize:   s.UserDeviceHeapSize,
 		UserID:               &s.UserID,
-	})
+	}
+	if err := c.Conn.InsertSessionStart(sessionID, session); err != nil {
+		return err
+	}
+	c.publishSession(session)
+	return nil
 }
 
 func (c *PGCache) HandleWebSessionStart(sessionID uint64, s *SessionStart) error {
@@ -59,6 +64,8 @@ func (c *PGCache) HandleWebSessionStart(sessionID uint64, s *SessionStart) error
 		c.sessions[sessionID] = nil
 		return err
 	}
+	c.applyUserAttributes(c.sessions[sessionID])
+	c.publishSession(c.sessions[sessionID])
 	return nil
 }
 
@@ -128,7 +135,13 @@ func (c *PGCache) InsertWebUserID(sessionID uint64, userID *UserID) error {
 	if err != nil {
 		return err
 	}
-	return c.Conn.InsertWebUserID(sessionID, session.ProjectID, userID)
+	if err := c.Conn.InsertWebUserID(sessionID, session.ProjectID, userID); err != nil {
+		return err
+	}
+	session.UserID = &userID.ID
+	c.applyUserAttributes(session)
+	c.publishSession(session)
+	return nil
 }
 
 func (c *PGCache) InsertWebUserAnonymousID(sessionID uint64, userAnonymousID *UserAnonymousID) error {
@@ -136,7 +149,12 @@ func (c *PGCache) InsertWebUserAnonymousID(sessionID uint64, userAnonymousID *Us
 	if err != nil {
 		return err
 	}
-	return c.Conn.InsertWebUserAnonymousID(sessionID, session.ProjectID, userAnonymousID)
+	if err := c.Conn.InsertWebUserAnonymousID(sessionID, session.ProjectID, userAnonymousID); err != nil {
+		return err
+	}
+	session.UserAnonymousID = &userAnonymousID.ID
+	c.publishSession(session)
+	return nil
 }
 
 func (c *PGCache) InsertWebPageEvent(sessionID uint64, e *PageEvent) error {
@@ -24,14 +24,19 @@ type PGCache struct {
 	projects                 map[uint32]*ProjectMeta
 	projectsByKeys           sync.Map // map[string]*ProjectMeta
 	projectExpirationTimeout time.Duration
+	state                    SessionState // optional, shared between services
+	identities               map[string]*IdentityMeta
+	identitiesCleanupTime    time.Time
 }
 
 // TODO: create conn automatically
-func NewPGCache(pgConn *postgres.Conn, projectExpirationTimeoutMs int64) *PGCache {
+func NewPGCache(pgConn *postgres.Conn, projectExpirationTimeoutMs int64, state SessionState) *PGCache {
 	return &PGCache{
 		Conn:                     pgConn,
 		sessions:                 make(map[uint64]*Session),
 		projects:                 make(map[uint32]*ProjectMeta),
 		projectExpirationTimeout: time.Duration(1000 * projectExpirationTimeoutMs),
+		state:                    state,
+		identities:               make(map[string]*IdentityMeta),
 	}
 }
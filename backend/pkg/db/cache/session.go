@@ -3,6 +3,7 @@ package cache
 import (
 	"errors"
 	"github.com/jackc/pgx/v4"
+	"log"
 	. "openreplay/backend/pkg/db/types"
 )
 
@@ -15,6 +16,16 @@ func (c *PGCache) GetSession(sessionID uint64) (*Session, error) {
 		}
 		return s, nil
 	}
+	if c.state != nil {
+		s, err := c.state.Get(sessionID)
+		if err != nil {
+			log.Printf("can't get session state, sessID: %d, err: %s", sessionID, err)
+		}
+		if s != nil {
+			c.sessions[sessionID] = s
+			return s, nil
+		}
+	}
 	s, err := c.Conn.GetSession(sessionID)
 	if err == pgx.ErrNoRows {
 		c.sessions[sessionID] = nil
@@ -23,6 +34,7 @@ func (c *PGCache) GetSession(sessionID uint64) (*Session, error) {
 		return nil, err
 	}
 	c.sessions[sessionID] = s
+	c.publishSession(s)
 	return s, nil
 }
 
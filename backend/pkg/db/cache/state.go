@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+
+	. "openreplay/backend/pkg/db/types"
+)
+
+// sessionStateTTL should be longer than the maximum session duration
+const sessionStateTTL = 24 * time.Hour
+
+// SessionState keeps current session attributes in one place for all services
+// instead of each service building its own copy from the database
+type SessionState interface {
+	Get(sessionID uint64) (*Session, error) // returns nil session if there is no state
+	Set(session *Session) error
+	Delete(sessionID uint64) error
+}
+
+type redisSessionState struct {
+	client *redis.Client
+}
+
+func NewRedisSessionState(addr string) (SessionState, error) {
+	if addr == "" {
+		return nil, fmt.Errorf("redis address is empty")
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr: addr,
+	})
+	if _, err := client.Ping().Result(); err != nil {
+		return nil, fmt.Errorf("can't connect to redis: %s", err)
+	}
+	return &redisSessionState{client: client}, nil
+}
+
+func sessionStateKey(sessionID uint64) string {
+	return "session-state:" + strconv.FormatUint(sessionID, 10)
+}
+
+func (s *redisSessionState) Get(sessionID uint64) (*Session, error) {
+	data, err := s.client.Get(sessionStateKey(sessionID)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	session := &Session{}
+	if err := json.Unmarshal(data, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (s *redisSessionState) Set(session *Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(sessionStateKey(session.SessionID), data, sessionStateTTL).Err()
+}
+
+func (s *redisSessionState) Delete(sessionID uint64) error {
+	return s.client.Del(sessionStateKey(sessionID)).Err()
+}
+
+// publishSession shares the latest version of session attributes with other services
+func (c *PGCache) publishSession(session *Session) {
+	if c.state == nil || session == nil {
+		return
+	}
+	if err := c.state.Set(session); err != nil {
+		log.Printf("can't update session state, sessID: %d, err: %s", session.SessionID, err)
+	}
+}
@@ -0,0 +1,34 @@
+package postgres
+
+import "fmt"
+
+// There is the only tenant and all dashboard users can read all projects, the enterprise edition
+// checks roles of users as well
+const projectAccessQuery = `
+	SELECT EXISTS(
+		SELECT 1
+		FROM users u, tenants t, projects p
+		WHERE u.user_id = $1 AND u.deleted_at IS NULL
+		  AND t.tenant_id = $2
+		  AND p.project_id = %s AND p.deleted_at IS NULL
+	)`
+
+// HasSessionAccess checks that the dashboard user of the tenant can read the project of the session
+func (conn *Conn) HasSessionAccess(userID, tenantID, sessionID uint64) (bool, error) {
+	var hasAccess bool
+	err := conn.c.QueryRow(
+		fmt.Sprintf(projectAccessQuery, "(SELECT project_id FROM sessions WHERE session_id = $3)"),
+		userID, tenantID, sessionID,
+	).Scan(&hasAccess)
+	return hasAccess, err
+}
+
+// HasProjectAccess checks that the dashboard user of the tenant can read sessions of the project
+func (conn *Conn) HasProjectAccess(userID, tenantID uint64, projectID uint32) (bool, error) {
+	var hasAccess bool
+	err := conn.r.QueryRow(
+		fmt.Sprintf(projectAccessQuery, "$3"),
+		userID, tenantID, projectID,
+	).Scan(&hasAccess)
+	return hasAccess, err
+}
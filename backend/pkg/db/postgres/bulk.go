@@ -2,29 +2,63 @@ package postgres
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+
+	"openreplay/backend/pkg/env"
 )
 
+// Rows of the bulk are skipped on conflicts with natural keys (session_id with message_id or seq_index),
+// so the bulk can be sent again after a timeout without duplicates
 const (
 	insertPrefix = `INSERT INTO `
 	insertValues = ` VALUES `
 	insertSuffix = ` ON CONFLICT DO NOTHING;`
+
+	maxRetainedBulks = 10 // bulks kept after transient errors, older rows are dropped above the limit
+)
+
+// Bulks are sent as one INSERT with multi-row values by default. POSTGRES_BULK_MODE=copy sends rows with COPY
+// in the binary format to the temporary table of the connection and moves them to the table by INSERT ... SELECT,
+// it saves building and parsing of statements with thousands of parameters on both sides.
+const (
+	bulkModeValues = "values"
+	bulkModeCopy   = "copy"
 )
 
+func bulkMode() string {
+	mode := strings.ToLower(strings.TrimSpace(env.StringOptional("POSTGRES_BULK_MODE")))
+	if mode != bulkModeCopy || IsCockroach() {
+		// Temporary tables are experimental in CockroachDB
+		return bulkModeValues
+	}
+	return mode
+}
+
 type Bulk interface {
 	Append(args ...interface{}) error
 	Send() error
 }
 
 type bulkImpl struct {
-	conn      Pool
-	table     string
-	columns   string
-	template  string
-	setSize   int
-	sizeLimit int
-	values    []interface{}
+	conn       Pool
+	table      string
+	columns    string
+	template   string
+	setSize    int
+	sizeLimit  int
+	values     []interface{}
+	retryAt    time.Time      // full bulks aren't sent by Append until then after a transient error
+	statements map[int]string // insert statements by the number of rows
+	stage      *bulkStage     // nil in the values mode
+	rows       syncfloat64.Histogram
 }
 
 func (b *bulkImpl) Append(args ...interface{}) error {
@@ -32,7 +66,7 @@ func (b *bulkImpl) Append(args ...interface{}) error {
 		return fmt.Errorf("wrong number of arguments, waited: %d, got: %d", b.setSize, len(args))
 	}
 	b.values = append(b.values, args...)
-	if len(b.values)/b.setSize >= b.sizeLimit {
+	if len(b.values)/b.setSize >= b.sizeLimit && time.Now().After(b.retryAt) {
 		return b.send()
 	}
 	return nil
@@ -46,9 +80,50 @@ func (b *bulkImpl) Send() error {
 }
 
 func (b *bulkImpl) send() error {
+	// Rows kept after transient errors, the size of the statement is limited by dropping the oldest ones
+	dropped := 0
+	if maxValues := b.setSize * b.sizeLimit * maxRetainedBulks; len(b.values) > maxValues {
+		dropped = (len(b.values) - maxValues) / b.setSize
+		b.values = b.values[len(b.values)-maxValues:]
+	}
+	var err error
+	mode := bulkModeValues
+	if b.stage != nil {
+		mode = bulkModeCopy
+		err = b.stage.send(b.conn, b.values, b.setSize)
+	} else {
+		err = b.conn.ExecIdempotent(b.statement(len(b.values)/b.setSize), b.values...)
+	}
+	if b.rows != nil {
+		b.rows.Record(context.Background(), float64(len(b.values)/b.setSize),
+			attribute.String("table", b.table), attribute.String("mode", mode), attribute.Bool("failed", err != nil))
+	}
+	switch {
+	case err == nil:
+		b.values = make([]interface{}, 0, b.setSize*b.sizeLimit)
+	case isRetryableWrite(err):
+		// Rows are sent again with the next bulk
+		b.retryAt = time.Now().Add(retryMaxDelay)
+		return fmt.Errorf("send bulk err: %s, rows kept for retry: %d", err, len(b.values)/b.setSize)
+	default:
+		b.values = make([]interface{}, 0, b.setSize*b.sizeLimit)
+		return fmt.Errorf("send bulk err: %s", err)
+	}
+	if dropped > 0 {
+		return fmt.Errorf("send bulk err: rows dropped after transient errors: %d", dropped)
+	}
+	return nil
+}
+
+// statement returns the insert of the rows, statements are the same for full bulks, so they are built once
+// and prepared once by the statement cache of the connection
+func (b *bulkImpl) statement(rows int) string {
+	if sql, ok := b.statements[rows]; ok {
+		return sql
+	}
 	request := bytes.NewBufferString(insertPrefix + b.table + b.columns + insertValues)
 	args := make([]interface{}, b.setSize)
-	for i := 0; i < len(b.values)/b.setSize; i++ {
+	for i := 0; i < rows; i++ {
 		for j := 0; j < b.setSize; j++ {
 			args[j] = i*b.setSize + j + 1
 		}
@@ -58,15 +133,75 @@ func (b *bulkImpl) send() error {
 		request.WriteString(fmt.Sprintf(b.template, args...))
 	}
 	request.WriteString(insertSuffix)
-	err := b.conn.Exec(request.String(), b.values...)
-	b.values = make([]interface{}, 0, b.setSize*b.sizeLimit)
-	if err != nil {
-		return fmt.Errorf("send bulk err: %s", err)
+	if rows == b.sizeLimit || rows == 1 {
+		// Other sizes are rare (the last bulk before the commit or retries), they aren't kept
+		b.statements[rows] = request.String()
 	}
-	return nil
+	return request.String()
+}
+
+// bulkStage is the temporary table of the copy mode with the columns of the bulk, values are converted
+// by the template of the bulk when they are moved to the table
+type bulkStage struct {
+	name    string
+	columns []string
+	create  string
+	insert  string
 }
 
-func NewBulk(conn Pool, table, columns, template string, setSize, sizeLimit int) (Bulk, error) {
+func newBulkStage(table, columns, template, types string) (*bulkStage, error) {
+	names := strings.Split(strings.Trim(columns, "()"), ",")
+	typeNames := strings.Split(types, ",")
+	if len(names) != len(typeNames) {
+		return nil, fmt.Errorf("wrong number of column types, waited: %d, got: %d", len(names), len(typeNames))
+	}
+	if placeholders := strings.Count(template, "$%d"); placeholders != len(names) {
+		return nil, fmt.Errorf("wrong number of template placeholders, waited: %d, got: %d", len(names), placeholders)
+	}
+	s := &bulkStage{
+		// Bulks of one table may have different columns
+		name:    fmt.Sprintf("bulk_%s_%d", strings.ReplaceAll(table, ".", "_"), len(names)),
+		columns: make([]string, len(names)),
+	}
+	definitions := make([]string, len(names))
+	args := make([]interface{}, len(names))
+	for i := range names {
+		s.columns[i] = strings.TrimSpace(names[i])
+		definitions[i] = s.columns[i] + " " + strings.TrimSpace(typeNames[i])
+		args[i] = s.columns[i]
+	}
+	// Templates have one placeholder per column in their order
+	expressions := strings.ReplaceAll(template, "$%d", "%s")
+	expressions = strings.TrimSuffix(strings.TrimPrefix(fmt.Sprintf(expressions, args...), "("), ")")
+	s.create = fmt.Sprintf("CREATE TEMPORARY TABLE IF NOT EXISTS %s (%s) ON COMMIT DELETE ROWS",
+		s.name, strings.Join(definitions, ", "))
+	s.insert = insertPrefix + table + columns + " SELECT " + expressions + " FROM " + s.name + insertSuffix
+	return s, nil
+}
+
+// send copies the rows in one transaction, the temporary table is created once per connection and emptied
+// on commit, so failed transactions leave nothing there
+func (s *bulkStage) send(conn Pool, values []interface{}, setSize int) error {
+	rows := make([][]interface{}, 0, len(values)/setSize)
+	for i := 0; i+setSize <= len(values); i += setSize {
+		rows = append(rows, values[i:i+setSize])
+	}
+	return conn.InTx(func(tx *_Tx) error {
+		if err := tx.exec(s.create); err != nil {
+			return err
+		}
+		if err := tx.copyFrom(s.name, s.columns, pgx.CopyFromRows(rows)); err != nil {
+			return err
+		}
+		return tx.exec(s.insert)
+	})
+}
+
+// NewBulk returns the bulk of rows with the columns converted by the template, types of the columns are
+// the types of values before the template, e.g. text for enums, they are used by the copy mode.
+// The number of rows of sent bulks is recorded to the rows histogram if it's set.
+func NewBulk(conn Pool, table, columns, template, types string, setSize, sizeLimit int,
+	rows syncfloat64.Histogram) (Bulk, error) {
 	switch {
 	case conn == nil:
 		return nil, errors.New("db conn is empty")
@@ -76,18 +211,30 @@ func NewBulk(conn Pool, table, columns, template string, setSize, sizeLimit int)
 		return nil, errors.New("columns is empty")
 	case template == "":
 		return nil, errors.New("template is empty")
+	case types == "":
+		return nil, errors.New("types is empty")
 	case setSize <= 0:
 		return nil, errors.New("set size is wrong")
 	case sizeLimit <= 0:
 		return nil, errors.New("size limit is wrong")
 	}
-	return &bulkImpl{
-		conn:      conn,
-		table:     table,
-		columns:   columns,
-		template:  template,
-		setSize:   setSize,
-		sizeLimit: sizeLimit,
-		values:    make([]interface{}, 0, setSize*sizeLimit),
-	}, nil
+	b := &bulkImpl{
+		conn:       conn,
+		table:      table,
+		columns:    columns,
+		template:   template,
+		setSize:    setSize,
+		sizeLimit:  sizeLimit,
+		values:     make([]interface{}, 0, setSize*sizeLimit),
+		statements: make(map[int]string),
+		rows:       rows,
+	}
+	if bulkMode() == bulkModeCopy {
+		stage, err := newBulkStage(table, columns, template, types)
+		if err != nil {
+			return nil, err
+		}
+		b.stage = stage
+	}
+	return b, nil
 }
@@ -0,0 +1,22 @@
+package postgres
+
+import (
+	"strings"
+
+	"openreplay/backend/pkg/env"
+)
+
+// POSTGRES_COMPAT=cockroach runs the backend against CockroachDB, which speaks the postgres protocol but lacks
+// some postgres features: integration changes are polled instead of LISTEN/NOTIFY, partitions of tables aren't
+// managed (there is no declarative partitioning by range of time) and conflicting transactions are retried
+// more times, CockroachDB aborts them with serialization failures (40001) instead of waiting on locks.
+const compatCockroach = "cockroach"
+
+const cockroachRetryAttempts = 10
+
+var cockroach = strings.EqualFold(strings.TrimSpace(env.StringOptional("POSTGRES_COMPAT")), compatCockroach)
+
+// IsCockroach reports the CockroachDB compatibility mode
+func IsCockroach() bool {
+	return cockroach
+}
@@ -6,6 +6,7 @@ import (
 	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
 	"log"
 	"openreplay/backend/pkg/db/types"
+	"openreplay/backend/pkg/env"
 	"openreplay/backend/pkg/monitoring"
 	"strings"
 	"time"
@@ -26,6 +27,8 @@ type batchItem struct {
 // Conn contains batches, bulks and cache for all sessions
 type Conn struct {
 	c                 Pool
+	r                 Reader    // the replica for lookups if it's set, the primary otherwise
+	replica           *readPool // nil without the replica
 	batches           map[uint64]*pgx.Batch
 	batchSizes        map[uint64]int
 	rawBatches        map[uint64][]*batchItem
@@ -35,14 +38,17 @@ type Conn struct {
 	webPageEvents     Bulk
 	webInputEvents    Bulk
 	webGraphQLEvents  Bulk
+	webFetchEvents    Bulk
+	webResourceEvents Bulk
+	webPerformance    Bulk
 	sessionUpdates    map[uint64]*sessionUpdates
 	batchQueueLimit   int
 	batchSizeLimit    int
 	batchSizeBytes    syncfloat64.Histogram
 	batchSizeLines    syncfloat64.Histogram
-	sqlRequestTime    syncfloat64.Histogram
-	sqlRequestCounter syncfloat64.Counter
+	bulkRows          syncfloat64.Histogram
 	chConn            CH
+	sqlMetrics
 }
 
 func (conn *Conn) SetClickHouse(ch CH) {
@@ -53,11 +59,18 @@ func NewConn(url string, queueLimit, sizeLimit int, metrics *monitoring.Metrics)
 	if metrics == nil {
 		log.Fatalf("metrics is nil")
 	}
-	c, err := pgxpool.Connect(context.Background(), url)
+	poolCfg, err := poolConfig(url)
+	if err != nil {
+		log.Fatalf("wrong postgres config: %s", err)
+	}
+	c, err := pgxpool.ConnectConfig(context.Background(), poolCfg)
 	if err != nil {
 		log.Println(err)
 		log.Fatalln("pgxpool.Connect Error")
 	}
+	if err := migrate(url); err != nil {
+		log.Fatalf("postgres migrations: %s", err)
+	}
 	conn := &Conn{
 		batches:         make(map[uint64]*pgx.Batch),
 		batchSizes:      make(map[uint64]int),
@@ -67,19 +80,40 @@ func NewConn(url string, queueLimit, sizeLimit int, metrics *monitoring.Metrics)
 		batchSizeLimit:  sizeLimit,
 	}
 	conn.initMetrics(metrics)
-	conn.c, err = NewPool(c, conn.sqlRequestTime, conn.sqlRequestCounter)
+	conn.c, err = NewPool(c, poolCfg.HealthCheckPeriod,
+		conn.sqlRequestTime, conn.sqlRequestCounter, conn.sqlRequestErrors)
 	if err != nil {
 		log.Fatalf("can't create new pool wrapper: %s", err)
 	}
+	conn.r = conn.c
+	if replicaURL := env.StringOptional("POSTGRES_REPLICA_STRING"); replicaURL != "" && !IsCockroach() {
+		conn.replica, err = newReadPool(replicaURL, conn.c, conn.sqlMetrics)
+		if err != nil {
+			log.Fatalf("wrong postgres replica config: %s", err)
+		}
+		conn.r = conn.replica
+	}
 	conn.initBulks()
 	return conn
 }
 
 func (conn *Conn) Close() error {
+	if conn.replica != nil {
+		conn.replica.Close()
+	}
 	conn.c.Close()
 	return nil
 }
 
+func (conn *Conn) Ping() error {
+	return conn.c.Ping()
+}
+
+// Unavailable returns how long postgres doesn't respond, 0 if it's available
+func (conn *Conn) Unavailable() time.Duration {
+	return conn.c.Unavailable()
+}
+
 func (conn *Conn) initMetrics(metrics *monitoring.Metrics) {
 	var err error
 	conn.batchSizeBytes, err = metrics.RegisterHistogram("batch_size_bytes")
@@ -98,6 +132,14 @@ func (conn *Conn) initMetrics(metrics *monitoring.Metrics) {
 	if err != nil {
 		log.Printf("can't create sqlRequestNumber metric: %s", err)
 	}
+	conn.sqlRequestErrors, err = metrics.RegisterCounter("sql_request_errors")
+	if err != nil {
+		log.Printf("can't create sqlRequestErrors metric: %s", err)
+	}
+	conn.bulkRows, err = metrics.RegisterHistogram("postgres_bulk_rows")
+	if err != nil {
+		log.Printf("can't create bulkRows metric: %s", err)
+	}
 }
 
 func (conn *Conn) initBulks() {
@@ -106,7 +148,8 @@ func (conn *Conn) initBulks() {
 		"autocomplete",
 		"(value, type, project_id)",
 		"($%d, $%d, $%d)",
-		3, 100)
+		"text, text, integer",
+		3, 100, conn.bulkRows)
 	if err != nil {
 		log.Fatalf("can't create autocomplete bulk")
 	}
@@ -114,15 +157,17 @@ func (conn *Conn) initBulks() {
 		"events_common.requests",
 		"(session_id, timestamp, seq_index, url, duration, success)",
 		"($%d, $%d, $%d, left($%d, 2700), $%d, $%d)",
-		6, 100)
+		"bigint, bigint, integer, text, integer, boolean",
+		6, 100, conn.bulkRows)
 	if err != nil {
 		log.Fatalf("can't create requests bulk")
 	}
 	conn.customEvents, err = NewBulk(conn.c,
 		"events_common.customs",
 		"(session_id, timestamp, seq_index, name, payload)",
-		"($%d, $%d, $%d, left($%d, 2700), $%d)",
-		5, 100)
+		"($%d, $%d, $%d, left($%d, 2700), $%d::jsonb)",
+		"bigint, bigint, integer, text, text",
+		5, 100, conn.bulkRows)
 	if err != nil {
 		log.Fatalf("can't create customEvents bulk")
 	}
@@ -133,7 +178,9 @@ func (conn *Conn) initBulks() {
 			"time_to_interactive, response_time, dom_building_time)",
 		"($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, NULLIF($%d, 0), NULLIF($%d, 0), NULLIF($%d, 0), NULLIF($%d, 0),"+
 			" NULLIF($%d, 0), NULLIF($%d, 0), NULLIF($%d, 0), NULLIF($%d, 0), NULLIF($%d, 0), NULLIF($%d, 0))",
-		18, 100)
+		"bigint, bigint, bigint, text, text, text, text, text, bigint, bigint, bigint, bigint, bigint, bigint, "+
+			"bigint, bigint, bigint, bigint",
+		18, 100, conn.bulkRows)
 	if err != nil {
 		log.Fatalf("can't create webPageEvents bulk")
 	}
@@ -141,7 +188,8 @@ func (conn *Conn) initBulks() {
 		"events.inputs",
 		"(session_id, message_id, timestamp, value, label)",
 		"($%d, $%d, $%d, $%d, NULLIF($%d,''))",
-		5, 100)
+		"bigint, bigint, bigint, text, text",
+		5, 100, conn.bulkRows)
 	if err != nil {
 		log.Fatalf("can't create webPageEvents bulk")
 	}
@@ -149,10 +197,47 @@ func (conn *Conn) initBulks() {
 		"events.graphql",
 		"(session_id, timestamp, message_id, name, request_body, response_body)",
 		"($%d, $%d, $%d, left($%d, 2700), $%d, $%d)",
-		6, 100)
+		"bigint, bigint, bigint, text, text, text",
+		6, 100, conn.bulkRows)
 	if err != nil {
 		log.Fatalf("can't create webPageEvents bulk")
 	}
+	conn.webFetchEvents, err = NewBulk(conn.c,
+		"events_common.requests",
+		"(session_id, timestamp, seq_index, url, host, path, query, request_body, response_body, status_code, "+
+			"method, duration, success)",
+		"($%d, $%d, $%d, left($%d, 2700), $%d, $%d, $%d, $%d, $%d, $%d::smallint, NULLIF($%d, '')::http_method, "+
+			"$%d, $%d)",
+		"bigint, bigint, integer, text, text, text, text, text, text, bigint, text, bigint, boolean",
+		13, 100, conn.bulkRows)
+	if err != nil {
+		log.Fatalf("can't create webFetchEvents bulk")
+	}
+	conn.webResourceEvents, err = NewBulk(conn.c,
+		"events.resources",
+		"(session_id, timestamp, message_id, type, url, url_host, url_hostpath, success, status, method, "+
+			"duration, ttfb, header_size, encoded_body_size, decoded_body_size)",
+		"($%d, $%d, $%d, $%d::events.resource_type, left($%d, 2700), $%d, $%d, $%d, $%d, "+
+			"NULLIF($%d, '')::events.resource_method, "+
+			"NULLIF($%d, 0), NULLIF($%d, 0), NULLIF($%d, 0), NULLIF($%d, 0), NULLIF($%d, 0))",
+		"bigint, bigint, bigint, text, text, text, text, boolean, bigint, text, "+
+			"bigint, bigint, bigint, bigint, bigint",
+		15, 100, conn.bulkRows)
+	if err != nil {
+		log.Fatalf("can't create webResourceEvents bulk")
+	}
+	conn.webPerformance, err = NewBulk(conn.c,
+		"events.performance",
+		"(session_id, timestamp, message_id, min_fps, avg_fps, max_fps, min_cpu, avg_cpu, max_cpu, "+
+			"min_total_js_heap_size, avg_total_js_heap_size, max_total_js_heap_size, "+
+			"min_used_js_heap_size, avg_used_js_heap_size, max_used_js_heap_size)",
+		"($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+		"bigint, bigint, bigint, bigint, bigint, bigint, bigint, bigint, bigint, "+
+			"bigint, bigint, bigint, bigint, bigint, bigint",
+		15, 100, conn.bulkRows)
+	if err != nil {
+		log.Fatalf("can't create webPerformance bulk")
+	}
 }
 
 func (conn *Conn) insertAutocompleteValue(sessionID uint64, projectID uint32, tp string, value string) {
@@ -218,6 +303,15 @@ func (conn *Conn) sendBulks() {
 	if err := conn.webGraphQLEvents.Send(); err != nil {
 		log.Printf("webGraphQLEvents bulk send err: %s", err)
 	}
+	if err := conn.webFetchEvents.Send(); err != nil {
+		log.Printf("webFetchEvents bulk send err: %s", err)
+	}
+	if err := conn.webResourceEvents.Send(); err != nil {
+		log.Printf("webResourceEvents bulk send err: %s", err)
+	}
+	if err := conn.webPerformance.Send(); err != nil {
+		log.Printf("webPerformance bulk send err: %s", err)
+	}
 }
 
 func (conn *Conn) CommitBatches() {
@@ -235,27 +329,7 @@ func (conn *Conn) CommitBatches() {
 		conn.batchSizeBytes.Record(context.Background(), float64(conn.batchSizes[sessID]))
 		conn.batchSizeLines.Record(context.Background(), float64(b.Len()))
 
-		start := time.Now()
-		isFailed := false
-
-		// Send batch to db and execute
-		br := conn.c.SendBatch(b)
-		l := b.Len()
-		for i := 0; i < l; i++ {
-			if ct, err := br.Exec(); err != nil {
-				log.Printf("Error in PG batch (command tag %s, session: %d): %v \n", ct.String(), sessID, err)
-				failedSql := conn.rawBatches[sessID][i]
-				query := strings.ReplaceAll(failedSql.query, "\n", " ")
-				log.Println("failed sql req:", query, failedSql.arguments)
-				isFailed = true
-			}
-		}
-		br.Close() // returns err
-		conn.sqlRequestTime.Record(context.Background(), float64(time.Now().Sub(start).Milliseconds()),
-			attribute.String("method", "batch"), attribute.Bool("failed", isFailed))
-		conn.sqlRequestCounter.Add(context.Background(), 1,
-			attribute.String("method", "batch"), attribute.Bool("failed", isFailed))
-		if !isFailed {
+		if conn.sendBatch(sessID, b) {
 			delete(conn.sessionUpdates, sessID)
 		}
 	}
@@ -302,31 +376,35 @@ func (conn *Conn) commitBatch(sessionID uint64) {
 	conn.batchSizeBytes.Record(context.Background(), float64(conn.batchSizes[sessionID]))
 	conn.batchSizeLines.Record(context.Background(), float64(b.Len()))
 
+	conn.sendBatch(sessionID, b)
+
+	// Clean batch info
+	delete(conn.batches, sessionID)
+	delete(conn.batchSizes, sessionID)
+	delete(conn.rawBatches, sessionID)
+	delete(conn.sessionUpdates, sessionID)
+}
+
+// sendBatch returns false if any statement of the batch failed, the batch is retried on transient errors only,
+// so statements which failed with permanent ones are logged and dropped
+func (conn *Conn) sendBatch(sessionID uint64, b *pgx.Batch) bool {
 	start := time.Now()
 	isFailed := false
-
-	// Send batch to db and execute
-	br := conn.c.SendBatch(b)
-	l := b.Len()
-	for i := 0; i < l; i++ {
-		if ct, err := br.Exec(); err != nil {
-			log.Printf("Error in PG batch (command tag %s, session: %d): %v \n", ct.String(), sessionID, err)
-			failedSql := conn.rawBatches[sessionID][i]
-			query := strings.ReplaceAll(failedSql.query, "\n", " ")
-			log.Println("failed sql req:", query, failedSql.arguments)
-			isFailed = true
+	for i, err := range conn.c.ExecBatch(b) {
+		if err == nil {
+			continue
 		}
+		log.Printf("Error in PG batch (session: %d): %v \n", sessionID, err)
+		failedSql := conn.rawBatches[sessionID][i]
+		method, table := methodName(failedSql.query)
+		conn.recordError(err, attribute.String("method", method), attribute.String("table", table))
+		query := strings.ReplaceAll(failedSql.query, "\n", " ")
+		log.Println("failed sql req:", query, failedSql.arguments)
+		isFailed = true
 	}
-	br.Close()
-
 	conn.sqlRequestTime.Record(context.Background(), float64(time.Now().Sub(start).Milliseconds()),
 		attribute.String("method", "batch"), attribute.Bool("failed", isFailed))
 	conn.sqlRequestCounter.Add(context.Background(), 1,
 		attribute.String("method", "batch"), attribute.Bool("failed", isFailed))
-
-	// Clean batch info
-	delete(conn.batches, sessionID)
-	delete(conn.batchSizes, sessionID)
-	delete(conn.rawBatches, sessionID)
-	delete(conn.sessionUpdates, sessionID)
+	return !isFailed
 }
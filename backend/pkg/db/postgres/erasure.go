@@ -0,0 +1,157 @@
+package postgres
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Statuses of erasure requests
+const (
+	ErasurePending = "pending"
+	ErasureDone    = "done"
+	ErasureFailed  = "failed"
+)
+
+// ErasureRequest is a request to erase all data of the user, the report is saved when it's processed
+type ErasureRequest struct {
+	ErasureID   uint64          `json:"erasureId"`
+	ProjectID   uint32          `json:"projectId"`
+	UserID      string          `json:"userId,omitempty"`
+	AnonymousID string          `json:"anonymousId,omitempty"`
+	RequestedBy uint64          `json:"requestedBy"`
+	Status      string          `json:"status"`
+	Report      json.RawMessage `json:"report,omitempty"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	FinishedAt  *time.Time      `json:"finishedAt,omitempty"`
+}
+
+func (conn *Conn) InsertErasureRequest(projectID uint32, userID, anonymousID string, requestedBy uint64) (uint64, error) {
+	var id uint64
+	err := conn.c.QueryRow(`
+		INSERT INTO erasure_requests (project_id, user_id, anonymous_id, requested_by)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), $4)
+		RETURNING erasure_id`,
+		projectID, userID, anonymousID, requestedBy,
+	).Scan(&id)
+	return id, err
+}
+
+// GetErasureRequest returns nil if the project has no such request
+func (conn *Conn) GetErasureRequest(projectID uint32, erasureID uint64) (*ErasureRequest, error) {
+	rows, err := conn.c.Query(erasureSelect+`
+		WHERE project_id = $1 AND erasure_id = $2`,
+		projectID, erasureID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	requests, err := scanErasureRequests(rows)
+	if err != nil || len(requests) == 0 {
+		return nil, err
+	}
+	return requests[0], nil
+}
+
+// GetPendingErasureRequests returns the oldest not processed requests
+func (conn *Conn) GetPendingErasureRequests(limit int) ([]*ErasureRequest, error) {
+	rows, err := conn.c.Query(erasureSelect+`
+		WHERE status = $1
+		ORDER BY erasure_id
+		LIMIT $2`,
+		ErasurePending, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanErasureRequests(rows)
+}
+
+func (conn *Conn) FinishErasureRequest(erasureID uint64, status string, report []byte) error {
+	return conn.c.Exec(`
+		UPDATE erasure_requests
+		SET status = $2, report = $3::jsonb, finished_at = (now() at time zone 'utc')
+		WHERE erasure_id = $1`,
+		erasureID, status, string(report),
+	)
+}
+
+const erasureSelect = `
+		SELECT erasure_id, project_id, COALESCE(user_id, ''), COALESCE(anonymous_id, ''), requested_by,
+			status, report, created_at, finished_at
+		FROM erasure_requests`
+
+func scanErasureRequests(rows pgx.Rows) ([]*ErasureRequest, error) {
+	defer rows.Close()
+	var requests []*ErasureRequest
+	for rows.Next() {
+		r := &ErasureRequest{}
+		var report []byte
+		if err := rows.Scan(&r.ErasureID, &r.ProjectID, &r.UserID, &r.AnonymousID, &r.RequestedBy,
+			&r.Status, &report, &r.CreatedAt, &r.FinishedAt); err != nil {
+			return nil, err
+		}
+		r.Report = report
+		requests = append(requests, r)
+	}
+	return requests, rows.Err()
+}
+
+// GetUserSessionIDs returns sessions of the project with the user id or the anonymous id, empty ids are ignored
+func (conn *Conn) GetUserSessionIDs(projectID uint32, userID, anonymousID string) ([]uint64, error) {
+	rows, err := conn.c.Query(`
+		SELECT session_id
+		FROM sessions
+		WHERE project_id = $1
+		  AND (user_id = NULLIF($2, '') OR user_anonymous_id = NULLIF($3, ''))`,
+		projectID, userID, anonymousID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uint64
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// EraseUser removes sessions (with their events by cascade), imported attributes and autocomplete values
+// of the user in one transaction
+func (conn *Conn) EraseUser(projectID uint32, userID, anonymousID string, sessionIDs []uint64) error {
+	return conn.c.InTx(func(tx *_Tx) error {
+		if err := tx.exec(`
+			DELETE FROM sessions
+			WHERE project_id = $1 AND session_id = ANY($2)`,
+			projectID, sessionIDs,
+		); err != nil {
+			return err
+		}
+		if userID != "" {
+			if err := tx.exec(`
+				DELETE FROM user_attributes
+				WHERE project_id = $1 AND user_id = $2`,
+				projectID, userID,
+			); err != nil {
+				return err
+			}
+		}
+		if err := tx.exec(`
+			DELETE FROM autocomplete
+			WHERE project_id = $1
+			  AND (type IN ('USERID', 'USERID_IOS') AND value = NULLIF($2, '')
+				OR type IN ('USERANONYMOUSID', 'USERANONYMOUSID_IOS') AND value = NULLIF($3, ''))`,
+			projectID, userID, anonymousID,
+		); err != nil {
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,236 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"openreplay/backend/pkg/env"
+)
+
+// Connections are probed in the background and replaced by the pool, statements which fail because of
+// a lost connection are retried with backoff, so a failover of the primary doesn't need restarts of services
+const (
+	defaultMaxConnLifetime   = 30 * time.Minute
+	defaultHealthCheckPeriod = 10 * time.Second
+	defaultRetryAttempts     = 3
+	retryBaseDelay           = 100 * time.Millisecond
+	retryMaxDelay            = 2 * time.Second
+	pingTimeout              = 5 * time.Second
+)
+
+// Errors of statements which weren't executed or were rolled back, the same statement may succeed later.
+// Other errors of the server (constraints, syntax, types) are permanent and aren't retried.
+var retryableCodes = map[string]bool{
+	pgerrcode.ReadOnlySQLTransaction: true,
+	pgerrcode.AdminShutdown:          true,
+	pgerrcode.CrashShutdown:          true,
+	pgerrcode.CannotConnectNow:       true,
+	pgerrcode.SerializationFailure:   true,
+	pgerrcode.DeadlockDetected:       true,
+	pgerrcode.TooManyConnections:     true,
+}
+
+// poolConfig applies POSTGRES_MAX_CONN_LIFETIME and POSTGRES_HEALTH_CHECK_PERIOD, connections live limited time,
+// so they are spread over the new servers after a failover
+func poolConfig(url string) (*pgxpool.Config, error) {
+	cfg, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		return nil, err
+	}
+	cfg.MaxConnLifetime = env.DurationOptional("POSTGRES_MAX_CONN_LIFETIME")
+	if cfg.MaxConnLifetime <= 0 {
+		cfg.MaxConnLifetime = defaultMaxConnLifetime
+	}
+	cfg.HealthCheckPeriod = env.DurationOptional("POSTGRES_HEALTH_CHECK_PERIOD")
+	if cfg.HealthCheckPeriod <= 0 {
+		cfg.HealthCheckPeriod = defaultHealthCheckPeriod
+	}
+	return cfg, nil
+}
+
+// isRetryable reports errors of statements which didn't reach the server or were rejected without execution
+func isRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if pgconn.SafeToRetry(err) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (retryableCodes[pgErr.Code] || pgerrcode.IsConnectionException(pgErr.Code))
+}
+
+// isRetryableWrite also reports timeouts, connections lost in the middle of the statement and ambiguous results
+// of CockroachDB (40003), the statement may have been executed then, so only inserts which skip rows with
+// existing natural keys are retried on them
+func isRetryableWrite(err error) bool {
+	if isRetryable(err) || pgconn.Timeout(err) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.StatementCompletionUnknown {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// isConflict reports transactions aborted because of concurrent ones, the server itself is available
+func isConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) &&
+		(pgErr.Code == pgerrcode.SerializationFailure || pgErr.Code == pgerrcode.DeadlockDetected)
+}
+
+func isReadOnly(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgerrcode.ReadOnlySQLTransaction
+}
+
+// healthChecker pings idle connections of the pool and closes broken ones, the pool opens new connections instead
+type healthChecker struct {
+	pool     *pgxpool.Pool
+	attempts int
+	healthy  int32 // 1 if the last check found a working connection, changes are logged
+	since    int64 // unix ns of the first failed check, 0 while the server is available
+}
+
+func newHealthChecker(pool *pgxpool.Pool, period time.Duration) *healthChecker {
+	h := &healthChecker{
+		pool:     pool,
+		attempts: env.IntOptional("POSTGRES_RETRY_ATTEMPTS"),
+		healthy:  1,
+	}
+	if h.attempts <= 0 {
+		h.attempts = defaultRetryAttempts
+		if IsCockroach() {
+			h.attempts = cockroachRetryAttempts
+		}
+	}
+	go func() {
+		for range time.Tick(period) {
+			h.check()
+		}
+	}()
+	return h
+}
+
+// check pings all idle connections, a new connection is opened if there are none of them
+func (h *healthChecker) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	alive, broken := 0, 0
+	for _, c := range h.pool.AcquireAllIdle(ctx) {
+		if err := c.Conn().Ping(ctx); err != nil {
+			c.Conn().Close(ctx)
+			broken++
+		} else {
+			alive++
+		}
+		c.Release()
+	}
+	if alive == 0 {
+		if c, err := h.pool.Acquire(ctx); err == nil {
+			if err := c.Conn().Ping(ctx); err == nil {
+				alive++
+			}
+			c.Release()
+		}
+	}
+	if broken > 0 {
+		log.Printf("postgres: closed broken connections: %d", broken)
+	}
+	h.setHealthy(alive > 0)
+}
+
+func (h *healthChecker) setHealthy(healthy bool) {
+	state := int32(0)
+	if healthy {
+		state = 1
+	}
+	if atomic.SwapInt32(&h.healthy, state) == state {
+		return
+	}
+	if healthy {
+		atomic.StoreInt64(&h.since, 0)
+		log.Printf("postgres: available again")
+	} else {
+		atomic.StoreInt64(&h.since, time.Now().UnixNano())
+		log.Printf("postgres: unavailable")
+	}
+}
+
+// unavailable returns how long the server is unavailable, 0 if it's available
+func (h *healthChecker) unavailable() time.Duration {
+	since := atomic.LoadInt64(&h.since)
+	if since == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(0, since))
+}
+
+// ping checks one connection of the pool right away, the state is updated by the result
+func (h *healthChecker) ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	c, err := h.pool.Acquire(ctx)
+	if err == nil {
+		err = c.Conn().Ping(ctx)
+		c.Release()
+	}
+	h.setHealthy(err == nil)
+	return err
+}
+
+// closeIdle drops idle connections, they are connected to the server which isn't the primary anymore
+func (h *healthChecker) closeIdle() {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	for _, c := range h.pool.AcquireAllIdle(ctx) {
+		c.Conn().Close(ctx)
+		c.Release()
+	}
+}
+
+// retry calls fn until it succeeds or fails with a not retryable error, the delay is doubled after every attempt
+func (h *healthChecker) retry(fn func() error) error {
+	return h.retryOn(isRetryable, fn)
+}
+
+// retryWrite retries idempotent inserts, see isRetryableWrite
+func (h *healthChecker) retryWrite(fn func() error) error {
+	return h.retryOn(isRetryableWrite, fn)
+}
+
+func (h *healthChecker) retryOn(retryable func(error) bool, fn func() error) error {
+	delay := retryBaseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if !retryable(err) {
+			return err
+		}
+		if attempt >= h.attempts {
+			// The server doesn't respond for all attempts, the next check or ping finds it again
+			if !isConflict(err) {
+				h.setHealthy(false)
+			}
+			return err
+		}
+		if isReadOnly(err) {
+			h.closeIdle()
+		}
+		time.Sleep(delay)
+		if delay *= 2; delay > retryMaxDelay {
+			delay = retryMaxDelay
+		}
+	}
+}
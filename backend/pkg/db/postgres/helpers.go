@@ -1,10 +1,43 @@
 package postgres
 
 import (
+	"encoding/json"
+
 	"openreplay/backend/pkg/messages"
 )
 
+// getIssueSeverity returns the severity of custom issues reported by tracker plugins
+func getIssueSeverity(issueEvent *messages.IssueEvent) string {
+	if issueEvent.Type != "custom" || issueEvent.Context == "" {
+		return ""
+	}
+	context := struct {
+		Severity string `json:"severity"`
+	}{}
+	if err := json.Unmarshal([]byte(issueEvent.Context), &context); err != nil {
+		return ""
+	}
+	return context.Severity
+}
+
+func getCustomLevel(issueEvent *messages.IssueEvent) string {
+	switch getIssueSeverity(issueEvent) {
+	case "info", "low":
+		return "info"
+	default:
+		return "error"
+	}
+}
+
 func getIssueScore(issueEvent *messages.IssueEvent) int {
+	switch getIssueSeverity(issueEvent) {
+	case "critical":
+		return 1000
+	case "high":
+		return 500
+	case "info", "low":
+		return 10
+	}
 	switch issueEvent.Type {
 	case "crash", "dead_click", "memory", "cpu":
 		return 1000
@@ -1,13 +1,20 @@
 package postgres
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v4"
+
+	"openreplay/backend/pkg/env"
 )
 
+// Integrations are polled with this period in the CockroachDB compatibility mode
+const defaultPollPeriod = 10 * time.Second
+
 type Listener struct {
 	conn         *pgx.Conn
 	Integrations chan *Integration
@@ -24,6 +31,19 @@ func NewIntegrationsListener(url string) (*Listener, error) {
 		Errors: make(chan error),
 	}
 	listener.Integrations = make(chan *Integration, 50)
+	if IsCockroach() {
+		// There is no LISTEN/NOTIFY in CockroachDB, changes are found by comparing the table with its last state
+		known, err := listener.integrations()
+		if err != nil {
+			return nil, err
+		}
+		period := env.DurationOptional("POSTGRES_POLL_PERIOD")
+		if period <= 0 {
+			period = defaultPollPeriod
+		}
+		go listener.poll(known, period)
+		return listener, nil
+	}
 	if _, err := conn.Exec(context.Background(), "LISTEN integration"); err != nil {
 		return nil, err
 	}
@@ -50,6 +70,55 @@ func (listener *Listener) listen() {
 	}
 }
 
+type integrationKey struct {
+	projectID uint32
+	provider  string
+}
+
+// poll sends integrations which are new or have changed options like the notify_integration trigger does,
+// deleted integrations are sent without options
+func (listener *Listener) poll(known map[integrationKey]*Integration, period time.Duration) {
+	for range time.Tick(period) {
+		current, err := listener.integrations()
+		if err != nil {
+			listener.Errors <- err
+			continue
+		}
+		for key, i := range current {
+			if prev, ok := known[key]; !ok || !bytes.Equal(prev.Options, i.Options) {
+				listener.Integrations <- i
+			}
+		}
+		for key := range known {
+			if _, ok := current[key]; !ok {
+				listener.Integrations <- &Integration{ProjectID: key.projectID, Provider: key.provider}
+			}
+		}
+		known = current
+	}
+}
+
+func (listener *Listener) integrations() (map[integrationKey]*Integration, error) {
+	rows, err := listener.conn.Query(context.Background(), `
+		SELECT project_id, provider, options, request_data
+		FROM integrations
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	integrations := make(map[integrationKey]*Integration)
+	for rows.Next() {
+		i := new(Integration)
+		if err := rows.Scan(&i.ProjectID, &i.Provider, &i.Options, &i.RequestData); err != nil {
+			return nil, err
+		}
+		integrations[integrationKey{i.ProjectID, i.Provider}] = i
+	}
+	return integrations, rows.Err()
+}
+
 func (listener *Listener) Close() error {
 	return listener.conn.Close(context.Background())
 }
@@ -2,7 +2,6 @@ package postgres
 
 import (
 	"fmt"
-	"log"
 	"strings"
 
 	"openreplay/backend/pkg/db/types"
@@ -18,8 +17,9 @@ func getAutocompleteType(baseType string, platform string) string {
 
 }
 
+// InsertSessionStart skips the session if it already exists, so redelivered and retried starts don't fail
 func (conn *Conn) InsertSessionStart(sessionID uint64, s *types.Session) error {
-	return conn.c.Exec(`
+	return conn.c.ExecIdempotent(`
 		INSERT INTO sessions (
 			session_id, project_id, start_ts,
 			user_uuid, user_device, user_device_type, user_country,
@@ -38,7 +38,8 @@ func (conn *Conn) InsertSessionStart(sessionID uint64, s *types.Session) error {
 			$13,
 			NULLIF($14, ''), NULLIF($15, ''), NULLIF($16, ''), NULLIF($17, 0), NULLIF($18, 0::bigint),
 			NULLIF($19, '')
-		)`,
+		)
+		ON CONFLICT (session_id) DO NOTHING`,
 		sessionID, s.ProjectID, s.Timestamp,
 		s.UserUUID, s.UserDevice, s.UserDeviceType, s.UserCountry,
 		s.UserOS, s.UserOSVersion,
@@ -141,18 +142,7 @@ func (conn *Conn) InsertMetadata(sessionID uint64, keyNo uint, value string) err
 	return conn.c.Exec(fmt.Sprintf(sqlRequest, keyNo), value, sessionID)
 }
 
-func (conn *Conn) InsertIssueEvent(sessionID uint64, projectID uint32, e *messages.IssueEvent) (err error) {
-	tx, err := conn.c.Begin()
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if err != nil {
-			if rollbackErr := tx.rollback(); rollbackErr != nil {
-				log.Printf("rollback err: %s", rollbackErr)
-			}
-		}
-	}()
+func (conn *Conn) InsertIssueEvent(sessionID uint64, projectID uint32, e *messages.IssueEvent) error {
 	issueID := hashid.IssueID(projectID, e)
 
 	// TEMP. TODO: nullable & json message field type
@@ -165,50 +155,51 @@ func (conn *Conn) InsertIssueEvent(sessionID uint64, projectID uint32, e *messag
 		context = nil
 	}
 
-	if err = tx.exec(`
-		INSERT INTO issues (
-			project_id, issue_id, type, context_string, context
-		) (SELECT
-			project_id, $2, $3, $4, CAST($5 AS jsonb)
-			FROM sessions
-			WHERE session_id = $1
-		)ON CONFLICT DO NOTHING`,
-		sessionID, issueID, e.Type, e.ContextString, context,
-	); err != nil {
-		return err
-	}
-	if err = tx.exec(`
-		INSERT INTO events_common.issues (
-			session_id, issue_id, timestamp, seq_index, payload
-		) VALUES (
-			$1, $2, $3, $4, CAST($5 AS jsonb)
-		)`,
-		sessionID, issueID, e.Timestamp,
-		getSqIdx(e.MessageID),
-		payload,
-	); err != nil {
-		return err
-	}
-	if err = tx.exec(`
-		UPDATE sessions SET issue_score = issue_score + $2
-		WHERE session_id = $1`,
-		sessionID, getIssueScore(e),
-	); err != nil {
-		return err
-	}
-	// TODO: no redundancy. Deliver to UI in a different way
-	if e.Type == "custom" {
-		if err = tx.exec(`
-			INSERT INTO events_common.customs
-				(session_id, seq_index, timestamp, name, payload, level)
-			VALUES
-				($1, $2, $3, left($4, 2700), $5, 'error')
-			`,
-			sessionID, getSqIdx(e.MessageID), e.Timestamp, e.ContextString, e.Payload,
+	return conn.c.InTx(func(tx *_Tx) error {
+		if err := tx.exec(`
+			INSERT INTO issues (
+				project_id, issue_id, type, context_string, context
+			) (SELECT
+				project_id, $2, $3, $4, CAST($5 AS jsonb)
+				FROM sessions
+				WHERE session_id = $1
+			)ON CONFLICT DO NOTHING`,
+			sessionID, issueID, e.Type, e.ContextString, context,
 		); err != nil {
 			return err
 		}
-	}
-	err = tx.commit()
-	return
+		if err := tx.exec(`
+			INSERT INTO events_common.issues (
+				session_id, issue_id, timestamp, seq_index, payload
+			) VALUES (
+				$1, $2, $3, $4, CAST($5 AS jsonb)
+			)`,
+			sessionID, issueID, e.Timestamp,
+			getSqIdx(e.MessageID),
+			payload,
+		); err != nil {
+			return err
+		}
+		if err := tx.exec(`
+			UPDATE sessions SET issue_score = issue_score + $2
+			WHERE session_id = $1`,
+			sessionID, getIssueScore(e),
+		); err != nil {
+			return err
+		}
+		// TODO: no redundancy. Deliver to UI in a different way
+		if e.Type == "custom" {
+			if err := tx.exec(`
+				INSERT INTO events_common.customs
+					(session_id, seq_index, timestamp, name, payload, level)
+				VALUES
+					($1, $2, $3, left($4, 2700), $5, $6)
+				`,
+				sessionID, getSqIdx(e.MessageID), e.Timestamp, e.ContextString, e.Payload, getCustomLevel(e),
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
@@ -14,6 +14,11 @@ func (conn *Conn) InsertIOSCustomEvent(sessionID uint64, e *messages.IOSCustomEv
 	return err
 }
 
+// InsertMobileLifecycleEvent saves app state changes as custom events, so sessions can be searched by them
+func (conn *Conn) InsertMobileLifecycleEvent(sessionID uint64, e *messages.MobileLifecycleEvent) error {
+	return conn.InsertCustomEvent(sessionID, e.Timestamp, e.Index, "app_"+e.State, "")
+}
+
 func (conn *Conn) InsertIOSUserID(sessionID uint64, userID *messages.IOSUserID) error {
 	err := conn.InsertUserID(sessionID, userID.Value)
 	if err == nil {
@@ -39,30 +44,26 @@ func (conn *Conn) InsertIOSNetworkCall(sessionID uint64, e *messages.IOSNetworkC
 }
 
 func (conn *Conn) InsertIOSScreenEnter(sessionID uint64, screenEnter *messages.IOSScreenEnter) error {
-	tx, err := conn.c.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.rollback()
-
-	if err = tx.exec(`
-		INSERT INTO events_ios.views (
-			session_id, timestamp, seq_index, name
-		) VALUES (
-			$1, $2, $3, $4
-		)`,
-		sessionID, screenEnter.Timestamp, screenEnter.Index, screenEnter.ViewName,
-	); err != nil {
-		return err
-	}
-	if err = tx.exec(`
-		UPDATE sessions SET pages_count = pages_count + 1 
-		WHERE session_id = $1`,
-		sessionID,
-	); err != nil {
-		return err
-	}
-	if err = tx.commit(); err != nil {
+	if err := conn.c.InTx(func(tx *_Tx) error {
+		if err := tx.exec(`
+			INSERT INTO events_ios.views (
+				session_id, timestamp, seq_index, name
+			) VALUES (
+				$1, $2, $3, $4
+			)`,
+			sessionID, screenEnter.Timestamp, screenEnter.Index, screenEnter.ViewName,
+		); err != nil {
+			return err
+		}
+		if err := tx.exec(`
+			UPDATE sessions SET pages_count = pages_count + 1 
+			WHERE session_id = $1`,
+			sessionID,
+		); err != nil {
+			return err
+		}
+		return nil
+	}); err != nil {
 		return err
 	}
 	conn.insertAutocompleteValue(sessionID, 0, "VIEW_IOS", screenEnter.ViewName)
@@ -70,30 +71,26 @@ func (conn *Conn) InsertIOSScreenEnter(sessionID uint64, screenEnter *messages.I
 }
 
 func (conn *Conn) InsertIOSClickEvent(sessionID uint64, clickEvent *messages.IOSClickEvent) error {
-	tx, err := conn.c.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.rollback()
-
-	if err = tx.exec(`
-		INSERT INTO events_ios.clicks (
-			session_id, timestamp, seq_index, label
-		) VALUES (
-			$1, $2, $3, $4
-		)`,
-		sessionID, clickEvent.Timestamp, clickEvent.Index, clickEvent.Label,
-	); err != nil {
-		return err
-	}
-	if err = tx.exec(`
-		UPDATE sessions SET events_count = events_count + 1
-		WHERE session_id = $1`,
-		sessionID,
-	); err != nil {
-		return err
-	}
-	if err = tx.commit(); err != nil {
+	if err := conn.c.InTx(func(tx *_Tx) error {
+		if err := tx.exec(`
+			INSERT INTO events_ios.clicks (
+				session_id, timestamp, seq_index, label
+			) VALUES (
+				$1, $2, $3, $4
+			)`,
+			sessionID, clickEvent.Timestamp, clickEvent.Index, clickEvent.Label,
+		); err != nil {
+			return err
+		}
+		if err := tx.exec(`
+			UPDATE sessions SET events_count = events_count + 1
+			WHERE session_id = $1`,
+			sessionID,
+		); err != nil {
+			return err
+		}
+		return nil
+	}); err != nil {
 		return err
 	}
 	conn.insertAutocompleteValue(sessionID, 0, "CLICK_IOS", clickEvent.Label)
@@ -101,35 +98,31 @@ func (conn *Conn) InsertIOSClickEvent(sessionID uint64, clickEvent *messages.IOS
 }
 
 func (conn *Conn) InsertIOSInputEvent(sessionID uint64, inputEvent *messages.IOSInputEvent) error {
-	tx, err := conn.c.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.rollback()
-
 	var value interface{} = inputEvent.Value
 	if inputEvent.ValueMasked {
 		value = nil
 	}
 
-	if err = tx.exec(`
-		INSERT INTO events_ios.inputs (
-			session_id, timestamp, seq_index, label, value
-		) VALUES (
-			$1, $2, $3, $4, $5
-		)`,
-		sessionID, inputEvent.Timestamp, inputEvent.Index, inputEvent.Label, value,
-	); err != nil {
-		return err
-	}
-	if err = tx.exec(`
-		UPDATE sessions SET events_count = events_count + 1
-		WHERE session_id = $1`,
-		sessionID,
-	); err != nil {
-		return err
-	}
-	if err = tx.commit(); err != nil {
+	if err := conn.c.InTx(func(tx *_Tx) error {
+		if err := tx.exec(`
+			INSERT INTO events_ios.inputs (
+				session_id, timestamp, seq_index, label, value
+			) VALUES (
+				$1, $2, $3, $4, $5
+			)`,
+			sessionID, inputEvent.Timestamp, inputEvent.Index, inputEvent.Label, value,
+		); err != nil {
+			return err
+		}
+		if err := tx.exec(`
+			UPDATE sessions SET events_count = events_count + 1
+			WHERE session_id = $1`,
+			sessionID,
+		); err != nil {
+			return err
+		}
+		return nil
+	}); err != nil {
 		return err
 	}
 	conn.insertAutocompleteValue(sessionID, 0, "INPUT_IOS", inputEvent.Label)
@@ -138,42 +131,38 @@ func (conn *Conn) InsertIOSInputEvent(sessionID uint64, inputEvent *messages.IOS
 }
 
 func (conn *Conn) InsertIOSCrash(sessionID uint64, projectID uint32, crash *messages.IOSCrash) error {
-	tx, err := conn.c.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.rollback()
-
 	crashID := hashid.IOSCrashID(projectID, crash)
 
-	if err = tx.exec(`
-		INSERT INTO crashes_ios (
-			project_id, crash_id, name, reason, stacktrace
-		) (SELECT
-			project_id, $2, $3, $4, $5
-			FROM sessions
-			WHERE session_id = $1
-		)ON CONFLICT DO NOTHING`,
-		sessionID, crashID, crash.Name, crash.Reason, crash.Stacktrace,
-	); err != nil {
-		return err
-	}
-	if err = tx.exec(`
-		INSERT INTO events_ios.crashes (
-			session_id, timestamp, seq_index, crash_id
-		) VALUES (
-			$1, $2, $3, $4
-		)`,
-		sessionID, crash.Timestamp, crash.Index, crashID,
-	); err != nil {
-		return err
-	}
-	if err = tx.exec(`
-		UPDATE sessions SET errors_count = errors_count + 1, issue_score = issue_score + 1000
-		WHERE session_id = $1`,
-		sessionID,
-	); err != nil {
-		return err
-	}
-	return tx.commit()
+	return conn.c.InTx(func(tx *_Tx) error {
+		if err := tx.exec(`
+			INSERT INTO crashes_ios (
+				project_id, crash_id, name, reason, stacktrace
+			) (SELECT
+				project_id, $2, $3, $4, $5
+				FROM sessions
+				WHERE session_id = $1
+			)ON CONFLICT DO NOTHING`,
+			sessionID, crashID, crash.Name, crash.Reason, crash.Stacktrace,
+		); err != nil {
+			return err
+		}
+		if err := tx.exec(`
+			INSERT INTO events_ios.crashes (
+				session_id, timestamp, seq_index, crash_id
+			) VALUES (
+				$1, $2, $3, $4
+			)`,
+			sessionID, crash.Timestamp, crash.Index, crashID,
+		); err != nil {
+			return err
+		}
+		if err := tx.exec(`
+			UPDATE sessions SET errors_count = errors_count + 1, issue_score = issue_score + 1000
+			WHERE session_id = $1`,
+			sessionID,
+		); err != nil {
+			return err
+		}
+		return nil
+	})
 }
@@ -1,6 +1,7 @@
 package postgres
 
 import (
+	"fmt"
 	"log"
 	"math"
 
@@ -68,7 +69,7 @@ func (conn *Conn) InsertWebClickEvent(sessionID uint64, projectID uint32, e *Cli
 			FROM events.pages
 			WHERE session_id = $1 AND timestamp <= $3 ORDER BY timestamp DESC LIMIT 1
 		)
-		`
+		ON CONFLICT DO NOTHING`
 	conn.batchQueue(sessionID, sqlRequest, sessionID, e.MessageID, e.Timestamp, e.Label, e.Selector)
 	// Accumulate session updates and exec inside batch with another sql commands
 	conn.updateSessionEvents(sessionID, 1, 0)
@@ -93,49 +94,39 @@ func (conn *Conn) InsertWebInputEvent(sessionID uint64, projectID uint32, e *Inp
 	return nil
 }
 
-func (conn *Conn) InsertWebErrorEvent(sessionID uint64, projectID uint32, e *ErrorEvent) (err error) {
-	tx, err := conn.c.Begin()
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if err != nil {
-			if rollbackErr := tx.rollback(); rollbackErr != nil {
-				log.Printf("rollback err: %s", rollbackErr)
-			}
-		}
-	}()
+func (conn *Conn) InsertWebErrorEvent(sessionID uint64, projectID uint32, e *ErrorEvent) error {
 	errorID := hashid.WebErrorID(projectID, e)
 
-	if err = tx.exec(`
-		INSERT INTO errors
-			(error_id, project_id, source, name, message, payload)
-		VALUES
-			($1, $2, $3, $4, $5, $6::jsonb)
-		ON CONFLICT DO NOTHING`,
-		errorID, projectID, e.Source, e.Name, e.Message, e.Payload,
-	); err != nil {
-		return err
-	}
-	if err = tx.exec(`
-		INSERT INTO events.errors
-			(session_id, message_id, timestamp, error_id)
-		VALUES
-			($1, $2, $3, $4)
-		`,
-		sessionID, e.MessageID, e.Timestamp, errorID,
-	); err != nil {
-		return err
-	}
-	if err = tx.exec(`
-		UPDATE sessions SET errors_count = errors_count + 1, issue_score = issue_score + 1000
-		WHERE session_id = $1`,
-		sessionID,
-	); err != nil {
-		return err
-	}
-	err = tx.commit()
-	return
+	return conn.c.InTx(func(tx *_Tx) error {
+		if err := tx.exec(`
+			INSERT INTO errors
+				(error_id, project_id, source, name, message, payload)
+			VALUES
+				($1, $2, $3, $4, $5, $6::jsonb)
+			ON CONFLICT DO NOTHING`,
+			errorID, projectID, e.Source, e.Name, e.Message, e.Payload,
+		); err != nil {
+			return err
+		}
+		if err := tx.exec(`
+			INSERT INTO events.errors
+				(session_id, message_id, timestamp, error_id)
+			VALUES
+				($1, $2, $3, $4)
+			`,
+			sessionID, e.MessageID, e.Timestamp, errorID,
+		); err != nil {
+			return err
+		}
+		if err := tx.exec(`
+			UPDATE sessions SET errors_count = errors_count + 1, issue_score = issue_score + 1000
+			WHERE session_id = $1`,
+			sessionID,
+		); err != nil {
+			return err
+		}
+		return nil
+	})
 }
 
 func (conn *Conn) InsertWebFetchEvent(sessionID uint64, projectID uint32, savePayload bool, e *FetchEvent) error {
@@ -150,28 +141,13 @@ func (conn *Conn) InsertWebFetchEvent(sessionID uint64, projectID uint32, savePa
 		return err
 	}
 
-	sqlRequest := `
-		INSERT INTO events_common.requests (
-			session_id, timestamp, seq_index, 
-			url, host, path, query,
-			request_body, response_body, status_code, method,
-			duration, success
-		) VALUES (
-			$1, $2, $3, 
-			left($4, 2700), $5, $6, $7,
-			$8, $9, $10::smallint, NULLIF($11, '')::http_method,
-			$12, $13
-		) ON CONFLICT DO NOTHING`
-	conn.batchQueue(sessionID, sqlRequest,
-		sessionID, e.Timestamp, getSqIdx(e.MessageID),
+	if err := conn.webFetchEvents.Append(sessionID, e.Timestamp, getSqIdx(e.MessageID),
 		e.URL, host, path, query,
 		request, response, e.Status, url.EnsureMethod(e.Method),
 		e.Duration, e.Status < 400,
-	)
-
-	// Record approximate message size
-	conn.updateBatchSize(sessionID, len(sqlRequest)+len(e.URL)+len(host)+len(path)+len(query)+
-		len(e.Request)+len(e.Response)+len(url.EnsureMethod(e.Method))+8*5+1)
+	); err != nil {
+		return fmt.Errorf("insert web fetch event in bulk err: %s", err)
+	}
 	return nil
 }
 
@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+)
+
+// sqlMetrics records time of statements with retries and counts failed ones by the class of the error,
+// so the lag of the ingest can be split between the database and the consumers
+type sqlMetrics struct {
+	sqlRequestTime    syncfloat64.Histogram
+	sqlRequestCounter syncfloat64.Counter
+	sqlRequestErrors  syncfloat64.Counter
+}
+
+func (m sqlMetrics) recordSQL(sql string, start time.Time, err error) {
+	method, table := methodName(sql)
+	m.record(method, table, start, err)
+}
+
+// record skips the table attribute if it's empty, e.g. for transactions and batches of many tables
+func (m sqlMetrics) record(method, table string, start time.Time, err error, extra ...attribute.KeyValue) {
+	attrs := []attribute.KeyValue{attribute.String("method", method)}
+	if table != "" {
+		attrs = append(attrs, attribute.String("table", table))
+	}
+	attrs = append(attrs, extra...)
+	m.sqlRequestTime.Record(context.Background(), float64(time.Now().Sub(start).Milliseconds()), attrs...)
+	m.sqlRequestCounter.Add(context.Background(), 1, attrs...)
+	m.recordError(err, attrs...)
+}
+
+func (m sqlMetrics) recordError(err error, attrs ...attribute.KeyValue) {
+	class := errorClass(err)
+	if class == "" || m.sqlRequestErrors == nil {
+		return
+	}
+	m.sqlRequestErrors.Add(context.Background(), 1, append(attrs, attribute.String("class", class))...)
+}
+
+// errorClass groups errors of statements for metrics, empty for successful statements and missing rows
+func errorClass(err error) string {
+	if err == nil || errors.Is(err, pgx.ErrNoRows) {
+		return ""
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgerrcode.IsIntegrityConstraintViolation(pgErr.Code):
+			return "constraint"
+		case pgerrcode.IsDataException(pgErr.Code):
+			return "data"
+		case isConflict(err) || pgerrcode.IsTransactionRollback(pgErr.Code):
+			return "conflict"
+		case retryableCodes[pgErr.Code] || pgerrcode.IsConnectionException(pgErr.Code):
+			return "unavailable"
+		case pgerrcode.IsSyntaxErrororAccessRuleViolation(pgErr.Code):
+			return "query"
+		}
+		return "server"
+	}
+	switch {
+	case pgconn.Timeout(err):
+		return "timeout"
+	case isRetryableWrite(err):
+		return "connection"
+	}
+	return "other"
+}
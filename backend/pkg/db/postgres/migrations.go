@@ -0,0 +1,356 @@
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+
+	"openreplay/backend/pkg/env"
+)
+
+// Upgrade scripts of releases newer than baseVersion, copies of scripts/helm/db/init_dbs/postgresql/<version>/<version>.sql
+// which have to be kept the same. Older schemas are upgraded by the scripts of the repo first.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+const baseVersion = "1.8.1"
+
+// Modes of POSTGRES_MIGRATIONS, services don't touch the schema by default. Verify fails the start if the schema
+// is behind the embedded migrations, run applies them under the advisory lock, so services starting together
+// apply every migration once.
+const (
+	migrationsOff    = "off"
+	migrationsVerify = "verify"
+	migrationsRun    = "run"
+
+	migrationsLockKey = 7352834159 // any number which isn't used by other advisory locks of the database
+)
+
+type migration struct {
+	version  string
+	sql      string
+	checksum string
+}
+
+// migrate verifies or applies the embedded migrations with its own connection, statements of the scripts run one
+// by one on it, so BEGIN and COMMIT of the scripts work and indexes are created concurrently out of transactions
+func migrate(url string) error {
+	mode := strings.ToLower(strings.TrimSpace(env.StringOptional("POSTGRES_MIGRATIONS")))
+	switch mode {
+	case "", migrationsOff:
+		return nil
+	case migrationsVerify, migrationsRun:
+	default:
+		return fmt.Errorf("unknown migrations mode: %s", mode)
+	}
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	if mode == migrationsRun {
+		if IsCockroach() {
+			// There are no advisory locks in CockroachDB, only one service should run migrations
+			log.Printf("postgres migrations: running without the lock")
+		} else {
+			if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationsLockKey); err != nil {
+				return fmt.Errorf("can't take the migrations lock: %s", err)
+			}
+			defer conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", migrationsLockKey)
+		}
+		if _, err := conn.Exec(ctx, `
+			CREATE TABLE IF NOT EXISTS schema_migrations
+			(
+				version    text PRIMARY KEY,
+				checksum   text                        NOT NULL,
+				dirty      boolean                     NOT NULL DEFAULT false,
+				applied_at timestamp without time zone NOT NULL DEFAULT (now() at time zone 'utc')
+			)`); err != nil {
+			return fmt.Errorf("can't create the migrations table: %s", err)
+		}
+	}
+	pending, baseline, err := pendingMigrations(ctx, conn, migrations)
+	if err != nil {
+		return err
+	}
+	if mode == migrationsVerify {
+		if len(pending) > 0 {
+			return fmt.Errorf("schema is behind the migrations: %s", strings.Join(versions(pending), ", "))
+		}
+		return nil
+	}
+	for _, m := range baseline {
+		if _, err := conn.Exec(ctx, `
+			INSERT INTO schema_migrations (version, checksum) VALUES ($1, $2)
+			ON CONFLICT DO NOTHING`,
+			m.version, m.checksum,
+		); err != nil {
+			return fmt.Errorf("can't record migration %s: %s", m.version, err)
+		}
+	}
+	for _, m := range pending {
+		if err := applyMigration(ctx, conn, m); err != nil {
+			return fmt.Errorf("can't apply migration %s: %s", m.version, err)
+		}
+		log.Printf("postgres migrations: applied %s", m.version)
+	}
+	return nil
+}
+
+// pendingMigrations returns migrations newer than the schema and changed ones, scripts of releases are idempotent,
+// so a changed script runs again. Not recorded migrations of the schema version and older ones are the baseline,
+// the schema got them from the scripts of the repo, they are recorded without running.
+func pendingMigrations(ctx context.Context, conn *pgx.Conn, migrations []migration) ([]migration, []migration, error) {
+	current, err := schemaVersion(ctx, conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	if compareVersions(current, baseVersion) < 0 {
+		return nil, nil, fmt.Errorf("schema %s is older than %s, upgrade it with the scripts of the repo first",
+			current, baseVersion)
+	}
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	var pending, baseline []migration
+	for _, m := range migrations {
+		a, ok := applied[m.version]
+		switch {
+		case ok && a.dirty:
+			return nil, nil, fmt.Errorf("migration %s failed before, fix the schema and delete its row "+
+				"of schema_migrations", m.version)
+		case ok && a.checksum == m.checksum:
+		case ok:
+			log.Printf("postgres migrations: %s has changed", m.version)
+			pending = append(pending, m)
+		case compareVersions(m.version, current) <= 0:
+			baseline = append(baseline, m)
+		default:
+			pending = append(pending, m)
+		}
+	}
+	return pending, baseline, nil
+}
+
+// applyMigration marks the migration dirty until all its statements succeed
+func applyMigration(ctx context.Context, conn *pgx.Conn, m migration) error {
+	if _, err := conn.Exec(ctx, `
+		INSERT INTO schema_migrations (version, checksum, dirty) VALUES ($1, $2, true)
+		ON CONFLICT (version) DO UPDATE SET checksum = EXCLUDED.checksum, dirty = true`,
+		m.version, m.checksum,
+	); err != nil {
+		return err
+	}
+	for _, statement := range splitStatements(m.sql) {
+		if onlyComments(statement) {
+			continue
+		}
+		if _, err := conn.Exec(ctx, statement); err != nil {
+			conn.Exec(ctx, "ROLLBACK")
+			return err
+		}
+	}
+	_, err := conn.Exec(ctx, `
+		UPDATE schema_migrations SET dirty = false, applied_at = (now() at time zone 'utc')
+		WHERE version = $1`,
+		m.version,
+	)
+	return err
+}
+
+// schemaVersion returns the version of openreplay_version() without the edition, e.g. 1.9.0
+func schemaVersion(ctx context.Context, conn *pgx.Conn) (string, error) {
+	var version string
+	if err := conn.QueryRow(ctx, "SELECT openreplay_version()").Scan(&version); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UndefinedFunction {
+			return "", errors.New("schema isn't initialized, apply init_schema.sql of the repo first")
+		}
+		return "", err
+	}
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.Index(version, "-"); i >= 0 {
+		version = version[:i]
+	}
+	return version, nil
+}
+
+type appliedMigration struct {
+	checksum string
+	dirty    bool
+}
+
+// appliedMigrations returns no migrations if the table doesn't exist yet (verify mode on a new schema)
+func appliedMigrations(ctx context.Context, conn *pgx.Conn) (map[string]appliedMigration, error) {
+	applied := make(map[string]appliedMigration)
+	rows, err := conn.Query(ctx, "SELECT version, checksum, dirty FROM schema_migrations")
+	if err != nil {
+		if isUndefinedTable(err) {
+			return applied, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version string
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.checksum, &a.dirty); err != nil {
+			return nil, err
+		}
+		applied[version] = a
+	}
+	if err := rows.Err(); err != nil && !isUndefinedTable(err) {
+		return nil, err
+	}
+	return applied, nil
+}
+
+func isUndefinedTable(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UndefinedTable
+}
+
+// loadMigrations returns the embedded migrations ordered by their versions
+func loadMigrations() ([]migration, error) {
+	names, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+	migrations := make([]migration, 0, len(names))
+	for _, name := range names {
+		data, err := migrationFiles.ReadFile(path.Join("migrations", name.Name()))
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		migrations = append(migrations, migration{
+			version:  strings.TrimSuffix(name.Name(), ".sql"),
+			sql:      string(data),
+			checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return compareVersions(migrations[i].version, migrations[j].version) < 0
+	})
+	return migrations, nil
+}
+
+func versions(migrations []migration) []string {
+	list := make([]string, 0, len(migrations))
+	for _, m := range migrations {
+		list = append(list, m.version)
+	}
+	return list
+}
+
+// compareVersions compares dotted versions by their numbers, missing numbers are 0
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// splitStatements splits the script by semicolons out of quotes, dollar quoted bodies of functions and comments
+func splitStatements(script string) []string {
+	var statements []string
+	start := 0
+	for i := 0; i < len(script); i++ {
+		switch {
+		case script[i] == '\'' || script[i] == '"':
+			if end := strings.IndexByte(script[i+1:], script[i]); end >= 0 {
+				i += end + 1 // doubled quotes are two quoted parts in a row
+			} else {
+				i = len(script)
+			}
+		case strings.HasPrefix(script[i:], "--"):
+			if end := strings.IndexByte(script[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(script)
+			}
+		case strings.HasPrefix(script[i:], "/*"):
+			if end := strings.Index(script[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(script)
+			}
+		case script[i] == '$':
+			tag := dollarTag(script[i:])
+			if tag == "" {
+				continue
+			}
+			if end := strings.Index(script[i+len(tag):], tag); end >= 0 {
+				i += len(tag) + end + len(tag) - 1
+			} else {
+				i = len(script)
+			}
+		case script[i] == ';':
+			if statement := strings.TrimSpace(script[start:i]); statement != "" {
+				statements = append(statements, statement)
+			}
+			start = i + 1
+		}
+	}
+	if statement := strings.TrimSpace(script[start:]); statement != "" {
+		statements = append(statements, statement)
+	}
+	return statements
+}
+
+// dollarTag returns the opening tag ($$ or $name$) at the start of the text, empty for parameters like $1
+func dollarTag(text string) string {
+	for i := 1; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '$':
+			return text[:i+1]
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 1 && c >= '0' && c <= '9':
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+func onlyComments(statement string) bool {
+	for _, line := range strings.Split(statement, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
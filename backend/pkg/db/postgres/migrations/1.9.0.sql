@@ -0,0 +1,71 @@
+BEGIN;
+CREATE OR REPLACE FUNCTION openreplay_version()
+    RETURNS text AS
+$$
+SELECT 'v1.9.0'
+$$ LANGUAGE sql IMMUTABLE;
+
+ALTER TABLE IF EXISTS sessions
+    ADD COLUMN IF NOT EXISTS frustration_score smallint NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS engagement_score  smallint NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS user_attributes
+(
+    project_id integer                     NOT NULL REFERENCES projects (project_id) ON DELETE CASCADE,
+    user_id    text                        NOT NULL,
+    attributes jsonb                       NOT NULL DEFAULT '{}'::jsonb,
+    updated_at timestamp without time zone NOT NULL DEFAULT (now() at time zone 'utc'),
+    PRIMARY KEY (project_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS tracker_configs
+(
+    project_id integer                     NOT NULL PRIMARY KEY REFERENCES projects (project_id) ON DELETE CASCADE,
+    config     jsonb                       NOT NULL DEFAULT '{}'::jsonb,
+    updated_at timestamp without time zone NOT NULL DEFAULT (now() at time zone 'utc')
+);
+
+CREATE TABLE IF NOT EXISTS server_keys
+(
+    key_id      integer generated BY DEFAULT AS IDENTITY PRIMARY KEY,
+    key_hash    text                        NOT NULL UNIQUE,
+    name        text                        NOT NULL,
+    project_ids integer[]                   NOT NULL,
+    created_at  timestamp without time zone NOT NULL DEFAULT (now() at time zone 'utc'),
+    revoked_at  timestamp without time zone NULL     DEFAULT NULL
+);
+
+ALTER TABLE IF EXISTS jobs
+    ADD COLUMN IF NOT EXISTS certificate jsonb NULL;
+
+ALTER TABLE IF EXISTS projects
+    ADD COLUMN IF NOT EXISTS retention_days integer NULL DEFAULT NULL CHECK (retention_days > 0);
+
+CREATE TABLE IF NOT EXISTS erasure_requests
+(
+    erasure_id   integer generated BY DEFAULT AS IDENTITY PRIMARY KEY,
+    project_id   integer                     NOT NULL REFERENCES projects (project_id) ON DELETE CASCADE,
+    user_id      text                        NULL,
+    anonymous_id text                        NULL,
+    requested_by integer                     NOT NULL,
+    status       text                        NOT NULL DEFAULT 'pending',
+    report       jsonb                       NULL,
+    created_at   timestamp without time zone NOT NULL DEFAULT (now() at time zone 'utc'),
+    finished_at  timestamp without time zone NULL,
+    CHECK (user_id IS NOT NULL OR anonymous_id IS NOT NULL)
+);
+CREATE INDEX IF NOT EXISTS erasure_requests_status_idx ON erasure_requests (status) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS tenant_routes
+(
+    project_id     integer                     NOT NULL PRIMARY KEY REFERENCES projects (project_id) ON DELETE CASCADE,
+    tenant         text                        NOT NULL,
+    retention_days integer                     NULL     DEFAULT NULL CHECK (retention_days > 0),
+    created_at     timestamp without time zone NOT NULL DEFAULT (now() at time zone 'utc')
+);
+CREATE INDEX IF NOT EXISTS tenant_routes_tenant_idx ON tenant_routes (tenant);
+
+COMMIT;
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS sessions_project_id_frustration_score_idx ON sessions (project_id, frustration_score DESC);
+CREATE INDEX CONCURRENTLY IF NOT EXISTS sessions_project_id_engagement_score_idx ON sessions (project_id, engagement_score DESC);
@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// GetPartitionKey returns the column and its type if the table is partitioned by the range of one column,
+// an empty column if the table isn't partitioned this way
+func (conn *Conn) GetPartitionKey(table string) (string, string, error) {
+	if IsCockroach() {
+		// CockroachDB has no declarative partitioning by range of time, all tables are handled as not partitioned
+		return "", "", nil
+	}
+	rows, err := conn.c.Query(`
+		SELECT a.attname, format_type(a.atttypid, a.atttypmod)
+		FROM pg_partitioned_table p
+			INNER JOIN pg_attribute a ON (a.attrelid = p.partrelid AND a.attnum = p.partattrs[0])
+		WHERE p.partrelid = to_regclass($1) AND p.partstrat = 'r' AND p.partnatts = 1`,
+		table,
+	)
+	if err != nil {
+		return "", "", err
+	}
+	defer rows.Close()
+
+	var column, typ string
+	if rows.Next() {
+		if err := rows.Scan(&column, &typ); err != nil {
+			return "", "", err
+		}
+	}
+	return column, typ, rows.Err()
+}
+
+// GetPartitions returns names (without the schema) of all partitions of the table including the default one
+func (conn *Conn) GetPartitions(table string) ([]string, error) {
+	rows, err := conn.c.Query(`
+		SELECT c.relname
+		FROM pg_inherits i
+			INNER JOIN pg_class c ON (c.oid = i.inhrelid)
+		WHERE i.inhparent = to_regclass($1)`,
+		table,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// CreatePartition adds the partition of the table in its schema, bounds are SQL literals of the key type.
+// It fails if the default partition already has rows of the range.
+func (conn *Conn) CreatePartition(table, name, from, to string) error {
+	return conn.c.Exec(fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s PARTITION OF %s
+		FOR VALUES FROM (%s) TO (%s)`,
+		sanitize(partitionOf(table, name)), sanitize(table), from, to,
+	))
+}
+
+// DropPartition removes the partition of the table with all its rows
+func (conn *Conn) DropPartition(table, name string) error {
+	return conn.c.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", sanitize(partitionOf(table, name))))
+}
+
+// partitionOf returns the full name of the partition in the schema of the table
+func partitionOf(table, name string) string {
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		return table[:i+1] + name
+	}
+	return name
+}
+
+func sanitize(name string) string {
+	return pgx.Identifier(strings.Split(name, ".")).Sanitize()
+}
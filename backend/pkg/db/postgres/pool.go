@@ -5,93 +5,160 @@ import (
 	"errors"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
-	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
 	"strings"
 	"time"
 )
 
-// Pool is a pgx.Pool wrapper with metrics integration
+// Pool is a pgx.Pool wrapper with metrics integration. Statements which fail because of lost connections
+// are retried, batches are sent again as a whole.
 type Pool interface {
 	Query(sql string, args ...interface{}) (pgx.Rows, error)
 	QueryRow(sql string, args ...interface{}) pgx.Row
 	Exec(sql string, arguments ...interface{}) error
-	SendBatch(b *pgx.Batch) pgx.BatchResults
-	Begin() (*_Tx, error)
+	ExecIdempotent(sql string, arguments ...interface{}) error
+	ExecBatch(b *pgx.Batch) []error
+	InTx(fn func(tx *_Tx) error) error
+	Ping() error
+	Unavailable() time.Duration
 	Close()
 }
 
 type poolImpl struct {
-	conn              *pgxpool.Pool
-	sqlRequestTime    syncfloat64.Histogram
-	sqlRequestCounter syncfloat64.Counter
+	conn   *pgxpool.Pool
+	health *healthChecker
+	sqlMetrics
 }
 
 func (p *poolImpl) Query(sql string, args ...interface{}) (pgx.Rows, error) {
 	start := time.Now()
-	res, err := p.conn.Query(getTimeoutContext(), sql, args...)
-	method, table := methodName(sql)
-	p.sqlRequestTime.Record(context.Background(), float64(time.Now().Sub(start).Milliseconds()),
-		attribute.String("method", method), attribute.String("table", table))
-	p.sqlRequestCounter.Add(context.Background(), 1,
-		attribute.String("method", method), attribute.String("table", table))
+	var res pgx.Rows
+	err := p.health.retry(func() (err error) {
+		res, err = p.conn.Query(getTimeoutContext(), sql, args...)
+		return err
+	})
+	p.recordSQL(sql, start, err)
 	return res, err
 }
 
+// QueryRow runs the query on Scan, errors of pgx rows are known only there
 func (p *poolImpl) QueryRow(sql string, args ...interface{}) pgx.Row {
+	return &retryRow{pool: p, sql: sql, args: args}
+}
+
+type retryRow struct {
+	pool *poolImpl
+	sql  string
+	args []interface{}
+}
+
+func (r *retryRow) Scan(dest ...interface{}) error {
+	p := r.pool
 	start := time.Now()
-	res := p.conn.QueryRow(getTimeoutContext(), sql, args...)
-	method, table := methodName(sql)
-	p.sqlRequestTime.Record(context.Background(), float64(time.Now().Sub(start).Milliseconds()),
-		attribute.String("method", method), attribute.String("table", table))
-	p.sqlRequestCounter.Add(context.Background(), 1,
-		attribute.String("method", method), attribute.String("table", table))
-	return res
+	err := p.health.retry(func() error {
+		return p.conn.QueryRow(getTimeoutContext(), r.sql, r.args...).Scan(dest...)
+	})
+	p.recordSQL(r.sql, start, err)
+	return err
 }
 
 func (p *poolImpl) Exec(sql string, arguments ...interface{}) error {
 	start := time.Now()
-	_, err := p.conn.Exec(getTimeoutContext(), sql, arguments...)
-	method, table := methodName(sql)
-	p.sqlRequestTime.Record(context.Background(), float64(time.Now().Sub(start).Milliseconds()),
-		attribute.String("method", method), attribute.String("table", table))
-	p.sqlRequestCounter.Add(context.Background(), 1,
-		attribute.String("method", method), attribute.String("table", table))
+	err := p.health.retry(func() error {
+		_, err := p.conn.Exec(getTimeoutContext(), sql, arguments...)
+		return err
+	})
+	p.recordSQL(sql, start, err)
+	return err
+}
+
+// ExecIdempotent is Exec of statements which can run twice without changes, e.g. inserts with ON CONFLICT DO NOTHING
+// on natural keys. They are retried also after timeouts, when the first run could have been committed.
+func (p *poolImpl) ExecIdempotent(sql string, arguments ...interface{}) error {
+	start := time.Now()
+	err := p.health.retryWrite(func() error {
+		_, err := p.conn.Exec(getTimeoutContext(), sql, arguments...)
+		return err
+	})
+	p.recordSQL(sql, start, err)
 	return err
 }
 
-func (p *poolImpl) SendBatch(b *pgx.Batch) pgx.BatchResults {
+// ExecBatch returns errors of the batch statements by their order. Statements of the batch run in one implicit
+// transaction, so the whole batch is sent again if any of them failed with a retryable error.
+func (p *poolImpl) ExecBatch(b *pgx.Batch) []error {
 	start := time.Now()
-	res := p.conn.SendBatch(getTimeoutContext(), b)
-	p.sqlRequestTime.Record(context.Background(), float64(time.Now().Sub(start).Milliseconds()),
-		attribute.String("method", "sendBatch"))
-	p.sqlRequestCounter.Add(context.Background(), 1,
-		attribute.String("method", "sendBatch"))
-	return res
+	errs := make([]error, b.Len())
+	// Statements failed with permanent errors are counted by the caller, it knows their tables
+	err := p.health.retry(func() error {
+		var retryErr error
+		br := p.conn.SendBatch(getTimeoutContext(), b)
+		for i := range errs {
+			_, errs[i] = br.Exec()
+			if retryErr == nil && isRetryable(errs[i]) {
+				retryErr = errs[i]
+			}
+		}
+		if err := br.Close(); retryErr == nil && isRetryable(err) {
+			retryErr = err
+		}
+		return retryErr
+	})
+	p.record("sendBatch", "", start, err)
+	return errs
 }
 
-func (p *poolImpl) Begin() (*_Tx, error) {
+// InTx runs fn in a transaction and commits it. The whole transaction runs again if any of its statements
+// or the commit failed with a retryable error, e.g. serialization failures which CockroachDB returns
+// under contention instead of waiting on locks, so fn must not have side effects out of the transaction.
+func (p *poolImpl) InTx(fn func(tx *_Tx) error) error {
+	return p.health.retry(func() error {
+		tx, err := p.begin()
+		if err != nil {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			tx.rollback()
+			return err
+		}
+		return tx.commit()
+	})
+}
+
+func (p *poolImpl) begin() (*_Tx, error) {
 	start := time.Now()
 	tx, err := p.conn.Begin(context.Background())
-	p.sqlRequestTime.Record(context.Background(), float64(time.Now().Sub(start).Milliseconds()),
-		attribute.String("method", "begin"))
-	p.sqlRequestCounter.Add(context.Background(), 1,
-		attribute.String("method", "begin"))
-	return &_Tx{tx, p.sqlRequestTime, p.sqlRequestCounter}, err
+	p.record("begin", "", start, err)
+	return &_Tx{tx, p.sqlMetrics}, err
+}
+
+// Ping checks the server right away, health checks of the pool run periodically
+func (p *poolImpl) Ping() error {
+	return p.health.ping()
+}
+
+// Unavailable returns how long the server doesn't respond to health checks and statements, 0 if it responds
+func (p *poolImpl) Unavailable() time.Duration {
+	return p.health.unavailable()
 }
 
 func (p *poolImpl) Close() {
 	p.conn.Close()
 }
 
-func NewPool(conn *pgxpool.Pool, sqlRequestTime syncfloat64.Histogram, sqlRequestCounter syncfloat64.Counter) (Pool, error) {
-	if conn == nil {
+// NewPool pings idle connections every healthCheckPeriod
+func NewPool(conn *pgxpool.Pool, healthCheckPeriod time.Duration, sqlRequestTime syncfloat64.Histogram,
+	sqlRequestCounter, sqlRequestErrors syncfloat64.Counter) (Pool, error) {
+	switch {
+	case conn == nil:
 		return nil, errors.New("conn is empty")
+	case healthCheckPeriod <= 0:
+		return nil, errors.New("health check period must be positive")
 	}
 	return &poolImpl{
-		conn:              conn,
-		sqlRequestTime:    sqlRequestTime,
-		sqlRequestCounter: sqlRequestCounter,
+		conn:       conn,
+		health:     newHealthChecker(conn, healthCheckPeriod),
+		sqlMetrics: sqlMetrics{sqlRequestTime, sqlRequestCounter, sqlRequestErrors},
 	}, nil
 }
 
@@ -99,38 +166,34 @@ func NewPool(conn *pgxpool.Pool, sqlRequestTime syncfloat64.Histogram, sqlReques
 
 type _Tx struct {
 	pgx.Tx
-	sqlRequestTime    syncfloat64.Histogram
-	sqlRequestCounter syncfloat64.Counter
+	sqlMetrics
 }
 
 func (tx *_Tx) exec(sql string, args ...interface{}) error {
 	start := time.Now()
 	_, err := tx.Exec(context.Background(), sql, args...)
-	method, table := methodName(sql)
-	tx.sqlRequestTime.Record(context.Background(), float64(time.Now().Sub(start).Milliseconds()),
-		attribute.String("method", method), attribute.String("table", table))
-	tx.sqlRequestCounter.Add(context.Background(), 1,
-		attribute.String("method", method), attribute.String("table", table))
+	tx.recordSQL(sql, start, err)
+	return err
+}
+
+func (tx *_Tx) copyFrom(table string, columns []string, rows pgx.CopyFromSource) error {
+	start := time.Now()
+	_, err := tx.CopyFrom(context.Background(), pgx.Identifier{table}, columns, rows)
+	tx.record("copy", table, start, err)
 	return err
 }
 
 func (tx *_Tx) rollback() error {
 	start := time.Now()
 	err := tx.Rollback(context.Background())
-	tx.sqlRequestTime.Record(context.Background(), float64(time.Now().Sub(start).Milliseconds()),
-		attribute.String("method", "rollback"))
-	tx.sqlRequestCounter.Add(context.Background(), 1,
-		attribute.String("method", "rollback"))
+	tx.record("rollback", "", start, err)
 	return err
 }
 
 func (tx *_Tx) commit() error {
 	start := time.Now()
 	err := tx.Commit(context.Background())
-	tx.sqlRequestTime.Record(context.Background(), float64(time.Now().Sub(start).Milliseconds()),
-		attribute.String("method", "commit"))
-	tx.sqlRequestCounter.Add(context.Background(), 1,
-		attribute.String("method", "commit"))
+	tx.record("commit", "", start, err)
 	return err
 }
 
@@ -169,7 +232,8 @@ func methodName(sql string) (string, string) {
 	case "update":
 		table = strings.TrimSpace(parts[1])
 	case "insert":
-		table = strings.TrimSpace(parts[2])
+		// Columns of bulk inserts follow the table without the space
+		table = strings.TrimSpace(strings.SplitN(parts[2], "(", 2)[0])
 	}
 	return cmd, table
 }
@@ -6,7 +6,7 @@ import (
 
 func (conn *Conn) GetProjectByKey(projectKey string) (*Project, error) {
 	p := &Project{ProjectKey: projectKey}
-	if err := conn.c.QueryRow(`
+	if err := conn.r.QueryRow(`
 		SELECT max_session_duration, sample_rate, project_id
 		FROM projects
 		WHERE project_key=$1 AND active = true
@@ -21,7 +21,7 @@ func (conn *Conn) GetProjectByKey(projectKey string) (*Project, error) {
 // TODO: logical separation of metadata
 func (conn *Conn) GetProject(projectID uint32) (*Project, error) {
 	p := &Project{ProjectID: projectID}
-	if err := conn.c.QueryRow(`
+	if err := conn.r.QueryRow(`
 		SELECT project_key, max_session_duration, save_request_payloads,
 			metadata_1, metadata_2, metadata_3, metadata_4, metadata_5,
 			metadata_6, metadata_7, metadata_8, metadata_9, metadata_10
@@ -36,3 +36,25 @@ func (conn *Conn) GetProject(projectID uint32) (*Project, error) {
 	}
 	return p, nil
 }
+
+func (conn *Conn) GetActiveProjectIDs() ([]uint32, error) {
+	rows, err := conn.r.Query(`
+		SELECT project_id
+		FROM projects
+		WHERE active = true AND deleted_at IS NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uint32
+	for rows.Next() {
+		var id uint32
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
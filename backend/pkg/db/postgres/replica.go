@@ -0,0 +1,166 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+
+	"openreplay/backend/pkg/env"
+)
+
+// POSTGRES_REPLICA_STRING sets the read replica for lookups which tolerate the replication lag (projects by keys,
+// users and their attributes), the replica is used while its lag is below POSTGRES_REPLICA_MAX_LAG
+const defaultReplicaMaxLag = 10 * time.Second
+
+// The lag is 0 if the replica has replayed all received changes, so the lag of an idle primary isn't counted
+const replicaLagQuery = `
+	SELECT CASE
+		WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+	END`
+
+// Reader runs read-only statements
+type Reader interface {
+	Query(sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(sql string, args ...interface{}) pgx.Row
+}
+
+// readPool sends reads to the replica while it's usable, reads which fail on the replica because of the connection
+// go to the primary, which also retries them
+type readPool struct {
+	primary Pool
+	cfg     *pgxpool.Config
+	mu      sync.Mutex
+	replica *pgxpool.Pool // connected by checks, the service starts without the replica if it's down
+	maxLag  time.Duration
+	usable  int32 // 1 if the last check found the replica with the lag below the limit, changes are logged
+	sqlMetrics
+}
+
+func newReadPool(url string, primary Pool, metrics sqlMetrics) (*readPool, error) {
+	cfg, err := poolConfig(url)
+	if err != nil {
+		return nil, err
+	}
+	r := &readPool{
+		primary:    primary,
+		cfg:        cfg,
+		maxLag:     env.DurationOptional("POSTGRES_REPLICA_MAX_LAG"),
+		usable:     1, // the first check logs the state if the replica isn't usable
+		sqlMetrics: metrics,
+	}
+	if r.maxLag <= 0 {
+		r.maxLag = defaultReplicaMaxLag
+	}
+	r.check()
+	go func() {
+		for range time.Tick(cfg.HealthCheckPeriod) {
+			r.check()
+		}
+	}()
+	return r, nil
+}
+
+// check measures the lag of the replica, the replica isn't used if it doesn't respond
+func (r *readPool) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	if r.replica == nil {
+		replica, err := pgxpool.ConnectConfig(ctx, r.cfg)
+		if err != nil {
+			r.setUsable(false, "postgres replica: can't connect: %s", err)
+			return
+		}
+		r.mu.Lock()
+		r.replica = replica
+		r.mu.Unlock()
+	}
+	var lag float64
+	if err := r.replica.QueryRow(ctx, replicaLagQuery).Scan(&lag); err != nil {
+		r.setUsable(false, "postgres replica: unavailable: %s", err)
+		return
+	}
+	if d := time.Duration(lag * float64(time.Second)); d > r.maxLag {
+		r.setUsable(false, "postgres replica: lag %s is above the limit", d)
+		return
+	}
+	r.setUsable(true, "postgres replica: available again")
+}
+
+func (r *readPool) setUsable(usable bool, format string, args ...interface{}) {
+	state := int32(0)
+	if usable {
+		state = 1
+	}
+	if atomic.SwapInt32(&r.usable, state) != state {
+		log.Printf(format, args...)
+	}
+}
+
+func (r *readPool) isUsable() bool {
+	return atomic.LoadInt32(&r.usable) == 1
+}
+
+// fallback reports errors of the replica connection, the same statement can succeed on the primary
+func (r *readPool) fallback(err error) bool {
+	if err == nil || errors.Is(err, pgx.ErrNoRows) || !isRetryableWrite(err) {
+		return false
+	}
+	r.setUsable(false, "postgres replica: reads go to the primary: %s", err)
+	return true
+}
+
+func (r *readPool) Query(sql string, args ...interface{}) (pgx.Rows, error) {
+	if r.isUsable() {
+		start := time.Now()
+		rows, err := r.replica.Query(getTimeoutContext(), sql, args...)
+		r.recordReplica(sql, start, err)
+		if !r.fallback(err) {
+			return rows, err
+		}
+	}
+	return r.primary.Query(sql, args...)
+}
+
+func (r *readPool) QueryRow(sql string, args ...interface{}) pgx.Row {
+	return &fallbackRow{pool: r, sql: sql, args: args}
+}
+
+func (r *readPool) recordReplica(sql string, start time.Time, err error) {
+	method, table := methodName(sql)
+	r.record(method, table, start, err, attribute.Bool("replica", true))
+}
+
+func (r *readPool) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.replica != nil {
+		r.replica.Close()
+	}
+}
+
+type fallbackRow struct {
+	pool *readPool
+	sql  string
+	args []interface{}
+}
+
+func (f *fallbackRow) Scan(dest ...interface{}) error {
+	r := f.pool
+	if r.isUsable() {
+		start := time.Now()
+		err := r.replica.QueryRow(getTimeoutContext(), f.sql, f.args...).Scan(dest...)
+		r.recordReplica(f.sql, start, err)
+		if !r.fallback(err) {
+			return err
+		}
+	}
+	return r.primary.QueryRow(f.sql, f.args...).Scan(dest...)
+}
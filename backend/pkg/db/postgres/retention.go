@@ -0,0 +1,90 @@
+package postgres
+
+// GetProjectRetentions returns retention days of all not deleted projects, projects without their own retention
+// get the retention of their tenant, 0 if the project uses the default one
+func (conn *Conn) GetProjectRetentions() (map[uint32]int, error) {
+	rows, err := conn.c.Query(`
+		SELECT p.project_id, COALESCE(p.retention_days, t.retention_days, 0)
+		FROM projects AS p
+		LEFT JOIN tenant_routes AS t USING (project_id)
+		WHERE p.deleted_at IS NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	retentions := make(map[uint32]int)
+	for rows.Next() {
+		var (
+			id   uint32
+			days int
+		)
+		if err := rows.Scan(&id, &days); err != nil {
+			return nil, err
+		}
+		retentions[id] = days
+	}
+	return retentions, rows.Err()
+}
+
+// GetSessionIDsBefore returns the oldest sessions of the project started before the timestamp (ms)
+func (conn *Conn) GetSessionIDsBefore(projectID uint32, before int64, limit int) ([]uint64, error) {
+	rows, err := conn.c.Query(`
+		SELECT session_id
+		FROM sessions
+		WHERE project_id = $1 AND start_ts < $2
+		ORDER BY start_ts
+		LIMIT $3
+	`,
+		projectID, before, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uint64
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// CountSessionsBefore returns the number of sessions of the project started before the timestamp (ms)
+func (conn *Conn) CountSessionsBefore(projectID uint32, before int64) (uint64, error) {
+	var count uint64
+	err := conn.c.QueryRow(`
+		SELECT count(*)
+		FROM sessions
+		WHERE project_id = $1 AND start_ts < $2
+	`,
+		projectID, before,
+	).Scan(&count)
+	return count, err
+}
+
+// DeleteSessions removes sessions with all their events and issue occurrences, they are deleted by cascade
+func (conn *Conn) DeleteSessions(sessionIDs []uint64) error {
+	return conn.c.Exec(`
+		DELETE FROM sessions
+		WHERE session_id = ANY($1)
+	`,
+		sessionIDs,
+	)
+}
+
+// DeleteUnusedIssues removes issues of the project which have no occurrences in the left sessions
+func (conn *Conn) DeleteUnusedIssues(projectID uint32) error {
+	return conn.c.Exec(`
+		DELETE FROM issues
+		WHERE project_id = $1
+		  AND NOT EXISTS(SELECT 1 FROM events_common.issues AS ei WHERE ei.issue_id = issues.issue_id)
+	`,
+		projectID,
+	)
+}
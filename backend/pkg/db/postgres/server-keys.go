@@ -0,0 +1,27 @@
+package postgres
+
+import (
+	"github.com/jackc/pgx/v4"
+)
+
+// GetServerKeyProjects returns projects the server-side key can send events to, nil if the key doesn't exist or is revoked
+func (conn *Conn) GetServerKeyProjects(keyHash string) ([]uint32, error) {
+	var ids []int32
+	err := conn.r.QueryRow(`
+		SELECT project_ids
+		FROM server_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL`,
+		keyHash,
+	).Scan(&ids)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	projects := make([]uint32, 0, len(ids))
+	for _, id := range ids {
+		projects = append(projects, uint32(id))
+	}
+	return projects, nil
+}
@@ -0,0 +1,32 @@
+package postgres
+
+// Scores are in 0..100 range, every signal increases the score with diminishing returns:
+// score = 100 * (1 - e^(-raw/scale)), so a few rage clicks matter more than the hundredth one.
+// Counters of the session are updated in the same batch, so the request is queued after them.
+const sessionScoresReq = `
+	UPDATE sessions
+	SET frustration_score = ROUND(100 * (1 - EXP(-(
+			3 * issues.rage_clicks + 2 * issues.dead_clicks + 3 * issues.crashes +
+			issues.bad_requests + issues.others + 2 * sessions.errors_count
+		)::numeric / 10))),
+		engagement_score = ROUND(100 * (1 - EXP(-(
+			sessions.events_count + 5 * sessions.pages_count + COALESCE(sessions.duration, 0) / 30000.0
+		)::numeric / 50)))
+	FROM (
+		SELECT COUNT(*) FILTER (WHERE ps.type IN ('click_rage', 'ml_click_rage'))      AS rage_clicks,
+			   COUNT(*) FILTER (WHERE ps.type IN ('dead_click', 'ml_dead_click'))      AS dead_clicks,
+			   COUNT(*) FILTER (WHERE ps.type = 'crash')                               AS crashes,
+			   COUNT(*) FILTER (WHERE ps.type IN ('bad_request', 'missing_resource'))  AS bad_requests,
+			   COUNT(*) FILTER (WHERE ps.type NOT IN ('click_rage', 'ml_click_rage', 'dead_click', 'ml_dead_click',
+													  'crash', 'bad_request', 'missing_resource', 'js_exception')) AS others
+		FROM events_common.issues
+			INNER JOIN issues AS ps USING (issue_id)
+		WHERE session_id = $1
+	) AS issues
+	WHERE sessions.session_id = $1`
+
+// UpdateSessionScores computes frustration and engagement scores of the finished session
+func (conn *Conn) UpdateSessionScores(sessionID uint64) {
+	conn.batchQueue(sessionID, sessionScoresReq, sessionID)
+	conn.updateBatchSize(sessionID, len(sessionScoresReq)+8)
+}
@@ -51,3 +51,93 @@ func (conn *Conn) GetSession(sessionID uint64) (*Session, error) {
 	}
 	return s, nil
 }
+
+// GetSessionPartitionKeys returns the project and the user id of the session for the queue partitioning
+func (conn *Conn) GetSessionPartitionKeys(sessionID uint64) (uint32, string, error) {
+	var projectID uint32
+	var userID *string
+	if err := conn.c.QueryRow(`
+		SELECT project_id, user_id FROM sessions WHERE session_id = $1`,
+		sessionID,
+	).Scan(&projectID, &userID); err != nil {
+		return 0, "", err
+	}
+	if userID == nil {
+		return projectID, "", nil
+	}
+	return projectID, *userID, nil
+}
+
+// GetUserSessionAt returns the latest session of the user which was active at the given time
+func (conn *Conn) GetUserSessionAt(projectID uint32, userID, anonymousID string, ts int64) (uint64, error) {
+	var sessionID uint64
+	err := conn.c.QueryRow(`
+		SELECT session_id
+		FROM sessions
+		WHERE project_id = $1
+		  AND (($2 != '' AND user_id = $2) OR ($3 != '' AND user_anonymous_id = $3))
+		  AND start_ts <= $4 AND start_ts >= $4 - 86400000
+		  AND (duration IS NULL OR start_ts + duration >= $4 - 300000)
+		ORDER BY start_ts DESC
+		LIMIT 1`,
+		projectID, userID, anonymousID, ts,
+	).Scan(&sessionID)
+	return sessionID, err
+}
+
+// GetRecentSessionIDs returns the latest finished sessions of the project
+func (conn *Conn) GetRecentSessionIDs(projectID uint32, limit int) ([]uint64, error) {
+	rows, err := conn.c.Query(`
+		SELECT session_id
+		FROM sessions
+		WHERE project_id = $1 AND duration IS NOT NULL
+		ORDER BY start_ts DESC
+		LIMIT $2`,
+		projectID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uint64
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SessionSummary is a short description of the session used for consistency checks
+type SessionSummary struct {
+	Finished    bool
+	EventsCount int
+}
+
+// GetWebSessionSummaries returns web sessions started in [from, to) time range (ms)
+func (conn *Conn) GetWebSessionSummaries(from, to int64) (map[uint64]*SessionSummary, error) {
+	rows, err := conn.c.Query(`
+		SELECT session_id, duration IS NOT NULL, events_count
+		FROM sessions
+		WHERE start_ts >= $1 AND start_ts < $2 AND platform = 'web'`,
+		from, to,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := make(map[uint64]*SessionSummary)
+	for rows.Next() {
+		var id uint64
+		summary := &SessionSummary{}
+		if err := rows.Scan(&id, &summary.Finished, &summary.EventsCount); err != nil {
+			return nil, err
+		}
+		summaries[id] = summary
+	}
+	return summaries, rows.Err()
+}
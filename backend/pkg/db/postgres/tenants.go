@@ -0,0 +1,26 @@
+package postgres
+
+// GetTenantRoutes returns tenants of routed projects, projects without routes aren't returned
+func (conn *Conn) GetTenantRoutes() (map[uint32]string, error) {
+	rows, err := conn.r.Query(`
+		SELECT project_id, tenant
+		FROM tenant_routes
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	routes := make(map[uint32]string)
+	for rows.Next() {
+		var (
+			id     uint32
+			tenant string
+		)
+		if err := rows.Scan(&id, &tenant); err != nil {
+			return nil, err
+		}
+		routes[id] = tenant
+	}
+	return routes, rows.Err()
+}
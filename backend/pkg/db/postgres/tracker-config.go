@@ -0,0 +1,23 @@
+package postgres
+
+import (
+	"github.com/jackc/pgx/v4"
+)
+
+// GetTrackerConfig returns the project overrides of the tracker remote configuration, nil if there are no overrides
+func (conn *Conn) GetTrackerConfig(projectID uint32) ([]byte, error) {
+	var data []byte
+	err := conn.r.QueryRow(`
+		SELECT config
+		FROM tracker_configs
+		WHERE project_id = $1`,
+		projectID,
+	).Scan(&data)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// UpsertUserAttributes saves imported attributes of the project users (account tier, region etc.),
+// new values are merged with the existing ones, so the import may contain only changed columns
+func (conn *Conn) UpsertUserAttributes(projectID uint32, users map[string]map[string]string) error {
+	b := &pgx.Batch{}
+	for userID, attributes := range users {
+		data, err := json.Marshal(attributes)
+		if err != nil {
+			return err
+		}
+		b.Queue(`
+			INSERT INTO user_attributes (project_id, user_id, attributes)
+			VALUES ($1, $2, $3::jsonb)
+			ON CONFLICT (project_id, user_id) DO UPDATE
+			SET attributes = user_attributes.attributes || EXCLUDED.attributes,
+				updated_at = (now() at time zone 'utc')`,
+			projectID, userID, string(data),
+		)
+	}
+	for _, err := range conn.c.ExecBatch(b) {
+		if err != nil {
+			return fmt.Errorf("can't upsert user attributes: %s", err)
+		}
+	}
+	return nil
+}
+
+// GetUserAttributes returns imported attributes of the user, nil if there are no attributes
+func (conn *Conn) GetUserAttributes(projectID uint32, userID string) (map[string]string, error) {
+	var data []byte
+	err := conn.r.QueryRow(`
+		SELECT attributes
+		FROM user_attributes
+		WHERE project_id = $1 AND user_id = $2`,
+		projectID, userID,
+	).Scan(&data)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	attributes := make(map[string]string)
+	if err := json.Unmarshal(data, &attributes); err != nil {
+		return nil, err
+	}
+	return attributes, nil
+}
@@ -57,3 +57,16 @@ func (p *Project) GetMetadataNo(key string) uint {
 	}
 	return 0
 }
+
+// GetMetadataKey returns the name of the metadata field, empty string if the field isn't used
+func (p *Project) GetMetadataKey(no uint) string {
+	if p == nil {
+		return ""
+	}
+	keys := []*string{p.Metadata1, p.Metadata2, p.Metadata3, p.Metadata4, p.Metadata5,
+		p.Metadata6, p.Metadata7, p.Metadata8, p.Metadata9, p.Metadata10}
+	if no < 1 || no > uint(len(keys)) || keys[no-1] == nil {
+		return ""
+	}
+	return *keys[no-1]
+}
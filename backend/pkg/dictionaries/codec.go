@@ -0,0 +1,114 @@
+package dictionaries
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	gzip "github.com/klauspost/pgzip"
+)
+
+const (
+	dictMagic          = 0xEC30A437
+	maxFrameHeaderSize = 18
+	ContentType        = "application/zstd"
+)
+
+var (
+	zstdMagic = []byte{0x28, 0xB5, 0x2F, 0xFD}
+	gzipMagic = []byte{0x1F, 0x8B}
+)
+
+func validate(dict []byte) error {
+	if len(dict) < 8 {
+		return fmt.Errorf("dictionary is too short: %d", len(dict))
+	}
+	if binary.LittleEndian.Uint32(dict[:4]) != dictMagic {
+		return fmt.Errorf("wrong dictionary magic")
+	}
+	return nil
+}
+
+// ID returns dictionary id written to the header of each compressed frame
+func ID(dict []byte) uint32 {
+	if validate(dict) != nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(dict[4:8])
+}
+
+// Compress returns a reader of zstd frames compressed with the given dictionary
+func Compress(file io.Reader, dict []byte) io.Reader {
+	reader, writer := io.Pipe()
+	go func() {
+		zw, err := zstd.NewWriter(writer, zstd.WithEncoderDict(dict))
+		if err != nil {
+			writer.CloseWithError(fmt.Errorf("can't create zstd writer: %s", err))
+			return
+		}
+		if _, err := io.Copy(zw, file); err != nil {
+			zw.Close()
+			writer.CloseWithError(err)
+			return
+		}
+		writer.CloseWithError(zw.Close())
+	}()
+	return reader
+}
+
+// frameDictID parses zstd frame header, returns 0 if the frame is compressed without dictionary
+func frameDictID(header []byte) uint32 {
+	if len(header) < len(zstdMagic)+1 {
+		return 0
+	}
+	descriptor := header[len(zstdMagic)]
+	offset := len(zstdMagic) + 1
+	if descriptor&(1<<5) == 0 { // window descriptor is present if the frame isn't single segment
+		offset++
+	}
+	size := [4]int{0, 1, 2, 4}[descriptor&3]
+	if len(header) < offset+size {
+		return 0
+	}
+	var id uint32
+	for i := size - 1; i >= 0; i-- {
+		id = id<<8 | uint32(header[offset+i])
+	}
+	return id
+}
+
+// NewReader decompresses both gzip and zstd session files, so files uploaded before
+// dictionary training are still readable. Dictionary is found by the id from the frame header,
+// store can be nil if only files without dictionary are expected.
+func NewReader(file io.Reader, store *Store) (io.ReadCloser, error) {
+	br := bufio.NewReader(file)
+	header, err := br.Peek(maxFrameHeaderSize)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(header, zstdMagic):
+		var dicts [][]byte
+		if dictID := frameDictID(header); dictID != 0 {
+			if store == nil {
+				return nil, fmt.Errorf("file is compressed with dictionary %d, but store is empty", dictID)
+			}
+			dict, err := store.GetByID(dictID)
+			if err != nil {
+				return nil, err
+			}
+			dicts = append(dicts, dict)
+		}
+		decoder, err := zstd.NewReader(br, zstd.WithDecoderDicts(dicts...))
+		if err != nil {
+			return nil, fmt.Errorf("can't create zstd reader: %s", err)
+		}
+		return decoder.IOReadCloser(), nil
+	case bytes.HasPrefix(header, gzipMagic):
+		return gzip.NewReader(br)
+	}
+	return io.NopCloser(br), nil
+}
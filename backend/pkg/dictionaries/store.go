@@ -0,0 +1,169 @@
+package dictionaries
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"openreplay/backend/pkg/storage"
+)
+
+// Key returns object storage path of the current project dictionary, it's used for compression
+func Key(projectID uint32) string {
+	return "dictionaries/" + strconv.FormatUint(uint64(projectID), 10) + ".zdict"
+}
+
+// ArchiveKey returns object storage path of the dictionary by its id, it's used for decompression,
+// because files compressed with previous dictionaries of the project are still in storage
+func ArchiveKey(dictID uint32) string {
+	return "dictionaries/ids/" + strconv.FormatUint(uint64(dictID), 10) + ".zdict"
+}
+
+// NewID returns a random dictionary id from the range allowed for private dictionaries
+func NewID() (uint32, error) {
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return 0, err
+	}
+	return 32768 + binary.LittleEndian.Uint32(buf)%(1<<31-32768), nil
+}
+
+type cachedDict struct {
+	data     []byte // nil if the project doesn't have a dictionary
+	loadedAt time.Time
+}
+
+// loading is a dictionary which is being downloaded, concurrent requests of the same key wait for it
+type loading struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// Store loads trained dictionaries from object storage and keeps them in memory,
+// so a new dictionary is picked up after ttl without service restart
+type Store struct {
+	objStorage storage.ObjectStorage
+	ttl        time.Duration
+	mu         sync.Mutex
+	dicts      map[uint32]*cachedDict // by project id
+	archive    map[uint32][]byte      // by dictionary id
+	loading    map[string]*loading    // by object storage key
+}
+
+func NewStore(objStorage storage.ObjectStorage, ttl time.Duration) (*Store, error) {
+	switch {
+	case objStorage == nil:
+		return nil, fmt.Errorf("object storage is empty")
+	case ttl <= 0:
+		return nil, fmt.Errorf("wrong dictionary ttl: %s", ttl)
+	}
+	return &Store{
+		objStorage: objStorage,
+		ttl:        ttl,
+		dicts:      make(map[uint32]*cachedDict),
+		archive:    make(map[uint32][]byte),
+		loading:    make(map[string]*loading),
+	}, nil
+}
+
+// Get returns nil if there is no dictionary for the project
+func (s *Store) Get(projectID uint32) []byte {
+	s.mu.Lock()
+	d, ok := s.dicts[projectID]
+	s.mu.Unlock()
+	if ok && time.Since(d.loadedAt) < s.ttl {
+		return d.data
+	}
+	data, err := s.loadOnce(Key(projectID))
+	if err != nil {
+		log.Printf("can't load dictionary, projID: %d, err: %s", projectID, err)
+	}
+	s.mu.Lock()
+	s.dicts[projectID] = &cachedDict{data: data, loadedAt: time.Now()}
+	s.mu.Unlock()
+	return data
+}
+
+// GetByID returns archived dictionary, dictionaries are immutable so they are cached without ttl
+func (s *Store) GetByID(dictID uint32) ([]byte, error) {
+	s.mu.Lock()
+	data, ok := s.archive[dictID]
+	s.mu.Unlock()
+	if ok {
+		return data, nil
+	}
+	data, err := s.loadOnce(ArchiveKey(dictID))
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("dictionary %d not found", dictID)
+	}
+	s.mu.Lock()
+	s.archive[dictID] = data
+	s.mu.Unlock()
+	return data, nil
+}
+
+// loadOnce downloads the dictionary without the store lock, so sessions of other projects aren't blocked
+// by the object storage, only one download of the same key runs at a time
+func (s *Store) loadOnce(key string) ([]byte, error) {
+	s.mu.Lock()
+	if l, ok := s.loading[key]; ok {
+		s.mu.Unlock()
+		<-l.done
+		return l.data, l.err
+	}
+	l := &loading{done: make(chan struct{})}
+	s.loading[key] = l
+	s.mu.Unlock()
+
+	l.data, l.err = s.load(key)
+	s.mu.Lock()
+	delete(s.loading, key)
+	s.mu.Unlock()
+	close(l.done)
+	return l.data, l.err
+}
+
+// Save uploads a new dictionary to the archive and makes it current for the project
+func (s *Store) Save(projectID uint32, dict []byte) error {
+	if err := validate(dict); err != nil {
+		return err
+	}
+	// Archive goes first, otherwise new files could be compressed with a dictionary that can't be found
+	if err := s.objStorage.Upload(bytes.NewReader(dict), ArchiveKey(ID(dict)), "application/octet-stream", false); err != nil {
+		return fmt.Errorf("can't upload dictionary to archive: %s", err)
+	}
+	if err := s.objStorage.Upload(bytes.NewReader(dict), Key(projectID), "application/octet-stream", false); err != nil {
+		return fmt.Errorf("can't upload project dictionary: %s", err)
+	}
+	return nil
+}
+
+// load returns nil if there is no dictionary for the key
+func (s *Store) load(key string) ([]byte, error) {
+	if !s.objStorage.Exists(key) {
+		return nil, nil
+	}
+	file, err := s.objStorage.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := validate(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,77 @@
+package dictionaries
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+)
+
+// Trainer builds dictionaries with the reference zstd implementation,
+// the compression library used by services can only apply them
+type Trainer struct {
+	binary    string
+	dictSize  int
+	blockSize int
+}
+
+func NewTrainer(binary string, dictSize, blockSize int) (*Trainer, error) {
+	switch {
+	case binary == "":
+		return nil, fmt.Errorf("zstd binary path is empty")
+	case dictSize <= 0:
+		return nil, fmt.Errorf("wrong dictionary size: %d", dictSize)
+	}
+	if _, err := exec.LookPath(binary); err != nil {
+		return nil, fmt.Errorf("can't find zstd binary: %s", err)
+	}
+	return &Trainer{
+		binary:    binary,
+		dictSize:  dictSize,
+		blockSize: blockSize,
+	}, nil
+}
+
+// Train builds a dictionary from the sample files of samplesDir, dictID is written
+// to the header of each file compressed with the dictionary
+func (t *Trainer) Train(dictID uint32, samplesDir string) ([]byte, error) {
+	samples, err := filepath.Glob(filepath.Join(samplesDir, "*"))
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no samples in %s", samplesDir)
+	}
+	output := filepath.Clean(samplesDir) + ".zdict"
+	args := []string{
+		"--train",
+		"-q",
+		"--maxdict=" + strconv.Itoa(t.dictSize),
+		"--dictID=" + strconv.FormatUint(uint64(dictID), 10),
+		"-o", output,
+	}
+	if t.blockSize > 0 {
+		// Session files are large, splitting them into blocks gives more samples
+		args = append(args, "-B"+strconv.Itoa(t.blockSize))
+	}
+	args = append(args, samples...)
+
+	stderr := &bytes.Buffer{}
+	cmd := exec.Command(t.binary, args...)
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("zstd training failed: %s, %s", err, stderr.String())
+	}
+	defer os.Remove(output)
+
+	dict, err := os.ReadFile(output)
+	if err != nil {
+		return nil, err
+	}
+	if err := validate(dict); err != nil {
+		return nil, err
+	}
+	return dict, nil
+}
@@ -5,6 +5,8 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 func String(key string) string {
@@ -70,3 +72,35 @@ func StringMapOptional(key string) map[string]string {
 	}
 	return stringMap
 }
+
+// DurationOptional returns 0 if the variable is missing, value format is "168h", "30m" etc.
+func DurationOptional(key string) time.Duration {
+	v := StringOptional(key)
+	if v == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalln(key+" has a wrong value. ", err)
+	}
+	return d
+}
+
+// IntOptional returns 0 if the variable is missing
+func IntOptional(key string) int {
+	if StringOptional(key) == "" {
+		return 0
+	}
+	return Int(key)
+}
+
+// StringListOptional splits comma separated values, empty values are skipped
+func StringListOptional(key string) []string {
+	var list []string
+	for _, v := range strings.Split(StringOptional(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,133 @@
+package fixtures
+
+import (
+	"fmt"
+
+	"openreplay/backend/pkg/messages"
+)
+
+type builder func(s *state, ts int64) messages.Message
+
+var tags = []string{"div", "span", "a", "p", "button", "input", "img", "li"}
+var consoleLevels = []string{"log", "info", "warn", "error"}
+
+var builders = map[int]builder{
+	messages.MsgMouseMove: func(s *state, ts int64) messages.Message {
+		return &messages.MouseMove{X: uint64(s.rnd.Intn(1920)), Y: uint64(s.rnd.Intn(1080))}
+	},
+	messages.MsgCreateElementNode: func(s *state, ts int64) messages.Message {
+		s.nodeID++
+		return &messages.CreateElementNode{
+			ID:       s.nodeID,
+			ParentID: s.parentID(),
+			Tag:      tags[s.rnd.Intn(len(tags))],
+		}
+	},
+	messages.MsgCreateTextNode: func(s *state, ts int64) messages.Message {
+		s.nodeID++
+		return &messages.CreateTextNode{ID: s.nodeID, ParentID: s.parentID()}
+	},
+	messages.MsgSetNodeAttribute: func(s *state, ts int64) messages.Message {
+		return &messages.SetNodeAttribute{
+			ID:    s.existingID(),
+			Name:  "class",
+			Value: fmt.Sprintf("item item-%d", s.rnd.Intn(50)),
+		}
+	},
+	messages.MsgSetNodeData: func(s *state, ts int64) messages.Message {
+		return &messages.SetNodeData{ID: s.existingID(), Data: s.text(5, 40)}
+	},
+	messages.MsgMouseClick: func(s *state, ts int64) messages.Message {
+		return &messages.MouseClick{
+			ID:             s.existingID(),
+			HesitationTime: uint64(s.rnd.Intn(3000)),
+			Label:          s.text(3, 15),
+			Selector:       "#app > div",
+		}
+	},
+	messages.MsgSetInputValue: func(s *state, ts int64) messages.Message {
+		return &messages.SetInputValue{ID: s.existingID(), Value: s.text(1, 20)}
+	},
+	messages.MsgConsoleLog: func(s *state, ts int64) messages.Message {
+		return &messages.ConsoleLog{
+			Level: consoleLevels[s.rnd.Intn(len(consoleLevels))],
+			Value: s.text(10, 80),
+		}
+	},
+	messages.MsgFetch: func(s *state, ts int64) messages.Message {
+		status := uint64(200)
+		if s.rnd.Intn(10) == 0 {
+			status = 500
+		}
+		return &messages.Fetch{
+			Method:    "GET",
+			URL:       fmt.Sprintf("https://example.com/api/items/%d", s.rnd.Intn(1000)),
+			Response:  "{}",
+			Status:    status,
+			Timestamp: uint64(ts),
+			Duration:  uint64(s.rnd.Intn(2000)),
+		}
+	},
+	messages.MsgJSException: func(s *state, ts int64) messages.Message {
+		return &messages.JSException{
+			Name:    "TypeError",
+			Message: "Cannot read properties of undefined",
+			Payload: "[]",
+		}
+	},
+	messages.MsgPerformanceTrack: func(s *state, ts int64) messages.Message {
+		return &messages.PerformanceTrack{
+			Frames:          int64(s.rnd.Intn(60)),
+			Ticks:           int64(s.rnd.Intn(60)),
+			TotalJSHeapSize: uint64(50e6 + s.rnd.Intn(10e6)),
+			UsedJSHeapSize:  uint64(20e6 + s.rnd.Intn(10e6)),
+		}
+	},
+	messages.MsgSetPageLocation: func(s *state, ts int64) messages.Message {
+		return &messages.SetPageLocation{
+			URL:             fmt.Sprintf("https://example.com/page/%d", s.rnd.Intn(20)),
+			NavigationStart: uint64(ts),
+		}
+	},
+	messages.MsgRawCustomEvent: func(s *state, ts int64) messages.Message {
+		return &messages.RawCustomEvent{Name: "fixture", Payload: `{"value":1}`}
+	},
+	messages.MsgMetadata: func(s *state, ts int64) messages.Message {
+		return &messages.Metadata{Key: "plan", Value: "free"}
+	},
+	messages.MsgUserID: func(s *state, ts int64) messages.Message {
+		return &messages.UserID{ID: fmt.Sprintf("user-%d", s.rnd.Intn(100))}
+	},
+	messages.MsgSetNodeAttributeURLBased: func(s *state, ts int64) messages.Message {
+		return &messages.SetNodeAttributeURLBased{
+			ID:      s.existingID(),
+			Name:    "href",
+			Value:   fmt.Sprintf("/static/style-%d.css", s.rnd.Intn(10)),
+			BaseURL: "https://example.com/",
+		}
+	},
+}
+
+func (s *state) parentID() uint64 {
+	if s.nodeID <= 1 {
+		return 0
+	}
+	return uint64(s.rnd.Int63n(int64(s.nodeID - 1)))
+}
+
+func (s *state) existingID() uint64 {
+	if s.nodeID == 0 {
+		return 0
+	}
+	return uint64(s.rnd.Int63n(int64(s.nodeID)))
+}
+
+const letters = "abcdefghijklmnopqrstuvwxyz     "
+
+func (s *state) text(min, max int) string {
+	buf := make([]byte, min+s.rnd.Intn(max-min+1))
+	for i := range buf {
+		buf[i] = letters[s.rnd.Intn(len(letters))]
+	}
+	return string(buf)
+}
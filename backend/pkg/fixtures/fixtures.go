@@ -0,0 +1,245 @@
+package fixtures
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"openreplay/backend/pkg/messages"
+)
+
+// Corruption describes which kind of damage should be applied to generated batches
+type Corruption int
+
+const CorruptNone Corruption = 0
+
+const (
+	CorruptTruncate      Corruption = 1 << iota // cut the tail of the batch in the middle of a message
+	CorruptGarbage                              // overwrite random bytes inside the batch
+	CorruptUnknownType                          // inject a message with unregistered type id
+	CorruptZeroTimestamp                        // drop timestamp messages, so all messages have ts == 0
+)
+
+// Config describes the session which should be synthesized
+type Config struct {
+	SessionID        uint64
+	StartTimestamp   int64 // unix milliseconds, time.Now() is used if empty
+	Duration         time.Duration
+	Batches          int
+	MessagesPerBatch int
+	Mix              map[int]int // message type -> relative weight
+	Corruption       Corruption
+	CorruptionRate   float64 // [0, 1], part of batches which should be corrupted
+	Seed             int64
+}
+
+// DefaultMix is a rough approximation of the message distribution of a regular web session
+var DefaultMix = map[int]int{
+	messages.MsgMouseMove:         30,
+	messages.MsgSetNodeAttribute:  20,
+	messages.MsgCreateElementNode: 15,
+	messages.MsgCreateTextNode:    5,
+	messages.MsgSetNodeData:       10,
+	messages.MsgMouseClick:        5,
+	messages.MsgSetInputValue:     3,
+	messages.MsgConsoleLog:        5,
+	messages.MsgFetch:             4,
+	messages.MsgJSException:       1,
+	messages.MsgPerformanceTrack:  2,
+}
+
+// Session is a synthesized session with all intermediate representations
+type Session struct {
+	ID       uint64
+	Start    int64
+	End      int64
+	Batches  [][]byte           // raw batches in the same format as the tracker sends them
+	Messages []messages.Message // all not corrupted messages in the order of generation
+}
+
+type Generator struct {
+	cfg     Config
+	types   []int
+	weights []int
+	total   int
+}
+
+// state of one generated session, it's created by every Generate call
+type state struct {
+	rnd    *rand.Rand
+	nodeID uint64
+}
+
+func New(cfg Config) (*Generator, error) {
+	switch {
+	case cfg.Batches <= 0:
+		return nil, errors.New("batches number should be positive")
+	case cfg.MessagesPerBatch <= 0:
+		return nil, errors.New("messages per batch should be positive")
+	case cfg.Duration <= 0:
+		return nil, errors.New("duration should be positive")
+	case cfg.CorruptionRate < 0 || cfg.CorruptionRate > 1:
+		return nil, fmt.Errorf("wrong corruption rate: %f", cfg.CorruptionRate)
+	}
+	if cfg.StartTimestamp == 0 {
+		cfg.StartTimestamp = time.Now().UnixMilli()
+	}
+	if cfg.Mix == nil {
+		cfg.Mix = DefaultMix
+	}
+	g := &Generator{cfg: cfg}
+	// Go maps don't have stable order, so sort message types to keep generation deterministic
+	for tp := 0; tp < 128; tp++ {
+		if w, ok := cfg.Mix[tp]; ok && w > 0 {
+			if _, ok := builders[tp]; !ok {
+				return nil, fmt.Errorf("message type %d is not supported", tp)
+			}
+			g.types = append(g.types, tp)
+			g.weights = append(g.weights, w)
+			g.total += w
+		}
+	}
+	if g.total == 0 {
+		return nil, errors.New("message mix is empty")
+	}
+	return g, nil
+}
+
+// Generate synthesizes a new session, the same config always produces the same result.
+// Messages get timestamps of their generation, the batch has the timestamp of its first message.
+func (g *Generator) Generate() *Session {
+	s := &state{rnd: rand.New(rand.NewSource(g.cfg.Seed))}
+	sess := &Session{
+		ID:    g.cfg.SessionID,
+		Start: g.cfg.StartTimestamp,
+		End:   g.cfg.StartTimestamp + g.cfg.Duration.Milliseconds(),
+	}
+	step := g.cfg.Duration.Milliseconds() / int64(g.cfg.Batches*g.cfg.MessagesPerBatch)
+	if step == 0 {
+		step = 1
+	}
+	ts := sess.Start
+	var index uint64
+	for b := 0; b < g.cfg.Batches; b++ {
+		batchTs := ts
+		batch := make([]messages.Message, 0, g.cfg.MessagesPerBatch+3)
+		if b == 0 {
+			batch = append(batch,
+				&messages.SetPageLocation{URL: "https://example.com/", NavigationStart: uint64(ts)},
+				&messages.SetViewportSize{Width: 1920, Height: 1080},
+				&messages.CreateDocument{},
+			)
+		}
+		for _, msg := range batch {
+			msg.Meta().Timestamp = batchTs
+		}
+		for i := 0; i < g.cfg.MessagesPerBatch; i++ {
+			msg := g.next(s, ts)
+			msg.Meta().Timestamp = ts
+			batch = append(batch, msg)
+			ts += step
+		}
+		corrupted := g.cfg.Corruption != CorruptNone && s.rnd.Float64() < g.cfg.CorruptionRate
+		data, next := g.encodeBatch(index, batchTs, batch, corrupted)
+		if corrupted {
+			data = g.corrupt(s, data)
+		} else {
+			sess.Messages = append(sess.Messages, batch...)
+		}
+		index = next
+		sess.Batches = append(sess.Batches, data)
+	}
+	return sess
+}
+
+// SessionStart returns the message which http service sends to the queue on session start
+func (s *Session) SessionStart(projectID uint64) *messages.SessionStart {
+	return &messages.SessionStart{
+		Timestamp:      uint64(s.Start),
+		ProjectID:      projectID,
+		TrackerVersion: "fixtures",
+		UserUUID:       "00000000-0000-0000-0000-000000000000",
+		UserOS:         "Linux",
+		UserBrowser:    "Chrome",
+		UserDevice:     "Other",
+		UserDeviceType: "desktop",
+		UserCountry:    "FR",
+	}
+}
+
+// SessionEnd returns the message which ender sends to the queue after session inactivity timeout
+func (s *Session) SessionEnd() *messages.SessionEnd {
+	return &messages.SessionEnd{Timestamp: uint64(s.End)}
+}
+
+// Mob returns session file content in the same format as sink writes it (only replayer messages with index)
+func (s *Session) Mob() []byte {
+	var data []byte
+	for _, msg := range s.Messages {
+		if messages.IsReplayerType(msg.TypeID()) {
+			data = append(data, msg.EncodeWithIndex()...)
+		}
+	}
+	return data
+}
+
+func (g *Generator) next(s *state, ts int64) messages.Message {
+	n := s.rnd.Intn(g.total)
+	for i, w := range g.weights {
+		if n < w {
+			return builders[g.types[i]](s, ts)
+		}
+		n -= w
+	}
+	return builders[g.types[len(g.types)-1]](s, ts)
+}
+
+// encodeBatch sets indexes of the batch messages and returns the index of the next batch,
+// Timestamp messages have indexes as well
+func (g *Generator) encodeBatch(firstIndex uint64, ts int64, batch []messages.Message, corrupted bool) ([]byte, uint64) {
+	data := (&messages.BatchMetadata{
+		Version:    1,
+		PageNo:     0, // all batches belong to the first page of the session
+		FirstIndex: firstIndex,
+		Timestamp:  ts,
+		Location:   "https://example.com/",
+	}).Encode()
+	// Timestamp message is written before each message with the new time, the same way as the tracker does
+	skipTimestamps := corrupted && g.cfg.Corruption&CorruptZeroTimestamp != 0
+	lastTs := int64(-1)
+	index := firstIndex
+	for _, msg := range batch {
+		if msgTs := msg.Meta().Timestamp; !skipTimestamps && msgTs != lastTs {
+			data = appendWithSize(data, (&messages.Timestamp{Timestamp: uint64(msgTs)}).Encode())
+			lastTs = msgTs
+			index++
+		}
+		msg.Meta().Index = index
+		data = appendWithSize(data, msg.Encode())
+		index++
+	}
+	return data, index
+}
+
+// appendWithSize writes message in the new protocol format: type, 3 bytes of body size, body
+func appendWithSize(data []byte, encoded []byte) []byte {
+	size := len(encoded) - 1
+	data = append(data, encoded[0], byte(size), byte(size>>8), byte(size>>16))
+	return append(data, encoded[1:]...)
+}
+
+func (g *Generator) corrupt(s *state, data []byte) []byte {
+	if g.cfg.Corruption&CorruptUnknownType != 0 {
+		data = appendWithSize(data, []byte{119, 1, 2, 3})
+	}
+	if g.cfg.Corruption&CorruptGarbage != 0 && len(data) > 1 {
+		for i := 0; i < 1+len(data)/100; i++ {
+			data[1+s.rnd.Intn(len(data)-1)] = byte(s.rnd.Intn(256))
+		}
+	}
+	if g.cfg.Corruption&CorruptTruncate != 0 && len(data) > 1 {
+		data = data[:1+s.rnd.Intn(len(data)-1)]
+	}
+	return data
+}
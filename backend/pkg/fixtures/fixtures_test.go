@@ -0,0 +1,86 @@
+package fixtures
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"openreplay/backend/pkg/messages"
+)
+
+func newGenerator(t *testing.T, cfg Config) *Generator {
+	t.Helper()
+	g, err := New(cfg)
+	if err != nil {
+		t.Fatalf("can't create generator: %s", err)
+	}
+	return g
+}
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	g := newGenerator(t, Config{
+		SessionID:        1,
+		StartTimestamp:   1660000000000,
+		Duration:         time.Minute,
+		Batches:          5,
+		MessagesPerBatch: 50,
+		Seed:             42,
+	})
+	first, second := g.Generate(), g.Generate()
+	if len(first.Batches) != len(second.Batches) {
+		t.Fatalf("different number of batches: %d != %d", len(first.Batches), len(second.Batches))
+	}
+	for i := range first.Batches {
+		if !bytes.Equal(first.Batches[i], second.Batches[i]) {
+			t.Fatalf("batch %d differs between calls", i)
+		}
+	}
+	if !bytes.Equal(first.Mob(), second.Mob()) {
+		t.Fatal("session file differs between calls")
+	}
+}
+
+func TestBatchesAreReadable(t *testing.T) {
+	sess := newGenerator(t, Config{
+		SessionID:        2,
+		StartTimestamp:   1660000000000,
+		Duration:         time.Minute,
+		Batches:          3,
+		MessagesPerBatch: 100,
+		Seed:             7,
+	}).Generate()
+
+	n := 0
+	for b, batch := range sess.Batches {
+		iter := messages.NewIterator(batch)
+		for iter.Next() {
+			tp := iter.Type()
+			if tp == messages.MsgBatchMetadata || tp == messages.MsgTimestamp {
+				continue
+			}
+			if n >= len(sess.Messages) {
+				t.Fatalf("more messages in batches than generated: %d", len(sess.Messages))
+			}
+			msg, expected := iter.Message(), sess.Messages[n]
+			if msg.TypeID() != expected.TypeID() {
+				t.Fatalf("message %d: type %d, expected %d", n, msg.TypeID(), expected.TypeID())
+			}
+			if msg.Meta().Index != expected.Meta().Index || msg.Meta().Timestamp != expected.Meta().Timestamp {
+				t.Fatalf("message %d: index %d ts %d, expected index %d ts %d", n,
+					msg.Meta().Index, msg.Meta().Timestamp, expected.Meta().Index, expected.Meta().Timestamp)
+			}
+			n++
+		}
+		if err := iter.Err(); err != nil {
+			t.Fatalf("can't read batch %d: %s", b, err)
+		}
+		iter.Close()
+	}
+	if n != len(sess.Messages) {
+		t.Fatalf("read %d messages, generated %d", n, len(sess.Messages))
+	}
+	last := sess.Messages[len(sess.Messages)-1].Meta().Timestamp
+	if first := sess.Messages[0].Meta().Timestamp; first >= last || last > sess.End {
+		t.Fatalf("wrong message timestamps: first %d, last %d, session end %d", first, last, sess.End)
+	}
+}
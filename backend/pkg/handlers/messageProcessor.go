@@ -9,3 +9,10 @@ type MessageProcessor interface {
 	Handle(message Message, messageID uint64, timestamp uint64) Message
 	Build() Message
 }
+
+// StatefulProcessor can be moved to another heuristics instance together with the session
+type StatefulProcessor interface {
+	MessageProcessor
+	MarshalState() ([]byte, error)
+	UnmarshalState(data []byte) error
+}
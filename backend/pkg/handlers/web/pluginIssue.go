@@ -0,0 +1,114 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+
+	. "openreplay/backend/pkg/messages"
+)
+
+/*
+	Handler name: PluginIssue
+	Input event:  PluginIssue
+	Output event: IssueEvent
+*/
+
+const MAX_PLUGIN_ISSUES = 100 // per session, a plugin reporting in a loop mustn't flood the issues table
+const MAX_PLUGIN_ISSUE_CONTEXT_LENGTH = 256
+const MAX_PLUGIN_ISSUE_PAYLOAD_LENGTH = 8192
+
+// Plugin and type names become a part of the issue name, so they are limited to identifiers
+var pluginIssueName = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,64}$`)
+
+var pluginIssueSeverities = map[string]bool{
+	"info":     true,
+	"low":      true,
+	"medium":   true,
+	"high":     true,
+	"critical": true,
+}
+
+// PluginIssueContext is saved as the context of the issue, the severity is read by the db service
+type PluginIssueContext struct {
+	Plugin        string `json:"plugin"`
+	Type          string `json:"type"`
+	Severity      string `json:"severity"`
+	ContextString string `json:"contextString,omitempty"`
+}
+
+// PluginIssueDetector passes issues classified by tracker plugins to the db as custom issues.
+// The same issue is reported once per session.
+type PluginIssueDetector struct {
+	reported map[string]bool
+	invalid  int
+}
+
+func (d *PluginIssueDetector) Build() Message {
+	return nil
+}
+
+func (d *PluginIssueDetector) Handle(message Message, messageID uint64, timestamp uint64) Message {
+	msg, ok := message.(*PluginIssue)
+	if !ok {
+		return nil
+	}
+	if err := validatePluginIssue(msg); err != nil {
+		// A broken plugin sends the same wrong issue many times, it's logged once
+		if d.invalid == 0 {
+			log.Printf("wrong plugin issue: %s", err)
+		}
+		d.invalid++
+		return nil
+	}
+	key := msg.Plugin + "/" + msg.Type + "/" + msg.ContextString
+	if d.reported[key] || len(d.reported) >= MAX_PLUGIN_ISSUES {
+		return nil
+	}
+	if d.reported == nil {
+		d.reported = make(map[string]bool)
+	}
+	d.reported[key] = true
+
+	context, err := json.Marshal(PluginIssueContext{
+		Plugin:        msg.Plugin,
+		Type:          msg.Type,
+		Severity:      msg.Severity,
+		ContextString: msg.ContextString,
+	})
+	if err != nil {
+		log.Printf("can't marshal PluginIssue context to json: %s", err)
+	}
+	contextString := msg.Plugin + "." + msg.Type
+	if msg.ContextString != "" {
+		contextString += ": " + msg.ContextString
+	}
+	return &IssueEvent{
+		Type:          "custom",
+		ContextString: contextString,
+		Context:       string(context),
+		Payload:       msg.Payload,
+		Timestamp:     timestamp,
+		MessageID:     messageID,
+	}
+}
+
+func validatePluginIssue(msg *PluginIssue) error {
+	switch {
+	case !pluginIssueName.MatchString(msg.Plugin):
+		return fmt.Errorf("wrong plugin name: %.64q", msg.Plugin)
+	case !pluginIssueName.MatchString(msg.Type):
+		return fmt.Errorf("wrong type of %s issue: %.64q", msg.Plugin, msg.Type)
+	case !pluginIssueSeverities[msg.Severity]:
+		return fmt.Errorf("wrong severity of %s issue: %.64q", msg.Plugin, msg.Severity)
+	case len(msg.ContextString) > MAX_PLUGIN_ISSUE_CONTEXT_LENGTH:
+		return fmt.Errorf("too long context of %s issue: %d", msg.Plugin, len(msg.ContextString))
+	case len(msg.Payload) > MAX_PLUGIN_ISSUE_PAYLOAD_LENGTH:
+		return fmt.Errorf("too long payload of %s issue: %d", msg.Plugin, len(msg.Payload))
+	case msg.Payload != "" && !json.Valid([]byte(msg.Payload)):
+		// Payload is saved as jsonb
+		return fmt.Errorf("payload of %s issue isn't json", msg.Plugin)
+	}
+	return nil
+}
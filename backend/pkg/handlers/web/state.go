@@ -0,0 +1,203 @@
+package web
+
+import (
+	"encoding/json"
+
+	. "openreplay/backend/pkg/messages"
+)
+
+// State of detectors is serialized when a partition is handed over to another heuristics instance
+
+type clickRageState struct {
+	LastTimestamp        uint64
+	LastLabel            string
+	FirstInARawTimestamp uint64
+	FirstInARawMessageId uint64
+	CountsInARow         int
+}
+
+func (crd *ClickRageDetector) MarshalState() ([]byte, error) {
+	return json.Marshal(clickRageState{
+		crd.lastTimestamp,
+		crd.lastLabel,
+		crd.firstInARawTimestamp,
+		crd.firstInARawMessageId,
+		crd.countsInARow,
+	})
+}
+
+func (crd *ClickRageDetector) UnmarshalState(data []byte) error {
+	state := clickRageState{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	crd.lastTimestamp = state.LastTimestamp
+	crd.lastLabel = state.LastLabel
+	crd.firstInARawTimestamp = state.FirstInARawTimestamp
+	crd.firstInARawMessageId = state.FirstInARawMessageId
+	crd.countsInARow = state.CountsInARow
+	return nil
+}
+
+type cpuIssueState struct {
+	StartTimestamp uint64
+	StartMessageID uint64
+	LastTimestamp  uint64
+	MaxRate        uint64
+	ContextString  string
+}
+
+func (f *CpuIssueDetector) MarshalState() ([]byte, error) {
+	return json.Marshal(cpuIssueState{
+		f.startTimestamp,
+		f.startMessageID,
+		f.lastTimestamp,
+		f.maxRate,
+		f.contextString,
+	})
+}
+
+func (f *CpuIssueDetector) UnmarshalState(data []byte) error {
+	state := cpuIssueState{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	f.startTimestamp = state.StartTimestamp
+	f.startMessageID = state.StartMessageID
+	f.lastTimestamp = state.LastTimestamp
+	f.maxRate = state.MaxRate
+	f.contextString = state.ContextString
+	return nil
+}
+
+type deadClickState struct {
+	LastTimestamp      uint64
+	LastMouseClick     *MouseClick
+	LastClickTimestamp uint64
+	LastMessageID      uint64
+	InputIDSet         map[uint64]bool
+}
+
+func (d *DeadClickDetector) MarshalState() ([]byte, error) {
+	return json.Marshal(deadClickState{
+		d.lastTimestamp,
+		d.lastMouseClick,
+		d.lastClickTimestamp,
+		d.lastMessageID,
+		d.inputIDSet,
+	})
+}
+
+func (d *DeadClickDetector) UnmarshalState(data []byte) error {
+	state := deadClickState{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	d.lastTimestamp = state.LastTimestamp
+	d.lastMouseClick = state.LastMouseClick
+	d.lastClickTimestamp = state.LastClickTimestamp
+	d.lastMessageID = state.LastMessageID
+	d.inputIDSet = state.InputIDSet
+	return nil
+}
+
+type memoryIssueState struct {
+	StartMessageID uint64
+	StartTimestamp uint64
+	Rate           int
+	Count          float64
+	Sum            float64
+	ContextString  string
+}
+
+func (f *MemoryIssueDetector) MarshalState() ([]byte, error) {
+	return json.Marshal(memoryIssueState{
+		f.startMessageID,
+		f.startTimestamp,
+		f.rate,
+		f.count,
+		f.sum,
+		f.contextString,
+	})
+}
+
+func (f *MemoryIssueDetector) UnmarshalState(data []byte) error {
+	state := memoryIssueState{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	f.startMessageID = state.StartMessageID
+	f.startTimestamp = state.StartTimestamp
+	f.rate = state.Rate
+	f.count = state.Count
+	f.sum = state.Sum
+	f.contextString = state.ContextString
+	return nil
+}
+
+type pluginIssueState struct {
+	Reported map[string]bool
+	Invalid  int
+}
+
+func (d *PluginIssueDetector) MarshalState() ([]byte, error) {
+	return json.Marshal(pluginIssueState{
+		d.reported,
+		d.invalid,
+	})
+}
+
+func (d *PluginIssueDetector) UnmarshalState(data []byte) error {
+	state := pluginIssueState{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	d.reported = state.Reported
+	d.invalid = state.Invalid
+	return nil
+}
+
+func (f *NetworkIssueDetector) MarshalState() ([]byte, error) {
+	return []byte("{}"), nil
+}
+
+func (f *NetworkIssueDetector) UnmarshalState(_ []byte) error {
+	return nil
+}
+
+type performanceAggregatorState struct {
+	Aggregation        *PerformanceTrackAggr
+	LastTimestamp      uint64
+	Count              float64
+	SumFrameRate       float64
+	SumTickRate        float64
+	SumTotalJSHeapSize float64
+	SumUsedJSHeapSize  float64
+}
+
+func (b *PerformanceAggregator) MarshalState() ([]byte, error) {
+	return json.Marshal(performanceAggregatorState{
+		b.PerformanceTrackAggr,
+		b.lastTimestamp,
+		b.count,
+		b.sumFrameRate,
+		b.sumTickRate,
+		b.sumTotalJSHeapSize,
+		b.sumUsedJSHeapSize,
+	})
+}
+
+func (b *PerformanceAggregator) UnmarshalState(data []byte) error {
+	state := performanceAggregatorState{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	b.PerformanceTrackAggr = state.Aggregation
+	b.lastTimestamp = state.LastTimestamp
+	b.count = state.Count
+	b.sumFrameRate = state.SumFrameRate
+	b.sumTickRate = state.SumTickRate
+	b.sumTotalJSHeapSize = state.SumTotalJSHeapSize
+	b.sumUsedJSHeapSize = state.SumUsedJSHeapSize
+	return nil
+}
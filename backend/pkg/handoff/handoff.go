@@ -0,0 +1,72 @@
+package handoff
+
+import (
+	"fmt"
+	"log"
+
+	"openreplay/backend/pkg/queue/types"
+)
+
+// Store keeps serialized state of partitions while they are moving between consumers
+type Store interface {
+	Save(partition types.TopicPartition, state []byte) error
+	Load(partition types.TopicPartition) ([]byte, error) // returns nil state if there is nothing to restore
+}
+
+// Stateful is implemented by services which keep in-memory state for consumed partitions
+type Stateful interface {
+	// ExportPartitions serializes and removes state of the given partitions
+	ExportPartitions(partitions []types.TopicPartition) (map[types.TopicPartition][]byte, error)
+	// ImportPartition restores state exported by another service instance
+	ImportPartition(partition types.TopicPartition, state []byte) error
+}
+
+// Manager transfers partitions state through the store on every consumer group rebalance
+type Manager struct {
+	store Store
+	state Stateful
+}
+
+func New(store Store, state Stateful) (*Manager, error) {
+	switch {
+	case store == nil:
+		return nil, fmt.Errorf("state store is empty")
+	case state == nil:
+		return nil, fmt.Errorf("stateful service is empty")
+	}
+	return &Manager{
+		store: store,
+		state: state,
+	}, nil
+}
+
+func (m *Manager) Assigned(partitions []types.TopicPartition) {
+	for _, partition := range partitions {
+		state, err := m.store.Load(partition)
+		if err != nil {
+			log.Printf("can't load state of partition %s: %s", partition, err)
+			continue
+		}
+		if state == nil {
+			continue
+		}
+		if err := m.state.ImportPartition(partition, state); err != nil {
+			log.Printf("can't import state of partition %s: %s", partition, err)
+			continue
+		}
+		log.Printf("restored state of partition %s, size: %d", partition, len(state))
+	}
+}
+
+func (m *Manager) Revoked(partitions []types.TopicPartition) {
+	states, err := m.state.ExportPartitions(partitions)
+	if err != nil {
+		log.Printf("can't export state of partitions %v: %s", partitions, err)
+		return
+	}
+	for partition, state := range states {
+		if err := m.store.Save(partition, state); err != nil {
+			log.Printf("can't save state of partition %s: %s", partition, err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package handoff
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+
+	"openreplay/backend/pkg/queue/types"
+)
+
+// stateTTL limits the life of the state which nobody picked up (e.g. the number of partitions was changed)
+const stateTTL = 24 * time.Hour
+
+type redisStore struct {
+	client *redis.Client
+	key    string
+}
+
+// NewRedisStore keeps state of all partitions of the consumer group in one redis hash
+func NewRedisStore(addr, group string) (Store, error) {
+	switch {
+	case addr == "":
+		return nil, fmt.Errorf("redis address is empty")
+	case group == "":
+		return nil, fmt.Errorf("consumer group is empty")
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr: addr,
+	})
+	if _, err := client.Ping().Result(); err != nil {
+		return nil, fmt.Errorf("can't connect to redis: %s", err)
+	}
+	return &redisStore{
+		client: client,
+		key:    "handoff:" + group,
+	}, nil
+}
+
+// field is unique for the topic and the partition, the same group may consume several topics
+func field(partition types.TopicPartition) string {
+	return partition.Topic + ":" + strconv.FormatUint(partition.Partition, 10)
+}
+
+func (r *redisStore) Save(partition types.TopicPartition, state []byte) error {
+	if err := r.client.HSet(r.key, field(partition), state).Err(); err != nil {
+		return err
+	}
+	return r.client.Expire(r.key, stateTTL).Err()
+}
+
+func (r *redisStore) Load(partition types.TopicPartition) ([]byte, error) {
+	name := field(partition)
+	state, err := r.client.HGet(r.key, name).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// State must be restored only once, otherwise the next assignment will bring back already ended sessions
+	if err := r.client.HDel(r.key, name).Err(); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
@@ -0,0 +1,154 @@
+package memqueue
+
+import (
+	"sync"
+	"time"
+
+	"openreplay/backend/pkg/env"
+)
+
+// Topics live in the memory of the process, so producers and consumers of all services have to run in one
+// binary. Every topic is a log with the only partition 0, groups read it from their own offsets the same way
+// as kafka groups. Messages are kept until all groups of the topic commit them.
+const defaultTopicSize = 64 << 20
+
+type message struct {
+	key   uint64
+	value []byte
+	ts    int64 // ms
+}
+
+type group struct {
+	committed uint64 // offset of the first not committed message
+	next      uint64 // offset of the next message to deliver
+	active    bool   // the group has a consumer
+	wake      chan<- struct{}
+}
+
+type topic struct {
+	mu       sync.Mutex
+	messages []message // messages from the offset first which aren't committed by all groups
+	first    uint64
+	size     int // bytes of values in messages
+	maxSize  int
+	groups   map[string]*group
+}
+
+type broker struct {
+	mu     sync.Mutex
+	topics map[string]*topic
+}
+
+// defaultBroker is shared by all producers and consumers of the process
+var defaultBroker = &broker{topics: make(map[string]*topic)}
+
+func (b *broker) topic(name string) *topic {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t, ok := b.topics[name]
+	if !ok {
+		maxSize := env.IntOptional("MEMQUEUE_TOPIC_SIZE")
+		if maxSize <= 0 {
+			maxSize = defaultTopicSize
+		}
+		t = &topic{
+			maxSize: maxSize,
+			groups:  make(map[string]*group),
+		}
+		b.topics[name] = t
+	}
+	return t
+}
+
+func (t *topic) end() uint64 {
+	return t.first + uint64(len(t.messages))
+}
+
+// append returns false if the topic is full, an empty topic accepts any message
+func (t *topic) append(key uint64, value []byte) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.size > 0 && t.size+len(value) > t.maxSize {
+		return false
+	}
+	t.messages = append(t.messages, message{key: key, value: value, ts: time.Now().UnixMilli()})
+	t.size += len(value)
+	for _, g := range t.groups {
+		if !g.active {
+			continue
+		}
+		select {
+		case g.wake <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+// join starts reading of the group from the last committed offset, new groups read all kept messages.
+// The consumer is woken up through wake on every new message.
+func (t *topic) join(name string, wake chan<- struct{}) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	g, ok := t.groups[name]
+	if !ok {
+		g = &group{committed: t.first}
+		t.groups[name] = g
+	}
+	if g.active {
+		return false
+	}
+	g.active, g.next, g.wake = true, g.committed, wake
+	return true
+}
+
+// leave makes not committed messages of the group available to its next consumer
+func (t *topic) leave(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	g := t.groups[name]
+	g.active, g.next, g.wake = false, g.committed, nil
+}
+
+// next returns the next message of the group and its offset, false if the group has read all messages
+func (t *topic) next(name string) (message, uint64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	g := t.groups[name]
+	if g.next >= t.end() {
+		return message{}, 0, false
+	}
+	offset := g.next
+	g.next++
+	return t.messages[offset-t.first], offset, true
+}
+
+// commit moves the offset of the group and drops messages which are committed by all groups
+func (t *topic) commit(name string, offset uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	g := t.groups[name]
+	if offset <= g.committed {
+		return
+	}
+	g.committed = offset
+	min := t.end()
+	for _, g := range t.groups {
+		if g.committed < min {
+			min = g.committed
+		}
+	}
+	n := int(min - t.first)
+	for _, m := range t.messages[:n] {
+		t.size -= len(m.value)
+	}
+	// Copy the rest, so the array of dropped messages is freed
+	t.messages = append([]message(nil), t.messages[n:]...)
+	t.first = min
+}
+
+func (t *topic) lag(name string) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return int64(t.end() - t.groups[name].committed)
+}
@@ -0,0 +1,150 @@
+package memqueue
+
+import (
+	"log"
+	"time"
+
+	"openreplay/backend/pkg/queue/types"
+)
+
+const pollTimeout = 200 * time.Millisecond
+
+type pendingMessage struct {
+	topic  *topic
+	offset uint64
+	ts     int64
+}
+
+// Consumer reads topics of the process for the group, one consumer of the group can be active at a time.
+// Messages which aren't committed before Close are delivered again to the next consumer of the group.
+type Consumer struct {
+	group      string
+	names      []string
+	topics     []*topic
+	handler    types.MessageHandler
+	autoCommit bool
+	wake       chan struct{}
+	cursor     int              // the topic to read first, topics are read in turns
+	pending    []pendingMessage // handled but not committed messages
+	lastTs     int64
+}
+
+func NewConsumer(group string, topics []string, handler types.MessageHandler, autoCommit bool) *Consumer {
+	c := &Consumer{
+		group:      group,
+		names:      topics,
+		handler:    handler,
+		autoCommit: autoCommit,
+		wake:       make(chan struct{}, 1),
+	}
+	for _, name := range topics {
+		t := defaultBroker.topic(name)
+		if !t.join(group, c.wake) {
+			log.Fatalf("group %s already has a consumer of %s", group, name)
+		}
+		c.topics = append(c.topics, t)
+	}
+	return c
+}
+
+func (c *Consumer) ConsumeNext() error {
+	if c.consume() {
+		return nil
+	}
+	timer := time.NewTimer(pollTimeout)
+	defer timer.Stop()
+	select {
+	case <-c.wake:
+		c.consume()
+	case <-timer.C:
+	}
+	return nil
+}
+
+// consume handles the next message of the topics, it returns false if there are no new messages
+func (c *Consumer) consume() bool {
+	for i := range c.topics {
+		n := (c.cursor + i) % len(c.topics)
+		msg, offset, ok := c.topics[n].next(c.group)
+		if !ok {
+			continue
+		}
+		c.cursor = (n + 1) % len(c.topics)
+		c.handler(msg.key, msg.value, &types.Meta{
+			ID:        offset,
+			Topic:     c.names[n],
+			Partition: 0,
+			Timestamp: msg.ts,
+		})
+		if c.autoCommit {
+			c.topics[n].commit(c.group, offset+1)
+			return true
+		}
+		c.lastTs = msg.ts
+		c.pending = append(c.pending, pendingMessage{topic: c.topics[n], offset: offset, ts: msg.ts})
+		return true
+	}
+	return false
+}
+
+// commit commits the first n pending messages
+func (c *Consumer) commit(n int) error {
+	for _, p := range c.pending[:n] {
+		p.topic.commit(c.group, p.offset+1)
+	}
+	c.pending = c.pending[n:]
+	return nil
+}
+
+func (c *Consumer) Commit() error {
+	return c.commit(len(c.pending))
+}
+
+// CommitBack commits messages which are older than the last one by gap milliseconds
+func (c *Consumer) CommitBack(gap int64) error {
+	if c.lastTs == 0 {
+		return nil
+	}
+	maxTs := c.lastTs - gap
+	n := 0
+	for n < len(c.pending) && c.pending[n].ts <= maxTs {
+		n++
+	}
+	return c.commit(n)
+}
+
+func (c *Consumer) Close() {
+	c.pending = nil
+	for _, t := range c.topics {
+		t.leave(c.group)
+	}
+}
+
+// HasFirstPartition is always true, the consumer reads the only partition of its topics
+func (c *Consumer) HasFirstPartition() bool {
+	return true
+}
+
+// SetPartitionListener reports the only partition of every topic right away, it never changes
+func (c *Consumer) SetPartitionListener(listener types.PartitionListener) {
+	if listener == nil {
+		return
+	}
+	assigned := make([]types.TopicPartition, 0, len(c.names))
+	for _, name := range c.names {
+		assigned = append(assigned, types.TopicPartition{Topic: name})
+	}
+	listener.Assigned(assigned)
+}
+
+// Lag returns the number of messages of topics which aren't committed by the group yet
+func (c *Consumer) Lag() ([]types.PartitionLag, error) {
+	lags := make([]types.PartitionLag, 0, len(c.topics))
+	for i, t := range c.topics {
+		lags = append(lags, types.PartitionLag{
+			TopicPartition: types.TopicPartition{Topic: c.names[i]},
+			Lag:            t.lag(c.group),
+		})
+	}
+	return lags, nil
+}
@@ -0,0 +1,33 @@
+package memqueue
+
+import "openreplay/backend/pkg/queue/types"
+
+// Producer appends messages to topics of the process right away, there is nothing to flush
+type Producer struct {
+	broker *broker
+}
+
+func NewProducer() *Producer {
+	return &Producer{broker: defaultBroker}
+}
+
+// Produce returns types.ErrBackpressure if consumers of the topic are behind by MEMQUEUE_TOPIC_SIZE bytes
+func (p *Producer) Produce(topic string, key uint64, value []byte) error {
+	if !p.broker.topic(topic).append(key, value) {
+		return types.ErrBackpressure
+	}
+	return nil
+}
+
+// ProduceToPartition writes to the only partition of the topic
+func (p *Producer) ProduceToPartition(topic string, _, key uint64, value []byte) error {
+	return p.Produce(topic, key, value)
+}
+
+func (p *Producer) Close(_ int) {
+	// noop
+}
+
+func (p *Producer) Flush(_ int) {
+	// noop
+}
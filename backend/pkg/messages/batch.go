@@ -1,7 +1,9 @@
 package messages
 
 import (
+	"bufio"
 	"bytes"
+	"fmt"
 	"io"
 	"log"
 	"strings"
@@ -12,10 +14,11 @@ type Iterator interface {
 	Type() int        // Return type of the next message
 	Message() Message // Return raw or decoded message
 	Close()
+	Err() error // Return the error which stopped the iteration, nil at the end of the batch
 }
 
 type iteratorImpl struct {
-	data      *bytes.Reader
+	data      io.Reader
 	index     uint64
 	timestamp int64
 	version   uint64
@@ -24,6 +27,7 @@ type iteratorImpl struct {
 	canSkip   bool
 	msg       Message
 	url       string
+	err       error
 }
 
 func NewIterator(data []byte) Iterator {
@@ -32,11 +36,31 @@ func NewIterator(data []byte) Iterator {
 	}
 }
 
+// NewReaderIterator decodes the batch message by message while reading it, so the whole batch
+// is never kept in memory. Not decoded messages are skipped without copying.
+func NewReaderIterator(reader io.Reader) Iterator {
+	if _, ok := reader.(io.ByteReader); !ok {
+		reader = bufio.NewReader(reader)
+	}
+	return &iteratorImpl{
+		data: reader,
+	}
+}
+
+// skip moves the reader to the next message, readers which can't seek are read through
+func (i *iteratorImpl) skip(n int64) error {
+	if seeker, ok := i.data.(io.Seeker); ok {
+		_, err := seeker.Seek(n, io.SeekCurrent)
+		return err
+	}
+	_, err := io.CopyN(io.Discard, i.data, n)
+	return err
+}
+
 func (i *iteratorImpl) Next() bool {
 	if i.canSkip {
-		if _, err := i.data.Seek(int64(i.msgSize), io.SeekCurrent); err != nil {
-			log.Printf("seek err: %s", err)
-			return false
+		if err := i.skip(int64(i.msgSize)); err != nil {
+			return i.fail("seek err: %s", err)
 		}
 	}
 	i.canSkip = false
@@ -47,16 +71,14 @@ func (i *iteratorImpl) Next() bool {
 		if err == io.EOF {
 			return false
 		}
-		log.Printf("can't read message type: %s", err)
-		return false
+		return i.fail("can't read message type: %s", err)
 	}
 
 	if i.version > 0 && messageHasSize(i.msgType) {
 		// Read message size if it is a new protocol version
 		i.msgSize, err = ReadSize(i.data)
 		if err != nil {
-			log.Printf("can't read message size: %s", err)
-			return false
+			return i.fail("can't read message size: %s", err)
 		}
 		i.msg = &RawMessage{
 			tp:      i.msgType,
@@ -66,37 +88,54 @@ func (i *iteratorImpl) Next() bool {
 			skipped: &i.canSkip,
 		}
 		i.canSkip = true
+		if needsUpgrade(i.version, i.msgType) {
+			i.msg = UpgradeMessage(i.version, i.msg)
+		}
 	} else {
 		i.msg, err = ReadMessage(i.msgType, i.data)
 		if err == io.EOF {
 			return false
+		} else if err != nil && IsDeprecated(i.msgType) {
+			// Removed types can be read only by their registered layout
+			i.msg, err = readDeprecated(i.msgType, i.data)
+			if err != nil || i.msg == nil {
+				return i.fail("can't read deprecated message %d: %v", i.msgType, err)
+			}
 		} else if err != nil {
 			if strings.HasPrefix(err.Error(), "Unknown message code:") {
 				code := strings.TrimPrefix(err.Error(), "Unknown message code: ")
 				i.msg, err = DecodeExtraMessage(code, i.data)
 				if err != nil {
-					log.Printf("can't decode msg: %s", err)
-					return false
+					return i.fail("can't decode msg: %s", err)
 				}
 			} else {
-				log.Printf("Batch Message decoding error on message with index %v, err: %s", i.index, err)
-				return false
+				return i.fail("Batch Message decoding error on message with index %v, err: %s", i.index, err)
 			}
 		}
-		i.msg = transformDeprecated(i.msg)
+		i.msg = UpgradeMessage(i.version, i.msg)
 	}
 
+	// Deprecated messages are replaced by the current ones or skipped, skipped ones still take their index
+	if IsDeprecated(uint64(i.msg.TypeID())) {
+		msg := replaceDeprecated(i.msg)
+		if msg == nil {
+			i.index++
+			return i.Next()
+		}
+		i.msg = msg
+	}
+	i.msgType = uint64(i.msg.TypeID())
+
 	// Process meta information
 	isBatchMeta := false
 	switch i.msgType {
 	case MsgBatchMetadata:
 		if i.index != 0 { // Might be several 0-0 BatchMeta in a row without an error though
-			log.Printf("Batch Metadata found at the end of the batch")
-			return false
+			return i.skipBatch("Batch Metadata found at the end of the batch")
 		}
 		msg := i.msg.Decode()
 		if msg == nil {
-			return false
+			return i.fail("can't decode message %d", i.msgType)
 		}
 		m := msg.(*BatchMetadata)
 		i.index = m.PageNo<<32 + m.FirstIndex // 2^32  is the maximum count of messages per page (ha-ha)
@@ -104,18 +143,16 @@ func (i *iteratorImpl) Next() bool {
 		i.version = m.Version
 		i.url = m.Url
 		isBatchMeta = true
-		if i.version > 1 {
-			log.Printf("incorrect batch version, skip current batch")
-			return false
+		if !IsSupportedVersion(i.version) {
+			return i.skipBatch("unsupported batch version: %d, skip current batch", i.version)
 		}
 	case MsgBatchMeta: // Is not required to be present in batch since IOS doesn't have it (though we might change it)
 		if i.index != 0 { // Might be several 0-0 BatchMeta in a row without an error though
-			log.Printf("Batch Meta found at the end of the batch")
-			return false
+			return i.skipBatch("Batch Meta found at the end of the batch")
 		}
 		msg := i.msg.Decode()
 		if msg == nil {
-			return false
+			return i.fail("can't decode message %d", i.msgType)
 		}
 		m := msg.(*BatchMeta)
 		i.index = m.PageNo<<32 + m.FirstIndex // 2^32  is the maximum count of messages per page (ha-ha)
@@ -124,12 +161,11 @@ func (i *iteratorImpl) Next() bool {
 		// continue readLoop
 	case MsgIOSBatchMeta:
 		if i.index != 0 { // Might be several 0-0 BatchMeta in a row without an error though
-			log.Printf("Batch Meta found at the end of the batch")
-			return false
+			return i.skipBatch("Batch Meta found at the end of the batch")
 		}
 		msg := i.msg.Decode()
 		if msg == nil {
-			return false
+			return i.fail("can't decode message %d", i.msgType)
 		}
 		m := msg.(*IOSBatchMeta)
 		i.index = m.FirstIndex
@@ -139,7 +175,7 @@ func (i *iteratorImpl) Next() bool {
 	case MsgTimestamp:
 		msg := i.msg.Decode()
 		if msg == nil {
-			return false
+			return i.fail("can't decode message %d", i.msgType)
 		}
 		m := msg.(*Timestamp)
 		i.timestamp = int64(m.Timestamp)
@@ -148,21 +184,21 @@ func (i *iteratorImpl) Next() bool {
 	case MsgSessionStart:
 		msg := i.msg.Decode()
 		if msg == nil {
-			return false
+			return i.fail("can't decode message %d", i.msgType)
 		}
 		m := msg.(*SessionStart)
 		i.timestamp = int64(m.Timestamp)
 	case MsgSessionEnd:
 		msg := i.msg.Decode()
 		if msg == nil {
-			return false
+			return i.fail("can't decode message %d", i.msgType)
 		}
 		m := msg.(*SessionEnd)
 		i.timestamp = int64(m.Timestamp)
 	case MsgSetPageLocation:
 		msg := i.msg.Decode()
 		if msg == nil {
-			return false
+			return i.fail("can't decode message %d", i.msgType)
 		}
 		m := msg.(*SetPageLocation)
 		i.url = m.URL
@@ -177,6 +213,33 @@ func (i *iteratorImpl) Next() bool {
 	return true
 }
 
+// fail stops the iteration, the error is returned by Err
+func (i *iteratorImpl) fail(format string, args ...interface{}) bool {
+	i.err = fmt.Errorf(format, args...)
+	log.Print(i.err)
+	return false
+}
+
+// SkippedBatchError stops the iteration of batches which are skipped on purpose (unsupported version or
+// meta in the middle of the batch), they aren't broken and reading them again gives the same result
+type SkippedBatchError struct {
+	reason string
+}
+
+func (e *SkippedBatchError) Error() string {
+	return e.reason
+}
+
+func (i *iteratorImpl) skipBatch(format string, args ...interface{}) bool {
+	i.err = &SkippedBatchError{reason: fmt.Sprintf(format, args...)}
+	log.Print(i.err)
+	return false
+}
+
+func (i *iteratorImpl) Err() error {
+	return i.err
+}
+
 func (i *iteratorImpl) Type() int {
 	return int(i.msgType)
 }
@@ -186,7 +249,13 @@ func (i *iteratorImpl) Message() Message {
 }
 
 func (i *iteratorImpl) Close() {
-	_, err := i.data.Seek(0, io.SeekEnd)
+	seeker, ok := i.data.(io.Seeker)
+	if !ok {
+		// Rest of the stream isn't read at all
+		i.data, i.canSkip = bytes.NewReader(nil), false
+		return
+	}
+	_, err := seeker.Seek(0, io.SeekEnd)
 	if err != nil {
 		log.Printf("can't set seek pointer at the end: %s", err)
 	}
@@ -0,0 +1,165 @@
+package messages
+
+import (
+	"time"
+)
+
+type sessionClock struct {
+	skew    int64 // smallest difference between the receive time and the client time
+	offset  int64 // applied shift, 0 if the skew is under the threshold
+	updated time.Time
+}
+
+// ClockNormalizer moves timestamps of tracker messages to the server clock for sessions with wrong client clocks.
+// The first timestamp of the batch (or of the session start) is older than the receive time of the batch by the
+// batching delay and the network latency only, so the smallest difference seen by the session is the skew of
+// its clock. Skews under the threshold are kept as is, so correct clocks aren't touched by latency jitter.
+// Every consumer of the same records gets the same timestamps, the estimate only improves with new batches.
+type ClockNormalizer struct {
+	threshold   int64
+	idleTimeout time.Duration
+	onShift     func(offset int64)
+	sessions    map[uint64]*sessionClock
+}
+
+// NewClockNormalizer calls onShift with the new offset of the session every time it changes
+func NewClockNormalizer(threshold, idleTimeout time.Duration, onShift func(offset int64)) *ClockNormalizer {
+	if onShift == nil {
+		onShift = func(int64) {}
+	}
+	return &ClockNormalizer{
+		threshold:   threshold.Milliseconds(),
+		idleTimeout: idleTimeout,
+		onShift:     onShift,
+		sessions:    make(map[uint64]*sessionClock),
+	}
+}
+
+// isClockSample is true for messages which are created by the tracker right before sending them
+func isClockSample(msgType int) bool {
+	return msgType == MsgBatchMetadata || msgType == MsgBatchMeta || msgType == MsgSessionStart
+}
+
+func (n *ClockNormalizer) sample(sessionID uint64, clientTime, receivedAt int64) {
+	if clientTime <= 0 || receivedAt <= 0 {
+		return
+	}
+	skew := receivedAt - clientTime
+	s, ok := n.sessions[sessionID]
+	if !ok {
+		s = &sessionClock{skew: skew}
+		n.sessions[sessionID] = s
+	}
+	s.updated = time.Now()
+	if skew < s.skew {
+		s.skew = skew
+	}
+	offset := s.skew
+	if offset <= n.threshold && offset >= -n.threshold {
+		offset = 0
+	}
+	if offset != s.offset {
+		s.offset = offset
+		n.onShift(offset)
+	}
+}
+
+func shiftTime(ts uint64, offset int64) uint64 {
+	if ts == 0 || int64(ts)+offset <= 0 {
+		return ts
+	}
+	return uint64(int64(ts) + offset)
+}
+
+// shift returns the decoded message with the corrected time, messages of sessions with correct clocks aren't decoded
+func (n *ClockNormalizer) shift(sessionID uint64, msg Message) Message {
+	s, ok := n.sessions[sessionID]
+	if !ok || s.offset == 0 || msg.Meta().Timestamp == 0 || IsIOSType(msg.TypeID()) {
+		return msg
+	}
+	s.updated = time.Now()
+	decoded := msg.Decode()
+	if decoded == nil {
+		return msg
+	}
+	// Tracker messages with their own time, other ones are placed by the meta
+	switch m := decoded.(type) {
+	case *Timestamp:
+		m.Timestamp = shiftTime(m.Timestamp, s.offset)
+	case *BatchMetadata:
+		m.Timestamp = int64(shiftTime(uint64(m.Timestamp), s.offset))
+	case *BatchMeta:
+		m.Timestamp = int64(shiftTime(uint64(m.Timestamp), s.offset))
+	case *SessionStart:
+		m.Timestamp = shiftTime(m.Timestamp, s.offset)
+	case *SessionEnd:
+		m.Timestamp = shiftTime(m.Timestamp, s.offset)
+	case *Fetch:
+		m.Timestamp = shiftTime(m.Timestamp, s.offset)
+	case *ResourceTiming:
+		m.Timestamp = shiftTime(m.Timestamp, s.offset)
+	case *LongTask:
+		m.Timestamp = shiftTime(m.Timestamp, s.offset)
+	case *CanvasSnapshot:
+		m.Timestamp = shiftTime(m.Timestamp, s.offset)
+	}
+	decoded.Meta().Timestamp = int64(shiftTime(uint64(decoded.Meta().Timestamp), s.offset))
+	return decoded
+}
+
+// Wrap returns the iterator over the record received at receivedAt (unix ms, the time of the queue record).
+// Nil normalizer returns the same iterator, so the correction can be disabled.
+func (n *ClockNormalizer) Wrap(sessionID uint64, iter Iterator, receivedAt int64) Iterator {
+	if n == nil {
+		return iter
+	}
+	return &normalizingIterator{Iterator: iter, normalizer: n, sessionID: sessionID, receivedAt: receivedAt}
+}
+
+// Delete is called at the end of the session
+func (n *ClockNormalizer) Delete(sessionID uint64) {
+	if n == nil {
+		return
+	}
+	delete(n.sessions, sessionID)
+}
+
+// Cleanup removes sessions which ended without the SessionEnd message
+func (n *ClockNormalizer) Cleanup() {
+	if n == nil {
+		return
+	}
+	now := time.Now()
+	for sessionID, s := range n.sessions {
+		if now.Sub(s.updated) > n.idleTimeout {
+			delete(n.sessions, sessionID)
+		}
+	}
+}
+
+type normalizingIterator struct {
+	Iterator
+	normalizer *ClockNormalizer
+	sessionID  uint64
+	receivedAt int64
+	started    bool
+	msg        Message
+}
+
+func (i *normalizingIterator) Next() bool {
+	if !i.Iterator.Next() {
+		return false
+	}
+	msg := i.Iterator.Message()
+	// Only the first message of the record is sent right after it's created
+	if !i.started && isClockSample(msg.TypeID()) {
+		i.normalizer.sample(i.sessionID, msg.Meta().Timestamp, i.receivedAt)
+	}
+	i.started = true
+	i.msg = i.normalizer.shift(i.sessionID, msg)
+	return true
+}
+
+func (i *normalizingIterator) Message() Message {
+	return i.msg
+}
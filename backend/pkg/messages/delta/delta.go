@@ -0,0 +1,227 @@
+package delta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"openreplay/backend/pkg/messages"
+)
+
+// Encoded session file starts with magic instead of the index of the first message,
+// as index it would be bigger than any real one
+var magic = []byte{0xFF, 'O', 'R', 'D', 'E', 'L', 'T', 'A'}
+
+const (
+	recordRaw  byte = 0 // uvarint length, original bytes
+	recordCopy byte = 1 // uvarint source message number, uvarint count, uvarint index of the first message
+)
+
+const indexSize = 8
+
+// span is a position of the message with index in the session file
+type span struct {
+	start int
+	end   int
+	index uint64
+}
+
+func (s span) body(data []byte) []byte {
+	return data[s.start+indexSize : s.end]
+}
+
+// parseMessages returns positions of all whole web messages from the beginning of data,
+// the unparsed tail is either a part of the message or a non-web file
+func parseMessages(data []byte, from int, spans []span) ([]span, int) {
+	pos := from
+	for len(data)-pos > indexSize {
+		tp := data[pos+indexSize]
+		if messages.IsIOSType(int(tp)) {
+			break
+		}
+		reader := bytes.NewReader(data[pos+indexSize+1:])
+		if _, err := messages.ReadMessage(uint64(tp), reader); err != nil {
+			break
+		}
+		end := len(data) - reader.Len()
+		spans = append(spans, span{
+			start: pos,
+			end:   end,
+			index: binary.LittleEndian.Uint64(data[pos:]),
+		})
+		pos = end
+	}
+	return spans, pos
+}
+
+func isSnapshotStart(data []byte, s span) bool {
+	return data[s.start+indexSize] == messages.MsgCreateDocument
+}
+
+// IsEncoded checks the beginning of the session file
+func IsEncoded(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// Encode replaces runs of messages repeating the previous DOM snapshot with references to it.
+// Returns false if there is nothing to replace, the file should be stored as is in this case.
+func Encode(data []byte, minRun int) ([]byte, bool) {
+	if minRun < 1 {
+		minRun = 1
+	}
+	spans, _ := parseMessages(data, 0, nil)
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)/2))
+	out.Write(magic)
+	varint := make([]byte, binary.MaxVarintLen64)
+	writeUint := func(v uint64) {
+		out.Write(varint[:binary.PutUvarint(varint, v)])
+	}
+	writeRaw := func(from, to int) {
+		if from >= to {
+			return
+		}
+		out.WriteByte(recordRaw)
+		writeUint(uint64(to - from))
+		out.Write(data[from:to])
+	}
+
+	replaced := false
+	rawStart := 0
+	prevStart, prevEnd, curStart := -1, -1, -1 // message numbers of the previous and the current snapshots
+	var positions map[string][]int             // message body -> message numbers in the previous snapshot
+	cursor := -1                               // next message of the previous snapshot after the last match
+	for i := 0; i < len(spans); {
+		if isSnapshotStart(data, spans[i]) {
+			if curStart >= 0 {
+				prevStart, prevEnd = curStart, i
+				positions = make(map[string][]int, prevEnd-prevStart)
+				for j := prevStart; j < prevEnd; j++ {
+					key := string(spans[j].body(data))
+					positions[key] = append(positions[key], j)
+				}
+				cursor = prevStart
+			}
+			curStart = i
+		}
+		if prevStart < 0 {
+			i++
+			continue
+		}
+
+		// The message following the previous match is the most probable source
+		candidates := positions[string(spans[i].body(data))]
+		if cursor < prevEnd && bytes.Equal(spans[cursor].body(data), spans[i].body(data)) {
+			candidates = append([]int{cursor}, candidates...)
+		}
+		bestSrc, bestLen := -1, 0
+		for n, src := range candidates {
+			if n > 8 {
+				break
+			}
+			length := matchLength(data, spans, src, i, prevEnd)
+			if length > bestLen {
+				bestSrc, bestLen = src, length
+			}
+		}
+		if bestLen < minRun {
+			i++
+			continue
+		}
+
+		writeRaw(rawStart, spans[i].start)
+		out.WriteByte(recordCopy)
+		writeUint(uint64(bestSrc))
+		writeUint(uint64(bestLen))
+		writeUint(spans[i].index)
+		replaced = true
+		cursor = bestSrc + bestLen
+		i += bestLen
+		if i < len(spans) {
+			rawStart = spans[i].start
+		} else {
+			rawStart = spans[len(spans)-1].end
+		}
+	}
+	if !replaced {
+		return nil, false
+	}
+	writeRaw(rawStart, len(data))
+	return out.Bytes(), out.Len() < len(data)
+}
+
+// matchLength counts equal messages, indexes of the copied messages have to be sequential
+func matchLength(data []byte, spans []span, src, dst, srcEnd int) int {
+	length := 0
+	for src+length < srcEnd && dst+length < len(spans) {
+		if spans[dst+length].index != spans[dst].index+uint64(length) {
+			break
+		}
+		if length > 0 && isSnapshotStart(data, spans[dst+length]) {
+			break
+		}
+		if !bytes.Equal(spans[src+length].body(data), spans[dst+length].body(data)) {
+			break
+		}
+		length++
+	}
+	return length
+}
+
+// Decode restores the original session file, not encoded data is returned as is
+func Decode(data []byte) ([]byte, error) {
+	if !IsEncoded(data) {
+		return data, nil
+	}
+	reader := bytes.NewReader(data[len(magic):])
+	out := make([]byte, 0, len(data)*2)
+	var spans []span
+	parsed := 0
+	for reader.Len() > 0 {
+		tag, _ := reader.ReadByte()
+		switch tag {
+		case recordRaw:
+			size, err := binary.ReadUvarint(reader)
+			if err != nil {
+				return nil, fmt.Errorf("can't read raw record size: %s", err)
+			}
+			if size > uint64(reader.Len()) {
+				return nil, errors.New("raw record is out of data")
+			}
+			start := len(data) - reader.Len()
+			out = append(out, data[start:start+int(size)]...)
+			reader.Seek(int64(size), io.SeekCurrent)
+			spans, parsed = parseMessages(out, parsed, spans)
+		case recordCopy:
+			src, err := binary.ReadUvarint(reader)
+			if err != nil {
+				return nil, fmt.Errorf("can't read copy source: %s", err)
+			}
+			count, err := binary.ReadUvarint(reader)
+			if err != nil {
+				return nil, fmt.Errorf("can't read copy count: %s", err)
+			}
+			index, err := binary.ReadUvarint(reader)
+			if err != nil {
+				return nil, fmt.Errorf("can't read copy index: %s", err)
+			}
+			if parsed != len(out) || src+count > uint64(len(spans)) {
+				return nil, fmt.Errorf("wrong copy source: %d, count: %d, messages: %d", src, count, len(spans))
+			}
+			buf := make([]byte, indexSize)
+			for n := uint64(0); n < count; n++ {
+				binary.LittleEndian.PutUint64(buf, index+n)
+				start := len(out)
+				out = append(out, buf...)
+				out = append(out, spans[src+n].body(out)...)
+				spans = append(spans, span{start: start, end: len(out), index: index + n})
+			}
+			parsed = len(out)
+		default:
+			return nil, fmt.Errorf("unknown record: %d", tag)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,98 @@
+package delta
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"openreplay/backend/pkg/fixtures"
+	"openreplay/backend/pkg/messages"
+)
+
+// snapshots returns the session file where the first page snapshot is recorded again count times,
+// every repetition has new indexes and misses one message, like after the restart of the tracker
+func snapshots(t *testing.T, count int) []byte {
+	t.Helper()
+	g, err := fixtures.New(fixtures.Config{
+		SessionID:        1,
+		StartTimestamp:   1660000000000,
+		Duration:         time.Minute,
+		Batches:          4,
+		MessagesPerBatch: 100,
+		Mix: map[int]int{
+			messages.MsgCreateElementNode: 5,
+			messages.MsgCreateTextNode:    2,
+			messages.MsgSetNodeAttribute:  3,
+			messages.MsgSetNodeData:       1,
+		},
+		Seed: 7,
+	})
+	if err != nil {
+		t.Fatalf("can't create generator: %s", err)
+	}
+	sess := g.Generate()
+	data := sess.Mob()
+	var snapshot []messages.Message
+	for _, msg := range sess.Messages {
+		if messages.IsReplayerType(msg.TypeID()) {
+			snapshot = append(snapshot, msg)
+		}
+	}
+	index := snapshot[len(snapshot)-1].Meta().Index + 1
+	for n := 1; n <= count; n++ {
+		skip := n * len(snapshot) / (count + 1)
+		for i, msg := range snapshot {
+			if i == skip {
+				continue
+			}
+			msg.Meta().Index = index
+			data = append(data, msg.EncodeWithIndex()...)
+			index++
+		}
+	}
+	return data
+}
+
+func TestSingleSnapshotIsNotEncoded(t *testing.T) {
+	if _, ok := Encode(snapshots(t, 0), 1); ok {
+		t.Fatal("file without repeated snapshots is encoded")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	data := snapshots(t, 3)
+	for _, minRun := range []int{1, 4, 16} {
+		encoded, ok := Encode(data, minRun)
+		if !ok {
+			t.Fatalf("minRun %d: repeated snapshots aren't encoded", minRun)
+		}
+		if !IsEncoded(encoded) {
+			t.Fatalf("minRun %d: encoded file has no magic", minRun)
+		}
+		decoded, err := Decode(encoded)
+		if err != nil {
+			t.Fatalf("minRun %d: can't decode: %s", minRun, err)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Fatalf("minRun %d: decoded file differs from the original one", minRun)
+		}
+	}
+}
+
+func TestDecodeNotEncoded(t *testing.T) {
+	data := snapshots(t, 1)
+	decoded, err := Decode(data)
+	if err != nil || !bytes.Equal(decoded, data) {
+		t.Fatalf("not encoded file is changed by decoding, err: %v", err)
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	encoded, ok := Encode(snapshots(t, 2), 1)
+	if !ok {
+		t.Fatal("repeated snapshots aren't encoded")
+	}
+	if _, err := Decode(encoded[:len(encoded)-1]); err == nil {
+		t.Fatal("truncated file is decoded without error")
+	}
+}
@@ -0,0 +1,171 @@
+package messages
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Actions applied to deprecated messages, they are passed to the deprecation callback
+const (
+	DeprecationReplaced = "replaced"
+	DeprecationSkipped  = "skipped"
+)
+
+// FieldKind is the encoding of one field of a removed message type
+type FieldKind int
+
+const (
+	FieldUint FieldKind = iota
+	FieldInt
+	FieldString
+	FieldBoolean
+)
+
+// Deprecation tells decoders what to do with messages of a deprecated type, so old sessions and
+// old trackers keep working after schema changes
+type Deprecation struct {
+	// Fields is the layout of types which were removed from the schema. Messages without sizes can't be
+	// skipped otherwise. Types which are still in the schema are decoded as usual and don't need it.
+	Fields []FieldKind
+	// Replace returns the message of the current schema, nil Replace skips messages of the type
+	Replace func(msg Message) Message
+}
+
+var (
+	deprecations = map[uint64]*Deprecation{
+		MsgMouseClickDepricated: {Replace: transformDeprecated},
+	}
+	deprecationsMutex sync.RWMutex
+	onDeprecated      = func(msgType int, action string) {}
+)
+
+// Deprecate registers the type, it has to be called before decoding starts
+func Deprecate(msgType uint64, d *Deprecation) {
+	deprecationsMutex.Lock()
+	defer deprecationsMutex.Unlock()
+	deprecations[msgType] = d
+}
+
+// OnDeprecated sets the callback called for every replaced or skipped message, it's used for metrics
+func OnDeprecated(fn func(msgType int, action string)) {
+	deprecationsMutex.Lock()
+	defer deprecationsMutex.Unlock()
+	onDeprecated = fn
+}
+
+func getDeprecation(msgType uint64) (*Deprecation, func(int, string)) {
+	deprecationsMutex.RLock()
+	defer deprecationsMutex.RUnlock()
+	return deprecations[msgType], onDeprecated
+}
+
+// IsDeprecated is checked before decoding, other messages stay raw
+func IsDeprecated(msgType uint64) bool {
+	d, _ := getDeprecation(msgType)
+	return d != nil
+}
+
+// readDeprecated reads the message of the removed type, nil message means there is no layout for it
+func readDeprecated(msgType uint64, reader io.Reader) (Message, error) {
+	d, _ := getDeprecation(msgType)
+	if d == nil || len(d.Fields) == 0 {
+		return nil, nil
+	}
+	msg := &LegacyMessage{Type: msgType, Fields: d.Fields, Values: make([]interface{}, len(d.Fields))}
+	var err error
+	for n, kind := range d.Fields {
+		switch kind {
+		case FieldUint:
+			msg.Values[n], err = ReadUint(reader)
+		case FieldInt:
+			msg.Values[n], err = ReadInt(reader)
+		case FieldString:
+			msg.Values[n], err = ReadString(reader)
+		case FieldBoolean:
+			msg.Values[n], err = ReadBoolean(reader)
+		default:
+			err = fmt.Errorf("unknown field kind: %d", kind)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("can't read field %d of deprecated message %d: %s", n, msgType, err)
+		}
+	}
+	return msg, nil
+}
+
+// replaceDeprecated returns nil if the message has to be skipped, other messages are returned as is.
+// Deprecated types which are still in the schema are decoded here.
+func replaceDeprecated(msg Message) Message {
+	d, callback := getDeprecation(uint64(msg.TypeID()))
+	if d == nil {
+		return msg
+	}
+	if d.Replace == nil {
+		callback(msg.TypeID(), DeprecationSkipped)
+		return nil
+	}
+	decoded := msg.Decode()
+	if decoded == nil {
+		callback(msg.TypeID(), DeprecationSkipped)
+		return nil
+	}
+	newMsg := d.Replace(decoded)
+	if newMsg == nil {
+		callback(msg.TypeID(), DeprecationSkipped)
+		return nil
+	}
+	newMsg.Meta().SetMeta(msg.Meta())
+	callback(msg.TypeID(), DeprecationReplaced)
+	return newMsg
+}
+
+// LegacyMessage keeps values of the removed message type in the order of its fields
+type LegacyMessage struct {
+	message
+	Type   uint64
+	Fields []FieldKind
+	Values []interface{}
+}
+
+func (msg *LegacyMessage) Encode() []byte {
+	size := 11
+	for _, v := range msg.Values {
+		size += 10
+		if s, ok := v.(string); ok {
+			size += len(s)
+		}
+	}
+	buf := make([]byte, size)
+	p := WriteUint(msg.Type, buf, 0)
+	for n, kind := range msg.Fields {
+		switch kind {
+		case FieldUint:
+			p = WriteUint(msg.Values[n].(uint64), buf, p)
+		case FieldInt:
+			p = WriteInt(msg.Values[n].(int64), buf, p)
+		case FieldString:
+			p = WriteString(msg.Values[n].(string), buf, p)
+		case FieldBoolean:
+			p = WriteBoolean(msg.Values[n].(bool), buf, p)
+		}
+	}
+	return buf[:p]
+}
+
+func (msg *LegacyMessage) EncodeWithIndex() []byte {
+	encoded := msg.Encode()
+	data := make([]byte, len(encoded)+8)
+	copy(data[8:], encoded[:])
+	binary.LittleEndian.PutUint64(data[0:], msg.Meta().Index)
+	return data
+}
+
+func (msg *LegacyMessage) Decode() Message {
+	return msg
+}
+
+func (msg *LegacyMessage) TypeID() int {
+	return int(msg.Type)
+}
@@ -58,6 +58,15 @@ func GetTimestamp(message Message) uint64 {
 	case *IOSIssueEvent:
 		return msg.Timestamp
 
+	case *MobileViewHierarchy:
+		return msg.Timestamp
+
+	case *MobileTouchEvent:
+		return msg.Timestamp
+
+	case *MobileLifecycleEvent:
+		return msg.Timestamp
+
 	}
 	return uint64(message.Meta().Timestamp)
 }
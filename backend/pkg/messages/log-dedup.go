@@ -0,0 +1,181 @@
+package messages
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+	"unicode/utf8"
+)
+
+// maxSummaryValueSize limits the text of the repeated message which is kept for the summary
+const maxSummaryValueSize = 256
+
+type repeatedLog struct {
+	start    int64 // timestamp of the first message of the window
+	level    string
+	value    string
+	repeated int
+}
+
+type sessionLogs struct {
+	logs    map[uint64]*repeatedLog
+	swept   int64
+	updated time.Time
+}
+
+// LogDeduplicator collapses identical console logs and js exceptions which the session repeats within
+// the window. The first message is kept, the repeated ones are counted and reported by one ConsoleLog
+// summary when the window is over, so log storms don't bloat replays and error analytics.
+// Windows are measured by message timestamps, so the result doesn't depend on the consumer lag.
+type LogDeduplicator struct {
+	window      int64
+	maxKeys     int
+	idleTimeout time.Duration
+	onCollapse  func(msgType int)
+	sessions    map[uint64]*sessionLogs
+}
+
+// NewLogDeduplicator keeps at most maxKeys different messages per session, others pass as is
+func NewLogDeduplicator(window time.Duration, maxKeys int, idleTimeout time.Duration, onCollapse func(msgType int)) *LogDeduplicator {
+	if onCollapse == nil {
+		onCollapse = func(int) {}
+	}
+	return &LogDeduplicator{
+		window:      window.Milliseconds(),
+		maxKeys:     maxKeys,
+		idleTimeout: idleTimeout,
+		onCollapse:  onCollapse,
+		sessions:    make(map[uint64]*sessionLogs),
+	}
+}
+
+func truncateSummary(value string) string {
+	if len(value) <= maxSummaryValueSize {
+		return value
+	}
+	n := maxSummaryValueSize
+	for n > 0 && !utf8.RuneStart(value[n]) {
+		n--
+	}
+	return value[:n] + "..."
+}
+
+// logKey returns the hash of the log message, level and text of its summary, false for other messages
+func logKey(msg Message) (uint64, string, string, bool) {
+	if msg.TypeID() != MsgConsoleLog && msg.TypeID() != MsgJSException {
+		return 0, "", "", false
+	}
+	h := fnv.New64a()
+	switch m := msg.Decode().(type) {
+	case *ConsoleLog:
+		h.Write([]byte(fmt.Sprintf("%d\x00%s\x00%s", MsgConsoleLog, m.Level, m.Value)))
+		return h.Sum64(), m.Level, truncateSummary(m.Value), true
+	case *JSException:
+		h.Write([]byte(fmt.Sprintf("%d\x00%s\x00%s\x00%s", MsgJSException, m.Name, m.Message, m.Payload)))
+		return h.Sum64(), "error", truncateSummary(m.Name + ": " + m.Message), true
+	}
+	return 0, "", "", false
+}
+
+// summary has the meta of the message which closes the window, so readers don't skip it as out of order
+func (l *repeatedLog) summary(meta *message) Message {
+	msg := &ConsoleLog{
+		Level: l.level,
+		Value: fmt.Sprintf("%s (repeated %d more times)", l.value, l.repeated),
+	}
+	msg.SetMeta(meta)
+	return msg
+}
+
+// sweep removes logs with finished windows and returns summaries of the repeated ones
+func (s *sessionLogs) sweep(ts, window int64, meta *message) []Message {
+	var res []Message
+	for key, l := range s.logs {
+		if ts-l.start < window {
+			continue
+		}
+		if l.repeated > 0 {
+			res = append(res, l.summary(meta))
+		}
+		delete(s.logs, key)
+	}
+	s.swept = ts
+	return res
+}
+
+// Apply returns messages to process instead of the given one: summaries of finished windows and the message
+// itself if it isn't a repetition. Nil deduplicator returns the message as is, so deduplication can be disabled.
+func (d *LogDeduplicator) Apply(sessionID uint64, msg Message) []Message {
+	if d == nil {
+		return []Message{msg}
+	}
+	ts := msg.Meta().Timestamp
+	s, ok := d.sessions[sessionID]
+	if !ok {
+		s = &sessionLogs{logs: make(map[uint64]*repeatedLog), swept: ts}
+		d.sessions[sessionID] = s
+	}
+	s.updated = time.Now()
+
+	var res []Message
+	if ts-s.swept >= d.window && len(s.logs) > 0 {
+		res = s.sweep(ts, d.window, msg.Meta())
+	}
+	key, level, value, ok := logKey(msg)
+	if !ok || ts == 0 {
+		return append(res, msg)
+	}
+	if l, ok := s.logs[key]; ok {
+		if ts >= l.start && ts-l.start < d.window {
+			l.repeated++
+			d.onCollapse(msg.TypeID())
+			return res
+		}
+		if l.repeated > 0 {
+			res = append(res, l.summary(msg.Meta()))
+		}
+		delete(s.logs, key)
+	}
+	if len(s.logs) < d.maxKeys {
+		s.logs[key] = &repeatedLog{start: ts, level: level, value: value}
+	}
+	return append(res, msg)
+}
+
+// Skip reports whether the message is a repetition, it's used by services which don't save summaries
+func (d *LogDeduplicator) Skip(sessionID uint64, msg Message) bool {
+	res := d.Apply(sessionID, msg)
+	return len(res) == 0 || res[len(res)-1] != msg
+}
+
+// Flush is called at the end of the session, it returns summaries of unfinished windows with the meta of the last message
+func (d *LogDeduplicator) Flush(sessionID uint64, last Message) []Message {
+	if d == nil {
+		return nil
+	}
+	s, ok := d.sessions[sessionID]
+	if !ok {
+		return nil
+	}
+	delete(d.sessions, sessionID)
+	var res []Message
+	for _, l := range s.logs {
+		if l.repeated > 0 {
+			res = append(res, l.summary(last.Meta()))
+		}
+	}
+	return res
+}
+
+// Cleanup removes sessions which didn't send messages for the idle timeout, their counters are lost
+func (d *LogDeduplicator) Cleanup() {
+	if d == nil {
+		return
+	}
+	now := time.Now()
+	for sessionID, s := range d.sessions {
+		if now.Sub(s.updated) > d.idleTimeout {
+			delete(d.sessions, sessionID)
+		}
+	}
+}
@@ -158,6 +158,18 @@ const (
 
 	MsgZustand = 79
 
+	MsgAssistEvent = 112
+
+	MsgCanvasSnapshot = 116
+
+	MsgCanvasNode = 117
+
+	MsgStringDict = 118
+
+	MsgSetNodeAttributeDict = 119
+
+	MsgPluginIssue = 120
+
 	MsgIOSBatchMeta = 107
 
 	MsgIOSSessionStart = 90
@@ -195,6 +207,12 @@ const (
 	MsgIOSPerformanceAggregated = 110
 
 	MsgIOSIssueEvent = 111
+
+	MsgMobileViewHierarchy = 113
+
+	MsgMobileTouchEvent = 114
+
+	MsgMobileLifecycleEvent = 115
 )
 
 type BatchMeta struct {
@@ -3074,6 +3092,236 @@ func (msg *Zustand) TypeID() int {
 	return 79
 }
 
+type AssistEvent struct {
+	message
+	Timestamp uint64
+	AgentID   uint64
+	AgentName string
+	Type      string
+	Payload   string
+}
+
+func (msg *AssistEvent) Encode() []byte {
+	buf := make([]byte, 51+len(msg.AgentName)+len(msg.Type)+len(msg.Payload))
+	buf[0] = 112
+	p := 1
+	p = WriteUint(msg.Timestamp, buf, p)
+	p = WriteUint(msg.AgentID, buf, p)
+	p = WriteString(msg.AgentName, buf, p)
+	p = WriteString(msg.Type, buf, p)
+	p = WriteString(msg.Payload, buf, p)
+	return buf[:p]
+}
+
+func (msg *AssistEvent) EncodeWithIndex() []byte {
+	encoded := msg.Encode()
+	if IsIOSType(msg.TypeID()) {
+		return encoded
+	}
+	data := make([]byte, len(encoded)+8)
+	copy(data[8:], encoded[:])
+	binary.LittleEndian.PutUint64(data[0:], msg.Meta().Index)
+	return data
+}
+
+func (msg *AssistEvent) Decode() Message {
+	return msg
+}
+
+func (msg *AssistEvent) TypeID() int {
+	return 112
+}
+
+type CanvasSnapshot struct {
+	message
+	NodeID    uint64
+	Timestamp uint64
+	Format    string
+	Data      string
+}
+
+func (msg *CanvasSnapshot) Encode() []byte {
+	buf := make([]byte, 41+len(msg.Format)+len(msg.Data))
+	buf[0] = 116
+	p := 1
+	p = WriteUint(msg.NodeID, buf, p)
+	p = WriteUint(msg.Timestamp, buf, p)
+	p = WriteString(msg.Format, buf, p)
+	p = WriteString(msg.Data, buf, p)
+	return buf[:p]
+}
+
+func (msg *CanvasSnapshot) EncodeWithIndex() []byte {
+	encoded := msg.Encode()
+	if IsIOSType(msg.TypeID()) {
+		return encoded
+	}
+	data := make([]byte, len(encoded)+8)
+	copy(data[8:], encoded[:])
+	binary.LittleEndian.PutUint64(data[0:], msg.Meta().Index)
+	return data
+}
+
+func (msg *CanvasSnapshot) Decode() Message {
+	return msg
+}
+
+func (msg *CanvasSnapshot) TypeID() int {
+	return 116
+}
+
+type CanvasNode struct {
+	message
+	NodeID    uint64
+	Timestamp uint64
+	Format    string
+	Size      uint64
+}
+
+func (msg *CanvasNode) Encode() []byte {
+	buf := make([]byte, 41+len(msg.Format))
+	buf[0] = 117
+	p := 1
+	p = WriteUint(msg.NodeID, buf, p)
+	p = WriteUint(msg.Timestamp, buf, p)
+	p = WriteString(msg.Format, buf, p)
+	p = WriteUint(msg.Size, buf, p)
+	return buf[:p]
+}
+
+func (msg *CanvasNode) EncodeWithIndex() []byte {
+	encoded := msg.Encode()
+	if IsIOSType(msg.TypeID()) {
+		return encoded
+	}
+	data := make([]byte, len(encoded)+8)
+	copy(data[8:], encoded[:])
+	binary.LittleEndian.PutUint64(data[0:], msg.Meta().Index)
+	return data
+}
+
+func (msg *CanvasNode) Decode() Message {
+	return msg
+}
+
+func (msg *CanvasNode) TypeID() int {
+	return 117
+}
+
+type StringDict struct {
+	message
+	Key          uint64
+	BaseKey      uint64
+	PrefixLength uint64
+	Suffix       string
+}
+
+func (msg *StringDict) Encode() []byte {
+	buf := make([]byte, 41+len(msg.Suffix))
+	buf[0] = 118
+	p := 1
+	p = WriteUint(msg.Key, buf, p)
+	p = WriteUint(msg.BaseKey, buf, p)
+	p = WriteUint(msg.PrefixLength, buf, p)
+	p = WriteString(msg.Suffix, buf, p)
+	return buf[:p]
+}
+
+func (msg *StringDict) EncodeWithIndex() []byte {
+	encoded := msg.Encode()
+	if IsIOSType(msg.TypeID()) {
+		return encoded
+	}
+	data := make([]byte, len(encoded)+8)
+	copy(data[8:], encoded[:])
+	binary.LittleEndian.PutUint64(data[0:], msg.Meta().Index)
+	return data
+}
+
+func (msg *StringDict) Decode() Message {
+	return msg
+}
+
+func (msg *StringDict) TypeID() int {
+	return 118
+}
+
+type SetNodeAttributeDict struct {
+	message
+	ID       uint64
+	NameKey  uint64
+	ValueKey uint64
+}
+
+func (msg *SetNodeAttributeDict) Encode() []byte {
+	buf := make([]byte, 31)
+	buf[0] = 119
+	p := 1
+	p = WriteUint(msg.ID, buf, p)
+	p = WriteUint(msg.NameKey, buf, p)
+	p = WriteUint(msg.ValueKey, buf, p)
+	return buf[:p]
+}
+
+func (msg *SetNodeAttributeDict) EncodeWithIndex() []byte {
+	encoded := msg.Encode()
+	if IsIOSType(msg.TypeID()) {
+		return encoded
+	}
+	data := make([]byte, len(encoded)+8)
+	copy(data[8:], encoded[:])
+	binary.LittleEndian.PutUint64(data[0:], msg.Meta().Index)
+	return data
+}
+
+func (msg *SetNodeAttributeDict) Decode() Message {
+	return msg
+}
+
+func (msg *SetNodeAttributeDict) TypeID() int {
+	return 119
+}
+
+type PluginIssue struct {
+	message
+	Plugin        string
+	Type          string
+	Severity      string
+	ContextString string
+	Payload       string
+}
+
+func (msg *PluginIssue) Encode() []byte {
+	buf := make([]byte, 51+len(msg.Plugin)+len(msg.Type)+len(msg.Severity)+len(msg.ContextString)+len(msg.Payload))
+	buf[0] = 120
+	p := 1
+	p = WriteString(msg.Plugin, buf, p)
+	p = WriteString(msg.Type, buf, p)
+	p = WriteString(msg.Severity, buf, p)
+	p = WriteString(msg.ContextString, buf, p)
+	p = WriteString(msg.Payload, buf, p)
+	return buf[:p]
+}
+
+func (msg *PluginIssue) EncodeWithIndex() []byte {
+	encoded := msg.Encode()
+	if IsIOSType(msg.TypeID()) {
+		return encoded
+	}
+	data := make([]byte, len(encoded)+8)
+	copy(data[8:], encoded[:])
+	binary.LittleEndian.PutUint64(data[0:], msg.Meta().Index)
+	return data
+}
+
+func (msg *PluginIssue) Decode() Message {
+	return msg
+}
+
+func (msg *PluginIssue) TypeID() int {
+	return 120
+}
+
 type IOSBatchMeta struct {
 	message
 	Timestamp  uint64
@@ -3835,3 +4083,125 @@ func (msg *IOSIssueEvent) Decode() Message {
 func (msg *IOSIssueEvent) TypeID() int {
 	return 111
 }
+
+type MobileViewHierarchy struct {
+	message
+	Timestamp  uint64
+	Length     uint64
+	ScreenName string
+	Width      uint64
+	Height     uint64
+	Tree       string
+}
+
+func (msg *MobileViewHierarchy) Encode() []byte {
+	buf := make([]byte, 61+len(msg.ScreenName)+len(msg.Tree))
+	buf[0] = 113
+	p := 1
+	p = WriteUint(msg.Timestamp, buf, p)
+	p = WriteUint(msg.Length, buf, p)
+	p = WriteString(msg.ScreenName, buf, p)
+	p = WriteUint(msg.Width, buf, p)
+	p = WriteUint(msg.Height, buf, p)
+	p = WriteString(msg.Tree, buf, p)
+	return buf[:p]
+}
+
+func (msg *MobileViewHierarchy) EncodeWithIndex() []byte {
+	encoded := msg.Encode()
+	if IsIOSType(msg.TypeID()) {
+		return encoded
+	}
+	data := make([]byte, len(encoded)+8)
+	copy(data[8:], encoded[:])
+	binary.LittleEndian.PutUint64(data[0:], msg.Meta().Index)
+	return data
+}
+
+func (msg *MobileViewHierarchy) Decode() Message {
+	return msg
+}
+
+func (msg *MobileViewHierarchy) TypeID() int {
+	return 113
+}
+
+type MobileTouchEvent struct {
+	message
+	Timestamp uint64
+	Length    uint64
+	Phase     string
+	PointerID uint64
+	X         uint64
+	Y         uint64
+	Label     string
+}
+
+func (msg *MobileTouchEvent) Encode() []byte {
+	buf := make([]byte, 71+len(msg.Phase)+len(msg.Label))
+	buf[0] = 114
+	p := 1
+	p = WriteUint(msg.Timestamp, buf, p)
+	p = WriteUint(msg.Length, buf, p)
+	p = WriteString(msg.Phase, buf, p)
+	p = WriteUint(msg.PointerID, buf, p)
+	p = WriteUint(msg.X, buf, p)
+	p = WriteUint(msg.Y, buf, p)
+	p = WriteString(msg.Label, buf, p)
+	return buf[:p]
+}
+
+func (msg *MobileTouchEvent) EncodeWithIndex() []byte {
+	encoded := msg.Encode()
+	if IsIOSType(msg.TypeID()) {
+		return encoded
+	}
+	data := make([]byte, len(encoded)+8)
+	copy(data[8:], encoded[:])
+	binary.LittleEndian.PutUint64(data[0:], msg.Meta().Index)
+	return data
+}
+
+func (msg *MobileTouchEvent) Decode() Message {
+	return msg
+}
+
+func (msg *MobileTouchEvent) TypeID() int {
+	return 114
+}
+
+type MobileLifecycleEvent struct {
+	message
+	Timestamp uint64
+	Length    uint64
+	State     string
+}
+
+func (msg *MobileLifecycleEvent) Encode() []byte {
+	buf := make([]byte, 31+len(msg.State))
+	buf[0] = 115
+	p := 1
+	p = WriteUint(msg.Timestamp, buf, p)
+	p = WriteUint(msg.Length, buf, p)
+	p = WriteString(msg.State, buf, p)
+	return buf[:p]
+}
+
+func (msg *MobileLifecycleEvent) EncodeWithIndex() []byte {
+	encoded := msg.Encode()
+	if IsIOSType(msg.TypeID()) {
+		return encoded
+	}
+	data := make([]byte, len(encoded)+8)
+	copy(data[8:], encoded[:])
+	binary.LittleEndian.PutUint64(data[0:], msg.Meta().Index)
+	return data
+}
+
+func (msg *MobileLifecycleEvent) Decode() Message {
+	return msg
+}
+
+func (msg *MobileLifecycleEvent) TypeID() int {
+	return 115
+}
@@ -0,0 +1,257 @@
+package messages
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ProtoContentType marks ingest requests with protobuf batches, see messages.proto
+const ProtoContentType = "application/x-protobuf"
+
+// ProtoBatchRecord is the full name of the Batch message, the first message of the schema
+const ProtoBatchRecord = "openreplay.messages.Batch"
+
+// ProtoSchema is messages.proto, it's registered in schema registries for consumers of protobuf topics
+//
+//go:embed messages.proto
+var ProtoSchema string
+
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// ProtoValue is a field value of any wire type, getters return zero values on wire type mismatch
+type ProtoValue struct {
+	wireType uint64
+	varint   uint64
+	bytes    []byte
+}
+
+func (v *ProtoValue) Uint() uint64 {
+	return v.varint
+}
+
+// Int decodes sint64 zigzag encoding, the same as the custom binary format uses
+func (v *ProtoValue) Int() int64 {
+	return int64(v.varint>>1) ^ -int64(v.varint&1)
+}
+
+func (v *ProtoValue) Boolean() bool {
+	return v.varint != 0
+}
+
+func (v *ProtoValue) String() string {
+	return string(v.bytes)
+}
+
+func (v *ProtoValue) Data() []byte {
+	return v.bytes
+}
+
+func appendUvarint(buf []byte, x uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], x)
+	return append(buf, tmp[:n]...)
+}
+
+func appendProtoTag(buf []byte, field, wireType uint64) []byte {
+	return appendUvarint(buf, field<<3|wireType)
+}
+
+// AppendProto* functions skip zero values like proto3 encoders do
+
+func AppendProtoUint(buf []byte, field uint64, v uint64) []byte {
+	if v == 0 {
+		return buf
+	}
+	return appendUvarint(appendProtoTag(buf, field, protoVarint), v)
+}
+
+func AppendProtoInt(buf []byte, field uint64, v int64) []byte {
+	if v == 0 {
+		return buf
+	}
+	return appendUvarint(appendProtoTag(buf, field, protoVarint), uint64(v<<1)^uint64(v>>63))
+}
+
+func AppendProtoBoolean(buf []byte, field uint64, v bool) []byte {
+	if !v {
+		return buf
+	}
+	return appendUvarint(appendProtoTag(buf, field, protoVarint), 1)
+}
+
+func AppendProtoString(buf []byte, field uint64, v string) []byte {
+	if v == "" {
+		return buf
+	}
+	buf = appendUvarint(appendProtoTag(buf, field, protoBytes), uint64(len(v)))
+	return append(buf, v...)
+}
+
+func AppendProtoData(buf []byte, field uint64, v []byte) []byte {
+	if len(v) == 0 {
+		return buf
+	}
+	return appendProtoBytes(buf, field, v)
+}
+
+// appendProtoBytes keeps empty values, message with all zero fields is encoded as an empty one
+func appendProtoBytes(buf []byte, field uint64, v []byte) []byte {
+	buf = appendUvarint(appendProtoTag(buf, field, protoBytes), uint64(len(v)))
+	return append(buf, v...)
+}
+
+// ReadProtoFields calls fn for every field of the encoded message, unknown fields are ignored by the caller
+func ReadProtoFields(data []byte, fn func(field uint64, value *ProtoValue)) error {
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("wrong field tag")
+		}
+		data = data[n:]
+		value := &ProtoValue{wireType: tag & 7}
+		switch value.wireType {
+		case protoVarint:
+			if value.varint, n = binary.Uvarint(data); n <= 0 {
+				return errors.New("wrong varint value")
+			}
+			data = data[n:]
+		case protoBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || size > uint64(len(data)-n) {
+				return errors.New("wrong length of bytes value")
+			}
+			value.bytes, data = data[n:n+int(size)], data[n+int(size):]
+		case protoFixed64, protoFixed32:
+			size := 8
+			if value.wireType == protoFixed32 {
+				size = 4
+			}
+			if len(data) < size {
+				return errors.New("wrong fixed value")
+			}
+			data = data[size:]
+		default:
+			return fmt.Errorf("unsupported wire type: %d", value.wireType)
+		}
+		fn(tag>>3, value)
+	}
+	return nil
+}
+
+// EncodeProto returns the Message envelope with the message as its payload
+func EncodeProto(msg Message) []byte {
+	msg = msg.Decode()
+	if msg == nil {
+		return nil
+	}
+	encoder, ok := msg.(interface{ EncodeProto() []byte })
+	if !ok {
+		return nil
+	}
+	return appendProtoBytes(nil, uint64(msg.TypeID())+1, encoder.EncodeProto())
+}
+
+// DecodeProto reads the Message envelope
+func DecodeProto(data []byte) (Message, error) {
+	var (
+		msg Message
+		err error
+	)
+	found := false
+	if fieldsErr := ReadProtoFields(data, func(field uint64, value *ProtoValue) {
+		if found || field == 0 || value.wireType != protoBytes {
+			return
+		}
+		found = true
+		msg, err = ReadProtoMessage(field-1, value.bytes)
+	}); fieldsErr != nil {
+		return nil, fieldsErr
+	}
+	if !found {
+		return nil, errors.New("message payload is empty")
+	}
+	return msg, err
+}
+
+// EncodeProtoBatch returns the Batch, messages without protobuf schema are skipped
+func EncodeProtoBatch(msgs []Message) []byte {
+	var buf []byte
+	for _, msg := range msgs {
+		if encoded := EncodeProto(msg); encoded != nil {
+			buf = appendProtoBytes(buf, 1, encoded)
+		}
+	}
+	return buf
+}
+
+// ProtoBatchToNative converts the Batch to the custom binary format, so the rest of the pipeline
+// doesn't depend on the ingest encoding. Messages unknown to this version are skipped.
+func ProtoBatchToNative(data []byte) ([]byte, error) {
+	native := make([]byte, 0, len(data)*2)
+	var (
+		version uint64
+		skipped int
+		err     error
+	)
+	fieldsErr := ReadProtoFields(data, func(field uint64, value *ProtoValue) {
+		if err != nil || field != 1 || value.wireType != protoBytes {
+			return
+		}
+		msg, decodeErr := DecodeProto(value.bytes)
+		if decodeErr != nil {
+			if strings.HasPrefix(decodeErr.Error(), "Unknown message code:") {
+				skipped++
+				return
+			}
+			err = decodeErr
+			return
+		}
+		if meta, ok := msg.(*BatchMetadata); ok {
+			version = meta.Version
+		}
+		encoded := msg.Encode()
+		if version > 0 && messageHasSize(uint64(msg.TypeID())) {
+			// Message type is a single byte, all ids are less than 128
+			size := uint64(len(encoded) - 1)
+			native = append(native, encoded[0], byte(size), byte(size>>8), byte(size>>16))
+			native = append(native, encoded[1:]...)
+		} else {
+			native = append(native, encoded...)
+		}
+	})
+	if fieldsErr != nil {
+		return nil, fieldsErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if skipped > 0 {
+		log.Printf("skipped unknown protobuf messages: %d", skipped)
+	}
+	return native, nil
+}
+
+// NativeBatchToProto converts the batch of the custom binary format to the Batch
+func NativeBatchToProto(data []byte) ([]byte, error) {
+	iter := NewIterator(data)
+	defer iter.Close()
+	var msgs []Message
+	for iter.Next() {
+		if msg := iter.Message().Decode(); msg != nil {
+			msgs = append(msgs, msg)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return EncodeProtoBatch(msgs), nil
+}
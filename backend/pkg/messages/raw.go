@@ -3,6 +3,7 @@ package messages
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"io"
 	"log"
 )
@@ -12,7 +13,7 @@ type RawMessage struct {
 	tp      uint64
 	size    uint64
 	data    []byte
-	reader  *bytes.Reader
+	reader  io.Reader
 	meta    *message
 	encoded bool
 	skipped *bool
@@ -52,6 +53,12 @@ func (m *RawMessage) Decode() Message {
 		m.Encode()
 	}
 	msg, err := ReadMessage(m.tp, bytes.NewReader(m.data[1:]))
+	if err != nil && IsDeprecated(m.tp) {
+		msg, err = readDeprecated(m.tp, bytes.NewReader(m.data[1:]))
+		if msg == nil && err == nil {
+			err = fmt.Errorf("deprecated message %d doesn't have the layout", m.tp)
+		}
+	}
 	if err != nil {
 		log.Printf("decode err: %s", err)
 		return nil
@@ -67,3 +74,8 @@ func (m *RawMessage) TypeID() int {
 func (m *RawMessage) Meta() *message {
 	return m.meta
 }
+
+// Size returns the encoded size of the message without reading it
+func (m *RawMessage) Size() int {
+	return int(m.size) + 1
+}
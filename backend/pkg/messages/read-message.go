@@ -1318,6 +1318,117 @@ func DecodeZustand(reader io.Reader) (Message, error) {
 	return msg, err
 }
 
+func DecodeAssistEvent(reader io.Reader) (Message, error) {
+	var err error = nil
+	msg := &AssistEvent{}
+	if msg.Timestamp, err = ReadUint(reader); err != nil {
+		return nil, err
+	}
+	if msg.AgentID, err = ReadUint(reader); err != nil {
+		return nil, err
+	}
+	if msg.AgentName, err = ReadString(reader); err != nil {
+		return nil, err
+	}
+	if msg.Type, err = ReadString(reader); err != nil {
+		return nil, err
+	}
+	if msg.Payload, err = ReadString(reader); err != nil {
+		return nil, err
+	}
+	return msg, err
+}
+
+func DecodeCanvasSnapshot(reader io.Reader) (Message, error) {
+	var err error = nil
+	msg := &CanvasSnapshot{}
+	if msg.NodeID, err = ReadUint(reader); err != nil {
+		return nil, err
+	}
+	if msg.Timestamp, err = ReadUint(reader); err != nil {
+		return nil, err
+	}
+	if msg.Format, err = ReadString(reader); err != nil {
+		return nil, err
+	}
+	if msg.Data, err = ReadString(reader); err != nil {
+		return nil, err
+	}
+	return msg, err
+}
+
+func DecodeCanvasNode(reader io.Reader) (Message, error) {
+	var err error = nil
+	msg := &CanvasNode{}
+	if msg.NodeID, err = ReadUint(reader); err != nil {
+		return nil, err
+	}
+	if msg.Timestamp, err = ReadUint(reader); err != nil {
+		return nil, err
+	}
+	if msg.Format, err = ReadString(reader); err != nil {
+		return nil, err
+	}
+	if msg.Size, err = ReadUint(reader); err != nil {
+		return nil, err
+	}
+	return msg, err
+}
+
+func DecodeStringDict(reader io.Reader) (Message, error) {
+	var err error = nil
+	msg := &StringDict{}
+	if msg.Key, err = ReadUint(reader); err != nil {
+		return nil, err
+	}
+	if msg.BaseKey, err = ReadUint(reader); err != nil {
+		return nil, err
+	}
+	if msg.PrefixLength, err = ReadUint(reader); err != nil {
+		return nil, err
+	}
+	if msg.Suffix, err = ReadString(reader); err != nil {
+		return nil, err
+	}
+	return msg, err
+}
+
+func DecodeSetNodeAttributeDict(reader io.Reader) (Message, error) {
+	var err error = nil
+	msg := &SetNodeAttributeDict{}
+	if msg.ID, err = ReadUint(reader); err != nil {
+		return nil, err
+	}
+	if msg.NameKey, err = ReadUint(reader); err != nil {
+		return nil, err
+	}
+	if msg.ValueKey, err = ReadUint(reader); err != nil {
+		return nil, err
+	}
+	return msg, err
+}
+
+func DecodePluginIssue(reader io.Reader) (Message, error) {
+	var err error = nil
+	msg := &PluginIssue{}
+	if msg.Plugin, err = ReadString(reader); err != nil {
+		return nil, err
+	}
+	if msg.Type, err = ReadString(reader); err != nil {
+		return nil, err
+	}
+	if msg.Severity, err = ReadString(reader); err != nil {
+		return nil, err
+	}
+	if msg.ContextString, err = ReadString(reader); err != nil {
+		return nil, err
+	}
+	if msg.Payload, err = ReadString(reader); err != nil {
+		return nil, err
+	}
+	return msg, err
+}
+
 func DecodeIOSBatchMeta(reader io.Reader) (Message, error) {
 	var err error = nil
 	msg := &IOSBatchMeta{}
@@ -1720,6 +1831,72 @@ func DecodeIOSIssueEvent(reader io.Reader) (Message, error) {
 	return msg, err
 }
 
+func DecodeMobileViewHierarchy(reader io.Reader) (Message, error) {
+	var err error = nil
+	msg := &MobileViewHierarchy{}
+	if msg.Timestamp, err = ReadUint(reader); err != nil {
+		return nil, err
+	}
+	if msg.Length, err = ReadUint(reader); err != nil {
+		return nil, err
+	}
+	if msg.ScreenName, err = ReadString(reader); err != nil {
+		return nil, err
+	}
+	if msg.Width, err = ReadUint(reader); err != nil {
+		return nil, err
+	}
+	if msg.Height, err = ReadUint(reader); err != nil {
+		return nil, err
+	}
+	if msg.Tree, err = ReadString(reader); err != nil {
+		return nil, err
+	}
+	return msg, err
+}
+
+func DecodeMobileTouchEvent(reader io.Reader) (Message, error) {
+	var err error = nil
+	msg := &MobileTouchEvent{}
+	if msg.Timestamp, err = ReadUint(reader); err != nil {
+		return nil, err
+	}
+	if msg.Length, err = ReadUint(reader); err != nil {
+		return nil, err
+	}
+	if msg.Phase, err = ReadString(reader); err != nil {
+		return nil, err
+	}
+	if msg.PointerID, err = ReadUint(reader); err != nil {
+		return nil, err
+	}
+	if msg.X, err = ReadUint(reader); err != nil {
+		return nil, err
+	}
+	if msg.Y, err = ReadUint(reader); err != nil {
+		return nil, err
+	}
+	if msg.Label, err = ReadString(reader); err != nil {
+		return nil, err
+	}
+	return msg, err
+}
+
+func DecodeMobileLifecycleEvent(reader io.Reader) (Message, error) {
+	var err error = nil
+	msg := &MobileLifecycleEvent{}
+	if msg.Timestamp, err = ReadUint(reader); err != nil {
+		return nil, err
+	}
+	if msg.Length, err = ReadUint(reader); err != nil {
+		return nil, err
+	}
+	if msg.State, err = ReadString(reader); err != nil {
+		return nil, err
+	}
+	return msg, err
+}
+
 func ReadMessage(t uint64, reader io.Reader) (Message, error) {
 	switch t {
 
@@ -1954,6 +2131,24 @@ func ReadMessage(t uint64, reader io.Reader) (Message, error) {
 	case 79:
 		return DecodeZustand(reader)
 
+	case 112:
+		return DecodeAssistEvent(reader)
+
+	case 116:
+		return DecodeCanvasSnapshot(reader)
+
+	case 117:
+		return DecodeCanvasNode(reader)
+
+	case 118:
+		return DecodeStringDict(reader)
+
+	case 119:
+		return DecodeSetNodeAttributeDict(reader)
+
+	case 120:
+		return DecodePluginIssue(reader)
+
 	case 107:
 		return DecodeIOSBatchMeta(reader)
 
@@ -2011,6 +2206,15 @@ func ReadMessage(t uint64, reader io.Reader) (Message, error) {
 	case 111:
 		return DecodeIOSIssueEvent(reader)
 
+	case 113:
+		return DecodeMobileViewHierarchy(reader)
+
+	case 114:
+		return DecodeMobileTouchEvent(reader)
+
+	case 115:
+		return DecodeMobileLifecycleEvent(reader)
+
 	}
 	return nil, fmt.Errorf("Unknown message code: %v", t)
 }
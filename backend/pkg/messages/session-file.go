@@ -0,0 +1,52 @@
+package messages
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// ReadSessionFile calls fn for every message of the web session file written by the sink,
+// each message is stored with its 8-byte index. Delta encoded files have to be decoded before.
+// Dictionary encoded attributes are passed as SetNodeAttribute messages.
+func ReadSessionFile(data []byte, fn func(msg Message)) error {
+	dict := NewStringDictionary()
+	pos := 0
+	for len(data)-pos > 8 {
+		index := binary.LittleEndian.Uint64(data[pos:])
+		tp := data[pos+8]
+		if IsIOSType(int(tp)) {
+			return fmt.Errorf("ios session files aren't supported")
+		}
+		reader := bytes.NewReader(data[pos+9:])
+		msg, err := ReadMessage(uint64(tp), reader)
+		if err != nil && IsDeprecated(uint64(tp)) {
+			msg, err = readDeprecated(uint64(tp), reader)
+			if msg == nil && err == nil {
+				err = fmt.Errorf("deprecated message %d doesn't have the layout", tp)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("can't read message at %d: %s", pos, err)
+		}
+		pos = len(data) - reader.Len()
+		// Old sessions are still readable after schema changes
+		if msg = replaceDeprecated(msg); msg == nil {
+			continue
+		}
+		switch m := msg.(type) {
+		case *StringDict:
+			if err := dict.Add(m); err != nil {
+				return fmt.Errorf("can't read string dictionary at %d: %s", pos, err)
+			}
+			continue
+		case *SetNodeAttributeDict:
+			if msg, err = dict.Resolve(m); err != nil {
+				return fmt.Errorf("can't resolve attribute at %d: %s", pos, err)
+			}
+		}
+		msg.Meta().Index = index
+		fn(msg)
+	}
+	return nil
+}
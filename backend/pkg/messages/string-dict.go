@@ -0,0 +1,44 @@
+package messages
+
+import "fmt"
+
+// StringDictionary keeps strings of StringDict messages in the order they are read from the session file,
+// later definitions of the same key replace earlier ones
+type StringDictionary struct {
+	values map[uint64]string
+}
+
+func NewStringDictionary() *StringDictionary {
+	return &StringDictionary{values: make(map[uint64]string)}
+}
+
+func (d *StringDictionary) Add(msg *StringDict) error {
+	value := msg.Suffix
+	if msg.BaseKey != 0 {
+		base, ok := d.values[msg.BaseKey]
+		if !ok {
+			return fmt.Errorf("unknown base key: %d", msg.BaseKey)
+		}
+		if msg.PrefixLength > uint64(len(base)) {
+			return fmt.Errorf("prefix length %d is out of base string: %d", msg.PrefixLength, len(base))
+		}
+		value = base[:msg.PrefixLength] + msg.Suffix
+	}
+	d.values[msg.Key] = value
+	return nil
+}
+
+// Resolve returns the message as it was sent by the tracker
+func (d *StringDictionary) Resolve(msg *SetNodeAttributeDict) (*SetNodeAttribute, error) {
+	name, ok := d.values[msg.NameKey]
+	if !ok {
+		return nil, fmt.Errorf("unknown name key: %d", msg.NameKey)
+	}
+	value, ok := d.values[msg.ValueKey]
+	if !ok {
+		return nil, fmt.Errorf("unknown value key: %d", msg.ValueKey)
+	}
+	resolved := &SetNodeAttribute{ID: msg.ID, Name: name, Value: value}
+	resolved.SetMeta(msg.Meta())
+	return resolved, nil
+}
@@ -0,0 +1,203 @@
+package messages
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Reasons of rejected messages, they are used as metric attributes
+const (
+	RejectDecode     = "decode"
+	RejectTimestamp  = "timestamp"
+	RejectCoordinate = "coordinate"
+	RejectStringSize = "string_size"
+
+	RejectCustomPayloadSize = "custom_payload_size"
+	RejectCustomPayloadJSON = "custom_payload_json"
+)
+
+// minTimestamp is 2000-01-01, smaller non-zero timestamps come from broken clocks or overflows
+const minTimestamp = 946684800000
+
+// coordinateFields are positions and sizes in pixels, scroll offsets can be negative
+var coordinateFields = map[string]bool{"X": true, "Y": true, "Width": true, "Height": true}
+
+// fieldPlan keeps indexes of the checked fields of one message type, so reflection
+// over the struct type happens only once
+type fieldPlan struct {
+	strings     []int
+	coordinates []int
+	timestamp   int // -1 if the message doesn't have it
+}
+
+// Validator rejects malformed or out-of-range messages before they get into replays and databases
+type Validator struct {
+	maxStringSize  int
+	maxCoordinate  int64
+	maxFutureDrift time.Duration
+	onReject       func(msgType int, reason string)
+	plans          sync.Map
+
+	// Payloads of custom events are set by customers' code, so they are limited even if other checks are disabled
+	checkFields        bool
+	maxCustomEventSize int
+}
+
+// NewValidator doesn't check limits which are 0, onReject is called for every rejected message
+func NewValidator(maxStringSize int, maxCoordinate int64, maxFutureDrift time.Duration, onReject func(msgType int, reason string)) *Validator {
+	if onReject == nil {
+		onReject = func(int, string) {}
+	}
+	return &Validator{
+		maxStringSize:  maxStringSize,
+		maxCoordinate:  maxCoordinate,
+		maxFutureDrift: maxFutureDrift,
+		onReject:       onReject,
+		checkFields:    true,
+	}
+}
+
+// NewCustomEventValidator checks custom events only, other messages aren't decoded
+func NewCustomEventValidator(maxPayloadSize int, onReject func(msgType int, reason string)) *Validator {
+	v := NewValidator(0, 0, 0, onReject)
+	v.checkFields = false
+	v.maxCustomEventSize = maxPayloadSize
+	return v
+}
+
+// LimitCustomEvents rejects custom events with payloads bigger than maxPayloadSize or not in JSON
+func (v *Validator) LimitCustomEvents(maxPayloadSize int) *Validator {
+	v.maxCustomEventSize = maxPayloadSize
+	return v
+}
+
+func isCustomEvent(msgType int) bool {
+	return msgType == MsgRawCustomEvent || msgType == MsgIOSCustomEvent
+}
+
+// checkCustomEvent allows empty payloads, the tracker sends them for events without data
+func (v *Validator) checkCustomEvent(msg Message) string {
+	var payload string
+	switch m := msg.(type) {
+	case *RawCustomEvent:
+		payload = m.Payload
+	case *IOSCustomEvent:
+		payload = m.Payload
+	default:
+		return ""
+	}
+	if v.maxCustomEventSize > 0 && len(payload) > v.maxCustomEventSize {
+		return RejectCustomPayloadSize
+	}
+	if payload != "" && !json.Valid([]byte(payload)) {
+		return RejectCustomPayloadJSON
+	}
+	return ""
+}
+
+func (v *Validator) plan(t reflect.Type) *fieldPlan {
+	if p, ok := v.plans.Load(t); ok {
+		return p.(*fieldPlan)
+	}
+	p := &fieldPlan{timestamp: -1}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			continue
+		}
+		kind := field.Type.Kind()
+		switch {
+		case kind == reflect.String:
+			p.strings = append(p.strings, i)
+		case field.Name == "Timestamp" && (kind == reflect.Uint64 || kind == reflect.Int64):
+			p.timestamp = i
+		case coordinateFields[field.Name] && (kind == reflect.Uint64 || kind == reflect.Int64):
+			p.coordinates = append(p.coordinates, i)
+		}
+	}
+	v.plans.Store(t, p)
+	return p
+}
+
+func intValue(value reflect.Value) (int64, bool) {
+	if value.Kind() == reflect.Int64 {
+		return value.Int(), true
+	}
+	u := value.Uint()
+	return int64(u), u <= 1<<63-1
+}
+
+// Validate returns the decoded message and an empty reason if the message is valid
+func (v *Validator) Validate(msg Message) (Message, string) {
+	if !v.checkFields && !isCustomEvent(msg.TypeID()) {
+		return msg, ""
+	}
+	msg = msg.Decode()
+	if msg == nil {
+		return nil, RejectDecode
+	}
+	if reason := v.checkCustomEvent(msg); reason != "" {
+		return nil, reason
+	}
+	if !v.checkFields {
+		return msg, ""
+	}
+	value := reflect.ValueOf(msg).Elem()
+	p := v.plan(value.Type())
+	if p.timestamp >= 0 {
+		ts, ok := intValue(value.Field(p.timestamp))
+		if !ok || ts < 0 || ts > 0 && ts < minTimestamp ||
+			v.maxFutureDrift > 0 && ts > time.Now().Add(v.maxFutureDrift).UnixMilli() {
+			return nil, RejectTimestamp
+		}
+	}
+	if v.maxCoordinate > 0 {
+		for _, i := range p.coordinates {
+			c, ok := intValue(value.Field(i))
+			if !ok || c > v.maxCoordinate || c < -v.maxCoordinate {
+				return nil, RejectCoordinate
+			}
+		}
+	}
+	if v.maxStringSize > 0 {
+		for _, i := range p.strings {
+			if value.Field(i).Len() > v.maxStringSize {
+				return nil, RejectStringSize
+			}
+		}
+	}
+	return msg, ""
+}
+
+// Wrap returns the iterator which skips invalid messages, valid ones are returned decoded.
+// Nil validator returns the same iterator, so validation can be disabled.
+func (v *Validator) Wrap(iter Iterator) Iterator {
+	if v == nil {
+		return iter
+	}
+	return &validatingIterator{Iterator: iter, validator: v}
+}
+
+type validatingIterator struct {
+	Iterator
+	validator *Validator
+	msg       Message
+}
+
+func (i *validatingIterator) Next() bool {
+	for i.Iterator.Next() {
+		msg, reason := i.validator.Validate(i.Iterator.Message())
+		if reason == "" {
+			i.msg = msg
+			return true
+		}
+		i.validator.onReject(i.Iterator.Type(), reason)
+	}
+	return false
+}
+
+func (i *validatingIterator) Message() Message {
+	return i.msg
+}
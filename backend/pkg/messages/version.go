@@ -0,0 +1,46 @@
+package messages
+
+// BatchVersion is the current version of the batch format, it's sent by the tracker in BatchMetadata.
+// Batches of the previous version are still accepted, their messages are upgraded to the current schema,
+// so the tracker and the backend can be updated independently.
+const (
+	BatchVersion    = 1
+	MinBatchVersion = BatchVersion - 1
+)
+
+// upgradeFunc converts the message to the schema of the next version
+type upgradeFunc func(msg Message) Message
+
+// upgrades keeps changes of every version by message type. Version 0 batches don't have message sizes,
+// version 1 added them and replaced deprecated messages.
+var upgrades = map[uint64]map[uint64]upgradeFunc{
+	0: {
+		MsgMouseClickDepricated: transformDeprecated,
+	},
+}
+
+func IsSupportedVersion(version uint64) bool {
+	return version >= MinBatchVersion && version <= BatchVersion
+}
+
+// needsUpgrade is checked before decoding, messages without schema changes stay raw
+func needsUpgrade(version, msgType uint64) bool {
+	for v := version; v < BatchVersion; v++ {
+		if _, ok := upgrades[v][msgType]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// UpgradeMessage applies changes of all versions after the batch one
+func UpgradeMessage(version uint64, msg Message) Message {
+	for v := version; v < BatchVersion; v++ {
+		if upgrade, ok := upgrades[v][uint64(msg.TypeID())]; ok {
+			if decoded := msg.Decode(); decoded != nil {
+				msg = upgrade(decoded)
+			}
+		}
+	}
+	return msg
+}
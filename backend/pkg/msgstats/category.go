@@ -0,0 +1,71 @@
+package msgstats
+
+import "openreplay/backend/pkg/messages"
+
+// categories group message types by what they record, a tracker regression usually floods one of them
+var categories = map[string][]int{
+	"dom": {
+		messages.MsgCreateDocument, messages.MsgCreateElementNode, messages.MsgCreateTextNode, messages.MsgMoveNode,
+		messages.MsgRemoveNode, messages.MsgSetNodeAttribute, messages.MsgRemoveNodeAttribute, messages.MsgSetNodeData,
+		messages.MsgSetCSSData, messages.MsgSetNodeScroll, messages.MsgCSSInsertRule, messages.MsgCSSDeleteRule,
+		messages.MsgSetNodeAttributeURLBased, messages.MsgSetCSSDataURLBased, messages.MsgCSSInsertRuleURLBased,
+		messages.MsgCreateIFrameDocument, messages.MsgAdoptedSSReplaceURLBased, messages.MsgAdoptedSSReplace,
+		messages.MsgAdoptedSSInsertRuleURLBased, messages.MsgAdoptedSSInsertRule, messages.MsgAdoptedSSDeleteRule,
+		messages.MsgAdoptedSSAddOwner, messages.MsgAdoptedSSRemoveOwner, messages.MsgStringDict, messages.MsgSetNodeAttributeDict,
+	},
+	"canvas": {messages.MsgCanvasSnapshot, messages.MsgCanvasNode},
+	"interaction": {
+		messages.MsgSetViewportSize, messages.MsgSetViewportScroll, messages.MsgSetInputTarget, messages.MsgSetInputValue,
+		messages.MsgSetInputChecked, messages.MsgMouseMove, messages.MsgMouseClickDepricated, messages.MsgMouseClick,
+		messages.MsgSetPageVisibility,
+	},
+	"page": {
+		messages.MsgSetPageLocation, messages.MsgPageLoadTiming, messages.MsgPageRenderTiming,
+		messages.MsgConnectionInformation, messages.MsgTechnicalInfo,
+	},
+	"console": {messages.MsgConsoleLog, messages.MsgJSException},
+	"network": {
+		messages.MsgFetch, messages.MsgGraphQL, messages.MsgResourceTiming,
+		messages.MsgFetchEvent, messages.MsgGraphQLEvent, messages.MsgResourceEvent,
+	},
+	"performance": {messages.MsgProfiler, messages.MsgPerformanceTrack, messages.MsgPerformanceTrackAggr, messages.MsgLongTask},
+	"state": {
+		messages.MsgOTable, messages.MsgStateAction, messages.MsgStateActionEvent, messages.MsgRedux, messages.MsgVuex,
+		messages.MsgMobX, messages.MsgNgRx, messages.MsgZustand,
+	},
+	"custom": {
+		messages.MsgIntegrationEvent, messages.MsgRawCustomEvent, messages.MsgCustomEvent, messages.MsgCustomIssue,
+		messages.MsgPluginIssue,
+	},
+	"events": {
+		messages.MsgPageEvent, messages.MsgInputEvent, messages.MsgClickEvent, messages.MsgErrorEvent,
+		messages.MsgIssueEvent, messages.MsgDOMDrop,
+	},
+	"session": {
+		messages.MsgTimestamp, messages.MsgBatchMeta, messages.MsgBatchMetadata, messages.MsgPartitionedMessage,
+		messages.MsgSessionStart, messages.MsgSessionEnd, messages.MsgUserID, messages.MsgUserAnonymousID, messages.MsgMetadata,
+	},
+	"assets": {messages.MsgAssetCache},
+	"assist": {messages.MsgAssistEvent},
+}
+
+var typeCategories = func() map[int]string {
+	res := make(map[int]string)
+	for category, types := range categories {
+		for _, msgType := range types {
+			res[msgType] = category
+		}
+	}
+	return res
+}()
+
+// Category returns the group of the message type, all mobile messages are in one group
+func Category(msgType int) string {
+	if category, ok := typeCategories[msgType]; ok {
+		return category
+	}
+	if messages.IsIOSType(msgType) {
+		return "mobile"
+	}
+	return "other"
+}
@@ -0,0 +1,99 @@
+package msgstats
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+
+	"openreplay/backend/pkg/messages"
+	"openreplay/backend/pkg/monitoring"
+)
+
+// maxSessions limits the map of session projects, sessions which never got the end message stay there
+const maxSessions = 100000
+
+// ProjectResolver returns 0 if the project of the session is unknown
+type ProjectResolver func(sessionID uint64) uint32
+
+// Stats counts messages and their bytes by message type, its category and project, so it's visible
+// which kinds of events make most of the volume
+type Stats struct {
+	messages syncfloat64.Counter
+	bytes    syncfloat64.Counter
+	resolve  ProjectResolver
+	projects map[uint64]uint32 // map[sessionID]projectID
+}
+
+// New takes an optional resolver for sessions which started before the service, projects of other
+// sessions are taken from their start messages
+func New(metrics *monitoring.Metrics, resolve ProjectResolver) (*Stats, error) {
+	if metrics == nil {
+		return nil, fmt.Errorf("metrics module is empty")
+	}
+	msgs, err := metrics.RegisterCounter("messages_by_type")
+	if err != nil {
+		return nil, fmt.Errorf("can't register messages_by_type metric: %s", err)
+	}
+	bytes, err := metrics.RegisterCounter("message_bytes_by_type")
+	if err != nil {
+		return nil, fmt.Errorf("can't register message_bytes_by_type metric: %s", err)
+	}
+	return &Stats{
+		messages: msgs,
+		bytes:    bytes,
+		resolve:  resolve,
+		projects: make(map[uint64]uint32),
+	}, nil
+}
+
+// Add is called for every processed message of the session
+func (s *Stats) Add(sessionID uint64, msg messages.Message) {
+	projectID := s.project(sessionID, msg)
+	attrs := []attribute.KeyValue{
+		attribute.Int("type", msg.TypeID()),
+		attribute.String("category", Category(msg.TypeID())),
+		attribute.Int64("project", int64(projectID)),
+	}
+	s.messages.Add(context.Background(), 1, attrs...)
+	s.bytes.Add(context.Background(), float64(size(msg)), attrs...)
+}
+
+func (s *Stats) project(sessionID uint64, msg messages.Message) uint32 {
+	switch msg.TypeID() {
+	case messages.MsgSessionStart, messages.MsgIOSSessionStart:
+		if len(s.projects) >= maxSessions {
+			s.projects = make(map[uint64]uint32)
+		}
+		switch m := msg.Decode().(type) {
+		case *messages.SessionStart:
+			s.projects[sessionID] = uint32(m.ProjectID)
+		case *messages.IOSSessionStart:
+			s.projects[sessionID] = uint32(m.ProjectID)
+		}
+	case messages.MsgSessionEnd, messages.MsgIOSSessionEnd:
+		projectID := s.projects[sessionID]
+		delete(s.projects, sessionID)
+		return projectID
+	}
+	if projectID, ok := s.projects[sessionID]; ok {
+		return projectID
+	}
+	if s.resolve == nil {
+		return 0
+	}
+	projectID := s.resolve(sessionID)
+	if projectID != 0 {
+		s.projects[sessionID] = projectID
+	}
+	return projectID
+}
+
+// size doesn't read raw messages, other ones are encoded back
+func size(msg messages.Message) int {
+	if raw, ok := msg.(*messages.RawMessage); ok {
+		return raw.Size()
+	}
+	return len(msg.Encode())
+}
@@ -0,0 +1,263 @@
+package natsstream
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"openreplay/backend/pkg/env"
+	"openreplay/backend/pkg/queue/types"
+)
+
+const (
+	fetchBatch           = 100
+	fetchWait            = time.Second
+	pollTimeout          = 200 * time.Millisecond
+	defaultAckWait       = 5 * time.Minute // the longest commit interval of services fits into it
+	defaultMaxAckPending = 100000
+)
+
+type pendingMessage struct {
+	msg *nats.Msg
+	ts  int64
+}
+
+// Consumer reads partitions of the instance through durable pull consumers, one per partition, so the group
+// can be scaled without losing positions. JetStream doesn't balance partitions between instances, they are
+// assigned statically: instance NATS_INSTANCE of NATS_INSTANCES gets partitions with p % instances == instance.
+// Messages are fetched in the background and handled by ConsumeNext in the order of their partitions.
+type Consumer struct {
+	conn       *nats.Conn
+	handler    types.MessageHandler
+	autoCommit bool
+	partitions []uint64
+	assigned   []types.TopicPartition // partitions of all topics, reported to the listener
+	subs       []*nats.Subscription   // subscriptions of assigned partitions, in the same order
+	fetched    chan *nats.Msg
+	pending    []pendingMessage // handled but not acknowledged messages
+	lastTs     int64
+	done       chan struct{}
+	wg         sync.WaitGroup
+}
+
+func NewConsumer(group string, topics []string, handler types.MessageHandler, autoCommit bool) *Consumer {
+	conn, err := connect(group)
+	if err != nil {
+		log.Fatalf("can't connect to nats: %s", err)
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		log.Fatalf("can't init jetstream: %s", err)
+	}
+	instances, instance := uint64(env.IntOptional("NATS_INSTANCES")), uint64(env.IntOptional("NATS_INSTANCE"))
+	if instances == 0 {
+		instances = 1
+	}
+	if instance >= instances {
+		log.Fatalf("wrong nats instance %d of %d", instance, instances)
+	}
+	ackWait := env.DurationOptional("NATS_ACK_WAIT")
+	if ackWait == 0 {
+		ackWait = defaultAckWait
+	}
+	maxAckPending := env.IntOptional("NATS_MAX_ACK_PENDING")
+	if maxAckPending == 0 {
+		maxAckPending = defaultMaxAckPending
+	}
+
+	c := &Consumer{
+		conn:       conn,
+		handler:    handler,
+		autoCommit: autoCommit,
+		fetched:    make(chan *nats.Msg, fetchBatch),
+		done:       make(chan struct{}),
+	}
+	for p := uint64(0); p < partitionsNumber(); p++ {
+		if p%instances == instance {
+			c.partitions = append(c.partitions, p)
+		}
+	}
+	streams := newStreams(js)
+	for _, topic := range topics {
+		if err := streams.ensure(topic); err != nil {
+			log.Fatalln(err)
+		}
+		for _, p := range c.partitions {
+			c.assigned = append(c.assigned, types.TopicPartition{Topic: topic, Partition: p})
+			durable := fmt.Sprintf("%s-%d", streamName(group), p)
+			sub, err := js.PullSubscribe(subject(topic, p), durable,
+				nats.BindStream(streamName(topic)),
+				nats.ManualAck(),
+				nats.AckExplicit(),
+				nats.AckWait(ackWait),
+				nats.MaxAckPending(maxAckPending),
+				nats.DeliverAll(),
+			)
+			if err != nil {
+				log.Fatalf("can't subscribe to %s: %s", subject(topic, p), err)
+			}
+			c.subs = append(c.subs, sub)
+			c.wg.Add(1)
+			go c.fetch(sub)
+		}
+	}
+	return c
+}
+
+// fetch passes messages of one partition to the main goroutine
+func (c *Consumer) fetch(sub *nats.Subscription) {
+	defer c.wg.Done()
+	for {
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+		msgs, err := sub.Fetch(fetchBatch, nats.MaxWait(fetchWait))
+		if errors.Is(err, nats.ErrTimeout) {
+			continue
+		}
+		if errors.Is(err, nats.ErrConnectionClosed) {
+			return
+		}
+		if err != nil {
+			log.Printf("nats: can't fetch messages of %s: %s", sub.Subject, err)
+			time.Sleep(time.Second)
+			continue
+		}
+		for i, msg := range msgs {
+			select {
+			case c.fetched <- msg:
+			case <-c.done:
+				// Not handled messages are redelivered right away
+				for _, msg := range msgs[i:] {
+					msg.Nak()
+				}
+				return
+			}
+		}
+	}
+}
+
+func (c *Consumer) ConsumeNext() error {
+	timer := time.NewTimer(pollTimeout)
+	defer timer.Stop()
+	select {
+	case msg := <-c.fetched:
+		return c.handle(msg)
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (c *Consumer) handle(msg *nats.Msg) error {
+	meta, err := msg.Metadata()
+	if err != nil {
+		return fmt.Errorf("nats: wrong message metadata: %s", err)
+	}
+	topic, partition, err := parseSubject(msg.Subject)
+	if err != nil {
+		return err
+	}
+	key, err := strconv.ParseUint(msg.Header.Get(keyHeader), 10, 64)
+	if err != nil {
+		// Messages without keys aren't produced by the backend, they would be redelivered forever
+		log.Printf("nats: message without key, subject: %s, seq: %d", msg.Subject, meta.Sequence.Stream)
+		return msg.Term()
+	}
+	ts := meta.Timestamp.UnixMilli()
+	c.handler(key, msg.Data, &types.Meta{
+		ID:        meta.Sequence.Stream,
+		Topic:     topic,
+		Partition: partition,
+		Timestamp: ts,
+	})
+	if c.autoCommit {
+		return msg.Ack()
+	}
+	c.lastTs = ts
+	c.pending = append(c.pending, pendingMessage{msg: msg, ts: ts})
+	return nil
+}
+
+// ack acknowledges the first n pending messages, acknowledgements are flushed to the server before return
+func (c *Consumer) ack(n int) error {
+	if n == 0 {
+		return nil
+	}
+	for i, p := range c.pending[:n] {
+		if err := p.msg.Ack(); err != nil {
+			c.pending = c.pending[i:]
+			return fmt.Errorf("nats: acknowledgment error on commit: %s", err)
+		}
+	}
+	c.pending = c.pending[n:]
+	return c.conn.Flush()
+}
+
+func (c *Consumer) Commit() error {
+	return c.ack(len(c.pending))
+}
+
+// CommitBack acknowledges messages which are older than the last one by gap milliseconds
+func (c *Consumer) CommitBack(gap int64) error {
+	if c.lastTs == 0 {
+		return nil
+	}
+	maxTs := c.lastTs - gap
+	n := 0
+	for n < len(c.pending) && c.pending[n].ts <= maxTs {
+		n++
+	}
+	return c.ack(n)
+}
+
+// Close stops fetching, not acknowledged messages are redelivered to other consumers of the group
+func (c *Consumer) Close() {
+	close(c.done)
+	c.wg.Wait()
+	for len(c.fetched) > 0 {
+		(<-c.fetched).Nak()
+	}
+	for _, p := range c.pending {
+		p.msg.Nak()
+	}
+	c.pending = nil
+	// Durable consumers stay on the server, only the connection is closed
+	if err := c.conn.Flush(); err != nil {
+		log.Printf("nats: can't flush connection: %s", err)
+	}
+	c.conn.Close()
+}
+
+func (c *Consumer) HasFirstPartition() bool {
+	return len(c.partitions) > 0 && c.partitions[0] == 0
+}
+
+// SetPartitionListener reports partitions of the instance right away, they never change
+func (c *Consumer) SetPartitionListener(listener types.PartitionListener) {
+	if listener != nil {
+		listener.Assigned(c.assigned)
+	}
+}
+
+// Lag returns the number of messages of partitions which aren't delivered or acknowledged yet
+func (c *Consumer) Lag() ([]types.PartitionLag, error) {
+	lags := make([]types.PartitionLag, 0, len(c.subs))
+	for i, sub := range c.subs {
+		info, err := sub.ConsumerInfo()
+		if err != nil {
+			return nil, fmt.Errorf("can't get consumer of %s: %s", c.assigned[i], err)
+		}
+		lags = append(lags, types.PartitionLag{
+			TopicPartition: c.assigned[i],
+			Lag:            int64(info.NumPending) + int64(info.NumAckPending),
+		})
+	}
+	return lags, nil
+}
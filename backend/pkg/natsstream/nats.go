@@ -0,0 +1,121 @@
+package natsstream
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"openreplay/backend/pkg/env"
+)
+
+// Topics are JetStream streams with one subject per partition: <topic>.<partition>. Messages of one key
+// always get into the same partition, so sessions keep the order of their batches the same way as in kafka.
+const (
+	keyHeader         = "Openreplay-Key"
+	defaultPartitions = 16
+	defaultMaxAge     = 24 * time.Hour
+)
+
+func partitionsNumber() uint64 {
+	if n := env.IntOptional("NATS_PARTITIONS"); n > 0 {
+		return uint64(n)
+	}
+	return defaultPartitions
+}
+
+func connect(name string) (*nats.Conn, error) {
+	hostname, _ := os.Hostname()
+	opts := []nats.Option{
+		nats.Name(fmt.Sprintf("%s-%s", name, hostname)),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(time.Second),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				log.Printf("nats: disconnected: %s", err)
+			}
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			log.Printf("nats: reconnected to %s", conn.ConnectedUrl())
+		}),
+	}
+	if creds := env.StringOptional("NATS_CREDENTIALS"); creds != "" {
+		opts = append(opts, nats.UserCredentials(creds))
+	}
+	return nats.Connect(env.String("NATS_URL"), opts...)
+}
+
+// streamName replaces characters which are not allowed in stream and consumer names
+func streamName(topic string) string {
+	return strings.NewReplacer(".", "_", "*", "_", ">", "_", " ", "_").Replace(topic)
+}
+
+func subject(topic string, partition uint64) string {
+	return topic + "." + strconv.FormatUint(partition, 10)
+}
+
+// parseSubject returns the topic and the partition of the message subject
+func parseSubject(subj string) (string, uint64, error) {
+	i := strings.LastIndexByte(subj, '.')
+	if i < 0 {
+		return "", 0, fmt.Errorf("subject without partition: %s", subj)
+	}
+	partition, err := strconv.ParseUint(subj[i+1:], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("wrong partition of subject %s: %s", subj, err)
+	}
+	return subj[:i], partition, nil
+}
+
+// streams creates missing streams of topics, messages are kept for NATS_MAX_AGE after all groups have read them
+type streams struct {
+	js      nats.JetStreamContext
+	mu      sync.Mutex
+	created map[string]bool
+}
+
+func newStreams(js nats.JetStreamContext) *streams {
+	return &streams{js: js, created: make(map[string]bool)}
+}
+
+func (s *streams) ensure(topic string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.created[topic] {
+		return nil
+	}
+	name := streamName(topic)
+	_, err := s.js.StreamInfo(name)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		maxAge := env.DurationOptional("NATS_MAX_AGE")
+		if maxAge == 0 {
+			maxAge = defaultMaxAge
+		}
+		replicas := env.IntOptional("NATS_REPLICAS")
+		if replicas == 0 {
+			replicas = 1
+		}
+		_, err = s.js.AddStream(&nats.StreamConfig{
+			Name:      name,
+			Subjects:  []string{topic + ".*"},
+			Retention: nats.LimitsPolicy,
+			MaxAge:    maxAge,
+			Storage:   nats.FileStorage,
+			Replicas:  replicas,
+		})
+		if errors.Is(err, nats.ErrStreamNameAlreadyInUse) {
+			err = nil // created by another service
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("can't create stream %s: %s", name, err)
+	}
+	s.created[topic] = true
+	return nil
+}
@@ -0,0 +1,80 @@
+package natsstream
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"openreplay/backend/pkg/env"
+)
+
+const defaultMaxPending = 4096
+
+// Producer publishes messages asynchronously, Flush waits for acknowledgements of the server
+type Producer struct {
+	conn       *nats.Conn
+	js         nats.JetStreamContext
+	streams    *streams
+	partitions uint64
+}
+
+func NewProducer() *Producer {
+	conn, err := connect("producer")
+	if err != nil {
+		log.Fatalf("can't connect to nats: %s", err)
+	}
+	maxPending := env.IntOptional("NATS_MAX_PENDING")
+	if maxPending == 0 {
+		maxPending = defaultMaxPending
+	}
+	js, err := conn.JetStream(
+		nats.PublishAsyncMaxPending(maxPending),
+		nats.PublishAsyncErrHandler(func(_ nats.JetStream, msg *nats.Msg, err error) {
+			log.Printf("nats: message isn't delivered: %s, subject: %s, key: %s", err, msg.Subject, msg.Header.Get(keyHeader))
+		}),
+	)
+	if err != nil {
+		log.Fatalf("can't init jetstream: %s", err)
+	}
+	return &Producer{
+		conn:       conn,
+		js:         js,
+		streams:    newStreams(js),
+		partitions: partitionsNumber(),
+	}
+}
+
+func (p *Producer) Produce(topic string, key uint64, value []byte) error {
+	return p.publish(topic, key%p.partitions, key, value)
+}
+
+func (p *Producer) ProduceToPartition(topic string, partition, key uint64, value []byte) error {
+	return p.publish(topic, partition%p.partitions, key, value)
+}
+
+func (p *Producer) publish(topic string, partition, key uint64, value []byte) error {
+	if err := p.streams.ensure(topic); err != nil {
+		return err
+	}
+	msg := nats.NewMsg(subject(topic, partition))
+	msg.Header.Set(keyHeader, strconv.FormatUint(key, 10))
+	msg.Data = value
+	_, err := p.js.PublishMsgAsync(msg)
+	return err
+}
+
+// Flush waits until all published messages are acknowledged, timeout is in milliseconds
+func (p *Producer) Flush(timeout int) {
+	select {
+	case <-p.js.PublishAsyncComplete():
+	case <-time.After(time.Duration(timeout) * time.Millisecond):
+		log.Printf("nats: %d messages aren't acknowledged after flush", p.js.PublishAsyncPending())
+	}
+}
+
+func (p *Producer) Close(timeout int) {
+	p.Flush(timeout)
+	p.conn.Close()
+}
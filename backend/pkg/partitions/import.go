@@ -0,0 +1,6 @@
+package partitions
+
+// NewAnalyticsStore returns no store in the community edition, sessions are stored only in postgres
+func NewAnalyticsStore(_ string) (Store, error) {
+	return nil, nil
+}
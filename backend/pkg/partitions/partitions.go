@@ -0,0 +1,123 @@
+package partitions
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+
+	"openreplay/backend/pkg/monitoring"
+)
+
+// Partition is a time range of the table which is created and dropped as a whole
+type Partition struct {
+	Table string
+	Name  string
+}
+
+// Store manages time partitions of the tables of one database
+type Store interface {
+	// Create adds missing partitions of all months from the month of the first time to the month of the last one,
+	// returns the number of created partitions
+	Create(from, to time.Time) (int, error)
+	// Expired returns partitions with data only older than the time
+	Expired(before time.Time) ([]Partition, error)
+	Drop(p Partition) error
+	Close() error
+}
+
+// Manager creates partitions ahead of time, so rows don't go to default partitions, and drops expired ones
+// which is much cheaper than deleting their rows
+type Manager struct {
+	stores    map[string]Store
+	ahead     int           // months
+	retention time.Duration // 0 keeps all partitions
+	dryRun    bool
+	created   syncfloat64.Counter
+	dropped   syncfloat64.Counter
+	failures  syncfloat64.Counter
+}
+
+func NewManager(stores map[string]Store, ahead int, retention time.Duration, dryRun bool,
+	metrics *monitoring.Metrics) (*Manager, error) {
+	switch {
+	case ahead < 0:
+		return nil, fmt.Errorf("wrong number of months ahead: %d", ahead)
+	case retention < 0:
+		return nil, fmt.Errorf("wrong partition retention: %s", retention)
+	case metrics == nil:
+		return nil, fmt.Errorf("metrics module is empty")
+	}
+	m := &Manager{
+		stores:    stores,
+		ahead:     ahead,
+		retention: retention,
+		dryRun:    dryRun,
+	}
+	var err error
+	if m.created, err = metrics.RegisterCounter("partitions_created"); err != nil {
+		return nil, fmt.Errorf("can't register partitions_created metric: %s", err)
+	}
+	if m.dropped, err = metrics.RegisterCounter("partitions_dropped"); err != nil {
+		return nil, fmt.Errorf("can't register partitions_dropped metric: %s", err)
+	}
+	if m.failures, err = metrics.RegisterCounter("partitions_failures"); err != nil {
+		return nil, fmt.Errorf("can't register partitions_failures metric: %s", err)
+	}
+	return m, nil
+}
+
+// Run manages partitions of all stores, a failed store doesn't stop the others
+func (m *Manager) Run(now time.Time) error {
+	failed := 0
+	for name, store := range m.stores {
+		if err := m.manage(name, store, now); err != nil {
+			log.Printf("partitions: can't manage %s partitions: %s", name, err)
+			m.failures.Add(context.Background(), 1, attribute.String("store", name))
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d stores failed", failed, len(m.stores))
+	}
+	return nil
+}
+
+func (m *Manager) manage(name string, store Store, now time.Time) error {
+	// Partitions are created in the dry run too, they don't change any data
+	created, err := store.Create(now, now.AddDate(0, m.ahead, 0))
+	if created > 0 {
+		log.Printf("partitions: created %d %s partitions", created, name)
+		m.created.Add(context.Background(), float64(created), attribute.String("store", name))
+	}
+	if err != nil {
+		return fmt.Errorf("can't create partitions: %s", err)
+	}
+	if m.retention == 0 {
+		return nil
+	}
+	expired, err := store.Expired(now.Add(-m.retention))
+	if err != nil {
+		return fmt.Errorf("can't get expired partitions: %s", err)
+	}
+	for _, p := range expired {
+		if m.dryRun {
+			log.Printf("partitions (dry run): would drop %s partition %s of %s", name, p.Name, p.Table)
+			continue
+		}
+		if err := store.Drop(p); err != nil {
+			return fmt.Errorf("can't drop partition %s of %s: %s", p.Name, p.Table, err)
+		}
+		log.Printf("partitions: dropped %s partition %s of %s", name, p.Name, p.Table)
+		m.dropped.Add(context.Background(), 1, attribute.String("store", name))
+	}
+	return nil
+}
+
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
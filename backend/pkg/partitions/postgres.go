@@ -0,0 +1,134 @@
+package partitions
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"openreplay/backend/pkg/db/postgres"
+)
+
+// Suffix of monthly partitions, partitions with other names (e.g. the default one) aren't touched
+const monthLayout = "200601"
+
+// postgresStore manages tables which are partitioned by the range of a time column, monthly partitions
+// are named <table>_p<yyyymm>. Tables of the shipped schema aren't partitioned, not partitioned tables are skipped.
+type postgresStore struct {
+	conn    *postgres.Conn
+	tables  []string
+	skipped map[string]bool // not partitioned tables, logged once
+}
+
+func NewPostgresStore(conn *postgres.Conn, tables []string) (Store, error) {
+	if conn == nil {
+		return nil, fmt.Errorf("postgres connection is empty")
+	}
+	return &postgresStore{conn: conn, tables: tables, skipped: make(map[string]bool)}, nil
+}
+
+func (s *postgresStore) Create(from, to time.Time) (int, error) {
+	created := 0
+	for _, table := range s.tables {
+		column, typ, err := s.conn.GetPartitionKey(table)
+		if err != nil {
+			return created, fmt.Errorf("can't get partition key of %s: %s", table, err)
+		}
+		if column == "" {
+			if !s.skipped[table] {
+				log.Printf("partitions: %s isn't partitioned by range, skipped", table)
+				s.skipped[table] = true
+			}
+			continue
+		}
+		existing, err := s.partitions(table)
+		if err != nil {
+			return created, err
+		}
+		for month := monthStart(from); !month.After(to); month = month.AddDate(0, 1, 0) {
+			if existing[month] != "" {
+				continue
+			}
+			lower, err := bound(typ, month)
+			if err != nil {
+				return created, fmt.Errorf("wrong partition key %s of %s: %s", column, table, err)
+			}
+			upper, _ := bound(typ, month.AddDate(0, 1, 0))
+			if err := s.conn.CreatePartition(table, partitionName(table, month), lower, upper); err != nil {
+				return created, fmt.Errorf("can't create partition of %s: %s", table, err)
+			}
+			created++
+		}
+	}
+	return created, nil
+}
+
+func (s *postgresStore) Expired(before time.Time) ([]Partition, error) {
+	var expired []Partition
+	for _, table := range s.tables {
+		if s.skipped[table] {
+			continue
+		}
+		existing, err := s.partitions(table)
+		if err != nil {
+			return nil, err
+		}
+		for month, name := range existing {
+			if !month.AddDate(0, 1, 0).After(before) {
+				expired = append(expired, Partition{Table: table, Name: name})
+			}
+		}
+	}
+	return expired, nil
+}
+
+func (s *postgresStore) Drop(p Partition) error {
+	return s.conn.DropPartition(p.Table, p.Name)
+}
+
+// Close keeps the connection, it's owned by the caller
+func (s *postgresStore) Close() error {
+	return nil
+}
+
+// partitions returns monthly partitions of the table by their first day
+func (s *postgresStore) partitions(table string) (map[time.Time]string, error) {
+	names, err := s.conn.GetPartitions(table)
+	if err != nil {
+		return nil, fmt.Errorf("can't get partitions of %s: %s", table, err)
+	}
+	prefix := partitionName(table, time.Time{})
+	prefix = prefix[:len(prefix)-len(monthLayout)]
+	months := make(map[time.Time]string, len(names))
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		month, err := time.Parse(monthLayout, name[len(prefix):])
+		if err != nil {
+			continue
+		}
+		months[month] = name
+	}
+	return months, nil
+}
+
+// partitionName returns the name of the monthly partition without the schema
+func partitionName(table string, month time.Time) string {
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		table = table[i+1:]
+	}
+	return table + "_p" + month.Format(monthLayout)
+}
+
+// bound returns the SQL literal of the time for the partition key type, bigint keys are timestamps in ms
+// like all timestamps written by the backend
+func bound(typ string, t time.Time) (string, error) {
+	switch {
+	case typ == "bigint":
+		return fmt.Sprintf("%d", t.UnixMilli()), nil
+	case typ == "date", strings.HasPrefix(typ, "timestamp"):
+		return "'" + t.UTC().Format("2006-01-02 15:04:05") + "+00'", nil
+	}
+	return "", fmt.Errorf("unsupported type %s", typ)
+}
@@ -0,0 +1,147 @@
+package pii
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"openreplay/backend/pkg/messages"
+)
+
+const maxFindingURLs = 10
+
+// Finding groups matches of one rule in one field of one message type
+type Finding struct {
+	MessageType string   `json:"messageType"`
+	Field       string   `json:"field"`
+	Rule        string   `json:"rule"`
+	Count       int      `json:"count"`
+	Sessions    int      `json:"sessions"`
+	Example     string   `json:"example"` // masked, the report itself mustn't leak data
+	URLs        []string `json:"urls"`    // pages without query where matches were found, limited to maxFindingURLs
+	sessions    map[uint64]bool
+}
+
+// Report is the result of the detection-only run over sampled sessions of the project
+type Report struct {
+	ProjectID uint32     `json:"projectId"`
+	CreatedAt time.Time  `json:"createdAt"`
+	Sessions  int        `json:"sessions"`
+	Messages  int        `json:"messages"`
+	Findings  []*Finding `json:"findings"` // the most frequent first
+	engine    *Engine
+	findings  map[string]*Finding
+	pageURL   string
+}
+
+func NewReport(projectID uint32, engine *Engine) (*Report, error) {
+	if engine == nil {
+		return nil, fmt.Errorf("rule engine is empty")
+	}
+	if engine.mode != ModeDetect {
+		return nil, fmt.Errorf("rule engine has to be in detection mode")
+	}
+	return &Report{
+		ProjectID: projectID,
+		CreatedAt: time.Now().UTC(),
+		engine:    engine,
+		findings:  make(map[string]*Finding),
+	}, nil
+}
+
+// AddSession checks all string fields of the session messages
+func (r *Report) AddSession(sessionID uint64, data []byte) error {
+	r.Sessions++
+	r.pageURL = ""
+	return messages.ReadSessionFile(data, func(msg messages.Message) {
+		r.Messages++
+		if m, ok := msg.(*messages.SetPageLocation); ok {
+			r.pageURL = r.pagePath(m.URL)
+		}
+		r.addMessage(sessionID, msg)
+	})
+}
+
+func (r *Report) addMessage(sessionID uint64, msg messages.Message) {
+	value := reflect.ValueOf(msg).Elem()
+	msgType := value.Type().Name()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.Anonymous || field.Type.Kind() != reflect.String {
+			continue
+		}
+		_, matches := r.engine.Apply(value.Field(i).String())
+		for _, match := range matches {
+			r.addMatch(sessionID, msgType, field.Name, match)
+		}
+	}
+}
+
+func (r *Report) addMatch(sessionID uint64, msgType, field string, match Match) {
+	key := msgType + "." + field + "." + match.Rule
+	f, ok := r.findings[key]
+	if !ok {
+		f = &Finding{
+			MessageType: msgType,
+			Field:       field,
+			Rule:        match.Rule,
+			Example:     maskValue(match.Value),
+			sessions:    make(map[uint64]bool),
+		}
+		r.findings[key] = f
+	}
+	f.Count++
+	f.sessions[sessionID] = true
+	if r.pageURL == "" || len(f.URLs) >= maxFindingURLs {
+		return
+	}
+	for _, u := range f.URLs {
+		if u == r.pageURL {
+			return
+		}
+	}
+	f.URLs = append(f.URLs, r.pageURL)
+}
+
+// Finish sorts findings, the report shouldn't be changed after it
+func (r *Report) Finish() *Report {
+	r.Findings = make([]*Finding, 0, len(r.findings))
+	for _, f := range r.findings {
+		f.Sessions = len(f.sessions)
+		r.Findings = append(r.Findings, f)
+	}
+	sort.Slice(r.Findings, func(i, j int) bool {
+		if r.Findings[i].Sessions != r.Findings[j].Sessions {
+			return r.Findings[i].Sessions > r.Findings[j].Sessions
+		}
+		return r.Findings[i].Count > r.Findings[j].Count
+	})
+	return r
+}
+
+// pagePath drops query and fragment and masks matches in the path, they are reported as findings of the URL field
+func (r *Report) pagePath(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	u.RawQuery, u.Fragment, u.User = "", "", nil
+	path := u.String()
+	_, matches := r.engine.Apply(path)
+	for _, match := range matches {
+		path = strings.ReplaceAll(path, match.Value, maskValue(match.Value))
+	}
+	return path
+}
+
+// maskValue keeps only the first and the last characters
+func maskValue(value string) string {
+	runes := []rune(value)
+	if len(runes) <= 4 {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[:2]) + strings.Repeat("*", len(runes)-4) + string(runes[len(runes)-2:])
+}
@@ -0,0 +1,143 @@
+package pii
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+type Mode int
+
+const (
+	ModeRedact Mode = iota // matches are replaced with the mask
+	ModeDetect             // values are kept, only matched rules are reported
+)
+
+const mask = "***"
+
+// Rule finds one kind of personal data, validate filters false positives of the pattern
+type Rule struct {
+	Name     string
+	pattern  *regexp.Regexp
+	validate func(match string) bool
+}
+
+var defaultRules = []*Rule{
+	{Name: "email", pattern: regexp.MustCompile(`[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}`)},
+	{Name: "credit_card", pattern: regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`), validate: luhn},
+	{Name: "ssn", pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)},
+	{Name: "phone", pattern: regexp.MustCompile(`\+\d{1,3}[ \-]?\(?\d{1,4}\)?(?:[ \-]?\d{2,4}){2,4}\b`)},
+	{Name: "iban", pattern: regexp.MustCompile(`\b[A-Z]{2}\d{2}[A-Z0-9]{11,30}\b`), validate: iban},
+	{Name: "jwt", pattern: regexp.MustCompile(`\beyJ[a-zA-Z0-9_\-]{8,}\.eyJ[a-zA-Z0-9_\-]{8,}\.[a-zA-Z0-9_\-]+`)},
+	{Name: "api_key", pattern: regexp.MustCompile(`\b(?:sk_live_[a-zA-Z0-9]{16,}|AKIA[A-Z0-9]{16}|gh[pousr]_[a-zA-Z0-9]{36}|xox[abpr]-[a-zA-Z0-9\-]{10,})\b`)},
+	{Name: "ipv4", pattern: regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`)},
+}
+
+// Engine applies scrubbing rules to string values
+type Engine struct {
+	mode  Mode
+	rules []*Rule
+}
+
+// NewEngine uses default rules with the given names, empty list enables all of them.
+// Custom rules are name:regexp pairs.
+func NewEngine(mode Mode, names []string, custom []string) (*Engine, error) {
+	e := &Engine{mode: mode}
+	enabled := make(map[string]bool, len(names))
+	for _, name := range names {
+		enabled[strings.TrimSpace(name)] = true
+	}
+	for _, rule := range defaultRules {
+		if len(enabled) == 0 || enabled[rule.Name] {
+			e.rules = append(e.rules, rule)
+			delete(enabled, rule.Name)
+		}
+	}
+	for name := range enabled {
+		return nil, fmt.Errorf("unknown rule: %s", name)
+	}
+	for _, pair := range custom {
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("wrong custom rule: %s", pair)
+		}
+		pattern, err := regexp.Compile(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("wrong pattern of rule %s: %s", parts[0], err)
+		}
+		e.rules = append(e.rules, &Rule{Name: parts[0], pattern: pattern})
+	}
+	return e, nil
+}
+
+// Match is the found piece of personal data
+type Match struct {
+	Rule  string
+	Value string
+}
+
+// Apply returns the scrubbed value and all matches, the value isn't changed in detection mode
+func (e *Engine) Apply(value string) (string, []Match) {
+	var matches []Match
+	for _, rule := range e.rules {
+		value = rule.pattern.ReplaceAllStringFunc(value, func(found string) string {
+			if rule.validate != nil && !rule.validate(found) {
+				return found
+			}
+			matches = append(matches, Match{Rule: rule.Name, Value: found})
+			if e.mode == ModeDetect {
+				return found
+			}
+			return mask
+		})
+	}
+	return value, matches
+}
+
+func digits(s string) []int {
+	var res []int
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			res = append(res, int(c-'0'))
+		}
+	}
+	return res
+}
+
+// luhn checks the card number checksum
+func luhn(s string) bool {
+	d := digits(s)
+	if len(d) < 13 || len(d) > 19 {
+		return false
+	}
+	sum := 0
+	for i := range d {
+		n := d[len(d)-1-i]
+		if i%2 == 1 {
+			if n *= 2; n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+	}
+	return sum%10 == 0
+}
+
+// iban checks mod-97 of the rearranged account number
+func iban(s string) bool {
+	rearranged := s[4:] + s[:4]
+	var numeric strings.Builder
+	for _, c := range rearranged {
+		switch {
+		case c >= '0' && c <= '9':
+			numeric.WriteRune(c)
+		case c >= 'A' && c <= 'Z':
+			numeric.WriteString(fmt.Sprint(int(c-'A') + 10))
+		default:
+			return false
+		}
+	}
+	n, ok := new(big.Int).SetString(numeric.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
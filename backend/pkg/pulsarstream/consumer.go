@@ -0,0 +1,175 @@
+package pulsarstream
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+
+	"openreplay/backend/pkg/queue/types"
+)
+
+const (
+	pollTimeout        = 200 * time.Millisecond
+	receiverQueueSize  = 1000
+	defaultTopicPrefix = "persistent://public/default/"
+	partitionSuffix    = "-partition-"
+)
+
+type pendingMessage struct {
+	msg pulsar.Message
+	ts  int64
+}
+
+// Consumer reads topics through one subscription of the group. Pulsar balances messages between consumers
+// of the subscription instead of partitions, so there are no partition assignments to report and state of
+// sessions can't be handed over. Handled messages are acknowledged on commit, not acknowledged ones are
+// redelivered to other consumers after the close.
+type Consumer struct {
+	client     pulsar.Client
+	consumer   pulsar.Consumer
+	handler    types.MessageHandler
+	autoCommit bool
+	topics     map[string]string // full names of topics to the names used by services
+	pending    []pendingMessage  // handled but not acknowledged messages
+	lastTs     int64
+}
+
+func NewConsumer(group string, topics []string, handler types.MessageHandler, autoCommit bool) *Consumer {
+	client, err := newClient()
+	if err != nil {
+		log.Fatalf("can't connect to pulsar: %s", err)
+	}
+	subType, err := subscriptionType()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	consumer, err := client.Subscribe(pulsar.ConsumerOptions{
+		Topics:                      topics,
+		SubscriptionName:            group,
+		Type:                        subType,
+		SubscriptionInitialPosition: pulsar.SubscriptionPositionEarliest,
+		ReceiverQueueSize:           receiverQueueSize,
+	})
+	if err != nil {
+		log.Fatalf("can't subscribe to %s: %s", strings.Join(topics, ","), err)
+	}
+	c := &Consumer{
+		client:     client,
+		consumer:   consumer,
+		handler:    handler,
+		autoCommit: autoCommit,
+		topics:     make(map[string]string, len(topics)),
+	}
+	for _, topic := range topics {
+		c.topics[fullTopicName(topic)] = topic
+	}
+	return c
+}
+
+// fullTopicName adds the default tenant and namespace to short names, the same way as the client does
+func fullTopicName(topic string) string {
+	if strings.Contains(topic, "://") {
+		return topic
+	}
+	return defaultTopicPrefix + topic
+}
+
+// parseTopic returns the topic of the service and the partition of the message topic
+func (c *Consumer) parseTopic(name string) (string, uint64, error) {
+	var partition uint64
+	if i := strings.LastIndex(name, partitionSuffix); i >= 0 {
+		p, err := strconv.ParseUint(name[i+len(partitionSuffix):], 10, 64)
+		if err != nil {
+			return "", 0, fmt.Errorf("wrong partition of topic %s: %s", name, err)
+		}
+		name, partition = name[:i], p
+	}
+	topic, ok := c.topics[name]
+	if !ok {
+		return "", 0, fmt.Errorf("unknown topic: %s", name)
+	}
+	return topic, partition, nil
+}
+
+func (c *Consumer) ConsumeNext() error {
+	timer := time.NewTimer(pollTimeout)
+	defer timer.Stop()
+	select {
+	case cm := <-c.consumer.Chan():
+		return c.handle(cm.Message)
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (c *Consumer) handle(msg pulsar.Message) error {
+	topic, partition, err := c.parseTopic(msg.Topic())
+	if err != nil {
+		return fmt.Errorf("pulsar: %s", err)
+	}
+	key, err := strconv.ParseUint(msg.Key(), 10, 64)
+	if err != nil {
+		// Messages without keys aren't produced by the backend, they would be redelivered forever
+		log.Printf("pulsar: message without key, topic: %s, entry: %d", msg.Topic(), msg.ID().EntryID())
+		c.consumer.Ack(msg)
+		return nil
+	}
+	ts := msg.PublishTime().UnixMilli()
+	c.handler(key, msg.Payload(), &types.Meta{
+		ID:        uint64(msg.ID().EntryID()),
+		Topic:     topic,
+		Partition: partition,
+		Timestamp: ts,
+	})
+	if c.autoCommit {
+		c.consumer.Ack(msg)
+		return nil
+	}
+	c.lastTs = ts
+	c.pending = append(c.pending, pendingMessage{msg: msg, ts: ts})
+	return nil
+}
+
+// ack acknowledges the first n pending messages, messages of shared subscriptions are acknowledged one by one
+func (c *Consumer) ack(n int) error {
+	for _, p := range c.pending[:n] {
+		c.consumer.Ack(p.msg)
+	}
+	c.pending = c.pending[n:]
+	return nil
+}
+
+func (c *Consumer) Commit() error {
+	return c.ack(len(c.pending))
+}
+
+// CommitBack acknowledges messages which are older than the last one by gap milliseconds
+func (c *Consumer) CommitBack(gap int64) error {
+	if c.lastTs == 0 {
+		return nil
+	}
+	maxTs := c.lastTs - gap
+	n := 0
+	for n < len(c.pending) && c.pending[n].ts <= maxTs {
+		n++
+	}
+	return c.ack(n)
+}
+
+// Close leaves the subscription on the server, not acknowledged messages are redelivered to other consumers
+func (c *Consumer) Close() {
+	c.pending = nil
+	c.consumer.Close()
+	c.client.Close()
+}
+
+func (c *Consumer) HasFirstPartition() bool {
+	return false
+}
+
+// SetPartitionListener does nothing because partitions aren't assigned to consumers of the subscription
+func (c *Consumer) SetPartitionListener(_ types.PartitionListener) {}
@@ -0,0 +1,92 @@
+package pulsarstream
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+// Producer sends messages asynchronously with one pulsar producer per topic, Flush waits for acknowledgements
+type Producer struct {
+	client    pulsar.Client
+	mu        sync.Mutex
+	producers map[string]pulsar.Producer
+}
+
+func NewProducer() *Producer {
+	client, err := newClient()
+	if err != nil {
+		log.Fatalf("can't connect to pulsar: %s", err)
+	}
+	return &Producer{
+		client:    client,
+		producers: make(map[string]pulsar.Producer),
+	}
+}
+
+func (p *Producer) producer(topic string) (pulsar.Producer, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if producer, ok := p.producers[topic]; ok {
+		return producer, nil
+	}
+	producer, err := p.client.CreateProducer(pulsar.ProducerOptions{
+		Topic:         topic,
+		MessageRouter: route,
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.producers[topic] = producer
+	return producer, nil
+}
+
+func (p *Producer) Produce(topic string, key uint64, value []byte) error {
+	return p.send(topic, key, value, nil)
+}
+
+func (p *Producer) ProduceToPartition(topic string, partition, key uint64, value []byte) error {
+	return p.send(topic, key, value, map[string]string{partitionProperty: strconv.FormatUint(partition, 10)})
+}
+
+func (p *Producer) send(topic string, key uint64, value []byte, properties map[string]string) error {
+	producer, err := p.producer(topic)
+	if err != nil {
+		return err
+	}
+	producer.SendAsync(context.Background(), &pulsar.ProducerMessage{
+		Key:        strconv.FormatUint(key, 10),
+		Payload:    value,
+		Properties: properties,
+	}, func(_ pulsar.MessageID, msg *pulsar.ProducerMessage, err error) {
+		if err != nil {
+			log.Printf("pulsar: message isn't delivered: %s, topic: %s, key: %s", err, topic, msg.Key)
+		}
+	})
+	return nil
+}
+
+// Flush waits until all sent messages are acknowledged, pulsar producers have no flush timeout
+func (p *Producer) Flush(_ int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for topic, producer := range p.producers {
+		if err := producer.Flush(); err != nil {
+			log.Printf("pulsar: can't flush producer of %s: %s", topic, err)
+		}
+	}
+}
+
+func (p *Producer) Close(timeout int) {
+	p.Flush(timeout)
+	p.mu.Lock()
+	for _, producer := range p.producers {
+		producer.Close()
+	}
+	p.producers = make(map[string]pulsar.Producer)
+	p.mu.Unlock()
+	p.client.Close()
+}
@@ -0,0 +1,67 @@
+package pulsarstream
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+
+	"openreplay/backend/pkg/env"
+)
+
+// Topics are partitioned topics of the cluster, messages are routed to partitions by key the same way
+// as in kafka, so sessions keep the order of their batches. The key is also the pulsar message key,
+// key_shared subscriptions deliver all messages of a session to the same consumer.
+const (
+	partitionProperty   = "openreplay-partition" // set by ProduceToPartition, overrides routing by key
+	operationTimeout    = 30 * time.Second
+	connectionTimeout   = 10 * time.Second
+	defaultSubscription = "key_shared"
+)
+
+var subscriptionTypes = map[string]pulsar.SubscriptionType{
+	"shared":     pulsar.Shared,    // messages are spread between consumers without any order
+	"key_shared": pulsar.KeyShared, // messages of one key are handled by one consumer in order
+}
+
+// newClient connects to PULSAR_URL, PULSAR_TOKEN enables token authentication, PULSAR_TLS_CA replaces system CAs
+func newClient() (pulsar.Client, error) {
+	opts := pulsar.ClientOptions{
+		URL:               env.String("PULSAR_URL"),
+		OperationTimeout:  operationTimeout,
+		ConnectionTimeout: connectionTimeout,
+	}
+	if token := env.StringOptional("PULSAR_TOKEN"); token != "" {
+		opts.Authentication = pulsar.NewAuthenticationToken(token)
+	}
+	if ca := env.StringOptional("PULSAR_TLS_CA"); ca != "" {
+		opts.TLSTrustCertsFilePath = ca
+	}
+	return pulsar.NewClient(opts)
+}
+
+func subscriptionType() (pulsar.SubscriptionType, error) {
+	name := env.StringOptional("PULSAR_SUBSCRIPTION_TYPE")
+	if name == "" {
+		name = defaultSubscription
+	}
+	t, ok := subscriptionTypes[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown pulsar subscription type: %s", name)
+	}
+	return t, nil
+}
+
+// route chooses the partition of the message, non-partitioned topics have the only one
+func route(msg *pulsar.ProducerMessage, topic pulsar.TopicMetadata) int {
+	partitions := uint64(topic.NumPartitions())
+	if partitions == 0 {
+		return 0
+	}
+	if p, err := strconv.ParseUint(msg.Properties[partitionProperty], 10, 64); err == nil {
+		return int(p % partitions)
+	}
+	key, _ := strconv.ParseUint(msg.Key, 10, 64)
+	return int(key % partitions)
+}
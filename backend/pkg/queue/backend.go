@@ -0,0 +1,23 @@
+package queue
+
+import "openreplay/backend/pkg/env"
+
+// useNATS is true if QUEUE_BACKEND is "nats", JetStream replaces the default queue of the edition
+func useNATS() bool {
+	return env.StringOptional("QUEUE_BACKEND") == "nats"
+}
+
+// useRedis is true if QUEUE_BACKEND is "redis", single node installs can run without kafka
+func useRedis() bool {
+	return env.StringOptional("QUEUE_BACKEND") == "redis"
+}
+
+// usePulsar is true if QUEUE_BACKEND is "pulsar", for platforms which run pulsar instead of kafka
+func usePulsar() bool {
+	return env.StringOptional("QUEUE_BACKEND") == "pulsar"
+}
+
+// useMemory is true if QUEUE_BACKEND is "memory", for single-binary installs where all services run in one process
+func useMemory() bool {
+	return env.StringOptional("QUEUE_BACKEND") == "memory"
+}
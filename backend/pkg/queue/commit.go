@@ -0,0 +1,130 @@
+package queue
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"openreplay/backend/pkg/env"
+	"openreplay/backend/pkg/queue/types"
+)
+
+const (
+	commitAtLeastOnce = "at_least_once" // the service commits offsets after messages are processed and saved
+	commitBestEffort  = "best_effort"   // the consumer commits offsets periodically, messages can be lost on crashes
+
+	inFlightPause = 200 * time.Millisecond
+)
+
+// autoCommitMode returns autoCommit of the consumer, QUEUE_COMMIT_MODE overrides the default of the service
+func autoCommitMode(group string, autoCommit bool) bool {
+	switch mode := env.StringOptional("QUEUE_COMMIT_MODE"); mode {
+	case "":
+		return autoCommit
+	case commitAtLeastOnce:
+		return false
+	case commitBestEffort:
+		return true
+	default:
+		log.Fatalf("unknown commit mode of group %s: %s", group, mode)
+	}
+	return autoCommit
+}
+
+// inFlightLimiter stops reading when the consumer has max handled but not committed batches, so the service
+// can't get too far ahead of its last commit and the number of batches read again after a crash is bounded.
+// Reading continues after the service commits them.
+type inFlightLimiter struct {
+	types.Consumer
+	group   string
+	max     int
+	mu      sync.Mutex
+	batches []int64 // timestamps of handled but not committed batches
+	lastTs  int64
+	paused  bool
+}
+
+func (l *inFlightLimiter) wrap(handler types.MessageHandler) types.MessageHandler {
+	return func(sessionID uint64, value []byte, meta *types.Meta) {
+		handler(sessionID, value, meta)
+		l.mu.Lock()
+		l.batches = append(l.batches, meta.Timestamp)
+		if meta.Timestamp > l.lastTs {
+			l.lastTs = meta.Timestamp
+		}
+		l.mu.Unlock()
+	}
+}
+
+func (l *inFlightLimiter) full() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	full := len(l.batches) >= l.max
+	if full && !l.paused {
+		log.Printf("group %s has %d not committed batches, reading is paused until the commit", l.group, len(l.batches))
+	}
+	l.paused = full
+	return full
+}
+
+func (l *inFlightLimiter) ConsumeNext() error {
+	if l.full() {
+		time.Sleep(inFlightPause)
+		return nil
+	}
+	return l.Consumer.ConsumeNext()
+}
+
+func (l *inFlightLimiter) Commit() error {
+	if err := l.Consumer.Commit(); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.batches = l.batches[:0]
+	l.mu.Unlock()
+	return nil
+}
+
+// CommitBack releases batches which are older than the last one by gap milliseconds, the same ones the consumer commits
+func (l *inFlightLimiter) CommitBack(gap int64) error {
+	if err := l.Consumer.CommitBack(gap); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	maxTs := l.lastTs - gap
+	rest := l.batches[:0]
+	for _, ts := range l.batches {
+		if ts > maxTs {
+			rest = append(rest, ts)
+		}
+	}
+	l.batches = rest
+	return nil
+}
+
+// limitInFlight wraps the consumer if QUEUE_MAX_IN_FLIGHT is set, consumers with auto commit aren't limited
+// because they don't wait for the service
+func limitInFlight(group string, autoCommit bool, newConsumer func(types.MessageHandler) types.Consumer, handler types.MessageHandler) types.Consumer {
+	max := env.IntOptional("QUEUE_MAX_IN_FLIGHT")
+	if max <= 0 || autoCommit {
+		return newConsumer(handler)
+	}
+	l := &inFlightLimiter{group: group, max: max}
+	l.Consumer = newConsumer(l.wrap(handler))
+	return l
+}
+
+// unwrapConsumer returns the consumer of the queue backend
+func unwrapConsumer(consumer types.Consumer) types.Consumer {
+	if s, ok := consumer.(*spillingConsumer); ok {
+		consumer = s.Consumer
+	}
+	if d, ok := consumer.(*batchDedup); ok {
+		consumer = d.Consumer
+	}
+	if l, ok := consumer.(*inFlightLimiter); ok {
+		return l.Consumer
+	}
+	return consumer
+}
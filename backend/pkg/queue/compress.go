@@ -0,0 +1,181 @@
+package queue
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+
+	"openreplay/backend/pkg/env"
+	"openreplay/backend/pkg/messages"
+	"openreplay/backend/pkg/queue/types"
+)
+
+// Compressed values start with the magic and the codec id. Batches can't start with 0xFF,
+// because all message types are less than 128 and fit into one byte.
+var compressionMagic = []byte{0xFF, 'O', 'R', 'Z'}
+
+const (
+	codecLZ4  byte = 1
+	codecZstd byte = 2
+)
+
+var codecs = map[string]byte{
+	"lz4":  codecLZ4,
+	"zstd": codecZstd,
+}
+
+var zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest))
+
+// Stream decoders are reused, one decoder per concurrent batch. Single-threaded decoders
+// don't start goroutines and keep only the current block in memory.
+var (
+	zstdDecoders = sync.Pool{New: func() interface{} {
+		decoder, _ := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderLowmem(true))
+		return decoder
+	}}
+	lz4Decoders = sync.Pool{New: func() interface{} {
+		return lz4.NewReader(nil)
+	}}
+)
+
+func compress(codec byte, value []byte) ([]byte, error) {
+	header := append(append(make([]byte, 0, len(compressionMagic)+1), compressionMagic...), codec)
+	switch codec {
+	case codecZstd:
+		return zstdEncoder.EncodeAll(value, header), nil
+	case codecLZ4:
+		buf := bytes.NewBuffer(header)
+		writer := lz4.NewWriter(buf)
+		if _, err := writer.Write(value); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("unknown codec: %d", codec)
+}
+
+// decompressStream returns the reader of the decompressed value, batches are decompressed while they
+// are decoded. Values without the magic are read as is, so topics can be switched to compression
+// at any time. release returns the decoder to the pool, the reader can't be used after it.
+func decompressStream(value []byte) (reader io.Reader, release func(), err error) {
+	if !bytes.HasPrefix(value, compressionMagic) || len(value) <= len(compressionMagic) {
+		return bytes.NewReader(value), func() {}, nil
+	}
+	codec, data := value[len(compressionMagic)], value[len(compressionMagic)+1:]
+	switch codec {
+	case codecZstd:
+		decoder := zstdDecoders.Get().(*zstd.Decoder)
+		if err := decoder.Reset(bytes.NewReader(data)); err != nil {
+			zstdDecoders.Put(decoder)
+			return nil, nil, err
+		}
+		return decoder, func() {
+			decoder.Reset(nil)
+			zstdDecoders.Put(decoder)
+		}, nil
+	case codecLZ4:
+		decoder := lz4Decoders.Get().(*lz4.Reader)
+		decoder.Reset(bytes.NewReader(data))
+		return decoder, func() {
+			decoder.Reset(nil)
+			lz4Decoders.Put(decoder)
+		}, nil
+	}
+	return nil, nil, fmt.Errorf("unknown codec: %d", codec)
+}
+
+// compressingProducer compresses batches of the chosen topics, smaller values aren't worth it
+type compressingProducer struct {
+	types.Producer
+	codec    byte
+	minSize  int
+	topics   map[string]bool // nil compresses values of all topics
+	rawBytes uint64
+	sent     uint64
+}
+
+func (p *compressingProducer) compress(topic string, value []byte) []byte {
+	// Protobuf topics are read by third-party consumers, they don't know the compression of batches
+	if len(value) < p.minSize || (p.topics != nil && !p.topics[topic]) || isProtobufTopic(topic) {
+		return value
+	}
+	compressed, err := compress(p.codec, value)
+	if err != nil || len(compressed) >= len(value) {
+		return value
+	}
+	atomic.AddUint64(&p.rawBytes, uint64(len(value)))
+	atomic.AddUint64(&p.sent, uint64(len(compressed)))
+	return compressed
+}
+
+func (p *compressingProducer) Produce(topic string, key uint64, value []byte) error {
+	return p.Producer.Produce(topic, key, p.compress(topic, value))
+}
+
+func (p *compressingProducer) ProduceToPartition(topic string, partition, key uint64, value []byte) error {
+	return p.Producer.ProduceToPartition(topic, partition, key, p.compress(topic, value))
+}
+
+func (p *compressingProducer) report() {
+	for range time.Tick(time.Minute) {
+		rawBytes, sent := atomic.SwapUint64(&p.rawBytes, 0), atomic.SwapUint64(&p.sent, 0)
+		if rawBytes > 0 {
+			log.Printf("compressed batches: %d bytes -> %d bytes", rawBytes, sent)
+		}
+	}
+}
+
+// compressBatches wraps the producer if QUEUE_COMPRESSION is set to lz4 or zstd. QUEUE_COMPRESSION_TOPICS
+// limits compression to the given topics, all their consumers have to support decompression.
+func compressBatches(producer types.Producer) types.Producer {
+	name := env.StringOptional("QUEUE_COMPRESSION")
+	if name == "" {
+		return producer
+	}
+	codec, ok := codecs[name]
+	if !ok {
+		log.Fatalf("unknown queue compression: %s", name)
+	}
+	p := &compressingProducer{
+		Producer: producer,
+		codec:    codec,
+		minSize:  env.IntOptional("QUEUE_COMPRESSION_MIN_SIZE"),
+	}
+	if topics := env.StringListOptional("QUEUE_COMPRESSION_TOPICS"); len(topics) > 0 {
+		p.topics = make(map[string]bool, len(topics))
+		for _, topic := range topics {
+			p.topics[topic] = true
+		}
+	}
+	go p.report()
+	return p
+}
+
+// streamBatch is applied to all message consumers, so producers can enable compression independently.
+// Messages are decoded from the stream, big batches are never decompressed into memory as a whole.
+// The iterator counts handled messages even if the handler panics.
+func streamBatch(handler types.RawMessageHandler, sessionID uint64, value []byte, meta *types.Meta, iter *countingIterator) error {
+	value, err := decodeBatch(meta.Topic, value)
+	if err != nil {
+		return fmt.Errorf("can't decode protobuf batch: %s", err)
+	}
+	reader, release, err := decompressStream(value)
+	if err != nil {
+		return fmt.Errorf("can't decompress batch: %s", err)
+	}
+	defer release()
+	iter.Iterator = messages.NewReaderIterator(reader)
+	handler(sessionID, iter, meta)
+	iter.finished = true
+	return iter.Err()
+}
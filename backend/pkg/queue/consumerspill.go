@@ -0,0 +1,147 @@
+package queue
+
+import (
+	"log"
+	"time"
+
+	"openreplay/backend/pkg/queue/types"
+)
+
+// ConsumerSpill keeps consumed batches in the disk spool while the store of the service is unavailable
+// for longer than timeout, so the consumer goes on and commits them instead of blocking the group until
+// the queue retention drops them. Spilled batches are replayed to the handler when the store is available
+// again, new batches go to the spool until it's empty, so batches of one session keep the order.
+type ConsumerSpill struct {
+	spool     *DiskSpool
+	health    HealthChecker
+	timeout   time.Duration
+	spilling  bool // batches spilled before the restart are replayed first
+	lastProbe time.Time
+}
+
+const spillProbePeriod = time.Second
+
+func NewConsumerSpill(spool *DiskSpool, health HealthChecker, timeout time.Duration) *ConsumerSpill {
+	return &ConsumerSpill{
+		spool:    spool,
+		health:   health,
+		timeout:  timeout,
+		spilling: true,
+	}
+}
+
+// wrap expects the regular consumer, its handler and Commit are called from one goroutine
+func (s *ConsumerSpill) wrap(handler types.MessageHandler) types.MessageHandler {
+	return func(sessionID uint64, value []byte, meta *types.Meta) {
+		if !s.spilling {
+			if unavailable := s.health.Unavailable(); unavailable > 0 && unavailable >= s.timeout {
+				log.Printf("store is unavailable for %s, spilling batches to disk", unavailable)
+				s.spilling = true
+			}
+		}
+		if s.spilling {
+			s.replay(handler)
+		}
+		if s.spilling {
+			err := s.spool.Spill(sessionID, value, meta)
+			if err == nil {
+				return
+			}
+			// The batch is handled as without the spill, the handler fails or blocks on the store
+			log.Printf("can't spill batch, sessID: %d, topic: %s, err: %s", sessionID, meta.Topic, err)
+		}
+		handler(sessionID, value, meta)
+	}
+}
+
+// replay moves spilled batches to the handler if the store is available, the store is probed once per period
+func (s *ConsumerSpill) replay(handler types.MessageHandler) {
+	if time.Since(s.lastProbe) < spillProbePeriod {
+		return
+	}
+	s.lastProbe = time.Now()
+	if err := s.health.Ping(); err != nil {
+		return
+	}
+	stop := func() bool {
+		return s.health.Unavailable() > 0
+	}
+	total := 0
+	for {
+		n, err := s.spool.Replay(handler, stop)
+		total += n
+		if err != nil {
+			log.Printf("can't replay spilled batches: %s, replayed: %d", err, total)
+			return
+		}
+		if n == 0 {
+			break
+		}
+	}
+	s.spilling = false
+	if total > 0 {
+		log.Printf("store is available, replayed spilled batches: %d", total)
+	}
+}
+
+// spillingConsumer syncs spilled batches before the commit of their offsets and replays them
+// when there are no new batches
+type spillingConsumer struct {
+	types.Consumer
+	spill   *ConsumerSpill
+	handler types.MessageHandler
+}
+
+func (c *spillingConsumer) Commit() error {
+	c.spill.spool.Flush(0)
+	if c.spill.spilling {
+		c.spill.replay(c.handler)
+	}
+	return c.Consumer.Commit()
+}
+
+func (c *spillingConsumer) Close() {
+	c.Consumer.Close()
+	c.spill.spool.Close(0)
+}
+
+// healthGroup is unavailable while any of its checkers is unavailable
+type healthGroup []HealthChecker
+
+// JoinHealth checks all stores of the service, e.g. postgres and clickhouse
+func JoinHealth(checkers ...HealthChecker) HealthChecker {
+	return healthGroup(checkers)
+}
+
+func (g healthGroup) Ping() error {
+	for _, checker := range g {
+		if err := checker.Ping(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g healthGroup) Unavailable() time.Duration {
+	var longest time.Duration
+	for _, checker := range g {
+		if unavailable := checker.Unavailable(); unavailable > longest {
+			longest = unavailable
+		}
+	}
+	return longest
+}
+
+// NewSpillingMessageConsumer is the regular message consumer with the spill in front of the handler.
+// Stale batches are dropped before the spill, spilled batches expire by the max age of the spool.
+func NewSpillingMessageConsumer(group string, topics []string, handler types.RawMessageHandler, autoCommit bool,
+	messageSizeLimit int, spill *ConsumerSpill) types.Consumer {
+	autoCommit = autoCommitMode(group, autoCommit)
+	batches := readRedriven(group, deadLetters(group, handler, messageSizeLimit))
+	consumer := dedupBatches(group, autoCommit, func(handler types.MessageHandler) types.Consumer {
+		return limitInFlight(group, autoCommit, func(handler types.MessageHandler) types.Consumer {
+			return NewConsumer(group, topics, handler, autoCommit, messageSizeLimit)
+		}, dropStaleMessages(handler))
+	}, spill.wrap(batches))
+	return &spillingConsumer{Consumer: consumer, spill: spill, handler: batches}
+}
@@ -0,0 +1,251 @@
+package queue
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+
+	"openreplay/backend/pkg/env"
+	"openreplay/backend/pkg/messages"
+	"openreplay/backend/pkg/queue/types"
+)
+
+const (
+	deadLetterFlushTimeout    = 5000
+	defaultDeadLetterAttempts = 3
+	deadLetterRetryDelay      = 200 * time.Millisecond // multiplied by the number of the failed attempt
+)
+
+// Redriven values start with the magic, the consumer group which failed to process the batch and
+// the number of messages it handled before the failure. Other groups of the topic skip them. The magic
+// can't be the start of a batch, the same as the compression one.
+var redriveMagic = []byte{0xFF, 'O', 'R', 'R'}
+
+// DeadLetter is a batch which the consumer group couldn't process, it's published to QUEUE_DLQ_TOPIC as JSON
+type DeadLetter struct {
+	Group     string `json:"group"`
+	Topic     string `json:"topic"`
+	Partition uint64 `json:"partition"`
+	MessageID uint64 `json:"messageId"`
+	Timestamp int64  `json:"timestamp"` // time of the batch in the source topic, unix ms
+	SessionID uint64 `json:"sessionId"`
+	Error     string `json:"error"`
+	Attempts  int    `json:"attempts"`
+	Handled   uint64 `json:"handled"`  // messages of the batch handled before the failure, redrive skips them
+	FailedAt  int64  `json:"failedAt"` // unix ms
+	Value     []byte `json:"value"`    // the batch as it was read from the source topic
+}
+
+func DecodeDeadLetter(data []byte) (*DeadLetter, error) {
+	letter := &DeadLetter{}
+	if err := json.Unmarshal(data, letter); err != nil {
+		return nil, err
+	}
+	if letter.Group == "" || letter.Topic == "" {
+		return nil, fmt.Errorf("dead letter without group or topic")
+	}
+	return letter, nil
+}
+
+// Redrive returns the value which is processed only by the group of the letter
+func (l *DeadLetter) Redrive() []byte {
+	size := make([]byte, binary.MaxVarintLen64)
+	size = size[:binary.PutUvarint(size, uint64(len(l.Group)))]
+	handled := make([]byte, binary.MaxVarintLen64)
+	handled = handled[:binary.PutUvarint(handled, l.Handled)]
+	value := make([]byte, 0, len(redriveMagic)+len(size)+len(l.Group)+len(handled)+len(l.Value))
+	value = append(value, redriveMagic...)
+	value = append(value, size...)
+	value = append(value, l.Group...)
+	value = append(value, handled...)
+	return append(value, l.Value...)
+}
+
+// IsRedriven reports values which are sent back to their topic for one consumer group
+func IsRedriven(value []byte) bool {
+	return bytes.HasPrefix(value, redriveMagic)
+}
+
+// NewRedriveProducer doesn't compress values, redriven batches are sent as they were read from the source topic
+func NewRedriveProducer(messageSizeLimit int) types.Producer {
+	return newProducer(messageSizeLimit, false)
+}
+
+// batchHandler handles the batch starting after the given number of messages, they were handled before
+type batchHandler func(sessionID uint64, value []byte, meta *types.Meta, skip uint64)
+
+// readRedriven is applied to all message consumers, redriven batches of other groups are skipped
+func readRedriven(group string, handler batchHandler) types.MessageHandler {
+	return func(sessionID uint64, value []byte, meta *types.Meta) {
+		if !bytes.HasPrefix(value, redriveMagic) {
+			handler(sessionID, value, meta, 0)
+			return
+		}
+		data := value[len(redriveMagic):]
+		size, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data)-n) < size {
+			log.Printf("wrong redriven batch, sessID: %d, topic: %s", sessionID, meta.Topic)
+			return
+		}
+		if string(data[n:n+int(size)]) != group {
+			return
+		}
+		data = data[n+int(size):]
+		handled, n := binary.Uvarint(data)
+		if n <= 0 {
+			log.Printf("wrong redriven batch, sessID: %d, topic: %s", sessionID, meta.Topic)
+			return
+		}
+		handler(sessionID, data[n:], meta, handled)
+	}
+}
+
+// countingIterator skips messages handled by the previous attempts and counts the handled ones
+type countingIterator struct {
+	messages.Iterator
+	skip     uint64
+	read     uint64 // messages returned by the batch iterator, including skipped ones
+	finished bool   // the handler returned, so the last read message is handled too
+}
+
+func (i *countingIterator) Next() bool {
+	for i.Iterator.Next() {
+		i.read++
+		if i.read > i.skip {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *countingIterator) Err() error {
+	if i.Iterator == nil {
+		return nil
+	}
+	return i.Iterator.Err()
+}
+
+// handled returns the number of messages which won't be passed to the handler again
+func (i *countingIterator) handled() uint64 {
+	handled := i.read
+	if !i.finished && handled > i.skip {
+		handled-- // the handler panicked on this message
+	}
+	if handled < i.skip {
+		handled = i.skip
+	}
+	return handled
+}
+
+// isSkippedBatch reports batches which the iterator skips on purpose, retrying or redriving them gives nothing
+func isSkippedBatch(err error) bool {
+	var skipped *messages.SkippedBatchError
+	return errors.As(err, &skipped)
+}
+
+// logFailure keeps the behaviour without the dead letter queue, the batch is skipped
+func logFailure(sessionID uint64, meta *types.Meta, err error) {
+	log.Printf("can't process batch, sessID: %d, topic: %s, err: %s", sessionID, meta.Topic, err)
+}
+
+// deadLetterQueue publishes batches which can't be processed by the consumer group
+type deadLetterQueue struct {
+	handler  types.RawMessageHandler
+	attempts int
+	producer types.Producer
+	topic    string
+	group    string
+}
+
+// handle retries the failed batch from the first not handled message, the batch is published
+// only when all attempts fail
+func (q *deadLetterQueue) handle(sessionID uint64, value []byte, meta *types.Meta, skip uint64) {
+	for attempt := 1; ; attempt++ {
+		iter := &countingIterator{skip: skip}
+		err := q.try(sessionID, value, meta, iter)
+		skip = iter.handled()
+		switch {
+		case err == nil:
+			return
+		case isSkippedBatch(err):
+			logFailure(sessionID, meta, err)
+			return
+		case attempt >= q.attempts:
+			q.send(sessionID, value, meta, attempt, skip, err)
+			return
+		}
+		log.Printf("can't process batch, attempt %d of %d, handled messages: %d, sessID: %d, topic: %s, err: %s",
+			attempt, q.attempts, skip, sessionID, meta.Topic, err)
+		time.Sleep(time.Duration(attempt) * deadLetterRetryDelay)
+	}
+}
+
+func (q *deadLetterQueue) try(sessionID uint64, value []byte, meta *types.Meta, iter *countingIterator) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("batch handler panicked: %v\n%s", r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return streamBatch(q.handler, sessionID, value, meta, iter)
+}
+
+// send flushes every letter, the batch is committed by the consumer right after it
+func (q *deadLetterQueue) send(sessionID uint64, value []byte, meta *types.Meta, attempts int, handled uint64, reason error) {
+	data, err := json.Marshal(&DeadLetter{
+		Group:     q.group,
+		Topic:     meta.Topic,
+		Partition: meta.Partition,
+		MessageID: meta.ID,
+		Timestamp: meta.Timestamp,
+		SessionID: sessionID,
+		Error:     reason.Error(),
+		Attempts:  attempts,
+		Handled:   handled,
+		FailedAt:  time.Now().UnixMilli(),
+		Value:     value,
+	})
+	if err != nil {
+		log.Printf("can't marshal dead letter: %s", err)
+		return
+	}
+	if err := q.producer.Produce(q.topic, sessionID, data); err != nil {
+		log.Printf("can't send batch to dead letter queue, sessID: %d, topic: %s, err: %s", sessionID, meta.Topic, err)
+		return
+	}
+	q.producer.Flush(deadLetterFlushTimeout)
+	log.Printf("batch is sent to dead letter queue, handled messages: %d, sessID: %d, topic: %s, err: %s",
+		handled, sessionID, meta.Topic, reason)
+}
+
+// deadLetters sends batches which can't be decoded or make the handler panic QUEUE_DLQ_ATTEMPTS times
+// to QUEUE_DLQ_TOPIC, the consumer goes on with the next batch. Without the topic such batches are skipped.
+// Batches skipped by the iterator on purpose (e.g. unsupported version) are never published.
+func deadLetters(group string, handler types.RawMessageHandler, messageSizeLimit int) batchHandler {
+	topic := env.StringOptional("QUEUE_DLQ_TOPIC")
+	if topic == "" {
+		return func(sessionID uint64, value []byte, meta *types.Meta, skip uint64) {
+			if err := streamBatch(handler, sessionID, value, meta, &countingIterator{skip: skip}); err != nil {
+				logFailure(sessionID, meta, err)
+			}
+		}
+	}
+	attempts := env.IntOptional("QUEUE_DLQ_ATTEMPTS")
+	if attempts <= 0 {
+		attempts = defaultDeadLetterAttempts
+	}
+	q := &deadLetterQueue{
+		handler:  handler,
+		attempts: attempts,
+		// Letters aren't compressed to be readable by any client, json encoding makes the batch bigger
+		producer: newProducer(2*messageSizeLimit, false),
+		topic:    topic,
+		group:    group,
+	}
+	return q.handle
+}
@@ -0,0 +1,175 @@
+package queue
+
+import (
+	"hash/fnv"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis"
+
+	"openreplay/backend/pkg/env"
+	"openreplay/backend/pkg/queue/types"
+)
+
+const defaultDedupMaxKeys = 1000000
+
+// batchDedup drops batches which the group has already handled within the window, e.g. batches delivered
+// again after a rebalance or a restart before the commit. Batches are identified by the session and the hash
+// of their content, batches of the tracker differ by the index of their first message and batches of the
+// analytics topic have no batch meta at all. Keys are kept in memory, with QUEUE_DEDUP_REDIS they are also
+// shared by all consumers of the group. Keys are saved to redis only after the commit, so batches handled
+// but not saved before a crash are handled again by the next consumer.
+type batchDedup struct {
+	types.Consumer
+	group      string
+	window     time.Duration
+	maxKeys    int
+	autoCommit bool
+	client     *redis.Client // nil keeps keys only in memory
+	mu         sync.Mutex
+	keys       map[string]time.Time // expiration of handled batches
+	order      []string             // keys by the time they were handled
+	pending    []string             // keys handled since the last commit
+	dropped    uint64
+}
+
+func newBatchDedup(group string, window time.Duration, maxKeys int, autoCommit bool, client *redis.Client) *batchDedup {
+	d := &batchDedup{
+		group:      group,
+		window:     window,
+		maxKeys:    maxKeys,
+		autoCommit: autoCommit,
+		client:     client,
+		keys:       make(map[string]time.Time),
+	}
+	go d.report()
+	return d
+}
+
+// batchKey is unique for the content of the batch within the session and the topic
+func batchKey(sessionID uint64, value []byte, meta *types.Meta) string {
+	hash := fnv.New64a()
+	hash.Write([]byte(meta.Topic))
+	hash.Write(value)
+	return strconv.FormatUint(sessionID, 10) + ":" + strconv.FormatUint(hash.Sum64(), 16)
+}
+
+func (d *batchDedup) redisKey(key string) string {
+	return "dedup:" + d.group + ":" + key
+}
+
+func (d *batchDedup) wrap(handler types.MessageHandler) types.MessageHandler {
+	return func(sessionID uint64, value []byte, meta *types.Meta) {
+		key := batchKey(sessionID, value, meta)
+		if d.seen(key) {
+			atomic.AddUint64(&d.dropped, 1)
+			return
+		}
+		handler(sessionID, value, meta)
+		d.add(key)
+	}
+}
+
+// seen falls back to handling the batch if redis doesn't respond, duplicates are better than lost batches
+func (d *batchDedup) seen(key string) bool {
+	now := time.Now()
+	d.mu.Lock()
+	expiration, ok := d.keys[key]
+	d.mu.Unlock()
+	if ok && now.Before(expiration) {
+		return true
+	}
+	if d.client == nil {
+		return false
+	}
+	found, err := d.client.Exists(d.redisKey(key)).Result()
+	if err != nil {
+		log.Printf("can't check batch in redis, group: %s, err: %s", d.group, err)
+		return false
+	}
+	return found > 0
+}
+
+func (d *batchDedup) add(key string) {
+	now := time.Now()
+	d.mu.Lock()
+	// Expired keys and the oldest keys above the limit are dropped
+	drop := 0
+	for drop < len(d.order) && (len(d.order)-drop >= d.maxKeys || now.After(d.keys[d.order[drop]])) {
+		delete(d.keys, d.order[drop])
+		drop++
+	}
+	d.order = append(d.order[drop:], key)
+	d.keys[key] = now.Add(d.window)
+	if d.client != nil && !d.autoCommit && len(d.pending) < d.maxKeys {
+		d.pending = append(d.pending, key)
+	}
+	d.mu.Unlock()
+	if d.autoCommit {
+		// Offsets are committed by the consumer itself, there is no commit to wait for
+		d.save([]string{key})
+	}
+}
+
+// Commit saves keys of the committed batches to redis, CommitBack keeps them pending until the next Commit
+// because some of their batches aren't committed yet
+func (d *batchDedup) Commit() error {
+	if err := d.Consumer.Commit(); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	pending := d.pending
+	d.pending = nil
+	d.mu.Unlock()
+	d.save(pending)
+	return nil
+}
+
+func (d *batchDedup) save(keys []string) {
+	if d.client == nil || len(keys) == 0 {
+		return
+	}
+	pipe := d.client.Pipeline()
+	for _, key := range keys {
+		pipe.Set(d.redisKey(key), 1, d.window)
+	}
+	if _, err := pipe.Exec(); err != nil {
+		log.Printf("can't save handled batches to redis, group: %s, batches: %d, err: %s", d.group, len(keys), err)
+	}
+}
+
+func (d *batchDedup) report() {
+	for range time.Tick(time.Minute) {
+		if dropped := atomic.SwapUint64(&d.dropped, 0); dropped > 0 {
+			log.Printf("group %s dropped duplicate batches: %d", d.group, dropped)
+		}
+	}
+}
+
+// dedupBatches wraps the consumer if QUEUE_DEDUP_WINDOW is set. QUEUE_DEDUP_MAX_KEYS limits batches kept
+// in memory, QUEUE_DEDUP_REDIS is the address of redis which shares handled batches between consumers of the group.
+func dedupBatches(group string, autoCommit bool, newConsumer func(types.MessageHandler) types.Consumer, handler types.MessageHandler) types.Consumer {
+	window := env.DurationOptional("QUEUE_DEDUP_WINDOW")
+	if window <= 0 {
+		return newConsumer(handler)
+	}
+	maxKeys := env.IntOptional("QUEUE_DEDUP_MAX_KEYS")
+	if maxKeys <= 0 {
+		maxKeys = defaultDedupMaxKeys
+	}
+	var client *redis.Client
+	if addr := env.StringOptional("QUEUE_DEDUP_REDIS"); addr != "" {
+		client = redis.NewClient(&redis.Options{
+			Addr: addr,
+		})
+		if _, err := client.Ping().Result(); err != nil {
+			log.Fatalf("can't connect to dedup redis of group %s: %s", group, err)
+		}
+	}
+	d := newBatchDedup(group, window, maxKeys, autoCommit, client)
+	d.Consumer = newConsumer(d.wrap(handler))
+	return d
+}
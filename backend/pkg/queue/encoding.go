@@ -0,0 +1,148 @@
+package queue
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"openreplay/backend/pkg/env"
+	"openreplay/backend/pkg/messages"
+	"openreplay/backend/pkg/queue/types"
+	"openreplay/backend/pkg/schemaregistry"
+)
+
+// Values of topics with protobuf encoding are messages.proto Batch messages in the Confluent wire format:
+// the magic byte, the id of the schema in the registry and the index of Batch in the schema. Third-party
+// consumers read them with Confluent deserializers, the services convert them back to the custom format.
+const (
+	encodingBinary   = "binary"
+	encodingProtobuf = "protobuf"
+	encodingAvro     = "avro"
+)
+
+var batchIndexes = []int{0} // Batch is the first message of messages.proto
+
+type encodedTopics struct {
+	topics map[string]bool // nil encodes values of all topics
+}
+
+var (
+	topicEncoding     *encodedTopics
+	topicEncodingOnce sync.Once
+)
+
+// protobufTopics returns nil if QUEUE_ENCODING isn't protobuf, producers and consumers of the topics
+// have to use the same QUEUE_ENCODING_TOPICS
+func protobufTopics() *encodedTopics {
+	topicEncodingOnce.Do(func() {
+		switch name := env.StringOptional("QUEUE_ENCODING"); name {
+		case "", encodingBinary:
+			return
+		case encodingProtobuf:
+		case encodingAvro:
+			log.Fatalf("avro queue encoding isn't supported, messages have only the protobuf schema")
+		default:
+			log.Fatalf("unknown queue encoding: %s", name)
+		}
+		topicEncoding = &encodedTopics{}
+		if topics := env.StringListOptional("QUEUE_ENCODING_TOPICS"); len(topics) > 0 {
+			topicEncoding.topics = make(map[string]bool, len(topics))
+			for _, topic := range topics {
+				topicEncoding.topics[topic] = true
+			}
+		}
+	})
+	return topicEncoding
+}
+
+func isProtobufTopic(topic string) bool {
+	encoded := protobufTopics()
+	return encoded != nil && (encoded.topics == nil || encoded.topics[topic])
+}
+
+// encodingProducer converts batches of protobuf topics, the schema is registered for every topic once
+type encodingProducer struct {
+	types.Producer
+	registry *schemaregistry.Client
+	strategy string
+	schema   *schemaregistry.Schema
+}
+
+func (p *encodingProducer) schemaID(topic string) (uint32, error) {
+	subject, err := schemaregistry.Subject(p.strategy, topic, messages.ProtoBatchRecord)
+	if err != nil {
+		return 0, err
+	}
+	return p.registry.Register(subject, p.schema)
+}
+
+func (p *encodingProducer) encode(topic string, value []byte) ([]byte, error) {
+	if !isProtobufTopic(topic) {
+		return value, nil
+	}
+	id, err := p.schemaID(topic)
+	if err != nil {
+		return nil, err
+	}
+	batch, err := messages.NativeBatchToProto(value)
+	if err != nil {
+		return nil, fmt.Errorf("can't encode protobuf batch: %s", err)
+	}
+	return schemaregistry.Frame(id, batchIndexes, batch), nil
+}
+
+func (p *encodingProducer) Produce(topic string, key uint64, value []byte) error {
+	encoded, err := p.encode(topic, value)
+	if err != nil {
+		return err
+	}
+	return p.Producer.Produce(topic, key, encoded)
+}
+
+func (p *encodingProducer) ProduceToPartition(topic string, partition, key uint64, value []byte) error {
+	encoded, err := p.encode(topic, value)
+	if err != nil {
+		return err
+	}
+	return p.Producer.ProduceToPartition(topic, partition, key, encoded)
+}
+
+// encodeBatches wraps the producer if QUEUE_ENCODING is protobuf. The schema is registered in the registry
+// of SCHEMA_REGISTRY_URL under the subject of SCHEMA_REGISTRY_SUBJECT_STRATEGY (topic, record or topic_record),
+// producers don't start if it isn't compatible with the latest version of the subject.
+func encodeBatches(producer types.Producer) types.Producer {
+	encoded := protobufTopics()
+	if encoded == nil {
+		return producer
+	}
+	registry, err := schemaregistry.New(env.String("SCHEMA_REGISTRY_URL"),
+		env.StringOptional("SCHEMA_REGISTRY_USERNAME"), env.StringOptional("SCHEMA_REGISTRY_PASSWORD"))
+	if err != nil {
+		log.Fatalf("can't init schema registry: %s", err)
+	}
+	p := &encodingProducer{
+		Producer: producer,
+		registry: registry,
+		strategy: env.StringOptional("SCHEMA_REGISTRY_SUBJECT_STRATEGY"),
+		schema:   &schemaregistry.Schema{Type: schemaregistry.TypeProtobuf, Schema: messages.ProtoSchema},
+	}
+	// Topics of all services aren't known here, they are registered on the first batch
+	for topic := range encoded.topics {
+		if _, err := p.schemaID(topic); err != nil {
+			log.Fatalf("can't register schema of %s: %s", topic, err)
+		}
+	}
+	return p
+}
+
+// decodeBatch converts protobuf batches of encoded topics to the custom format, other values are returned as is
+func decodeBatch(topic string, value []byte) ([]byte, error) {
+	if !isProtobufTopic(topic) || !schemaregistry.IsFramed(value) {
+		return value, nil
+	}
+	_, _, batch, err := schemaregistry.Unframe(value)
+	if err != nil {
+		return nil, err
+	}
+	return messages.ProtoBatchToNative(batch)
+}
@@ -0,0 +1,182 @@
+package queue
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"openreplay/backend/pkg/queue/types"
+)
+
+// HealthChecker is implemented by producers which can detect broker outages
+type HealthChecker interface {
+	Ping() error                // checks connection to the broker
+	Unavailable() time.Duration // how long the broker doesn't accept messages, 0 if it's available
+}
+
+// Spool keeps messages during the primary broker outage
+type Spool interface {
+	types.Producer
+	Drain(target types.Producer) (int, error) // moves saved messages to the target, returns the number of moved messages
+}
+
+// FailureReporter is implemented by producers which can return undelivered messages
+type FailureReporter interface {
+	SetDeliveryFailureHandler(handler func(topic string, partition int32, key uint64, value []byte))
+}
+
+const (
+	stateAvailable int32 = iota
+	stateFailed
+	stateDraining // spool is still in use to keep the order of messages
+)
+
+// FailoverProducer writes messages to the spool when the primary broker is unavailable
+// for longer than timeout and sends them back to the primary broker on recovery.
+// Messages which the primary broker rejects right away are spooled too, except the ones rejected
+// because of backpressure: the broker is slow but available, so the caller retries them later.
+type FailoverProducer struct {
+	primary types.Producer
+	health  HealthChecker // nil if the broker can't be checked, the spool is drained to probe it
+	spool   Spool
+	timeout time.Duration
+	state   int32
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func NewFailoverProducer(primary types.Producer, health HealthChecker, spool Spool, timeout time.Duration) *FailoverProducer {
+	p := &FailoverProducer{
+		primary: primary,
+		health:  health,
+		spool:   spool,
+		timeout: timeout,
+		state:   stateFailed, // messages spooled before the restart are moved first
+		done:    make(chan struct{}),
+	}
+	if reporter, ok := primary.(FailureReporter); ok {
+		reporter.SetDeliveryFailureHandler(p.rescue)
+	}
+	p.wg.Add(1)
+	go p.monitor()
+	return p
+}
+
+// rescue saves messages which the primary broker couldn't deliver
+func (p *FailoverProducer) rescue(topic string, partition int32, key uint64, value []byte) {
+	var err error
+	if partition < 0 {
+		err = p.spool.Produce(topic, key, value)
+	} else {
+		err = p.spool.ProduceToPartition(topic, uint64(partition), key, value)
+	}
+	if err != nil {
+		log.Printf("can't save undelivered message to spool: %s, key: %d", err, key)
+	}
+}
+
+func (p *FailoverProducer) Produce(topic string, key uint64, value []byte) error {
+	if atomic.LoadInt32(&p.state) == stateAvailable {
+		err := p.primary.Produce(topic, key, value)
+		if err == nil || errors.Is(err, types.ErrBackpressure) {
+			return err
+		}
+		p.fail(err)
+	}
+	return p.spool.Produce(topic, key, value)
+}
+
+func (p *FailoverProducer) ProduceToPartition(topic string, partition, key uint64, value []byte) error {
+	if atomic.LoadInt32(&p.state) == stateAvailable {
+		err := p.primary.ProduceToPartition(topic, partition, key, value)
+		if err == nil || errors.Is(err, types.ErrBackpressure) {
+			return err
+		}
+		p.fail(err)
+	}
+	return p.spool.ProduceToPartition(topic, partition, key, value)
+}
+
+// fail switches to the spool after the error of the primary broker, the following messages
+// mustn't overtake the spooled one
+func (p *FailoverProducer) fail(err error) {
+	if atomic.CompareAndSwapInt32(&p.state, stateAvailable, stateFailed) {
+		log.Printf("primary broker rejected message: %s, switching to spool", err)
+	}
+}
+
+func (p *FailoverProducer) monitor() {
+	defer p.wg.Done()
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-tick.C:
+		}
+		switch atomic.LoadInt32(&p.state) {
+		case stateAvailable:
+			if p.health == nil {
+				continue
+			}
+			if unavailable := p.health.Unavailable(); unavailable > 0 && unavailable >= p.timeout {
+				log.Printf("primary broker is unavailable for %s, switching to spool", unavailable)
+				atomic.StoreInt32(&p.state, stateFailed)
+			}
+		case stateFailed:
+			if p.health != nil {
+				if err := p.health.Ping(); err != nil {
+					continue
+				}
+				log.Printf("primary broker is available, draining spool")
+			}
+			atomic.StoreInt32(&p.state, stateDraining)
+			p.reconcile()
+		}
+	}
+}
+
+// reconcile moves all spooled messages to the primary broker, new messages go to the spool
+// until it's empty, so messages of one session keep the order
+func (p *FailoverProducer) reconcile() {
+	total := 0
+	for {
+		n, err := p.spool.Drain(p.primary)
+		total += n
+		if err != nil {
+			// Without the health checker every drain is a probe, failed probes aren't logged
+			if total > 0 || p.health != nil {
+				log.Printf("can't drain spool: %s, moved: %d", err, total)
+			}
+			atomic.StoreInt32(&p.state, stateFailed)
+			return
+		}
+		if n == 0 {
+			break
+		}
+	}
+	atomic.StoreInt32(&p.state, stateAvailable)
+	// Messages written between the last drain and the switch
+	n, err := p.spool.Drain(p.primary)
+	if err != nil {
+		log.Printf("can't drain spool: %s", err)
+	}
+	if total+n > 0 {
+		log.Printf("switched back to primary broker, moved messages: %d", total+n)
+	}
+}
+
+func (p *FailoverProducer) Close(timeout int) {
+	close(p.done)
+	p.wg.Wait()
+	p.primary.Close(timeout)
+	p.spool.Close(timeout)
+}
+
+func (p *FailoverProducer) Flush(timeout int) {
+	p.primary.Flush(timeout)
+	p.spool.Flush(timeout)
+}
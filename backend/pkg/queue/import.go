@@ -1,14 +1,56 @@
 package queue
 
 import (
+	"log"
+
+	"openreplay/backend/pkg/memqueue"
+	"openreplay/backend/pkg/natsstream"
+	"openreplay/backend/pkg/pulsarstream"
 	"openreplay/backend/pkg/queue/types"
 	"openreplay/backend/pkg/redisstream"
 )
 
-func NewConsumer(group string, topics []string, handler types.MessageHandler, _ bool, _ int) types.Consumer {
-	return redisstream.NewConsumer(group, topics, handler)
+func NewConsumer(group string, topics []string, handler types.MessageHandler, autoCommit bool, _ int) types.Consumer {
+	if useMemory() {
+		return memqueue.NewConsumer(group, topics, handler, autoCommit)
+	}
+	if usePulsar() {
+		return pulsarstream.NewConsumer(group, topics, handler, autoCommit)
+	}
+	if useNATS() {
+		return natsstream.NewConsumer(group, topics, handler, autoCommit)
+	}
+	return redisstream.NewConsumer(group, topics, handler, autoCommit)
+}
+
+// NewConcurrentConsumer falls back to the regular consumer, partitions of redis, nats, pulsar and memory consumers are read in one goroutine
+func NewConcurrentConsumer(group string, topics []string, handler types.MessageHandler, autoCommit bool, _ int) types.Consumer {
+	log.Printf("concurrent consumer requires kafka, partitions of group %s are processed in one goroutine", group)
+	if useMemory() {
+		return memqueue.NewConsumer(group, topics, handler, autoCommit)
+	}
+	if usePulsar() {
+		return pulsarstream.NewConsumer(group, topics, handler, autoCommit)
+	}
+	if useNATS() {
+		return natsstream.NewConsumer(group, topics, handler, autoCommit)
+	}
+	return redisstream.NewConsumer(group, topics, handler, autoCommit)
+}
+
+func NewProducer(messageSizeLimit int, useBatch bool) types.Producer {
+	return compressBatches(encodeBatches(newProducer(messageSizeLimit, useBatch)))
 }
 
-func NewProducer(_ int, _ bool) types.Producer {
+func newProducer(_ int, _ bool) types.Producer {
+	if useMemory() {
+		return memqueue.NewProducer()
+	}
+	if usePulsar() {
+		return pulsarstream.NewProducer()
+	}
+	if useNATS() {
+		return natsstream.NewProducer()
+	}
 	return redisstream.NewProducer()
 }
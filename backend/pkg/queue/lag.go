@@ -0,0 +1,157 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+
+	"openreplay/backend/internal/config/common"
+	"openreplay/backend/pkg/monitoring"
+	"openreplay/backend/pkg/queue/types"
+)
+
+const lagReportTimeout = 5 * time.Second
+
+// lagReport is posted to LAG_REPORT_URL, autoscalers sum totals of the group instances
+type lagReport struct {
+	Group      string         `json:"group"`
+	Instance   string         `json:"instance"`
+	Timestamp  int64          `json:"timestamp"` // ms
+	Total      int64          `json:"total"`
+	Partitions []partitionLag `json:"partitions"`
+}
+
+type partitionLag struct {
+	Topic     string `json:"topic"`
+	Partition uint64 `json:"partition"`
+	Lag       int64  `json:"lag"`
+}
+
+// LagMonitor measures the lag of the consumer partitions every LAG_REPORT_INTERVAL and records it
+// to the consumer_lag metric, so services can be scaled by the real lag instead of CPU
+type LagMonitor struct {
+	consumer types.LagReporter
+	group    string
+	instance string
+	url      string
+	client   *http.Client
+	lag      syncfloat64.UpDownCounter
+	reported map[types.TopicPartition]int64 // the metric is changed by the difference with the last value
+	total    int64
+}
+
+// NewLagMonitor returns an error if the consumer can't measure lag
+func NewLagMonitor(consumer types.Consumer, group string, cfg *common.Config, metrics *monitoring.Metrics) (*LagMonitor, error) {
+	reporter, ok := unwrapConsumer(consumer).(types.LagReporter)
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("consumer doesn't report lag")
+	case cfg == nil:
+		return nil, fmt.Errorf("config is empty")
+	case cfg.LagReportInterval <= 0:
+		return nil, fmt.Errorf("wrong lag report interval: %s", cfg.LagReportInterval)
+	case metrics == nil:
+		return nil, fmt.Errorf("metrics module is empty")
+	}
+	m := &LagMonitor{
+		consumer: reporter,
+		group:    group,
+		url:      cfg.LagReportURL,
+		client:   &http.Client{Timeout: lagReportTimeout},
+		reported: make(map[types.TopicPartition]int64),
+	}
+	m.instance, _ = os.Hostname()
+	var err error
+	if m.lag, err = metrics.RegisterUpDownCounter("consumer_lag"); err != nil {
+		return nil, fmt.Errorf("can't register consumer_lag metric: %s", err)
+	}
+	go m.run(cfg.LagReportInterval)
+	return m, nil
+}
+
+// Total returns the lag of all partitions measured the last time
+func (m *LagMonitor) Total() int64 {
+	return atomic.LoadInt64(&m.total)
+}
+
+func (m *LagMonitor) run(interval time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for range tick.C {
+		lags, err := m.consumer.Lag()
+		if err != nil {
+			log.Printf("can't get consumer lag of group %s: %s", m.group, err)
+			continue
+		}
+		report := m.record(lags)
+		if m.url == "" {
+			continue
+		}
+		if err := m.post(report); err != nil {
+			log.Printf("can't send consumer lag of group %s: %s", m.group, err)
+		}
+	}
+}
+
+// record updates the metric, revoked partitions are reported as 0
+func (m *LagMonitor) record(lags []types.PartitionLag) *lagReport {
+	report := &lagReport{
+		Group:      m.group,
+		Instance:   m.instance,
+		Timestamp:  time.Now().UnixMilli(),
+		Partitions: make([]partitionLag, 0, len(lags)),
+	}
+	ctx := context.Background()
+	current := make(map[types.TopicPartition]int64, len(lags))
+	for _, l := range lags {
+		current[l.TopicPartition] = l.Lag
+		report.Total += l.Lag
+		report.Partitions = append(report.Partitions, partitionLag{Topic: l.Topic, Partition: l.Partition, Lag: l.Lag})
+	}
+	for tp, prev := range m.reported {
+		if _, ok := current[tp]; !ok {
+			m.lag.Add(ctx, float64(-prev), m.attributes(tp)...)
+		}
+	}
+	for tp, lag := range current {
+		if diff := lag - m.reported[tp]; diff != 0 {
+			m.lag.Add(ctx, float64(diff), m.attributes(tp)...)
+		}
+	}
+	m.reported = current
+	atomic.StoreInt64(&m.total, report.Total)
+	return report
+}
+
+func (m *LagMonitor) attributes(tp types.TopicPartition) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("group", m.group),
+		attribute.String("topic", tp.Topic),
+		attribute.Int64("partition", int64(tp.Partition)),
+	}
+}
+
+func (m *LagMonitor) post(report *lagReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	resp, err := m.client.Post(m.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
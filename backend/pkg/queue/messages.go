@@ -1,12 +1,28 @@
 package queue
 
 import (
-	"openreplay/backend/pkg/messages"
 	"openreplay/backend/pkg/queue/types"
 )
 
 func NewMessageConsumer(group string, topics []string, handler types.RawMessageHandler, autoCommit bool, messageSizeLimit int) types.Consumer {
-	return NewConsumer(group, topics, func(sessionID uint64, value []byte, meta *types.Meta) {
-		handler(sessionID, messages.NewIterator(value), meta)
-	}, autoCommit, messageSizeLimit)
+	autoCommit = autoCommitMode(group, autoCommit)
+	return dedupBatches(group, autoCommit, func(handler types.MessageHandler) types.Consumer {
+		return limitInFlight(group, autoCommit, func(handler types.MessageHandler) types.Consumer {
+			return NewConsumer(group, topics, handler, autoCommit, messageSizeLimit)
+		}, handler)
+	}, messageHandler(group, handler, messageSizeLimit))
+}
+
+// NewConcurrentMessageConsumer calls the handler from several goroutines, one per partition, and only with kafka.
+// Only storage uses it: db, heuristics, ender and sink keep per-session state without locks, they need the
+// regular consumer and are scaled by adding instances to the group.
+func NewConcurrentMessageConsumer(group string, topics []string, handler types.RawMessageHandler, autoCommit bool, messageSizeLimit int) types.Consumer {
+	autoCommit = autoCommitMode(group, autoCommit)
+	return limitInFlight(group, autoCommit, func(handler types.MessageHandler) types.Consumer {
+		return NewConcurrentConsumer(group, topics, handler, autoCommit, messageSizeLimit)
+	}, messageHandler(group, handler, messageSizeLimit))
+}
+
+func messageHandler(group string, handler types.RawMessageHandler, messageSizeLimit int) types.MessageHandler {
+	return dropStaleMessages(readRedriven(group, deadLetters(group, handler, messageSizeLimit)))
 }
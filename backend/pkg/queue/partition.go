@@ -0,0 +1,210 @@
+package queue
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"openreplay/backend/pkg/queue/types"
+	"openreplay/backend/pkg/tiers"
+)
+
+// Partition keys of QUEUE_PARTITION_KEYS, the message key is always the session id, only the partition depends on it
+const (
+	SessionPartitionKey = "session"
+	ProjectPartitionKey = "project"
+	UserPartitionKey    = "user" // sessions without the user id are partitioned by session
+	TierPartitionKey    = "tier" // each project tier gets its own partitions, sessions are spread inside them
+)
+
+// Keys of sessions which weren't seen for this time are resolved again
+const (
+	sessionKeysTTL             = time.Hour
+	sessionKeysCleanupInterval = time.Minute
+)
+
+// SessionKeys returns the project and the user id (empty if unknown) of the session
+type SessionKeys func(sessionID uint64) (projectID uint32, userID string, err error)
+
+// ParsePartitionKeys parses the comma separated list of topic=key pairs
+func ParsePartitionKeys(s string) (map[string]string, error) {
+	keys := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		topic, key, ok := strings.Cut(pair, "=")
+		topic, key = strings.TrimSpace(topic), strings.TrimSpace(key)
+		if !ok || topic == "" {
+			return nil, fmt.Errorf("wrong partition key of topic: %q", pair)
+		}
+		switch key {
+		case SessionPartitionKey, ProjectPartitionKey, UserPartitionKey, TierPartitionKey:
+		default:
+			return nil, fmt.Errorf("unknown partition key %q of topic %s", key, topic)
+		}
+		keys[topic] = key
+	}
+	return keys, nil
+}
+
+type sessionPartitions struct {
+	project  uint64 // hash of the project id
+	user     uint64 // hash of the project and user ids, 0 if the user is unknown
+	tier     tiers.Tier
+	lastSeen time.Time
+}
+
+// partitioningProducer chooses the partition of the topic by the project or the user of the session.
+// Keys are resolved once per session, so all messages of the session get into the same partition
+// even if the user id is set in the middle of the session.
+type partitioningProducer struct {
+	types.Producer
+	keys       map[string]string
+	partitions uint64
+	resolve    SessionKeys
+	tiers      *tiers.Tiers
+	tierRanges map[tiers.Tier][2]uint64 // first partition and the number of partitions of the tier
+
+	mu          sync.Mutex
+	sessions    map[uint64]*sessionPartitions
+	lastCleanup time.Time
+}
+
+// NewPartitioningProducer returns the producer as is if all topics are partitioned by session. All services
+// which write to the topic have to use the same keys, otherwise messages of a project get into different partitions.
+// Project tiers are required only by the tier key.
+func NewPartitioningProducer(producer types.Producer, partitionKeys string, partitions int, resolve SessionKeys, projectTiers *tiers.Tiers) (types.Producer, error) {
+	keys, err := ParsePartitionKeys(partitionKeys)
+	switch {
+	case err != nil:
+		return nil, err
+	case partitions <= 0:
+		return nil, fmt.Errorf("wrong number of partitions: %d", partitions)
+	case resolve == nil:
+		return nil, fmt.Errorf("session keys resolver is empty")
+	}
+	for topic, key := range keys {
+		if key == SessionPartitionKey {
+			delete(keys, topic)
+		}
+	}
+	if len(keys) == 0 {
+		return producer, nil
+	}
+	p := &partitioningProducer{
+		Producer:    producer,
+		keys:        keys,
+		partitions:  uint64(partitions),
+		resolve:     resolve,
+		tiers:       projectTiers,
+		sessions:    make(map[uint64]*sessionPartitions),
+		lastCleanup: time.Now(),
+	}
+	for topic, key := range keys {
+		if key != TierPartitionKey {
+			continue
+		}
+		if projectTiers == nil {
+			return nil, fmt.Errorf("project tiers are empty, they are required by the partition key of topic %s", topic)
+		}
+		if p.tierRanges, err = projectTiers.Partitions(p.partitions); err != nil {
+			return nil, err
+		}
+		break
+	}
+	return p, nil
+}
+
+func (p *partitioningProducer) Produce(topic string, key uint64, value []byte) error {
+	strategy, ok := p.keys[topic]
+	if !ok {
+		return p.Producer.Produce(topic, key, value)
+	}
+	s := p.session(key)
+	if strategy == TierPartitionKey {
+		r := p.tierRanges[s.tier]
+		return p.Producer.ProduceToPartition(topic, r[0]+key%r[1], key, value)
+	}
+	hash := s.project
+	if strategy == UserPartitionKey {
+		hash = s.user
+	}
+	if hash == 0 {
+		return p.Producer.Produce(topic, key, value)
+	}
+	return p.Producer.ProduceToPartition(topic, hash%p.partitions, key, value)
+}
+
+// session keeps keys of the unknown session as well (they are zero), the session isn't moved to
+// another partition when it's saved to the database
+func (p *partitioningProducer) session(sessionID uint64) sessionPartitions {
+	if s, ok := p.cached(sessionID); ok {
+		return s
+	}
+	// Keys are resolved without the lock, messages of known sessions don't wait for the database
+	s := &sessionPartitions{}
+	projectID, userID, err := p.resolve(sessionID)
+	if p.tiers != nil {
+		// Sessions of unknown projects get the default tier
+		s.tier = p.tiers.Get(projectID)
+	}
+	if err != nil {
+		log.Printf("can't get partition keys, sessID: %d, err: %s", sessionID, err)
+	} else {
+		s.project = partitionHash(projectID, "")
+		if userID != "" {
+			s.user = partitionHash(projectID, userID)
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// Another message of the session could be sent while the keys were resolved
+	if cached, ok := p.sessions[sessionID]; ok {
+		return *cached
+	}
+	s.lastSeen = time.Now()
+	p.sessions[sessionID] = s
+	return *s
+}
+
+func (p *partitioningProducer) cached(sessionID uint64) (sessionPartitions, bool) {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if now.Sub(p.lastCleanup) > sessionKeysCleanupInterval {
+		for id, s := range p.sessions {
+			if now.Sub(s.lastSeen) > sessionKeysTTL {
+				delete(p.sessions, id)
+			}
+		}
+		p.lastCleanup = now
+	}
+	s, ok := p.sessions[sessionID]
+	if !ok {
+		return sessionPartitions{}, false
+	}
+	s.lastSeen = now
+	return *s, true
+}
+
+// partitionHash is never 0 for a known project, 0 means the key is unknown
+func partitionHash(projectID uint32, userID string) uint64 {
+	if projectID == 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	buf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(buf, projectID)
+	h.Write(buf)
+	h.Write([]byte(userID))
+	if sum := h.Sum64(); sum != 0 {
+		return sum
+	}
+	return 1
+}
@@ -0,0 +1,561 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+
+	"openreplay/backend/pkg/monitoring"
+	"openreplay/backend/pkg/queue/types"
+)
+
+const (
+	spillSegmentExt      = ".spill"
+	spillPositionFile    = "position"
+	maxSpillSegmentSize  = 64 << 20
+	spillDrainBatchSize  = 1000
+	spillDrainBatchBytes = 16 << 20
+	spillHeaderSize      = 8  // length and crc of the record body
+	spillFixedBodySize   = 26 // timestamp, partition, key and length of the topic
+)
+
+var (
+	errSpillCorrupted = errors.New("corrupted record")
+	errSpillStopped   = errors.New("replay is stopped")
+)
+
+// spillRecord is one batch sent to the queue
+type spillRecord struct {
+	timestamp time.Time
+	topic     string
+	partition int64 // -1 for batches which are partitioned by the key
+	key       uint64
+	value     []byte
+}
+
+func (r *spillRecord) encode() []byte {
+	size := spillFixedBodySize + len(r.topic) + len(r.value)
+	buf := make([]byte, spillHeaderSize+size)
+	body := buf[spillHeaderSize:]
+	binary.LittleEndian.PutUint64(body[0:], uint64(r.timestamp.UnixNano()))
+	binary.LittleEndian.PutUint64(body[8:], uint64(r.partition))
+	binary.LittleEndian.PutUint64(body[16:], r.key)
+	binary.LittleEndian.PutUint16(body[24:], uint16(len(r.topic)))
+	copy(body[spillFixedBodySize:], r.topic)
+	copy(body[spillFixedBodySize+len(r.topic):], r.value)
+	binary.LittleEndian.PutUint32(buf[0:], uint32(size))
+	binary.LittleEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(body))
+	return buf
+}
+
+func (r *spillRecord) produce(target types.Producer) error {
+	if r.partition < 0 {
+		return target.Produce(r.topic, r.key, r.value)
+	}
+	return target.ProduceToPartition(r.topic, uint64(r.partition), r.key, r.value)
+}
+
+// spillBatch is a part of the oldest segment, offsets are positions of the records in the segment
+type spillBatch struct {
+	seq       uint64
+	records   []*spillRecord
+	offsets   []int64
+	end       int64
+	exhausted bool // the segment has no records after the end
+}
+
+// DiskSpool keeps batches in segment files of the local directory while the queue is unavailable.
+// Segments are replayed in the order of writes. The read position is saved after every drained
+// part, so batches are replayed at least once after a crash. Records are checked by crc, a torn
+// write ends the segment. Batches older than maxAge aren't replayed, new batches are rejected
+// when the files take maxSize bytes.
+type DiskSpool struct {
+	dir         string
+	maxSize     int64
+	maxAge      time.Duration // 0 keeps batches until they are replayed
+	segmentSize int64
+
+	mu         sync.Mutex
+	segments   []uint64 // closed segments, the oldest first
+	size       int64    // size of all segment files
+	file       *os.File // current segment, it's created on the first write after rotation
+	fileSeq    uint64
+	fileSize   int64
+	readOffset int64 // position in the oldest segment, it's the current one if there are no closed segments
+
+	spilled  syncfloat64.Counter
+	replayed syncfloat64.Counter
+	dropped  syncfloat64.Counter
+	bytes    syncfloat64.UpDownCounter
+}
+
+func NewDiskSpool(dir string, maxSize int64, maxAge time.Duration, metrics *monitoring.Metrics) (*DiskSpool, error) {
+	switch {
+	case dir == "":
+		return nil, fmt.Errorf("spill directory is empty")
+	case maxSize <= 0:
+		return nil, fmt.Errorf("wrong max size of spill buffer: %d", maxSize)
+	case metrics == nil:
+		return nil, fmt.Errorf("metrics module is empty")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("can't create spill directory: %s", err)
+	}
+	s := &DiskSpool{
+		dir:         dir,
+		maxSize:     maxSize,
+		maxAge:      maxAge,
+		segmentSize: maxSize / 16,
+	}
+	if s.segmentSize > maxSpillSegmentSize {
+		s.segmentSize = maxSpillSegmentSize
+	}
+	if err := s.load(); err != nil {
+		return nil, fmt.Errorf("can't load spilled batches: %s", err)
+	}
+	var err error
+	if s.spilled, err = metrics.RegisterCounter("spill_batches"); err != nil {
+		return nil, fmt.Errorf("can't register spill_batches metric: %s", err)
+	}
+	if s.replayed, err = metrics.RegisterCounter("spill_replayed_batches"); err != nil {
+		return nil, fmt.Errorf("can't register spill_replayed_batches metric: %s", err)
+	}
+	if s.dropped, err = metrics.RegisterCounter("spill_dropped_batches"); err != nil {
+		return nil, fmt.Errorf("can't register spill_dropped_batches metric: %s", err)
+	}
+	if s.bytes, err = metrics.RegisterUpDownCounter("spill_size_bytes"); err != nil {
+		return nil, fmt.Errorf("can't register spill_size_bytes metric: %s", err)
+	}
+	s.bytes.Add(context.Background(), float64(s.size))
+	if len(s.segments) > 0 {
+		log.Printf("spill buffer keeps %d bytes of batches of the previous run", s.size)
+	}
+	return s, nil
+}
+
+// load finds segments of the previous run, new batches are written to the new segment
+func (s *DiskSpool) load() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, spillSegmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, spillSegmentExt), 16, 64)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		s.segments = append(s.segments, seq)
+		s.size += info.Size()
+	}
+	sort.Slice(s.segments, func(i, j int) bool {
+		return s.segments[i] < s.segments[j]
+	})
+	if len(s.segments) > 0 {
+		s.fileSeq = s.segments[len(s.segments)-1] + 1
+	}
+	seq, offset, err := s.readPosition()
+	if err != nil {
+		log.Printf("can't read spill position, segments are replayed from the start: %s", err)
+		return nil
+	}
+	if len(s.segments) > 0 && s.segments[0] == seq {
+		s.readOffset = offset
+	}
+	// The position mustn't point to a new segment with the same number
+	if seq >= s.fileSeq {
+		s.fileSeq = seq + 1
+	}
+	return nil
+}
+
+func (s *DiskSpool) segmentPath(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%016x%s", seq, spillSegmentExt))
+}
+
+func (s *DiskSpool) readPosition() (uint64, int64, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, spillPositionFile))
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	var seq uint64
+	var offset int64
+	if _, err := fmt.Sscan(string(data), &seq, &offset); err != nil {
+		return 0, 0, err
+	}
+	return seq, offset, nil
+}
+
+// writePosition replaces the file at once, so it's never half-written
+func (s *DiskSpool) writePosition(seq uint64, offset int64) error {
+	tmp := filepath.Join(s.dir, spillPositionFile+".tmp")
+	if err := os.WriteFile(tmp, []byte(fmt.Sprintf("%d %d\n", seq, offset)), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.dir, spillPositionFile))
+}
+
+func (s *DiskSpool) add(record *spillRecord) error {
+	data := record.encode()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size+int64(len(data)) > s.maxSize {
+		s.expire()
+	}
+	if s.size+int64(len(data)) > s.maxSize {
+		s.dropped.Add(context.Background(), 1, attribute.String("reason", "full"))
+		return fmt.Errorf("spill buffer is full: %d bytes", s.size)
+	}
+	if s.file == nil {
+		file, err := os.OpenFile(s.segmentPath(s.fileSeq), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("can't create spill segment: %s", err)
+		}
+		s.file = file
+	}
+	n, err := s.file.Write(data)
+	s.fileSize += int64(n)
+	s.size += int64(n)
+	s.bytes.Add(context.Background(), float64(n))
+	if err != nil {
+		// The torn record ends the segment, next batches go to the new one
+		s.rotate()
+		return fmt.Errorf("can't write spill segment: %s", err)
+	}
+	s.spilled.Add(context.Background(), 1)
+	if s.fileSize >= s.segmentSize {
+		s.rotate()
+	}
+	return nil
+}
+
+func (s *DiskSpool) Produce(topic string, key uint64, value []byte) error {
+	return s.add(&spillRecord{timestamp: time.Now(), topic: topic, partition: -1, key: key, value: value})
+}
+
+func (s *DiskSpool) ProduceToPartition(topic string, partition, key uint64, value []byte) error {
+	return s.add(&spillRecord{timestamp: time.Now(), topic: topic, partition: int64(partition), key: key, value: value})
+}
+
+// rotate closes the current segment, it becomes available for reading
+func (s *DiskSpool) rotate() {
+	if s.file == nil {
+		return
+	}
+	if err := s.file.Close(); err != nil {
+		log.Printf("can't close spill segment: %s", err)
+	}
+	s.file = nil
+	s.segments = append(s.segments, s.fileSeq)
+	s.fileSeq++
+	s.fileSize = 0
+	s.expire()
+}
+
+// expire removes segments which were last written more than maxAge ago
+func (s *DiskSpool) expire() {
+	if s.maxAge == 0 {
+		return
+	}
+	for len(s.segments) > 0 {
+		path := s.segmentPath(s.segments[0])
+		info, err := os.Stat(path)
+		if err == nil && time.Since(info.ModTime()) < s.maxAge {
+			return
+		}
+		if n := countSpillRecords(path, s.readOffset); n > 0 {
+			log.Printf("dropping %d spilled batches older than %s", n, s.maxAge)
+			s.dropped.Add(context.Background(), float64(n), attribute.String("reason", "expired"))
+		}
+		s.removeOldest()
+	}
+}
+
+func (s *DiskSpool) removeOldest() {
+	path := s.segmentPath(s.segments[0])
+	if info, err := os.Stat(path); err == nil {
+		s.size -= info.Size()
+		s.bytes.Add(context.Background(), -float64(info.Size()))
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("can't remove spill segment: %s", err)
+	}
+	s.segments = s.segments[1:]
+	s.readOffset = 0
+}
+
+// Drain replays the oldest batches to the target, expired and corrupted ones are skipped
+func (s *DiskSpool) Drain(target types.Producer) (int, error) {
+	return s.drain(func(record *spillRecord) error {
+		if err := record.produce(target); err != nil {
+			return fmt.Errorf("can't replay spilled batch, topic: %s, key: %d, err: %s", record.topic, record.key, err)
+		}
+		return nil
+	})
+}
+
+// Spill keeps the consumed batch, its age is counted from the time of the batch in the queue
+func (s *DiskSpool) Spill(sessionID uint64, value []byte, meta *types.Meta) error {
+	timestamp := time.Now()
+	if meta.Timestamp > 0 {
+		timestamp = time.UnixMilli(meta.Timestamp)
+	}
+	return s.add(&spillRecord{timestamp: timestamp, topic: meta.Topic, partition: int64(meta.Partition), key: sessionID, value: value})
+}
+
+// Replay passes the oldest spilled batches to the handler until stop returns true,
+// the batch is passed again after a crash if the position wasn't saved
+func (s *DiskSpool) Replay(handler types.MessageHandler, stop func() bool) (int, error) {
+	return s.drain(func(record *spillRecord) error {
+		if stop() {
+			return errSpillStopped
+		}
+		handler(record.key, record.value, &types.Meta{
+			Topic:     record.topic,
+			Partition: uint64(record.partition),
+			Timestamp: record.timestamp.UnixMilli(),
+		})
+		return nil
+	})
+}
+
+// drain passes the oldest records to send, the position is saved before the first failed record
+func (s *DiskSpool) drain(send func(record *spillRecord) error) (int, error) {
+	for {
+		batch, err := s.next()
+		if err != nil || batch == nil {
+			return 0, err
+		}
+		moved, expired := 0, 0
+		for i, record := range batch.records {
+			if s.maxAge > 0 && time.Since(record.timestamp) > s.maxAge {
+				expired++
+				continue
+			}
+			if err := send(record); err != nil {
+				s.advance(batch.seq, batch.offsets[i], false)
+				s.count(moved, expired)
+				return moved, err
+			}
+			moved++
+		}
+		s.advance(batch.seq, batch.end, batch.exhausted)
+		s.count(moved, expired)
+		// A part of expired batches doesn't mean that the spool is empty
+		if moved > 0 {
+			return moved, nil
+		}
+	}
+}
+
+func (s *DiskSpool) count(moved, expired int) {
+	if moved > 0 {
+		s.replayed.Add(context.Background(), float64(moved))
+	}
+	if expired > 0 {
+		s.dropped.Add(context.Background(), float64(expired), attribute.String("reason", "expired"))
+	}
+}
+
+// next reads the batches after the read position, nil if the spool is empty
+func (s *DiskSpool) next() (*spillBatch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.segments) > 0 {
+		seq := s.segments[0]
+		file, err := os.Open(s.segmentPath(seq))
+		if os.IsNotExist(err) {
+			s.removeOldest()
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		batch, err := s.read(file, seq, s.readOffset)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(batch.records) == 0 && batch.exhausted {
+			s.removeOldest()
+			continue
+		}
+		return batch, nil
+	}
+	if s.file == nil {
+		return nil, nil
+	}
+	// The current segment stays open, writes are done under the lock, so it ends with a whole record
+	file, err := os.Open(s.segmentPath(s.fileSeq))
+	if err != nil {
+		return nil, err
+	}
+	batch, err := s.read(file, s.fileSeq, s.readOffset)
+	file.Close()
+	if err != nil || len(batch.records) == 0 {
+		return nil, err
+	}
+	batch.exhausted = false
+	return batch, nil
+}
+
+func (s *DiskSpool) read(file *os.File, seq uint64, offset int64) (*spillBatch, error) {
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(file)
+	batch := &spillBatch{seq: seq, end: offset}
+	size := 0
+	for len(batch.records) < spillDrainBatchSize && size < spillDrainBatchBytes {
+		record, n, err := s.readRecord(reader)
+		if err == io.EOF {
+			batch.exhausted = true
+			break
+		}
+		if err == errSpillCorrupted {
+			log.Printf("skipping corrupted tail of spill segment %016x at %d", seq, batch.end)
+			s.dropped.Add(context.Background(), 1, attribute.String("reason", "corrupted"))
+			batch.exhausted = true
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		batch.records = append(batch.records, record)
+		batch.offsets = append(batch.offsets, batch.end)
+		batch.end += n
+		size += len(record.value)
+	}
+	return batch, nil
+}
+
+// readRecord returns io.EOF at the end of the segment and errSpillCorrupted for torn writes
+func (s *DiskSpool) readRecord(reader io.Reader) (*spillRecord, int64, error) {
+	header := make([]byte, spillHeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, 0, errSpillCorrupted
+		}
+		return nil, 0, err
+	}
+	size := binary.LittleEndian.Uint32(header[0:])
+	if size < spillFixedBodySize || int64(size) > s.maxSize {
+		return nil, 0, errSpillCorrupted
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, 0, errSpillCorrupted
+		}
+		return nil, 0, err
+	}
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(header[4:]) {
+		return nil, 0, errSpillCorrupted
+	}
+	topicLen := int(binary.LittleEndian.Uint16(body[24:]))
+	if spillFixedBodySize+topicLen > len(body) {
+		return nil, 0, errSpillCorrupted
+	}
+	return &spillRecord{
+		timestamp: time.Unix(0, int64(binary.LittleEndian.Uint64(body[0:]))),
+		partition: int64(binary.LittleEndian.Uint64(body[8:])),
+		key:       binary.LittleEndian.Uint64(body[16:]),
+		topic:     string(body[spillFixedBodySize : spillFixedBodySize+topicLen]),
+		value:     body[spillFixedBodySize+topicLen:],
+	}, int64(spillHeaderSize) + int64(size), nil
+}
+
+// advance moves the read position after the replayed records, the segment could expire in the meantime
+func (s *DiskSpool) advance(seq uint64, offset int64, exhausted bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	oldest := len(s.segments) > 0 && s.segments[0] == seq
+	current := len(s.segments) == 0 && s.file != nil && s.fileSeq == seq
+	if !oldest && !current {
+		return
+	}
+	if exhausted && oldest {
+		s.removeOldest()
+		return
+	}
+	s.readOffset = offset
+	if err := s.writePosition(seq, offset); err != nil {
+		log.Printf("can't save spill position: %s", err)
+	}
+}
+
+// countSpillRecords reads only headers of the records after the offset
+func countSpillRecords(path string, offset int64) int {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return 0
+	}
+	reader := bufio.NewReader(file)
+	header := make([]byte, spillHeaderSize)
+	n := 0
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return n
+		}
+		if _, err := reader.Discard(int(binary.LittleEndian.Uint32(header[0:]))); err != nil {
+			return n
+		}
+		n++
+	}
+}
+
+// Flush syncs the current segment to the disk
+func (s *DiskSpool) Flush(_ int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return
+	}
+	if err := s.file.Sync(); err != nil {
+		log.Printf("can't sync spill segment: %s", err)
+	}
+}
+
+// Close keeps not replayed batches, they are replayed after restart
+func (s *DiskSpool) Close(timeout int) {
+	s.Flush(timeout)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rotate()
+}
+
+// NewSpillingProducer keeps batches in the disk spool while the queue doesn't accept them for longer than timeout
+func NewSpillingProducer(messageSizeLimit int, useBatch bool, spool *DiskSpool, timeout time.Duration) types.Producer {
+	primary := newProducer(messageSizeLimit, useBatch)
+	health, _ := primary.(HealthChecker)
+	return compressBatches(encodeBatches(NewFailoverProducer(primary, health, spool, timeout)))
+}
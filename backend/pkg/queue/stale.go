@@ -0,0 +1,69 @@
+package queue
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+
+	"openreplay/backend/pkg/env"
+	"openreplay/backend/pkg/flakeid"
+	"openreplay/backend/pkg/queue/types"
+)
+
+// staleFilter drops messages which can't get into a live session anymore:
+// messages stuck in the queue for longer than maxAge and messages of sessions
+// started before the retention period (their data is already deleted)
+type staleFilter struct {
+	maxAge    time.Duration // 0 disables the check
+	retention time.Duration // 0 disables the check
+	dropped   uint64
+}
+
+func newStaleFilter(maxAge, retention time.Duration) *staleFilter {
+	f := &staleFilter{
+		maxAge:    maxAge,
+		retention: retention,
+	}
+	go f.report()
+	return f
+}
+
+func (f *staleFilter) isStale(sessionID uint64, meta *types.Meta) bool {
+	now := time.Now().UnixMilli()
+	if f.maxAge > 0 && meta.Timestamp > 0 && now-meta.Timestamp > f.maxAge.Milliseconds() {
+		return true
+	}
+	if f.retention > 0 && sessionID != 0 && now-int64(flakeid.ExtractTimestamp(sessionID)) > f.retention.Milliseconds() {
+		return true
+	}
+	return false
+}
+
+func (f *staleFilter) wrap(handler types.MessageHandler) types.MessageHandler {
+	return func(sessionID uint64, value []byte, meta *types.Meta) {
+		if f.isStale(sessionID, meta) {
+			atomic.AddUint64(&f.dropped, 1)
+			return
+		}
+		handler(sessionID, value, meta)
+	}
+}
+
+func (f *staleFilter) report() {
+	for range time.Tick(time.Minute) {
+		if dropped := atomic.SwapUint64(&f.dropped, 0); dropped > 0 {
+			log.Printf("dropped stale messages: %d", dropped)
+		}
+	}
+}
+
+// dropStaleMessages wraps the handler if QUEUE_MAX_MESSAGE_AGE or QUEUE_SESSION_RETENTION is set,
+// both are set for each service, so every consumer group has its own limits
+func dropStaleMessages(handler types.MessageHandler) types.MessageHandler {
+	maxAge := env.DurationOptional("QUEUE_MAX_MESSAGE_AGE")
+	retention := env.DurationOptional("QUEUE_SESSION_RETENTION")
+	if maxAge == 0 && retention == 0 {
+		return handler
+	}
+	return newStaleFilter(maxAge, retention).wrap(handler)
+}
@@ -1,6 +1,9 @@
 package types
 
 import (
+	"errors"
+	"fmt"
+
 	"openreplay/backend/pkg/messages"
 )
 
@@ -10,8 +13,41 @@ type Consumer interface {
 	CommitBack(gap int64) error
 	Close()
 	HasFirstPartition() bool
+	SetPartitionListener(listener PartitionListener)
+}
+
+// TopicPartition identifies a partition, partitions of different topics have the same numbers
+type TopicPartition struct {
+	Topic     string
+	Partition uint64
+}
+
+func (tp TopicPartition) String() string {
+	return fmt.Sprintf("%s[%d]", tp.Topic, tp.Partition)
+}
+
+// PartitionListener is notified when partitions are handed over between consumers of the same group
+type PartitionListener interface {
+	Assigned(partitions []TopicPartition)
+	Revoked(partitions []TopicPartition)
 }
 
+// PartitionLag is the number of messages of the partition which aren't committed by the group yet
+type PartitionLag struct {
+	TopicPartition
+	Lag int64
+}
+
+// LagReporter is implemented by consumers which can measure the lag of their partitions,
+// Lag is called concurrently with ConsumeNext
+type LagReporter interface {
+	Lag() ([]PartitionLag, error)
+}
+
+// ErrBackpressure is returned by producers which have too many bytes waiting for the broker,
+// the message isn't sent and the caller should ask its client to retry later
+var ErrBackpressure = errors.New("producer buffer is full")
+
 type Producer interface {
 	Produce(topic string, key uint64, value []byte) error
 	ProduceToPartition(topic string, partition, key uint64, value []byte) error
@@ -22,9 +58,14 @@ type Producer interface {
 type Meta struct {
 	ID        uint64
 	Topic     string
+	Partition uint64
 	Timestamp int64
 }
 
+func (m *Meta) TopicPartition() TopicPartition {
+	return TopicPartition{Topic: m.Topic, Partition: m.Partition}
+}
+
 type MessageHandler func(uint64, []byte, *Meta)
 type DecodedMessageHandler func(uint64, messages.Message, *Meta)
 type RawMessageHandler func(uint64, messages.Iterator, *Meta)
@@ -0,0 +1,8 @@
+package reconcile
+
+import "errors"
+
+// NewStore returns an error in the community edition, sessions are stored only in postgres
+func NewStore(_ string) (Store, error) {
+	return nil, errors.New("consistency check is available only in the enterprise edition")
+}
@@ -0,0 +1,166 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+
+	"openreplay/backend/pkg/db/postgres"
+	"openreplay/backend/pkg/db/types"
+	"openreplay/backend/pkg/monitoring"
+)
+
+// Number of session ids written to the log for each kind of drift
+const sampleSize = 10
+
+// Store is the analytics copy of sessions and events (ClickHouse in the enterprise edition)
+type Store interface {
+	// SessionIDs returns sessions started in [from, to) time range
+	SessionIDs(from, to time.Time) (map[uint64]bool, error)
+	// SessionsWithEvents returns sessions from the list which have at least one event
+	SessionsWithEvents(from time.Time, sessionIDs []uint64) (map[uint64]bool, error)
+	InsertSessions(sessions []*types.Session) error
+	Close() error
+}
+
+type Report struct {
+	From              time.Time
+	To                time.Time
+	Postgres          int      // all web sessions in postgres
+	Store             int      // sessions in the analytics store
+	MissingInStore    []uint64 // finished sessions without a row in the analytics store
+	MissingInPostgres []uint64
+	MissingEvents     []uint64 // sessions with events in postgres counters, but without events in the analytics store
+	Repaired          int
+}
+
+func (r *Report) String() string {
+	return fmt.Sprintf("from: %s, to: %s, postgres: %d, store: %d, missing in store: %d %v, missing in postgres: %d %v, missing events: %d %v, repaired: %d",
+		r.From.Format(time.RFC3339), r.To.Format(time.RFC3339), r.Postgres, r.Store,
+		len(r.MissingInStore), sample(r.MissingInStore),
+		len(r.MissingInPostgres), sample(r.MissingInPostgres),
+		len(r.MissingEvents), sample(r.MissingEvents),
+		r.Repaired)
+}
+
+func sample(ids []uint64) []uint64 {
+	if len(ids) > sampleSize {
+		return ids[:sampleSize]
+	}
+	return ids
+}
+
+// Checker compares sessions in postgres with the analytics store. Only sessions missing in the store
+// can be repaired, events and sessions missing in postgres exist only in the message stream.
+type Checker struct {
+	pg              *postgres.Conn
+	store           Store
+	repair          bool
+	missingSessions syncfloat64.Counter
+	missingEvents   syncfloat64.Counter
+	repairedCounter syncfloat64.Counter
+}
+
+func NewChecker(pg *postgres.Conn, store Store, repair bool, metrics *monitoring.Metrics) (*Checker, error) {
+	switch {
+	case pg == nil:
+		return nil, fmt.Errorf("postgres connection is empty")
+	case store == nil:
+		return nil, fmt.Errorf("analytics store is empty")
+	case metrics == nil:
+		return nil, fmt.Errorf("metrics module is empty")
+	}
+	c := &Checker{pg: pg, store: store, repair: repair}
+	var err error
+	if c.missingSessions, err = metrics.RegisterCounter("reconcile_missing_sessions"); err != nil {
+		return nil, fmt.Errorf("can't register reconcile_missing_sessions metric: %s", err)
+	}
+	if c.missingEvents, err = metrics.RegisterCounter("reconcile_missing_events"); err != nil {
+		return nil, fmt.Errorf("can't register reconcile_missing_events metric: %s", err)
+	}
+	if c.repairedCounter, err = metrics.RegisterCounter("reconcile_repaired_sessions"); err != nil {
+		return nil, fmt.Errorf("can't register reconcile_repaired_sessions metric: %s", err)
+	}
+	return c, nil
+}
+
+// Check compares sessions started in [from, to) time range, the range has to end
+// long enough ago for all finished sessions to be saved in both stores
+func (c *Checker) Check(from, to time.Time) (*Report, error) {
+	pgSessions, err := c.pg.GetWebSessionSummaries(from.UnixMilli(), to.UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("can't get postgres sessions: %s", err)
+	}
+	storeSessions, err := c.store.SessionIDs(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("can't get store sessions: %s", err)
+	}
+	report := &Report{From: from, To: to, Postgres: len(pgSessions), Store: len(storeSessions)}
+
+	var withEvents []uint64
+	for sessionID, summary := range pgSessions {
+		// Unfinished sessions aren't saved to the store yet
+		if !summary.Finished {
+			continue
+		}
+		if !storeSessions[sessionID] {
+			report.MissingInStore = append(report.MissingInStore, sessionID)
+		}
+		if summary.EventsCount > 0 {
+			withEvents = append(withEvents, sessionID)
+		}
+	}
+	for sessionID := range storeSessions {
+		if _, ok := pgSessions[sessionID]; !ok {
+			report.MissingInPostgres = append(report.MissingInPostgres, sessionID)
+		}
+	}
+	if len(withEvents) > 0 {
+		storeEvents, err := c.store.SessionsWithEvents(from, withEvents)
+		if err != nil {
+			return nil, fmt.Errorf("can't get store events: %s", err)
+		}
+		for _, sessionID := range withEvents {
+			if !storeEvents[sessionID] {
+				report.MissingEvents = append(report.MissingEvents, sessionID)
+			}
+		}
+	}
+	for _, ids := range [][]uint64{report.MissingInStore, report.MissingInPostgres, report.MissingEvents} {
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	}
+
+	ctx := context.Background()
+	c.missingSessions.Add(ctx, float64(len(report.MissingInStore)), attribute.String("missing_in", "store"))
+	c.missingSessions.Add(ctx, float64(len(report.MissingInPostgres)), attribute.String("missing_in", "postgres"))
+	c.missingEvents.Add(ctx, float64(len(report.MissingEvents)))
+
+	if c.repair && len(report.MissingInStore) > 0 {
+		report.Repaired = c.repairStore(report.MissingInStore)
+		c.repairedCounter.Add(ctx, float64(report.Repaired))
+	}
+	return report, nil
+}
+
+// repairStore copies missing sessions from postgres, events can't be restored this way
+func (c *Checker) repairStore(sessionIDs []uint64) int {
+	sessions := make([]*types.Session, 0, len(sessionIDs))
+	for _, sessionID := range sessionIDs {
+		session, err := c.pg.GetSession(sessionID)
+		if err != nil {
+			log.Printf("can't get session for repair, sessID: %d, err: %s", sessionID, err)
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	if err := c.store.InsertSessions(sessions); err != nil {
+		log.Printf("can't repair sessions: %s", err)
+		return 0
+	}
+	return len(sessions)
+}
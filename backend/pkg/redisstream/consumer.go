@@ -1,8 +1,10 @@
 package redisstream
 
 import (
+	"fmt"
 	"log"
 	"net"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -11,26 +13,58 @@ import (
 	_redis "github.com/go-redis/redis"
 	"github.com/pkg/errors"
 
+	"openreplay/backend/pkg/env"
 	"openreplay/backend/pkg/queue/types"
 )
 
+const (
+	READ_COUNT           = 10
+	CLAIM_COUNT          = 100
+	defaultClaimInterval = 30 * time.Second
+	defaultClaimMinIdle  = 5 * time.Minute // the longest commit interval of services fits into it
+	defaultMaxDeliveries = 10
+)
+
 type idsInfo struct {
 	id []string
 	ts []int64
 }
+
+// add keeps ids sorted by timestamps, claimed messages are older than the read ones
+func (info *idsInfo) add(id string, ts int64) {
+	i := sort.Search(len(info.ts), func(i int) bool {
+		return info.ts[i] > ts
+	})
+	info.id = append(info.id, "")
+	info.ts = append(info.ts, 0)
+	copy(info.id[i+1:], info.id[i:])
+	copy(info.ts[i+1:], info.ts[i:])
+	info.id[i] = id
+	info.ts[i] = ts
+}
+
 type streamPendingIDsMap map[string]*idsInfo
 
+// Consumer reads streams as a member of the consumer group. Messages which were delivered to the consumer
+// before its restart are read first. Messages of consumers which are gone are claimed after
+// REDIS_CLAIM_MIN_IDLE, the ones which failed REDIS_MAX_DELIVERIES times are dropped.
 type Consumer struct {
 	redis          *_redis.Client
 	streams        []string
 	group          string
+	consumer       string
 	messageHandler types.MessageHandler
 	idsPending     streamPendingIDsMap
 	lastTs         int64
 	autoCommit     bool
+	recovering     bool
+	claimInterval  time.Duration
+	claimMinIdle   time.Duration
+	maxDeliveries  int64
+	lastClaim      time.Time
 }
 
-func NewConsumer(group string, streams []string, messageHandler types.MessageHandler) *Consumer {
+func NewConsumer(group string, streams []string, messageHandler types.MessageHandler, autoCommit bool) *Consumer {
 	redis := getRedisClient()
 	for _, stream := range streams {
 		err := redis.XGroupCreateMkStream(stream, group, "0").Err()
@@ -43,80 +77,201 @@ func NewConsumer(group string, streams []string, messageHandler types.MessageHan
 
 	streamsCount := len(streams)
 	for i := 0; i < streamsCount; i++ {
-		// ">" is for never-delivered messages.
-		// Otherwise - never acknoledged only
-		// TODO: understand why in case of "0" it eats 100% cpu
-		streams = append(streams, ">")
+		// "0" is for messages delivered to the consumer but never acknowledged, the position is
+		// moved forward on each read. ">" is for never-delivered messages.
+		streams = append(streams, "0")
 
 		idsPending[streams[i]] = new(idsInfo)
 	}
 
+	// The name must survive restarts to get back own pending messages, pods of stateful sets keep hostnames
+	consumer := env.StringOptional("REDIS_CONSUMER_NAME")
+	if consumer == "" {
+		hostname, _ := os.Hostname()
+		consumer = fmt.Sprintf("%s-%s", group, hostname)
+	}
+	claimInterval := env.DurationOptional("REDIS_CLAIM_INTERVAL")
+	if claimInterval == 0 {
+		claimInterval = defaultClaimInterval
+	}
+	claimMinIdle := env.DurationOptional("REDIS_CLAIM_MIN_IDLE")
+	if claimMinIdle == 0 {
+		claimMinIdle = defaultClaimMinIdle
+	}
+	maxDeliveries := int64(env.IntOptional("REDIS_MAX_DELIVERIES"))
+	if maxDeliveries == 0 {
+		maxDeliveries = defaultMaxDeliveries
+	}
+
 	return &Consumer{
 		redis:          redis,
 		messageHandler: messageHandler,
 		streams:        streams,
 		group:          group,
-		autoCommit:     true,
+		consumer:       consumer,
+		autoCommit:     autoCommit,
+		recovering:     true,
 		idsPending:     idsPending,
+		claimInterval:  claimInterval,
+		claimMinIdle:   claimMinIdle,
+		maxDeliveries:  maxDeliveries,
+		lastClaim:      time.Now(),
 	}
 }
 
-const READ_COUNT = 10
-
 func (c *Consumer) ConsumeNext() error {
+	if time.Since(c.lastClaim) >= c.claimInterval {
+		c.lastClaim = time.Now()
+		if err := c.claim(); err != nil {
+			log.Printf("Redisstreams: can't claim pending messages: %s", err)
+		}
+	}
 	// MBTODO: read in go routine, send messages to channel
 	res, err := c.redis.XReadGroup(&_redis.XReadGroupArgs{
 		Group:    c.group,
-		Consumer: c.group,
+		Consumer: c.consumer,
 		Streams:  c.streams,
 		Count:    int64(READ_COUNT),
 		Block:    200 * time.Millisecond,
 	}).Result()
-	if err != nil {
+	if err != nil && err != _redis.Nil {
 		if err, ok := err.(net.Error); ok && err.Timeout() {
 			return nil
 		}
-		if err == _redis.Nil {
-			return nil
-		}
 		return err
 	}
+	if c.recovering {
+		c.moveRecoveryPositions(res)
+	}
 	for _, r := range res {
 		for _, m := range r.Messages {
-			sessionIDString, ok := m.Values["sessionID"].(string)
-			if !ok {
-				return errors.Errorf("Can not cast value for messageID %v", m.ID)
+			if err := c.handle(r.Stream, m); err != nil {
+				return err
 			}
-			sessionID, err := strconv.ParseUint(sessionIDString, 10, 64)
-			if err != nil {
-				return errors.Wrapf(err, "Can not parse sessionID '%v' for messageID %v", sessionID, m.ID)
+		}
+	}
+	return nil
+}
+
+// moveRecoveryPositions continues reading of own pending messages after the last read ones,
+// streams without pending messages are switched to new messages
+func (c *Consumer) moveRecoveryPositions(res []_redis.XStream) {
+	streamsCount := len(c.streams) / 2
+	read := make(map[string]string, len(res))
+	for _, r := range res {
+		if len(r.Messages) > 0 {
+			read[r.Stream] = r.Messages[len(r.Messages)-1].ID
+		}
+	}
+	c.recovering = false
+	for i := 0; i < streamsCount; i++ {
+		if c.streams[streamsCount+i] == ">" {
+			continue
+		}
+		if lastID, ok := read[c.streams[i]]; ok {
+			c.streams[streamsCount+i] = lastID
+			c.recovering = true
+		} else {
+			c.streams[streamsCount+i] = ">"
+		}
+	}
+}
+
+func (c *Consumer) handle(stream string, m _redis.XMessage) error {
+	// assumming that ID has a correct format
+	idParts := strings.Split(m.ID, "-")
+	ts, _ := strconv.ParseUint(idParts[0], 10, 64)
+	idx, _ := strconv.ParseUint(idParts[1], 10, 64)
+	if len(m.Values) == 0 {
+		// Pending message was trimmed from the stream by the max length limit
+		return c.redis.XAck(stream, c.group, m.ID).Err()
+	}
+	sessionIDString, ok := m.Values["sessionID"].(string)
+	if !ok {
+		return errors.Errorf("Can not cast value for messageID %v", m.ID)
+	}
+	sessionID, err := strconv.ParseUint(sessionIDString, 10, 64)
+	if err != nil {
+		return errors.Wrapf(err, "Can not parse sessionID '%v' for messageID %v", sessionID, m.ID)
+	}
+	valueString, ok := m.Values["value"].(string)
+	if !ok {
+		return errors.Errorf("Can not cast value for messageID %v", m.ID)
+	}
+	if idx > 0x1FFF {
+		return errors.New("Too many messages per ms in redis")
+	}
+	c.messageHandler(sessionID, []byte(valueString), &types.Meta{
+		Topic:     stream,
+		Timestamp: int64(ts),
+		ID:        ts<<13 | (idx & 0x1FFF), // Max: 4096 messages/ms for 69 years
+	})
+	if c.autoCommit {
+		if err = c.redis.XAck(stream, c.group, m.ID).Err(); err != nil {
+			return errors.Wrapf(err, "Acknoledgment error for messageID %v", m.ID)
+		}
+	} else {
+		if int64(ts) > c.lastTs {
+			c.lastTs = int64(ts)
+		}
+		c.idsPending[stream].add(m.ID, int64(ts))
+	}
+	return nil
+}
+
+// claim takes over messages which are pending in other consumers of the group for too long,
+// they are handled right away
+func (c *Consumer) claim() error {
+	for stream := range c.idsPending {
+		pending, err := c.redis.XPendingExt(&_redis.XPendingExtArgs{
+			Stream: stream,
+			Group:  c.group,
+			Start:  "-",
+			End:    "+",
+			Count:  CLAIM_COUNT,
+		}).Result()
+		if err != nil {
+			return err
+		}
+		var ids, dropped []string
+		for _, p := range pending {
+			if p.Consumer == c.consumer || p.Idle < c.claimMinIdle {
+				continue
 			}
-			valueString, ok := m.Values["value"].(string)
-			if !ok {
-				return errors.Errorf("Can not cast value for messageID %v", m.ID)
+			if p.RetryCount >= c.maxDeliveries {
+				dropped = append(dropped, p.Id)
+				continue
 			}
-			// assumming that ID has a correct format
-			idParts := strings.Split(m.ID, "-")
-			ts, _ := strconv.ParseUint(idParts[0], 10, 64)
-			idx, _ := strconv.ParseUint(idParts[1], 10, 64)
-			if idx > 0x1FFF {
-				return errors.New("Too many messages per ms in redis")
+			ids = append(ids, p.Id)
+		}
+		if len(dropped) > 0 {
+			log.Printf("Redisstreams: dropping %d messages of %s which were delivered %d times, first: %s",
+				len(dropped), stream, c.maxDeliveries, dropped[0])
+			if err := c.redis.XAck(stream, c.group, dropped...).Err(); err != nil {
+				return err
 			}
-			c.messageHandler(sessionID, []byte(valueString), &types.Meta{
-				Topic:     r.Stream,
-				Timestamp: int64(ts),
-				ID:        ts<<13 | (idx & 0x1FFF), // Max: 4096 messages/ms for 69 years
-			})
-			if c.autoCommit {
-				if err = c.redis.XAck(r.Stream, c.group, m.ID).Err(); err != nil {
-					return errors.Wrapf(err, "Acknoledgment error for messageID %v", m.ID)
-				}
-			} else {
-				c.lastTs = int64(ts)
-				c.idsPending[r.Stream].id = append(c.idsPending[r.Stream].id, m.ID)
-				c.idsPending[r.Stream].ts = append(c.idsPending[r.Stream].ts, int64(ts))
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		// Messages which were acknowledged or claimed by others in the meantime aren't returned
+		claimed, err := c.redis.XClaim(&_redis.XClaimArgs{
+			Stream:   stream,
+			Group:    c.group,
+			Consumer: c.consumer,
+			MinIdle:  c.claimMinIdle,
+			Messages: ids,
+		}).Result()
+		if err != nil {
+			return err
+		}
+		if len(claimed) > 0 {
+			log.Printf("Redisstreams: claimed %d messages of %s", len(claimed), stream)
+		}
+		for _, m := range claimed {
+			if err := c.handle(stream, m); err != nil {
+				return err
 			}
-
 		}
 	}
 	return nil
@@ -149,6 +304,9 @@ func (c *Consumer) CommitBack(gap int64) error {
 		maxI := sort.Search(len(idsInfo.ts), func(i int) bool {
 			return idsInfo.ts[i] > maxTs
 		})
+		if maxI == 0 {
+			continue
+		}
 		if err := c.redis.XAck(stream, c.group, idsInfo.id[:maxI]...).Err(); err != nil {
 			return errors.Wrapf(err, "Redisstreams: Acknoledgment error on commit %v", err)
 		}
@@ -158,6 +316,7 @@ func (c *Consumer) CommitBack(gap int64) error {
 	return nil
 }
 
+// Close leaves not acknowledged messages pending, they are read again after restart or claimed by other consumers
 func (c *Consumer) Close() {
 	// noop
 }
@@ -165,3 +324,49 @@ func (c *Consumer) Close() {
 func (c *Consumer) HasFirstPartition() bool {
 	return false
 }
+
+// SetPartitionListener does nothing because all consumers of the group read the same stream
+func (c *Consumer) SetPartitionListener(_ types.PartitionListener) {}
+
+// Lag returns the number of messages which aren't acknowledged by the group: never delivered ones (the lag
+// of XINFO GROUPS, redis 7 is required) and pending ones. Streams have no partitions, they are reported as 0.
+func (c *Consumer) Lag() ([]types.PartitionLag, error) {
+	streams := c.streams[:len(c.streams)/2]
+	lags := make([]types.PartitionLag, 0, len(streams))
+	for _, stream := range streams {
+		res, err := c.redis.Do("XINFO", "GROUPS", stream).Result()
+		if err != nil {
+			return nil, err
+		}
+		lag, err := groupLag(res, c.group)
+		if err != nil {
+			return nil, fmt.Errorf("stream %s: %s", stream, err)
+		}
+		lags = append(lags, types.PartitionLag{TopicPartition: types.TopicPartition{Topic: stream}, Lag: lag})
+	}
+	return lags, nil
+}
+
+// groupLag finds the group in the reply of XINFO GROUPS, every group is a flat list of fields and values
+func groupLag(res interface{}, group string) (int64, error) {
+	groups, _ := res.([]interface{})
+	for _, g := range groups {
+		fields, _ := g.([]interface{})
+		info := make(map[string]interface{}, len(fields)/2)
+		for i := 0; i+1 < len(fields); i += 2 {
+			if name, ok := fields[i].(string); ok {
+				info[name] = fields[i+1]
+			}
+		}
+		if info["name"] != group {
+			continue
+		}
+		lag, ok := info["lag"].(int64)
+		if !ok {
+			return 0, errors.New("lag of the group is unknown")
+		}
+		pending, _ := info["pending"].(int64)
+		return lag + pending, nil
+	}
+	return 0, fmt.Errorf("group %s doesn't exist", group)
+}
@@ -1,6 +1,9 @@
 package redisstream
 
 import (
+	"sync/atomic"
+	"time"
+
 	"github.com/go-redis/redis"
 
 	"openreplay/backend/pkg/env"
@@ -9,6 +12,7 @@ import (
 type Producer struct {
 	redis        *redis.Client
 	maxLenApprox int64
+	downSince    int64 // unix nano time of the first failure after the last successful write
 }
 
 func NewProducer() *Producer {
@@ -30,13 +34,32 @@ func (p *Producer) Produce(topic string, key uint64, value []byte) error {
 
 	_, err := p.redis.XAdd(args).Result()
 	if err != nil {
+		atomic.CompareAndSwapInt64(&p.downSince, 0, time.Now().UnixNano())
 		return err
 	}
+	atomic.StoreInt64(&p.downSince, 0)
 	return nil
 }
 
-func (p *Producer) ProduceToPartition(topic string, partition, key uint64, value []byte) error {
-	// not implemented
+// ProduceToPartition writes to the stream of the topic, streams have no partitions
+func (p *Producer) ProduceToPartition(topic string, _, key uint64, value []byte) error {
+	return p.Produce(topic, key, value)
+}
+
+// Unavailable returns how long redis doesn't accept messages
+func (p *Producer) Unavailable() time.Duration {
+	downSince := atomic.LoadInt64(&p.downSince)
+	if downSince == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(0, downSince))
+}
+
+func (p *Producer) Ping() error {
+	if err := p.redis.Ping().Err(); err != nil {
+		return err
+	}
+	atomic.StoreInt64(&p.downSince, 0)
 	return nil
 }
 
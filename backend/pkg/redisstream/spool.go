@@ -0,0 +1,96 @@
+package redisstream
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/go-redis/redis"
+
+	"openreplay/backend/pkg/queue/types"
+)
+
+const spoolBatchSize = 1000
+
+// Spool keeps messages of all topics in one stream while the main broker is unavailable
+type Spool struct {
+	redis  *redis.Client
+	stream string
+}
+
+func NewSpool(stream string) *Spool {
+	return &Spool{
+		redis:  getRedisClient(),
+		stream: stream,
+	}
+}
+
+func (s *Spool) add(topic string, partition int64, key uint64, value []byte) error {
+	return s.redis.XAdd(&redis.XAddArgs{
+		Stream: s.stream,
+		Values: map[string]interface{}{
+			"topic":     topic,
+			"partition": partition,
+			"sessionID": key,
+			"value":     value,
+		},
+	}).Err()
+}
+
+func (s *Spool) Produce(topic string, key uint64, value []byte) error {
+	return s.add(topic, -1, key, value)
+}
+
+func (s *Spool) ProduceToPartition(topic string, partition, key uint64, value []byte) error {
+	return s.add(topic, int64(partition), key, value)
+}
+
+// Drain sends the oldest batch of messages to the target and removes them from the stream
+func (s *Spool) Drain(target types.Producer) (int, error) {
+	messages, err := s.redis.XRangeN(s.stream, "-", "+", spoolBatchSize).Result()
+	if err != nil {
+		return 0, err
+	}
+	for i, msg := range messages {
+		if err := s.produce(target, msg.Values); err != nil {
+			s.delete(messages[:i])
+			return i, fmt.Errorf("can't move message %s: %s", msg.ID, err)
+		}
+	}
+	return len(messages), s.delete(messages)
+}
+
+func (s *Spool) produce(target types.Producer, values map[string]interface{}) error {
+	topic, _ := values["topic"].(string)
+	rawValue, _ := values["value"].(string)
+	key, err := strconv.ParseUint(fmt.Sprint(values["sessionID"]), 10, 64)
+	if err != nil {
+		return err
+	}
+	partition, err := strconv.ParseInt(fmt.Sprint(values["partition"]), 10, 64)
+	if err != nil {
+		return err
+	}
+	if partition < 0 {
+		return target.Produce(topic, key, []byte(rawValue))
+	}
+	return target.ProduceToPartition(topic, uint64(partition), key, []byte(rawValue))
+}
+
+func (s *Spool) delete(messages []redis.XMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	ids := make([]string, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.ID
+	}
+	return s.redis.XDel(s.stream, ids...).Err()
+}
+
+func (s *Spool) Close(_ int) {
+	// noop
+}
+
+func (s *Spool) Flush(_ int) {
+	// noop
+}
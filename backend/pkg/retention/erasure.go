@@ -0,0 +1,105 @@
+package retention
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"openreplay/backend/pkg/db/postgres"
+)
+
+// ErasureReport is saved with the request as the evidence of the erasure
+type ErasureReport struct {
+	Sessions          []uint64 `json:"sessions"` // all erased sessions
+	PostgresSessions  int      `json:"postgresSessions"`
+	AnalyticsSessions int      `json:"analyticsSessions"`
+	Files             int      `json:"files"`
+	StartedAt         int64    `json:"startedAt"` // ms
+	FinishedAt        int64    `json:"finishedAt"`
+	Error             string   `json:"error,omitempty"`
+}
+
+// RunErasures processes pending erasure requests one by one, they aren't processed in the dry run mode.
+// A failed request is saved with the error in its report and has to be requested again.
+func (c *Cleaner) RunErasures(limit int) error {
+	if c.dryRun {
+		return nil
+	}
+	requests, err := c.pg.GetPendingErasureRequests(limit)
+	if err != nil {
+		return fmt.Errorf("can't get erasure requests: %s", err)
+	}
+	for _, req := range requests {
+		report := c.erase(req)
+		status := postgres.ErasureDone
+		if report.Error != "" {
+			status = postgres.ErasureFailed
+			log.Printf("retention: erasure %d of project %d failed: %s", req.ErasureID, req.ProjectID, report.Error)
+		}
+		data, err := json.Marshal(report)
+		if err != nil {
+			return fmt.Errorf("can't encode erasure report: %s", err)
+		}
+		if err := c.pg.FinishErasureRequest(req.ErasureID, status, data); err != nil {
+			// The request stays pending and is processed again, the erasure is idempotent
+			return fmt.Errorf("can't save erasure report %d: %s", req.ErasureID, err)
+		}
+		c.erasures.Add(context.Background(), 1, attribute.String("status", status))
+	}
+	return nil
+}
+
+// erase deletes files first, then analytics rows and postgres rows last, so sessions of a failed
+// erasure are still found by their user in postgres when the erasure is requested again
+func (c *Cleaner) erase(req *postgres.ErasureRequest) *ErasureReport {
+	report := &ErasureReport{StartedAt: time.Now().UnixMilli()}
+	fail := func(format string, args ...interface{}) *ErasureReport {
+		report.Error = fmt.Sprintf(format, args...)
+		report.FinishedAt = time.Now().UnixMilli()
+		return report
+	}
+	pgIDs, err := c.pg.GetUserSessionIDs(req.ProjectID, req.UserID, req.AnonymousID)
+	if err != nil {
+		return fail("can't get sessions: %s", err)
+	}
+	report.PostgresSessions = len(pgIDs)
+	ids := make(map[uint64]bool, len(pgIDs))
+	for _, id := range pgIDs {
+		ids[id] = true
+	}
+	if c.store != nil {
+		storeIDs, err := c.store.UserSessionIDs(req.ProjectID, req.UserID, req.AnonymousID)
+		if err != nil {
+			return fail("can't get analytics sessions: %s", err)
+		}
+		report.AnalyticsSessions = len(storeIDs)
+		for _, id := range storeIDs {
+			ids[id] = true
+		}
+	}
+	report.Sessions = make([]uint64, 0, len(ids))
+	for id := range ids {
+		report.Sessions = append(report.Sessions, id)
+	}
+	sort.Slice(report.Sessions, func(i, j int) bool { return report.Sessions[i] < report.Sessions[j] })
+
+	if report.Files, err = c.deleteFiles(req.ProjectID, report.Sessions); err != nil {
+		return fail("can't delete files: %s", err)
+	}
+	if c.store != nil {
+		if err := c.store.EraseUser(req.ProjectID, req.UserID, req.AnonymousID, report.Sessions); err != nil {
+			return fail("can't erase analytics sessions: %s", err)
+		}
+	}
+	if err := c.pg.EraseUser(req.ProjectID, req.UserID, req.AnonymousID, report.Sessions); err != nil {
+		return fail("can't erase sessions: %s", err)
+	}
+	c.sessions.Add(context.Background(), float64(len(report.Sessions)), c.attributes(req.ProjectID)...)
+	report.FinishedAt = time.Now().UnixMilli()
+	return report
+}
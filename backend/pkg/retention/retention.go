@@ -0,0 +1,239 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+
+	"openreplay/backend/pkg/db/postgres"
+	"openreplay/backend/pkg/monitoring"
+	"openreplay/backend/pkg/storage"
+)
+
+// Store is the analytics copy of sessions and events (ClickHouse in the enterprise edition)
+type Store interface {
+	// CountBefore returns the number of sessions of the project started before the time
+	CountBefore(projectID uint32, before time.Time) (uint64, error)
+	// DeleteBefore removes sessions and events of the project started before the time
+	DeleteBefore(projectID uint32, before time.Time) error
+	// UserSessionIDs returns sessions of the project with the user id or the anonymous id, empty ids are ignored
+	UserSessionIDs(projectID uint32, userID, anonymousID string) ([]uint64, error)
+	// EraseUser removes the sessions with their events and autocomplete values of the user
+	EraseUser(projectID uint32, userID, anonymousID string, sessionIDs []uint64) error
+	Close() error
+}
+
+// Cleaner deletes sessions older than the retention of their project. Files of the sessions are deleted
+// first, so sessions whose files weren't deleted stay in postgres and are found again by the next run.
+type Cleaner struct {
+	pg               *postgres.Conn
+	store            Store                 // nil if sessions are stored only in postgres
+	objects          storage.ObjectStorage // nil if files of sessions are kept
+	canvasPrefix     string
+	defaultRetention time.Duration // 0 keeps sessions of projects without their own retention
+	batchSize        int
+	dryRun           bool
+	sessions         syncfloat64.Counter
+	files            syncfloat64.Counter
+	failures         syncfloat64.Counter
+	duration         syncfloat64.Histogram
+	erasures         syncfloat64.Counter
+}
+
+func NewCleaner(pg *postgres.Conn, store Store, objects storage.ObjectStorage, canvasPrefix string,
+	defaultRetention time.Duration, batchSize int, dryRun bool, metrics *monitoring.Metrics) (*Cleaner, error) {
+	switch {
+	case pg == nil:
+		return nil, fmt.Errorf("postgres connection is empty")
+	case defaultRetention < 0:
+		return nil, fmt.Errorf("wrong default retention: %s", defaultRetention)
+	case batchSize <= 0:
+		return nil, fmt.Errorf("wrong batch size: %d", batchSize)
+	case metrics == nil:
+		return nil, fmt.Errorf("metrics module is empty")
+	}
+	c := &Cleaner{
+		pg:               pg,
+		store:            store,
+		objects:          objects,
+		canvasPrefix:     canvasPrefix,
+		defaultRetention: defaultRetention,
+		batchSize:        batchSize,
+		dryRun:           dryRun,
+	}
+	var err error
+	if c.sessions, err = metrics.RegisterCounter("retention_deleted_sessions"); err != nil {
+		return nil, fmt.Errorf("can't register retention_deleted_sessions metric: %s", err)
+	}
+	if c.files, err = metrics.RegisterCounter("retention_deleted_files"); err != nil {
+		return nil, fmt.Errorf("can't register retention_deleted_files metric: %s", err)
+	}
+	if c.failures, err = metrics.RegisterCounter("retention_failures"); err != nil {
+		return nil, fmt.Errorf("can't register retention_failures metric: %s", err)
+	}
+	if c.duration, err = metrics.RegisterHistogram("retention_run_duration"); err != nil {
+		return nil, fmt.Errorf("can't register retention_run_duration metric: %s", err)
+	}
+	if c.erasures, err = metrics.RegisterCounter("retention_erasures"); err != nil {
+		return nil, fmt.Errorf("can't register retention_erasures metric: %s", err)
+	}
+	return c, nil
+}
+
+// Run cleans all projects, a failed project doesn't stop the others and is cleaned again by the next run
+func (c *Cleaner) Run(now time.Time) error {
+	start := time.Now()
+	defer func() {
+		c.duration.Record(context.Background(), float64(time.Since(start).Milliseconds()),
+			attribute.Bool("dry_run", c.dryRun))
+	}()
+	retentions, err := c.pg.GetProjectRetentions()
+	if err != nil {
+		return fmt.Errorf("can't get project retentions: %s", err)
+	}
+	failed := 0
+	for projectID, days := range retentions {
+		retention := c.defaultRetention
+		if days > 0 {
+			retention = time.Duration(days) * 24 * time.Hour
+		}
+		if retention == 0 {
+			continue
+		}
+		if err := c.clean(projectID, now.Add(-retention)); err != nil {
+			log.Printf("retention: can't clean project %d: %s", projectID, err)
+			c.failures.Add(context.Background(), 1, c.attributes(projectID)...)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d projects failed", failed, len(retentions))
+	}
+	return nil
+}
+
+func (c *Cleaner) attributes(projectID uint32) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Int64("project_id", int64(projectID)),
+		attribute.Bool("dry_run", c.dryRun),
+	}
+}
+
+func (c *Cleaner) clean(projectID uint32, before time.Time) error {
+	if c.dryRun {
+		return c.report(projectID, before)
+	}
+	total := 0
+	for {
+		ids, err := c.pg.GetSessionIDsBefore(projectID, before.UnixMilli(), c.batchSize)
+		if err != nil {
+			return fmt.Errorf("can't get sessions: %s", err)
+		}
+		if len(ids) == 0 {
+			break
+		}
+		if _, err := c.deleteFiles(projectID, ids); err != nil {
+			return fmt.Errorf("can't delete files: %s", err)
+		}
+		if err := c.pg.DeleteSessions(ids); err != nil {
+			return fmt.Errorf("can't delete sessions: %s", err)
+		}
+		total += len(ids)
+		c.sessions.Add(context.Background(), float64(len(ids)), c.attributes(projectID)...)
+		if len(ids) < c.batchSize {
+			break
+		}
+	}
+	if err := c.pg.DeleteUnusedIssues(projectID); err != nil {
+		return fmt.Errorf("can't delete issues: %s", err)
+	}
+	if c.store != nil {
+		count, err := c.store.CountBefore(projectID, before)
+		if err != nil {
+			return fmt.Errorf("can't count analytics sessions: %s", err)
+		}
+		// Deletes are heavy mutations in ClickHouse, they are run only if there is something to delete
+		if count > 0 {
+			if err := c.store.DeleteBefore(projectID, before); err != nil {
+				return fmt.Errorf("can't delete analytics sessions: %s", err)
+			}
+		}
+	}
+	if total > 0 {
+		log.Printf("retention: deleted %d sessions of project %d started before %s", total, projectID, before.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// report logs the number of sessions which would be deleted without deleting them
+func (c *Cleaner) report(projectID uint32, before time.Time) error {
+	count, err := c.pg.CountSessionsBefore(projectID, before.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("can't count sessions: %s", err)
+	}
+	var analytics uint64
+	if c.store != nil {
+		if analytics, err = c.store.CountBefore(projectID, before); err != nil {
+			return fmt.Errorf("can't count analytics sessions: %s", err)
+		}
+	}
+	if count > 0 || analytics > 0 {
+		log.Printf("retention (dry run): would delete %d sessions (%d in analytics) of project %d started before %s",
+			count, analytics, projectID, before.Format(time.RFC3339))
+		c.sessions.Add(context.Background(), float64(count), c.attributes(projectID)...)
+	}
+	return nil
+}
+
+// deleteFiles removes recordings, live chunks and canvas snapshots of the sessions, returns the number of files
+func (c *Cleaner) deleteFiles(projectID uint32, sessionIDs []uint64) (int, error) {
+	if c.objects == nil {
+		return 0, nil
+	}
+	var keys []string
+	for _, id := range sessionIDs {
+		sessionKey := strconv.FormatUint(id, 10)
+		err := c.objects.Walk(sessionKey, func(obj *storage.ObjectInfo) error {
+			if isSessionObject(obj.Key, sessionKey) {
+				keys = append(keys, obj.Key)
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		if c.canvasPrefix == "" {
+			continue // snapshots are under the session prefix
+		}
+		err = c.objects.Walk(c.canvasPrefix+sessionKey+"/", func(obj *storage.ObjectInfo) error {
+			keys = append(keys, obj.Key)
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	if err := c.objects.Delete(keys); err != nil {
+		return 0, err
+	}
+	c.files.Add(context.Background(), float64(len(keys)), c.attributes(projectID)...)
+	return len(keys), nil
+}
+
+// isSessionObject filters out objects of other sessions whose ids start with the same digits
+func isSessionObject(key, sessionKey string) bool {
+	if !strings.HasPrefix(key, sessionKey) {
+		return false
+	}
+	rest := key[len(sessionKey):]
+	return rest == "" || rest[0] < '0' || rest[0] > '9'
+}
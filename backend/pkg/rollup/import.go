@@ -0,0 +1,8 @@
+package rollup
+
+import "errors"
+
+// NewStore returns an error in the community edition, internal metrics are kept only in ClickHouse
+func NewStore(_ string) (Store, error) {
+	return nil, errors.New("metrics rollup is available only in the enterprise edition")
+}
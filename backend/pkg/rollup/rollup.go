@@ -0,0 +1,135 @@
+package rollup
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+
+	"openreplay/backend/pkg/monitoring"
+)
+
+// Max number of periods of the coarser level which are rolled up by one query
+const maxPeriodsPerQuery = 100
+
+// Level is one resolution of the metrics, the data of each level is computed from the previous one
+type Level struct {
+	Name      string
+	Period    time.Duration
+	Retention time.Duration // 0 keeps the data forever
+}
+
+// Store keeps metrics of all levels (ClickHouse in the enterprise edition)
+type Store interface {
+	// First returns the start of the oldest period of the level, zero time if the level is empty
+	First(level Level) (time.Time, error)
+	// Last returns the start of the newest period of the level, zero time if the level is empty
+	Last(level Level) (time.Time, error)
+	// Rollup aggregates data of the source level in [from, to) time range into periods of the target level
+	Rollup(source, target Level, from, to time.Time) error
+	// DropBefore removes data of the level which is older than the time, returns the number of removed partitions
+	DropBefore(level Level, before time.Time) (int, error)
+	Close() error
+}
+
+// Roller downsamples metrics level by level. Only finished periods are rolled up: the period of the target
+// level has to end lateness before now, so late writes to the source level are included.
+type Roller struct {
+	store    Store
+	levels   []Level
+	lateness time.Duration
+	periods  syncfloat64.Counter
+	dropped  syncfloat64.Counter
+}
+
+func NewRoller(store Store, levels []Level, lateness time.Duration, metrics *monitoring.Metrics) (*Roller, error) {
+	switch {
+	case store == nil:
+		return nil, fmt.Errorf("metrics store is empty")
+	case len(levels) < 2:
+		return nil, fmt.Errorf("at least two levels are required")
+	case metrics == nil:
+		return nil, fmt.Errorf("metrics module is empty")
+	}
+	for i := 1; i < len(levels); i++ {
+		source, target := levels[i-1], levels[i]
+		if target.Period <= source.Period || target.Period%source.Period != 0 {
+			return nil, fmt.Errorf("period of %s level isn't a multiple of %s level period", target.Name, source.Name)
+		}
+		// The source data of the period has to be there until the period is rolled up
+		if source.Retention != 0 && source.Retention <= target.Period+lateness {
+			return nil, fmt.Errorf("retention of %s level is shorter than the period of %s level", source.Name, target.Name)
+		}
+	}
+	periods, err := metrics.RegisterCounter("rollup_periods")
+	if err != nil {
+		return nil, fmt.Errorf("can't register rollup_periods metric: %s", err)
+	}
+	dropped, err := metrics.RegisterCounter("rollup_dropped_partitions")
+	if err != nil {
+		return nil, fmt.Errorf("can't register rollup_dropped_partitions metric: %s", err)
+	}
+	return &Roller{
+		store:    store,
+		levels:   levels,
+		lateness: lateness,
+		periods:  periods,
+		dropped:  dropped,
+	}, nil
+}
+
+// Run rolls up all finished periods and removes data older than the retention of each level
+func (r *Roller) Run(now time.Time) error {
+	for i := 1; i < len(r.levels); i++ {
+		if err := r.rollup(r.levels[i-1], r.levels[i], now); err != nil {
+			return fmt.Errorf("can't roll up %s level: %s", r.levels[i].Name, err)
+		}
+	}
+	for _, level := range r.levels {
+		if level.Retention == 0 {
+			continue
+		}
+		n, err := r.store.DropBefore(level, now.Add(-level.Retention))
+		if err != nil {
+			return fmt.Errorf("can't remove old data of %s level: %s", level.Name, err)
+		}
+		if n > 0 {
+			log.Printf("removed %d partitions of %s level", n, level.Name)
+			r.dropped.Add(context.Background(), float64(n), attribute.String("level", level.Name))
+		}
+	}
+	return nil
+}
+
+// rollup continues after the last period of the target level, it's written by one query, so the
+// periods are never aggregated twice
+func (r *Roller) rollup(source, target Level, now time.Time) error {
+	last, err := r.store.Last(target)
+	if err != nil {
+		return err
+	}
+	from := last.Add(target.Period)
+	if last.IsZero() {
+		first, err := r.store.First(source)
+		if err != nil || first.IsZero() {
+			return err
+		}
+		from = first.Truncate(target.Period)
+	}
+	end := now.Add(-r.lateness).Truncate(target.Period)
+	for from.Before(end) {
+		to := from.Add(maxPeriodsPerQuery * target.Period)
+		if to.After(end) {
+			to = end
+		}
+		if err := r.store.Rollup(source, target, from, to); err != nil {
+			return err
+		}
+		r.periods.Add(context.Background(), float64(to.Sub(from)/target.Period), attribute.String("level", target.Name))
+		from = to
+	}
+	return nil
+}
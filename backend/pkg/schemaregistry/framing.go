@@ -0,0 +1,63 @@
+package schemaregistry
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Values of Confluent serializers start with the magic byte and the schema id (big endian),
+// protobuf values also have indexes of the message type in the schema file after them
+const (
+	magicByte  = 0
+	headerSize = 5
+)
+
+// Frame returns the value of the protobuf message with the schema id, indexes is the path of the message type
+// in the schema file: [0] is the first top-level message, it's encoded as a single zero byte
+func Frame(id uint32, indexes []int, payload []byte) []byte {
+	value := make([]byte, headerSize, headerSize+binary.MaxVarintLen64*(len(indexes)+1)+len(payload))
+	value[0] = magicByte
+	binary.BigEndian.PutUint32(value[1:headerSize], id)
+	var tmp [binary.MaxVarintLen64]byte
+	if len(indexes) == 1 && indexes[0] == 0 {
+		value = append(value, 0)
+	} else {
+		value = append(value, tmp[:binary.PutVarint(tmp[:], int64(len(indexes)))]...)
+		for _, index := range indexes {
+			value = append(value, tmp[:binary.PutVarint(tmp[:], int64(index))]...)
+		}
+	}
+	return append(value, payload...)
+}
+
+// IsFramed reports values which start with the magic byte, other formats can start with it too
+func IsFramed(value []byte) bool {
+	return len(value) > headerSize && value[0] == magicByte
+}
+
+// Unframe returns the schema id, the message indexes and the protobuf message of the value
+func Unframe(value []byte) (uint32, []int, []byte, error) {
+	if !IsFramed(value) {
+		return 0, nil, nil, errors.New("value isn't framed by schema id")
+	}
+	id := binary.BigEndian.Uint32(value[1:headerSize])
+	data := value[headerSize:]
+	count, n := binary.Varint(data)
+	if n <= 0 || count < 0 || count > int64(len(data)) {
+		return 0, nil, nil, errors.New("wrong message indexes")
+	}
+	data = data[n:]
+	if count == 0 {
+		return id, []int{0}, data, nil
+	}
+	indexes := make([]int, 0, count)
+	for i := int64(0); i < count; i++ {
+		index, n := binary.Varint(data)
+		if n <= 0 {
+			return 0, nil, nil, errors.New("wrong message indexes")
+		}
+		indexes = append(indexes, int(index))
+		data = data[n:]
+	}
+	return id, indexes, data, nil
+}
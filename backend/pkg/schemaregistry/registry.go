@@ -0,0 +1,161 @@
+package schemaregistry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	contentType    = "application/vnd.schemaregistry.v1+json"
+	requestTimeout = 10 * time.Second
+
+	// Error code of the registry when the subject has no versions yet
+	errSubjectNotFound = 40401
+)
+
+const (
+	TypeProtobuf = "PROTOBUF"
+	TypeAvro     = "AVRO"
+)
+
+// Subject name strategies, the same as the ones of Confluent serializers
+const (
+	TopicNameStrategy       = "topic"        // <topic>-value
+	RecordNameStrategy      = "record"       // <record>
+	TopicRecordNameStrategy = "topic_record" // <topic>-<record>
+)
+
+type Schema struct {
+	Type   string `json:"schemaType"`
+	Schema string `json:"schema"`
+}
+
+// Subject returns the subject of values of the topic
+func Subject(strategy, topic, record string) (string, error) {
+	switch strategy {
+	case TopicNameStrategy, "":
+		return topic + "-value", nil
+	case RecordNameStrategy:
+		return record, nil
+	case TopicRecordNameStrategy:
+		return topic + "-" + record, nil
+	}
+	return "", fmt.Errorf("unknown subject name strategy: %s", strategy)
+}
+
+// Client registers schemas in Confluent Schema Registry, ids of registered schemas are cached
+type Client struct {
+	url      string
+	username string
+	password string
+	client   *http.Client
+	mu       sync.Mutex
+	ids      map[string]uint32 // subject to id
+}
+
+func New(registryURL, username, password string) (*Client, error) {
+	switch {
+	case registryURL == "":
+		return nil, fmt.Errorf("schema registry url is empty")
+	case (username == "") != (password == ""):
+		return nil, fmt.Errorf("both username and password are required for schema registry")
+	}
+	return &Client{
+		url:      strings.TrimRight(registryURL, "/"),
+		username: username,
+		password: password,
+		client:   &http.Client{Timeout: requestTimeout},
+		ids:      make(map[string]uint32),
+	}, nil
+}
+
+type registryError struct {
+	Code    int    `json:"error_code"`
+	Message string `json:"message"`
+}
+
+func (e *registryError) Error() string {
+	return fmt.Sprintf("schema registry error %d: %s", e.Code, e.Message)
+}
+
+func (c *Client) request(method, path string, body, result interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, c.url+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", contentType)
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		regErr := &registryError{}
+		if err := json.Unmarshal(respBody, regErr); err != nil || regErr.Code == 0 {
+			return fmt.Errorf("unexpected status of schema registry: %s", resp.Status)
+		}
+		return regErr
+	}
+	return json.Unmarshal(respBody, result)
+}
+
+// Compatible checks the schema against the latest version of the subject with the compatibility level
+// of the subject, any schema is compatible with a new subject
+func (c *Client) Compatible(subject string, schema *Schema) (bool, []string, error) {
+	result := struct {
+		IsCompatible bool     `json:"is_compatible"`
+		Messages     []string `json:"messages"`
+	}{}
+	path := "/compatibility/subjects/" + url.PathEscape(subject) + "/versions/latest?verbose=true"
+	if err := c.request(http.MethodPost, path, schema, &result); err != nil {
+		if regErr, ok := err.(*registryError); ok && regErr.Code == errSubjectNotFound {
+			return true, nil, nil
+		}
+		return false, nil, err
+	}
+	return result.IsCompatible, result.Messages, nil
+}
+
+// Register checks compatibility of the schema and registers it under the subject, the registry returns
+// the id of the existing version if the schema is registered already
+func (c *Client) Register(subject string, schema *Schema) (uint32, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if id, ok := c.ids[subject]; ok {
+		return id, nil
+	}
+	compatible, messages, err := c.Compatible(subject, schema)
+	if err != nil {
+		return 0, fmt.Errorf("can't check compatibility of %s: %s", subject, err)
+	}
+	if !compatible {
+		return 0, fmt.Errorf("schema isn't compatible with the latest version of %s: %s", subject, strings.Join(messages, "; "))
+	}
+	result := struct {
+		ID uint32 `json:"id"`
+	}{}
+	if err := c.request(http.MethodPost, "/subjects/"+url.PathEscape(subject)+"/versions", schema, &result); err != nil {
+		return 0, fmt.Errorf("can't register schema of %s: %s", subject, err)
+	}
+	c.ids[subject] = result.ID
+	return result.ID, nil
+}
@@ -0,0 +1,8 @@
+package search
+
+import "errors"
+
+// NewSearcher returns an error in the community edition, session search requires ClickHouse
+func NewSearcher(_ string) (Searcher, error) {
+	return nil, errors.New("session search is available only in the enterprise edition")
+}
@@ -0,0 +1,256 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+	maxFilters   = 50
+	maxValues    = 100
+	maxPeriod    = 90 * 24 * 60 * 60 * 1000 // ms, sessions are kept in ClickHouse for 3 months
+)
+
+// Operators of the filters, numeric filters support only is, gt and lt
+const (
+	OpIs         = "is"
+	OpIsNot      = "isNot"
+	OpContains   = "contains"
+	OpStartsWith = "startsWith"
+	OpEndsWith   = "endsWith"
+	OpGreater    = "gt"
+	OpLess       = "lt"
+)
+
+// Session attribute filters
+const (
+	FilterUserID      = "userId"
+	FilterAnonymousID = "userAnonymousId"
+	FilterUserCountry = "userCountry"
+	FilterUserOS      = "userOs"
+	FilterUserBrowser = "userBrowser"
+	FilterUserDevice  = "userDevice"
+	FilterPlatform    = "platform"
+	FilterReferrer    = "referrer"
+	FilterRevID       = "revId"
+	FilterDuration    = "duration" // ms
+	FilterMetadata    = "metadata" // key is the name of the project metadata field
+	FilterIssue       = "issue"    // values are issue types, any of them matches
+)
+
+// Event types, session matches if it has events of all listed filters
+const (
+	EventClick       = "click"
+	EventInput       = "input"
+	EventLocation    = "location"
+	EventRequest     = "request"
+	EventError       = "error"
+	EventCustom      = "custom"
+	EventGraphQL     = "graphql"
+	EventStateAction = "stateAction"
+)
+
+// Sort fields
+const (
+	SortStartTs     = "startTs"
+	SortDuration    = "duration"
+	SortEventsCount = "eventsCount"
+	SortErrorsCount = "errorsCount"
+	SortIssueScore  = "issueScore"
+)
+
+type Filter struct {
+	Type     string   `json:"type"`
+	Key      string   `json:"key,omitempty"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values"`
+	// Number of the metadata column, resolved from the key by the project config
+	MetadataNo uint `json:"-"`
+}
+
+type EventFilter struct {
+	Type     string   `json:"type"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values"`
+}
+
+type Request struct {
+	ProjectID      uint32         `json:"projectId"`
+	StartTimestamp int64          `json:"startTimestamp"`
+	EndTimestamp   int64          `json:"endTimestamp"`
+	Filters        []*Filter      `json:"filters"`
+	Events         []*EventFilter `json:"events"`
+	Sort           string         `json:"sort"`
+	Order          string         `json:"order"`
+	Page           int            `json:"page"`
+	Limit          int            `json:"limit"`
+}
+
+type Session struct {
+	SessionID   string            `json:"sessionId"` // string, because JS can't handle uint64
+	ProjectID   uint32            `json:"projectId"`
+	StartTs     int64             `json:"startTs"`
+	Duration    uint32            `json:"duration"`
+	Platform    string            `json:"platform"`
+	UserID      string            `json:"userId,omitempty"`
+	AnonymousID string            `json:"userAnonymousId,omitempty"`
+	UserCountry string            `json:"userCountry"`
+	UserOS      string            `json:"userOs"`
+	UserBrowser string            `json:"userBrowser"`
+	UserDevice  string            `json:"userDevice,omitempty"`
+	PagesCount  uint16            `json:"pagesCount"`
+	EventsCount uint16            `json:"eventsCount"`
+	ErrorsCount uint16            `json:"errorsCount"`
+	IssueScore  uint32            `json:"issueScore"`
+	IssueTypes  []string          `json:"issueTypes"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+type Response struct {
+	Total    uint64     `json:"total"`
+	Page     int        `json:"page"`
+	Limit    int        `json:"limit"`
+	Sessions []*Session `json:"sessions"`
+}
+
+// Searcher is implemented only in the enterprise edition, because it requires ClickHouse
+type Searcher interface {
+	Search(req *Request) (*Response, error)
+	Close() error
+}
+
+var (
+	stringOperators  = []string{OpIs, OpIsNot, OpContains, OpStartsWith, OpEndsWith}
+	numericOperators = []string{OpIs, OpGreater, OpLess}
+	issueOperators   = []string{OpIs, OpIsNot}
+)
+
+func isOneOf(value string, list []string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func checkValues(values []string) error {
+	switch {
+	case len(values) == 0:
+		return errors.New("values are empty")
+	case len(values) > maxValues:
+		return fmt.Errorf("too many values, max: %d", maxValues)
+	}
+	return nil
+}
+
+// Validate checks the request and sets default values, metadata keys must be resolved separately
+func (r *Request) Validate() error {
+	switch {
+	case r.ProjectID == 0:
+		return errors.New("projectId is empty")
+	case r.StartTimestamp <= 0 || r.EndTimestamp <= 0:
+		return errors.New("startTimestamp and endTimestamp are required")
+	case r.EndTimestamp < r.StartTimestamp:
+		return errors.New("endTimestamp is before startTimestamp")
+	case r.EndTimestamp-r.StartTimestamp > maxPeriod:
+		return errors.New("period is too long, max: 90 days")
+	case len(r.Filters)+len(r.Events) > maxFilters:
+		return fmt.Errorf("too many filters, max: %d", maxFilters)
+	}
+	for _, f := range r.Filters {
+		if err := f.validate(); err != nil {
+			return fmt.Errorf("wrong %s filter: %s", f.Type, err)
+		}
+	}
+	for _, e := range r.Events {
+		if err := e.validate(); err != nil {
+			return fmt.Errorf("wrong %s event filter: %s", e.Type, err)
+		}
+	}
+	if r.Sort == "" {
+		r.Sort = SortStartTs
+	}
+	if !isOneOf(r.Sort, []string{SortStartTs, SortDuration, SortEventsCount, SortErrorsCount, SortIssueScore}) {
+		return fmt.Errorf("wrong sort field: %s", r.Sort)
+	}
+	r.Order = strings.ToLower(r.Order)
+	if r.Order == "" {
+		r.Order = "desc"
+	}
+	if r.Order != "asc" && r.Order != "desc" {
+		return fmt.Errorf("wrong order: %s", r.Order)
+	}
+	if r.Page < 1 {
+		r.Page = 1
+	}
+	if r.Limit <= 0 {
+		r.Limit = DefaultLimit
+	}
+	if r.Limit > MaxLimit {
+		return fmt.Errorf("limit is too big, max: %d", MaxLimit)
+	}
+	return nil
+}
+
+func (f *Filter) validate() error {
+	if err := checkValues(f.Values); err != nil {
+		return err
+	}
+	switch f.Type {
+	case FilterUserID, FilterAnonymousID, FilterUserCountry, FilterUserOS, FilterUserBrowser,
+		FilterUserDevice, FilterPlatform, FilterReferrer, FilterRevID:
+		if !isOneOf(f.Operator, stringOperators) {
+			return fmt.Errorf("wrong operator: %s", f.Operator)
+		}
+	case FilterMetadata:
+		if f.Key == "" {
+			return errors.New("key is empty")
+		}
+		if !isOneOf(f.Operator, stringOperators) {
+			return fmt.Errorf("wrong operator: %s", f.Operator)
+		}
+	case FilterDuration:
+		if !isOneOf(f.Operator, numericOperators) {
+			return fmt.Errorf("wrong operator: %s", f.Operator)
+		}
+		for _, value := range f.Values {
+			if _, err := strconv.ParseUint(value, 10, 32); err != nil {
+				return fmt.Errorf("wrong duration: %s", value)
+			}
+		}
+	case FilterIssue:
+		if !isOneOf(f.Operator, issueOperators) {
+			return fmt.Errorf("wrong operator: %s", f.Operator)
+		}
+	default:
+		return errors.New("unknown filter type")
+	}
+	return nil
+}
+
+func (e *EventFilter) validate() error {
+	if !isOneOf(e.Type, []string{EventClick, EventInput, EventLocation, EventRequest, EventError,
+		EventCustom, EventGraphQL, EventStateAction}) {
+		return errors.New("unknown event type")
+	}
+	if !isOneOf(e.Operator, stringOperators) {
+		return fmt.Errorf("wrong operator: %s", e.Operator)
+	}
+	return checkValues(e.Values)
+}
+
+// EscapeLike escapes wildcards of the LIKE pattern
+func EscapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// MetadataColumn is the key of the metadata value in the found session,
+// it should be replaced with the name of the project metadata field
+func MetadataColumn(no uint) string {
+	return "metadata_" + strconv.Itoa(int(no))
+}
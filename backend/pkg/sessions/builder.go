@@ -3,6 +3,7 @@ package sessions
 import (
 	"log"
 	"openreplay/backend/pkg/handlers"
+	"openreplay/backend/pkg/queue/types"
 	"time"
 
 	. "openreplay/backend/pkg/messages"
@@ -10,6 +11,7 @@ import (
 
 type builder struct {
 	sessionID      uint64
+	partition      types.TopicPartition
 	readyMsgs      []Message
 	timestamp      uint64
 	lastMessageID  uint64
@@ -18,9 +20,10 @@ type builder struct {
 	ended          bool
 }
 
-func NewBuilder(sessionID uint64, handlers ...handlers.MessageProcessor) *builder {
+func NewBuilder(sessionID uint64, partition types.TopicPartition, handlers ...handlers.MessageProcessor) *builder {
 	return &builder{
 		sessionID:  sessionID,
+		partition:  partition,
 		processors: handlers,
 	}
 }
@@ -2,6 +2,7 @@ package sessions
 
 import (
 	"openreplay/backend/pkg/handlers"
+	"openreplay/backend/pkg/queue/types"
 	"time"
 
 	. "openreplay/backend/pkg/messages"
@@ -21,17 +22,17 @@ func NewBuilderMap(handlersFabric func() []handlers.MessageProcessor) *builderMa
 	}
 }
 
-func (m *builderMap) GetBuilder(sessionID uint64) *builder {
+func (m *builderMap) GetBuilder(sessionID uint64, partition types.TopicPartition) *builder {
 	b := m.sessions[sessionID]
 	if b == nil {
-		b = NewBuilder(sessionID, m.handlersFabric()...) // Should create new instances
+		b = NewBuilder(sessionID, partition, m.handlersFabric()...) // Should create new instances
 		m.sessions[sessionID] = b
 	}
 	return b
 }
 
-func (m *builderMap) HandleMessage(sessionID uint64, msg Message, messageID uint64) {
-	b := m.GetBuilder(sessionID)
+func (m *builderMap) HandleMessage(sessionID uint64, partition types.TopicPartition, msg Message, messageID uint64) {
+	b := m.GetBuilder(sessionID, partition)
 	b.handleMessage(msg, messageID)
 }
 
@@ -0,0 +1,129 @@
+package sessions
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"openreplay/backend/pkg/handlers"
+	. "openreplay/backend/pkg/messages"
+	"openreplay/backend/pkg/queue/types"
+)
+
+// builderState is a serializable copy of builder, processors keep the same order as in handlersFabric
+type builderState struct {
+	Timestamp     uint64            `json:"timestamp"`
+	LastMessageID uint64            `json:"lastMessageID"`
+	Ended         bool              `json:"ended"`
+	ReadyMessages [][]byte          `json:"readyMessages"`
+	Processors    []json.RawMessage `json:"processors"`
+}
+
+func (b *builder) marshalState() (*builderState, error) {
+	state := &builderState{
+		Timestamp:     b.timestamp,
+		LastMessageID: b.lastMessageID,
+		Ended:         b.ended,
+	}
+	for _, msg := range b.readyMsgs {
+		state.ReadyMessages = append(state.ReadyMessages, Encode(msg))
+	}
+	for _, p := range b.processors {
+		sp, ok := p.(handlers.StatefulProcessor)
+		if !ok {
+			return nil, fmt.Errorf("processor %T doesn't support state transfer", p)
+		}
+		data, err := sp.MarshalState()
+		if err != nil {
+			return nil, err
+		}
+		state.Processors = append(state.Processors, data)
+	}
+	return state, nil
+}
+
+func (b *builder) unmarshalState(state *builderState) error {
+	if len(state.Processors) != len(b.processors) {
+		return fmt.Errorf("wrong number of processors: %d, expected: %d", len(state.Processors), len(b.processors))
+	}
+	for i, p := range b.processors {
+		sp, ok := p.(handlers.StatefulProcessor)
+		if !ok {
+			return fmt.Errorf("processor %T doesn't support state transfer", p)
+		}
+		if err := sp.UnmarshalState(state.Processors[i]); err != nil {
+			return err
+		}
+	}
+	for _, data := range state.ReadyMessages {
+		if len(data) == 0 {
+			continue
+		}
+		msg, err := ReadMessage(uint64(data[0]), bytes.NewReader(data[1:]))
+		if err != nil {
+			return err
+		}
+		b.readyMsgs = append(b.readyMsgs, msg)
+	}
+	b.timestamp = state.Timestamp
+	b.lastMessageID = state.LastMessageID
+	b.ended = state.Ended
+	b.lastSystemTime = time.Now()
+	return nil
+}
+
+// ExportPartitions serializes sessions of revoked partitions and removes them from the map
+func (m *builderMap) ExportPartitions(partitions []types.TopicPartition) (map[types.TopicPartition][]byte, error) {
+	revoked := make(map[types.TopicPartition]map[uint64]*builderState, len(partitions)) // map[partition]map[sessionID]state
+	for _, partition := range partitions {
+		revoked[partition] = make(map[uint64]*builderState)
+	}
+	for sessionID, b := range m.sessions {
+		states, ok := revoked[b.partition]
+		if !ok {
+			continue
+		}
+		// Session is removed anyway, because the new partition owner will continue to process it
+		delete(m.sessions, sessionID)
+		state, err := b.marshalState()
+		if err != nil {
+			log.Printf("can't export session state, sessID: %d, err: %s", sessionID, err)
+			continue
+		}
+		states[sessionID] = state
+	}
+	result := make(map[types.TopicPartition][]byte, len(revoked))
+	for partition, states := range revoked {
+		if len(states) == 0 {
+			continue
+		}
+		data, err := json.Marshal(states)
+		if err != nil {
+			return nil, err
+		}
+		result[partition] = data
+	}
+	return result, nil
+}
+
+// ImportPartition restores sessions exported by another heuristics instance
+func (m *builderMap) ImportPartition(partition types.TopicPartition, data []byte) error {
+	states := make(map[uint64]*builderState)
+	if err := json.Unmarshal(data, &states); err != nil {
+		return err
+	}
+	for sessionID, state := range states {
+		if _, ok := m.sessions[sessionID]; ok {
+			continue
+		}
+		b := NewBuilder(sessionID, partition, m.handlersFabric()...)
+		if err := b.unmarshalState(state); err != nil {
+			log.Printf("can't import session state, sessID: %d, err: %s", sessionID, err)
+			continue
+		}
+		m.sessions[sessionID] = b
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const cacheTmpSuffix = ".tmp"
+
+// DiskCache keeps recently uploaded objects on the local disk, so the replay opened right after the
+// session end is served without the round trip to the object storage. The directory is shared by the
+// service which puts objects (storage) and the one which reads them (http), so files are the only state:
+// modification time is the time of the last access and the least recently used files are evicted.
+type DiskCache struct {
+	dir     string
+	maxSize int64
+	mu      sync.Mutex // evictions of the same process don't run concurrently
+}
+
+// CachedObject is the body of the object with the headers it was uploaded with
+type CachedObject struct {
+	ContentType string
+	Gzipped     bool
+	ModTime     time.Time
+	Body        *io.SectionReader
+	file        *os.File
+}
+
+func (o *CachedObject) Close() error {
+	return o.file.Close()
+}
+
+// NewDiskCache creates the cache directory, maxSize limits the total size of files, 0 means that
+// the process only reads the cache and doesn't evict files
+func NewDiskCache(dir string, maxSize int64) (*DiskCache, error) {
+	switch {
+	case dir == "":
+		return nil, fmt.Errorf("cache dir is empty")
+	case maxSize < 0:
+		return nil, fmt.Errorf("cache size can't be negative")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("can't create cache dir: %s", err)
+	}
+	return &DiskCache{dir: dir, maxSize: maxSize}, nil
+}
+
+func (c *DiskCache) path(key string) string {
+	return filepath.Join(c.dir, url.PathEscape(key))
+}
+
+// Put writes the object the same way it's uploaded, the first line keeps its headers
+func (c *DiskCache) Put(key string, contentType string, gzipped bool, data []byte) error {
+	tmp, err := os.CreateTemp(c.dir, url.PathEscape(key)+"-*"+cacheTmpSuffix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after the rename
+	w := bufio.NewWriter(tmp)
+	fmt.Fprintf(w, "%s %t\n", contentType, gzipped)
+	w.Write(data)
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// Rename is atomic, readers never see partially written files
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		return err
+	}
+	return c.evict()
+}
+
+// Open returns nil if the object isn't cached, the caller closes the object
+func (c *DiskCache) Open(key string) (*CachedObject, error) {
+	path := c.path(key)
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	header, err := bufio.NewReader(io.NewSectionReader(file, 0, info.Size())).ReadString('\n')
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("wrong cache file header: %s", err)
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 2 {
+		file.Close()
+		return nil, fmt.Errorf("wrong cache file header: %q", header)
+	}
+	gzipped, _ := strconv.ParseBool(fields[1])
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return &CachedObject{
+		ContentType: fields[0],
+		Gzipped:     gzipped,
+		ModTime:     info.ModTime(),
+		Body:        io.NewSectionReader(file, int64(len(header)), info.Size()-int64(len(header))),
+		file:        file,
+	}, nil
+}
+
+// Exists doesn't update the access time, objects are accessed by Open
+func (c *DiskCache) Exists(key string) bool {
+	_, err := os.Stat(c.path(key))
+	return err == nil
+}
+
+// evict removes the least recently used files until the cache fits its size
+func (c *DiskCache) evict() error {
+	if c.maxSize == 0 {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	files := make([]os.FileInfo, 0, len(entries))
+	var size int64
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // removed by another process
+		}
+		// Temporary files are left only by crashed writers
+		if strings.HasSuffix(entry.Name(), cacheTmpSuffix) {
+			if time.Since(info.ModTime()) > time.Hour {
+				os.Remove(filepath.Join(c.dir, entry.Name()))
+			}
+			continue
+		}
+		files = append(files, info)
+		size += info.Size()
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+	for _, info := range files {
+		if size <= c.maxSize {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.dir, info.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		size -= info.Size()
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ClassUploader is implemented by storages which support storage classes (only s3 for now)
+type ClassUploader interface {
+	UploadWithClass(reader io.Reader, key string, contentType string, gzipped bool, class string) error
+}
+
+var storageClassAliases = map[string]string{
+	"STANDARD":            "STANDARD",
+	"IA":                  "STANDARD_IA",
+	"STANDARD_IA":         "STANDARD_IA",
+	"ONEZONE_IA":          "ONEZONE_IA",
+	"INTELLIGENT_TIERING": "INTELLIGENT_TIERING",
+}
+
+func parseStorageClass(class string) (string, error) {
+	if class == "" {
+		return "", nil
+	}
+	if name, ok := storageClassAliases[strings.ToUpper(class)]; ok {
+		return name, nil
+	}
+	return "", fmt.Errorf("unknown storage class: %s", class)
+}
+
+// StorageClasses keeps storage classes chosen by projects, empty class means the bucket default
+type StorageClasses struct {
+	defaultClass string
+	projects     map[uint32]string
+}
+
+// NewStorageClasses parses projectID:class pairs from the config
+func NewStorageClasses(defaultClass string, projects []string) (*StorageClasses, error) {
+	c := &StorageClasses{projects: make(map[uint32]string, len(projects))}
+	var err error
+	if c.defaultClass, err = parseStorageClass(defaultClass); err != nil {
+		return nil, err
+	}
+	for _, pair := range projects {
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("wrong project storage class: %s", pair)
+		}
+		projectID, err := strconv.ParseUint(strings.TrimSpace(parts[0]), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("wrong project id: %s", parts[0])
+		}
+		if c.projects[uint32(projectID)], err = parseStorageClass(strings.TrimSpace(parts[1])); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// HasProjects is false if all objects use the default class, so projects of sessions don't have to be found
+func (c *StorageClasses) HasProjects() bool {
+	return len(c.projects) > 0
+}
+
+func (c *StorageClasses) Get(projectID uint32) string {
+	if class, ok := c.projects[projectID]; ok && class != "" {
+		return class
+	}
+	return c.defaultClass
+}
+
+// UploadWithClass falls back to the bucket default class if the storage doesn't support classes
+func UploadWithClass(s ObjectStorage, reader io.Reader, key string, contentType string, gzipped bool, class string) error {
+	if uploader, ok := s.(ClassUploader); ok && class != "" {
+		return uploader.UploadWithClass(reader, key, contentType, gzipped, class)
+	}
+	return s.Upload(reader, key, contentType, gzipped)
+}
+
+type classStorage struct {
+	ObjectStorage
+	class string
+}
+
+// WithStorageClass returns the storage which uploads all objects with the class, it's used for objects
+// shared by projects like cached assets
+func WithStorageClass(s ObjectStorage, class string) (ObjectStorage, error) {
+	class, err := parseStorageClass(class)
+	if err != nil || class == "" {
+		return s, err
+	}
+	return &classStorage{ObjectStorage: s, class: class}, nil
+}
+
+func (s *classStorage) Upload(reader io.Reader, key string, contentType string, gzipped bool) error {
+	return UploadWithClass(s.ObjectStorage, reader, key, contentType, gzipped, s.class)
+}
@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"time"
+
+	gcs "cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+
+	"openreplay/backend/pkg/env"
+)
+
+// Default chunk size of the google client, every upload with non-zero chunk size is resumable
+const defaultGCSChunkSize = 16 << 20
+
+type GCS struct {
+	client     *gcs.Client
+	bucket     *gcs.BucketHandle
+	bucketName string
+	chunkSize  int
+	retention  string
+	signer     *gcsSigner
+}
+
+// gcsSigner holds service account credentials, ADC can't be used for URL signing without extra IAM calls
+type gcsSigner struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+}
+
+func loadGCSSigner(path string) (*gcsSigner, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	signer := &gcsSigner{}
+	if err := json.Unmarshal(data, signer); err != nil {
+		return nil, err
+	}
+	if signer.ClientEmail == "" || signer.PrivateKey == "" {
+		return nil, errors.New("client_email or private_key is empty")
+	}
+	return signer, nil
+}
+
+// NewGCS uses Application Default Credentials, so it works with both workload identity
+// and GOOGLE_APPLICATION_CREDENTIALS service account files
+func NewGCS(bucket string) (*GCS, error) {
+	client, err := gcs.NewClient(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	chunkSize := defaultGCSChunkSize
+	if env.StringOptional("GCS_UPLOAD_CHUNK_SIZE") != "" {
+		chunkSize = env.Int("GCS_UPLOAD_CHUNK_SIZE")
+	}
+	var signer *gcsSigner
+	if path := env.StringOptional("GCS_SIGNER_KEY_FILE"); path != "" {
+		if signer, err = loadGCSSigner(path); err != nil {
+			return nil, fmt.Errorf("can't load gcs signer key: %s", err)
+		}
+	}
+	return &GCS{
+		client:     client, // Docs: "Clients should be reused instead of created as needed. The methods of Client are safe for concurrent use by multiple goroutines."
+		bucket:     client.Bucket(bucket),
+		bucketName: bucket,
+		chunkSize:  chunkSize,
+		retention:  loadRetention(),
+		signer:     signer,
+	}, nil
+}
+
+func (g *GCS) Upload(reader io.Reader, key string, contentType string, gzipped bool) error {
+	w := g.bucket.Object(key).NewWriter(context.Background())
+	w.ChunkSize = g.chunkSize
+	w.ContentType = contentType
+	w.CacheControl = "max-age=2628000, immutable, private"
+	if gzipped {
+		w.ContentEncoding = "gzip"
+	}
+	w.Metadata = map[string]string{retentionKey: g.retention}
+	if _, err := io.Copy(w, reader); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (g *GCS) Get(key string) (io.ReadCloser, error) {
+	return g.bucket.Object(key).NewReader(context.Background())
+}
+
+func (g *GCS) Exists(key string) bool {
+	_, err := g.bucket.Object(key).Attrs(context.Background())
+	return err == nil
+}
+
+func (g *GCS) GetCreationTime(key string) *time.Time {
+	attrs, err := g.bucket.Object(key).Attrs(context.Background())
+	if err != nil {
+		return nil
+	}
+	return &attrs.Updated
+}
+
+func (g *GCS) GetFrequentlyUsedKeys(projectID uint64) ([]string, error) {
+	prefix := strconv.FormatUint(projectID, 10) + "/"
+	it := g.bucket.Objects(context.Background(), &gcs.Query{Prefix: prefix})
+	var list []*gcs.ObjectAttrs
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, attrs)
+	}
+
+	max := len(list)
+	if max > MAX_RETURNING_COUNT {
+		max = MAX_RETURNING_COUNT
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Updated.After(list[j].Updated)
+		})
+	}
+
+	var keyList []string
+	s := len(prefix)
+	for _, obj := range list[:max] {
+		keyList = append(keyList, obj.Name[s:])
+	}
+	return keyList, nil
+}
+
+func (g *GCS) GetPresignedURL(key string, ttl time.Duration) (string, error) {
+	if g.signer == nil {
+		return "", errors.New("GCS_SIGNER_KEY_FILE is not set")
+	}
+	return gcs.SignedURL(g.bucketName, key, &gcs.SignedURLOptions{
+		GoogleAccessID: g.signer.ClientEmail,
+		PrivateKey:     []byte(g.signer.PrivateKey),
+		Method:         "GET",
+		Expires:        time.Now().Add(ttl),
+		Scheme:         gcs.SigningSchemeV4,
+	})
+}
+
+func (g *GCS) Walk(prefix string, fn func(obj *ObjectInfo) error) error {
+	it := g.bucket.Objects(context.Background(), &gcs.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(&ObjectInfo{Key: attrs.Name, Size: attrs.Size, LastModified: attrs.Updated}); err != nil {
+			return err
+		}
+	}
+}
+
+func (g *GCS) Delete(keys []string) error {
+	for _, key := range keys {
+		err := g.bucket.Object(key).Delete(context.Background())
+		if err != nil && err != gcs.ErrObjectNotExist {
+			return fmt.Errorf("can't delete %s: %s", key, err)
+		}
+	}
+	return nil
+}
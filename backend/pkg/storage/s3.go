@@ -1,13 +1,14 @@
 package storage
 
 import (
+	"fmt"
 	"io"
 	"net/url"
-	"os"
 	"sort"
 	"strconv"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	_s3 "github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 
@@ -32,13 +33,17 @@ func NewS3(region string, bucket string) *S3 {
 }
 
 func (s3 *S3) Upload(reader io.Reader, key string, contentType string, gzipped bool) error {
+	return s3.UploadWithClass(reader, key, contentType, gzipped, "")
+}
+
+func (s3 *S3) UploadWithClass(reader io.Reader, key string, contentType string, gzipped bool, class string) error {
 	cacheControl := "max-age=2628000, immutable, private"
 	var contentEncoding *string
 	if gzipped {
 		gzipStr := "gzip"
 		contentEncoding = &gzipStr
 	}
-	_, err := s3.uploader.Upload(&s3manager.UploadInput{
+	input := &s3manager.UploadInput{
 		Body:            reader,
 		Bucket:          s3.bucket,
 		Key:             &key,
@@ -46,7 +51,11 @@ func (s3 *S3) Upload(reader io.Reader, key string, contentType string, gzipped b
 		CacheControl:    &cacheControl,
 		ContentEncoding: contentEncoding,
 		Tagging:         &s3.fileTag,
-	})
+	}
+	if class != "" {
+		input.StorageClass = &class
+	}
+	_, err := s3.uploader.Upload(input)
 	return err
 }
 
@@ -114,14 +123,68 @@ func (s3 *S3) GetFrequentlyUsedKeys(projectID uint64) ([]string, error) {
 }
 
 func loadFileTag() string {
-	// Load file tag from env
-	key := "retention"
-	value := os.Getenv("RETENTION")
-	if value == "" {
-		value = "default"
-	}
 	// Create URL encoded tag set for file
 	params := url.Values{}
-	params.Add(key, value)
+	params.Add(retentionKey, loadRetention())
 	return params.Encode()
 }
+
+func (s3 *S3) GetPresignedURL(key string, ttl time.Duration) (string, error) {
+	req, _ := s3.svc.GetObjectRequest(&_s3.GetObjectInput{
+		Bucket: s3.bucket,
+		Key:    &key,
+	})
+	return req.Presign(ttl)
+}
+
+func (s3 *S3) Walk(prefix string, fn func(obj *ObjectInfo) error) error {
+	var fnErr error
+	err := s3.svc.ListObjectsV2Pages(&_s3.ListObjectsV2Input{
+		Bucket: s3.bucket,
+		Prefix: &prefix,
+	}, func(page *_s3.ListObjectsV2Output, _ bool) bool {
+		for _, obj := range page.Contents {
+			fnErr = fn(&ObjectInfo{
+				Key:          *obj.Key,
+				Size:         *obj.Size,
+				LastModified: *obj.LastModified,
+			})
+			if fnErr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// Maximum number of keys in one DeleteObjects request
+const deleteBatchSize = 1000
+
+func (s3 *S3) Delete(keys []string) error {
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > deleteBatchSize {
+			n = deleteBatchSize
+		}
+		objects := make([]*_s3.ObjectIdentifier, 0, n)
+		for i := range keys[:n] {
+			objects = append(objects, &_s3.ObjectIdentifier{Key: &keys[i]})
+		}
+		out, err := s3.svc.DeleteObjects(&_s3.DeleteObjectsInput{
+			Bucket: s3.bucket,
+			Delete: &_s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return err
+		}
+		if len(out.Errors) > 0 {
+			return fmt.Errorf("can't delete %s: %s", aws.StringValue(out.Errors[0].Key), aws.StringValue(out.Errors[0].Message))
+		}
+		keys = keys[n:]
+	}
+	return nil
+}
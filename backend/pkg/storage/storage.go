@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// ObjectStorage is a common interface for all supported cloud storages
+type ObjectStorage interface {
+	Upload(reader io.Reader, key string, contentType string, gzipped bool) error
+	Get(key string) (io.ReadCloser, error)
+	Exists(key string) bool
+	GetCreationTime(key string) *time.Time
+	GetFrequentlyUsedKeys(projectID uint64) ([]string, error)
+	GetPresignedURL(key string, ttl time.Duration) (string, error)
+	Walk(prefix string, fn func(obj *ObjectInfo) error) error // stops on the first error returned by fn
+	Delete(keys []string) error
+}
+
+// ObjectInfo is returned by the bucket listing
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// NewObjectStorage returns storage implementation for the given provider (s3 by default),
+// the region is required only by s3, gcs buckets are global
+func NewObjectStorage(provider, region, bucket string) (ObjectStorage, error) {
+	switch provider {
+	case "", "s3":
+		if region == "" {
+			return nil, fmt.Errorf("s3 region is empty")
+		}
+		return NewS3(region, bucket), nil
+	case "gcs":
+		return NewGCS(bucket)
+	}
+	return nil, fmt.Errorf("unknown storage provider: %s", provider)
+}
+
+// LiveChunksPrefix is the common prefix of chunks uploaded while the session is active
+func LiveChunksPrefix(sessionKey string) string {
+	return sessionKey + "/live/"
+}
+
+// LiveChunkKey is zero padded, so listing returns chunks in upload order
+func LiveChunkKey(sessionKey string, n int) string {
+	return fmt.Sprintf("%s%06d", LiveChunksPrefix(sessionKey), n)
+}
+
+// CanvasDir is the directory of canvas snapshots of all sessions, it's next to the session files
+const CanvasDir = "canvas"
+
+// CanvasFileName is the same on the disk and in the object storage, so the player finds snapshots by CanvasNode messages
+func CanvasFileName(nodeID, timestamp uint64, format string) string {
+	return fmt.Sprintf("%d_%d.%s", nodeID, timestamp, format)
+}
+
+// WaitUntilExists checks the object until it's found, the backoff is doubled after each attempt.
+// Eventually consistent stores can return 404 for a while right after the upload.
+func WaitUntilExists(s ObjectStorage, key string, attempts int, backoff time.Duration) bool {
+	for i := 0; i < attempts; i++ {
+		if s.Exists(key) {
+			return true
+		}
+		if i < attempts-1 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	return false
+}
+
+const retentionKey = "retention"
+
+func loadRetention() string {
+	// Load file tag from env
+	value := os.Getenv("RETENTION")
+	if value == "" {
+		value = "default"
+	}
+	return value
+}
@@ -0,0 +1,122 @@
+package tenants
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"openreplay/backend/pkg/env"
+)
+
+const defaultRefresh = time.Minute
+
+// Source returns tenants of routed projects, e.g. tenant_routes of postgres
+type Source interface {
+	GetTenantRoutes() (map[uint32]string, error)
+}
+
+// Router routes projects to clickhouse databases of their tenants, so noisy tenants are isolated from the others
+// and their data is kept by their own retention. CLICKHOUSE_TENANT_DATABASES sets databases of tenants
+// (tenant=database, comma separated), they have the same tables as the shared database. Projects without routes
+// and projects of tenants without databases stay in the shared database. Routes are reloaded every
+// TENANT_ROUTES_REFRESH, so new routes apply to new rows without restarts.
+type Router struct {
+	source    Source
+	databases map[string]string
+	mu        sync.RWMutex
+	routes    map[uint32]string
+}
+
+// NewRouter returns nil without databases of tenants, nil router keeps all projects in the shared database.
+// Routes have to be loaded at the start, otherwise rows of isolated tenants would go to the shared database.
+func NewRouter(source Source) (*Router, error) {
+	databases, err := parseDatabases(env.StringListOptional("CLICKHOUSE_TENANT_DATABASES"))
+	if err != nil || len(databases) == 0 {
+		return nil, err
+	}
+	if source == nil {
+		return nil, fmt.Errorf("routes source is empty")
+	}
+	r := &Router{
+		source:    source,
+		databases: databases,
+	}
+	if err := r.load(); err != nil {
+		return nil, fmt.Errorf("can't load tenant routes: %s", err)
+	}
+	refresh := env.DurationOptional("TENANT_ROUTES_REFRESH")
+	if refresh <= 0 {
+		refresh = defaultRefresh
+	}
+	go func() {
+		for range time.Tick(refresh) {
+			if err := r.load(); err != nil {
+				log.Printf("can't reload tenant routes, previous routes are used: %s", err)
+			}
+		}
+	}()
+	return r, nil
+}
+
+func parseDatabases(list []string) (map[string]string, error) {
+	databases := make(map[string]string, len(list))
+	for _, item := range list {
+		tenant, database, ok := strings.Cut(item, "=")
+		tenant, database = strings.TrimSpace(tenant), strings.TrimSpace(database)
+		if !ok || tenant == "" || !isIdentifier(database) {
+			return nil, fmt.Errorf("wrong tenant database: %s", item)
+		}
+		databases[tenant] = database
+	}
+	return databases, nil
+}
+
+// isIdentifier allows only names which don't need quotes in queries
+func isIdentifier(name string) bool {
+	for i, c := range name {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return name != ""
+}
+
+func (r *Router) load() error {
+	routes, err := r.source.GetTenantRoutes()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.routes = routes
+	r.mu.Unlock()
+	return nil
+}
+
+// Database returns the database of the project tenant, empty for projects of the shared database
+func (r *Router) Database(projectID uint32) string {
+	if r == nil {
+		return ""
+	}
+	r.mu.RLock()
+	tenant := r.routes[projectID]
+	r.mu.RUnlock()
+	return r.databases[tenant]
+}
+
+// Databases returns databases of all tenants, tenants may share one database
+func (r *Router) Databases() []string {
+	if r == nil {
+		return nil
+	}
+	unique := make(map[string]bool, len(r.databases))
+	databases := make([]string, 0, len(r.databases))
+	for _, database := range r.databases {
+		if !unique[database] {
+			unique[database] = true
+			databases = append(databases, database)
+		}
+	}
+	return databases
+}
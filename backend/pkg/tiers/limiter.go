@@ -0,0 +1,66 @@
+package tiers
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Limiter shares a fixed number of slots (e.g. concurrent uploads) between tiers. Free slots are taken
+// right away, released ones are handed to the waiting tier which got the smallest share for its weight,
+// so a burst of a low tier doesn't delay higher ones and still isn't starved by them.
+type Limiter struct {
+	mu      sync.Mutex
+	free    int
+	waiting [tierCount][]chan struct{}
+	served  [tierCount]int // slots handed to waiting tiers since the last moment without waiters
+}
+
+func NewLimiter(slots int) (*Limiter, error) {
+	if slots <= 0 {
+		return nil, fmt.Errorf("wrong number of slots: %d", slots)
+	}
+	return &Limiter{free: slots}, nil
+}
+
+func (l *Limiter) Acquire(tier Tier) {
+	l.mu.Lock()
+	if l.free > 0 {
+		l.free--
+		l.mu.Unlock()
+		return
+	}
+	ready := make(chan struct{})
+	l.waiting[tier] = append(l.waiting[tier], ready)
+	l.mu.Unlock()
+	<-ready
+}
+
+func (l *Limiter) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	next := l.next()
+	if next < 0 {
+		l.free++
+		l.served = [tierCount]int{}
+		return
+	}
+	ready := l.waiting[next][0]
+	l.waiting[next][0] = nil
+	l.waiting[next] = l.waiting[next][1:]
+	l.served[next]++
+	close(ready)
+}
+
+// next returns the waiting tier with the smallest served/weight ratio, -1 if nobody waits
+func (l *Limiter) next() int {
+	next := -1
+	for tier := range l.waiting {
+		if len(l.waiting[tier]) == 0 {
+			continue
+		}
+		if next < 0 || l.served[tier]*tierWeights[next] < l.served[next]*tierWeights[tier] {
+			next = tier
+		}
+	}
+	return next
+}
@@ -0,0 +1,143 @@
+package tiers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Tier is the processing priority class of a project, e.g. paid projects are high and free ones are low
+type Tier int
+
+const (
+	High Tier = iota
+	Normal
+	Low
+	tierCount
+)
+
+var tierNames = [tierCount]string{High: "high", Normal: "normal", Low: "low"}
+
+// Weights are shares of partitions and upload slots, normal is the weight of the current behaviour
+var tierWeights = [tierCount]int{High: 4, Normal: 2, Low: 1}
+
+func Parse(name string) (Tier, error) {
+	for t, n := range tierNames {
+		if strings.EqualFold(strings.TrimSpace(name), n) {
+			return Tier(t), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown project tier: %s", name)
+}
+
+func (t Tier) String() string {
+	return tierNames[t]
+}
+
+func (t Tier) Weight() int {
+	return tierWeights[t]
+}
+
+// IntervalFactor slows down periodic jobs (e.g. requests of integrations) of tiers below normal,
+// jobs of higher tiers can't run more often than the service interval
+func (t Tier) IntervalFactor() int {
+	if factor := tierWeights[Normal] / tierWeights[t]; factor > 1 {
+		return factor
+	}
+	return 1
+}
+
+// Tiers keeps tiers of projects, projects which aren't listed (and unknown ones) get the default tier
+type Tiers struct {
+	defaultTier Tier
+	projects    map[uint32]Tier
+}
+
+// New parses projectID:tier pairs from the config
+func New(defaultTier string, projects []string) (*Tiers, error) {
+	t := &Tiers{projects: make(map[uint32]Tier, len(projects))}
+	var err error
+	if t.defaultTier, err = Parse(defaultTier); err != nil {
+		return nil, err
+	}
+	for _, pair := range projects {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("wrong project tier: %s", pair)
+		}
+		projectID, err := strconv.ParseUint(strings.TrimSpace(parts[0]), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("wrong project id: %s", parts[0])
+		}
+		if t.projects[uint32(projectID)], err = Parse(parts[1]); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// HasProjects is false if all projects have the default tier, so projects of sessions don't have to be found
+func (t *Tiers) HasProjects() bool {
+	return len(t.projects) > 0
+}
+
+func (t *Tiers) Get(projectID uint32) Tier {
+	if tier, ok := t.projects[projectID]; ok {
+		return tier
+	}
+	return t.defaultTier
+}
+
+// Used returns tiers which can be returned by Get, from the highest one
+func (t *Tiers) Used() []Tier {
+	var used [tierCount]bool
+	used[t.defaultTier] = true
+	for _, tier := range t.projects {
+		used[tier] = true
+	}
+	var tiers []Tier
+	for tier, ok := range used {
+		if ok {
+			tiers = append(tiers, Tier(tier))
+		}
+	}
+	return tiers
+}
+
+// Partitions splits partitions of a topic between used tiers by their weights, every tier gets at least one.
+// Ranges are returned as [first, first+count) by tier.
+func (t *Tiers) Partitions(partitions uint64) (map[Tier][2]uint64, error) {
+	used := t.Used()
+	if partitions < uint64(len(used)) {
+		return nil, fmt.Errorf("%d partitions can't be shared by %d project tiers", partitions, len(used))
+	}
+	total := 0
+	for _, tier := range used {
+		total += tier.Weight()
+	}
+	counts := make([]uint64, len(used))
+	left := partitions
+	for i, tier := range used {
+		counts[i] = partitions * uint64(tier.Weight()) / uint64(total)
+		if counts[i] == 0 {
+			counts[i] = 1
+		}
+		// Lower tiers must get at least one partition each
+		if rest := uint64(len(used) - i - 1); counts[i] > left-rest {
+			counts[i] = left - rest
+		}
+		left -= counts[i]
+	}
+	// Rounding leftovers go to the highest tier
+	counts[0] += left
+	ranges := make(map[Tier][2]uint64, len(used))
+	first := uint64(0)
+	for i, tier := range used {
+		ranges[tier] = [2]uint64{first, counts[i]}
+		first += counts[i]
+	}
+	return ranges, nil
+}
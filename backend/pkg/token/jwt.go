@@ -0,0 +1,99 @@
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// UserClaims is a payload of the JWT issued by chalice for dashboard users
+type UserClaims struct {
+	UserID   uint64 `json:"userId"`
+	TenantID uint64 `json:"tenantId"`
+	ExpTime  int64  `json:"exp"`
+	Issuer   string `json:"iss"`
+}
+
+// JWTValidator checks HMAC signed tokens of dashboard users
+type JWTValidator struct {
+	secret []byte
+	issuer string
+}
+
+func NewJWTValidator(secret, issuer string) *JWTValidator {
+	return &JWTValidator{
+		secret: []byte(secret),
+		issuer: issuer,
+	}
+}
+
+func (v *JWTValidator) ParseFromHTTPRequest(r *http.Request) (*UserClaims, error) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, BEARER_SCHEMA) {
+		return nil, errors.New("Missing token")
+	}
+	return v.Parse(header[len(BEARER_SCHEMA):])
+}
+
+func (v *JWTValidator) Parse(token string) (*UserClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("wrong token format")
+	}
+	headerData, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("can't decode token header: %s", err)
+	}
+	header := struct {
+		Alg string `json:"alg"`
+	}{}
+	if err := json.Unmarshal(headerData, &header); err != nil {
+		return nil, fmt.Errorf("can't parse token header: %s", err)
+	}
+	var hashFunc func() hash.Hash
+	switch header.Alg {
+	case "HS256":
+		hashFunc = sha256.New
+	case "HS384":
+		hashFunc = sha512.New384
+	case "HS512":
+		hashFunc = sha512.New
+	default:
+		return nil, fmt.Errorf("unsupported token algorithm: %s", header.Alg)
+	}
+	sign, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("can't decode token signature: %s", err)
+	}
+	mac := hmac.New(hashFunc, v.secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sign, mac.Sum(nil)) {
+		return nil, errors.New("wrong token signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("can't decode token payload: %s", err)
+	}
+	claims := &UserClaims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, fmt.Errorf("can't parse token payload: %s", err)
+	}
+	if claims.ExpTime < time.Now().Unix() {
+		return nil, EXPIRED
+	}
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return nil, fmt.Errorf("wrong token issuer: %s", claims.Issuer)
+	}
+	if claims.UserID == 0 {
+		return nil, errors.New("user id is empty")
+	}
+	return claims, nil
+}
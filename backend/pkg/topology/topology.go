@@ -0,0 +1,165 @@
+package topology
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"openreplay/backend/pkg/queue/types"
+)
+
+// Path of the endpoint on the metrics server
+const Path = "/topology"
+
+// Topology is the part of the pipeline the service believes in, it's assembled from the service config
+// and the runtime state, so services of an install consuming topics nobody produces are easy to find
+type Topology struct {
+	Service    string            `json:"service"`
+	Hostname   string            `json:"hostname"`
+	StartedAt  time.Time         `json:"startedAt"`
+	Group      string            `json:"group,omitempty"`
+	Consumes   []string          `json:"consumes,omitempty"`
+	Produces   []string          `json:"produces,omitempty"`
+	Stores     map[string]string `json:"stores,omitempty"` // store kind -> address without credentials
+	Partitions []uint64          `json:"partitions"`       // partitions assigned to the consumer right now
+	mu         sync.Mutex
+	assigned   map[types.TopicPartition]bool
+	next       types.PartitionListener
+}
+
+// New creates the topology of the service and registers its endpoint in the default http mux
+func New(service, group string) *Topology {
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Printf("can't get hostname: %s", err)
+	}
+	t := &Topology{
+		Service:   service,
+		Hostname:  hostname,
+		StartedAt: time.Now(),
+		Group:     group,
+		Stores:    make(map[string]string),
+		assigned:  make(map[types.TopicPartition]bool),
+	}
+	http.Handle(Path, t)
+	return t
+}
+
+// Consume adds topics read by the service, empty names of optional topics are skipped
+func (t *Topology) Consume(topics ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Consumes = appendTopics(t.Consumes, topics)
+}
+
+func (t *Topology) Produce(topics ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Produces = appendTopics(t.Produces, topics)
+}
+
+// Store adds a downstream store, credentials are removed from the address
+func (t *Topology) Store(kind, addr string) {
+	if addr == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Stores[kind] = redact(addr)
+}
+
+// Listener tracks partition assignments of the consumer and passes them to the next listener if it's set
+func (t *Topology) Listener(next types.PartitionListener) types.PartitionListener {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.next = next
+	return t
+}
+
+func (t *Topology) Assigned(partitions []types.TopicPartition) {
+	t.mu.Lock()
+	for _, partition := range partitions {
+		t.assigned[partition] = true
+	}
+	next := t.next
+	t.mu.Unlock()
+	if next != nil {
+		next.Assigned(partitions)
+	}
+}
+
+func (t *Topology) Revoked(partitions []types.TopicPartition) {
+	t.mu.Lock()
+	for _, partition := range partitions {
+		delete(t.assigned, partition)
+	}
+	next := t.next
+	t.mu.Unlock()
+	if next != nil {
+		next.Revoked(partitions)
+	}
+}
+
+func (t *Topology) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	t.mu.Lock()
+	// Consumed topics have the same partitions, each number is listed once
+	numbers := make(map[uint64]bool, len(t.assigned))
+	for tp := range t.assigned {
+		numbers[tp.Partition] = true
+	}
+	t.Partitions = make([]uint64, 0, len(numbers))
+	for partition := range numbers {
+		t.Partitions = append(t.Partitions, partition)
+	}
+	sort.Slice(t.Partitions, func(i, j int) bool { return t.Partitions[i] < t.Partitions[j] })
+	body, err := json.Marshal(t)
+	t.mu.Unlock()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+func appendTopics(list, topics []string) []string {
+	for _, topic := range topics {
+		if topic == "" {
+			continue
+		}
+		found := false
+		for _, t := range list {
+			found = found || t == topic
+		}
+		if !found {
+			list = append(list, topic)
+		}
+	}
+	return list
+}
+
+// redact removes user info and parameters from urls and passwords from key=value connection strings
+func redact(addr string) string {
+	if u, err := url.Parse(addr); err == nil && u.Host != "" {
+		u.User = nil
+		u.RawQuery = ""
+		return u.String()
+	}
+	fields := strings.Fields(addr)
+	for i, field := range fields {
+		if strings.HasPrefix(strings.ToLower(field), "password=") {
+			fields[i] = "password=***"
+		}
+	}
+	return strings.Join(fields, " ")
+}
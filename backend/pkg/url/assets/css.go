@@ -8,7 +8,7 @@ import (
 
 // TODO: ignore  data: , escaped quotes , spaces between brackets?
 var cssURLs = regexp.MustCompile(`url\(("[^"]*"|'[^']*'|[^)]*)\)`)
-var cssImports = regexp.MustCompile(`@import "(.*?)"`)
+var cssImports = regexp.MustCompile(`@import\s*("[^"]*"|'[^']*')`)
 
 func cssUrlsIndex(css string) [][]int {
 	var idxs [][]int
@@ -38,28 +38,35 @@ func unquote(str string) (string, string) {
 	return str, ""
 }
 
-func ExtractURLsFromCSS(css string) []string {
-	indexes := cssUrlsIndex(css)
-	urls := make([]string, len(indexes))
+func extractURLs(text string, indexes [][]int) []string {
+	urls := make([]string, 0, len(indexes))
 	for _, idx := range indexes {
-
 		f := idx[0]
 		t := idx[1]
-		rawurl, _ := unquote(css[f:t])
+		rawurl, _ := unquote(text[f:t])
 		urls = append(urls, rawurl)
 	}
 	return urls
 }
 
-func rewriteLinks(css string, rewrite func(rawurl string) string) string {
-	for _, idx := range cssUrlsIndex(css) {
+func ExtractURLsFromCSS(css string) []string {
+	return extractURLs(css, cssUrlsIndex(css))
+}
+
+// replaceLinks expects indexes sorted from the end of the text, so replacement doesn't shift the rest
+func replaceLinks(text string, indexes [][]int, rewrite func(rawurl string) string) string {
+	for _, idx := range indexes {
 		f := idx[0]
 		t := idx[1]
-		rawurl, q := unquote(css[f:t])
+		rawurl, q := unquote(text[f:t])
 		// why exactly quote back?
-		css = css[:f] + q + rewrite(rawurl) + q + css[t:]
+		text = text[:f] + q + rewrite(rawurl) + q + text[t:]
 	}
-	return css
+	return text
+}
+
+func rewriteLinks(css string, rewrite func(rawurl string) string) string {
+	return replaceLinks(css, cssUrlsIndex(css), rewrite)
 }
 
 func ResolveCSS(baseURL string, css string) string {
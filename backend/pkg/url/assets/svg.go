@@ -0,0 +1,33 @@
+package assets
+
+import (
+	"regexp"
+	"sort"
+)
+
+// External references of SVG documents: <image href>, <use xlink:href>, <feImage href> etc. and url() in styles
+var svgHrefs = regexp.MustCompile(`(?:xlink:)?href\s*=\s*("[^"]*"|'[^']*')`)
+
+func svgUrlsIndex(svg string) [][]int {
+	var idxs [][]int
+	for _, match := range svgHrefs.FindAllStringSubmatchIndex(svg, -1) {
+		idxs = append(idxs, match[2:])
+	}
+	for _, match := range cssURLs.FindAllStringSubmatchIndex(svg, -1) {
+		idxs = append(idxs, match[2:])
+	}
+	sort.Slice(idxs, func(i, j int) bool {
+		return idxs[i][0] > idxs[j][0]
+	})
+	return idxs
+}
+
+func ExtractURLsFromSVG(svg string) []string {
+	return extractURLs(svg, svgUrlsIndex(svg))
+}
+
+func (r *Rewriter) RewriteSVG(sessionID uint64, baseurl string, svg string) string {
+	return replaceLinks(svg, svgUrlsIndex(svg), func(rawurl string) string {
+		return r.RewriteURL(sessionID, baseurl, rawurl)
+	})
+}
@@ -52,7 +52,8 @@ func isCachable(rawurl string) bool {
 		ext == ".woff2" ||
 		ext == ".ttf" ||
 		ext == ".otf" ||
-		ext == ".eot"
+		ext == ".eot" ||
+		ext == ".svg"
 }
 
 func GetFullCachableURL(baseURL string, relativeURL string) (string, bool) {
@@ -66,7 +67,14 @@ func GetFullCachableURL(baseURL string, relativeURL string) (string, bool) {
 	return fullURL, true
 }
 
+// splitFragment separates "#fragment" that isn't sent to the server, but selects an SVG symbol or a font
+func splitFragment(rawurl string) (string, string) {
+	withoutFragment, fragment, _ := strings.Cut(rawurl, "#")
+	return withoutFragment, fragment
+}
+
 func getCachePath(rawurl string) string {
+	rawurl, _ = splitFragment(rawurl)
 	return "/" + strings.ReplaceAll(url.QueryEscape(rawurl), "%", "!") // s3 keys are ok with "!"
 }
 
@@ -78,20 +86,55 @@ func GetCachePathForJS(rawurl string) string {
 	return getCachePath(rawurl)
 }
 
+// GetCachePathForSourceMap returns path of the source map stored next to the cached JS file
+func GetCachePathForSourceMap(jsURL string) string {
+	return getCachePath(jsURL) + ".map"
+}
+
+// GetSourceMapStatusPath returns path of the record about source map availability of the JS file
+func GetSourceMapStatusPath(jsURL string) string {
+	return getCachePath(jsURL) + ".map.json"
+}
+
 func GetCachePathForAssets(sessionID uint64, rawurl string) string {
 	return getCachePathWithKey(sessionID, rawurl)
 }
 
+// GetBundlePath returns path of the bundle with small assets of the session, parts are numbered from 0
+func GetBundlePath(sessionID uint64, part int) string {
+	return "/bundles/" + strconv.FormatUint(sessionID, 10) + "/" + strconv.Itoa(part)
+}
+
+// IsSessionAssetPath checks that the path was built by GetCachePathForAssets or GetBundlePath for the session
+func IsSessionAssetPath(sessionID uint64, path string) bool {
+	bundlePrefix := "/bundles/" + strconv.FormatUint(sessionID, 10) + "/"
+	if strings.HasPrefix(path, bundlePrefix) {
+		part, err := strconv.Atoi(strings.TrimPrefix(path, bundlePrefix))
+		return err == nil && part >= 0 && GetBundlePath(sessionID, part) == path
+	}
+	escaped := strings.TrimSuffix(path, "."+getSessionKey(sessionID))
+	if escaped == path || !strings.HasPrefix(escaped, "/") {
+		return false
+	}
+	rawurl, err := url.QueryUnescape(strings.ReplaceAll(escaped[1:], "!", "%"))
+	if err != nil || !isCachable(rawurl) {
+		return false
+	}
+	return GetCachePathForAssets(sessionID, rawurl) == path
+}
+
 func (r *Rewriter) RewriteURL(sessionID uint64, baseURL string, relativeURL string) string {
 	fullURL, cachable := GetFullCachableURL(baseURL, relativeURL)
 	if !cachable {
 		return fullURL
 	}
 
+	_, fragment := splitFragment(fullURL)
 	u := url.URL{
-		Path:   r.assetsURL.Path + getCachePathWithKey(sessionID, fullURL),
-		Host:   r.assetsURL.Host,
-		Scheme: r.assetsURL.Scheme,
+		Path:     r.assetsURL.Path + getCachePathWithKey(sessionID, fullURL),
+		Host:     r.assetsURL.Host,
+		Scheme:   r.assetsURL.Scheme,
+		Fragment: fragment,
 	}
 	return u.String()
 }
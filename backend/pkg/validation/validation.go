@@ -0,0 +1,100 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"openreplay/backend/internal/config/common"
+	"openreplay/backend/pkg/messages"
+	"openreplay/backend/pkg/monitoring"
+)
+
+// New returns the validator of custom events only if validation is disabled, payloads of custom events
+// are always limited. Rejected messages are counted by type and reason.
+func New(cfg *common.Config, metrics *monitoring.Metrics) (*messages.Validator, error) {
+	switch {
+	case cfg == nil:
+		return nil, fmt.Errorf("config is empty")
+	case metrics == nil:
+		return nil, fmt.Errorf("metrics module is empty")
+	case cfg.MaxStringSize < 0 || cfg.MaxCoordinate < 0 || cfg.MaxFutureDrift < 0 || cfg.CustomEventMaxPayloadSize < 0:
+		return nil, fmt.Errorf("validation limits can't be negative")
+	}
+	rejected, err := metrics.RegisterCounter("messages_rejected")
+	if err != nil {
+		return nil, fmt.Errorf("can't register messages_rejected metric: %s", err)
+	}
+	onReject := func(msgType int, reason string) {
+		rejected.Add(context.Background(), 1, attribute.Int("type", msgType), attribute.String("reason", reason))
+	}
+	if !cfg.ValidateMessages {
+		return messages.NewCustomEventValidator(cfg.CustomEventMaxPayloadSize, onReject), nil
+	}
+	return messages.NewValidator(cfg.MaxStringSize, int64(cfg.MaxCoordinate), cfg.MaxFutureDrift, onReject).
+		LimitCustomEvents(cfg.CustomEventMaxPayloadSize), nil
+}
+
+// CountDeprecated counts messages of deprecated types by type and action (replaced or skipped)
+func CountDeprecated(metrics *monitoring.Metrics) error {
+	if metrics == nil {
+		return fmt.Errorf("metrics module is empty")
+	}
+	deprecated, err := metrics.RegisterCounter("messages_deprecated")
+	if err != nil {
+		return fmt.Errorf("can't register messages_deprecated metric: %s", err)
+	}
+	messages.OnDeprecated(func(msgType int, action string) {
+		deprecated.Add(context.Background(), 1, attribute.Int("type", msgType), attribute.String("action", action))
+	})
+	return nil
+}
+
+// NewLogDeduplicator returns nil if deduplication is disabled, collapsed messages are counted by type
+func NewLogDeduplicator(cfg *common.Config, metrics *monitoring.Metrics) (*messages.LogDeduplicator, error) {
+	switch {
+	case cfg == nil:
+		return nil, fmt.Errorf("config is empty")
+	case metrics == nil:
+		return nil, fmt.Errorf("metrics module is empty")
+	case cfg.LogDedupWindow < 0 || cfg.LogDedupMaxKeys < 0 || cfg.LogDedupIdleTimeout < 0:
+		return nil, fmt.Errorf("log deduplication limits can't be negative")
+	case cfg.LogDedupWindow == 0:
+		return nil, nil
+	}
+	collapsed, err := metrics.RegisterCounter("messages_collapsed")
+	if err != nil {
+		return nil, fmt.Errorf("can't register messages_collapsed metric: %s", err)
+	}
+	return messages.NewLogDeduplicator(cfg.LogDedupWindow, cfg.LogDedupMaxKeys, cfg.LogDedupIdleTimeout, func(msgType int) {
+		collapsed.Add(context.Background(), 1, attribute.Int("type", msgType))
+	}), nil
+}
+
+// NewClockNormalizer returns nil if the correction is disabled, corrected sessions and their skews are measured
+func NewClockNormalizer(cfg *common.Config, metrics *monitoring.Metrics) (*messages.ClockNormalizer, error) {
+	switch {
+	case cfg == nil:
+		return nil, fmt.Errorf("config is empty")
+	case metrics == nil:
+		return nil, fmt.Errorf("metrics module is empty")
+	case cfg.ClockSkewThreshold < 0 || cfg.ClockSkewIdleTimeout < 0:
+		return nil, fmt.Errorf("clock skew limits can't be negative")
+	case cfg.ClockSkewThreshold == 0:
+		return nil, nil
+	}
+	shifts, err := metrics.RegisterCounter("session_clock_shifts")
+	if err != nil {
+		return nil, fmt.Errorf("can't register session_clock_shifts metric: %s", err)
+	}
+	skew, err := metrics.RegisterHistogram("session_clock_skew")
+	if err != nil {
+		return nil, fmt.Errorf("can't register session_clock_skew metric: %s", err)
+	}
+	return messages.NewClockNormalizer(cfg.ClockSkewThreshold, cfg.ClockSkewIdleTimeout, func(offset int64) {
+		shifts.Add(context.Background(), 1)
+		skew.Record(context.Background(), math.Abs(float64(offset))/1000) // seconds
+	}), nil
+}
@@ -0,0 +1,23 @@
+import json
+
+from chalicelib.utils import pg_client
+from chalicelib.utils.TimeUTC import TimeUTC
+
+
+def save(job, certificate):
+    # The certificate is kept with the job and written to the audit trail of the project's tenant
+    with pg_client.PostgresClient() as cur:
+        cur.execute(
+            cur.mogrify("""UPDATE public.jobs
+                           SET certificate = %(certificate)s::jsonb
+                           WHERE job_id = %(job_id)s;
+                           INSERT INTO traces(tenant_id, created_at, auth, action, method, path_format, endpoint,
+                                              payload, status)
+                           SELECT tenant_id, %(created_at)s, 'system', 'deletion_certificate', 'JOB',
+                                  '/jobs/{jobId}', %(endpoint)s, %(certificate)s::jsonb, NULL
+                           FROM public.projects
+                           WHERE project_id = %(project_id)s;""",
+                        {"job_id": job["jobId"], "project_id": job["projectId"],
+                         "certificate": json.dumps(certificate), "created_at": TimeUTC.now(),
+                         "endpoint": f"/jobs/{job['jobId']}"})
+        )
@@ -1,5 +1,7 @@
 import math
 
+from decouple import config
+
 import schemas
 from chalicelib.utils import pg_client, exp_ch_helper
 from chalicelib.utils import args_transformer
@@ -1042,8 +1044,26 @@ def get_sessions_location(project_id, startTimestamp=TimeUTC.now(delta_days=-1),
     return {"count": sum(i["count"] for i in rows), "chart": helper.list_to_camel_case(rows)}
 
 
+SPEED_INDEX_PERCENTILES = [50, 90, 95, 99]
+
+
+def __use_aggregates(args):
+    # Tables aggregated by the db service keep only the project, time, url host and country,
+    # charts filtered by other session fields scan events
+    return config("EXP_AGGREGATES", cast=bool, default=True) \
+        and len(__get_generic_constraint(data=args, table_name="")) == 0 \
+        and len(__get_meta_constraint(args)) == 0
+
+
+def __percentiles(values):
+    return {f"p{k}": values[i] if values is not None and values[i] is not None and not isnan(values[i]) else 0
+            for i, k in enumerate(SPEED_INDEX_PERCENTILES)}
+
+
 def get_speed_index_location(project_id, startTimestamp=TimeUTC.now(delta_days=-1),
                              endTimestamp=TimeUTC.now(), **args):
+    if __use_aggregates(args):
+        return __get_speed_index_location_aggregated(project_id, startTimestamp, endTimestamp)
     ch_sub_query = __get_basic_constraints(table_name="pages", data=args)
     ch_sub_query.append("pages.event_type='LOCATION'")
     ch_sub_query.append("isNotNull(pages.speed_index)")
@@ -1063,11 +1083,35 @@ def get_speed_index_location(project_id, startTimestamp=TimeUTC.now(delta_days=-
                   "endTimestamp": endTimestamp, **__get_constraint_values(args)}
         # print(ch.format(query=ch_query, params=params))
         rows = ch.execute(query=ch_query, params=params)
-        ch_query = f"""SELECT COALESCE(avgOrNull(pages.speed_index),0) AS avg
+        ch_query = f"""SELECT COALESCE(avgOrNull(pages.speed_index),0) AS avg,
+                              quantilesTDigest({",".join([str(i / 100) for i in SPEED_INDEX_PERCENTILES])})(pages.speed_index) AS values
                     FROM {exp_ch_helper.get_main_events_table(startTimestamp)} AS pages
                     WHERE {" AND ".join(ch_sub_query)};"""
-        avg = ch.execute(query=ch_query, params=params)[0]["avg"] if len(rows) > 0 else 0
-    return {"value": avg, "chart": helper.list_to_camel_case(rows), "unit": schemas.TemplatePredefinedUnits.millisecond}
+        total = ch.execute(query=ch_query, params=params)[0] if len(rows) > 0 else {"avg": 0, "values": None}
+    return {"value": total["avg"], "percentiles": __percentiles(total["values"]),
+            "chart": helper.list_to_camel_case(rows), "unit": schemas.TemplatePredefinedUnits.millisecond}
+
+
+def __get_speed_index_location_aggregated(project_id, startTimestamp, endTimestamp):
+    ch_sub_query = __get_basic_constraints(table_name="pages")
+    with ch_client.ClickHouseClient() as ch:
+        ch_query = f"""SELECT pages.user_country,
+                              COALESCE(sum(pages.speed_index_sum) / nullIf(sum(pages.speed_index_count), 0), 0) AS value
+                        FROM experimental.page_speed_minutely AS pages
+                        WHERE {" AND ".join(ch_sub_query)}
+                        GROUP BY pages.user_country
+                        ORDER BY value, pages.user_country;"""
+        params = {"project_id": project_id,
+                  "startTimestamp": startTimestamp,
+                  "endTimestamp": endTimestamp}
+        rows = ch.execute(query=ch_query, params=params)
+        ch_query = f"""SELECT COALESCE(sum(pages.speed_index_sum) / nullIf(sum(pages.speed_index_count), 0), 0) AS avg,
+                              quantilesTDigestMerge({",".join([str(i / 100) for i in SPEED_INDEX_PERCENTILES])})(pages.speed_index_quantiles) AS values
+                    FROM experimental.page_speed_minutely AS pages
+                    WHERE {" AND ".join(ch_sub_query)};"""
+        total = ch.execute(query=ch_query, params=params)[0] if len(rows) > 0 else {"avg": 0, "values": None}
+    return {"value": total["avg"], "percentiles": __percentiles(total["values"]),
+            "chart": helper.list_to_camel_case(rows), "unit": schemas.TemplatePredefinedUnits.millisecond}
 
 
 def get_pages_response_time(project_id, startTimestamp=TimeUTC.now(delta_days=-1),
@@ -1528,21 +1572,30 @@ def __merge_rows_with_neutral(rows, neutral):
     return rows
 
 
+def __get_request_errors_source(startTimestamp, args, round_start=False):
+    # Returns the table, the count expression and constraints of requests failed with %(status_code)s xx
+    if __use_aggregates(args):
+        ch_sub_query = __get_basic_constraints(table_name="requests", round_start=round_start)
+        ch_sub_query.append("requests.status_class = %(status_code)s")
+        return "experimental.request_errors_minutely", "sum(requests.errors_count)", ch_sub_query
+    ch_sub_query = __get_basic_constraints(table_name="requests", round_start=round_start, data=args)
+    ch_sub_query.append("requests.event_type='REQUEST'")
+    ch_sub_query.append("intDiv(requests.status, 100) == %(status_code)s")
+    ch_sub_query += __get_meta_constraint(args)
+    return exp_ch_helper.get_main_events_table(startTimestamp), "COUNT(1)", ch_sub_query
+
+
 def get_domains_errors(project_id, startTimestamp=TimeUTC.now(delta_days=-1),
                        endTimestamp=TimeUTC.now(), density=6, **args):
     step_size = __get_step_size(startTimestamp, endTimestamp, density)
-    ch_sub_query = __get_basic_constraints(table_name="requests", round_start=True, data=args)
-    ch_sub_query.append("requests.event_type='REQUEST'")
-    ch_sub_query.append("intDiv(requests.status, 100) == %(status_code)s")
-    meta_condition = __get_meta_constraint(args)
-    ch_sub_query += meta_condition
+    table, count, ch_sub_query = __get_request_errors_source(startTimestamp, args, round_start=True)
 
     with ch_client.ClickHouseClient() as ch:
         ch_query = f"""SELECT timestamp,
                                groupArray([domain, toString(count)]) AS keys
                         FROM (SELECT toUnixTimestamp(toStartOfInterval(requests.datetime, INTERVAL %(step_size)s second)) * 1000 AS timestamp,
-                                        requests.url_host AS domain, COUNT(1) AS count
-                                FROM {exp_ch_helper.get_main_events_table(startTimestamp)} AS requests
+                                        requests.url_host AS domain, {count} AS count
+                                FROM {table} AS requests
                                 WHERE {" AND ".join(ch_sub_query)} 
                                 GROUP BY timestamp,requests.url_host
                                 ORDER BY timestamp, count DESC 
@@ -1576,18 +1629,14 @@ def get_domains_errors(project_id, startTimestamp=TimeUTC.now(delta_days=-1),
 def __get_domains_errors_4xx_and_5xx(status, project_id, startTimestamp=TimeUTC.now(delta_days=-1),
                                      endTimestamp=TimeUTC.now(), density=6, **args):
     step_size = __get_step_size(startTimestamp, endTimestamp, density)
-    ch_sub_query = __get_basic_constraints(table_name="requests", round_start=True, data=args)
-    ch_sub_query.append("requests.event_type='REQUEST'")
-    ch_sub_query.append("intDiv(requests.status, 100) == %(status_code)s")
-    meta_condition = __get_meta_constraint(args)
-    ch_sub_query += meta_condition
+    table, count, ch_sub_query = __get_request_errors_source(startTimestamp, args, round_start=True)
 
     with ch_client.ClickHouseClient() as ch:
         ch_query = f"""SELECT timestamp,
                                groupArray([domain, toString(count)]) AS keys
                         FROM (SELECT toUnixTimestamp(toStartOfInterval(requests.datetime, INTERVAL %(step_size)s second)) * 1000 AS timestamp,
-                                        requests.url_host AS domain, COUNT(1) AS count
-                                FROM {exp_ch_helper.get_main_events_table(startTimestamp)} AS requests
+                                        requests.url_host AS domain, {count} AS count
+                                FROM {table} AS requests
                                 WHERE {" AND ".join(ch_sub_query)} 
                                 GROUP BY timestamp,requests.url_host
                                 ORDER BY timestamp, count DESC 
@@ -1657,6 +1706,8 @@ def get_slowest_domains(project_id, startTimestamp=TimeUTC.now(delta_days=-1),
 
 def get_errors_per_domains(project_id, startTimestamp=TimeUTC.now(delta_days=-1),
                            endTimestamp=TimeUTC.now(), **args):
+    if __use_aggregates(args):
+        return __get_errors_per_domains_aggregated(project_id, startTimestamp, endTimestamp)
     ch_sub_query = __get_basic_constraints(table_name="requests", data=args)
     ch_sub_query.append("requests.event_type = 'REQUEST'")
     ch_sub_query.append("requests.success = 0")
@@ -1679,6 +1730,24 @@ def get_errors_per_domains(project_id, startTimestamp=TimeUTC.now(delta_days=-1)
     return helper.list_to_camel_case(rows)
 
 
+def __get_errors_per_domains_aggregated(project_id, startTimestamp, endTimestamp):
+    ch_sub_query = __get_basic_constraints(table_name="requests")
+    with ch_client.ClickHouseClient() as ch:
+        ch_query = f"""SELECT
+                            requests.url_host AS domain,
+                            sum(requests.errors_count) AS errors_count
+                        FROM experimental.request_errors_minutely AS requests
+                        WHERE {" AND ".join(ch_sub_query)}
+                        GROUP BY requests.url_host
+                        ORDER BY errors_count DESC
+                        LIMIT 5;"""
+        params = {"project_id": project_id,
+                  "startTimestamp": startTimestamp,
+                  "endTimestamp": endTimestamp}
+        rows = ch.execute(query=ch_query, params=params)
+    return helper.list_to_camel_case(rows)
+
+
 def get_sessions_per_browser(project_id, startTimestamp=TimeUTC.now(delta_days=-1), endTimestamp=TimeUTC.now(),
                              platform=None, **args):
     ch_sub_query = __get_basic_constraints(table_name="sessions", data=args)
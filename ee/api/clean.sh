@@ -6,6 +6,7 @@ rm -rf ./chalicelib/core/announcements.py
 rm -rf ./chalicelib/core/autocomplete.py
 rm -rf ./chalicelib/core/collaboration_slack.py
 rm -rf ./chalicelib/core/countries.py
+rm -rf ./chalicelib/core/deletion_certificates.py
 #exp rm -rf ./chalicelib/core/errors.py
 rm -rf ./chalicelib/core/errors_favorite.py
 #exp rm -rf ./chalicelib/core/events.py
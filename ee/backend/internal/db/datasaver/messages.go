@@ -33,7 +33,7 @@ func (mi *Saver) InsertMessage(sessionID uint64, msg messages.Message) error {
 			log.Printf("can't get session info for CH: %s", err)
 		} else {
 			if err := mi.ch.InsertCustom(session, m); err != nil {
-				log.Printf("can't insert graphQL event into clickhouse: %s", err)
+				log.Printf("can't insert custom event into clickhouse: %s", err)
 			}
 		}
 		return mi.pg.InsertWebCustomEvent(sessionID, m)
@@ -107,6 +107,12 @@ func (mi *Saver) InsertMessage(sessionID uint64, msg messages.Message) error {
 	case *messages.IOSCrash:
 		return mi.pg.InsertIOSCrash(sessionID, m)
 
+		// Mobile
+	case *messages.MobileTouchEvent:
+		return mi.pg.InsertMobileTouchEvent(sessionID, m)
+	case *messages.MobileLifecycleEvent:
+		return mi.pg.InsertMobileLifecycleEvent(sessionID, m)
+
 	}
 	return nil // "Not implemented"
 }
@@ -0,0 +1,72 @@
+package datasaver
+
+import (
+	"time"
+
+	"openreplay/backend/pkg/db/types"
+	"openreplay/backend/pkg/messages"
+)
+
+// Per-minute metrics of projects are aggregated between commits, the rollup service downsamples them
+
+type metricKey struct {
+	projectID uint32
+	name      string
+	minute    int64
+}
+
+type metricValue struct {
+	count uint64
+	sum   float64
+	min   float64
+	max   float64
+}
+
+type minuteMetrics map[metricKey]*metricValue
+
+func (m minuteMetrics) add(projectID uint32, name string, timestamp uint64, value float64) {
+	key := metricKey{projectID, name, int64(timestamp) / time.Minute.Milliseconds()}
+	v, ok := m[key]
+	if !ok {
+		m[key] = &metricValue{count: 1, sum: value, min: value, max: value}
+		return
+	}
+	v.count++
+	v.sum += value
+	if value < v.min {
+		v.min = value
+	}
+	if value > v.max {
+		v.max = value
+	}
+}
+
+func (si *Saver) collectMetrics(session *types.Session, msg messages.Message) {
+	switch m := msg.(type) {
+	case *messages.SessionEnd:
+		duration := uint64(0)
+		if session.Duration != nil {
+			duration = *session.Duration
+		}
+		si.metrics.add(session.ProjectID, "sessions", session.Timestamp, float64(duration))
+	case *messages.PageEvent:
+		si.metrics.add(session.ProjectID, "pages", m.Timestamp, float64(m.LoadEventEnd))
+	case *messages.ClickEvent:
+		si.metrics.add(session.ProjectID, "clicks", m.Timestamp, float64(m.HesitationTime))
+	case *messages.ErrorEvent:
+		si.metrics.add(session.ProjectID, "errors", m.Timestamp, 1)
+	case *messages.ResourceEvent:
+		si.metrics.add(session.ProjectID, "resources", m.Timestamp, float64(m.Duration))
+	}
+}
+
+func (si *Saver) insertMetrics() error {
+	for key, v := range si.metrics {
+		minute := time.UnixMilli(key.minute * time.Minute.Milliseconds())
+		if err := si.ch.InsertMetric(key.projectID, key.name, minute, v.count, v.sum, v.min, v.max); err != nil {
+			return err
+		}
+	}
+	si.metrics = make(minuteMetrics)
+	return nil
+}
@@ -3,15 +3,22 @@ package datasaver
 import (
 	"openreplay/backend/pkg/db/cache"
 	"openreplay/backend/pkg/db/clickhouse"
+	"openreplay/backend/pkg/queue"
 	"openreplay/backend/pkg/queue/types"
 )
 
 type Saver struct {
 	pg       *cache.PGCache
 	ch       clickhouse.Connector
+	metrics  minuteMetrics
 	producer types.Producer
 }
 
 func New(pg *cache.PGCache, producer types.Producer) *Saver {
 	return &Saver{pg: pg, producer: producer}
 }
+
+// Health reports outages of postgres and clickhouse, InitStats has to be called before
+func (s *Saver) Health() queue.HealthChecker {
+	return queue.JoinHealth(s.pg, s.ch)
+}
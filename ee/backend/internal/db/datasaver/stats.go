@@ -6,17 +6,26 @@ import (
 	"openreplay/backend/pkg/db/types"
 	"openreplay/backend/pkg/env"
 	"openreplay/backend/pkg/messages"
+	"openreplay/backend/pkg/monitoring"
+	"openreplay/backend/pkg/tenants"
 )
 
-func (si *Saver) InitStats() {
-	si.ch = clickhouse.NewConnector(env.String("CLICKHOUSE_STRING"))
+func (si *Saver) InitStats(metrics *monitoring.Metrics) {
+	si.ch = clickhouse.NewConnector(env.String("CLICKHOUSE_STRING"), metrics)
+	router, err := tenants.NewRouter(si.pg.Conn)
+	if err != nil {
+		log.Fatalf("can't init tenant routes: %s", err)
+	}
+	si.ch.SetRouter(router)
 	if err := si.ch.Prepare(); err != nil {
 		log.Fatalf("Clickhouse prepare error: %v\n", err)
 	}
 	si.pg.Conn.SetClickHouse(si.ch)
+	si.metrics = make(minuteMetrics)
 }
 
 func (si *Saver) InsertStats(session *types.Session, msg messages.Message) error {
+	si.collectMetrics(session, msg)
 	switch m := msg.(type) {
 	// Web
 	case *messages.SessionEnd:
@@ -39,5 +48,8 @@ func (si *Saver) InsertStats(session *types.Session, msg messages.Message) error
 }
 
 func (si *Saver) CommitStats(optimize bool) error {
+	if err := si.insertMetrics(); err != nil {
+		return err
+	}
 	return si.ch.Commit()
 }
@@ -0,0 +1,293 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+
+	"openreplay/backend/pkg/env"
+	"openreplay/backend/pkg/monitoring"
+)
+
+// Batches are sent on whichever limit comes first, so busy tables don't make huge inserts and
+// quiet ones don't wait for the commit. Commit still sends all batches before consumer offsets are committed.
+const (
+	defaultBatchRows    = 100000
+	defaultBatchBytes   = 32 << 20
+	defaultBatchLatency = 5 * time.Second
+	latencyCheckPeriod  = 100 * time.Millisecond
+)
+
+// Reasons of sending the batch, recorded with batch metrics
+const (
+	flushRows    = "rows"
+	flushBytes   = "bytes"
+	flushLatency = "latency"
+	flushCommit  = "commit"
+)
+
+// BatchLimits of one table, zero values disable the limit
+type BatchLimits struct {
+	Rows    int
+	Bytes   int
+	Latency time.Duration
+}
+
+// batchLimits returns CLICKHOUSE_BATCH_ROWS, CLICKHOUSE_BATCH_BYTES and CLICKHOUSE_BATCH_LATENCY of all tables
+// with CLICKHOUSE_TABLE_BATCHES overrides (table=rows/bytes/latency, comma separated)
+func batchLimits() (BatchLimits, map[string]BatchLimits, error) {
+	defaults := BatchLimits{Rows: defaultBatchRows, Bytes: defaultBatchBytes, Latency: defaultBatchLatency}
+	if rows := env.StringOptional("CLICKHOUSE_BATCH_ROWS"); rows != "" {
+		defaults.Rows = env.IntOptional("CLICKHOUSE_BATCH_ROWS")
+	}
+	if bytes := env.StringOptional("CLICKHOUSE_BATCH_BYTES"); bytes != "" {
+		defaults.Bytes = env.IntOptional("CLICKHOUSE_BATCH_BYTES")
+	}
+	if latency := env.StringOptional("CLICKHOUSE_BATCH_LATENCY"); latency != "" {
+		defaults.Latency = env.DurationOptional("CLICKHOUSE_BATCH_LATENCY")
+	}
+	tables := make(map[string]BatchLimits)
+	for _, item := range env.StringListOptional("CLICKHOUSE_TABLE_BATCHES") {
+		table, value, ok := strings.Cut(item, "=")
+		parts := strings.Split(value, "/")
+		if !ok || len(parts) != 3 {
+			return defaults, nil, fmt.Errorf("wrong table batch limits: %s", item)
+		}
+		var (
+			limits BatchLimits
+			err    error
+		)
+		if limits.Rows, err = strconv.Atoi(parts[0]); err != nil {
+			return defaults, nil, fmt.Errorf("wrong rows limit of %s: %s", table, err)
+		}
+		if limits.Bytes, err = strconv.Atoi(parts[1]); err != nil {
+			return defaults, nil, fmt.Errorf("wrong bytes limit of %s: %s", table, err)
+		}
+		if limits.Latency, err = time.ParseDuration(parts[2]); err != nil {
+			return defaults, nil, fmt.Errorf("wrong latency limit of %s: %s", table, err)
+		}
+		tables[table] = limits
+	}
+	return defaults, tables, nil
+}
+
+// bulkMetrics are shared by batches of all tables, nil metrics aren't recorded
+type bulkMetrics struct {
+	rows    syncfloat64.Histogram
+	bytes   syncfloat64.Histogram
+	latency syncfloat64.Histogram // ms from the first row of the batch to the end of the insert
+	insert  syncfloat64.Histogram // ms of the insert with retries
+	errors  syncfloat64.Counter   // failed inserts and appends by the class of the error
+}
+
+func newBulkMetrics(metrics *monitoring.Metrics) *bulkMetrics {
+	if metrics == nil {
+		return nil
+	}
+	m := &bulkMetrics{}
+	var err error
+	if m.rows, err = metrics.RegisterHistogram("clickhouse_batch_rows"); err != nil {
+		log.Printf("can't create clickhouse_batch_rows metric: %s", err)
+		return nil
+	}
+	if m.bytes, err = metrics.RegisterHistogram("clickhouse_batch_bytes"); err != nil {
+		log.Printf("can't create clickhouse_batch_bytes metric: %s", err)
+		return nil
+	}
+	if m.latency, err = metrics.RegisterHistogram("clickhouse_flush_latency"); err != nil {
+		log.Printf("can't create clickhouse_flush_latency metric: %s", err)
+		return nil
+	}
+	if m.insert, err = metrics.RegisterHistogram("clickhouse_insert_time"); err != nil {
+		log.Printf("can't create clickhouse_insert_time metric: %s", err)
+		return nil
+	}
+	if m.errors, err = metrics.RegisterCounter("clickhouse_insert_errors"); err != nil {
+		log.Printf("can't create clickhouse_insert_errors metric: %s", err)
+		return nil
+	}
+	return m
+}
+
+func (m *bulkMetrics) recordError(table, class string) {
+	if m == nil {
+		return
+	}
+	m.errors.Add(context.Background(), 1, attribute.String("table", table), attribute.String("class", class))
+}
+
+type Bulk interface {
+	Append(args ...interface{}) error
+	Send() error
+}
+
+type bulkImpl struct {
+	conn     driver.Conn
+	table    string
+	query    string
+	limits   BatchLimits
+	metrics  *bulkMetrics
+	health   *availability
+	attempts int
+	mu       sync.Mutex
+	values   [][]interface{}
+	size     int       // estimated bytes of values
+	first    time.Time // time of the first row of the batch
+	retryAt  time.Time // limits don't send the batch until then after a transient error
+	err      error     // error of the last batch sent by a limit, returned by the next Send
+}
+
+func newBulk(conn driver.Conn, table, query string, limits BatchLimits, metrics *bulkMetrics, health *availability) (Bulk, error) {
+	switch {
+	case conn == nil:
+		return nil, errors.New("clickhouse connection is empty")
+	case query == "":
+		return nil, errors.New("query is empty")
+	case health == nil:
+		return nil, errors.New("availability tracker is empty")
+	}
+	return &bulkImpl{
+		conn:     conn,
+		table:    table,
+		query:    query,
+		limits:   limits,
+		metrics:  metrics,
+		health:   health,
+		attempts: retryAttempts(),
+		values:   make([][]interface{}, 0),
+	}, nil
+}
+
+// Append sends the batch if it reaches the rows or bytes limit, the error of sending is returned by Send
+func (b *bulkImpl) Append(args ...interface{}) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.values) == 0 {
+		b.first = time.Now()
+	}
+	b.values = append(b.values, args)
+	b.size += estimateSize(args)
+	if time.Now().Before(b.retryAt) {
+		return nil
+	}
+	switch {
+	case b.limits.Rows > 0 && len(b.values) >= b.limits.Rows:
+		b.sendByLimit(flushRows)
+	case b.limits.Bytes > 0 && b.size >= b.limits.Bytes:
+		b.sendByLimit(flushBytes)
+	}
+	return nil
+}
+
+// expire sends the batch if its first row waits for longer than the latency limit
+func (b *bulkImpl) expire() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.limits.Latency > 0 && len(b.values) > 0 && time.Since(b.first) >= b.limits.Latency && time.Now().After(b.retryAt) {
+		b.sendByLimit(flushLatency)
+	}
+}
+
+func (b *bulkImpl) sendByLimit(reason string) {
+	if err := b.send(reason); err != nil {
+		log.Printf("can't send %s batch: %s", b.table, err)
+		if b.err == nil {
+			b.err = err
+		}
+	}
+}
+
+func (b *bulkImpl) Send() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	err := b.send(flushCommit)
+	if b.err != nil {
+		err, b.err = b.err, nil
+	}
+	return err
+}
+
+func (b *bulkImpl) send(reason string) error {
+	if len(b.values) == 0 {
+		return nil
+	}
+	values, size, first := b.values, b.size, b.first
+	b.values, b.size = make([][]interface{}, 0, len(values)), 0
+
+	start := time.Now()
+	err := retry(b.attempts, func() error { return b.insert(values) })
+	if b.metrics != nil {
+		b.metrics.insert.Record(context.Background(), float64(time.Since(start).Milliseconds()),
+			attribute.String("table", b.table), attribute.Bool("failed", err != nil))
+	}
+	if err != nil {
+		b.metrics.recordError(b.table, errorClass(err))
+		if !isRetryable(err) {
+			return err
+		}
+		b.health.fail()
+		if size > maxRetainedBytes {
+			return err
+		}
+		// Rows are sent again with the next batch
+		b.values, b.size, b.first = values, size, first
+		b.retryAt = time.Now().Add(retryMaxDelay)
+		return fmt.Errorf("%s, rows kept for retry: %d", err, len(values))
+	}
+	b.health.succeed()
+	if b.metrics != nil {
+		ctx := context.Background()
+		attrs := []attribute.KeyValue{attribute.String("table", b.table), attribute.String("reason", reason)}
+		b.metrics.rows.Record(ctx, float64(len(values)), attrs...)
+		b.metrics.bytes.Record(ctx, float64(size), attrs...)
+		b.metrics.latency.Record(ctx, float64(time.Since(first).Milliseconds()), attrs...)
+	}
+	return nil
+}
+
+func (b *bulkImpl) insert(values [][]interface{}) error {
+	batch, err := b.conn.PrepareBatch(context.Background(), b.query)
+	if err != nil {
+		return fmt.Errorf("can't create new batch: %w", err)
+	}
+	for _, set := range values {
+		if err := batch.Append(set...); err != nil {
+			log.Printf("can't append value set to batch, err: %s", err)
+			log.Printf("failed query: %s", b.query)
+		}
+	}
+	return batch.Send()
+}
+
+// estimateSize returns the approximate size of the row in the native format
+func estimateSize(args []interface{}) int {
+	size := 0
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			size += len(v)
+		case *string:
+			if v != nil {
+				size += len(*v)
+			}
+		case []string:
+			for _, s := range v {
+				size += len(s)
+			}
+		case []byte:
+			size += len(v)
+		default:
+			size += 8
+		}
+	}
+	return size
+}
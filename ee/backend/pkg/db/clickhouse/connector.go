@@ -11,62 +11,21 @@ import (
 	"openreplay/backend/pkg/db/types"
 	"openreplay/backend/pkg/hashid"
 	"openreplay/backend/pkg/messages"
+	"openreplay/backend/pkg/monitoring"
 	"openreplay/backend/pkg/url"
 	"strings"
+	"sync"
 	"time"
 
 	"openreplay/backend/pkg/license"
+	"openreplay/backend/pkg/tenants"
 )
 
-type Bulk interface {
-	Append(args ...interface{}) error
-	Send() error
-}
-
-type bulkImpl struct {
-	conn   driver.Conn
-	query  string
-	values [][]interface{}
-}
-
-func NewBulk(conn driver.Conn, query string) (Bulk, error) {
-	switch {
-	case conn == nil:
-		return nil, errors.New("clickhouse connection is empty")
-	case query == "":
-		return nil, errors.New("query is empty")
-	}
-	return &bulkImpl{
-		conn:   conn,
-		query:  query,
-		values: make([][]interface{}, 0),
-	}, nil
-}
-
-func (b *bulkImpl) Append(args ...interface{}) error {
-	b.values = append(b.values, args)
-	return nil
-}
-
-func (b *bulkImpl) Send() error {
-	batch, err := b.conn.PrepareBatch(context.Background(), b.query)
-	if err != nil {
-		return fmt.Errorf("can't create new batch: %s", err)
-	}
-	for _, set := range b.values {
-		if err := batch.Append(set...); err != nil {
-			log.Printf("can't append value set to batch, err: %s", err)
-			log.Printf("failed query: %s", b.query)
-		}
-	}
-	b.values = make([][]interface{}, 0)
-	return batch.Send()
-}
-
 var CONTEXT_MAP = map[uint64]string{0: "unknown", 1: "self", 2: "same-origin-ancestor", 3: "same-origin-descendant", 4: "same-origin", 5: "cross-origin-ancestor", 6: "cross-origin-descendant", 7: "cross-origin-unreachable", 8: "multiple-contexts"}
 var CONTAINER_TYPE_MAP = map[uint64]string{0: "window", 1: "iframe", 2: "embed", 3: "object"}
 
 type Connector interface {
+	SetRouter(router *tenants.Router)
 	Prepare() error
 	Commit() error
 	InsertWebSession(session *types.Session) error
@@ -80,14 +39,24 @@ type Connector interface {
 	InsertRequest(session *types.Session, msg *messages.FetchEvent, savePayload bool) error
 	InsertCustom(session *types.Session, msg *messages.CustomEvent) error
 	InsertGraphQL(session *types.Session, msg *messages.GraphQLEvent) error
+	InsertMetric(projectID uint32, name string, minute time.Time, count uint64, sum, min, max float64) error
+	Ping() error
+	Unavailable() time.Duration
 }
 
 type connectorImpl struct {
-	conn    driver.Conn
-	batches map[string]Bulk //driver.Batch
+	conn     driver.Conn
+	mu       sync.Mutex      // batches are replaced by Prepare while their latency is checked
+	batches  map[string]Bulk //driver.Batch
+	defaults BatchLimits
+	tables   map[string]BatchLimits
+	metrics  *bulkMetrics
+	health   *availability
+	router   *tenants.Router // nil without databases of tenants
 }
 
-func NewConnector(url string) Connector {
+// NewConnector sends batches by the limits of tables, metrics of batches aren't recorded if metrics is nil
+func NewConnector(url string, metrics *monitoring.Metrics) Connector {
 	license.CheckLicense()
 	url = strings.TrimPrefix(url, "tcp://")
 	url = strings.TrimSuffix(url, "/default")
@@ -108,22 +77,53 @@ func NewConnector(url string) Connector {
 		log.Fatal(err)
 	}
 
-	c := &connectorImpl{
-		conn:    conn,
-		batches: make(map[string]Bulk, 9),
+	defaults, tables, err := batchLimits()
+	if err != nil {
+		log.Fatalf("wrong clickhouse batch limits: %s", err)
 	}
+	c := &connectorImpl{
+		conn:     conn,
+		batches:  make(map[string]Bulk, 9),
+		defaults: defaults,
+		tables:   tables,
+		metrics:  newBulkMetrics(metrics),
+		health:   &availability{},
+	}
+	go c.checkLatency()
 	return c
 }
 
-func (c *connectorImpl) newBatch(name, query string) error {
-	batch, err := NewBulk(c.conn, query)
+// newBatch inserts rows of the table to the database of tenants, to the shared one if the database is empty.
+// Limits of the table apply to batches of all databases.
+func (c *connectorImpl) newBatch(database, table, query string) error {
+	limits, ok := c.tables[table]
+	if !ok {
+		limits = c.defaults
+	}
+	name := batchName(database, table)
+	if database != "" {
+		query = strings.Replace(query, "INSERT INTO experimental.", "INSERT INTO "+database+".", 1)
+	}
+	batch, err := newBulk(c.conn, name, query, limits, c.metrics, c.health)
 	if err != nil {
 		return fmt.Errorf("can't create new batch: %s", err)
 	}
+	c.mu.Lock()
 	c.batches[name] = batch
+	c.mu.Unlock()
 	return nil
 }
 
+func (c *connectorImpl) checkLatency() {
+	for range time.Tick(latencyCheckPeriod) {
+		c.mu.Lock()
+		for _, b := range c.batches {
+			b.(*bulkImpl).expire()
+		}
+		c.mu.Unlock()
+	}
+}
+
 var batches = map[string]string{
 	"sessions":      "INSERT INTO experimental.sessions (session_id, project_id, user_id, user_uuid, user_os, user_os_version, user_device, user_device_type, user_country, datetime, duration, pages_count, events_count, errors_count, issue_score, referrer, issue_types, tracker_version, user_browser, user_browser_version, metadata_1, metadata_2, metadata_3, metadata_4, metadata_5, metadata_6, metadata_7, metadata_8, metadata_9, metadata_10) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
 	"resources":     "INSERT INTO experimental.resources (session_id, project_id, message_id, datetime, url, type, duration, ttfb, header_size, encoded_body_size, decoded_body_size, success) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
@@ -136,17 +136,39 @@ var batches = map[string]string{
 	"requests":      "INSERT INTO experimental.events (session_id, project_id, message_id, datetime, url, request_body, response_body, status, method, duration, success, event_type) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
 	"custom":        "INSERT INTO experimental.events (session_id, project_id, message_id, datetime, name, payload, event_type) VALUES (?, ?, ?, ?, ?, ?, ?)",
 	"graphql":       "INSERT INTO experimental.events (session_id, project_id, message_id, datetime, name, request_body, response_body, event_type) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+	"metrics":       "INSERT INTO experimental.metrics_minutely (project_id, name, datetime, count, sum_value, min_value, max_value) VALUES (?, ?, ?, ?, ?, ?, ?)",
+	"page_speed":    "INSERT INTO experimental.page_speed (project_id, datetime, url, user_country, speed_index) VALUES (?, ?, ?, ?, ?)",
+}
+
+func batchName(database, table string) string {
+	if database == "" {
+		return table
+	}
+	return database + "." + table
+}
+
+// SetRouter routes rows of projects to databases of their tenants, it has to be set before Prepare
+func (c *connectorImpl) SetRouter(router *tenants.Router) {
+	c.router = router
 }
 
 func (c *connectorImpl) Prepare() error {
+	databases := append([]string{""}, c.router.Databases()...)
 	for table, query := range batches {
-		if err := c.newBatch(table, query); err != nil {
-			return fmt.Errorf("can't create %s batch: %s", table, err)
+		for _, database := range databases {
+			if err := c.newBatch(database, table, query); err != nil {
+				return fmt.Errorf("can't create %s batch: %s", batchName(database, table), err)
+			}
 		}
 	}
 	return nil
 }
 
+// batch returns the batch of the table in the database of the project tenant
+func (c *connectorImpl) batch(projectID uint32, table string) Bulk {
+	return c.batches[batchName(c.router.Database(projectID), table)]
+}
+
 func (c *connectorImpl) Commit() error {
 	for _, b := range c.batches {
 		if err := b.Send(); err != nil {
@@ -156,7 +178,24 @@ func (c *connectorImpl) Commit() error {
 	return nil
 }
 
+// Ping checks the server right away, inserts of tables are sent again by their own limits
+func (c *connectorImpl) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	if err := c.conn.Ping(ctx); err != nil {
+		return err
+	}
+	c.health.succeed()
+	return nil
+}
+
+// Unavailable returns how long inserts fail with transient errors, 0 if the last insert succeeded
+func (c *connectorImpl) Unavailable() time.Duration {
+	return c.health.unavailable()
+}
+
 func (c *connectorImpl) checkError(name string, err error) {
+	c.metrics.recordError(name, "append")
 	if err != clickhouse.ErrBatchAlreadySent {
 		log.Printf("can't create %s batch after failed append operation: %s", name, err)
 	}
@@ -166,7 +205,7 @@ func (c *connectorImpl) InsertWebSession(session *types.Session) error {
 	if session.Duration == nil {
 		return errors.New("trying to insert session with nil duration")
 	}
-	if err := c.batches["sessions"].Append(
+	if err := c.batch(session.ProjectID, "sessions").Append(
 		session.SessionID,
 		uint16(session.ProjectID),
 		session.UserID,
@@ -213,7 +252,7 @@ func (c *connectorImpl) InsertWebResourceEvent(session *types.Session, msg *mess
 	if resourceType == "" {
 		return fmt.Errorf("can't parse resource type, sess: %s, type: %s", session.SessionID, msg.Type)
 	}
-	if err := c.batches["resources"].Append(
+	if err := c.batch(session.ProjectID, "resources").Append(
 		session.SessionID,
 		uint16(session.ProjectID),
 		msg.MessageID,
@@ -234,7 +273,7 @@ func (c *connectorImpl) InsertWebResourceEvent(session *types.Session, msg *mess
 }
 
 func (c *connectorImpl) InsertWebPageEvent(session *types.Session, msg *messages.PageEvent) error {
-	if err := c.batches["pages"].Append(
+	if err := c.batch(session.ProjectID, "pages").Append(
 		session.SessionID,
 		uint16(session.ProjectID),
 		msg.MessageID,
@@ -257,6 +296,20 @@ func (c *connectorImpl) InsertWebPageEvent(session *types.Session, msg *messages
 		c.checkError("pages", err)
 		return fmt.Errorf("can't append to pages batch: %s", err)
 	}
+	if msg.SpeedIndex == 0 {
+		return nil
+	}
+	// Aggregated by the country of the session for dashboards, see page_speed_minutely
+	if err := c.batch(session.ProjectID, "page_speed").Append(
+		uint16(session.ProjectID),
+		datetime(msg.Timestamp),
+		url.DiscardURLQuery(msg.URL),
+		session.UserCountry,
+		uint16(msg.SpeedIndex),
+	); err != nil {
+		c.checkError("page_speed", err)
+		return fmt.Errorf("can't append to page_speed batch: %s", err)
+	}
 	return nil
 }
 
@@ -264,7 +317,7 @@ func (c *connectorImpl) InsertWebClickEvent(session *types.Session, msg *message
 	if msg.Label == "" {
 		return nil
 	}
-	if err := c.batches["clicks"].Append(
+	if err := c.batch(session.ProjectID, "clicks").Append(
 		session.SessionID,
 		uint16(session.ProjectID),
 		msg.MessageID,
@@ -283,7 +336,7 @@ func (c *connectorImpl) InsertWebInputEvent(session *types.Session, msg *message
 	if msg.Label == "" {
 		return nil
 	}
-	if err := c.batches["inputs"].Append(
+	if err := c.batch(session.ProjectID, "inputs").Append(
 		session.SessionID,
 		uint16(session.ProjectID),
 		msg.MessageID,
@@ -298,7 +351,7 @@ func (c *connectorImpl) InsertWebInputEvent(session *types.Session, msg *message
 }
 
 func (c *connectorImpl) InsertWebErrorEvent(session *types.Session, msg *messages.ErrorEvent) error {
-	if err := c.batches["errors"].Append(
+	if err := c.batch(session.ProjectID, "errors").Append(
 		session.SessionID,
 		uint16(session.ProjectID),
 		msg.MessageID,
@@ -317,7 +370,7 @@ func (c *connectorImpl) InsertWebErrorEvent(session *types.Session, msg *message
 
 func (c *connectorImpl) InsertWebPerformanceTrackAggr(session *types.Session, msg *messages.PerformanceTrackAggr) error {
 	var timestamp uint64 = (msg.TimestampStart + msg.TimestampEnd) / 2
-	if err := c.batches["performance"].Append(
+	if err := c.batch(session.ProjectID, "performance").Append(
 		session.SessionID,
 		uint16(session.ProjectID),
 		uint64(0), // TODO: find messageID for performance events
@@ -347,7 +400,7 @@ func (c *connectorImpl) InsertAutocomplete(session *types.Session, msgType, msgV
 	if len(msgValue) == 0 {
 		return nil
 	}
-	if err := c.batches["autocompletes"].Append(
+	if err := c.batch(session.ProjectID, "autocompletes").Append(
 		uint16(session.ProjectID),
 		msgType,
 		msgValue,
@@ -368,7 +421,7 @@ func (c *connectorImpl) InsertRequest(session *types.Session, msg *messages.Fetc
 		request = &msg.Request
 		response = &msg.Response
 	}
-	if err := c.batches["requests"].Append(
+	if err := c.batch(session.ProjectID, "requests").Append(
 		session.SessionID,
 		uint16(session.ProjectID),
 		msg.MessageID,
@@ -389,7 +442,7 @@ func (c *connectorImpl) InsertRequest(session *types.Session, msg *messages.Fetc
 }
 
 func (c *connectorImpl) InsertCustom(session *types.Session, msg *messages.CustomEvent) error {
-	if err := c.batches["custom"].Append(
+	if err := c.batch(session.ProjectID, "custom").Append(
 		session.SessionID,
 		uint16(session.ProjectID),
 		msg.MessageID,
@@ -405,7 +458,7 @@ func (c *connectorImpl) InsertCustom(session *types.Session, msg *messages.Custo
 }
 
 func (c *connectorImpl) InsertGraphQL(session *types.Session, msg *messages.GraphQLEvent) error {
-	if err := c.batches["graphql"].Append(
+	if err := c.batch(session.ProjectID, "graphql").Append(
 		session.SessionID,
 		uint16(session.ProjectID),
 		msg.MessageID,
@@ -421,6 +474,22 @@ func (c *connectorImpl) InsertGraphQL(session *types.Session, msg *messages.Grap
 	return nil
 }
 
+func (c *connectorImpl) InsertMetric(projectID uint32, name string, minute time.Time, count uint64, sum, min, max float64) error {
+	if err := c.batch(projectID, "metrics").Append(
+		uint16(projectID),
+		name,
+		minute,
+		count,
+		sum,
+		min,
+		max,
+	); err != nil {
+		c.checkError("metrics", err)
+		return fmt.Errorf("can't append to metrics batch: %s", err)
+	}
+	return nil
+}
+
 func nullableUint16(v uint16) *uint16 {
 	var p *uint16 = nil
 	if v != 0 {
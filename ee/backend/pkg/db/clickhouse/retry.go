@@ -0,0 +1,115 @@
+package clickhouse
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+
+	"openreplay/backend/pkg/env"
+)
+
+// Batches which failed with transient errors are sent again. Tables are ReplacingMergeTree ordered by
+// natural keys (session_id, message_id), so rows of a batch which was written before the error are merged
+// with their copies, and replicated tables drop the same block inserted twice.
+const (
+	defaultRetryAttempts = 3
+	retryBaseDelay       = 100 * time.Millisecond
+	retryMaxDelay        = 2 * time.Second
+	maxRetainedBytes     = 256 << 20 // rows kept for the next batch after all attempts failed
+	pingTimeout          = 5 * time.Second
+)
+
+// Server errors which don't depend on the inserted rows, other exceptions (types, parsing, schema) are permanent
+var retryableCodes = map[int32]bool{
+	159: true, // TIMEOUT_EXCEEDED
+	164: true, // READONLY
+	202: true, // TOO_MANY_SIMULTANEOUS_QUERIES
+	203: true, // NO_FREE_CONNECTION
+	209: true, // SOCKET_TIMEOUT
+	210: true, // NETWORK_ERROR
+	241: true, // MEMORY_LIMIT_EXCEEDED
+	242: true, // TABLE_IS_READ_ONLY
+	252: true, // TOO_MANY_PARTS
+	279: true, // ALL_CONNECTION_TRIES_FAILED
+	285: true, // TOO_FEW_LIVE_REPLICAS
+	319: true, // UNKNOWN_STATUS_OF_INSERT
+	999: true, // KEEPER_EXCEPTION
+}
+
+func isRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var exception *clickhouse.Exception
+	if errors.As(err, &exception) {
+		return retryableCodes[exception.Code]
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, clickhouse.ErrAcquireConnTimeout) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE)
+}
+
+// errorClass groups errors of inserts for metrics
+func errorClass(err error) string {
+	var exception *clickhouse.Exception
+	switch {
+	case errors.As(err, &exception) && retryableCodes[exception.Code]:
+		return "transient"
+	case errors.As(err, &exception):
+		return "permanent"
+	case isRetryable(err):
+		return "connection"
+	}
+	return "other"
+}
+
+// retryAttempts returns CLICKHOUSE_RETRY_ATTEMPTS, the number of sends of one batch
+func retryAttempts() int {
+	if attempts := env.IntOptional("CLICKHOUSE_RETRY_ATTEMPTS"); attempts > 0 {
+		return attempts
+	}
+	return defaultRetryAttempts
+}
+
+// retry calls fn until it succeeds or fails with a permanent error, the delay is doubled after every attempt
+func retry(attempts int, fn func() error) error {
+	delay := retryBaseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if !isRetryable(err) || attempt >= attempts {
+			return err
+		}
+		time.Sleep(delay)
+		if delay *= 2; delay > retryMaxDelay {
+			delay = retryMaxDelay
+		}
+	}
+}
+
+// availability tracks how long inserts of all tables fail with transient errors
+type availability struct {
+	since int64 // unix ns of the first failed insert, 0 while inserts succeed
+}
+
+func (a *availability) fail() {
+	atomic.CompareAndSwapInt64(&a.since, 0, time.Now().UnixNano())
+}
+
+func (a *availability) succeed() {
+	atomic.StoreInt64(&a.since, 0)
+}
+
+func (a *availability) unavailable() time.Duration {
+	since := atomic.LoadInt64(&a.since)
+	if since == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(0, since))
+}
@@ -0,0 +1,39 @@
+package postgres
+
+import "fmt"
+
+// The user and the project have to belong to the tenant of the token, the role of the user
+// gives access either to all projects of the tenant or to the listed ones
+const projectAccessQuery = `
+	SELECT EXISTS(
+		SELECT 1
+		FROM users u
+			INNER JOIN roles r ON r.role_id = u.role_id AND r.tenant_id = u.tenant_id
+			INNER JOIN projects p ON p.tenant_id = u.tenant_id
+		WHERE u.user_id = $1 AND u.tenant_id = $2 AND u.deleted_at IS NULL
+		  AND r.deleted_at IS NULL
+		  AND p.project_id = %s AND p.deleted_at IS NULL
+		  AND (r.all_projects OR EXISTS(
+			SELECT 1 FROM roles_projects rp WHERE rp.role_id = r.role_id AND rp.project_id = p.project_id
+		  ))
+	)`
+
+// HasSessionAccess checks that the dashboard user of the tenant can read the project of the session
+func (conn *Conn) HasSessionAccess(userID, tenantID, sessionID uint64) (bool, error) {
+	var hasAccess bool
+	err := conn.c.QueryRow(
+		fmt.Sprintf(projectAccessQuery, "(SELECT project_id FROM sessions WHERE session_id = $3)"),
+		userID, tenantID, sessionID,
+	).Scan(&hasAccess)
+	return hasAccess, err
+}
+
+// HasProjectAccess checks that the dashboard user of the tenant can read sessions of the project
+func (conn *Conn) HasProjectAccess(userID, tenantID uint64, projectID uint32) (bool, error) {
+	var hasAccess bool
+	err := conn.r.QueryRow(
+		fmt.Sprintf(projectAccessQuery, "$3"),
+		userID, tenantID, projectID,
+	).Scan(&hasAccess)
+	return hasAccess, err
+}
@@ -1,6 +1,7 @@
 package kafka
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"time"
@@ -14,11 +15,18 @@ import (
 
 type Message = kafka.Message
 
+const (
+	lagQueryTimeoutMs         = 5000 // timeout of the offsets requests of the lag reporter
+	defaultAutoCommitInterval = 2 * time.Minute
+)
+
 type Consumer struct {
 	c              *kafka.Consumer
 	messageHandler types.MessageHandler
 	commitTicker   *time.Ticker
 	pollTimeout    uint
+	workers        *partitionWorkers
+	listener       types.PartitionListener
 
 	lastReceivedPrtTs map[int32]int64
 }
@@ -29,23 +37,52 @@ func NewConsumer(
 	messageHandler types.MessageHandler,
 	autoCommit bool,
 	messageSizeLimit int,
+) *Consumer {
+	return newConsumer(group, topics, messageHandler, autoCommit, messageSizeLimit, false)
+}
+
+// NewConcurrentConsumer creates consumer which processes every assigned partition in a separate goroutine.
+// Message handler must be safe for concurrent use, messages of one partition are still handled in order.
+func NewConcurrentConsumer(
+	group string,
+	topics []string,
+	messageHandler types.MessageHandler,
+	autoCommit bool,
+	messageSizeLimit int,
+) *Consumer {
+	return newConsumer(group, topics, messageHandler, autoCommit, messageSizeLimit, true)
+}
+
+func newConsumer(
+	group string,
+	topics []string,
+	messageHandler types.MessageHandler,
+	autoCommit bool,
+	messageSizeLimit int,
+	concurrent bool,
 ) *Consumer {
 	kafkaConfig := &kafka.ConfigMap{
 		"bootstrap.servers":               env.String("KAFKA_SERVERS"),
 		"group.id":                        group,
 		"auto.offset.reset":               "earliest",
 		"enable.auto.commit":              "false",
-		"security.protocol":               "plaintext",
 		"go.application.rebalance.enable": true,
 		"max.poll.interval.ms":            env.Int("KAFKA_MAX_POLL_INTERVAL_MS"),
 		"max.partition.fetch.bytes":       messageSizeLimit,
 	}
-	// Apply ssl configuration
-	if env.Bool("KAFKA_USE_SSL") {
-		kafkaConfig.SetKey("security.protocol", "ssl")
-		kafkaConfig.SetKey("ssl.ca.location", os.Getenv("KAFKA_SSL_CA"))
-		kafkaConfig.SetKey("ssl.key.location", os.Getenv("KAFKA_SSL_KEY"))
-		kafkaConfig.SetKey("ssl.certificate.location", os.Getenv("KAFKA_SSL_CERT"))
+	if err := applySecurity(kafkaConfig); err != nil {
+		log.Fatalf("wrong kafka security configuration: %s", err)
+	}
+	// "cooperative-sticky" moves only the partitions which change their owner, the others are consumed during rebalances
+	if strategy := env.StringOptional("KAFKA_PARTITION_ASSIGNMENT_STRATEGY"); strategy != "" {
+		kafkaConfig.SetKey("partition.assignment.strategy", strategy)
+	}
+	// Static members get their partitions back if they restart within the session timeout, without a rebalance
+	if instanceID := groupInstanceID(group); instanceID != "" {
+		kafkaConfig.SetKey("group.instance.id", instanceID)
+	}
+	if timeout := env.IntOptional("KAFKA_SESSION_TIMEOUT_MS"); timeout > 0 {
+		kafkaConfig.SetKey("session.timeout.ms", timeout)
 	}
 	c, err := kafka.NewConsumer(kafkaConfig)
 	if err != nil {
@@ -59,25 +96,97 @@ func NewConsumer(
 		subREx += t
 	}
 	subREx += ")$"
-	if err := c.Subscribe(subREx, nil); err != nil {
-		log.Fatalln(err)
-	}
 
 	var commitTicker *time.Ticker
 	if autoCommit {
-		commitTicker = time.NewTicker(2 * time.Minute)
+		interval := env.DurationOptional("QUEUE_AUTO_COMMIT_INTERVAL")
+		if interval <= 0 {
+			interval = defaultAutoCommitInterval
+		}
+		commitTicker = time.NewTicker(interval)
 	}
 
-	return &Consumer{
+	consumer := &Consumer{
 		c:                 c,
 		messageHandler:    messageHandler,
 		commitTicker:      commitTicker,
 		pollTimeout:       200,
 		lastReceivedPrtTs: make(map[int32]int64),
 	}
+	if concurrent {
+		consumer.workers = newPartitionWorkers(messageHandler)
+	}
+	if err := c.Subscribe(subREx, consumer.rebalance); err != nil {
+		log.Fatalln(err)
+	}
+	return consumer
+}
+
+// groupInstanceID is KAFKA_GROUP_INSTANCE_ID or, with KAFKA_STATIC_MEMBERSHIP, the group name with the hostname,
+// which is stable for pods of stateful sets
+func groupInstanceID(group string) string {
+	if id := env.StringOptional("KAFKA_GROUP_INSTANCE_ID"); id != "" {
+		return id
+	}
+	if env.StringOptional("KAFKA_STATIC_MEMBERSHIP") != "true" {
+		return ""
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Fatalf("can't get hostname for static group membership: %s", err)
+	}
+	return fmt.Sprintf("%s-%s", group, hostname)
+}
+
+// rebalance finishes processing of revoked partitions before they are handed over to another consumer.
+// The library (un)assigns partitions after the callback, incrementally for cooperative strategies, so
+// the listeners get only the changed partitions in this case.
+func (consumer *Consumer) rebalance(c *kafka.Consumer, ev kafka.Event) error {
+	switch e := ev.(type) {
+	case kafka.AssignedPartitions:
+		if consumer.listener != nil {
+			consumer.listener.Assigned(topicPartitions(e.Partitions))
+		}
+	case kafka.RevokedPartitions:
+		if consumer.workers != nil {
+			consumer.workers.stop(e.Partitions)
+			if consumer.commitTicker != nil {
+				consumer.Commit()
+			}
+		}
+		if consumer.listener != nil {
+			consumer.listener.Revoked(topicPartitions(e.Partitions))
+		}
+	}
+	return nil
+}
+
+// SetPartitionListener sets the listener which is called on every partition assignment and revocation
+func (consumer *Consumer) SetPartitionListener(listener types.PartitionListener) {
+	consumer.listener = listener
+}
+
+func topicPartitions(partitions []kafka.TopicPartition) []types.TopicPartition {
+	result := make([]types.TopicPartition, 0, len(partitions))
+	for _, p := range partitions {
+		tp := types.TopicPartition{Partition: uint64(p.Partition)}
+		if p.Topic != nil {
+			tp.Topic = *p.Topic
+		}
+		result = append(result, tp)
+	}
+	return result
+}
+
+// waitWorkers blocks until all dispatched messages are handled, so that commit doesn't skip any of them
+func (consumer *Consumer) waitWorkers() {
+	if consumer.workers != nil {
+		consumer.workers.wait()
+	}
 }
 
 func (consumer *Consumer) Commit() error {
+	consumer.waitWorkers()
 	consumer.c.Commit() // TODO: return error if it is not "No offset stored"
 	return nil
 }
@@ -86,6 +195,7 @@ func (consumer *Consumer) commitAtTimestamps(
 	getPartitionTime func(kafka.TopicPartition) (bool, int64),
 	limitToCommitted bool,
 ) error {
+	consumer.waitWorkers()
 	assigned, err := consumer.c.Assignment()
 	if err != nil {
 		return err
@@ -171,9 +281,15 @@ func (consumer *Consumer) ConsumeNext() error {
 			return errors.Wrap(e.TopicPartition.Error, "Consumer Partition Error")
 		}
 		ts := e.Timestamp.UnixMilli()
+		if consumer.workers != nil {
+			consumer.workers.dispatch(e)
+			consumer.lastReceivedPrtTs[e.TopicPartition.Partition] = ts
+			return nil
+		}
 		consumer.messageHandler(decodeKey(e.Key), e.Value, &types.Meta{
 			Topic:     *(e.TopicPartition.Topic),
 			ID:        uint64(e.TopicPartition.Offset),
+			Partition: uint64(e.TopicPartition.Partition),
 			Timestamp: ts,
 		})
 		consumer.lastReceivedPrtTs[e.TopicPartition.Partition] = ts
@@ -190,6 +306,9 @@ func (consumer *Consumer) Close() {
 	if consumer.commitTicker != nil {
 		consumer.Commit()
 	}
+	if consumer.workers != nil {
+		consumer.workers.stop(nil)
+	}
 	if err := consumer.c.Close(); err != nil {
 		log.Printf("Kafka consumer close error: %v", err)
 	}
@@ -207,3 +326,36 @@ func (consumer *Consumer) HasFirstPartition() bool {
 	}
 	return false
 }
+
+// Lag returns the number of messages of assigned partitions after the committed offsets of the group
+func (consumer *Consumer) Lag() ([]types.PartitionLag, error) {
+	assigned, err := consumer.c.Assignment()
+	if err != nil || len(assigned) == 0 {
+		return nil, err
+	}
+	committed, err := consumer.c.Committed(assigned, lagQueryTimeoutMs)
+	if err != nil {
+		return nil, err
+	}
+	lags := make([]types.PartitionLag, 0, len(committed))
+	for _, p := range committed {
+		low, high, err := consumer.c.QueryWatermarkOffsets(*p.Topic, p.Partition, lagQueryTimeoutMs)
+		if err != nil {
+			return nil, err
+		}
+		offset := int64(p.Offset)
+		if offset < 0 {
+			// Nothing is committed, the group starts from the earliest message
+			offset = low
+		}
+		lag := high - offset
+		if lag < 0 {
+			lag = 0
+		}
+		lags = append(lags, types.PartitionLag{
+			TopicPartition: types.TopicPartition{Topic: *p.Topic, Partition: uint64(p.Partition)},
+			Lag:            lag,
+		})
+	}
+	return lags, nil
+}
@@ -3,14 +3,26 @@ package kafka
 import (
 	"fmt"
 	"log"
-	"os"
+	"sync/atomic"
+	"time"
 
 	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
 	"openreplay/backend/pkg/env"
+	"openreplay/backend/pkg/queue/types"
 )
 
+// Bytes of messages which are produced but not delivered yet, the produce channel and the local queue
+// of librdkafka grow without limit while brokers are slow
+const defaultMaxInFlightBytes = 256 << 20
+
 type Producer struct {
-	producer *kafka.Producer
+	producer  *kafka.Producer
+	txn       *transaction // nil if KAFKA_TRANSACTIONAL isn't "true"
+	downSince int64        // unix nano time of the first failure after the last successful delivery
+	onFailure func(topic string, partition int32, key uint64, value []byte)
+	inFlight  int64 // bytes of messages waiting for the delivery report
+	maxBytes  int64 // 0 or less disables the limit
+	rejected  uint64
 }
 
 func NewProducer(messageSizeLimit int, useBatch bool) *Producer {
@@ -18,24 +30,33 @@ func NewProducer(messageSizeLimit int, useBatch bool) *Producer {
 		"enable.idempotence":     true,
 		"bootstrap.servers":      env.String("KAFKA_SERVERS"),
 		"go.delivery.reports":    true,
-		"security.protocol":      "plaintext",
 		"go.batch.producer":      useBatch,
 		"queue.buffering.max.ms": 100,
 		"message.max.bytes":      messageSizeLimit,
 	}
-	// Apply ssl configuration
-	if env.Bool("KAFKA_USE_SSL") {
-		kafkaConfig.SetKey("security.protocol", "ssl")
-		kafkaConfig.SetKey("ssl.ca.location", os.Getenv("KAFKA_SSL_CA"))
-		kafkaConfig.SetKey("ssl.key.location", os.Getenv("KAFKA_SSL_KEY"))
-		kafkaConfig.SetKey("ssl.certificate.location", os.Getenv("KAFKA_SSL_CERT"))
+	if err := applySecurity(kafkaConfig); err != nil {
+		log.Fatalf("wrong kafka security configuration: %s", err)
+	}
+	// Re-sent batches of the restarted instance are fenced by the broker and never seen by consumers
+	transactional := env.StringOptional("KAFKA_TRANSACTIONAL") == "true"
+	if transactional {
+		kafkaConfig.SetKey("transactional.id", transactionalID())
 	}
 	producer, err := kafka.NewProducer(kafkaConfig)
 	if err != nil {
 		log.Fatalln(err)
 	}
-	newProducer := &Producer{producer}
+	newProducer := &Producer{producer: producer, maxBytes: defaultMaxInFlightBytes}
+	if env.StringOptional("KAFKA_MAX_IN_FLIGHT_BYTES") != "" {
+		newProducer.maxBytes = int64(env.Int("KAFKA_MAX_IN_FLIGHT_BYTES"))
+	}
 	go newProducer.errorHandler()
+	go newProducer.report()
+	if transactional {
+		if newProducer.txn, err = newTransaction(producer, newProducer.sent); err != nil {
+			log.Fatalf("can't init kafka transactions: %s", err)
+		}
+	}
 	return newProducer
 }
 
@@ -43,36 +64,122 @@ func (p *Producer) errorHandler() {
 	for e := range p.producer.Events() {
 		switch ev := e.(type) {
 		case *kafka.Message:
+			atomic.AddInt64(&p.inFlight, -int64(len(ev.Value)))
 			if ev.TopicPartition.Error != nil {
 				fmt.Printf("Delivery failed: topicPartition: %v, key: %d\n", ev.TopicPartition, decodeKey(ev.Key))
+				p.markDown()
+				// Messages of failed transactions are sent again by the transaction itself
+				if p.onFailure != nil && p.txn == nil && ev.TopicPartition.Topic != nil {
+					p.onFailure(*ev.TopicPartition.Topic, ev.TopicPartition.Partition, decodeKey(ev.Key), ev.Value)
+				}
+			} else {
+				p.markUp()
+			}
+		case kafka.Error:
+			if ev.Code() == kafka.ErrAllBrokersDown {
+				log.Printf("all brokers are down: %s", ev)
+				p.markDown()
 			}
 		}
 	}
 }
 
+func (p *Producer) markDown() {
+	atomic.CompareAndSwapInt64(&p.downSince, 0, time.Now().UnixNano())
+}
+
+func (p *Producer) markUp() {
+	atomic.StoreInt64(&p.downSince, 0)
+}
+
+// Unavailable returns how long brokers don't accept messages
+func (p *Producer) Unavailable() time.Duration {
+	downSince := atomic.LoadInt64(&p.downSince)
+	if downSince == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(0, downSince))
+}
+
+// Ping requests cluster metadata to check that brokers are reachable
+func (p *Producer) Ping() error {
+	if _, err := p.producer.GetMetadata(nil, false, 1000); err != nil {
+		return err
+	}
+	p.markUp()
+	return nil
+}
+
+// SetDeliveryFailureHandler sets handler for messages which weren't delivered after all retries,
+// must be called before producing messages
+func (p *Producer) SetDeliveryFailureHandler(handler func(topic string, partition int32, key uint64, value []byte)) {
+	p.onFailure = handler
+}
+
+// InFlightBytes returns the size of messages which weren't delivered yet
+func (p *Producer) InFlightBytes() int64 {
+	return atomic.LoadInt64(&p.inFlight)
+}
+
+func (p *Producer) sent(msg *kafka.Message) {
+	atomic.AddInt64(&p.inFlight, int64(len(msg.Value)))
+}
+
+// full doesn't reject the message if nothing is in flight, so a message bigger than the limit is still sent
+func (p *Producer) full(size int) bool {
+	inFlight := atomic.LoadInt64(&p.inFlight)
+	return p.maxBytes > 0 && inFlight > 0 && inFlight+int64(size) > p.maxBytes
+}
+
+func (p *Producer) report() {
+	for range time.Tick(time.Minute) {
+		if rejected := atomic.SwapUint64(&p.rejected, 0); rejected > 0 {
+			log.Printf("kafka producer rejected %d messages, in flight: %d bytes, limit: %d bytes", rejected, p.InFlightBytes(), p.maxBytes)
+		}
+	}
+}
+
+func (p *Producer) produce(msg *kafka.Message) error {
+	if p.full(len(msg.Value)) {
+		atomic.AddUint64(&p.rejected, 1)
+		return types.ErrBackpressure
+	}
+	if p.txn != nil {
+		return p.txn.produce(msg)
+	}
+	p.sent(msg)
+	p.producer.ProduceChannel() <- msg
+	return nil
+}
+
 func (p *Producer) Produce(topic string, key uint64, value []byte) error {
-	p.producer.ProduceChannel() <- &kafka.Message{
+	return p.produce(&kafka.Message{
 		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: getKeyPartition(key)},
 		Key:            encodeKey(key),
 		Value:          value,
-	}
-	return nil
+	})
 }
 
 func (p *Producer) ProduceToPartition(topic string, partition, key uint64, value []byte) error {
-	p.producer.ProduceChannel() <- &kafka.Message{
+	return p.produce(&kafka.Message{
 		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: int32(partition)},
 		Key:            encodeKey(key),
 		Value:          value,
-	}
-	return nil
+	})
 }
 
 func (p *Producer) Close(timeoutMs int) {
-	p.producer.Flush(timeoutMs)
+	p.Flush(timeoutMs)
 	p.producer.Close()
 }
 
+// Flush commits the current transaction in the transactional mode, messages become visible to consumers
 func (p *Producer) Flush(timeoutMs int) {
+	if p.txn != nil {
+		if err := p.txn.commit(time.Duration(timeoutMs) * time.Millisecond); err != nil {
+			log.Printf("can't commit kafka transaction: %s", err)
+		}
+		return
+	}
 	p.producer.Flush(timeoutMs)
 }
@@ -0,0 +1,72 @@
+package kafka
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+	"openreplay/backend/pkg/env"
+)
+
+// SASL mechanisms supported by managed clusters (MSK, Confluent Cloud, Aiven)
+var saslMechanisms = map[string]bool{
+	"PLAIN":         true,
+	"SCRAM-SHA-256": true,
+	"SCRAM-SHA-512": true,
+}
+
+// applySecurity configures authentication of producers and consumers. KAFKA_USE_SSL enables TLS,
+// KAFKA_SSL_CA replaces system CAs, KAFKA_SSL_CERT and KAFKA_SSL_KEY (with KAFKA_SSL_KEY_PASSWORD if the key
+// is encrypted) are the client certificate of mutual TLS. KAFKA_SASL_MECHANISM enables SASL with
+// KAFKA_SASL_USERNAME and KAFKA_SASL_PASSWORD, over TLS if it's enabled.
+func applySecurity(cfg *kafka.ConfigMap) error {
+	useSSL := env.Bool("KAFKA_USE_SSL")
+	mechanism := strings.ToUpper(env.StringOptional("KAFKA_SASL_MECHANISM"))
+	protocol := "plaintext"
+	switch {
+	case mechanism != "" && useSSL:
+		protocol = "sasl_ssl"
+	case mechanism != "":
+		protocol = "sasl_plaintext"
+	case useSSL:
+		protocol = "ssl"
+	}
+	settings := map[string]string{"security.protocol": protocol}
+
+	if useSSL {
+		ca, cert, key := env.StringOptional("KAFKA_SSL_CA"), env.StringOptional("KAFKA_SSL_CERT"), env.StringOptional("KAFKA_SSL_KEY")
+		if (cert == "") != (key == "") {
+			return fmt.Errorf("both KAFKA_SSL_CERT and KAFKA_SSL_KEY are required for mutual tls")
+		}
+		if ca != "" {
+			settings["ssl.ca.location"] = ca
+		}
+		if cert != "" {
+			settings["ssl.certificate.location"] = cert
+			settings["ssl.key.location"] = key
+		}
+		if password := env.StringOptional("KAFKA_SSL_KEY_PASSWORD"); password != "" {
+			settings["ssl.key.password"] = password
+		}
+	}
+
+	if mechanism != "" {
+		if !saslMechanisms[mechanism] {
+			return fmt.Errorf("unknown sasl mechanism: %s", mechanism)
+		}
+		username, password := env.StringOptional("KAFKA_SASL_USERNAME"), env.StringOptional("KAFKA_SASL_PASSWORD")
+		if username == "" || password == "" {
+			return fmt.Errorf("KAFKA_SASL_USERNAME and KAFKA_SASL_PASSWORD are required for sasl")
+		}
+		settings["sasl.mechanisms"] = mechanism
+		settings["sasl.username"] = username
+		settings["sasl.password"] = password
+	}
+
+	for key, value := range settings {
+		if err := cfg.SetKey(key, value); err != nil {
+			return fmt.Errorf("can't set %s: %s", key, err)
+		}
+	}
+	return nil
+}
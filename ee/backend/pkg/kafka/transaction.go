@@ -0,0 +1,163 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+	"openreplay/backend/pkg/env"
+)
+
+const (
+	defaultTransactionInterval = time.Second
+	transactionInitTimeout     = 30 * time.Second
+	transactionCommitTimeout   = 10 * time.Second
+	transactionCommitAttempts  = 3
+	queueFullRetryDelay        = 10 * time.Millisecond
+)
+
+// transactionalID is stable across restarts of the instance, so the broker fences its previous incarnation
+func transactionalID() string {
+	if id := env.StringOptional("KAFKA_TRANSACTIONAL_ID"); id != "" {
+		return id
+	}
+	service := env.StringOptional("SERVICE_NAME")
+	if service == "" {
+		service = filepath.Base(os.Args[0])
+	}
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%s", service, hostname)
+}
+
+// transaction groups produced messages, consumers see them only after the commit (read_committed
+// is the default isolation level of librdkafka). Transactions are committed on flush and every
+// KAFKA_TRANSACTION_INTERVAL, messages of aborted transactions are sent again in the next one.
+type transaction struct {
+	producer *kafka.Producer
+	mu       sync.Mutex
+	open     bool
+	messages []*kafka.Message
+	sent     func(msg *kafka.Message) // counts messages passed to librdkafka, resent ones too
+}
+
+func newTransaction(producer *kafka.Producer, sent func(msg *kafka.Message)) (*transaction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), transactionInitTimeout)
+	defer cancel()
+	if err := producer.InitTransactions(ctx); err != nil {
+		return nil, err
+	}
+	t := &transaction{producer: producer, sent: sent}
+	interval := env.DurationOptional("KAFKA_TRANSACTION_INTERVAL")
+	if interval == 0 {
+		interval = defaultTransactionInterval
+	}
+	go t.commitLoop(interval)
+	return t, nil
+}
+
+// commitLoop doesn't let transactions of rarely flushed producers hit the transaction timeout
+func (t *transaction) commitLoop(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := t.commit(transactionCommitTimeout); err != nil {
+			log.Printf("can't commit kafka transaction: %s", err)
+		}
+	}
+}
+
+func (t *transaction) produce(msg *kafka.Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.open {
+		if err := t.begin(); err != nil {
+			return err
+		}
+	}
+	if err := t.send(msg); err != nil {
+		return err
+	}
+	t.messages = append(t.messages, msg)
+	return nil
+}
+
+// send waits while the local queue is full, the same way as the produce channel does
+func (t *transaction) send(msg *kafka.Message) error {
+	for {
+		err := t.producer.Produce(msg, nil)
+		if kerr, ok := err.(kafka.Error); ok && kerr.Code() == kafka.ErrQueueFull {
+			time.Sleep(queueFullRetryDelay)
+			continue
+		}
+		if err == nil {
+			t.sent(msg)
+		}
+		return err
+	}
+}
+
+func (t *transaction) commit(timeout time.Duration) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.open {
+		return nil
+	}
+	var err error
+	for attempt := 0; attempt < transactionCommitAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err = t.producer.CommitTransaction(ctx)
+		cancel()
+		if err == nil {
+			t.open = false
+			t.messages = nil
+			return nil
+		}
+		kerr, ok := err.(kafka.Error)
+		switch {
+		case ok && kerr.IsFatal():
+			// Another instance with the same transactional id has started, this one must not produce anymore
+			log.Fatalf("kafka transaction failed: %s", err)
+		case ok && kerr.TxnRequiresAbort():
+			if err := t.resend(timeout); err != nil {
+				return err
+			}
+		case ok && kerr.IsRetriable():
+		default:
+			return err
+		}
+	}
+	return fmt.Errorf("transaction isn't committed after %d attempts: %s", transactionCommitAttempts, err)
+}
+
+// resend aborts the transaction and sends its messages again in a new one
+func (t *transaction) resend(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := t.producer.AbortTransaction(ctx); err != nil {
+		return fmt.Errorf("can't abort transaction: %s", err)
+	}
+	t.open = false
+	log.Printf("kafka transaction is aborted, sending %d messages again", len(t.messages))
+	return t.begin()
+}
+
+// begin starts a new transaction with messages of the aborted one
+func (t *transaction) begin() error {
+	if err := t.producer.BeginTransaction(); err != nil {
+		return err
+	}
+	t.open = true
+	for _, msg := range t.messages {
+		if err := t.send(&kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: msg.TopicPartition.Topic, Partition: msg.TopicPartition.Partition},
+			Key:            msg.Key,
+			Value:          msg.Value,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package kafka
+
+import (
+	"sync"
+
+	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+	"openreplay/backend/pkg/queue/types"
+)
+
+const partitionQueueSize = 64
+
+type partitionKey struct {
+	topic     string
+	partition int32
+}
+
+// partitionWorkers processes messages of each partition in a separate goroutine.
+// Order is kept inside one partition, different partitions are processed concurrently.
+type partitionWorkers struct {
+	mu       sync.Mutex
+	handler  types.MessageHandler
+	workers  map[partitionKey]chan *kafka.Message
+	inFlight sync.WaitGroup
+}
+
+func newPartitionWorkers(handler types.MessageHandler) *partitionWorkers {
+	return &partitionWorkers{
+		handler: handler,
+		workers: make(map[partitionKey]chan *kafka.Message),
+	}
+}
+
+func (pw *partitionWorkers) dispatch(msg *kafka.Message) {
+	key := partitionKey{*msg.TopicPartition.Topic, msg.TopicPartition.Partition}
+	pw.mu.Lock()
+	queue, ok := pw.workers[key]
+	if !ok {
+		queue = make(chan *kafka.Message, partitionQueueSize)
+		pw.workers[key] = queue
+		go pw.worker(queue)
+	}
+	pw.mu.Unlock()
+
+	pw.inFlight.Add(1)
+	queue <- msg // blocks the poll loop when the partition is too slow
+}
+
+func (pw *partitionWorkers) worker(queue chan *kafka.Message) {
+	for msg := range queue {
+		pw.handler(decodeKey(msg.Key), msg.Value, &types.Meta{
+			Topic:     *(msg.TopicPartition.Topic),
+			ID:        uint64(msg.TopicPartition.Offset),
+			Partition: uint64(msg.TopicPartition.Partition),
+			Timestamp: msg.Timestamp.UnixMilli(),
+		})
+		pw.inFlight.Done()
+	}
+}
+
+// wait blocks until all dispatched messages are processed, must be called before any offset commit
+func (pw *partitionWorkers) wait() {
+	pw.inFlight.Wait()
+}
+
+// stop finishes workers of the given partitions (all partitions if nil) after draining their queues
+func (pw *partitionWorkers) stop(partitions []kafka.TopicPartition) {
+	pw.wait()
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if partitions == nil {
+		for key, queue := range pw.workers {
+			close(queue)
+			delete(pw.workers, key)
+		}
+		return
+	}
+	for _, p := range partitions {
+		if p.Topic == nil {
+			continue
+		}
+		key := partitionKey{*p.Topic, p.Partition}
+		if queue, ok := pw.workers[key]; ok {
+			close(queue)
+			delete(pw.workers, key)
+		}
+	}
+}
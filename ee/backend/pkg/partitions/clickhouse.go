@@ -0,0 +1,94 @@
+package partitions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+const queryTimeout = 10 * time.Minute
+
+// Tables with rows of sessions partitioned by their datetime, metrics tables are managed by the rollup service
+var tables = []string{"sessions", "events", "resources"}
+
+type clickHouseStore struct {
+	conn driver.Conn
+}
+
+func newClickHouseStore(url string) (*clickHouseStore, error) {
+	if url == "" {
+		return nil, errors.New("clickhouse url is empty")
+	}
+	addr := strings.TrimPrefix(url, "tcp://")
+	addr = strings.TrimSuffix(addr, "/default")
+	conn, err := clickhouse.Open(&clickhouse.Options{
+		Addr: []string{addr},
+		Auth: clickhouse.Auth{
+			Database: "default",
+		},
+		MaxOpenConns:    2,
+		MaxIdleConns:    1,
+		ConnMaxLifetime: 3 * time.Minute,
+		Compression: &clickhouse.Compression{
+			Method: clickhouse.CompressionLZ4,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &clickHouseStore{conn: conn}, nil
+}
+
+// Create does nothing, ClickHouse creates partitions on inserts
+func (s *clickHouseStore) Create(_, _ time.Time) (int, error) {
+	return 0, nil
+}
+
+// Expired returns partitions whose all parts are older than the time, the TTL of the tables removes
+// their rows only during merges
+func (s *clickHouseStore) Expired(before time.Time) ([]Partition, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	var expired []Partition
+	for _, table := range tables {
+		rows, err := s.conn.Query(ctx, `
+			SELECT partition_id
+			FROM system.parts
+			WHERE database = 'experimental' AND table = ? AND active
+			GROUP BY partition_id
+			HAVING max(max_time) < ?`,
+			table, before.UTC(),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("can't get partitions of %s: %s", table, err)
+		}
+		for rows.Next() {
+			var partition string
+			if err := rows.Scan(&partition); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			expired = append(expired, Partition{Table: table, Name: partition})
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return expired, nil
+}
+
+func (s *clickHouseStore) Drop(p Partition) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	return s.conn.Exec(ctx, fmt.Sprintf("ALTER TABLE experimental.%s DROP PARTITION ID '%s'", p.Table, p.Name))
+}
+
+func (s *clickHouseStore) Close() error {
+	return s.conn.Close()
+}
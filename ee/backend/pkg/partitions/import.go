@@ -0,0 +1,13 @@
+package partitions
+
+import "openreplay/backend/pkg/license"
+
+// NewAnalyticsStore connects to ClickHouse, partitions of the experimental database are dropped by their time
+func NewAnalyticsStore(url string) (Store, error) {
+	license.CheckLicense()
+	store, err := newClickHouseStore(url)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
@@ -1,17 +1,83 @@
 package queue
 
 import (
+	"log"
+	"time"
+
+	"openreplay/backend/pkg/env"
 	"openreplay/backend/pkg/kafka"
 	"openreplay/backend/pkg/license"
+	"openreplay/backend/pkg/memqueue"
+	"openreplay/backend/pkg/natsstream"
+	"openreplay/backend/pkg/pulsarstream"
 	"openreplay/backend/pkg/queue/types"
+	"openreplay/backend/pkg/redisstream"
 )
 
 func NewConsumer(group string, topics []string, handler types.MessageHandler, autoCommit bool, messageSizeLimit int) types.Consumer {
 	license.CheckLicense()
+	if useMemory() {
+		return memqueue.NewConsumer(group, topics, handler, autoCommit)
+	}
+	if usePulsar() {
+		return pulsarstream.NewConsumer(group, topics, handler, autoCommit)
+	}
+	if useNATS() {
+		return natsstream.NewConsumer(group, topics, handler, autoCommit)
+	}
+	if useRedis() {
+		return redisstream.NewConsumer(group, topics, handler, autoCommit)
+	}
 	return kafka.NewConsumer(group, topics, handler, autoCommit, messageSizeLimit)
 }
 
+func NewConcurrentConsumer(group string, topics []string, handler types.MessageHandler, autoCommit bool, messageSizeLimit int) types.Consumer {
+	license.CheckLicense()
+	if useMemory() || usePulsar() || useNATS() || useRedis() {
+		log.Printf("concurrent consumer requires kafka, partitions of group %s are processed in one goroutine", group)
+	}
+	if useMemory() {
+		return memqueue.NewConsumer(group, topics, handler, autoCommit)
+	}
+	if usePulsar() {
+		return pulsarstream.NewConsumer(group, topics, handler, autoCommit)
+	}
+	if useNATS() {
+		return natsstream.NewConsumer(group, topics, handler, autoCommit)
+	}
+	if useRedis() {
+		return redisstream.NewConsumer(group, topics, handler, autoCommit)
+	}
+	return kafka.NewConcurrentConsumer(group, topics, handler, autoCommit, messageSizeLimit)
+}
+
 func NewProducer(messageSizeLimit int, useBatch bool) types.Producer {
+	return compressBatches(encodeBatches(newProducer(messageSizeLimit, useBatch)))
+}
+
+func newProducer(messageSizeLimit int, useBatch bool) types.Producer {
 	license.CheckLicense()
-	return kafka.NewProducer(messageSizeLimit, useBatch)
+	if useMemory() {
+		return memqueue.NewProducer()
+	}
+	if usePulsar() {
+		return pulsarstream.NewProducer()
+	}
+	if useNATS() {
+		return natsstream.NewProducer()
+	}
+	if useRedis() {
+		return redisstream.NewProducer()
+	}
+	producer := kafka.NewProducer(messageSizeLimit, useBatch)
+	if env.StringOptional("QUEUE_FAILOVER") != "true" {
+		return producer
+	}
+	// Messages are kept in redis while kafka is unavailable
+	stream := env.StringOptional("QUEUE_FAILOVER_STREAM")
+	if stream == "" {
+		stream = "queue-failover"
+	}
+	timeout := time.Duration(env.Int("QUEUE_FAILOVER_TIMEOUT_SEC")) * time.Second
+	return NewFailoverProducer(producer, producer, redisstream.NewSpool(stream), timeout)
 }
@@ -0,0 +1,135 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+
+	ch "openreplay/backend/pkg/db/clickhouse"
+	"openreplay/backend/pkg/db/types"
+)
+
+const (
+	queryTimeout = 60 * time.Second
+	// Max number of session ids in one IN clause
+	chunkSize = 1000
+)
+
+type clickHouseStore struct {
+	url       string
+	conn      driver.Conn
+	connector ch.Connector // created on the first repair
+}
+
+func newClickHouseStore(url string) (*clickHouseStore, error) {
+	if url == "" {
+		return nil, errors.New("clickhouse url is empty")
+	}
+	addr := strings.TrimPrefix(url, "tcp://")
+	addr = strings.TrimSuffix(addr, "/default")
+	conn, err := clickhouse.Open(&clickhouse.Options{
+		Addr: []string{addr},
+		Auth: clickhouse.Auth{
+			Database: "default",
+		},
+		MaxOpenConns:    5,
+		MaxIdleConns:    2,
+		ConnMaxLifetime: 3 * time.Minute,
+		Compression: &clickhouse.Compression{
+			Method: clickhouse.CompressionLZ4,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &clickHouseStore{url: url, conn: conn}, nil
+}
+
+func (s *clickHouseStore) SessionIDs(from, to time.Time) (map[uint64]bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	rows, err := s.conn.Query(ctx, `
+		SELECT session_id
+		FROM experimental.sessions FINAL
+		WHERE datetime >= ? AND datetime < ?`,
+		from.UTC(), to.UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("can't get sessions: %s", err)
+	}
+	defer rows.Close()
+	ids := make(map[uint64]bool)
+	for rows.Next() {
+		var sessionID uint64
+		if err := rows.Scan(&sessionID); err != nil {
+			return nil, err
+		}
+		ids[sessionID] = true
+	}
+	return ids, rows.Err()
+}
+
+// SessionsWithEvents looks for events starting from the session start, events of long sessions may be after the window end
+func (s *clickHouseStore) SessionsWithEvents(from time.Time, sessionIDs []uint64) (map[uint64]bool, error) {
+	ids := make(map[uint64]bool, len(sessionIDs))
+	for start := 0; start < len(sessionIDs); start += chunkSize {
+		end := start + chunkSize
+		if end > len(sessionIDs) {
+			end = len(sessionIDs)
+		}
+		if err := s.sessionsWithEvents(from, sessionIDs[start:end], ids); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+func (s *clickHouseStore) sessionsWithEvents(from time.Time, sessionIDs []uint64, ids map[uint64]bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	rows, err := s.conn.Query(ctx, `
+		SELECT DISTINCT session_id
+		FROM experimental.events
+		WHERE datetime >= ? AND session_id IN ?`,
+		from.UTC(), sessionIDs,
+	)
+	if err != nil {
+		return fmt.Errorf("can't get events: %s", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sessionID uint64
+		if err := rows.Scan(&sessionID); err != nil {
+			return err
+		}
+		ids[sessionID] = true
+	}
+	return rows.Err()
+}
+
+func (s *clickHouseStore) InsertSessions(sessions []*types.Session) error {
+	if len(sessions) == 0 {
+		return nil
+	}
+	if s.connector == nil {
+		s.connector = ch.NewConnector(s.url, nil)
+	}
+	if err := s.connector.Prepare(); err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if err := s.connector.InsertWebSession(session); err != nil {
+			return fmt.Errorf("can't insert session %d: %s", session.SessionID, err)
+		}
+	}
+	return s.connector.Commit()
+}
+
+func (s *clickHouseStore) Close() error {
+	return s.conn.Close()
+}
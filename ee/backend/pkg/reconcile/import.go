@@ -0,0 +1,13 @@
+package reconcile
+
+import "openreplay/backend/pkg/license"
+
+// NewStore connects to ClickHouse, sessions and events are taken from the experimental database
+func NewStore(url string) (Store, error) {
+	license.CheckLicense()
+	store, err := newClickHouseStore(url)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
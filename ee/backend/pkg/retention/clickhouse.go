@@ -0,0 +1,180 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+
+	"openreplay/backend/pkg/tenants"
+)
+
+const (
+	queryTimeout = 10 * time.Minute
+	// Max number of session ids in one IN clause
+	chunkSize = 1000
+)
+
+// Tables with rows of sessions and dashboard aggregates, all of them are ordered by project and datetime
+var tables = []string{"sessions", "events", "resources", "request_errors_minutely", "page_speed_minutely"}
+
+// Tables with rows of sessions which are erased on requests of users. Materialized views of the last 7 days
+// aren't changed by mutations of their sources, their rows are removed by the TTL.
+var userTables = []string{"sessions", "events", "resources", "user_favorite_sessions", "user_viewed_sessions"}
+
+type clickHouseStore struct {
+	conn   driver.Conn
+	router *tenants.Router // nil keeps all projects in the shared database
+}
+
+func newClickHouseStore(url string, router *tenants.Router) (*clickHouseStore, error) {
+	if url == "" {
+		return nil, errors.New("clickhouse url is empty")
+	}
+	addr := strings.TrimPrefix(url, "tcp://")
+	addr = strings.TrimSuffix(addr, "/default")
+	conn, err := clickhouse.Open(&clickhouse.Options{
+		Addr: []string{addr},
+		Auth: clickhouse.Auth{
+			Database: "default",
+		},
+		MaxOpenConns:    2,
+		MaxIdleConns:    1,
+		ConnMaxLifetime: 3 * time.Minute,
+		Compression: &clickhouse.Compression{
+			Method: clickhouse.CompressionLZ4,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &clickHouseStore{conn: conn, router: router}, nil
+}
+
+// database returns the database of the project tenant, rows of isolated tenants are cleaned by their own retention
+func (s *clickHouseStore) database(projectID uint32) string {
+	if database := s.router.Database(projectID); database != "" {
+		return database
+	}
+	return "experimental"
+}
+
+func (s *clickHouseStore) CountBefore(projectID uint32, before time.Time) (uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	var count uint64
+	err := s.conn.QueryRow(ctx, fmt.Sprintf(`
+		SELECT count()
+		FROM %s.sessions
+		WHERE project_id = ? AND datetime < ?`, s.database(projectID)),
+		projectID, before.UTC(),
+	).Scan(&count)
+	return count, err
+}
+
+// DeleteBefore starts mutations of the tables, ClickHouse applies them in the background after the query returns
+func (s *clickHouseStore) DeleteBefore(projectID uint32, before time.Time) error {
+	for _, table := range tables {
+		err := s.mutate(fmt.Sprintf(`
+			ALTER TABLE %s.%s DELETE
+			WHERE project_id = ? AND datetime < ?`, s.database(projectID), table),
+			projectID, before.UTC(),
+		)
+		if err != nil {
+			return fmt.Errorf("can't delete from %s: %s", table, err)
+		}
+	}
+	return nil
+}
+
+func (s *clickHouseStore) UserSessionIDs(projectID uint32, userID, anonymousID string) ([]uint64, error) {
+	var (
+		conditions []string
+		args       = []interface{}{projectID}
+	)
+	if userID != "" {
+		conditions = append(conditions, "user_id = ?")
+		args = append(args, userID)
+	}
+	if anonymousID != "" {
+		conditions = append(conditions, "user_anonymous_id = ?")
+		args = append(args, anonymousID)
+	}
+	if len(conditions) == 0 {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	rows, err := s.conn.Query(ctx, fmt.Sprintf(`
+		SELECT DISTINCT session_id
+		FROM %s.sessions
+		WHERE project_id = ? AND (%s)`, s.database(projectID), strings.Join(conditions, " OR ")),
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []uint64
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// EraseUser starts mutations of all tables with sessions and of the autocomplete with the user ids
+func (s *clickHouseStore) EraseUser(projectID uint32, userID, anonymousID string, sessionIDs []uint64) error {
+	database := s.database(projectID)
+	for start := 0; start < len(sessionIDs); start += chunkSize {
+		end := start + chunkSize
+		if end > len(sessionIDs) {
+			end = len(sessionIDs)
+		}
+		for _, table := range userTables {
+			if err := s.mutate(fmt.Sprintf(`
+				ALTER TABLE %s.%s DELETE
+				WHERE project_id = ? AND session_id IN ?`, database, table),
+				projectID, sessionIDs[start:end],
+			); err != nil {
+				return fmt.Errorf("can't delete from %s: %s", table, err)
+			}
+		}
+	}
+	for _, value := range []struct {
+		id    string
+		types []string
+	}{
+		{userID, []string{"USERID", "USERID_IOS"}},
+		{anonymousID, []string{"USERANONYMOUSID", "USERANONYMOUSID_IOS"}},
+	} {
+		if value.id == "" {
+			continue
+		}
+		if err := s.mutate(fmt.Sprintf(`
+			ALTER TABLE %s.autocomplete DELETE
+			WHERE project_id = ? AND type IN ? AND value = ?`, database),
+			projectID, value.types, value.id,
+		); err != nil {
+			return fmt.Errorf("can't delete from autocomplete: %s", err)
+		}
+	}
+	return nil
+}
+
+func (s *clickHouseStore) mutate(query string, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	return s.conn.Exec(ctx, query, args...)
+}
+
+func (s *clickHouseStore) Close() error {
+	return s.conn.Close()
+}
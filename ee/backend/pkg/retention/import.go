@@ -0,0 +1,21 @@
+package retention
+
+import (
+	"openreplay/backend/pkg/license"
+	"openreplay/backend/pkg/tenants"
+)
+
+// NewStore connects to ClickHouse, sessions and events are deleted from the experimental database or from
+// databases of tenants if CLICKHOUSE_TENANT_DATABASES is set
+func NewStore(url string, routes tenants.Source) (Store, error) {
+	license.CheckLicense()
+	router, err := tenants.NewRouter(routes)
+	if err != nil {
+		return nil, err
+	}
+	store, err := newClickHouseStore(url, router)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
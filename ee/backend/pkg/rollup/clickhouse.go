@@ -0,0 +1,130 @@
+package rollup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+const queryTimeout = 10 * time.Minute // the first rollup of a long living installation reads a lot of data
+
+type clickHouseStore struct {
+	conn driver.Conn
+}
+
+func newClickHouseStore(url string) (*clickHouseStore, error) {
+	if url == "" {
+		return nil, errors.New("clickhouse url is empty")
+	}
+	addr := strings.TrimPrefix(url, "tcp://")
+	addr = strings.TrimSuffix(addr, "/default")
+	conn, err := clickhouse.Open(&clickhouse.Options{
+		Addr: []string{addr},
+		Auth: clickhouse.Auth{
+			Database: "default",
+		},
+		MaxOpenConns:    2,
+		MaxIdleConns:    1,
+		ConnMaxLifetime: 3 * time.Minute,
+		Compression: &clickhouse.Compression{
+			Method: clickhouse.CompressionLZ4,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &clickHouseStore{conn: conn}, nil
+}
+
+func table(level Level) string {
+	return "metrics_" + level.Name
+}
+
+// timeBound returns zero time for the empty table, ClickHouse returns the epoch start in this case
+func (s *clickHouseStore) timeBound(query string) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	var res time.Time
+	if err := s.conn.QueryRow(ctx, query).Scan(&res); err != nil {
+		return time.Time{}, err
+	}
+	if res.Unix() <= 0 {
+		return time.Time{}, nil
+	}
+	return res, nil
+}
+
+func (s *clickHouseStore) First(level Level) (time.Time, error) {
+	return s.timeBound(fmt.Sprintf("SELECT min(datetime) FROM experimental.%s", table(level)))
+}
+
+func (s *clickHouseStore) Last(level Level) (time.Time, error) {
+	return s.timeBound(fmt.Sprintf("SELECT max(datetime) FROM experimental.%s", table(level)))
+}
+
+// Rollup aligns periods to the unix epoch the same way as time.Truncate does for UTC
+func (s *clickHouseStore) Rollup(source, target Level, from, to time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	period := uint32(target.Period / time.Second)
+	return s.conn.Exec(ctx, fmt.Sprintf(`
+		INSERT INTO experimental.%s (project_id, name, datetime, count, sum_value, min_value, max_value)
+		SELECT project_id,
+		       name,
+		       toDateTime(intDiv(toUInt32(datetime), ?) * ?, 'UTC') AS period,
+		       sum(count),
+		       sum(sum_value),
+		       min(min_value),
+		       max(max_value)
+		FROM experimental.%s
+		WHERE datetime >= ? AND datetime < ?
+		GROUP BY project_id, name, period`, table(target), table(source)),
+		period, period, from.UTC(), to.UTC(),
+	)
+}
+
+// DropBefore removes whole partitions, so the data is kept a bit longer than the retention
+func (s *clickHouseStore) DropBefore(level Level, before time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	rows, err := s.conn.Query(ctx, `
+		SELECT partition_id
+		FROM system.parts
+		WHERE database = 'experimental' AND table = ? AND active
+		GROUP BY partition_id
+		HAVING max(max_time) < ?`,
+		table(level), before.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("can't get partitions: %s", err)
+	}
+	var partitions []string
+	for rows.Next() {
+		var partition string
+		if err := rows.Scan(&partition); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		partitions = append(partitions, partition)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	for i, partition := range partitions {
+		query := fmt.Sprintf("ALTER TABLE experimental.%s DROP PARTITION ID '%s'", table(level), partition)
+		if err := s.conn.Exec(ctx, query); err != nil {
+			return i, fmt.Errorf("can't drop partition %s: %s", partition, err)
+		}
+	}
+	return len(partitions), nil
+}
+
+func (s *clickHouseStore) Close() error {
+	return s.conn.Close()
+}
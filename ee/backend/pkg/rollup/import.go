@@ -0,0 +1,13 @@
+package rollup
+
+import "openreplay/backend/pkg/license"
+
+// NewStore connects to ClickHouse, levels are kept in experimental.metrics_<level> tables
+func NewStore(url string) (Store, error) {
+	license.CheckLicense()
+	store, err := newClickHouseStore(url)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
@@ -0,0 +1,243 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+const queryTimeout = 30 * time.Second
+
+var sessionColumns = map[string]string{
+	FilterUserID:      "s.user_id",
+	FilterAnonymousID: "s.user_anonymous_id",
+	FilterUserCountry: "toString(s.user_country)",
+	FilterUserOS:      "s.user_os",
+	FilterUserBrowser: "s.user_browser",
+	FilterUserDevice:  "s.user_device",
+	FilterPlatform:    "toString(s.platform)",
+	FilterReferrer:    "s.referrer",
+	FilterRevID:       "s.rev_id",
+	FilterDuration:    "s.duration",
+}
+
+// Event type in the events table and the column compared with filter values
+var eventColumns = map[string][2]string{
+	EventClick:       {"CLICK", "label"},
+	EventInput:       {"INPUT", "label"},
+	EventLocation:    {"LOCATION", "url_path"},
+	EventRequest:     {"REQUEST", "url_path"},
+	EventError:       {"ERROR", "message"},
+	EventCustom:      {"CUSTOM", "name"},
+	EventGraphQL:     {"GRAPHQL", "name"},
+	EventStateAction: {"STATEACTION", "name"},
+}
+
+var sortColumns = map[string]string{
+	SortStartTs:     "s.datetime",
+	SortDuration:    "s.duration",
+	SortEventsCount: "s.events_count",
+	SortErrorsCount: "s.errors_count",
+	SortIssueScore:  "s.issue_score",
+}
+
+type clickHouseSearcher struct {
+	conn driver.Conn
+}
+
+func newClickHouseSearcher(url string) (*clickHouseSearcher, error) {
+	if url == "" {
+		return nil, errors.New("clickhouse url is empty")
+	}
+	url = strings.TrimPrefix(url, "tcp://")
+	url = strings.TrimSuffix(url, "/default")
+	conn, err := clickhouse.Open(&clickhouse.Options{
+		Addr: []string{url},
+		Auth: clickhouse.Auth{
+			Database: "default",
+		},
+		MaxOpenConns:    10,
+		MaxIdleConns:    5,
+		ConnMaxLifetime: 3 * time.Minute,
+		Compression: &clickhouse.Compression{
+			Method: clickhouse.CompressionLZ4,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &clickHouseSearcher{conn: conn}, nil
+}
+
+// condition returns the expression for one of the filter values, all values are joined with OR
+// (with AND for negative operator)
+func condition(column, operator string, value interface{}, args *[]interface{}) string {
+	switch operator {
+	case OpIsNot:
+		*args = append(*args, value)
+		return column + " != ?"
+	case OpContains:
+		*args = append(*args, "%"+EscapeLike(value.(string))+"%")
+		return "ilike(" + column + ", ?)"
+	case OpStartsWith:
+		*args = append(*args, EscapeLike(value.(string))+"%")
+		return "ilike(" + column + ", ?)"
+	case OpEndsWith:
+		*args = append(*args, "%"+EscapeLike(value.(string)))
+		return "ilike(" + column + ", ?)"
+	case OpGreater:
+		*args = append(*args, value)
+		return column + " > ?"
+	case OpLess:
+		*args = append(*args, value)
+		return column + " < ?"
+	default:
+		*args = append(*args, value)
+		return column + " = ?"
+	}
+}
+
+func conditions(column, operator string, values []string, args *[]interface{}) string {
+	parts := make([]string, 0, len(values))
+	for _, value := range values {
+		parts = append(parts, condition(column, operator, value, args))
+	}
+	if operator == OpIsNot {
+		return "(" + strings.Join(parts, " AND ") + ")"
+	}
+	return "(" + strings.Join(parts, " OR ") + ")"
+}
+
+// buildWhere returns the condition for the sessions table
+func buildWhere(req *Request) (string, []interface{}, error) {
+	var args []interface{}
+	start := time.UnixMilli(req.StartTimestamp).UTC()
+	end := time.UnixMilli(req.EndTimestamp).UTC()
+	where := []string{"s.project_id = ?", "s.datetime >= ?", "s.datetime <= ?", "s.duration > 0"}
+	args = append(args, req.ProjectID, start, end)
+	for _, f := range req.Filters {
+		switch f.Type {
+		case FilterMetadata:
+			if f.MetadataNo < 1 || f.MetadataNo > 10 {
+				return "", nil, fmt.Errorf("unknown metadata key: %s", f.Key)
+			}
+			where = append(where, conditions("s."+MetadataColumn(f.MetadataNo), f.Operator, f.Values, &args))
+		case FilterIssue:
+			cond := "hasAny(s.issue_types, ?)"
+			if f.Operator == OpIsNot {
+				cond = "NOT " + cond
+			}
+			where = append(where, cond)
+			args = append(args, f.Values)
+		case FilterDuration:
+			parts := make([]string, 0, len(f.Values))
+			for _, value := range f.Values {
+				duration, _ := strconv.ParseUint(value, 10, 32) // checked by validation
+				parts = append(parts, condition(sessionColumns[f.Type], f.Operator, duration, &args))
+			}
+			where = append(where, "("+strings.Join(parts, " OR ")+")")
+		default:
+			where = append(where, conditions(sessionColumns[f.Type], f.Operator, f.Values, &args))
+		}
+	}
+	for _, e := range req.Events {
+		event := eventColumns[e.Type]
+		args = append(args, req.ProjectID, start, end, event[0])
+		where = append(where, `s.session_id IN (
+			SELECT session_id FROM experimental.events AS ev
+			WHERE ev.project_id = ? AND ev.datetime >= ? AND ev.datetime <= ? AND ev.event_type = ?
+			  AND `+conditions("ev."+event[1], e.Operator, e.Values, &args)+`)`)
+	}
+	return strings.Join(where, " AND "), args, nil
+}
+
+func (c *clickHouseSearcher) Search(req *Request) (*Response, error) {
+	where, args, err := buildWhere(req)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	res := &Response{Page: req.Page, Limit: req.Limit, Sessions: make([]*Session, 0, req.Limit)}
+	if err := c.conn.QueryRow(ctx, "SELECT count() FROM experimental.sessions AS s FINAL WHERE "+where,
+		args...).Scan(&res.Total); err != nil {
+		return nil, fmt.Errorf("can't count sessions: %s", err)
+	}
+	if res.Total == 0 {
+		return res, nil
+	}
+
+	query := `
+		SELECT s.session_id, s.project_id, s.datetime, s.duration, toString(s.platform),
+			   s.user_id, s.user_anonymous_id, toString(s.user_country), s.user_os, s.user_browser, s.user_device,
+			   s.pages_count, s.events_count, s.errors_count, s.issue_score, s.issue_types,
+			   s.metadata_1, s.metadata_2, s.metadata_3, s.metadata_4, s.metadata_5,
+			   s.metadata_6, s.metadata_7, s.metadata_8, s.metadata_9, s.metadata_10
+		FROM experimental.sessions AS s FINAL
+		WHERE ` + where + `
+		ORDER BY ` + sortColumns[req.Sort] + ` ` + strings.ToUpper(req.Order) + `, s.session_id
+		LIMIT ? OFFSET ?`
+	args = append(args, req.Limit, (req.Page-1)*req.Limit)
+	rows, err := c.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("can't search sessions: %s", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			sess                        Session
+			sessionID                   uint64
+			projectID                   uint16
+			datetime                    time.Time
+			userID, anonymousID, device *string
+			issueScore                  *uint32
+			metadata                    [10]*string
+		)
+		if err := rows.Scan(&sessionID, &projectID, &datetime, &sess.Duration, &sess.Platform,
+			&userID, &anonymousID, &sess.UserCountry, &sess.UserOS, &sess.UserBrowser, &device,
+			&sess.PagesCount, &sess.EventsCount, &sess.ErrorsCount, &issueScore, &sess.IssueTypes,
+			&metadata[0], &metadata[1], &metadata[2], &metadata[3], &metadata[4],
+			&metadata[5], &metadata[6], &metadata[7], &metadata[8], &metadata[9],
+		); err != nil {
+			return nil, fmt.Errorf("can't scan session: %s", err)
+		}
+		sess.SessionID = strconv.FormatUint(sessionID, 10)
+		sess.ProjectID = uint32(projectID)
+		sess.StartTs = datetime.UnixMilli()
+		sess.UserID = stringValue(userID)
+		sess.AnonymousID = stringValue(anonymousID)
+		sess.UserDevice = stringValue(device)
+		if issueScore != nil {
+			sess.IssueScore = *issueScore
+		}
+		for i, value := range metadata {
+			if value == nil {
+				continue
+			}
+			if sess.Metadata == nil {
+				sess.Metadata = make(map[string]string)
+			}
+			sess.Metadata[MetadataColumn(uint(i+1))] = *value
+		}
+		res.Sessions = append(res.Sessions, &sess)
+	}
+	return res, rows.Err()
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func (c *clickHouseSearcher) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,13 @@
+package search
+
+import "openreplay/backend/pkg/license"
+
+// NewSearcher connects to ClickHouse, sessions and events are taken from the experimental database
+func NewSearcher(url string) (Searcher, error) {
+	license.CheckLicense()
+	searcher, err := newClickHouseSearcher(url)
+	if err != nil {
+		return nil, err
+	}
+	return searcher, nil
+}
@@ -752,6 +752,67 @@ class Zustand(Message):
         self.state = state
 
 
+class AssistEvent(Message):
+    __id__ = 112
+
+    def __init__(self, timestamp, agent_id, agent_name, type, payload):
+        self.timestamp = timestamp
+        self.agent_id = agent_id
+        self.agent_name = agent_name
+        self.type = type
+        self.payload = payload
+
+
+class CanvasSnapshot(Message):
+    __id__ = 116
+
+    def __init__(self, node_id, timestamp, format, data):
+        self.node_id = node_id
+        self.timestamp = timestamp
+        self.format = format
+        self.data = data
+
+
+class CanvasNode(Message):
+    __id__ = 117
+
+    def __init__(self, node_id, timestamp, format, size):
+        self.node_id = node_id
+        self.timestamp = timestamp
+        self.format = format
+        self.size = size
+
+
+class StringDict(Message):
+    __id__ = 118
+
+    def __init__(self, key, base_key, prefix_length, suffix):
+        self.key = key
+        self.base_key = base_key
+        self.prefix_length = prefix_length
+        self.suffix = suffix
+
+
+class SetNodeAttributeDict(Message):
+    __id__ = 119
+
+    def __init__(self, id, name_key, value_key):
+        self.id = id
+        self.name_key = name_key
+        self.value_key = value_key
+
+
+class PluginIssue(Message):
+    __id__ = 120
+
+    def __init__(self, plugin, type, severity, context_string, payload):
+        self.plugin = plugin
+        self.type = type
+        self.severity = severity
+        self.context_string = context_string
+        self.payload = payload
+
+
 class IOSBatchMeta(Message):
     __id__ = 107
 
@@ -962,3 +1023,37 @@ class IOSIssueEvent(Message):
         self.payload = payload
 
 
+class MobileViewHierarchy(Message):
+    __id__ = 113
+
+    def __init__(self, timestamp, length, screen_name, width, height, tree):
+        self.timestamp = timestamp
+        self.length = length
+        self.screen_name = screen_name
+        self.width = width
+        self.height = height
+        self.tree = tree
+
+
+class MobileTouchEvent(Message):
+    __id__ = 114
+
+    def __init__(self, timestamp, length, phase, pointer_id, x, y, label):
+        self.timestamp = timestamp
+        self.length = length
+        self.phase = phase
+        self.pointer_id = pointer_id
+        self.x = x
+        self.y = y
+        self.label = label
+
+
+class MobileLifecycleEvent(Message):
+    __id__ = 115
+
+    def __init__(self, timestamp, length, state):
+        self.timestamp = timestamp
+        self.length = length
+        self.state = state
+
+
@@ -668,6 +668,55 @@ class MessageCodec(Codec):
                 state=self.read_string(reader)
             )
 
+        if message_id == 112:
+            return AssistEvent(
+                timestamp=self.read_uint(reader),
+                agent_id=self.read_uint(reader),
+                agent_name=self.read_string(reader),
+                type=self.read_string(reader),
+                payload=self.read_string(reader)
+            )
+
+        if message_id == 116:
+            return CanvasSnapshot(
+                node_id=self.read_uint(reader),
+                timestamp=self.read_uint(reader),
+                format=self.read_string(reader),
+                data=self.read_string(reader)
+            )
+
+        if message_id == 117:
+            return CanvasNode(
+                node_id=self.read_uint(reader),
+                timestamp=self.read_uint(reader),
+                format=self.read_string(reader),
+                size=self.read_uint(reader)
+            )
+
+        if message_id == 118:
+            return StringDict(
+                key=self.read_uint(reader),
+                base_key=self.read_uint(reader),
+                prefix_length=self.read_uint(reader),
+                suffix=self.read_string(reader)
+            )
+
+        if message_id == 119:
+            return SetNodeAttributeDict(
+                id=self.read_uint(reader),
+                name_key=self.read_uint(reader),
+                value_key=self.read_uint(reader)
+            )
+
+        if message_id == 120:
+            return PluginIssue(
+                plugin=self.read_string(reader),
+                type=self.read_string(reader),
+                severity=self.read_string(reader),
+                context_string=self.read_string(reader),
+                payload=self.read_string(reader)
+            )
+
         if message_id == 107:
             return IOSBatchMeta(
                 timestamp=self.read_uint(reader),
@@ -840,3 +889,31 @@ class MessageCodec(Codec):
                 payload=self.read_string(reader)
             )
 
+        if message_id == 113:
+            return MobileViewHierarchy(
+                timestamp=self.read_uint(reader),
+                length=self.read_uint(reader),
+                screen_name=self.read_string(reader),
+                width=self.read_uint(reader),
+                height=self.read_uint(reader),
+                tree=self.read_string(reader)
+            )
+
+        if message_id == 114:
+            return MobileTouchEvent(
+                timestamp=self.read_uint(reader),
+                length=self.read_uint(reader),
+                phase=self.read_string(reader),
+                pointer_id=self.read_uint(reader),
+                x=self.read_uint(reader),
+                y=self.read_uint(reader),
+                label=self.read_string(reader)
+            )
+
+        if message_id == 115:
+            return MobileLifecycleEvent(
+                timestamp=self.read_uint(reader),
+                length=self.read_uint(reader),
+                state=self.read_string(reader)
+            )
+
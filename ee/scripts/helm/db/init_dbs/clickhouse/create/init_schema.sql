@@ -192,6 +192,110 @@ CREATE TABLE IF NOT EXISTS experimental.user_viewed_errors
       ORDER BY (project_id, user_id, error_id)
       TTL _timestamp + INTERVAL 3 MONTH;
 
+CREATE TABLE IF NOT EXISTS experimental.metrics_minutely
+(
+    project_id UInt16,
+    name LowCardinality(String),
+    datetime   DateTime,
+    count      SimpleAggregateFunction(sum, UInt64),
+    sum_value  SimpleAggregateFunction(sum, Float64),
+    min_value  SimpleAggregateFunction(min, Float64),
+    max_value  SimpleAggregateFunction(max, Float64)
+) ENGINE = AggregatingMergeTree
+      PARTITION BY toYYYYMMDD(datetime)
+      ORDER BY (project_id, name, datetime);
+
+CREATE TABLE IF NOT EXISTS experimental.metrics_hourly
+(
+    project_id UInt16,
+    name LowCardinality(String),
+    datetime   DateTime,
+    count      SimpleAggregateFunction(sum, UInt64),
+    sum_value  SimpleAggregateFunction(sum, Float64),
+    min_value  SimpleAggregateFunction(min, Float64),
+    max_value  SimpleAggregateFunction(max, Float64)
+) ENGINE = AggregatingMergeTree
+      PARTITION BY toYYYYMM(datetime)
+      ORDER BY (project_id, name, datetime);
+
+CREATE TABLE IF NOT EXISTS experimental.metrics_daily
+(
+    project_id UInt16,
+    name LowCardinality(String),
+    datetime   DateTime,
+    count      SimpleAggregateFunction(sum, UInt64),
+    sum_value  SimpleAggregateFunction(sum, Float64),
+    min_value  SimpleAggregateFunction(min, Float64),
+    max_value  SimpleAggregateFunction(max, Float64)
+) ENGINE = AggregatingMergeTree
+      PARTITION BY toYear(datetime)
+      ORDER BY (project_id, name, datetime);
+
+-- Pre-aggregated dashboard metrics, dashboards read them instead of scanning events.
+-- Rows retried by the db service after transient errors can be counted twice, the values are approximate.
+CREATE TABLE IF NOT EXISTS experimental.request_errors_minutely
+(
+    project_id   UInt16,
+    datetime     DateTime,
+    url_host     String,
+    status_class UInt8, -- status / 100, 0 for requests failed without response
+    errors_count SimpleAggregateFunction(sum, UInt64)
+) ENGINE = AggregatingMergeTree
+      PARTITION BY toYYYYMM(datetime)
+      ORDER BY (project_id, datetime, url_host, status_class)
+      TTL datetime + INTERVAL 3 MONTH;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS experimental.request_errors_minutely_mv
+    TO experimental.request_errors_minutely
+AS
+SELECT project_id,
+       toStartOfMinute(datetime)               AS datetime,
+       ifNull(url_host, '')                    AS url_host,
+       toUInt8(intDiv(ifNull(status, 0), 100)) AS status_class,
+       count()                                 AS errors_count
+FROM experimental.events
+WHERE event_type = 'REQUEST'
+  AND (success = 0 OR status >= 400)
+GROUP BY project_id, datetime, url_host, status_class;
+
+-- Pages with the speed index are written by the db service with the country of their session,
+-- the table keeps no rows, they are aggregated by the view
+CREATE TABLE IF NOT EXISTS experimental.page_speed
+(
+    project_id   UInt16,
+    datetime     DateTime,
+    url          String,
+    user_country LowCardinality(String),
+    speed_index  UInt16
+) ENGINE = Null;
+
+CREATE TABLE IF NOT EXISTS experimental.page_speed_minutely
+(
+    project_id            UInt16,
+    datetime              DateTime,
+    url_host              String,
+    user_country          LowCardinality(String),
+    speed_index_count     SimpleAggregateFunction(sum, UInt64),
+    speed_index_sum       SimpleAggregateFunction(sum, UInt64),
+    speed_index_quantiles AggregateFunction(quantilesTDigest(0.5, 0.9, 0.95, 0.99), UInt16)
+) ENGINE = AggregatingMergeTree
+      PARTITION BY toYYYYMM(datetime)
+      ORDER BY (project_id, datetime, url_host, user_country)
+      TTL datetime + INTERVAL 3 MONTH;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS experimental.page_speed_minutely_mv
+    TO experimental.page_speed_minutely
+AS
+SELECT project_id,
+       toStartOfMinute(datetime)                                  AS datetime,
+       lower(domain(url))                                         AS url_host,
+       user_country,
+       count()                                                    AS speed_index_count,
+       sum(speed_index)                                           AS speed_index_sum,
+       quantilesTDigestState(0.5, 0.9, 0.95, 0.99)(speed_index) AS speed_index_quantiles
+FROM experimental.page_speed
+GROUP BY project_id, datetime, url_host, user_country;
+
 CREATE MATERIALIZED VIEW IF NOT EXISTS experimental.events_l7d_mv
             ENGINE = ReplacingMergeTree(_timestamp)
                 PARTITION BY toYYYYMM(datetime)
@@ -28,6 +28,7 @@ import AssistManager from './managers/AssistManager';
 
 import MFileReader from './messages/MFileReader';
 import { loadFiles, checkUnprocessedMobs } from './network/loadFiles';
+import AssetsBundles from './network/loadBundles';
 
 import { INITIAL_STATE as SUPER_INITIAL_STATE, State as SuperState } from './StatedScreen/StatedScreen';
 import { INITIAL_STATE as ASSIST_INITIAL_STATE, State as AssistState } from './managers/AssistManager';
@@ -83,6 +84,7 @@ export default class MessageDistributor extends StatedScreen {
 
   private resizeManager: ListWalker<SetViewportSize> = new ListWalker([]);
   private pagesManager: PagesManager;
+  private readonly assetsBundles: AssetsBundles = new AssetsBundles();
   private mouseMoveManager: MouseMoveManager;
   private assistManager: AssistManager;
 
@@ -101,7 +103,7 @@ export default class MessageDistributor extends StatedScreen {
 
   constructor(private readonly session: any /*Session*/, config: any, live: boolean) {
     super();
-    this.pagesManager = new PagesManager(this, this.session.isMobile)
+    this.pagesManager = new PagesManager(this, this.session.isMobile, this.assetsBundles)
     this.mouseMoveManager = new MouseMoveManager(this);
     this.assistManager = new AssistManager(session, this, config);
 
@@ -216,9 +218,12 @@ export default class MessageDistributor extends StatedScreen {
       this.processStateUpdates(msgs)
     }
 
-    loadFiles(this.session.mobsUrl,
+    // Bundled assets are resolved while messages are applied, the rest are loaded separately
+    this.assetsBundles.load(this.session.sessionId)
+    .catch(e => logger.warn("Can't load assets bundles", e))
+    .then(() => loadFiles(this.session.mobsUrl,
       onData
-    )
+    ))
     .then(() => this.onFileSuccessRead())
     .catch(async () => {
         checkUnprocessedMobs(this.session.sessionId)
@@ -289,7 +294,7 @@ export default class MessageDistributor extends StatedScreen {
 
     this.performanceTrackManager = new PerformanceTrackManager()
     this.windowNodeCounter = new WindowNodeCounter();
-    this.pagesManager = new PagesManager(this, this.session.isMobile)
+    this.pagesManager = new PagesManager(this, this.session.isMobile, this.assetsBundles)
     this.mouseMoveManager = new MouseMoveManager(this);
     this.activityManager = new ActivityManager(this.session.duration.milliseconds);
   }
@@ -545,6 +550,7 @@ export default class MessageDistributor extends StatedScreen {
     super.clean();
     update(INITIAL_STATE);
     this.assistManager.clear();
+    this.assetsBundles.clear();
   }
 
   public setLastRecordedMessageTime(time: number) {
@@ -2,6 +2,7 @@ import logger from 'App/logger';
 
 import type StatedScreen from '../../StatedScreen';
 import type { Message, SetNodeScroll, CreateElementNode } from '../../messages';
+import type AssetsBundles from '../../network/loadBundles';
 
 import ListWalker from '../ListWalker';
 import StylesManager, { rewriteNodeStyleSheet } from './StylesManager';
@@ -71,7 +72,8 @@ export default class DOMManager extends ListWalker<Message> {
   constructor(
     private readonly screen: StatedScreen,
     private readonly isMobile: boolean,
-    public readonly time: number
+    public readonly time: number,
+    private readonly bundles?: AssetsBundles,
   ) {
     super()
     this.stylesManager = new StylesManager(screen)
@@ -100,6 +102,11 @@ export default class DOMManager extends ListWalker<Message> {
     super.append(m)
   }
 
+  // Assets from the session bundles are served by blob urls, the rest keep the urls of the assets service
+  private resolveAsset(value: string): string {
+    return this.bundles ? this.bundles.resolve(value) : value
+  }
+
   private removeBodyScroll(id: number, vn: VElement): void {
     if (this.isMobile && this.upperBodyId === id) { // Need more type safety!
       (vn.node as HTMLBodyElement).style.overflow = "hidden"
@@ -223,6 +230,7 @@ export default class DOMManager extends ListWalker<Message> {
           //   value = value.replace("?", "%3F");
           // }
           if (!value.startsWith("http")) { return }
+          value = this.resolveAsset(value)
           // blob:... value happened here. https://foss.openreplay.com/3/session/7013553567419137
           // that resulted in that link being unable to load and having 4sec timeout in the below function.
           this.stylesManager.setStyleHandlers(vn.node as HTMLLinkElement, value);
@@ -230,7 +238,7 @@ export default class DOMManager extends ListWalker<Message> {
         if (vn.node.namespaceURI === 'http://www.w3.org/2000/svg' && value.startsWith("url(")) {
           value = "url(#" + (value.split("#")[1] ||")")
         }
-        vn.setAttribute(name, value)
+        vn.setAttribute(name, this.resolveAsset(value))
         this.removeBodyScroll(msg.id, vn)
         return
       case "remove_node_attribute":
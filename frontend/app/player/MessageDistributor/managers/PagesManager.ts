@@ -1,5 +1,6 @@
 import type StatedScreen from '../StatedScreen';
 import type { Message } from '../messages';
+import type AssetsBundles from '../network/loadBundles';
 
 import ListWalker from './ListWalker';
 import DOMManager from './DOM/DOMManager'; 
@@ -11,7 +12,7 @@ export default class PagesManager extends ListWalker<DOMManager> {
 	private isMobile: boolean;
 	private screen: StatedScreen;
 
-	constructor(screen: StatedScreen, isMobile: boolean) {
+	constructor(screen: StatedScreen, isMobile: boolean, private readonly bundles?: AssetsBundles) {
 		super()
 		this.screen = screen
 		this.isMobile = isMobile
@@ -22,7 +23,7 @@ export default class PagesManager extends ListWalker<DOMManager> {
 	*/
 	appendMessage(m: Message): void {
 		if (m.tp === "create_document") {
-			super.append(new DOMManager(this.screen, this.isMobile, m.time))
+			super.append(new DOMManager(this.screen, this.isMobile, m.time, this.bundles))
 		}
 		if (this.last === null) {
 			// Log wrong
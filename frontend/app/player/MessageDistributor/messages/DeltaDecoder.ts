@@ -0,0 +1,163 @@
+import RawMessageReader from './RawMessageReader';
+
+// Encoded session file starts with 0xFF "ORDELTA" instead of the index of the first message
+// (see backend/pkg/messages/delta)
+const MAGIC = [ 0xFF, 0x4F, 0x52, 0x44, 0x45, 0x4C, 0x54, 0x41 ]
+const RECORD_RAW = 0  // uint length, original bytes
+const RECORD_COPY = 1 // uint source message number, uint count, uint index of the first message
+const INDEX_SIZE = 8
+
+// Finds positions of the whole messages in the decoded data, copy records refer to them by number
+class MessageSpans extends RawMessageReader {
+  readonly starts: number[] = []
+  readonly ends: number[] = []
+  parsed: number = 0
+
+  parse(data: Uint8Array) {
+    this.buf = data
+    while (data.length - this.parsed > INDEX_SIZE) {
+      this.p = this.parsed + INDEX_SIZE
+      let msg
+      try {
+        msg = this.readMessage()
+      } catch (e) {
+        msg = null
+      }
+      if (!msg) {
+        return
+      }
+      this.starts.push(this.parsed)
+      this.ends.push(this.p)
+      this.parsed = this.p
+    }
+  }
+
+  add(start: number, end: number) {
+    this.starts.push(start)
+    this.ends.push(end)
+    this.parsed = end
+  }
+}
+
+/**
+ * Restores the original session file from the delta-encoded one. Parts of the file can be passed
+ * one by one, decode() returns the decoded bytes of every part.
+ */
+export default class DeltaDecoder {
+  private input: Uint8Array = new Uint8Array(0)
+  private out: Uint8Array = new Uint8Array(1024)
+  private outLength: number = 0
+  private rawLeft: number = 0
+  private magicRead: boolean = false
+  private readonly spans = new MessageSpans()
+
+  static isEncoded(data: Uint8Array): boolean {
+    return data.length >= MAGIC.length && MAGIC.every((b, i) => data[i] === b)
+  }
+
+  decode(data: Uint8Array): Uint8Array {
+    const input = new Uint8Array(this.input.length + data.length)
+    input.set(this.input)
+    input.set(data, this.input.length)
+    const from = this.outLength
+    let p = 0
+    if (!this.magicRead) {
+      if (input.length < MAGIC.length) {
+        this.input = input
+        return new Uint8Array(0)
+      }
+      if (!DeltaDecoder.isEncoded(input)) {
+        throw new Error("Session file isn't delta encoded")
+      }
+      p = MAGIC.length
+      this.magicRead = true
+    }
+    while (p < input.length) {
+      if (this.rawLeft > 0) {
+        const size = Math.min(this.rawLeft, input.length - p)
+        this.write(input.subarray(p, p + size))
+        p += size
+        this.rawLeft -= size
+        if (this.rawLeft === 0) {
+          this.spans.parse(this.out.subarray(0, this.outLength))
+        }
+        continue
+      }
+      const tag = input[p]
+      if (tag !== RECORD_RAW && tag !== RECORD_COPY) {
+        throw new Error(`Unknown delta record: ${ tag }`)
+      }
+      const record = readUints(input, p + 1, tag === RECORD_COPY ? 3 : 1)
+      if (record === null) {
+        break // the rest of the record is in the next part
+      }
+      if (tag === RECORD_RAW) {
+        this.rawLeft = record.values[0]
+      } else {
+        this.copy(record.values[0], record.values[1], record.values[2])
+      }
+      p = record.p
+    }
+    this.input = input.slice(p)
+    return this.out.slice(from, this.outLength)
+  }
+
+  private copy(src: number, count: number, index: number) {
+    const { starts, ends } = this.spans
+    if (this.spans.parsed !== this.outLength || src + count > starts.length) {
+      throw new Error(`Wrong delta copy source: ${ src }, count: ${ count }, messages: ${ starts.length }`)
+    }
+    for (let n = 0; n < count; n++) {
+      const start = this.outLength
+      const bodyStart = starts[src + n] + INDEX_SIZE, bodyEnd = ends[src + n]
+      this.reserve(INDEX_SIZE + bodyEnd - bodyStart)
+      writeIndex(this.out, start, index + n)
+      this.out.copyWithin(start + INDEX_SIZE, bodyStart, bodyEnd)
+      this.outLength += INDEX_SIZE + bodyEnd - bodyStart
+      this.spans.add(start, this.outLength)
+    }
+  }
+
+  private write(data: Uint8Array) {
+    this.reserve(data.length)
+    this.out.set(data, this.outLength)
+    this.outLength += data.length
+  }
+
+  private reserve(size: number) {
+    if (this.outLength + size <= this.out.length) {
+      return
+    }
+    const out = new Uint8Array(Math.max(this.out.length * 2, this.outLength + size))
+    out.set(this.out.subarray(0, this.outLength))
+    this.out = out
+  }
+}
+
+function readUints(buf: Uint8Array, p: number, count: number): { values: number[], p: number } | null {
+  const values: number[] = []
+  for (let i = 0; i < count; i++) {
+    let r = 0, s = 1, b
+    do {
+      if (p >= buf.length) {
+        return null
+      }
+      b = buf[ p++ ]
+      r += (b & 0x7F) * s
+      s *= 128
+    } while (b >= 0x80)
+    values.push(r)
+  }
+  return { values, p }
+}
+
+// Indexes are 64-bit little-endian, numbers are exact up to 2^53 which is enough for page << 32 + index
+function writeIndex(buf: Uint8Array, p: number, index: number) {
+  let lo = index % 0x100000000, hi = Math.floor(index / 0x100000000)
+  for (let i = 0; i < 4; i++) {
+    buf[p + i] = lo & 0xFF
+    buf[p + 4 + i] = hi & 0xFF
+    lo = lo >>> 8
+    hi = hi >>> 8
+  }
+}
@@ -2,16 +2,41 @@ import type { Message } from './message';
 import type { RawMessage } from './raw';
 import logger from 'App/logger';
 import RawMessageReader from './RawMessageReader';
+import DeltaDecoder from './DeltaDecoder';
 
 // TODO: composition instead of inheritance
 // needSkipMessage() and next() methods here use buf and p protected properties, 
 // which should be probably somehow incapsulated
 export default class MFileReader extends RawMessageReader {
   private pLastMessageID: number = 0
+  // Attribute strings written by the sink as dictionary entries
+  private readonly dict: Map<number, string> = new Map()
   private currentTime: number
+  // Set if the session file is delta encoded by the storage service, both parts of the file are decoded by it
+  private delta: DeltaDecoder | null = null
+  private started: boolean = false
   public error: boolean = false
   constructor(data: Uint8Array, private startTime?: number) {
-    super(data)
+    super()
+    this.append(data)
+  }
+
+  append(buf: Uint8Array) {
+    if (!this.started && buf.length > 0) {
+      this.started = true
+      if (DeltaDecoder.isEncoded(buf)) {
+        this.delta = new DeltaDecoder()
+      }
+    }
+    if (!this.delta) {
+      return super.append(buf)
+    }
+    try {
+      super.append(this.delta.decode(buf))
+    } catch (e) {
+      this.error = true
+      logger.error("Delta decoding error:", e)
+    }
   }
 
   private needSkipMessage(): boolean {
@@ -67,7 +92,21 @@ export default class MFileReader extends RawMessageReader {
       return this.next()
     } 
 
-    const msg = Object.assign(rMsg, {
+    if (rMsg.tp === "string_dict") {
+      const base = rMsg.baseKey ? this.dict.get(rMsg.baseKey) || "" : ""
+      this.dict.set(rMsg.key, base.slice(0, rMsg.prefixLength) + rMsg.suffix)
+      return this.next()
+    }
+    const resolved: RawMessage = rMsg.tp === "set_node_attribute_dict"
+      ? {
+        tp: "set_node_attribute",
+        id: rMsg.id,
+        name: this.dict.get(rMsg.nameKey) || "",
+        value: this.dict.get(rMsg.valueKey) || "",
+      }
+      : rMsg
+
+    const msg = Object.assign(resolved, {
       time: this.currentTime,
       _index: this.pLastMessageID,
     })
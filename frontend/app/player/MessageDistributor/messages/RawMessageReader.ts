@@ -210,6 +210,16 @@ export default class RawMessageReader extends PrimitiveReader {
       };
     }
     
+    case 27: {
+      const name = this.readString(); if (name === null) { return resetPointer() }
+      const payload = this.readString(); if (payload === null) { return resetPointer() }
+      return {
+        tp: "raw_custom_event",
+        name,
+        payload,
+      };
+    }
+    
     case 37: {
       const id = this.readUint(); if (id === null) { return resetPointer() }
       const rule = this.readString(); if (rule === null) { return resetPointer() }
@@ -538,6 +548,62 @@ export default class RawMessageReader extends PrimitiveReader {
       };
     }
     
+    case 112: {
+      const timestamp = this.readUint(); if (timestamp === null) { return resetPointer() }
+      const agentID = this.readUint(); if (agentID === null) { return resetPointer() }
+      const agentName = this.readString(); if (agentName === null) { return resetPointer() }
+      const type = this.readString(); if (type === null) { return resetPointer() }
+      const payload = this.readString(); if (payload === null) { return resetPointer() }
+      return {
+        tp: "assist_event",
+        timestamp,
+        agentID,
+        agentName,
+        type,
+        payload,
+      };
+    }
+    
+    case 117: {
+      const nodeID = this.readUint(); if (nodeID === null) { return resetPointer() }
+      const timestamp = this.readUint(); if (timestamp === null) { return resetPointer() }
+      const format = this.readString(); if (format === null) { return resetPointer() }
+      const size = this.readUint(); if (size === null) { return resetPointer() }
+      return {
+        tp: "canvas_node",
+        nodeID,
+        timestamp,
+        format,
+        size,
+      };
+    }
+    
+    case 118: {
+      const key = this.readUint(); if (key === null) { return resetPointer() }
+      const baseKey = this.readUint(); if (baseKey === null) { return resetPointer() }
+      const prefixLength = this.readUint(); if (prefixLength === null) { return resetPointer() }
+      const suffix = this.readString(); if (suffix === null) { return resetPointer() }
+      return {
+        tp: "string_dict",
+        key,
+        baseKey,
+        prefixLength,
+        suffix,
+      };
+    }
+    
+    case 119: {
+      const id = this.readUint(); if (id === null) { return resetPointer() }
+      const nameKey = this.readUint(); if (nameKey === null) { return resetPointer() }
+      const valueKey = this.readUint(); if (valueKey === null) { return resetPointer() }
+      return {
+        tp: "set_node_attribute_dict",
+        id,
+        nameKey,
+        valueKey,
+      };
+    }
+    
     case 90: {
       const timestamp = this.readUint(); if (timestamp === null) { return resetPointer() }
       const projectID = this.readUint(); if (projectID === null) { return resetPointer() }
@@ -664,6 +730,56 @@ export default class RawMessageReader extends PrimitiveReader {
       };
     }
     
+    case 113: {
+      const timestamp = this.readUint(); if (timestamp === null) { return resetPointer() }
+      const length = this.readUint(); if (length === null) { return resetPointer() }
+      const screenName = this.readString(); if (screenName === null) { return resetPointer() }
+      const width = this.readUint(); if (width === null) { return resetPointer() }
+      const height = this.readUint(); if (height === null) { return resetPointer() }
+      const tree = this.readString(); if (tree === null) { return resetPointer() }
+      return {
+        tp: "mobile_view_hierarchy",
+        timestamp,
+        length,
+        screenName,
+        width,
+        height,
+        tree,
+      };
+    }
+    
+    case 114: {
+      const timestamp = this.readUint(); if (timestamp === null) { return resetPointer() }
+      const length = this.readUint(); if (length === null) { return resetPointer() }
+      const phase = this.readString(); if (phase === null) { return resetPointer() }
+      const pointerID = this.readUint(); if (pointerID === null) { return resetPointer() }
+      const x = this.readUint(); if (x === null) { return resetPointer() }
+      const y = this.readUint(); if (y === null) { return resetPointer() }
+      const label = this.readString(); if (label === null) { return resetPointer() }
+      return {
+        tp: "mobile_touch_event",
+        timestamp,
+        length,
+        phase,
+        pointerID,
+        x,
+        y,
+        label,
+      };
+    }
+    
+    case 115: {
+      const timestamp = this.readUint(); if (timestamp === null) { return resetPointer() }
+      const length = this.readUint(); if (length === null) { return resetPointer() }
+      const state = this.readString(); if (state === null) { return resetPointer() }
+      return {
+        tp: "mobile_lifecycle_event",
+        timestamp,
+        length,
+        state,
+      };
+    }
+    
     default:
       throw new Error(`Unrecognizable message type: ${ tp }; Pointer at the position ${this.p} of ${this.buf.length}`)
       return null;
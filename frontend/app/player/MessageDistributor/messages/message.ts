@@ -22,6 +22,7 @@ import type {
   RawSetInputChecked,
   RawMouseMove,
   RawConsoleLog,
+  RawRawCustomEvent,
   RawCssInsertRule,
   RawCssDeleteRule,
   RawFetch,
@@ -49,6 +50,10 @@ import type {
   RawAdoptedSsAddOwner,
   RawAdoptedSsRemoveOwner,
   RawZustand,
+  RawAssistEvent,
+  RawCanvasNode,
+  RawStringDict,
+  RawSetNodeAttributeDict,
   RawIosSessionStart,
   RawIosCustomEvent,
   RawIosScreenChanges,
@@ -56,6 +61,9 @@ import type {
   RawIosPerformanceEvent,
   RawIosLog,
   RawIosNetworkCall,
+  RawMobileViewHierarchy,
+  RawMobileTouchEvent,
+  RawMobileLifecycleEvent,
 } from './raw'
 
 export type Message = RawMessage & Timed
@@ -97,6 +105,8 @@ export type MouseMove = RawMouseMove & Timed
 
 export type ConsoleLog = RawConsoleLog & Timed
 
+export type RawCustomEvent = RawRawCustomEvent & Timed
+
 export type CssInsertRule = RawCssInsertRule & Timed
 
 export type CssDeleteRule = RawCssDeleteRule & Timed
@@ -151,6 +161,14 @@ export type AdoptedSsRemoveOwner = RawAdoptedSsRemoveOwner & Timed
 
 export type Zustand = RawZustand & Timed
 
+export type AssistEvent = RawAssistEvent & Timed
+
+export type CanvasNode = RawCanvasNode & Timed
+
+export type StringDict = RawStringDict & Timed
+
+export type SetNodeAttributeDict = RawSetNodeAttributeDict & Timed
+
 export type IosSessionStart = RawIosSessionStart & Timed
 
 export type IosCustomEvent = RawIosCustomEvent & Timed
@@ -165,3 +183,9 @@ export type IosLog = RawIosLog & Timed
 
 export type IosNetworkCall = RawIosNetworkCall & Timed
 
+export type MobileViewHierarchy = RawMobileViewHierarchy & Timed
+
+export type MobileTouchEvent = RawMobileTouchEvent & Timed
+
+export type MobileLifecycleEvent = RawMobileLifecycleEvent & Timed
+
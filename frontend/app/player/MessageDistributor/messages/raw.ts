@@ -116,6 +116,12 @@ export interface RawConsoleLog {
   value: string,
 }
 
+export interface RawRawCustomEvent {
+  tp: "raw_custom_event",
+  name: string,
+  payload: string,
+}
+
 export interface RawCssInsertRule {
   tp: "css_insert_rule",
   id: number,
@@ -307,6 +313,38 @@ export interface RawZustand {
   state: string,
 }
 
+export interface RawAssistEvent {
+  tp: "assist_event",
+  timestamp: number,
+  agentID: number,
+  agentName: string,
+  type: string,
+  payload: string,
+}
+
+export interface RawCanvasNode {
+  tp: "canvas_node",
+  nodeID: number,
+  timestamp: number,
+  format: string,
+  size: number,
+}
+
+export interface RawStringDict {
+  tp: "string_dict",
+  key: number,
+  baseKey: number,
+  prefixLength: number,
+  suffix: string,
+}
+
+export interface RawSetNodeAttributeDict {
+  tp: "set_node_attribute_dict",
+  id: number,
+  nameKey: number,
+  valueKey: number,
+}
+
 export interface RawIosSessionStart {
   tp: "ios_session_start",
   timestamp: number,
@@ -377,5 +415,33 @@ export interface RawIosNetworkCall {
   status: number,
 }
 
+export interface RawMobileViewHierarchy {
+  tp: "mobile_view_hierarchy",
+  timestamp: number,
+  length: number,
+  screenName: string,
+  width: number,
+  height: number,
+  tree: string,
+}
+
+export interface RawMobileTouchEvent {
+  tp: "mobile_touch_event",
+  timestamp: number,
+  length: number,
+  phase: string,
+  pointerID: number,
+  x: number,
+  y: number,
+  label: string,
+}
+
+export interface RawMobileLifecycleEvent {
+  tp: "mobile_lifecycle_event",
+  timestamp: number,
+  length: number,
+  state: string,
+}
+
 
-export type RawMessage = RawTimestamp | RawSetPageLocation | RawSetViewportSize | RawSetViewportScroll | RawCreateDocument | RawCreateElementNode | RawCreateTextNode | RawMoveNode | RawRemoveNode | RawSetNodeAttribute | RawRemoveNodeAttribute | RawSetNodeData | RawSetCssData | RawSetNodeScroll | RawSetInputValue | RawSetInputChecked | RawMouseMove | RawConsoleLog | RawCssInsertRule | RawCssDeleteRule | RawFetch | RawProfiler | RawOTable | RawRedux | RawVuex | RawMobX | RawNgRx | RawGraphQl | RawPerformanceTrack | RawConnectionInformation | RawSetPageVisibility | RawLongTask | RawSetNodeAttributeURLBased | RawSetCssDataURLBased | RawCssInsertRuleURLBased | RawMouseClick | RawCreateIFrameDocument | RawAdoptedSsReplaceURLBased | RawAdoptedSsReplace | RawAdoptedSsInsertRuleURLBased | RawAdoptedSsInsertRule | RawAdoptedSsDeleteRule | RawAdoptedSsAddOwner | RawAdoptedSsRemoveOwner | RawZustand | RawIosSessionStart | RawIosCustomEvent | RawIosScreenChanges | RawIosClickEvent | RawIosPerformanceEvent | RawIosLog | RawIosNetworkCall;
+export type RawMessage = RawTimestamp | RawSetPageLocation | RawSetViewportSize | RawSetViewportScroll | RawCreateDocument | RawCreateElementNode | RawCreateTextNode | RawMoveNode | RawRemoveNode | RawSetNodeAttribute | RawRemoveNodeAttribute | RawSetNodeData | RawSetCssData | RawSetNodeScroll | RawSetInputValue | RawSetInputChecked | RawMouseMove | RawConsoleLog | RawRawCustomEvent | RawCssInsertRule | RawCssDeleteRule | RawFetch | RawProfiler | RawOTable | RawRedux | RawVuex | RawMobX | RawNgRx | RawGraphQl | RawPerformanceTrack | RawConnectionInformation | RawSetPageVisibility | RawLongTask | RawSetNodeAttributeURLBased | RawSetCssDataURLBased | RawCssInsertRuleURLBased | RawMouseClick | RawCreateIFrameDocument | RawAdoptedSsReplaceURLBased | RawAdoptedSsReplace | RawAdoptedSsInsertRuleURLBased | RawAdoptedSsInsertRule | RawAdoptedSsDeleteRule | RawAdoptedSsAddOwner | RawAdoptedSsRemoveOwner | RawZustand | RawAssistEvent | RawCanvasNode | RawStringDict | RawSetNodeAttributeDict | RawIosSessionStart | RawIosCustomEvent | RawIosScreenChanges | RawIosClickEvent | RawIosPerformanceEvent | RawIosLog | RawIosNetworkCall | RawMobileViewHierarchy | RawMobileTouchEvent | RawMobileLifecycleEvent;
@@ -62,6 +62,12 @@ export const TP_MAP = {
   76: "adopted_ss_add_owner",
   77: "adopted_ss_remove_owner",
   79: "zustand",
+  112: "assist_event",
+  116: "canvas_snapshot",
+  117: "canvas_node",
+  118: "string_dict",
+  119: "set_node_attribute_dict",
+  120: "plugin_issue",
   90: "ios_session_start",
   93: "ios_custom_event",
   96: "ios_screen_changes",
@@ -69,4 +75,7 @@ export const TP_MAP = {
   102: "ios_performance_event",
   103: "ios_log",
   105: "ios_network_call",
+  113: "mobile_view_hierarchy",
+  114: "mobile_touch_event",
+  115: "mobile_lifecycle_event",
 } as const
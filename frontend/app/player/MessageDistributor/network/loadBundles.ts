@@ -0,0 +1,71 @@
+// Small assets of the session are uploaded by the assets service in bundles /bundles/<sessionId>/<part>:
+// 4 bytes of the little-endian index length, JSON index and data of all items one after another
+interface BundleItem {
+  path: string
+  contentType: string
+  offset: number
+  size: number
+}
+
+const MAX_BUNDLE_PARTS = 100
+
+export default class AssetsBundles {
+  private readonly urls: Map<string, string> = new Map() // asset path -> blob url
+  private readonly host: string
+
+  // @ts-ignore  ?global ENV type
+  constructor(host: string = window.env.ASSETS_HOST || '') {
+    this.host = host.replace(/\/+$/, '')
+  }
+
+  /**
+   * Loads parts one by one until the missing one. Assets which aren't bundled (or all of them
+   * if bundles can't be loaded) are loaded by the player from their own urls.
+   */
+  async load(sessionId: string): Promise<void> {
+    if (!this.host) {
+      return
+    }
+    for (let part = 0; part < MAX_BUNDLE_PARTS; part++) {
+      const r = await window.fetch(`${this.host}/bundles/${sessionId}/${part}`)
+      if (!r.ok) {
+        return
+      }
+      this.add(new Uint8Array(await r.arrayBuffer()))
+    }
+  }
+
+  private add(data: Uint8Array) {
+    if (data.length < 4) {
+      throw new Error(`Wrong assets bundle size: ${ data.length }`)
+    }
+    const indexSize = new DataView(data.buffer, data.byteOffset, 4).getUint32(0, true)
+    const index: BundleItem[] = JSON.parse(new TextDecoder().decode(data.subarray(4, 4 + indexSize)))
+    const body = 4 + indexSize
+    index.forEach(item => {
+      if (this.urls.has(item.path) || body + item.offset + item.size > data.length) {
+        return
+      }
+      const blob = new Blob([ data.subarray(body + item.offset, body + item.offset + item.size) ], { type: item.contentType })
+      this.urls.set(item.path, URL.createObjectURL(blob))
+    })
+  }
+
+  /** Returns the url of the bundled asset or the original url */
+  resolve(url: string): string {
+    if (this.urls.size === 0 || !url.startsWith(this.host + '/')) {
+      return url
+    }
+    const [ withoutFragment, fragment ] = url.split('#', 2)
+    const blobURL = this.urls.get(withoutFragment.slice(this.host.length))
+    if (!blobURL) {
+      return url
+    }
+    return fragment !== undefined ? blobURL + '#' + fragment : blobURL
+  }
+
+  clear() {
+    this.urls.forEach(url => URL.revokeObjectURL(url))
+    this.urls.clear()
+  }
+}
@@ -170,3 +170,29 @@ message 111, 'IOSIssueEvent', :seq_index => true do
   string 'Context'
   string 'Payload'
 end
+
+# Mobile replay messages, they are shared by iOS and Android SDKs
+message 113, 'MobileViewHierarchy', :replayer => true do
+  uint 'Timestamp'
+  uint 'Length'
+  string 'ScreenName'
+  uint 'Width'
+  uint 'Height'
+  string 'Tree' # JSON of the view tree: type, frame, text and children of every view
+end
+
+message 114, 'MobileTouchEvent', :replayer => true, :seq_index => true do
+  uint 'Timestamp'
+  uint 'Length'
+  string 'Phase' # Possible values ("began", "moved", "ended", "cancelled")
+  uint 'PointerID'
+  uint 'X'
+  uint 'Y'
+  string 'Label'
+end
+
+message 115, 'MobileLifecycleEvent', :replayer => true, :seq_index => true do
+  uint 'Timestamp'
+  uint 'Length'
+  string 'State' # Possible values ("launch", "foreground", "background", "terminate", "low_memory")
+end
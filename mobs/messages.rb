@@ -163,7 +163,7 @@ message 26, 'IntegrationEvent', :tracker => false, :replayer => false do
   string 'Message'
   string 'Payload'
 end
-message 27, 'RawCustomEvent', :replayer => false do
+message 27, 'RawCustomEvent' do
   string 'Name'
   string 'Payload'
 end
@@ -469,4 +469,48 @@ message 79, 'Zustand' do
   string 'State'
 end
 
+# Control events of the assist agent, merged into the recording by the backend
+message 112, 'AssistEvent', :tracker => false do
+  uint 'Timestamp'
+  uint 'AgentID'
+  string 'AgentName'
+  string 'Type'
+  string 'Payload'
+end
+# Data is the base64 encoded image, the sink stores it outside of the recording
+message 116, 'CanvasSnapshot', :replayer => false do
+  uint 'NodeID'
+  uint 'Timestamp'
+  string 'Format'
+  string 'Data'
+end
+# Replaces CanvasSnapshot in the recording, the image is uploaded to canvas/<sessionID>/<NodeID>_<Timestamp>.<Format>
+message 117, 'CanvasNode', :tracker => false do
+  uint 'NodeID'
+  uint 'Timestamp'
+  string 'Format'
+  uint 'Size'
+end
+# Written by the sink instead of repeated attribute strings, Value is Suffix appended to PrefixLength bytes of
+# the BaseKey string (0 means no base). Keys are redefined after sink restarts, readers keep the last definition.
+message 118, 'StringDict', :tracker => false do
+  uint 'Key'
+  uint 'BaseKey'
+  uint 'PrefixLength'
+  string 'Suffix'
+end
+message 119, 'SetNodeAttributeDict', :tracker => false do
+  uint 'ID'
+  uint 'NameKey'
+  uint 'ValueKey'
+end
+# Issue detected by a tracker plugin, the heuristics service validates it and saves it as a custom issue
+message 120, 'PluginIssue', :replayer => false do
+  string 'Plugin'
+  string 'Type'
+  string 'Severity'
+  string 'ContextString'
+  string 'Payload'
+end
+
 # 80 -- 90 reserved
\ No newline at end of file
@@ -69,6 +69,21 @@ class Attribute
     end
   end
 
+  def type_proto
+    case @type
+    when :int
+      'sint64'
+    when :uint
+      'uint64'
+    when :string
+      'string'
+    when :data
+      'bytes'
+    when :boolean
+      'bool'
+    end
+  end
+
   def lengh_encoded
     case @type
     when :string, :data
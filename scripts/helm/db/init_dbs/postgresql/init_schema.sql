@@ -6,7 +6,7 @@ CREATE SCHEMA IF NOT EXISTS events;
 CREATE OR REPLACE FUNCTION openreplay_version()
     RETURNS text AS
 $$
-SELECT 'v1.8.1'
+SELECT 'v1.9.0'
 $$ LANGUAGE sql IMMUTABLE;
 
 -- --- accounts.sql ---
@@ -192,6 +192,7 @@ $$
                 metadata_9                text                                        DEFAULT NULL,
                 metadata_10               text                                        DEFAULT NULL,
                 save_request_payloads     boolean                     NOT NULL        DEFAULT FALSE,
+                retention_days            integer                     NULL            DEFAULT NULL CHECK (retention_days > 0),
                 gdpr                      jsonb                       NOT NULL        DEFAULT '{
                   "maskEmails": true,
                   "sampleRate": 33,
@@ -437,6 +438,8 @@ $$
                 errors_count            integer      NOT NULL DEFAULT 0,
                 watchdogs_score         bigint       NOT NULL DEFAULT 0,
                 issue_score             bigint       NOT NULL DEFAULT 0,
+                frustration_score       smallint     NOT NULL DEFAULT 0,
+                engagement_score        smallint     NOT NULL DEFAULT 0,
                 issue_types             issue_type[] NOT NULL DEFAULT '{}'::issue_type[],
                 utm_source              text         NULL     DEFAULT NULL,
                 utm_medium              text         NULL     DEFAULT NULL,
@@ -455,6 +458,8 @@ $$
                 metadata_10             text                  DEFAULT NULL
             );
             CREATE INDEX sessions_project_id_start_ts_idx ON sessions (project_id, start_ts);
+            CREATE INDEX sessions_project_id_frustration_score_idx ON sessions (project_id, frustration_score DESC);
+            CREATE INDEX sessions_project_id_engagement_score_idx ON sessions (project_id, engagement_score DESC);
             CREATE INDEX sessions_project_id_user_id_idx ON sessions (project_id, user_id);
             CREATE INDEX sessions_project_id_user_anonymous_id_idx ON sessions (project_id, user_anonymous_id);
             CREATE INDEX sessions_project_id_user_device_idx ON sessions (project_id, user_device);
@@ -530,6 +535,56 @@ $$
             );
             CREATE INDEX user_favorite_sessions_user_id_session_id_idx ON user_favorite_sessions (user_id, session_id);
 
+            CREATE TABLE user_attributes
+            (
+                project_id integer                     NOT NULL REFERENCES projects (project_id) ON DELETE CASCADE,
+                user_id    text                        NOT NULL,
+                attributes jsonb                       NOT NULL DEFAULT '{}'::jsonb,
+                updated_at timestamp without time zone NOT NULL DEFAULT (now() at time zone 'utc'),
+                PRIMARY KEY (project_id, user_id)
+            );
+
+            CREATE TABLE tracker_configs
+            (
+                project_id integer                     NOT NULL PRIMARY KEY REFERENCES projects (project_id) ON DELETE CASCADE,
+                config     jsonb                       NOT NULL DEFAULT '{}'::jsonb,
+                updated_at timestamp without time zone NOT NULL DEFAULT (now() at time zone 'utc')
+            );
+
+            CREATE TABLE server_keys
+            (
+                key_id      integer generated BY DEFAULT AS IDENTITY PRIMARY KEY,
+                key_hash    text                        NOT NULL UNIQUE,
+                name        text                        NOT NULL,
+                project_ids integer[]                   NOT NULL,
+                created_at  timestamp without time zone NOT NULL DEFAULT (now() at time zone 'utc'),
+                revoked_at  timestamp without time zone NULL     DEFAULT NULL
+            );
+
+            CREATE TABLE erasure_requests
+            (
+                erasure_id   integer generated BY DEFAULT AS IDENTITY PRIMARY KEY,
+                project_id   integer                     NOT NULL REFERENCES projects (project_id) ON DELETE CASCADE,
+                user_id      text                        NULL,
+                anonymous_id text                        NULL,
+                requested_by integer                     NOT NULL,
+                status       text                        NOT NULL DEFAULT 'pending',
+                report       jsonb                       NULL,
+                created_at   timestamp without time zone NOT NULL DEFAULT (now() at time zone 'utc'),
+                finished_at  timestamp without time zone NULL,
+                CHECK (user_id IS NOT NULL OR anonymous_id IS NOT NULL)
+            );
+            CREATE INDEX erasure_requests_status_idx ON erasure_requests (status) WHERE status = 'pending';
+
+            CREATE TABLE tenant_routes
+            (
+                project_id     integer                     NOT NULL PRIMARY KEY REFERENCES projects (project_id) ON DELETE CASCADE,
+                tenant         text                        NOT NULL,
+                retention_days integer                     NULL     DEFAULT NULL CHECK (retention_days > 0),
+                created_at     timestamp without time zone NOT NULL DEFAULT (now() at time zone 'utc')
+            );
+            CREATE INDEX tenant_routes_tenant_idx ON tenant_routes (tenant);
+
 -- --- assignments.sql ---
 
             create table assigned_sessions
@@ -880,7 +935,8 @@ $$
                 created_at   timestamp default timezone('utc'::text, now()) NOT NULL,
                 updated_at   timestamp default timezone('utc'::text, now()) NULL,
                 start_at     timestamp                                      NOT NULL,
-                errors       text                                           NULL
+                errors       text                                           NULL,
+                certificate  jsonb                                          NULL
             );
             CREATE INDEX jobs_status_idx ON jobs (status);
             CREATE INDEX jobs_start_at_idx ON jobs (start_at);
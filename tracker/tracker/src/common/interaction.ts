@@ -13,10 +13,18 @@ type Start = {
   url: string
 } & Options
 
+// Sent by the backend while it's overloaded, in start responses and bodies of rejected batches
+export interface OverloadHint {
+  reason: string
+  retryAfter: number // ms
+  sampleRate: number // percent of sessions to keep recording
+}
+
 type Auth = {
   type: 'auth'
   token: string
   beaconSizeLimit?: number
+  overload?: OverloadHint
 }
 
 export type WorkerMessageData = null | 'stop' | Start | Auth | Array<Message>
@@ -433,6 +433,7 @@ export default class App {
           projectID,
           beaconSizeLimit,
           startTimestamp, // real startTS, derived from sessionID
+          overload,
         } = r
         if (
           typeof token !== 'string' ||
@@ -454,6 +455,7 @@ export default class App {
           type: 'auth',
           token,
           beaconSizeLimit,
+          overload,
         }
         this.worker.postMessage(startWorkerMsg)
 
@@ -719,3 +719,34 @@ export function Zustand(
   ]
 }
 
+export function CanvasSnapshot(
+  nodeID: number,
+  timestamp: number,
+  format: string,
+  data: string,
+): Messages.CanvasSnapshot {
+  return [
+    Messages.Type.CanvasSnapshot,
+    nodeID,
+    timestamp,
+    format,
+    data,
+  ]
+}
+
+export function PluginIssue(
+  plugin: string,
+  type: string,
+  severity: string,
+  contextString: string,
+  payload: string,
+): Messages.PluginIssue {
+  return [
+    Messages.Type.PluginIssue,
+    plugin,
+    type,
+    severity,
+    contextString,
+    payload,
+  ]
+}
@@ -234,6 +234,14 @@ export default class MessageEncoder extends PrimitiveEncoder {
       return  this.string(msg[1]) && this.string(msg[2]) 
     break
     
+    case Messages.Type.CanvasSnapshot:
+      return  this.uint(msg[1]) && this.uint(msg[2]) && this.string(msg[3]) && this.string(msg[4]) 
+    break
+    
+    case Messages.Type.PluginIssue:
+      return  this.string(msg[1]) && this.string(msg[2]) && this.string(msg[3]) && this.string(msg[4]) && this.string(msg[5]) 
+    break
+    
     }
   }
 
@@ -1,3 +1,5 @@
+import type { OverloadHint } from '../common/interaction.js'
+
 const INGEST_PATH = '/v1/web/i'
 
 const KEEPALIVE_SIZE_LIMIT = 64 << 10 // 64 kB
@@ -26,6 +28,7 @@ export default class QueueSender {
   private readonly queue: Array<Uint8Array> = []
   private readonly ingestURL
   private token: string | null = null
+  private sampleRate = 100
   constructor(
     ingestBaseURL: string,
     private readonly onUnauthorised: () => any,
@@ -40,6 +43,19 @@ export default class QueueSender {
     this.token = token
   }
 
+  /**
+   * Returns false if the session isn't kept by the sample rate of the hint. Rates of later hints
+   * are applied only to sessions kept by the previous ones, so the total rate is the lowest one.
+   */
+  applyOverloadHint(hint: OverloadHint): boolean {
+    if (typeof hint.sampleRate !== 'number' || hint.sampleRate >= this.sampleRate) {
+      return true
+    }
+    const kept = Math.random() * this.sampleRate < hint.sampleRate
+    this.sampleRate = hint.sampleRate
+    return kept
+  }
+
   push(batch: Uint8Array): void {
     if (this.busy || !this.token) {
       this.queue.push(batch)
@@ -57,6 +73,22 @@ export default class QueueSender {
     setTimeout(() => this.sendBatch(batch), this.ATTEMPT_TIMEOUT * this.attemptsCount)
   }
 
+  // Rejected batch is sent again when the backend asks, these attempts aren't counted
+  private overloaded(batch: Uint8Array, hint: OverloadHint | undefined, retryAfterHeader: string | null): void {
+    if (hint && !this.applyOverloadHint(hint)) {
+      this.busy = false
+      this.onFailure()
+      return
+    }
+    let retryAfter = this.ATTEMPT_TIMEOUT
+    if (hint && typeof hint.retryAfter === 'number') {
+      retryAfter = hint.retryAfter
+    } else if (retryAfterHeader && !isNaN(parseInt(retryAfterHeader))) {
+      retryAfter = parseInt(retryAfterHeader) * 1000
+    }
+    setTimeout(() => this.sendBatch(batch), retryAfter)
+  }
+
   // would be nice to use Beacon API, but it is not available in WebWorker
   private sendBatch(batch: Uint8Array): void {
     this.busy = true
@@ -76,6 +108,11 @@ export default class QueueSender {
           this.busy = false
           this.onUnauthorised()
           return
+        } else if (r.status === 503) {
+          r.json()
+            .catch(() => ({}))
+            .then((body) => this.overloaded(batch, body.hint, r.headers.get('Retry-After')))
+          return
         } else if (r.status >= 400) {
           this.retry(batch)
           return
@@ -128,6 +128,10 @@ self.onmessage = ({ data }: MessageEvent<WorkerMessageData>): any => {
     if (!writer) {
       throw new Error('WebWorker: writer not initialised. Received auth.')
     }
+    if (data.overload && !sender.applyOverloadHint(data.overload)) {
+      initiateFailure()
+      return
+    }
     sender.authorise(data.token)
     data.beaconSizeLimit && writer.setBeaconSizeLimit(data.beaconSizeLimit)
     return